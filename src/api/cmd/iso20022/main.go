@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"api/config"
+	"api/internal/auth"
+	"api/internal/iso20022"
+)
+
+// main exports payments as an ISO 20022 pain.001 or camt.054 file
+func main() {
+	var (
+		messageType string
+		filter      iso20022.Filter
+		messageID   string
+		out         string
+		debtor      iso20022.Debtor
+		tenantID    int
+	)
+
+	if cfg := config.NewConfig(); cfg != nil {
+		debtor = iso20022.Debtor{Name: cfg.DebtorName, IBAN: cfg.DebtorIBAN, BIC: cfg.DebtorBIC}
+	}
+
+	flag.IntVar(&tenantID, "tenant", 0, "Tenant whose payments are exported")
+	flag.StringVar(&messageType, "type", iso20022.MessagePain001, "Message type: pain.001 or camt.054")
+	flag.StringVar(&filter.Status, "status", "", "Payment status (pain.001 defaults to pending, camt.054 to completed and captured)")
+	flag.StringVar(&filter.From, "from", "", "First payment date (YYYY-MM-DD)")
+	flag.StringVar(&filter.To, "to", "", "Last payment date (YYYY-MM-DD)")
+	flag.StringVar(&filter.BatchID, "batch", "", "Only payments created by this payment batch")
+	flag.StringVar(&messageID, "message-id", "", "Message ID (generated when omitted)")
+	flag.StringVar(&out, "out", "", "Output file (stdout when omitted)")
+	flag.StringVar(&debtor.Name, "debtor-name", debtor.Name, "Debtor name")
+	flag.StringVar(&debtor.IBAN, "debtor-iban", debtor.IBAN, "Debtor account IBAN")
+	flag.StringVar(&debtor.BIC, "debtor-bic", debtor.BIC, "Debtor agent BIC")
+	flag.Parse()
+
+	if tenantID <= 0 {
+		fmt.Fprintln(os.Stderr, "Please provide a tenant with -tenant")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx := auth.WithTenantID(context.Background(), tenantID)
+	exporter := iso20022.NewExporterWithRepo(iso20022.NewExportRepo(), debtor)
+	body, err := exporter.Export(ctx, messageType, filter, messageID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error exporting payments: %v\n", err)
+		os.Exit(1)
+	}
+
+	if out == "" {
+		os.Stdout.Write(body)
+		return
+	}
+	if err := os.WriteFile(out, body, 0o644); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing %s: %v\n", out, err)
+		os.Exit(1)
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %s to %s\n", messageType, out)
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"api/internal/auth"
+	"api/internal/reconcile"
+)
+
+// main imports a bank statement file and prints the reconciliation queues
+func main() {
+	var (
+		file      string
+		format    string
+		tolerance int
+		user      string
+		tenantID  int
+	)
+
+	flag.IntVar(&tenantID, "tenant", 0, "Tenant the statement belongs to")
+	flag.StringVar(&file, "file", "", "Statement file (CSV or camt.053 XML)")
+	flag.StringVar(&format, "format", "", "Statement format: csv or camt.053 (detected when omitted)")
+	flag.IntVar(&tolerance, "tolerance", reconcile.DefaultDateToleranceDays, "Booking date tolerance in days")
+	flag.StringVar(&user, "user", "system", "User recorded as the importer")
+	flag.Parse()
+
+	if file == "" {
+		fmt.Fprintln(os.Stderr, "Please provide a statement file with -file")
+		flag.Usage()
+		os.Exit(2)
+	}
+	if tenantID <= 0 {
+		fmt.Fprintln(os.Stderr, "Please provide a tenant with -tenant")
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	var statementFormat reconcile.Format
+	if format != "" {
+		parsed, err := reconcile.ParseFormat(format)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(2)
+		}
+		statementFormat = parsed
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error opening statement: %v\n", err)
+		os.Exit(1)
+	}
+	defer f.Close()
+
+	ctx := auth.WithTenantID(context.Background(), tenantID)
+	reconciler := reconcile.NewReconciler(tolerance)
+	statement, err := reconciler.Import(ctx, filepath.Base(file), statementFormat, f, user)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error importing statement: %v\n", err)
+		os.Exit(1)
+	}
+
+	fmt.Printf("Imported %s (%s): %d lines\n", statement.FileName, statement.Format, statement.LineCount)
+	fmt.Printf("  matched:   %d\n  suggested: %d\n  unmatched: %d\n", statement.Matched, statement.Suggested, statement.Unmatched)
+
+	lines, err := reconciler.Lines(ctx, "", statement.ImportID)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error listing statement lines: %v\n", err)
+		os.Exit(1)
+	}
+	for _, line := range lines {
+		fmt.Printf("%-9s %s %12.2f %-3s %-20s -> %s %s (score %d)\n",
+			line.Status,
+			line.BookingDate.Format("2006-01-02"),
+			line.Amount,
+			line.Currency,
+			line.Reference,
+			line.MatchType,
+			line.MatchID,
+			line.Score,
+		)
+	}
+}
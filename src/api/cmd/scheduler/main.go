@@ -0,0 +1,52 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"api/internal/payment"
+)
+
+// main runs the recurring payment scheduler and scheduled payment batches until interrupted
+func main() {
+	var interval time.Duration
+	var once bool
+
+	flag.DurationVar(&interval, "interval", time.Minute, "How often to check for due schedules")
+	flag.BoolVar(&once, "once", false, "Process due schedules, retries and batches once, then exit")
+	flag.Parse()
+
+	scheduler := payment.NewScheduler()
+	batches := payment.NewBatchProcessor()
+
+	if once {
+		processed, err := scheduler.RunDue()
+		if err != nil {
+			log.Fatalf("[error] - Run scheduled payments: %v", err)
+		}
+		retried, err := scheduler.RetryFailed()
+		if err != nil {
+			log.Fatalf("[error] - Retry scheduled payments: %v", err)
+		}
+		executed, err := batches.RunDue()
+		if err != nil {
+			log.Fatalf("[error] - Run payment batches: %v", err)
+		}
+		log.Printf("[info] - Materialized %d and retried %d scheduled payments", processed, retried)
+		log.Printf("[info] - Executed %d payment batches", executed)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[info] - Payment scheduler running every %s", interval)
+	go batches.Run(ctx, interval)
+	scheduler.Run(ctx, interval)
+	log.Println("[info] - Payment scheduler stopped")
+}
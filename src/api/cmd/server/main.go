@@ -8,10 +8,12 @@ import (
 	"os/signal"
 	"strconv"
 	"sync"
+	"time"
 
 	"github.com/spf13/viper"
 
 	"api/config"
+	"api/internal/auth"
 	"api/internal/monitoring"
 	"api/internal/server"
 
@@ -72,6 +74,9 @@ func main() {
 		}
 	}()
 
+	// Rotate JWT signing keys once per process; every instance reads the same key directory
+	go auth.GetKeySet().Run(ctx, time.Hour)
+
 	// WaitGroup to wait for all servers to stop
 	var wg sync.WaitGroup
 
@@ -21,6 +21,7 @@ type Config struct {
 	DBName          string
 	SecretKey       string
 	TokenAge        int
+	RefreshTokenAge int
 	GraphQLPort     int
 	LogServerPort   int
 	LogMergeMin     int
@@ -32,6 +33,42 @@ type Config struct {
 	CachePassword   string
 	CacheIndex      int
 	CacheAge        int
+	VaultKey        string
+	RiskRulesFile   string
+	PolicyFile      string
+	DebtorName      string
+	DebtorIBAN      string
+	DebtorBIC       string
+	BaseCurrency    string
+	PasswordMemory  int
+	PasswordTime    int
+	PasswordThreads int
+	JwtKeyDir       string
+	JwtAlgorithm    string
+	JwtRotateHours  int
+	JwtGraceHours   int
+	MFAIssuer       string
+	LoginMaxFails   int
+	LoginIPMaxFails int
+	LoginDelayAfter int
+	LoginLockoutMin int
+	TrustProxy      bool
+	MailProvider    string
+	MailFrom        string
+	MailOutboxDir   string
+	SMTPHost        string
+	SMTPPort        int
+	SMTPUsername    string
+	SMTPPassword    string
+	AppURL          string
+	OAuthIssuer     string
+	ImpersonateMin  int
+	TrustedProxies  string
+	RateLimitPublic string
+	RateLimitRoles  string
+	RateLimitIdle   int
+	RateLimitShared bool
+	RateLimitOpen   bool
 }
 
 const (
@@ -42,6 +79,7 @@ const (
 	DBName          = "DB_NAME"
 	SecretKey       = "SECRETE_KEY"
 	TokenAge        = "TOKEN_AGE"
+	RefreshTokenAge = "REFRESH_TOKEN_AGE"
 	GraphQLPort     = "GRAPHQL_PORT"
 	LogServerPort   = "LOG_SERVER_PORT"
 	LogMergeMin     = "LOG_MERGE_MIN"
@@ -52,6 +90,42 @@ const (
 	CacheConnString = "CACHE_CONNECTION_STRING"
 	CacheIndex      = "CACHE_INDEX"
 	CachePassword   = "CACHE_PASSWORD"
+	VaultKey        = "VAULT_KEY"
+	RiskRulesFile   = "RISK_RULES_FILE"
+	PolicyFile      = "POLICY_FILE"
+	DebtorName      = "DEBTOR_NAME"
+	DebtorIBAN      = "DEBTOR_IBAN"
+	DebtorBIC       = "DEBTOR_BIC"
+	BaseCurrency    = "BASE_CURRENCY"
+	PasswordMemory  = "PASSWORD_HASH_MEMORY_KB"
+	PasswordTime    = "PASSWORD_HASH_ITERATIONS"
+	PasswordThreads = "PASSWORD_HASH_THREADS"
+	JwtKeyDir       = "JWT_KEY_DIR"
+	JwtAlgorithm    = "JWT_ALGORITHM"
+	JwtRotateHours  = "JWT_KEY_ROTATE_HOURS"
+	JwtGraceHours   = "JWT_KEY_GRACE_HOURS"
+	MFAIssuer       = "MFA_ISSUER"
+	LoginMaxFails   = "LOGIN_MAX_FAILURES"
+	LoginIPMaxFails = "LOGIN_IP_MAX_FAILURES"
+	LoginDelayAfter = "LOGIN_DELAY_AFTER"
+	LoginLockoutMin = "LOGIN_LOCKOUT_MIN"
+	TrustProxy      = "TRUST_PROXY_HEADERS"
+	MailProvider    = "MAIL_PROVIDER"
+	MailFrom        = "MAIL_FROM"
+	MailOutboxDir   = "MAIL_OUTBOX_DIR"
+	SMTPHost        = "SMTP_HOST"
+	SMTPPort        = "SMTP_PORT"
+	SMTPUsername    = "SMTP_USERNAME"
+	SMTPPassword    = "SMTP_PASSWORD"
+	AppURL          = "APP_URL"
+	OAuthIssuer     = "OAUTH_ISSUER"
+	ImpersonateMin  = "IMPERSONATION_TOKEN_MIN"
+	TrustedProxies  = "TRUSTED_PROXIES"
+	RateLimitPublic = "RATE_LIMIT_PUBLIC"
+	RateLimitRoles  = "RATE_LIMIT_ROLES"
+	RateLimitIdle   = "RATE_LIMIT_IDLE_MIN"
+	RateLimitShared = "RATE_LIMIT_SHARED"
+	RateLimitOpen   = "RATE_LIMIT_FAIL_OPEN"
 )
 
 var instance *Config
@@ -90,6 +164,36 @@ func NewConfig() *Config {
 		}
 
 		viper.AutomaticEnv()
+		viper.SetDefault(RiskRulesFile, "../../config/risk_rules.yaml")
+		viper.SetDefault(PolicyFile, "../../config/policies.yaml")
+		viper.SetDefault(BaseCurrency, "USD")
+		viper.SetDefault(RefreshTokenAge, 7*24*60)
+		viper.SetDefault(PasswordMemory, 64*1024)
+		viper.SetDefault(PasswordTime, 3)
+		viper.SetDefault(PasswordThreads, 2)
+		viper.SetDefault(JwtKeyDir, "../../config/jwt-keys")
+		viper.SetDefault(JwtAlgorithm, "RS256")
+		viper.SetDefault(JwtRotateHours, 30*24)
+		viper.SetDefault(JwtGraceHours, 24)
+		viper.SetDefault(MFAIssuer, "FinTech API")
+		viper.SetDefault(LoginMaxFails, 5)
+		viper.SetDefault(LoginIPMaxFails, 50)
+		viper.SetDefault(LoginDelayAfter, 3)
+		viper.SetDefault(LoginLockoutMin, 15)
+		viper.SetDefault(MailProvider, "file")
+		viper.SetDefault(MailFrom, "no-reply@localhost")
+		viper.SetDefault(MailOutboxDir, "../../data/outbox")
+		viper.SetDefault(SMTPPort, 587)
+		viper.SetDefault(AppURL, "http://localhost:3000")
+		viper.SetDefault(OAuthIssuer, "")
+		viper.SetDefault(ImpersonateMin, 15)
+		viper.SetDefault(RateLimitReqSec, 10)
+		viper.SetDefault(RateLimitBurst, 20)
+		viper.SetDefault(RateLimitPublic, "2:10")
+		viper.SetDefault(RateLimitRoles, "")
+		viper.SetDefault(RateLimitIdle, 10)
+		viper.SetDefault(RateLimitShared, true)
+		viper.SetDefault(RateLimitOpen, true)
 
 		// Create a Config instance and set values from Viper
 		instance = &Config{
@@ -100,12 +204,49 @@ func NewConfig() *Config {
 			DBName:          viper.GetString(DBName),
 			SecretKey:       viper.GetString(SecretKey),
 			TokenAge:        viper.GetInt(TokenAge),
+			RefreshTokenAge: viper.GetInt(RefreshTokenAge),
 			GraphQLPort:     viper.GetInt(GraphQLPort),
 			LogServerPort:   viper.GetInt(LogServerPort),
 			LogMergeMin:     viper.GetInt(LogMergeMin),
 			LogMoveMin:      viper.GetFloat64(LogMoveMin),
 			RateLimitReqSec: viper.GetInt(RateLimitReqSec),
 			RateLimitBurst:  viper.GetInt(RateLimitBurst),
+			VaultKey:        viper.GetString(VaultKey),
+			RiskRulesFile:   viper.GetString(RiskRulesFile),
+			PolicyFile:      viper.GetString(PolicyFile),
+			DebtorName:      viper.GetString(DebtorName),
+			DebtorIBAN:      viper.GetString(DebtorIBAN),
+			DebtorBIC:       viper.GetString(DebtorBIC),
+			BaseCurrency:    viper.GetString(BaseCurrency),
+			PasswordMemory:  viper.GetInt(PasswordMemory),
+			PasswordTime:    viper.GetInt(PasswordTime),
+			PasswordThreads: viper.GetInt(PasswordThreads),
+			JwtKeyDir:       viper.GetString(JwtKeyDir),
+			JwtAlgorithm:    viper.GetString(JwtAlgorithm),
+			JwtRotateHours:  viper.GetInt(JwtRotateHours),
+			JwtGraceHours:   viper.GetInt(JwtGraceHours),
+			MFAIssuer:       viper.GetString(MFAIssuer),
+			LoginMaxFails:   viper.GetInt(LoginMaxFails),
+			LoginIPMaxFails: viper.GetInt(LoginIPMaxFails),
+			LoginDelayAfter: viper.GetInt(LoginDelayAfter),
+			LoginLockoutMin: viper.GetInt(LoginLockoutMin),
+			TrustProxy:      viper.GetBool(TrustProxy),
+			MailProvider:    viper.GetString(MailProvider),
+			MailFrom:        viper.GetString(MailFrom),
+			MailOutboxDir:   viper.GetString(MailOutboxDir),
+			SMTPHost:        viper.GetString(SMTPHost),
+			SMTPPort:        viper.GetInt(SMTPPort),
+			SMTPUsername:    viper.GetString(SMTPUsername),
+			SMTPPassword:    viper.GetString(SMTPPassword),
+			AppURL:          viper.GetString(AppURL),
+			OAuthIssuer:     viper.GetString(OAuthIssuer),
+			ImpersonateMin:  viper.GetInt(ImpersonateMin),
+			TrustedProxies:  viper.GetString(TrustedProxies),
+			RateLimitPublic: viper.GetString(RateLimitPublic),
+			RateLimitRoles:  viper.GetString(RateLimitRoles),
+			RateLimitIdle:   viper.GetInt(RateLimitIdle),
+			RateLimitShared: viper.GetBool(RateLimitShared),
+			RateLimitOpen:   viper.GetBool(RateLimitOpen),
 		}
 	})
 	return instance
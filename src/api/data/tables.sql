@@ -423,4 +423,522 @@ CREATE INDEX idx_loan_applications_applicant ON loan_applications(applicant_id);
 CREATE INDEX idx_payment_periods_loan ON payment_periods(loan_id);
 CREATE INDEX idx_payment_periods_status ON payment_periods(status);
 CREATE INDEX idx_payment_periods_due_date ON payment_periods(due_date);
-CREATE INDEX idx_evidence_loan ON evidence(loan_application_id);
\ No newline at end of file
+CREATE INDEX idx_evidence_loan ON evidence(loan_application_id);
+
+-- Card token vault
+-- encrypted_card holds the AES-GCM encrypted card number and expiry (never the CVV)
+CREATE TABLE IF NOT EXISTS card_tokens (
+    token TEXT PRIMARY KEY,
+    fingerprint TEXT NOT NULL,
+    encrypted_card TEXT NOT NULL,
+    brand TEXT NOT NULL,
+    masked_number TEXT NOT NULL,
+    last4 TEXT NOT NULL,
+    expiry_month INTEGER NOT NULL,
+    expiry_year INTEGER NOT NULL,
+    cardholder_name TEXT,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL,
+    CHECK (
+        expiry_month >= 1
+        AND expiry_month <= 12
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_card_tokens_fingerprint ON card_tokens(fingerprint, status_id);
+CREATE INDEX IF NOT EXISTS idx_card_tokens_owner ON card_tokens(created_by, fingerprint, status_id);
+
+-- General ledger
+-- Amounts are stored in minor units; debits are positive and credits negative.
+-- Journal entries and postings are immutable: corrections are posted as reversals.
+CREATE TABLE IF NOT EXISTS ledger_accounts (
+    code TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    parent_code TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL,
+    CHECK (
+        type IN ('ASSET', 'LIABILITY', 'EQUITY', 'INCOME', 'EXPENSE')
+    )
+);
+CREATE TABLE IF NOT EXISTS journal_entries (
+    entry_id TEXT PRIMARY KEY,
+    reference_type TEXT NOT NULL,
+    reference_id TEXT NOT NULL DEFAULT '',
+    description TEXT,
+    currency TEXT NOT NULL,
+    effective_at DATETIME NOT NULL,
+    reversal_of TEXT UNIQUE,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    FOREIGN KEY (reversal_of) REFERENCES journal_entries(entry_id)
+);
+CREATE TABLE IF NOT EXISTS journal_postings (
+    posting_id TEXT PRIMARY KEY,
+    entry_id TEXT NOT NULL,
+    account_code TEXT NOT NULL,
+    amount INTEGER NOT NULL,
+    FOREIGN KEY (entry_id) REFERENCES journal_entries(entry_id),
+    FOREIGN KEY (account_code) REFERENCES ledger_accounts(code),
+    CHECK (amount <> 0)
+);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_reference ON journal_entries(reference_type, reference_id);
+CREATE INDEX IF NOT EXISTS idx_journal_entries_effective ON journal_entries(effective_at);
+CREATE INDEX IF NOT EXISTS idx_journal_postings_entry ON journal_postings(entry_id);
+CREATE INDEX IF NOT EXISTS idx_journal_postings_account ON journal_postings(account_code);
+CREATE TRIGGER IF NOT EXISTS trg_journal_entries_no_update BEFORE UPDATE ON journal_entries
+BEGIN
+    SELECT RAISE(ABORT, 'journal entries are immutable');
+END;
+CREATE TRIGGER IF NOT EXISTS trg_journal_entries_no_delete BEFORE DELETE ON journal_entries
+BEGIN
+    SELECT RAISE(ABORT, 'journal entries are immutable');
+END;
+CREATE TRIGGER IF NOT EXISTS trg_journal_postings_no_update BEFORE UPDATE ON journal_postings
+BEGIN
+    SELECT RAISE(ABORT, 'journal postings are immutable');
+END;
+CREATE TRIGGER IF NOT EXISTS trg_journal_postings_no_delete BEFORE DELETE ON journal_postings
+BEGIN
+    SELECT RAISE(ABORT, 'journal postings are immutable');
+END;
+-- System accounts
+INSERT OR IGNORE INTO ledger_accounts (code, name, type, currency, parent_code, created_at, created_by, status_id) VALUES
+    ('CASH', 'Cash at bank', 'ASSET', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('LOANS_RECEIVABLE', 'Loans receivable', 'ASSET', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('PAYMENTS_CLEARING', 'Payments clearing', 'LIABILITY', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('OWNER_EQUITY', 'Owner equity', 'EQUITY', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('INTEREST_INCOME', 'Interest income', 'INCOME', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('FEE_INCOME', 'Fee and fine income', 'INCOME', 'USD', '', CURRENT_TIMESTAMP, 'system', 1);
+
+-- Bank statement reconciliation
+CREATE TABLE IF NOT EXISTS statement_imports (
+    import_id TEXT PRIMARY KEY,
+    file_name TEXT,
+    format TEXT NOT NULL,
+    line_count INTEGER NOT NULL,
+    matched INTEGER NOT NULL DEFAULT 0,
+    suggested INTEGER NOT NULL DEFAULT 0,
+    unmatched INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    CHECK (format IN ('CSV', 'CAMT053'))
+);
+CREATE TABLE IF NOT EXISTS statement_lines (
+    line_id TEXT PRIMARY KEY,
+    import_id TEXT NOT NULL,
+    bank_reference TEXT,
+    booking_date DATETIME NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL,
+    currency TEXT,
+    reference TEXT,
+    description TEXT,
+    counterparty TEXT,
+    status TEXT NOT NULL,
+    match_type TEXT NOT NULL DEFAULT '',
+    match_id TEXT NOT NULL DEFAULT '',
+    score INTEGER NOT NULL DEFAULT 0,
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (import_id) REFERENCES statement_imports(import_id),
+    CHECK (
+        status IN ('MATCHED', 'SUGGESTED', 'UNMATCHED', 'CONFIRMED')
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_statement_lines_status ON statement_lines(status);
+CREATE INDEX IF NOT EXISTS idx_statement_lines_import ON statement_lines(import_id);
+CREATE INDEX IF NOT EXISTS idx_statement_lines_match ON statement_lines(match_type, match_id);
+
+-- Payment columns the API reads and writes that the original payments table lacks
+ALTER TABLE payments ADD COLUMN id TEXT;
+ALTER TABLE payments ADD COLUMN description TEXT;
+ALTER TABLE payments ADD COLUMN currency TEXT;
+
+-- Recurring payment schedules (standing orders)
+CREATE TABLE IF NOT EXISTS payment_schedules (
+    schedule_id TEXT PRIMARY KEY,
+    amount REAL NOT NULL,
+    currency TEXT,
+    payment_method TEXT NOT NULL,
+    pay_to TEXT NOT NULL,
+    note TEXT,
+    description TEXT,
+    recurrence TEXT NOT NULL,
+    start_date DATETIME NOT NULL,
+    end_date DATETIME,
+    max_occurrences INTEGER NOT NULL DEFAULT 0,
+    occurrences INTEGER NOT NULL DEFAULT 0,
+    next_run_at DATETIME,
+    max_attempts INTEGER NOT NULL,
+    retry_minutes INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CHECK (amount > 0),
+    CHECK (
+        status IN ('ACTIVE', 'PAUSED', 'CANCELLED', 'COMPLETED')
+    )
+);
+-- One row per occurrence, linking the schedule to the payment it produced
+CREATE TABLE IF NOT EXISTS payment_schedule_runs (
+    run_id TEXT PRIMARY KEY,
+    schedule_id TEXT NOT NULL,
+    occurrence INTEGER NOT NULL,
+    due_date DATETIME NOT NULL,
+    payment_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (schedule_id, occurrence),
+    FOREIGN KEY (schedule_id) REFERENCES payment_schedules(schedule_id),
+    CHECK (
+        status IN ('PENDING', 'SUCCEEDED', 'FAILED', 'EXHAUSTED')
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_payment_schedules_due ON payment_schedules(status, next_run_at);
+CREATE INDEX IF NOT EXISTS idx_payment_schedule_runs_retry ON payment_schedule_runs(status, next_attempt_at);
+CREATE INDEX IF NOT EXISTS idx_payment_schedule_runs_payment ON payment_schedule_runs(payment_id);
+
+-- Fraud/risk assessments recorded for every created payment (audit trail and velocity history)
+CREATE TABLE IF NOT EXISTS risk_assessments (
+    assessment_id TEXT PRIMARY KEY,
+    payment_id TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    pay_to TEXT NOT NULL,
+    amount REAL NOT NULL,
+    score INTEGER NOT NULL,
+    decision TEXT NOT NULL,
+    triggered_rules TEXT NOT NULL,
+    rules_version TEXT NOT NULL DEFAULT '',
+    review_status TEXT NOT NULL DEFAULT '',
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    review_note TEXT,
+    created_at DATETIME NOT NULL,
+    CHECK (decision IN ('APPROVE', 'REVIEW')),
+    CHECK (
+        review_status IN ('', 'PENDING', 'APPROVED', 'REJECTED')
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_user ON risk_assessments(username, created_at);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_payee ON risk_assessments(pay_to, created_at);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_review ON risk_assessments(review_status);
+
+-- Bulk payment batches and their per-row validation and execution report
+CREATE TABLE IF NOT EXISTS payment_batches (
+    batch_id TEXT PRIMARY KEY,
+    file_name TEXT NOT NULL DEFAULT '',
+    format TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    status TEXT NOT NULL,
+    total_rows INTEGER NOT NULL,
+    valid_rows INTEGER NOT NULL,
+    invalid_rows INTEGER NOT NULL,
+    inserted_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    execute_at DATETIME,
+    executed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    CHECK (format IN ('csv', 'jsonl')),
+    CHECK (mode IN ('ATOMIC', 'PER_ROW')),
+    CHECK (
+        status IN ('PENDING', 'EXECUTING', 'COMPLETED', 'PARTIAL', 'FAILED', 'CANCELLED')
+    )
+);
+CREATE TABLE IF NOT EXISTS payment_batch_rows (
+    batch_id TEXT NOT NULL,
+    row_number INTEGER NOT NULL,
+    payment_id TEXT NOT NULL DEFAULT '',
+    amount REAL NOT NULL DEFAULT 0,
+    currency TEXT NOT NULL DEFAULT '',
+    pay_to TEXT NOT NULL DEFAULT '',
+    payment_method TEXT NOT NULL DEFAULT '',
+    payment_date TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    errors TEXT NOT NULL DEFAULT 'null',
+    PRIMARY KEY (batch_id, row_number),
+    FOREIGN KEY (batch_id) REFERENCES payment_batches(batch_id),
+    CHECK (
+        status IN ('VALID', 'INVALID', 'INSERTED', 'REVIEW', 'FAILED', 'SKIPPED')
+    )
+);
+CREATE INDEX IF NOT EXISTS idx_payment_batches_due ON payment_batches(status, execute_at);
+
+-- FX rates: one unit of base costs rate units of quote from effective_date
+CREATE TABLE IF NOT EXISTS fx_rates (
+    base TEXT NOT NULL,
+    quote TEXT NOT NULL,
+    rate REAL NOT NULL,
+    effective_date TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    imported_at DATETIME NOT NULL,
+    imported_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (base, quote, effective_date),
+    CHECK (rate > 0),
+    CHECK (base <> quote)
+);
+
+-- Base currency value locked when a payment is created
+ALTER TABLE payments ADD COLUMN base_currency TEXT;
+ALTER TABLE payments ADD COLUMN base_amount REAL;
+ALTER TABLE payments ADD COLUMN fx_rate REAL;
+ALTER TABLE payments ADD COLUMN fx_rate_date TEXT;
+CREATE INDEX IF NOT EXISTS idx_payments_currency ON payments(currency);
+
+-- Refresh tokens: stored as SHA-256 hashes; tokens from one login share a family
+CREATE TABLE IF NOT EXISTS refresh_tokens (
+    token_id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    user_name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    issued_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    revoked_at DATETIME,
+    replaced_by TEXT,
+    access_jti TEXT NOT NULL,
+    access_expires_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_refresh_tokens_family ON refresh_tokens(family_id);
+
+-- Access tokens (by jti) revoked before their expiry
+CREATE TABLE IF NOT EXISTS revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NOT NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);
+
+-- TOTP multi-factor authentication; secrets are encrypted with VAULT_KEY
+CREATE TABLE IF NOT EXISTS user_mfa (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed_at DATETIME,
+    last_used_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+
+-- Single-use recovery codes, stored as SHA-256 hashes
+CREATE TABLE IF NOT EXISTS mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_mfa_recovery_codes_user ON mfa_recovery_codes(user_id);
+
+-- Attempts at completing each MFA login challenge, counted atomically
+CREATE TABLE IF NOT EXISTS mfa_challenge_attempts (
+    challenge_id TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL
+);
+
+ALTER TABLE roles ADD COLUMN require_mfa BOOLEAN NOT NULL DEFAULT 0;
+ALTER TABLE refresh_tokens ADD COLUMN amr TEXT NOT NULL DEFAULT '';
+
+-- Failed login counters per username ("user:<name>") and per client address ("ip:<addr>")
+CREATE TABLE IF NOT EXISTS login_failures (
+    attempt_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at DATETIME NOT NULL,
+    locked_until DATETIME
+);
+
+-- Authentication audit trail: logins, lockouts, MFA, logouts, token refreshes and role changes
+CREATE TABLE IF NOT EXISTS auth_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    user_name TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_auth_events_user ON auth_events(user_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_auth_events_type ON auth_events(event_type, created_at);
+
+-- Email addresses and their verification
+ALTER TABLE users ADD COLUMN email TEXT;
+ALTER TABLE users ADD COLUMN email_verified_at DATETIME;
+CREATE INDEX IF NOT EXISTS idx_users_email ON users(email);
+
+-- Single-use tokens sent by email (password reset, email verification), by jti
+CREATE TABLE IF NOT EXISTS action_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    purpose TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME NOT NULL,
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_action_tokens_user ON action_tokens(user_id, purpose);
+
+-- Scoped API keys for service-to-service access; only a SHA-256 hash of the key is stored.
+-- Scopes are space separated "<resource>:<read|write|delete|execute|*>" entries.
+CREATE TABLE IF NOT EXISTS api_keys (
+    key_id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    last_used_ip TEXT,
+    revoked_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_api_keys_user ON api_keys(user_id);
+
+-- Route-pattern permissions: resource_name may use "*", "{name}" and a final "**".
+-- methods lists the HTTP methods a rule covers (comma separated, empty = use the can_* flags);
+-- deny rules override allow rules.
+ALTER TABLE role_permissions ADD COLUMN methods TEXT NOT NULL DEFAULT '';
+ALTER TABLE role_permissions ADD COLUMN effect TEXT NOT NULL DEFAULT 'allow';
+
+-- GraphQL field permissions (resource_type_id 2) are read from the view and honour deny rules
+DROP VIEW IF EXISTS vw_user_permissions;
+CREATE VIEW vw_user_permissions AS
+SELECT
+    ur.user_id,
+    r.role_id,
+    r.role_name,
+    r.is_super_admin,
+    rp.role_permission_id,
+    rp.resource_type_id,
+    rp.resource_name,
+    rp.can_execute,
+    rp.can_read,
+    rp.can_write,
+    rp.can_delete,
+    rp.effect
+FROM
+    user_roles ur
+    INNER JOIN roles r ON ur.role_id = r.role_id
+    LEFT JOIN role_permissions rp ON r.role_id = rp.role_id
+WHERE
+    ur.status_id = 1
+    AND r.status_id = 1
+    AND rp.status_id = 1;
+
+-- Attribute-based policies: user attributes such as branch, and the loan attributes policies check
+CREATE TABLE IF NOT EXISTS user_attributes (
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    value TEXT NOT NULL,
+    PRIMARY KEY (user_id, name),
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+ALTER TABLE loan_applications ADD COLUMN branch TEXT;
+ALTER TABLE loan_applications ADD COLUMN consent_status TEXT;
+
+-- Multi-tenancy: every lending brand is a tenant with its own products, branding and rate limits.
+-- Tenant 1 is the root tenant that existing rows belong to; its super admins provision the others.
+CREATE TABLE IF NOT EXISTS tenants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    status_id INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT NOT NULL
+);
+INSERT OR IGNORE INTO tenants (id, slug, name, config, status_id, created_at, created_by, updated_at, updated_by)
+VALUES (1, 'root', 'Root tenant', '{}', 1, CURRENT_TIMESTAMP, 'system', CURRENT_TIMESTAMP, 'system');
+
+ALTER TABLE users ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE roles ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE loan_applications ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE payments ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE consents ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE contact ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE payment_schedules ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE payment_batches ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE risk_assessments ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE statement_imports ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+ALTER TABLE statement_lines ADD COLUMN tenant_id INTEGER NOT NULL DEFAULT 1;
+CREATE INDEX IF NOT EXISTS idx_users_tenant ON users(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_loan_applications_tenant ON loan_applications(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_payments_tenant ON payments(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_consents_tenant ON consents(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_contact_tenant ON contact(tenant_id);
+CREATE INDEX IF NOT EXISTS idx_risk_assessments_tenant ON risk_assessments(tenant_id, review_status);
+CREATE INDEX IF NOT EXISTS idx_statement_lines_tenant ON statement_lines(tenant_id, status);
+
+-- OAuth 2.0 / OpenID Connect authorization server. Client secrets and authorization codes
+-- are stored as SHA-256 hashes; list columns hold space-separated values.
+CREATE TABLE IF NOT EXISTS oauth_clients (
+    client_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    secret_hash TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT '',
+    grant_types TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    is_public BOOLEAN NOT NULL DEFAULT 0,
+    skip_consent BOOLEAN NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    revoked_at DATETIME,
+    FOREIGN KEY (user_id) REFERENCES users(user_id)
+);
+CREATE INDEX IF NOT EXISTS idx_oauth_clients_user ON oauth_clients(user_id);
+
+CREATE TABLE IF NOT EXISTS oauth_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL DEFAULT '',
+    code_challenge_method TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    amr TEXT NOT NULL DEFAULT '',
+    auth_time DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(client_id)
+);
+
+CREATE TABLE IF NOT EXISTS oauth_consents (
+    user_id INTEGER NOT NULL,
+    client_id TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    granted_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, client_id),
+    FOREIGN KEY (client_id) REFERENCES oauth_clients(client_id)
+);
+
+-- Refresh tokens issued to OAuth clients can only be exchanged by the same client
+ALTER TABLE refresh_tokens ADD COLUMN client_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE refresh_tokens ADD COLUMN scope TEXT NOT NULL DEFAULT '';
+
+-- Audit log entries made while a staff member impersonates the user
+ALTER TABLE logs ADD COLUMN actor_id INTEGER NOT NULL DEFAULT 0;
@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"api/config"
+	"api/internal/mailer"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// Purposes of the tokens sent by email
+const (
+	PurposePasswordReset = "password_reset"
+	PurposeVerifyEmail   = "verify_email"
+)
+
+const (
+	// passwordResetTTL is how long a password reset link works
+	passwordResetTTL = time.Hour
+	// verifyEmailTTL is how long an email verification link works
+	verifyEmailTTL = 24 * time.Hour
+	// minPasswordLength is the shortest password a reset accepts
+	minPasswordLength = 8
+)
+
+// Account errors
+var (
+	ErrInvalidActionToken   = errors.New("invalid or expired link")
+	ErrWeakPassword         = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+	ErrNoEmail              = errors.New("user has no email address")
+	ErrEmailAlreadyVerified = errors.New("email address is already verified")
+)
+
+// PasswordForgotRequest asks for a password reset link
+type PasswordForgotRequest struct {
+	Email string `json:"email" example:"john@example.com"`
+}
+
+// PasswordResetRequest sets a new password with the token from a reset link
+type PasswordResetRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// EmailVerifyRequest confirms an email address with the token from a verification link
+type EmailVerifyRequest struct {
+	Token string `json:"token"`
+}
+
+// AccountService sends and redeems password reset and email verification links
+type AccountService struct {
+	users    *UserRepo
+	tokens   *ActionTokenRepo
+	keys     *KeySet
+	mailer   mailer.Mailer
+	sessions *TokenService
+	appURL   string
+	params   PasswordParams
+	now      func() time.Time
+}
+
+var (
+	accountServiceOnce     sync.Once
+	accountServiceInstance *AccountService
+)
+
+// NewAccountService creates an AccountService from the configuration
+func NewAccountService() *AccountService {
+	return NewAccountServiceWithRepo(
+		NewUserRepo(),
+		NewActionTokenRepo(),
+		GetKeySet(),
+		mailer.GetMailer(),
+		GetTokenService(),
+		config.NewConfig().AppURL,
+		NewPasswordParams(),
+	)
+}
+
+// NewAccountServiceWithRepo creates an AccountService with explicit dependencies.
+// sessions may be nil, in which case a password reset does not end other sessions.
+func NewAccountServiceWithRepo(users *UserRepo, tokens *ActionTokenRepo, keys *KeySet, m mailer.Mailer, sessions *TokenService, appURL string, params PasswordParams) *AccountService {
+	return &AccountService{
+		users:    users,
+		tokens:   tokens,
+		keys:     keys,
+		mailer:   m,
+		sessions: sessions,
+		appURL:   appURL,
+		params:   params,
+		now:      time.Now,
+	}
+}
+
+// GetAccountService returns the shared AccountService
+func GetAccountService() *AccountService {
+	accountServiceOnce.Do(func() {
+		accountServiceInstance = NewAccountService()
+	})
+	return accountServiceInstance
+}
+
+// RequestPasswordReset mails a reset link to the owner of an email address. Unknown
+// addresses are ignored so that the endpoint does not reveal which accounts exist.
+// Older reset links of the user stop working.
+func (s *AccountService) RequestPasswordReset(email string) error {
+	user, err := s.users.GetUserByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.tokens.InvalidateActionTokens(user.UserID, PurposePasswordReset, s.now()); err != nil {
+		return err
+	}
+	token, err := s.issue(user, PurposePasswordReset, passwordResetTTL)
+	if err != nil {
+		return err
+	}
+	return s.send("password_reset", user, "/reset-password", token, passwordResetTTL)
+}
+
+// ResetPassword sets a new password with a reset token and ends the user's sessions
+func (s *AccountService) ResetPassword(token, password string) (*User, error) {
+	if len(password) < minPasswordLength {
+		return nil, ErrWeakPassword
+	}
+	claims, err := s.redeem(token, PurposePasswordReset)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := HashPassword(password, s.params)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.users.UpdatePassword(claims.UserID, hash); err != nil {
+		return nil, err
+	}
+	if err := s.tokens.InvalidateActionTokens(claims.UserID, PurposePasswordReset, s.now()); err != nil {
+		return nil, err
+	}
+	if s.sessions != nil {
+		if err := s.sessions.RevokeUser(claims.UserID, "password reset"); err != nil {
+			return nil, err
+		}
+	}
+	return s.users.GetUserByID(claims.UserID)
+}
+
+// SendVerification mails an email verification link to a user
+func (s *AccountService) SendVerification(user *User) error {
+	if user.Email == "" {
+		return ErrNoEmail
+	}
+	if user.EmailVerifiedAt != nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	token, err := s.issue(user, PurposeVerifyEmail, verifyEmailTTL)
+	if err != nil {
+		return err
+	}
+	return s.send("verify_email", user, "/verify-email", token, verifyEmailTTL)
+}
+
+// ResendVerification mails a new verification link to the owner of an unverified
+// address. Unknown and verified addresses are ignored.
+func (s *AccountService) ResendVerification(email string) error {
+	user, err := s.users.GetUserByEmail(email)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if user.EmailVerifiedAt != nil {
+		return nil
+	}
+	return s.SendVerification(user)
+}
+
+// VerifyEmail marks the address a verification token was sent to as verified
+func (s *AccountService) VerifyEmail(token string) (*User, error) {
+	claims, err := s.redeem(token, PurposeVerifyEmail)
+	if err != nil {
+		return nil, err
+	}
+
+	// The token names the address it was sent to; a changed address needs a new link
+	verified, err := s.users.SetEmailVerified(claims.UserID, claims.Subject, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !verified {
+		return nil, ErrInvalidActionToken
+	}
+	return s.users.GetUserByID(claims.UserID)
+}
+
+// issue signs a single-use token for purpose and records it
+func (s *AccountService) issue(user *User, purpose string, ttl time.Duration) (string, error) {
+	now := s.now()
+	expiresAt := now.Add(ttl)
+	jti := uuid.NewString()
+
+	token, err := s.keys.Sign(&JwtClaims{
+		UserID:   user.UserID,
+		Username: user.Username,
+		Purpose:  purpose,
+		StandardClaims: jwt.StandardClaims{
+			Id:        jti,
+			Subject:   user.Email,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	})
+	if err != nil {
+		return "", err
+	}
+	if err := s.tokens.InsertActionToken(jti, user.UserID, purpose, expiresAt, now); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// redeem verifies a token for purpose and uses it up
+func (s *AccountService) redeem(token, purpose string) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	if _, err := s.keys.Verify(token, claims); err != nil || claims.Purpose != purpose {
+		return nil, ErrInvalidActionToken
+	}
+
+	used, err := s.tokens.UseActionToken(claims.Id, claims.UserID, purpose, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidActionToken
+	}
+	return claims, nil
+}
+
+// send renders a mail template with a link to the frontend page that redeems token
+func (s *AccountService) send(template string, user *User, page, token string, ttl time.Duration) error {
+	link := s.appURL + page + "?token=" + url.QueryEscape(token)
+	msg, err := mailer.Render(template, map[string]string{
+		"Username":  user.Username,
+		"Email":     user.Email,
+		"Link":      link,
+		"ExpiresIn": formatTTL(ttl),
+	}, user.Email)
+	if err != nil {
+		return err
+	}
+	return s.mailer.Send(msg)
+}
+
+// formatTTL writes a link lifetime for humans, e.g. "1 hour" or "24 hours"
+func formatTTL(ttl time.Duration) string {
+	if hours := int(ttl / time.Hour); hours > 1 {
+		return fmt.Sprintf("%d hours", hours)
+	} else if hours == 1 {
+		return "1 hour"
+	}
+	return fmt.Sprintf("%d minutes", int(ttl/time.Minute))
+}
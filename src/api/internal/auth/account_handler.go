@@ -0,0 +1,147 @@
+package auth
+
+import (
+	"api/internal/handler"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// ForgotPasswordHandler godoc
+// @Summary Request a password reset
+// @Description Mail a password reset link. The response is the same whether or not the address belongs to an account.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasswordForgotRequest true "Email address"
+// @Success 202 {object} map[string]string
+// @Router /password/forgot [post]
+func ForgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body PasswordForgotRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "An email address is required")
+		return
+	}
+
+	// Send in the background so the response time does not tell whether the account exists
+	go func(email string) {
+		if err := GetAccountService().RequestPasswordReset(email); err != nil {
+			log.Printf("[error] - Send password reset to %s: %v", email, err)
+		}
+	}(body.Email)
+
+	writeAccepted(w, r, "If the address belongs to an account, a password reset link has been sent")
+}
+
+// ResetPasswordHandler godoc
+// @Summary Reset a password
+// @Description Set a new password with the token from a reset link. All sessions of the user are ended.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasswordResetRequest true "Reset token and new password"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} types.ErrorResponse
+// @Router /password/reset [post]
+func ResetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body PasswordResetRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	user, err := GetAccountService().ResetPassword(body.Token, body.Password)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+
+	// The user proved control of the mailbox, so earlier failed logins no longer count
+	if _, err := GetLoginThrottle().Unlock(user.Username); err != nil {
+		log.Printf("[error] - Unlock user %s after password reset: %v", user.Username, err)
+	}
+	RecordAuthEvent(r, AuthEvent{EventType: EventPasswordReset, UserID: user.UserID, Username: user.Username, Success: true})
+	writeAuthResponse(w, r, map[string]string{"message": "Password has been reset"})
+}
+
+// VerifyEmailHandler godoc
+// @Summary Verify an email address
+// @Description Confirm an email address with the token from a verification link
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body EmailVerifyRequest true "Verification token"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} types.ErrorResponse
+// @Router /email/verify [post]
+func VerifyEmailHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body EmailVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	user, err := GetAccountService().VerifyEmail(body.Token)
+	if err != nil {
+		writeAccountError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventEmailVerified, UserID: user.UserID, Username: user.Username, Success: true, Detail: user.Email})
+	writeAuthResponse(w, r, map[string]string{"message": "Email address verified"})
+}
+
+// ResendVerificationHandler godoc
+// @Summary Resend the verification email
+// @Description Mail a new verification link. The response is the same whether or not the address belongs to an account.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body PasswordForgotRequest true "Email address"
+// @Success 202 {object} map[string]string
+// @Router /email/verify/resend [post]
+func ResendVerificationHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body PasswordForgotRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Email == "" {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "An email address is required")
+		return
+	}
+
+	go func(email string) {
+		if err := GetAccountService().ResendVerification(email); err != nil {
+			log.Printf("[error] - Resend verification to %s: %v", email, err)
+		}
+	}(body.Email)
+
+	writeAccepted(w, r, "If the address belongs to an unverified account, a verification link has been sent")
+}
+
+// writeAccountError maps account errors to responses
+func writeAccountError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidActionToken):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_TOKEN", err.Error())
+	case errors.Is(err, ErrWeakPassword):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "WEAK_PASSWORD", err.Error())
+	default:
+		log.Printf("[error] - Account request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "ACCOUNT_REQUEST_FAILED", "Request failed")
+	}
+}
+
+// writeAccepted writes a 202 response with a message
+func writeAccepted(w http.ResponseWriter, r *http.Request, message string) {
+	resp := handler.NewResponse(http.StatusAccepted, "Accepted", map[string]string{"message": message}, GetRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"api/internal/db"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ActionTokenRepo represents the repository for single-use email verification and password reset tokens
+type ActionTokenRepo struct {
+	DB *db.DB
+}
+
+// NewActionTokenRepo creates a new instance of ActionTokenRepo
+func NewActionTokenRepo() *ActionTokenRepo {
+	db := db.NewDB()
+	return &ActionTokenRepo{DB: db}
+}
+
+// InsertActionToken records an issued token by its jti
+func (ar *ActionTokenRepo) InsertActionToken(jti string, userID int, purpose string, expiresAt, createdAt time.Time) error {
+	_, err := ar.DB.Insert(`
+		INSERT INTO action_tokens (jti, user_id, purpose, expires_at, created_at)
+		VALUES (?, ?, ?, ?, ?)`,
+		jti, userID, purpose, expiresAt.UTC(), createdAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting action token: %w", err)
+	}
+	return nil
+}
+
+// UseActionToken marks a token as used. It returns false when the token is unknown,
+// belongs to another user or purpose, or was already used or invalidated.
+func (ar *ActionTokenRepo) UseActionToken(jti string, userID int, purpose string, at time.Time) (bool, error) {
+	result, err := ar.DB.Update(`
+		UPDATE action_tokens SET used_at = ?
+		WHERE jti = ? AND user_id = ? AND purpose = ? AND used_at IS NULL AND expires_at > ?`,
+		at.UTC(), jti, userID, purpose, at.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error using action token: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error using action token: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// InvalidateActionTokens uses up every outstanding token of a user for a purpose
+func (ar *ActionTokenRepo) InvalidateActionTokens(userID int, purpose string, at time.Time) error {
+	_, err := ar.DB.Update(`
+		UPDATE action_tokens SET used_at = ?
+		WHERE user_id = ? AND purpose = ? AND used_at IS NULL`,
+		at.UTC(), userID, purpose,
+	)
+	if err != nil {
+		return fmt.Errorf("error invalidating action tokens: %w", err)
+	}
+	return nil
+}
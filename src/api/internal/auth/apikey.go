@@ -0,0 +1,275 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// APIKeyHeader carries an API key instead of a bearer token
+	APIKeyHeader = "X-API-Key"
+	// apiKeyPrefix starts every key so that leaked keys are easy to recognise
+	apiKeyPrefix = "fk"
+	// apiKeyIDBytes and apiKeySecretBytes size the public id and the secret of a key
+	apiKeyIDBytes     = 6
+	apiKeySecretBytes = 32
+	// apiKeyTouchInterval limits how often last-used tracking writes to the database
+	apiKeyTouchInterval = time.Minute
+	// apiKeyContextKey stores the authenticated key in the request context
+	apiKeyContextKey = ContextKey("api_key")
+)
+
+// Scope actions; they correspond to the can_* columns of role_permissions
+const (
+	ActionRead    = "read"
+	ActionWrite   = "write"
+	ActionDelete  = "delete"
+	ActionExecute = "execute"
+	ActionAll     = "*"
+)
+
+// API key errors
+var (
+	ErrInvalidAPIKey   = errors.New("invalid, expired or revoked API key")
+	ErrAPIKeyNotFound  = errors.New("API key not found")
+	ErrAPIKeyName      = errors.New("API key name is required")
+	ErrInvalidScope    = errors.New("invalid scope; expected <resource>:<read|write|delete|execute|*>")
+	ErrScopeNotAllowed = errors.New("scope exceeds the permissions of the key owner")
+)
+
+// APIKey is a stored API key. Only the hash of the secret is kept.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     int        `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	KeyHash    string     `json:"-"`
+	CreatedAt  time.Time  `json:"created_at"`
+	CreatedBy  string     `json:"created_by"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `json:"last_used_ip,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// CreateAPIKeyRequest creates a key. UserID defaults to the caller; only super admins
+// may create keys for other users, such as service accounts.
+type CreateAPIKeyRequest struct {
+	Name          string   `json:"name" example:"nightly-settlement"`
+	UserID        int      `json:"user_id,omitempty"`
+	Scopes        []string `json:"scopes" example:"/api/payments:read"`
+	ExpiresInDays int      `json:"expires_in_days,omitempty" example:"90"`
+}
+
+// CreatedAPIKey is returned once, when a key is created
+type CreatedAPIKey struct {
+	*APIKey
+	Key string `json:"key"`
+}
+
+// Allows reports whether the key's scopes grant action on resource. A scope is
+// "<resource>:<action>" where the resource is a route pattern like those of role
+// permissions; the resource "*" and the action "*" match anything.
+func (k *APIKey) Allows(resource, action string) bool {
+	path := splitPath(normalizeResource(resource))
+	for _, scope := range k.Scopes {
+		scopeResource, scopeAction, _ := strings.Cut(scope, ":")
+		if scopeAction != ActionAll && scopeAction != action {
+			continue
+		}
+		if scopeResource == "*" || matchSegments(splitPath(normalizeResource(scopeResource)), path) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyService creates, authenticates and revokes API keys
+type APIKeyService struct {
+	repo  *APIKeyRepo
+	perms *UserPermissionRepo
+	rules *PermissionEvaluator
+	now   func() time.Time
+}
+
+var (
+	apiKeyServiceOnce     sync.Once
+	apiKeyServiceInstance *APIKeyService
+)
+
+// NewAPIKeyService creates an APIKeyService with explicit dependencies
+func NewAPIKeyService(repo *APIKeyRepo, perms *UserPermissionRepo, rules *PermissionEvaluator) *APIKeyService {
+	return &APIKeyService{repo: repo, perms: perms, rules: rules, now: time.Now}
+}
+
+// GetAPIKeyService returns the shared APIKeyService
+func GetAPIKeyService() *APIKeyService {
+	apiKeyServiceOnce.Do(func() {
+		apiKeyServiceInstance = NewAPIKeyService(NewAPIKeyRepo(), NewUserPermissionRepo(), GetPermissionEvaluator())
+	})
+	return apiKeyServiceInstance
+}
+
+// Create issues a key for req.UserID. Every scope must be covered by the owner's role
+// permissions, unless the owner is a super admin. The returned key value is not stored.
+func (s *APIKeyService) Create(req CreateAPIKeyRequest, createdBy string) (*CreatedAPIKey, error) {
+	if strings.TrimSpace(req.Name) == "" {
+		return nil, ErrAPIKeyName
+	}
+	if len(req.Scopes) == 0 {
+		return nil, ErrInvalidScope
+	}
+	if err := s.checkScopes(req.UserID, req.Scopes); err != nil {
+		return nil, err
+	}
+
+	id, value, err := newAPIKeyValue()
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	key := &APIKey{
+		ID:        id,
+		UserID:    req.UserID,
+		Name:      req.Name,
+		Prefix:    apiKeyPrefix + "_" + id,
+		Scopes:    req.Scopes,
+		KeyHash:   hashRefreshToken(value),
+		CreatedAt: now,
+		CreatedBy: createdBy,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	if err := s.repo.InsertAPIKey(key); err != nil {
+		return nil, err
+	}
+	return &CreatedAPIKey{APIKey: key, Key: value}, nil
+}
+
+// Authenticate returns the key for a key value if it is valid, and records its use
+func (s *APIKeyService) Authenticate(value, ip string) (*APIKey, error) {
+	id, ok := parseAPIKey(value)
+	if !ok {
+		return nil, ErrInvalidAPIKey
+	}
+	key, err := s.repo.GetAPIKey(id)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, ErrInvalidAPIKey
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	if subtle.ConstantTimeCompare([]byte(key.KeyHash), []byte(hashRefreshToken(value))) != 1 ||
+		key.RevokedAt != nil || (key.ExpiresAt != nil && !now.Before(*key.ExpiresAt)) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= apiKeyTouchInterval {
+		if err := s.repo.TouchAPIKey(key.ID, now, ip); err != nil {
+			return nil, err
+		}
+		key.LastUsedAt = &now
+		key.LastUsedIP = ip
+	}
+	return key, nil
+}
+
+// List returns the keys of a user, including revoked ones
+func (s *APIKeyService) List(userID int) ([]*APIKey, error) {
+	return s.repo.GetAPIKeysByUser(userID)
+}
+
+// Get returns a key by id
+func (s *APIKeyService) Get(id string) (*APIKey, error) {
+	return s.repo.GetAPIKey(id)
+}
+
+// Revoke stops a key from working
+func (s *APIKeyService) Revoke(id string) error {
+	return s.repo.RevokeAPIKey(id, s.now())
+}
+
+// checkScopes validates scopes against the owner's role permissions
+func (s *APIKeyService) checkScopes(userID int, scopes []string) error {
+	isSuperAdmin, err := s.perms.IsSuperAdmin(userID)
+	if err != nil {
+		return err
+	}
+	for _, scope := range scopes {
+		resource, action, ok := strings.Cut(scope, ":")
+		if !ok || resource == "" || !validScopeAction(action) {
+			return fmt.Errorf("%w: %q", ErrInvalidScope, scope)
+		}
+		if isSuperAdmin {
+			continue
+		}
+		if resource == "*" {
+			return fmt.Errorf("%w: %q", ErrScopeNotAllowed, scope)
+		}
+		decision, err := s.rules.EvaluateAction(userID, action, resource)
+		if err != nil {
+			return err
+		}
+		if !decision.Allowed {
+			return fmt.Errorf("%w: %q", ErrScopeNotAllowed, scope)
+		}
+	}
+	return nil
+}
+
+// validScopeAction reports whether action can be used in a scope
+func validScopeAction(action string) bool {
+	switch action {
+	case ActionRead, ActionWrite, ActionDelete, ActionExecute, ActionAll:
+		return true
+	}
+	return false
+}
+
+// WithAPIKey stores an authenticated key in a context
+func WithAPIKey(ctx context.Context, key *APIKey) context.Context {
+	return context.WithValue(ctx, apiKeyContextKey, key)
+}
+
+// APIKeyFromContext returns the key a request was authenticated with, if any
+func APIKeyFromContext(ctx context.Context) *APIKey {
+	key, _ := ctx.Value(apiKeyContextKey).(*APIKey)
+	return key
+}
+
+// newAPIKeyValue returns a key id and the full key value fk_<id>_<secret>
+func newAPIKeyValue() (string, string, error) {
+	id := make([]byte, apiKeyIDBytes)
+	secret := make([]byte, apiKeySecretBytes)
+	if _, err := rand.Read(id); err != nil {
+		return "", "", fmt.Errorf("error generating API key: %w", err)
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", "", fmt.Errorf("error generating API key: %w", err)
+	}
+	keyID := hex.EncodeToString(id)
+	return keyID, apiKeyPrefix + "_" + keyID + "_" + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+// parseAPIKey returns the id part of a key value
+func parseAPIKey(value string) (string, bool) {
+	parts := strings.SplitN(value, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix || len(parts[1]) != 2*apiKeyIDBytes || parts[2] == "" {
+		return "", false
+	}
+	return parts[1], true
+}
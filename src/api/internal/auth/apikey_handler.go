@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// CreateAPIKeyHandler godoc
+// @Summary Create an API key
+// @Description Create a scoped API key for the current user. Super admins may create keys for other users, such as service accounts. The key is only returned once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body CreateAPIKeyRequest true "Key name, scopes and expiry"
+// @Success 200 {object} CreatedAPIKey
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Router /api-keys [post]
+func CreateAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+	// Keys skip MFA when used, so a role that requires MFA must pass it to create one
+	if satisfied, err := GetMFAService().SatisfiesMFA(claims); err != nil || !satisfied {
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "MFA_REQUIRED", "MFA required")
+		return
+	}
+
+	var body CreateAPIKeyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+	if body.ExpiresInDays < 0 {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "expires_in_days must not be negative")
+		return
+	}
+	if body.UserID == 0 {
+		body.UserID = claims.UserID
+	}
+	if body.UserID != claims.UserID && !isSuperAdminUser(claims.UserID) {
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "FORBIDDEN", "Only super admins can create keys for other users")
+		return
+	}
+
+	key, err := GetAPIKeyService().Create(body, claims.Username)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventAPIKeyCreate, UserID: key.UserID, Actor: claims.Username, Success: true, Detail: key.Prefix})
+	writeAuthResponse(w, r, key)
+}
+
+// GetAPIKeysHandler godoc
+// @Summary List API keys
+// @Description List the API keys of the current user, or of another user for super admins. Key values are never returned.
+// @Tags auth
+// @Produce json
+// @Param user_id query int false "User ID (super admins only)"
+// @Success 200 {array} APIKey
+// @Failure 403 {object} types.ErrorResponse
+// @Router /api-keys [get]
+func GetAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	userID := claims.UserID
+	if value := r.URL.Query().Get("user_id"); value != "" {
+		id, err := strconv.Atoi(value)
+		if err != nil {
+			writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_QUERY", "Invalid user_id")
+			return
+		}
+		if id != claims.UserID && !isSuperAdminUser(claims.UserID) {
+			writeAuthError(w, r, http.StatusForbidden, "Forbidden", "FORBIDDEN", "Only super admins can list keys of other users")
+			return
+		}
+		userID = id
+	}
+
+	keys, err := GetAPIKeyService().List(userID)
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, keys)
+}
+
+// RevokeAPIKeyHandler godoc
+// @Summary Revoke an API key
+// @Description Revoke one of the current user's API keys; super admins may revoke any key
+// @Tags auth
+// @Produce json
+// @Param id path string true "Key ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Router /api-keys/{id} [delete]
+func RevokeAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	service := GetAPIKeyService()
+	key, err := service.Get(router.Param(r, "id"))
+	if err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+	// Keys of other users are reported as missing rather than forbidden
+	if key.UserID != claims.UserID && !isSuperAdminUser(claims.UserID) {
+		writeAPIKeyError(w, r, ErrAPIKeyNotFound)
+		return
+	}
+
+	if err := service.Revoke(key.ID); err != nil {
+		writeAPIKeyError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventAPIKeyRevoke, UserID: key.UserID, Actor: claims.Username, Success: true, Detail: key.Prefix})
+	writeAuthResponse(w, r, map[string]string{"message": "API key revoked"})
+}
+
+// isSuperAdminUser reports whether a user holds a super admin role
+func isSuperAdminUser(userID int) bool {
+	isSuperAdmin, err := NewUserPermissionRepo().IsSuperAdmin(userID)
+	if err != nil {
+		log.Printf("[error] - Check super admin %d: %v", userID, err)
+		return false
+	}
+	return isSuperAdmin
+}
+
+// writeAPIKeyError maps API key errors to responses
+func writeAPIKeyError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrAPIKeyNotFound):
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "API_KEY_NOT_FOUND", err.Error())
+	case errors.Is(err, ErrAPIKeyName):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", err.Error())
+	case errors.Is(err, ErrInvalidScope):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_SCOPE", err.Error())
+	case errors.Is(err, ErrScopeNotAllowed):
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "SCOPE_NOT_ALLOWED", err.Error())
+	default:
+		log.Printf("[error] - API key request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "API_KEY_REQUEST_FAILED", "Request failed")
+	}
+}
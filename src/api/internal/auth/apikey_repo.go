@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// APIKeyRepo represents the repository for API keys
+type APIKeyRepo struct {
+	DB *db.DB
+}
+
+// NewAPIKeyRepo creates a new instance of APIKeyRepo
+func NewAPIKeyRepo() *APIKeyRepo {
+	db := db.NewDB()
+	return &APIKeyRepo{DB: db}
+}
+
+// apiKeyColumns are the api_keys columns read by scanAPIKey
+const apiKeyColumns = `key_id, user_id, name, prefix, scopes, key_hash, created_at, created_by,
+	expires_at, last_used_at, last_used_ip, revoked_at`
+
+// InsertAPIKey stores a new key
+func (ar *APIKeyRepo) InsertAPIKey(key *APIKey) error {
+	_, err := ar.DB.Insert(`
+		INSERT INTO api_keys (key_id, user_id, name, prefix, scopes, key_hash, created_at, created_by, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		key.ID,
+		key.UserID,
+		key.Name,
+		key.Prefix,
+		strings.Join(key.Scopes, " "),
+		key.KeyHash,
+		key.CreatedAt.UTC(),
+		key.CreatedBy,
+		nullTime(key.ExpiresAt),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting API key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey retrieves a key by id; ErrAPIKeyNotFound when there is none
+func (ar *APIKeyRepo) GetAPIKey(id string) (*APIKey, error) {
+	row, err := ar.DB.QueryRow("SELECT "+apiKeyColumns+" FROM api_keys WHERE key_id = ?", id)
+	if err != nil {
+		return nil, fmt.Errorf("error querying API key: %w", err)
+	}
+	key, err := scanAPIKey(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, err
+}
+
+// GetAPIKeysByUser lists the keys of a user, newest first
+func (ar *APIKeyRepo) GetAPIKeysByUser(userID int) ([]*APIKey, error) {
+	rows, err := ar.DB.Query("SELECT "+apiKeyColumns+" FROM api_keys WHERE user_id = ? ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys := []*APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	return keys, rows.Err()
+}
+
+// TouchAPIKey records when and from where a key was last used
+func (ar *APIKeyRepo) TouchAPIKey(id string, at time.Time, ip string) error {
+	if _, err := ar.DB.Update("UPDATE api_keys SET last_used_at = ?, last_used_ip = ? WHERE key_id = ?", at.UTC(), ip, id); err != nil {
+		return fmt.Errorf("error updating API key usage: %w", err)
+	}
+	return nil
+}
+
+// RevokeAPIKey revokes a key; ErrAPIKeyNotFound when it does not exist or was already revoked
+func (ar *APIKeyRepo) RevokeAPIKey(id string, at time.Time) error {
+	result, err := ar.DB.Update("UPDATE api_keys SET revoked_at = ? WHERE key_id = ? AND revoked_at IS NULL", at.UTC(), id)
+	if err != nil {
+		return fmt.Errorf("error revoking API key: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking API key: %w", err)
+	}
+	if affected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+// scanAPIKey scans a row selected with apiKeyColumns
+func scanAPIKey(row interface{ Scan(...any) error }) (*APIKey, error) {
+	var key APIKey
+	var scopes string
+	var expiresAt, lastUsedAt, revokedAt sql.NullTime
+	var lastUsedIP sql.NullString
+	err := row.Scan(
+		&key.ID,
+		&key.UserID,
+		&key.Name,
+		&key.Prefix,
+		&scopes,
+		&key.KeyHash,
+		&key.CreatedAt,
+		&key.CreatedBy,
+		&expiresAt,
+		&lastUsedAt,
+		&lastUsedIP,
+		&revokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning API key: %w", err)
+	}
+
+	key.Scopes = strings.Fields(scopes)
+	key.LastUsedIP = lastUsedIP.String
+	if expiresAt.Valid {
+		key.ExpiresAt = &expiresAt.Time
+	}
+	if lastUsedAt.Valid {
+		key.LastUsedAt = &lastUsedAt.Time
+	}
+	if revokedAt.Valid {
+		key.RevokedAt = &revokedAt.Time
+	}
+	return &key, nil
+}
+
+// nullTime converts an optional time for a nullable column
+func nullTime(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
@@ -0,0 +1,113 @@
+package auth
+
+import (
+	"api/internal/db"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// AuthEventRepo represents the repository for the authentication audit trail
+type AuthEventRepo struct {
+	DB *db.DB
+}
+
+// NewAuthEventRepo creates a new instance of AuthEventRepo
+func NewAuthEventRepo() *AuthEventRepo {
+	db := db.NewDB()
+	return &AuthEventRepo{DB: db}
+}
+
+// InsertAuthEvent stores an auth event and returns its id
+func (ar *AuthEventRepo) InsertAuthEvent(event *AuthEvent) (int64, error) {
+	result, err := ar.DB.Insert(`
+		INSERT INTO auth_events (
+			event_type, user_id, user_name, actor, ip_address, user_agent, success, detail, created_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		event.EventType,
+		event.UserID,
+		event.Username,
+		event.Actor,
+		event.IPAddress,
+		event.UserAgent,
+		event.Success,
+		event.Detail,
+		event.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting auth event: %w", err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, fmt.Errorf("error inserting auth event: %w", err)
+	}
+	event.ID = id
+	return id, nil
+}
+
+// GetAuthEvents lists auth events matching the filter, newest first
+func (ar *AuthEventRepo) GetAuthEvents(filter AuthEventFilter) ([]*AuthEvent, error) {
+	var conditions []string
+	var args []interface{}
+	if filter.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, filter.UserID)
+	}
+	if filter.Username != "" {
+		conditions = append(conditions, "LOWER(user_name) = LOWER(?)")
+		args = append(args, filter.Username)
+	}
+	if filter.EventType != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, filter.EventType)
+	}
+	if filter.IPAddress != "" {
+		conditions = append(conditions, "ip_address = ?")
+		args = append(args, filter.IPAddress)
+	}
+	if !filter.From.IsZero() {
+		conditions = append(conditions, "created_at >= ?")
+		args = append(args, filter.From.UTC())
+	}
+	if !filter.To.IsZero() {
+		conditions = append(conditions, "created_at < ?")
+		args = append(args, filter.To.UTC())
+	}
+
+	query := `
+		SELECT id, event_type, user_id, user_name, actor, ip_address, user_agent, success, detail, created_at
+		FROM auth_events`
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY created_at DESC, id DESC LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := ar.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying auth events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []*AuthEvent{}
+	for rows.Next() {
+		var event AuthEvent
+		if err := rows.Scan(
+			&event.ID,
+			&event.EventType,
+			&event.UserID,
+			&event.Username,
+			&event.Actor,
+			&event.IPAddress,
+			&event.UserAgent,
+			&event.Success,
+			&event.Detail,
+			&event.CreatedAt,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning auth event: %w", err)
+		}
+		events = append(events, &event)
+	}
+	return events, rows.Err()
+}
@@ -2,14 +2,8 @@
 package auth
 
 import (
-	"crypto/rand"
-	"encoding/hex"
-	"fmt"
+	"errors"
 	"net/http"
-
-	"api/config"
-
-	"github.com/dgrijalva/jwt-go"
 )
 
 // cors is a middleware function that sets the CORS headers
@@ -24,39 +18,30 @@ func cors(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
-// generateSalt generates a random salt for password hashing
-func generateSalt() string {
-	bytes := make([]byte, 16)
-	if _, err := rand.Read(bytes); err != nil {
-		return ""
-	}
-	return hex.EncodeToString(bytes)
+// VerifyToken verifies an access token with the signing key named by its kid header and
+// returns its claims. It is the single place where tokens are verified.
+func VerifyToken(tokenString string) (*JwtClaims, error) {
+	return verifyToken(GetKeySet(), tokenString)
 }
 
-// DecodeJWTToken decodes a JWT and verifies its signature with a secret key
+// DecodeJWTToken decodes a JWT and verifies its signature.
+//
+// Deprecated: tokens are signed with the key set, so secretKey is ignored; use VerifyToken.
 func DecodeJWTToken(tokenString, secretKey string) (*JwtClaims, error) {
-	config := config.NewConfig()
-	// Parse the token and validate the signature
-	token, err := jwt.ParseWithClaims(tokenString, &JwtClaims{}, func(token *jwt.Token) (interface{}, error) {
-		// Check if the signing method is what we expect (HS256)
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Method)
-		}
-
-		// Return the secret key for signature verification
-		return []byte(config.SecretKey), nil
-	})
+	return VerifyToken(tokenString)
+}
 
-	if err != nil {
+// verifyToken verifies a token against a key set
+func verifyToken(keys *KeySet, tokenString string) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	if _, err := keys.Verify(tokenString, claims); err != nil {
 		return nil, err
 	}
-
-	// Check if the token is valid and contains our expected claims
-	if claims, ok := token.Claims.(*JwtClaims); ok && token.Valid {
-		return claims, nil
+	// Challenge and other special-purpose tokens are signed by the same keys
+	if claims.Purpose != "" {
+		return nil, errors.New("token is not an access token")
 	}
-
-	return nil, fmt.Errorf("invalid token or claims")
+	return claims, nil
 }
 
 // getTokenFromRequest retrieves the token from the request header or cookies
@@ -75,19 +60,3 @@ func getTokenFromRequest(r *http.Request) string {
 
 	return ""
 }
-
-// validateToken validates the JWT token
-func validateToken(tokenString string) (*jwt.Token, error) {
-	config := config.NewConfig()
-	// Parse and validate the JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.SecretKey), nil // Replace "your-secret-key" with your actual secret key
-	})
-
-	// fmt.Println("Token", token)
-	if err != nil {
-		return nil, err
-	}
-
-	return token, nil
-}
@@ -5,6 +5,7 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/graphql-go/graphql"
 )
@@ -42,7 +43,7 @@ func (auth *AuthorizeWorkflow) IsSuperAdmin(userId int) *AuthorizeWorkflow {
 func (auth *AuthorizeWorkflow) GetUserIDFromToken(p graphql.ResolveParams) *AuthorizeWorkflow {
 	userKey := ContextKey("user")
 	tokenString, _ := p.Context.Value(userKey).(string)
-	claims, err := DecodeJWTToken(tokenString, appConfig.SecretKey)
+	claims, err := VerifyToken(tokenString)
 
 	if err != nil {
 		auth.addError(errors.New("token expired"))
@@ -79,15 +80,17 @@ func (auth *AuthorizeWorkflow) setResult(res interface{}) *AuthorizeWorkflow {
 // GetUserName retrieves the user name from the token
 func GetUserName(p graphql.ResolveParams) (JwtClaims, error) {
 	tokenString, _ := p.Context.Value(userKey).(string)
-	config := config.NewConfig()
-	claim, err := DecodeJWTToken(tokenString, config.SecretKey)
-	return *claim, err
+	claim, err := VerifyToken(tokenString)
+	if err != nil {
+		return JwtClaims{}, err
+	}
+	return *claim, nil
 }
 
 // GetUserPermission retrieves the user permission from the token
 func GetUserPermission(r *http.Request) ([]*UserPermissionView, error) {
 	tokenString := getTokenFromRequest(r)
-	claims, err := DecodeJWTToken(tokenString, config.SecretKey)
+	claims, err := VerifyToken(tokenString)
 	if err != nil {
 		return nil, err
 	}
@@ -99,20 +102,27 @@ func GetUserPermission(r *http.Request) ([]*UserPermissionView, error) {
 	return userPermission, nil
 }
 
-// HasUserApiPermission checks if the user has the API permission
+// HasUserApiPermission checks if the user has the API permission. Requests authenticated
+// with an API key must also be allowed by the key's scopes.
 func HasUserApiPermission(r *http.Request) (bool, error) {
-	tokenString := getTokenFromRequest(r)
-	claims, err := DecodeJWTToken(tokenString, config.SecretKey)
-	if err != nil {
-		return false, err
+	var userID int
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		if !key.Allows(r.URL.Path, methodAction(r.Method)) {
+			return false, nil
+		}
+		userID = key.UserID
+	} else {
+		tokenString := getTokenFromRequest(r)
+		claims, err := VerifyToken(tokenString)
+		if err != nil {
+			return false, err
+		}
+		userID = claims.UserID
 	}
 
 	userPermissionRepo := NewUserPermissionRepo()
 
-	// fmt.Println("claims", claims.UserID)
-
-	isSuperAdmin, err := userPermissionRepo.IsSuperAdmin(claims.UserID)
-	fmt.Printf("isSuperAdmin:%v, err:%v", isSuperAdmin, err)
+	isSuperAdmin, err := userPermissionRepo.IsSuperAdmin(userID)
 	if err != nil {
 		fmt.Printf("err:%v", err)
 		return false, err
@@ -122,27 +132,32 @@ func HasUserApiPermission(r *http.Request) (bool, error) {
 		return true, nil
 	}
 
-	userPermission, err := userPermissionRepo.GetUserApiPermissionView(claims.UserID, r.URL.Path)
-	// fmt.Printf("userPermission:%v, err:%v", userPermission, err)
-
+	// Role permissions are route patterns with method lists and deny rules
+	decision, err := GetPermissionEvaluator().Evaluate(userID, r.Method, r.URL.Path)
 	if err != nil {
 		return false, err
 	}
-	method := r.Method
-
-	// fmt.Printf("userPermission:%v", userPermission)
-
-	switch method {
-	case http.MethodGet:
-		return userPermission.CanRead, nil
-	case http.MethodPost:
-		return userPermission.CanWrite, nil
-	case http.MethodPut:
-		return userPermission.CanWrite, nil
-	case http.MethodDelete:
-		return userPermission.CanDelete, nil
+	return decision.Allowed, nil
+}
+
+// versionedResources maps versioned path prefixes to the unversioned prefixes permissions are granted on
+var versionedResources = []struct {
+	prefix   string
+	resource string
+}{
+	{prefix: "/api/v1/loans/", resource: "/loans/"},
+	{prefix: "/api/v1/", resource: "/api/"},
+}
+
+// apiResource returns the resource name a request path is authorized against,
+// so /api/v1/payments and its alias /api/payments share one permission
+func apiResource(path string) string {
+	for _, versioned := range versionedResources {
+		if rest, ok := strings.CutPrefix(path, versioned.prefix); ok {
+			return versioned.resource + rest
+		}
 	}
-	return false, nil
+	return path
 }
 
 // AuthorizeUserMiddleware is a middleware function that checks if the user has the API permission
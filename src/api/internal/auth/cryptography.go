@@ -2,30 +2,34 @@ package auth
 
 import (
 	"crypto/md5"
+	"crypto/rand"
 	"encoding/hex"
-	"math/rand"
-	
+	"math/big"
 )
 
 func GenerateRandomSalt(length int) string {
 	// Define the character set from which to generate the salt
 	charset := "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 
-	// Seed the random number generator
-	//rand.Seed(time.Now().UnixNano())
-
 	// Create a byte slice to store the salt characters
 	salt := make([]byte, length)
 
-	// Fill the byte slice with random characters from the charset
+	// Fill the byte slice with characters picked by the system's secure random source
 	for i := 0; i < length; i++ {
-		salt[i] = charset[rand.Intn(len(charset))]
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return ""
+		}
+		salt[i] = charset[n.Int64()]
 	}
 
 	// Convert the byte slice to a string and return it
 	return string(salt)
 }
 
+// HashString returns the hex MD5 of input.
+//
+// Deprecated: only used to verify legacy password hashes; use HashPassword.
 func HashString(input string) string {
 
 	// Calculate the MD5 hash of the concatenated string
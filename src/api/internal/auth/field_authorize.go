@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+)
+
+// Field authorization error codes, reported under the error's "extensions"
+const (
+	FieldErrUnauthenticated = "UNAUTHENTICATED"
+	FieldErrForbidden       = "FORBIDDEN"
+)
+
+const fieldPermissionsKey = ContextKey("field_permissions")
+
+var (
+	ErrFieldUnauthenticated = errors.New("authentication is required to access this field")
+	ErrFieldForbidden       = errors.New("not authorized to access this field")
+)
+
+// FieldAuthError is returned by a field the user may not resolve. The field resolves to
+// null and the rest of the query continues; graphql-go reports the code and permission
+// under the error's "extensions".
+type FieldAuthError struct {
+	Code       string
+	Permission string
+	err        error
+}
+
+func (e *FieldAuthError) Error() string {
+	return e.err.Error()
+}
+
+func (e *FieldAuthError) Unwrap() error {
+	return e.err
+}
+
+// Extensions implements gqlerrors.ExtendedError
+func (e *FieldAuthError) Extensions() map[string]interface{} {
+	return map[string]interface{}{
+		"code":       e.Code,
+		"permission": e.Permission,
+	}
+}
+
+// fieldPermissions are the GraphQL permissions of one user, loaded once per request
+type fieldPermissions struct {
+	userID     int
+	once       sync.Once
+	superAdmin bool
+	allowed    map[string]bool
+	err        error
+}
+
+// WithFieldPermissions returns a context in which the GraphQL permissions of a user are
+// loaded on the first authorized field and reused by every other field of the request
+func WithFieldPermissions(ctx context.Context, userID int) context.Context {
+	return context.WithValue(ctx, fieldPermissionsKey, &fieldPermissions{userID: userID})
+}
+
+// FieldAuthorizer checks GraphQL fields against the caller's role permissions of
+// resource type GraphQL. It is the code-first form of an @auth(permission: "...")
+// directive: wrap a field's resolver with Resolver and a permission name.
+type FieldAuthorizer struct {
+	perms *UserPermissionRepo
+}
+
+var (
+	fieldAuthorizerOnce     sync.Once
+	fieldAuthorizerInstance *FieldAuthorizer
+)
+
+// NewFieldAuthorizer creates a FieldAuthorizer
+func NewFieldAuthorizer(perms *UserPermissionRepo) *FieldAuthorizer {
+	return &FieldAuthorizer{perms: perms}
+}
+
+// GetFieldAuthorizer returns the shared FieldAuthorizer
+func GetFieldAuthorizer() *FieldAuthorizer {
+	fieldAuthorizerOnce.Do(func() {
+		fieldAuthorizerInstance = NewFieldAuthorizer(NewUserPermissionRepo())
+	})
+	return fieldAuthorizerInstance
+}
+
+// AuthorizeResolverClean wraps a resolver so it only runs for users granted permission
+func AuthorizeResolverClean(permission string, next graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return GetFieldAuthorizer().Resolver(permission, next)
+}
+
+// Resolver wraps a resolver so it only runs for users granted permission. Wrap it
+// outside any cache resolver so cached results are not served to other users.
+func (a *FieldAuthorizer) Resolver(permission string, next graphql.FieldResolveFn) graphql.FieldResolveFn {
+	return func(p graphql.ResolveParams) (interface{}, error) {
+		if err := a.Authorize(p.Context, permission); err != nil {
+			return nil, err
+		}
+		return next(p)
+	}
+}
+
+// Authorize checks a permission for the user of the request context
+func (a *FieldAuthorizer) Authorize(ctx context.Context, permission string) error {
+	perms, ok := ctx.Value(fieldPermissionsKey).(*fieldPermissions)
+	if !ok {
+		// Without a per-request cache, verify the token the authentication handler stored
+		claims, err := GetUserName(graphql.ResolveParams{Context: ctx})
+		if err != nil {
+			return &FieldAuthError{Code: FieldErrUnauthenticated, Permission: permission, err: ErrFieldUnauthenticated}
+		}
+		perms = &fieldPermissions{userID: claims.UserID}
+	}
+
+	perms.once.Do(func() {
+		perms.superAdmin, perms.err = a.perms.IsSuperAdmin(perms.userID)
+		if perms.err == nil && !perms.superAdmin {
+			perms.allowed, perms.err = a.perms.GetUserGraphQLPermissions(perms.userID)
+		}
+	})
+	if perms.err != nil {
+		return perms.err
+	}
+
+	if perms.superAdmin || perms.allowed[permission] {
+		return nil
+	}
+	return &FieldAuthError{Code: FieldErrForbidden, Permission: permission, err: ErrFieldForbidden}
+}
@@ -1,16 +1,15 @@
 package auth
 
 import (
-	"api/config"
 	"api/internal/handler"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
 	"strconv"
 	"time"
-
-	"github.com/dgrijalva/jwt-go"
 )
 
 func GetRequestID(r *http.Request) string {
@@ -56,11 +55,17 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	userRepo := NewUserRepo()
-	config := config.NewConfig()
+	// Refuse attempts from usernames and addresses that failed too often
+	ip := ClientIP(r)
+	throttle := GetLoginThrottle()
+	if !checkLoginThrottle(w, r, throttle, user.Username, ip) {
+		return
+	}
 
+	userRepo := NewUserRepo()
 	userdb, err := userRepo.GetUserByName(user.Username) // users[user.Username]
 	if err != nil {
+		recordLoginFailure(r, throttle, user.Username, ip, 0, "unknown username")
 		resp := handler.NewErrorResponse(
 			http.StatusUnauthorized,
 			"Unauthorized",
@@ -73,10 +78,13 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
-	// fmt.Println("User")
-	hash := HashString(user.Username + user.Password + userdb.Salt)
-	// fmt.Println("UserDbPassword:", userdb.Password, "Hash:",hash, "UserNameDb:", userdb.Username, "username", user.Username )
-	if userdb.Password != hash {
+	params := NewPasswordParams()
+	valid, needsRehash, err := VerifyPassword(userdb, user.Password, params)
+	if err != nil {
+		log.Printf("[error] - Verify password of user %s: %v", userdb.Username, err)
+	}
+	if !valid {
+		recordLoginFailure(r, throttle, userdb.Username, ip, userdb.UserID, "invalid password")
 		resp := handler.NewErrorResponse(
 			http.StatusUnauthorized,
 			"Unauthorized",
@@ -90,19 +98,37 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	// fmt.Println("Duration", time.Duration(config.TokenAge))
-	expirationTime := time.Now().Add(time.Duration(config.TokenAge) * time.Minute)
+	// Upgrade legacy or outdated hashes now that the plain password is known
+	if needsRehash {
+		if err := upgradePasswordHash(userRepo, userdb, user.Password, params); err != nil {
+			log.Printf("[error] - Upgrade password hash of user %s: %v", userdb.Username, err)
+		}
+	}
+
+	// Users with MFA get a challenge to complete at /mfa/verify instead of tokens.
+	// Their failed logins are only reset once the code is verified too.
+	mfaEnabled, err := GetMFAService().Enabled(userdb.UserID)
+	if err != nil {
+		log.Printf("[error] - Check MFA of user %s: %v", userdb.Username, err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "MFA_CHECK_FAILED", "Failed to check MFA enrollment")
+		return
+	}
+	if mfaEnabled {
+		challenge, err := GetMFAService().NewChallenge(userdb.UserID, userdb.Username)
+		if err != nil {
+			writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "TOKEN_GENERATION_FAILED", err.Error())
+			return
+		}
+		RecordAuthEvent(r, AuthEvent{EventType: EventMFAChallenge, UserID: userdb.UserID, Username: userdb.Username, Success: true})
+		writeAuthResponse(w, r, challenge)
+		return
+	}
 
-	claims := &JwtClaims{
-		UserID:   userdb.UserID,
-		Username: userdb.Username,
-		StandardClaims: jwt.StandardClaims{
-			ExpiresAt: expirationTime.Unix(),
-		},
+	if err := throttle.RecordSuccess(userdb.Username); err != nil {
+		log.Printf("[error] - Reset failed logins of user %s: %v", userdb.Username, err)
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString([]byte(config.SecretKey))
+	tokens, err := GetTokenService().IssueTokens(userdb.UserID, userdb.Username, []string{AMRPassword})
 	if err != nil {
 		resp := handler.NewErrorResponse(
 			http.StatusInternalServerError,
@@ -117,17 +143,11 @@ func LoginHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:    "token",
-		Value:   tokenString,
-		Expires: expirationTime,
-	})
+	RecordAuthEvent(r, AuthEvent{EventType: EventLoginSuccess, UserID: userdb.UserID, Username: userdb.Username, Success: true})
+	setTokenCookies(w, tokens)
 
 	// Updated response format
-	responseData := JwtToken{
-		Token:     tokenString,
-		ExpiredAt: expirationTime.Unix(),
-	}
+	responseData := tokens
 	resp := handler.NewResponse(http.StatusOK, "Success", responseData, GetRequestID(r))
 
 	w.Header().Set("Content-Type", "application/json")
@@ -147,13 +167,29 @@ func AuthenticationHandler(next http.Handler) http.Handler {
 		}
 
 		// Validate the JWT token
-		token, err := validateToken(tokenString)
-		if err != nil || !token.Valid {
+		claims, err := VerifyToken(tokenString)
+		if err != nil {
 			http.Error(w, "Unauthorized", http.StatusUnauthorized)
 			return
 		}
 
+		// Same checks as JWTMiddleware: revoked tokens are rejected before they expire,
+		// and roles that require MFA only accept tokens from a login that passed it
+		if IsTokenRevoked(claims.Id) {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		satisfied, err := GetMFAService().SatisfiesMFA(claims)
+		if err != nil || !satisfied {
+			http.Error(w, "MFA required", http.StatusForbidden)
+			return
+		}
+
 		ctx := context.WithValue(r.Context(), userKey, tokenString)
+		// GraphQL field permissions are loaded once for the whole request
+		ctx = WithFieldPermissions(ctx, claims.UserID)
+		// Resolvers read and write the data of the token's tenant only
+		ctx = WithTenantID(ctx, claims.Tenant())
 		r = r.WithContext(ctx)
 
 		// Pass the request to the next handler if the token is valid
@@ -234,16 +270,30 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	salt := generateSalt()
-	hashedPassword := HashString(user.Username + user.Password + salt)
+	hashedPassword, err := HashPassword(user.Password, NewPasswordParams())
+	if err != nil {
+		resp := handler.NewErrorResponse(
+			http.StatusInternalServerError,
+			"Internal Server Error",
+			"REGISTRATION_FAILED",
+			"Failed to hash password",
+			GetRequestID(r),
+		)
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(resp.Status)
+		json.NewEncoder(w).Encode(resp)
+		return
+	}
 
+	// The salt is part of the PHC hash; the column is only used by legacy hashes
 	newUser := User{
 		Username:  user.Username,
 		Password:  hashedPassword,
-		Salt:      salt,
+		Salt:      "",
 		CreatedAt: time.Now(),
 		CreatedBy: user.Username,
 		StatusID:  1,
+		Email:     user.Email,
 	}
 
 	err = userRepo.CreateUser(&newUser)
@@ -261,6 +311,14 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if newUser.Email != "" {
+		go func(user User) {
+			if err := GetAccountService().SendVerification(&user); err != nil {
+				log.Printf("[error] - Send verification to %s: %v", user.Email, err)
+			}
+		}(newUser)
+	}
+
 	responseData := map[string]string{
 		"username": user.Username,
 		"message":  "User registered successfully",
@@ -275,12 +333,37 @@ func RegisterHandler(w http.ResponseWriter, r *http.Request) {
 func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	cors(w, r)
 
-	// Clear the authentication cookie
+	// Revoke the access token and the refresh token family so neither outlives the logout
+	var body RefreshRequest
+	json.NewDecoder(r.Body).Decode(&body)
+	if body.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshCookieName); err == nil {
+			body.RefreshToken = cookie.Value
+		}
+	}
+	service := GetTokenService()
+	claims, _ := service.ParseAccessToken(getTokenFromRequest(r))
+	if err := service.Revoke(claims, body.RefreshToken); err != nil {
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "LOGOUT_FAILED", "Failed to revoke tokens")
+		return
+	}
+	if claims != nil {
+		RecordAuthEvent(r, AuthEvent{EventType: EventLogout, UserID: claims.UserID, Username: claims.Username, Success: true})
+	}
+
+	// Clear the authentication cookies
 	http.SetCookie(w, &http.Cookie{
 		Name:    "token",
 		Value:   "",
 		Expires: time.Now().Add(-1 * time.Hour), // Set expiration in the past
 	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    "",
+		Path:     refreshCookiePath,
+		Expires:  time.Now().Add(-1 * time.Hour),
+		HttpOnly: true,
+	})
 
 	// Create a success response
 	resp := handler.NewResponse(http.StatusOK, "Success", map[string]string{"message": "Logged out successfully"}, GetRequestID(r))
@@ -289,92 +372,100 @@ func LogoutHandler(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-// CreateRoleHandler godoc
-// @Summary Create a new role
-// @Description Create a new role in the system
-// @Tags auth
-// @Accept json
-// @Produce json
-// @Param role body Role true "Role information"
-// @Success 201 {object} Role
-// @Failure 400 {object} types.ErrorResponse
-// @Router /role [post]
-func CreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
 	cors(w, r)
 
-	var role Role
-	err := json.NewDecoder(r.Body).Decode(&role)
+	tenantID, ok := TenantIDFromContext(r.Context())
+	if !ok {
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "UNAUTHORIZED", "No tenant for this request")
+		return
+	}
+	userRepo := NewUserRepo()
+	users, err := userRepo.GetUsersBySearchText(tenantID, r.URL.Query().Get("searchText"), 10, 0)
 	if err != nil {
-		resp := handler.NewErrorResponse(http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body", GetRequestID(r))
+		resp := handler.NewErrorResponse(http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed", GetRequestID(r))
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(resp.Status)
 		json.NewEncoder(w).Encode(resp)
 		return
 	}
 
-	roleRepo := NewRoleRepo()
-	roleRepo.InsertRole(&Role{
-		RoleName:     role.RoleName,
-		RoleDesc:     role.RoleDesc,
-		IsSuperAdmin: role.IsSuperAdmin,
-		CreatedAt:    time.Now(),
-		CreatedBy:    role.CreatedBy,
-		StatusID:     role.StatusID,
-	})
-
-	responseData := map[string]string{
-		"role_name": role.RoleName,
-		"message":   "Role created successfully",
-	}
-
-	resp := handler.NewResponse(http.StatusCreated, "Created", responseData, GetRequestID(r))
+	resp := handler.NewResponse(http.StatusOK, "Success", users, GetRequestID(r))
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(resp.Status)
 	json.NewEncoder(w).Encode(resp)
 }
 
-func CreateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
-	cors(w, r)
+// recordLoginFailure counts a failed login and records it, and the lockout it may cause
+func recordLoginFailure(r *http.Request, throttle *LoginThrottle, username, ip string, userID int, reason string) {
+	RecordAuthEvent(r, AuthEvent{EventType: EventLoginFailure, UserID: userID, Username: username, Detail: reason})
+	countLoginFailure(r, throttle, username, ip, userID)
+}
+
+// recordMFAFailure audits a failed MFA code and counts a wrong code as a failed login,
+// so the code cannot be guessed by logging in again with the known password
+func recordMFAFailure(r *http.Request, throttle *LoginThrottle, username, ip string, userID int, err error) {
+	RecordAuthEvent(r, AuthEvent{EventType: EventMFAFailure, UserID: userID, Username: username, Detail: err.Error()})
+	if errors.Is(err, ErrInvalidMFACode) || errors.Is(err, ErrTooManyMFAAttempts) {
+		countLoginFailure(r, throttle, username, ip, userID)
+	}
+}
 
-	var userRole UserRoles
-	err := json.NewDecoder(r.Body).Decode(&userRole)
+// checkLoginThrottle refuses attempts from usernames and addresses that failed too often
+func checkLoginThrottle(w http.ResponseWriter, r *http.Request, throttle *LoginThrottle, username, ip string) bool {
+	err := throttle.Check(username, ip)
+	if err == nil {
+		return true
+	}
+	var blocked *LoginBlockedError
+	if !errors.As(err, &blocked) {
+		log.Printf("[error] - Check login throttle of user %s: %v", username, err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "LOGIN_CHECK_FAILED", "Failed to check login attempts")
+		return false
+	}
+	RecordAuthEvent(r, AuthEvent{EventType: EventLoginBlocked, Username: username, Detail: blocked.Error()})
+	writeLoginBlocked(w, r, blocked)
+	return false
+}
+
+// countLoginFailure adds a failure to the login throttle and audits a resulting lockout
+func countLoginFailure(r *http.Request, throttle *LoginThrottle, username, ip string, userID int) {
+	locked, err := throttle.RecordFailure(username, ip)
 	if err != nil {
-		resp := handler.NewErrorResponse(http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body", GetRequestID(r))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.Status)
-		json.NewEncoder(w).Encode(resp)
+		log.Printf("[error] - Record failed login of user %s: %v", username, err)
 		return
 	}
+	if locked {
+		log.Printf("[warn] - Login locked for user %s from %s", username, ip)
+		RecordAuthEvent(r, AuthEvent{EventType: EventAccountLocked, UserID: userID, Username: username, Detail: "too many failed logins from " + ip})
+	}
+}
 
-	userRoleRepo := NewUserRoleRepo()
-	userRoleRepo.CreateUserRole(&userRole)
-
-	responseData := map[string]string{
-		"user_role_id": strconv.Itoa(userRole.UserRoleID),
-		"message":      "User role created successfully",
+// writeLoginBlocked tells the client when it may try to log in again
+func writeLoginBlocked(w http.ResponseWriter, r *http.Request, blocked *LoginBlockedError) {
+	seconds := int(blocked.RetryAfter.Seconds())
+	if blocked.RetryAfter > time.Duration(seconds)*time.Second {
+		seconds++
 	}
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
 
-	resp := handler.NewResponse(http.StatusCreated, "Created", responseData, GetRequestID(r))
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.Status)
-	json.NewEncoder(w).Encode(resp)
+	code := "LOGIN_THROTTLED"
+	if blocked.Locked {
+		code = "ACCOUNT_LOCKED"
+	}
+	writeAuthError(w, r, http.StatusTooManyRequests, "Too Many Requests", code, blocked.Error())
 }
 
-func GetUsersHandler(w http.ResponseWriter, r *http.Request) {
-	cors(w, r)
-
-	userRepo := NewUserRepo()
-	users, err := userRepo.GetUsersBySearchText(r.URL.Query().Get("searchText"), 10, 0)
+// upgradePasswordHash replaces a user's stored hash with an argon2id hash of password
+func upgradePasswordHash(repo *UserRepo, user *User, password string, params PasswordParams) error {
+	hash, err := HashPassword(password, params)
 	if err != nil {
-		resp := handler.NewErrorResponse(http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed", GetRequestID(r))
-		w.Header().Set("Content-Type", "application/json")
-		w.WriteHeader(resp.Status)
-		json.NewEncoder(w).Encode(resp)
-		return
+		return err
 	}
-
-	resp := handler.NewResponse(http.StatusOK, "Success", users, GetRequestID(r))
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(resp.Status)
-	json.NewEncoder(w).Encode(resp)
+	if err := repo.UpdatePassword(user.UserID, hash); err != nil {
+		return err
+	}
+	user.Password = hash
+	user.Salt = ""
+	return nil
 }
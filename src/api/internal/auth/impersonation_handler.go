@@ -0,0 +1,97 @@
+package auth
+
+import (
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// ImpersonateHandler godoc
+// @Summary Impersonate a user
+// @Description Issue a short-lived access token to act as another user of the same tenant, e.g. to see what a borrower sees. The token has an act claim naming the caller and no refresh token. The token can only read: requests that change anything are refused. Super admins cannot be impersonated, and every request made with the token is audited.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param request body ImpersonateRequest true "Why the user is impersonated"
+// @Success 200 {object} ImpersonationToken
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /users/{userId}/impersonate [post]
+func ImpersonateHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+	// Impersonation tokens carry the actor's amr, so the actor must have passed MFA
+	if satisfied, err := GetMFAService().SatisfiesMFA(claims); err != nil || !satisfied {
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "MFA_REQUIRED", "MFA required")
+		return
+	}
+
+	userID, err := strconv.Atoi(router.Param(r, "userId"))
+	if err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_USER_ID", "Invalid user ID")
+		return
+	}
+	var body ImpersonateRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	token, err := GetImpersonationService().Start(claims, userID, body.Reason)
+	if err != nil {
+		if !errors.Is(err, ErrImpersonateReason) && !errors.Is(err, ErrImpersonateNotFound) {
+			RecordAuthEvent(r, AuthEvent{EventType: EventImpersonateDenied, UserID: userID, Actor: claims.Username, Detail: err.Error()})
+		}
+		writeImpersonationError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventImpersonateStart, UserID: token.UserID, Username: token.Username, Actor: claims.Username, Success: true, Detail: body.Reason})
+	writeAuthResponse(w, r, token)
+}
+
+// StopImpersonationHandler godoc
+// @Summary Stop impersonating a user
+// @Description Revoke the impersonation token the request is made with
+// @Tags auth
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 401 {object} types.ErrorResponse
+// @Router /impersonate/stop [post]
+func StopImpersonationHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+	if err := GetImpersonationService().Stop(claims); err != nil {
+		writeImpersonationError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventImpersonateEnd, UserID: claims.UserID, Username: claims.Username, Actor: claims.Act.Username, Success: true})
+	writeAuthResponse(w, r, map[string]string{"message": "Impersonation ended"})
+}
+
+// writeImpersonationError maps impersonation errors to responses
+func writeImpersonationError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrImpersonateNotFound):
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "USER_NOT_FOUND", err.Error())
+	case errors.Is(err, ErrImpersonateReason), errors.Is(err, ErrImpersonateSelf), errors.Is(err, ErrNotImpersonating):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", err.Error())
+	case errors.Is(err, ErrImpersonateSuperAdmin), errors.Is(err, ErrImpersonateNested),
+		errors.Is(err, ErrImpersonateInactive), errors.Is(err, ErrTenantMismatch):
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "IMPERSONATION_FORBIDDEN", err.Error())
+	default:
+		log.Printf("[error] - Impersonation request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "IMPERSONATION_FAILED", "Request failed")
+	}
+}
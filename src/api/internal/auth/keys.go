@@ -0,0 +1,381 @@
+package auth
+
+import (
+	"api/config"
+	"context"
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// Supported token signing algorithms
+const (
+	AlgorithmRS256 = "RS256"
+	AlgorithmEdDSA = "EdDSA"
+)
+
+const (
+	// keyIDLayout prefixes generated key ids with their creation time
+	keyIDLayout = "20060102T150405.000Z"
+	// rsaKeyBits is the size of generated RSA keys
+	rsaKeyBits = 2048
+)
+
+// Key set errors
+var (
+	ErrUnknownKey       = errors.New("unknown signing key")
+	ErrNoSigningKey     = errors.New("no signing key available")
+	ErrUnknownAlgorithm = errors.New("unsupported signing algorithm")
+)
+
+// SigningKey is a private key from the key directory. Its id is the file name without .pem
+// and is sent as the kid header of every token it signs.
+type SigningKey struct {
+	ID        string
+	Algorithm string
+	CreatedAt time.Time
+	private   crypto.Signer
+}
+
+// Public returns the key's public half
+func (k *SigningKey) Public() crypto.PublicKey {
+	return k.private.Public()
+}
+
+// method returns the JWT signing method matching the key type
+func (k *SigningKey) method() jwt.SigningMethod {
+	if k.Algorithm == AlgorithmEdDSA {
+		return SigningMethodEdDSA
+	}
+	return jwt.SigningMethodRS256
+}
+
+// KeySet signs tokens with the newest key in a directory and verifies them with any key
+// still in use. After a rotation the previous keys keep verifying for the grace period,
+// so tokens they signed stay valid until they expire.
+type KeySet struct {
+	mu          sync.RWMutex
+	dir         string
+	algorithm   string
+	rotateAfter time.Duration
+	grace       time.Duration
+	keys        []*SigningKey // oldest first
+	now         func() time.Time
+}
+
+var (
+	keySetOnce     sync.Once
+	keySetInstance *KeySet
+)
+
+// NewKeySet loads the keys in dir, creating the directory and a first key when there are none.
+// New keys use algorithm; a key is rotated once it is older than rotateAfter.
+func NewKeySet(dir, algorithm string, rotateAfter, grace time.Duration) (*KeySet, error) {
+	if algorithm != AlgorithmRS256 && algorithm != AlgorithmEdDSA {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, algorithm)
+	}
+	ks := &KeySet{dir: dir, algorithm: algorithm, rotateAfter: rotateAfter, grace: grace, now: time.Now}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("error creating key directory: %w", err)
+	}
+	if err := ks.Load(); err != nil {
+		return nil, err
+	}
+	if _, err := ks.current(); errors.Is(err, ErrNoSigningKey) {
+		if _, err := ks.Rotate(); err != nil {
+			return nil, err
+		}
+	}
+	return ks, nil
+}
+
+// GetKeySet returns the key set configured by JWT_KEY_DIR and JWT_ALGORITHM
+func GetKeySet() *KeySet {
+	keySetOnce.Do(func() {
+		cfg := config.NewConfig()
+		var err error
+		keySetInstance, err = NewKeySet(cfg.JwtKeyDir, cfg.JwtAlgorithm,
+			time.Duration(cfg.JwtRotateHours)*time.Hour, time.Duration(cfg.JwtGraceHours)*time.Hour)
+		if err != nil {
+			log.Fatalf("Error loading JWT signing keys: %v", err)
+		}
+	})
+	return keySetInstance
+}
+
+// Load reads every *.pem private key in the directory
+func (ks *KeySet) Load() error {
+	paths, err := filepath.Glob(filepath.Join(ks.dir, "*.pem"))
+	if err != nil {
+		return fmt.Errorf("error listing keys: %w", err)
+	}
+
+	keys := make([]*SigningKey, 0, len(paths))
+	for _, path := range paths {
+		key, err := readSigningKey(path)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.mu.Unlock()
+	return nil
+}
+
+// Rotate generates a new signing key, writes it to the directory and makes it current
+func (ks *KeySet) Rotate() (*SigningKey, error) {
+	now := ks.now().UTC()
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return nil, fmt.Errorf("error generating key id: %w", err)
+	}
+	key := &SigningKey{
+		ID:        now.Format(keyIDLayout) + "-" + hex.EncodeToString(suffix),
+		Algorithm: ks.algorithm,
+		CreatedAt: now,
+	}
+
+	var err error
+	switch ks.algorithm {
+	case AlgorithmEdDSA:
+		_, key.private, err = ed25519.GenerateKey(rand.Reader)
+	default:
+		key.private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error generating signing key: %w", err)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(key.private)
+	if err != nil {
+		return nil, fmt.Errorf("error encoding signing key: %w", err)
+	}
+	data := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if err := os.WriteFile(filepath.Join(ks.dir, key.ID+".pem"), data, 0o600); err != nil {
+		return nil, fmt.Errorf("error writing signing key: %w", err)
+	}
+
+	ks.mu.Lock()
+	ks.keys = append(ks.keys, key)
+	ks.mu.Unlock()
+	return key, nil
+}
+
+// RotateIfDue reloads the directory, which may have been rotated by another instance,
+// rotates when the current key is too old and deletes keys past their grace period
+func (ks *KeySet) RotateIfDue() (bool, error) {
+	if err := ks.Load(); err != nil {
+		return false, err
+	}
+
+	rotated := false
+	current, err := ks.current()
+	if errors.Is(err, ErrNoSigningKey) || (err == nil && ks.rotateAfter > 0 && ks.now().Sub(current.CreatedAt) >= ks.rotateAfter) {
+		if _, err := ks.Rotate(); err != nil {
+			return false, err
+		}
+		rotated = true
+	}
+
+	for _, key := range ks.retired() {
+		if err := os.Remove(filepath.Join(ks.dir, key.ID+".pem")); err != nil && !os.IsNotExist(err) {
+			return rotated, fmt.Errorf("error removing retired key %s: %w", key.ID, err)
+		}
+	}
+	return rotated, ks.Load()
+}
+
+// Run rotates keys every interval until the context is cancelled
+func (ks *KeySet) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if rotated, err := ks.RotateIfDue(); err != nil {
+			log.Printf("[error] - Rotate JWT signing keys: %v", err)
+		} else if rotated {
+			log.Printf("[info] - Rotated JWT signing key")
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Sign signs claims with the current key and sets the kid header
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	key, err := ks.current()
+	if err != nil {
+		return "", err
+	}
+	token := jwt.NewWithClaims(key.method(), claims)
+	token.Header["kid"] = key.ID
+	return token.SignedString(key.private)
+}
+
+// Verify parses a token, checks its signature with the key named by its kid header and
+// fills claims. Tokens signed with another algorithm than their key's are rejected.
+func (ks *KeySet) Verify(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key := ks.find(kid)
+		if key == nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownKey, kid)
+		}
+		if token.Method.Alg() != key.method().Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Method.Alg())
+		}
+		return key.Public(), nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !token.Valid {
+		return nil, fmt.Errorf("invalid token")
+	}
+	return token, nil
+}
+
+// Keys returns the keys that currently verify tokens, oldest first
+func (ks *KeySet) Keys() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	retired := ks.retiredLocked()
+	return append([]*SigningKey(nil), ks.keys[len(retired):]...)
+}
+
+// JWKS returns the public keys in JSON Web Key Set format
+func (ks *KeySet) JWKS() JWKSet {
+	keys := ks.Keys()
+	set := JWKSet{Keys: make([]JWK, 0, len(keys))}
+	for _, key := range keys {
+		jwk := JWK{KeyID: key.ID, Use: "sig", Algorithm: key.Algorithm}
+		switch public := key.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KeyType = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KeyType = "OKP"
+			jwk.Curve = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+// current returns the newest key
+func (ks *KeySet) current() (*SigningKey, error) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	if len(ks.keys) == 0 {
+		return nil, ErrNoSigningKey
+	}
+	return ks.keys[len(ks.keys)-1], nil
+}
+
+// find returns the key with the given id unless it is past its grace period
+func (ks *KeySet) find(kid string) *SigningKey {
+	for _, key := range ks.Keys() {
+		if key.ID == kid {
+			return key
+		}
+	}
+	return nil
+}
+
+// retired returns the keys whose grace period is over
+func (ks *KeySet) retired() []*SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.retiredLocked()
+}
+
+// retiredLocked returns the oldest keys that were superseded more than the grace period ago;
+// the caller holds mu
+func (ks *KeySet) retiredLocked() []*SigningKey {
+	now := ks.now()
+	n := 0
+	for n < len(ks.keys)-1 && !now.Before(ks.keys[n+1].CreatedAt.Add(ks.grace)) {
+		n++
+	}
+	return ks.keys[:n]
+}
+
+// readSigningKey reads a PKCS#8 (or PKCS#1 RSA) private key. The creation time is taken
+// from a generated key id and otherwise from the file's modification time.
+func readSigningKey(path string) (*SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading key %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("error reading key %s: no PEM data", path)
+	}
+
+	var private interface{}
+	if block.Type == "RSA PRIVATE KEY" {
+		private, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	} else {
+		private, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing key %s: %w", path, err)
+	}
+
+	key := &SigningKey{ID: strings.TrimSuffix(filepath.Base(path), ".pem")}
+	switch private := private.(type) {
+	case *rsa.PrivateKey:
+		key.Algorithm = AlgorithmRS256
+		key.private = private
+	case ed25519.PrivateKey:
+		key.Algorithm = AlgorithmEdDSA
+		key.private = private
+	default:
+		return nil, fmt.Errorf("%w: key %s is a %T", ErrUnknownAlgorithm, path, private)
+	}
+
+	if prefix, _, ok := strings.Cut(key.ID, "-"); ok {
+		key.CreatedAt, err = time.Parse(keyIDLayout, prefix)
+	}
+	if key.CreatedAt.IsZero() || err != nil {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, fmt.Errorf("error reading key %s: %w", path, err)
+		}
+		key.CreatedAt = info.ModTime().UTC()
+	}
+	return key, nil
+}
@@ -0,0 +1,335 @@
+package auth
+
+import (
+	"api/config"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// userKeyPrefix and ipKeyPrefix separate the two kinds of login failure counters
+	userKeyPrefix = "user:"
+	ipKeyPrefix   = "ip:"
+	// loginFailureWindow is how long a failure counts towards delays and lockouts
+	loginFailureWindow = time.Hour
+)
+
+// LoginFailure counts the recent failed logins of a username or an IP address
+type LoginFailure struct {
+	Key          string     `json:"key"`
+	Failures     int        `json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `json:"locked_until,omitempty"`
+}
+
+// LoginPolicy configures login throttling. After DelayAfter failures each attempt
+// has to wait twice as long as the previous one, from BaseDelay up to MaxDelay.
+// A username is locked after MaxFailures failures and an IP after IPMaxFailures.
+type LoginPolicy struct {
+	DelayAfter      int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	MaxFailures     int
+	IPMaxFailures   int
+	LockoutDuration time.Duration
+}
+
+// DefaultLoginPolicy is used for settings that are not configured
+var DefaultLoginPolicy = LoginPolicy{
+	DelayAfter:      3,
+	BaseDelay:       time.Second,
+	MaxDelay:        time.Minute,
+	MaxFailures:     5,
+	IPMaxFailures:   50,
+	LockoutDuration: 15 * time.Minute,
+}
+
+// LoginBlockedError is returned while a login may not be attempted
+type LoginBlockedError struct {
+	// Locked is true for a lockout and false for a progressive delay
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+func (e *LoginBlockedError) Error() string {
+	if e.Locked {
+		return fmt.Sprintf("too many failed logins; locked for %s", e.RetryAfter.Round(time.Second))
+	}
+	return fmt.Sprintf("too many failed logins; retry in %s", e.RetryAfter.Round(time.Second))
+}
+
+// LoginThrottle tracks failed logins per username and per IP address
+type LoginThrottle struct {
+	repo   *LockoutRepo
+	policy LoginPolicy
+	now    func() time.Time
+}
+
+var (
+	loginThrottleOnce     sync.Once
+	loginThrottleInstance *LoginThrottle
+)
+
+// NewLoginPolicy returns the login policy from the configuration
+func NewLoginPolicy() LoginPolicy {
+	policy := DefaultLoginPolicy
+	cfg := config.NewConfig()
+	if cfg == nil {
+		return policy
+	}
+	if cfg.LoginDelayAfter > 0 {
+		policy.DelayAfter = cfg.LoginDelayAfter
+	}
+	if cfg.LoginMaxFails > 0 {
+		policy.MaxFailures = cfg.LoginMaxFails
+	}
+	if cfg.LoginIPMaxFails > 0 {
+		policy.IPMaxFailures = cfg.LoginIPMaxFails
+	}
+	if cfg.LoginLockoutMin > 0 {
+		policy.LockoutDuration = time.Duration(cfg.LoginLockoutMin) * time.Minute
+	}
+	return policy
+}
+
+// NewLoginThrottle creates a LoginThrottle with the configured policy
+func NewLoginThrottle(repo *LockoutRepo, policy LoginPolicy) *LoginThrottle {
+	return &LoginThrottle{repo: repo, policy: policy, now: time.Now}
+}
+
+// GetLoginThrottle returns the shared LoginThrottle
+func GetLoginThrottle() *LoginThrottle {
+	loginThrottleOnce.Do(func() {
+		loginThrottleInstance = NewLoginThrottle(NewLockoutRepo(), NewLoginPolicy())
+	})
+	return loginThrottleInstance
+}
+
+// Check returns a *LoginBlockedError when the username or the IP address may not
+// attempt a login yet
+func (t *LoginThrottle) Check(username, ip string) error {
+	now := t.now()
+	var blocked *LoginBlockedError
+	for _, key := range loginKeys(username, ip) {
+		failure, err := t.repo.GetLoginFailure(key)
+		if err != nil {
+			return err
+		}
+		if failure == nil {
+			continue
+		}
+
+		if failure.LockedUntil != nil && now.Before(*failure.LockedUntil) {
+			wait := failure.LockedUntil.Sub(now)
+			if blocked == nil || !blocked.Locked || wait > blocked.RetryAfter {
+				blocked = &LoginBlockedError{Locked: true, RetryAfter: wait}
+			}
+			continue
+		}
+
+		if now.Sub(failure.LastFailedAt) > loginFailureWindow {
+			continue
+		}
+		next := failure.LastFailedAt.Add(t.delay(failure.Failures))
+		if now.Before(next) && blocked == nil {
+			blocked = &LoginBlockedError{RetryAfter: next.Sub(now)}
+		}
+	}
+	if blocked != nil {
+		return blocked
+	}
+	return nil
+}
+
+// RecordFailure counts a failed login and locks the username or the IP address once
+// it has failed too often. It returns true when this failure caused a lockout.
+func (t *LoginThrottle) RecordFailure(username, ip string) (bool, error) {
+	now := t.now()
+	locked := false
+	for _, key := range loginKeys(username, ip) {
+		failure, err := t.repo.IncrementLoginFailure(key, now, now.Add(-loginFailureWindow))
+		if err != nil {
+			return false, err
+		}
+
+		limit := t.policy.MaxFailures
+		if strings.HasPrefix(key, ipKeyPrefix) {
+			limit = t.policy.IPMaxFailures
+		}
+		if limit > 0 && failure.Failures >= limit && (failure.LockedUntil == nil || !now.Before(*failure.LockedUntil)) {
+			if err := t.repo.LockLogin(key, now.Add(t.policy.LockoutDuration)); err != nil {
+				return false, err
+			}
+			locked = true
+		}
+	}
+	return locked, nil
+}
+
+// RecordSuccess forgets the failures of a username after a successful login. The IP
+// counter is kept, so that one valid account does not reset an attack from that address.
+func (t *LoginThrottle) RecordSuccess(username string) error {
+	_, err := t.repo.ClearLoginFailures(userKeyPrefix + normalizeUsername(username))
+	return err
+}
+
+// Unlock lifts the lockout of a username; false when it had no failures
+func (t *LoginThrottle) Unlock(username string) (bool, error) {
+	return t.repo.ClearLoginFailures(userKeyPrefix + normalizeUsername(username))
+}
+
+// UnlockIP lifts the lockout of an IP address; false when it had no failures
+func (t *LoginThrottle) UnlockIP(ip string) (bool, error) {
+	return t.repo.ClearLoginFailures(ipKeyPrefix + ip)
+}
+
+// Lockouts lists the usernames and IP addresses that are currently locked
+func (t *LoginThrottle) Lockouts() ([]*LoginFailure, error) {
+	return t.repo.GetActiveLockouts(t.now())
+}
+
+// delay returns how long to wait after the given number of failures
+func (t *LoginThrottle) delay(failures int) time.Duration {
+	if failures < t.policy.DelayAfter {
+		return 0
+	}
+	delay := t.policy.BaseDelay
+	for i := t.policy.DelayAfter; i < failures && delay < t.policy.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > t.policy.MaxDelay {
+		delay = t.policy.MaxDelay
+	}
+	return delay
+}
+
+// loginKeys returns the counter keys of a login attempt
+func loginKeys(username, ip string) []string {
+	keys := []string{userKeyPrefix + normalizeUsername(username)}
+	if ip != "" {
+		keys = append(keys, ipKeyPrefix+ip)
+	}
+	return keys
+}
+
+// normalizeUsername makes counters case-insensitive, so "Alice" and "alice" share one
+func normalizeUsername(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// ClientIP returns the address of the client. Forwarded headers are only used when
+// the request comes from one of the TRUSTED_PROXIES, since clients can send any value.
+// TRUST_PROXY_HEADERS trusts the server's direct peer as the only proxy, so the client
+// is the rightmost X-Forwarded-For hop that the peer appended.
+func ClientIP(r *http.Request) string {
+	cfg := config.GetConfig()
+	if cfg == nil {
+		return remoteIP(r)
+	}
+	if cfg.TrustedProxies != "" {
+		return getTrustedProxies(cfg.TrustedProxies).ClientIP(r)
+	}
+	if cfg.TrustProxy {
+		peer, _ := ParseTrustedProxies(remoteIP(r))
+		return peer.ClientIP(r)
+	}
+	return remoteIP(r)
+}
+
+// TrustedProxies are the addresses of the reverse proxies in front of the server
+type TrustedProxies []*net.IPNet
+
+var (
+	trustedProxiesOnce     sync.Once
+	trustedProxiesInstance TrustedProxies
+)
+
+// getTrustedProxies parses the configured proxies once; invalid entries are logged and skipped
+func getTrustedProxies(spec string) TrustedProxies {
+	trustedProxiesOnce.Do(func() {
+		proxies, err := ParseTrustedProxies(spec)
+		if err != nil {
+			log.Printf("[error] - %s: %v", config.TrustedProxies, err)
+		}
+		trustedProxiesInstance = proxies
+	})
+	return trustedProxiesInstance
+}
+
+// ParseTrustedProxies parses a comma-separated list of IP addresses and CIDR ranges.
+// The entries that could be parsed are returned along with the first error.
+func ParseTrustedProxies(spec string) (TrustedProxies, error) {
+	var proxies TrustedProxies
+	var firstErr error
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		if !strings.Contains(entry, "/") {
+			if ip := net.ParseIP(entry); ip != nil && ip.To4() != nil {
+				entry += "/32"
+			} else {
+				entry += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(entry)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("invalid trusted proxy %q: %w", entry, err)
+			}
+			continue
+		}
+		proxies = append(proxies, network)
+	}
+	return proxies, firstErr
+}
+
+// Contains reports whether an address belongs to a trusted proxy
+func (p TrustedProxies) Contains(address string) bool {
+	ip := net.ParseIP(address)
+	if ip == nil {
+		return false
+	}
+	for _, network := range p {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the address of the client. X-Forwarded-For is read from the right,
+// skipping the proxies, so that a client cannot pick its address by sending the header.
+func (p TrustedProxies) ClientIP(r *http.Request) string {
+	ip := remoteIP(r)
+	if !p.Contains(ip) {
+		return ip
+	}
+	hops := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !p.Contains(hop) {
+			break
+		}
+	}
+	return ip
+}
+
+// remoteIP returns the address the request came from
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
@@ -0,0 +1,153 @@
+package auth
+
+import (
+	"api/internal/router"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	// defaultEventsLimit and maxEventsLimit bound the page size of the auth event list
+	defaultEventsLimit = 50
+	maxEventsLimit     = 500
+)
+
+// GetAuthEventsHandler godoc
+// @Summary List auth events
+// @Description Query the authentication audit trail, newest first
+// @Tags auth
+// @Produce json
+// @Param user_id query int false "User ID"
+// @Param username query string false "Username"
+// @Param event_type query string false "Event type, e.g. login_failure"
+// @Param ip query string false "Client IP address"
+// @Param from query string false "Start time (RFC 3339)"
+// @Param to query string false "End time (RFC 3339)"
+// @Param limit query int false "Page size (default 50, max 500)"
+// @Param offset query int false "Offset"
+// @Success 200 {array} AuthEvent
+// @Failure 400 {object} types.ErrorResponse
+// @Router /auth-events [get]
+func GetAuthEventsHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	query := r.URL.Query()
+	filter := AuthEventFilter{
+		Username:  query.Get("username"),
+		EventType: query.Get("event_type"),
+		IPAddress: query.Get("ip"),
+		Limit:     defaultEventsLimit,
+	}
+
+	var err error
+	for name, target := range map[string]*int{"user_id": &filter.UserID, "limit": &filter.Limit, "offset": &filter.Offset} {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 0 {
+				writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_QUERY", "Invalid "+name)
+				return
+			}
+		}
+	}
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := query.Get(name); value != "" {
+			if *target, err = time.Parse(time.RFC3339, value); err != nil {
+				writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_QUERY", "Invalid "+name+", expected RFC 3339")
+				return
+			}
+		}
+	}
+	if filter.Limit == 0 {
+		filter.Limit = defaultEventsLimit
+	}
+	if filter.Limit > maxEventsLimit {
+		filter.Limit = maxEventsLimit
+	}
+
+	events, err := NewAuthEventRepo().GetAuthEvents(filter)
+	if err != nil {
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed")
+		return
+	}
+	writeAuthResponse(w, r, events)
+}
+
+// GetLockoutsHandler godoc
+// @Summary List lockouts
+// @Description List the usernames and IP addresses locked after failed logins
+// @Tags auth
+// @Produce json
+// @Success 200 {array} LoginFailure
+// @Router /lockouts [get]
+func GetLockoutsHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	lockouts, err := GetLoginThrottle().Lockouts()
+	if err != nil {
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed")
+		return
+	}
+	writeAuthResponse(w, r, lockouts)
+}
+
+// UnlockUserHandler godoc
+// @Summary Unlock a user
+// @Description Lift the lockout of a username and reset its failed login count
+// @Tags auth
+// @Produce json
+// @Param username path string true "Username"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Router /users/{username}/unlock [post]
+func UnlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+	username := router.Param(r, "username")
+	unlock(w, r, username, GetLoginThrottle().Unlock, AuthEvent{Username: username})
+}
+
+// UnlockIPHandler godoc
+// @Summary Unlock an IP address
+// @Description Lift the lockout of an IP address and reset its failed login count
+// @Tags auth
+// @Produce json
+// @Param ip path string true "IP address"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Router /lockouts/ip/{ip} [delete]
+func UnlockIPHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+	ip := router.Param(r, "ip")
+	unlock(w, r, ip, GetLoginThrottle().UnlockIP, AuthEvent{Detail: "ip " + ip})
+}
+
+// unlock clears a lockout and records who lifted it
+func unlock(w http.ResponseWriter, r *http.Request, subject string, clear func(string) (bool, error), event AuthEvent) {
+	cleared, err := clear(subject)
+	if err != nil {
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed")
+		return
+	}
+	if !cleared {
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "NOT_LOCKED", subject+" has no failed logins")
+		return
+	}
+
+	event.EventType = EventAccountUnlock
+	event.Actor = requestActor(r)
+	event.Success = true
+	RecordAuthEvent(r, event)
+	writeAuthResponse(w, r, map[string]string{"message": subject + " unlocked"})
+}
+
+// requestActor returns the username of the request's access token, or the prefix
+// of the API key it was made with
+func requestActor(r *http.Request) string {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return "apikey:" + key.Prefix
+	}
+	claims, err := VerifyToken(getTokenFromRequest(r))
+	if err != nil {
+		return ""
+	}
+	return claims.Username
+}
@@ -0,0 +1,137 @@
+package auth
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// LockoutRepo represents the repository for failed login counters
+type LockoutRepo struct {
+	DB *db.DB
+}
+
+// NewLockoutRepo creates a new instance of LockoutRepo
+func NewLockoutRepo() *LockoutRepo {
+	db := db.NewDB()
+	return &LockoutRepo{DB: db}
+}
+
+// GetLoginFailure retrieves the counter for a key; nil when there were no failures
+func (lr *LockoutRepo) GetLoginFailure(key string) (*LoginFailure, error) {
+	row, err := lr.DB.QueryRow(`
+		SELECT attempt_key, failures, last_failed_at, locked_until
+		FROM login_failures
+		WHERE attempt_key = ?`,
+		key,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying login failures: %w", err)
+	}
+
+	failure, err := scanLoginFailure(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	return failure, err
+}
+
+// IncrementLoginFailure counts a failed login for a key. Failures before windowStart
+// are forgotten and the count starts again at one. It returns the updated counter.
+func (lr *LockoutRepo) IncrementLoginFailure(key string, at, windowStart time.Time) (*LoginFailure, error) {
+	tx, err := lr.DB.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO login_failures (attempt_key, failures, last_failed_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (attempt_key) DO UPDATE SET
+			failures = CASE WHEN login_failures.last_failed_at < ? THEN 1 ELSE login_failures.failures + 1 END,
+			last_failed_at = excluded.last_failed_at`,
+		key, at.UTC(), windowStart.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error recording login failure: %w", err)
+	}
+
+	failure, err := scanLoginFailure(tx.QueryRow(`
+		SELECT attempt_key, failures, last_failed_at, locked_until
+		FROM login_failures
+		WHERE attempt_key = ?`,
+		key,
+	))
+	if err != nil {
+		return nil, err
+	}
+	return failure, tx.Commit()
+}
+
+// LockLogin blocks logins for a key until the given time
+func (lr *LockoutRepo) LockLogin(key string, until time.Time) error {
+	if _, err := lr.DB.Update("UPDATE login_failures SET locked_until = ? WHERE attempt_key = ?", until.UTC(), key); err != nil {
+		return fmt.Errorf("error locking login: %w", err)
+	}
+	return nil
+}
+
+// ClearLoginFailures forgets the failures and lock of a key; false when there were none
+func (lr *LockoutRepo) ClearLoginFailures(key string) (bool, error) {
+	result, err := lr.DB.Delete("DELETE FROM login_failures WHERE attempt_key = ?", key)
+	if err != nil {
+		return false, fmt.Errorf("error clearing login failures: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error clearing login failures: %w", err)
+	}
+	return affected > 0, nil
+}
+
+// GetActiveLockouts lists the keys that are locked at the given time
+func (lr *LockoutRepo) GetActiveLockouts(at time.Time) ([]*LoginFailure, error) {
+	rows, err := lr.DB.Query(`
+		SELECT attempt_key, failures, last_failed_at, locked_until
+		FROM login_failures
+		WHERE locked_until > ?
+		ORDER BY locked_until DESC`,
+		at.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying lockouts: %w", err)
+	}
+	defer rows.Close()
+
+	lockouts := []*LoginFailure{}
+	for rows.Next() {
+		failure, err := scanLoginFailure(rows)
+		if err != nil {
+			return nil, err
+		}
+		lockouts = append(lockouts, failure)
+	}
+	return lockouts, rows.Err()
+}
+
+// scanLoginFailure scans a login_failures row
+func scanLoginFailure(row interface{ Scan(...any) error }) (*LoginFailure, error) {
+	var failure LoginFailure
+	var lockedUntil sql.NullTime
+	err := row.Scan(&failure.Key, &failure.Failures, &failure.LastFailedAt, &lockedUntil)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning login failures: %w", err)
+	}
+	if lockedUntil.Valid {
+		failure.LockedUntil = &lockedUntil.Time
+	}
+	return &failure, nil
+}
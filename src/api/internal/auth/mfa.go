@@ -0,0 +1,427 @@
+package auth
+
+import (
+	"api/config"
+	"api/internal/security"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+)
+
+// Authentication method references (RFC 8176) carried in the amr claim
+const (
+	AMRPassword = "pwd"
+	AMROTP      = "otp"
+	AMRMFA      = "mfa"
+)
+
+const (
+	// purposeMFAChallenge marks the short-lived token that links the two login steps
+	purposeMFAChallenge = "mfa_challenge"
+	// mfaChallengeTTL is how long the user has to enter a code after the password
+	mfaChallengeTTL = 5 * time.Minute
+	// maxMFAAttempts is how many codes one challenge accepts
+	maxMFAAttempts = 5
+	// recoveryCodeCount is how many recovery codes an enrollment gets
+	recoveryCodeCount = 10
+	// recoveryCodeAlphabet avoids characters that are easily confused
+	recoveryCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+)
+
+// MFA errors
+var (
+	ErrMFANotEnrolled      = errors.New("MFA is not enabled")
+	ErrMFAAlreadyEnabled   = errors.New("MFA is already enabled")
+	ErrInvalidMFACode      = errors.New("invalid MFA code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired MFA challenge")
+	ErrTooManyMFAAttempts  = errors.New("too many invalid MFA codes; log in again")
+	ErrMFAKeyMissing       = errors.New("MFA secret encryption key is not configured")
+)
+
+// UserMFA is a user's TOTP enrollment. Secret is encrypted with the vault key.
+type UserMFA struct {
+	UserID       int
+	Secret       string
+	ConfirmedAt  *time.Time
+	LastUsedStep int64
+	CreatedAt    time.Time
+}
+
+// MFAEnrollment is returned when enrollment starts; the secret is shown only once
+type MFAEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// MFAStatus describes a user's MFA setup
+type MFAStatus struct {
+	Enabled           bool       `json:"enabled"`
+	Pending           bool       `json:"pending"`
+	Required          bool       `json:"required"`
+	ConfirmedAt       *time.Time `json:"confirmed_at,omitempty"`
+	RecoveryCodesLeft int        `json:"recovery_codes_left"`
+}
+
+// MFAChallenge is the login response when a second factor is needed
+type MFAChallenge struct {
+	MFARequired    bool     `json:"mfa_required"`
+	ChallengeToken string   `json:"challenge_token"`
+	ExpiredAt      int64    `json:"expiredAt"`
+	Methods        []string `json:"methods"`
+}
+
+// MFAVerifyRequest completes a login with a TOTP code or a recovery code
+type MFAVerifyRequest struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code,omitempty"`
+	RecoveryCode   string `json:"recovery_code,omitempty"`
+}
+
+// MFACodeRequest carries a code that proves possession of the second factor
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// MFAService manages TOTP enrollment, recovery codes and the login challenge
+type MFAService struct {
+	repo      *MFARepo
+	keys      *KeySet
+	secretKey string
+	issuer    string
+	now       func() time.Time
+}
+
+var (
+	mfaServiceOnce     sync.Once
+	mfaServiceInstance *MFAService
+)
+
+// NewMFAService creates an MFAService that encrypts secrets with VAULT_KEY
+func NewMFAService() *MFAService {
+	cfg := config.NewConfig()
+	return NewMFAServiceWithRepo(
+		NewMFARepo(),
+		GetKeySet(),
+		cfg.VaultKey,
+		cfg.MFAIssuer,
+	)
+}
+
+// NewMFAServiceWithRepo creates an MFAService with explicit dependencies
+func NewMFAServiceWithRepo(repo *MFARepo, keys *KeySet, secretKey, issuer string) *MFAService {
+	return &MFAService{
+		repo:      repo,
+		keys:      keys,
+		secretKey: secretKey,
+		issuer:    issuer,
+		now:       time.Now,
+	}
+}
+
+// GetMFAService returns the shared MFAService
+func GetMFAService() *MFAService {
+	mfaServiceOnce.Do(func() {
+		mfaServiceInstance = NewMFAService()
+	})
+	return mfaServiceInstance
+}
+
+// Status returns whether the user has MFA and whether a role requires it
+func (s *MFAService) Status(userID int) (*MFAStatus, error) {
+	required, err := s.repo.RoleRequiresMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	status := &MFAStatus{Required: required}
+
+	mfa, err := s.repo.GetMFA(userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return status, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	status.Enabled = mfa.ConfirmedAt != nil
+	status.Pending = mfa.ConfirmedAt == nil
+	status.ConfirmedAt = mfa.ConfirmedAt
+	if status.Enabled {
+		if status.RecoveryCodesLeft, err = s.repo.CountRecoveryCodes(userID); err != nil {
+			return nil, err
+		}
+	}
+	return status, nil
+}
+
+// Enabled reports whether the user has a confirmed enrollment
+func (s *MFAService) Enabled(userID int) (bool, error) {
+	mfa, err := s.repo.GetMFA(userID)
+	if errors.Is(err, ErrMFANotEnrolled) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return mfa.ConfirmedAt != nil, nil
+}
+
+// RequiresMFA reports whether one of the user's roles requires a second factor
+func (s *MFAService) RequiresMFA(userID int) (bool, error) {
+	return s.repo.RoleRequiresMFA(userID)
+}
+
+// Enroll starts enrollment with a new secret. Until Confirm succeeds it can be restarted.
+func (s *MFAService) Enroll(userID int, username string) (*MFAEnrollment, error) {
+	if s.secretKey == "" {
+		return nil, ErrMFAKeyMissing
+	}
+
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	encrypted, err := security.Encrypt(secret, s.secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting TOTP secret: %w", err)
+	}
+
+	saved, err := s.repo.SavePendingMFA(userID, encrypted, s.now())
+	if err != nil {
+		return nil, err
+	}
+	if !saved {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	return &MFAEnrollment{Secret: secret, URI: OTPAuthURI(s.issuer, username, secret)}, nil
+}
+
+// Confirm activates a pending enrollment with a code from the app and returns new recovery codes
+func (s *MFAService) Confirm(userID int, code string) ([]string, error) {
+	mfa, err := s.repo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.ConfirmedAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	step, err := s.checkTOTP(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ConfirmMFA(userID, step, hashes, s.now()); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Verify checks a TOTP code or a recovery code of an enabled user and returns the
+// authentication methods it proves. Each code and recovery code works only once.
+func (s *MFAService) Verify(userID int, code, recoveryCode string) ([]string, error) {
+	mfa, err := s.repo.GetMFA(userID)
+	if err != nil {
+		return nil, err
+	}
+	if mfa.ConfirmedAt == nil {
+		return nil, ErrMFANotEnrolled
+	}
+
+	if recoveryCode != "" {
+		used, err := s.repo.UseRecoveryCode(userID, hashRecoveryCode(recoveryCode), s.now())
+		if err != nil {
+			return nil, err
+		}
+		if !used {
+			return nil, ErrInvalidMFACode
+		}
+		return []string{AMRMFA}, nil
+	}
+
+	step, err := s.checkTOTP(mfa, code)
+	if err != nil {
+		return nil, err
+	}
+	used, err := s.repo.UseTOTPStep(userID, step)
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidMFACode
+	}
+	return []string{AMROTP, AMRMFA}, nil
+}
+
+// RegenerateRecoveryCodes replaces the recovery codes after checking a current code
+func (s *MFAService) RegenerateRecoveryCodes(userID int, code string) ([]string, error) {
+	if _, err := s.Verify(userID, code, ""); err != nil {
+		return nil, err
+	}
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.repo.ReplaceRecoveryCodes(userID, hashes, s.now()); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// Disable removes MFA after checking a current code or a recovery code
+func (s *MFAService) Disable(userID int, code, recoveryCode string) error {
+	if _, err := s.Verify(userID, code, recoveryCode); err != nil {
+		return err
+	}
+	return s.repo.DeleteMFA(userID)
+}
+
+// NewChallenge issues the token that proves the password step of a login
+func (s *MFAService) NewChallenge(userID int, username string) (*MFAChallenge, error) {
+	now := s.now()
+	expiresAt := now.Add(mfaChallengeTTL)
+	token, err := s.keys.Sign(&JwtClaims{
+		UserID:   userID,
+		Username: username,
+		AMR:      []string{AMRPassword},
+		Purpose:  purposeMFAChallenge,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: expiresAt.Unix(),
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &MFAChallenge{
+		MFARequired:    true,
+		ChallengeToken: token,
+		ExpiredAt:      expiresAt.Unix(),
+		Methods:        []string{"totp", "recovery_code"},
+	}, nil
+}
+
+// Challenge verifies a challenge token and returns the user it was issued to
+func (s *MFAService) Challenge(challenge string) (*JwtClaims, error) {
+	claims := &JwtClaims{}
+	if _, err := s.keys.Verify(challenge, claims); err != nil || claims.Purpose != purposeMFAChallenge || claims.Id == "" {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return claims, nil
+}
+
+// CompleteChallenge verifies a challenge and the code entered for it and returns the
+// claims to issue tokens for, with the amr of both steps. A challenge allows a few wrong
+// codes and a single success. Once the challenge itself is valid the claims are returned
+// with any error, so that the failure can be attributed to the user.
+func (s *MFAService) CompleteChallenge(challenge, code, recoveryCode string) (*JwtClaims, error) {
+	claims, err := s.Challenge(challenge)
+	if err != nil {
+		return nil, err
+	}
+
+	// The attempt is counted before the code is checked, so concurrent
+	// requests cannot try more codes than the challenge allows
+	attempts, err := s.repo.CountChallengeAttempt(claims.Id, time.Unix(claims.ExpiresAt, 0), s.now())
+	if err != nil {
+		return claims, err
+	}
+	if attempts > maxMFAAttempts {
+		return claims, ErrTooManyMFAAttempts
+	}
+
+	amr, err := s.Verify(claims.UserID, code, recoveryCode)
+	if err != nil {
+		return claims, err
+	}
+
+	// Spend the challenge so the same password step cannot log in twice
+	spent, err := s.repo.SpendChallenge(claims.Id, maxMFAAttempts)
+	if err != nil {
+		return claims, err
+	}
+	if !spent {
+		return claims, ErrTooManyMFAAttempts
+	}
+
+	claims.AMR = append(claims.AMR, amr...)
+	return claims, nil
+}
+
+// checkTOTP decrypts the user's secret and validates a code against it
+func (s *MFAService) checkTOTP(mfa *UserMFA, code string) (int64, error) {
+	if s.secretKey == "" {
+		return 0, ErrMFAKeyMissing
+	}
+	secret, err := security.Decrypt(mfa.Secret, s.secretKey)
+	if err != nil {
+		return 0, fmt.Errorf("error decrypting TOTP secret: %w", err)
+	}
+	step, ok := ValidateTOTP(secret, code, s.now(), mfa.LastUsedStep)
+	if !ok {
+		return 0, ErrInvalidMFACode
+	}
+	return step, nil
+}
+
+// SatisfiesMFA reports whether a token meets the MFA requirement of the user's roles
+func (s *MFAService) SatisfiesMFA(claims *JwtClaims) (bool, error) {
+	if claims.HasAMR(AMRMFA) {
+		return true, nil
+	}
+	required, err := s.repo.RoleRequiresMFA(claims.UserID)
+	if err != nil {
+		return false, err
+	}
+	return !required, nil
+}
+
+// HasAMR reports whether the token was obtained with the given authentication method
+func (c *JwtClaims) HasAMR(method string) bool {
+	for _, amr := range c.AMR {
+		if amr == method {
+			return true
+		}
+	}
+	return false
+}
+
+// newRecoveryCodes returns recovery codes such as ABCDE-FGHJK and their hashes
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	random := make([]byte, 10)
+	for i := range codes {
+		if _, err := rand.Read(random); err != nil {
+			return nil, nil, fmt.Errorf("error generating recovery code: %w", err)
+		}
+		var code strings.Builder
+		for j, b := range random {
+			if j == 5 {
+				code.WriteByte('-')
+			}
+			code.WriteByte(recoveryCodeAlphabet[int(b)%len(recoveryCodeAlphabet)])
+		}
+		codes[i] = code.String()
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+	return codes, hashes, nil
+}
+
+// hashRecoveryCode hashes a recovery code, ignoring case and dashes
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	return hashRefreshToken(normalized)
+}
@@ -0,0 +1,238 @@
+package auth
+
+import (
+	"api/internal/handler"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+)
+
+// MFAVerifyHandler godoc
+// @Summary Complete an MFA login
+// @Description Exchange the challenge token returned by /login and a TOTP or recovery code for tokens
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFAVerifyRequest true "Challenge token and code"
+// @Success 200 {object} JwtToken
+// @Failure 401 {object} types.ErrorResponse
+// @Failure 429 {object} types.ErrorResponse
+// @Router /mfa/verify [post]
+func MFAVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body MFAVerifyRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	// Wrong codes count towards the login throttle of the user, which is
+	// only reset once the code is verified
+	challenge, err := GetMFAService().Challenge(body.ChallengeToken)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	ip := ClientIP(r)
+	throttle := GetLoginThrottle()
+	if !checkLoginThrottle(w, r, throttle, challenge.Username, ip) {
+		return
+	}
+
+	claims, err := GetMFAService().CompleteChallenge(body.ChallengeToken, body.Code, body.RecoveryCode)
+	if err != nil {
+		if claims != nil {
+			recordMFAFailure(r, throttle, claims.Username, ip, claims.UserID, err)
+		}
+		writeMFAError(w, r, err)
+		return
+	}
+	if err := throttle.RecordSuccess(claims.Username); err != nil {
+		log.Printf("[error] - Reset failed logins of user %s: %v", claims.Username, err)
+	}
+
+	tokens, err := GetTokenService().IssueTokens(claims.UserID, claims.Username, claims.AMR)
+	if err != nil {
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "TOKEN_GENERATION_FAILED", err.Error())
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventMFASuccess, UserID: claims.UserID, Username: claims.Username, Success: true})
+	setTokenCookies(w, tokens)
+	writeAuthResponse(w, r, tokens)
+}
+
+// MFAStatusHandler godoc
+// @Summary MFA status
+// @Description Show whether the current user has MFA enabled and whether a role requires it
+// @Tags auth
+// @Produce json
+// @Success 200 {object} MFAStatus
+// @Failure 401 {object} types.ErrorResponse
+// @Router /mfa [get]
+func MFAStatusHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	status, err := GetMFAService().Status(claims.UserID)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, status)
+}
+
+// MFAEnrollHandler godoc
+// @Summary Start MFA enrollment
+// @Description Generate a TOTP secret and its otpauth URI. Enrollment is active after /mfa/confirm.
+// @Tags auth
+// @Produce json
+// @Success 200 {object} MFAEnrollment
+// @Failure 409 {object} types.ErrorResponse
+// @Router /mfa/enroll [post]
+func MFAEnrollHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	enrollment, err := GetMFAService().Enroll(claims.UserID, claims.Username)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeAuthResponse(w, r, enrollment)
+}
+
+// MFAConfirmHandler godoc
+// @Summary Confirm MFA enrollment
+// @Description Activate MFA with a code from the authenticator app. The recovery codes are shown only once.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP code"
+// @Success 200 {object} map[string][]string
+// @Failure 401 {object} types.ErrorResponse
+// @Router /mfa/confirm [post]
+func MFAConfirmHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var body MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	codes, err := GetMFAService().Confirm(claims.UserID, body.Code)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeAuthResponse(w, r, map[string][]string{"recovery_codes": codes})
+}
+
+// MFARecoveryCodesHandler godoc
+// @Summary Regenerate recovery codes
+// @Description Replace the recovery codes after checking a current TOTP code
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP code"
+// @Success 200 {object} map[string][]string
+// @Failure 401 {object} types.ErrorResponse
+// @Router /mfa/recovery-codes [post]
+func MFARecoveryCodesHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var body MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	codes, err := GetMFAService().RegenerateRecoveryCodes(claims.UserID, body.Code)
+	if err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeAuthResponse(w, r, map[string][]string{"recovery_codes": codes})
+}
+
+// MFADisableHandler godoc
+// @Summary Disable MFA
+// @Description Remove MFA after checking a current TOTP code or a recovery code
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body MFACodeRequest true "TOTP code or recovery code"
+// @Success 200 {object} map[string]string
+// @Failure 401 {object} types.ErrorResponse
+// @Router /mfa [delete]
+func MFADisableHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var body MFACodeRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	if err := GetMFAService().Disable(claims.UserID, body.Code, body.RecoveryCode); err != nil {
+		writeMFAError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, map[string]string{"message": "MFA disabled"})
+}
+
+// requestClaims returns the claims of the request's access token or writes a 401
+func requestClaims(w http.ResponseWriter, r *http.Request) (*JwtClaims, bool) {
+	claims, err := VerifyToken(getTokenFromRequest(r))
+	if err != nil {
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "INVALID_TOKEN", "Invalid or expired token")
+		return nil, false
+	}
+	return claims, true
+}
+
+// writeMFAError maps MFA errors to responses
+func writeMFAError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrInvalidMFAChallenge):
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "INVALID_MFA_CHALLENGE", err.Error())
+	case errors.Is(err, ErrInvalidMFACode):
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "INVALID_MFA_CODE", err.Error())
+	case errors.Is(err, ErrTooManyMFAAttempts):
+		writeAuthError(w, r, http.StatusTooManyRequests, "Too Many Requests", "TOO_MANY_MFA_ATTEMPTS", err.Error())
+	case errors.Is(err, ErrMFANotEnrolled):
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "MFA_NOT_ENROLLED", err.Error())
+	case errors.Is(err, ErrMFAAlreadyEnabled):
+		writeAuthError(w, r, http.StatusConflict, "Conflict", "MFA_ALREADY_ENABLED", err.Error())
+	default:
+		log.Printf("[error] - MFA request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "MFA_FAILED", "MFA request failed")
+	}
+}
+
+// writeAuthResponse writes a success response in the format used by the auth handlers
+func writeAuthResponse(w http.ResponseWriter, r *http.Request, data interface{}) {
+	resp := handler.NewResponse(http.StatusOK, "Success", data, GetRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,241 @@
+package auth
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// MFARepo represents the repository for TOTP enrollments and recovery codes
+type MFARepo struct {
+	DB *db.DB
+}
+
+// NewMFARepo creates a new instance of MFARepo
+func NewMFARepo() *MFARepo {
+	db := db.NewDB()
+	return &MFARepo{DB: db}
+}
+
+// GetMFA retrieves a user's enrollment; ErrMFANotEnrolled when there is none
+func (mr *MFARepo) GetMFA(userID int) (*UserMFA, error) {
+	row, err := mr.DB.QueryRow(`
+		SELECT user_id, secret, confirmed_at, last_used_step, created_at
+		FROM user_mfa
+		WHERE user_id = ?`,
+		userID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying MFA enrollment: %w", err)
+	}
+
+	var mfa UserMFA
+	var confirmedAt sql.NullTime
+	err = row.Scan(&mfa.UserID, &mfa.Secret, &confirmedAt, &mfa.LastUsedStep, &mfa.CreatedAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrMFANotEnrolled
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning MFA enrollment: %w", err)
+	}
+	if confirmedAt.Valid {
+		mfa.ConfirmedAt = &confirmedAt.Time
+	}
+	return &mfa, nil
+}
+
+// SavePendingMFA stores a new unconfirmed secret, replacing an unconfirmed one.
+// It returns false when the user already has a confirmed enrollment.
+func (mr *MFARepo) SavePendingMFA(userID int, secret string, at time.Time) (bool, error) {
+	result, err := mr.DB.Exec(`
+		INSERT INTO user_mfa (user_id, secret, confirmed_at, last_used_step, created_at)
+		VALUES (?, ?, NULL, 0, ?)
+		ON CONFLICT (user_id) DO UPDATE SET
+			secret = excluded.secret,
+			last_used_step = 0,
+			created_at = excluded.created_at
+		WHERE user_mfa.confirmed_at IS NULL`,
+		userID, secret, at.UTC(),
+	)
+	if err != nil {
+		return false, fmt.Errorf("error saving MFA enrollment: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error saving MFA enrollment: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// ConfirmMFA activates an enrollment and replaces the user's recovery codes in one transaction
+func (mr *MFARepo) ConfirmMFA(userID int, step int64, codeHashes []string, at time.Time) error {
+	tx, err := mr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("UPDATE user_mfa SET confirmed_at = ?, last_used_step = ? WHERE user_id = ?",
+		at.UTC(), step, userID); err != nil {
+		return fmt.Errorf("error confirming MFA enrollment: %w", err)
+	}
+	if err := replaceRecoveryCodes(tx, userID, codeHashes, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// ReplaceRecoveryCodes discards a user's recovery codes and stores new ones
+func (mr *MFARepo) ReplaceRecoveryCodes(userID int, codeHashes []string, at time.Time) error {
+	tx, err := mr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := replaceRecoveryCodes(tx, userID, codeHashes, at); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// UseTOTPStep records the step of an accepted code. It returns false when the same or a
+// later step was already used, so a code cannot be replayed.
+func (mr *MFARepo) UseTOTPStep(userID int, step int64) (bool, error) {
+	result, err := mr.DB.Update("UPDATE user_mfa SET last_used_step = ? WHERE user_id = ? AND last_used_step < ?",
+		step, userID, step)
+	if err != nil {
+		return false, fmt.Errorf("error recording TOTP step: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error recording TOTP step: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// UseRecoveryCode marks an unused recovery code as used; false when no such code is left
+func (mr *MFARepo) UseRecoveryCode(userID int, codeHash string, at time.Time) (bool, error) {
+	result, err := mr.DB.Update(`
+		UPDATE mfa_recovery_codes SET used_at = ?
+		WHERE user_id = ? AND code_hash = ? AND used_at IS NULL`,
+		at.UTC(), userID, codeHash,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error using recovery code: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// CountRecoveryCodes returns how many unused recovery codes a user has
+func (mr *MFARepo) CountRecoveryCodes(userID int) (int, error) {
+	row, err := mr.DB.QueryRow("SELECT COUNT(*) FROM mfa_recovery_codes WHERE user_id = ? AND used_at IS NULL", userID)
+	if err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting recovery codes: %w", err)
+	}
+	return count, nil
+}
+
+// DeleteMFA removes a user's enrollment and recovery codes
+func (mr *MFARepo) DeleteMFA(userID int) error {
+	tx, err := mr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, query := range []string{
+		"DELETE FROM mfa_recovery_codes WHERE user_id = ?",
+		"DELETE FROM user_mfa WHERE user_id = ?",
+	} {
+		if _, err := tx.Exec(query, userID); err != nil {
+			return fmt.Errorf("error deleting MFA enrollment: %w", err)
+		}
+	}
+	return tx.Commit()
+}
+
+// CountChallengeAttempt counts an attempt at completing a login challenge and returns
+// the number of attempts so far. Counters of expired challenges are removed on the way.
+func (mr *MFARepo) CountChallengeAttempt(challengeID string, expiresAt, now time.Time) (int, error) {
+	if _, err := mr.DB.Delete("DELETE FROM mfa_challenge_attempts WHERE expires_at < ?", now.UTC()); err != nil {
+		return 0, fmt.Errorf("error removing expired MFA challenges: %w", err)
+	}
+
+	row, err := mr.DB.QueryRow(`
+		INSERT INTO mfa_challenge_attempts (challenge_id, attempts, expires_at)
+		VALUES (?, 1, ?)
+		ON CONFLICT (challenge_id) DO UPDATE SET attempts = mfa_challenge_attempts.attempts + 1
+		RETURNING attempts`,
+		challengeID, expiresAt.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error counting MFA attempt: %w", err)
+	}
+	var attempts int
+	if err := row.Scan(&attempts); err != nil {
+		return 0, fmt.Errorf("error counting MFA attempt: %w", err)
+	}
+	return attempts, nil
+}
+
+// SpendChallenge uses up a challenge after a successful attempt; false when
+// it was already spent or out of attempts
+func (mr *MFARepo) SpendChallenge(challengeID string, maxAttempts int) (bool, error) {
+	result, err := mr.DB.Update(
+		"UPDATE mfa_challenge_attempts SET attempts = ? WHERE challenge_id = ? AND attempts <= ?",
+		maxAttempts+1, challengeID, maxAttempts,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error spending MFA challenge: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error spending MFA challenge: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// RoleRequiresMFA reports whether any active role of the user requires MFA
+func (mr *MFARepo) RoleRequiresMFA(userID int) (bool, error) {
+	row, err := mr.DB.QueryRow(`
+		SELECT COUNT(*) FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.user_id = ? AND r.require_mfa = 1`,
+		userID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error querying MFA requirement: %w", err)
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error querying MFA requirement: %w", err)
+	}
+	return count > 0, nil
+}
+
+// replaceRecoveryCodes swaps a user's recovery codes inside tx
+func replaceRecoveryCodes(tx *sql.Tx, userID int, codeHashes []string, at time.Time) error {
+	if _, err := tx.Exec("DELETE FROM mfa_recovery_codes WHERE user_id = ?", userID); err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.Exec("INSERT INTO mfa_recovery_codes (user_id, code_hash, created_at) VALUES (?, ?, ?)",
+			userID, hash, at.UTC()); err != nil {
+			return fmt.Errorf("error inserting recovery code: %w", err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,774 @@
+package auth
+
+import (
+	"api/config"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+// OAuth grant types
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantClientCredentials = "client_credentials"
+	GrantRefreshToken      = "refresh_token"
+)
+
+// OIDC scopes; they select the claims of ID tokens and of the userinfo endpoint
+const (
+	ScopeOpenID  = "openid"
+	ScopeProfile = "profile"
+	ScopeEmail   = "email"
+	ScopeRoles   = "roles"
+)
+
+// OAuth endpoint paths, served at the root of the server
+const (
+	OAuthAuthorizePath = "/oauth/authorize"
+	OAuthLoginPath     = "/oauth/login"
+	OAuthTokenPath     = "/oauth/token"
+	OAuthUserInfoPath  = "/oauth/userinfo"
+)
+
+const (
+	// oauthCodeTTL is how long an authorization code can be exchanged
+	oauthCodeTTL = 5 * time.Minute
+	// oauthClientIDBytes and oauthSecretBytes size client ids and secrets
+	oauthClientIDBytes = 12
+	oauthSecretBytes   = 32
+	// oauthCodeBytes is the entropy of an authorization code
+	oauthCodeBytes = 32
+	// pkceMethodS256 is the only PKCE method accepted; "plain" offers no protection
+	pkceMethodS256 = "S256"
+	// idTokenPurpose keeps ID tokens from being accepted as access tokens
+	idTokenPurpose = "id_token"
+)
+
+// supportedScopes are the scopes clients may be registered with
+var supportedScopes = []string{ScopeOpenID, ScopeProfile, ScopeEmail, ScopeRoles}
+
+// OAuth client errors
+var (
+	ErrOAuthClientNotFound = errors.New("OAuth client not found")
+	ErrInvalidOAuthClient  = errors.New("name and grant_types are required; authorization_code clients need redirect URIs and public clients cannot use client_credentials")
+	ErrInvalidRedirectURI  = errors.New("redirect URIs must be absolute http(s) URLs without a fragment")
+	ErrScopeNotGranted     = errors.New("scope exceeds the granted scope")
+)
+
+// OAuthError is an error returned to OAuth clients in the format of RFC 6749 section 5.2
+type OAuthError struct {
+	Code        string `json:"error"`
+	Description string `json:"error_description,omitempty"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Description == "" {
+		return e.Code
+	}
+	return e.Code + ": " + e.Description
+}
+
+// oauthError creates an OAuthError
+func oauthError(code, description string) *OAuthError {
+	return &OAuthError{Code: code, Description: description}
+}
+
+// OAuthClient is an application registered to use the authorization server. Only the
+// hash of a confidential client's secret is kept.
+type OAuthClient struct {
+	ClientID     string   `json:"client_id"`
+	Name         string   `json:"name"`
+	SecretHash   string   `json:"-"`
+	RedirectURIs []string `json:"redirect_uris"`
+	GrantTypes   []string `json:"grant_types"`
+	Scopes       []string `json:"scopes"`
+	// Public clients, such as SPAs, cannot keep a secret and must use PKCE
+	Public bool `json:"public"`
+	// SkipConsent marks first-party clients that users are not asked to approve
+	SkipConsent bool `json:"skip_consent"`
+	// UserID owns the client; client_credentials tokens act as this user
+	UserID    int        `json:"user_id"`
+	TenantID  int        `json:"tenant_id"`
+	CreatedAt time.Time  `json:"created_at"`
+	CreatedBy string     `json:"created_by"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
+
+// AllowsGrant reports whether the client is registered for a grant type
+func (c *OAuthClient) AllowsGrant(grantType string) bool {
+	return containsString(c.GrantTypes, grantType)
+}
+
+// AllowsRedirectURI reports whether uri is one of the client's redirect URIs
+func (c *OAuthClient) AllowsRedirectURI(uri string) bool {
+	return containsString(c.RedirectURIs, uri)
+}
+
+// OAuthClientRequest registers a client
+type OAuthClientRequest struct {
+	Name         string   `json:"name" example:"Partner portal"`
+	RedirectURIs []string `json:"redirect_uris" example:"https://partner.example/callback"`
+	GrantTypes   []string `json:"grant_types" example:"authorization_code,refresh_token"`
+	Scopes       []string `json:"scopes" example:"openid,profile,email"`
+	Public       bool     `json:"public"`
+	SkipConsent  bool     `json:"skip_consent"`
+}
+
+// RegisteredOAuthClient is returned once, when a client is registered
+type RegisteredOAuthClient struct {
+	*OAuthClient
+	ClientSecret string `json:"client_secret,omitempty"`
+}
+
+// OAuthCode is a stored authorization code. Only its hash is kept, and it can be
+// exchanged once.
+type OAuthCode struct {
+	CodeHash            string
+	ClientID            string
+	UserID              int
+	RedirectURI         string
+	Scope               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Nonce               string
+	AMR                 []string
+	AuthTime            time.Time
+	ExpiresAt           time.Time
+	UsedAt              *time.Time
+}
+
+// AuthorizationRequest is an authorization request of the authorization code flow
+type AuthorizationRequest struct {
+	ResponseType        string
+	ClientID            string
+	RedirectURI         string
+	Scope               string
+	State               string
+	Nonce               string
+	CodeChallenge       string
+	CodeChallengeMethod string
+	Prompt              string
+}
+
+// ParseAuthorizationRequest reads an authorization request from query or form values
+func ParseAuthorizationRequest(values url.Values) AuthorizationRequest {
+	return AuthorizationRequest{
+		ResponseType:        values.Get("response_type"),
+		ClientID:            values.Get("client_id"),
+		RedirectURI:         values.Get("redirect_uri"),
+		Scope:               normalizeScope(values.Get("scope")),
+		State:               values.Get("state"),
+		Nonce:               values.Get("nonce"),
+		CodeChallenge:       values.Get("code_challenge"),
+		CodeChallengeMethod: values.Get("code_challenge_method"),
+		Prompt:              values.Get("prompt"),
+	}
+}
+
+// Values encodes the request, so that it can be carried through the login form
+func (a AuthorizationRequest) Values() url.Values {
+	values := url.Values{}
+	set := func(key, value string) {
+		if value != "" {
+			values.Set(key, value)
+		}
+	}
+	set("response_type", a.ResponseType)
+	set("client_id", a.ClientID)
+	set("redirect_uri", a.RedirectURI)
+	set("scope", a.Scope)
+	set("state", a.State)
+	set("nonce", a.Nonce)
+	set("code_challenge", a.CodeChallenge)
+	set("code_challenge_method", a.CodeChallengeMethod)
+	return values
+}
+
+// OAuthTokenResponse is a successful response of the token endpoint
+type OAuthTokenResponse struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	IDToken      string `json:"id_token,omitempty"`
+	Scope        string `json:"scope,omitempty"`
+}
+
+// IDTokenClaims are the claims of an OpenID Connect ID token. The audience is the
+// client the token was issued to.
+type IDTokenClaims struct {
+	AuthTime          int64    `json:"auth_time,omitempty"`
+	Nonce             string   `json:"nonce,omitempty"`
+	AMR               []string `json:"amr,omitempty"`
+	PreferredUsername string   `json:"preferred_username,omitempty"`
+	Email             string   `json:"email,omitempty"`
+	EmailVerified     *bool    `json:"email_verified,omitempty"`
+	TenantID          int      `json:"tenant_id,omitempty"`
+	Purpose           string   `json:"purpose"`
+	jwt.StandardClaims
+}
+
+// OIDCDiscovery is the OpenID Provider metadata served at /.well-known/openid-configuration
+type OIDCDiscovery struct {
+	Issuer                            string   `json:"issuer"`
+	AuthorizationEndpoint             string   `json:"authorization_endpoint"`
+	TokenEndpoint                     string   `json:"token_endpoint"`
+	UserInfoEndpoint                  string   `json:"userinfo_endpoint"`
+	JWKSURI                           string   `json:"jwks_uri"`
+	ResponseTypesSupported            []string `json:"response_types_supported"`
+	GrantTypesSupported               []string `json:"grant_types_supported"`
+	SubjectTypesSupported             []string `json:"subject_types_supported"`
+	IDTokenSigningAlgValuesSupported  []string `json:"id_token_signing_alg_values_supported"`
+	ScopesSupported                   []string `json:"scopes_supported"`
+	TokenEndpointAuthMethodsSupported []string `json:"token_endpoint_auth_methods_supported"`
+	CodeChallengeMethodsSupported     []string `json:"code_challenge_methods_supported"`
+	ClaimsSupported                   []string `json:"claims_supported"`
+}
+
+// OAuthService is the OAuth 2.0 authorization server. Users log in with their
+// existing accounts, and the access tokens it issues are the same JWTs as those of
+// /login, carrying the user's roles; scopes select the OpenID Connect claims.
+type OAuthService struct {
+	repo   *OAuthRepo
+	users  *UserRepo
+	roles  *RoleRepo
+	tokens *TokenService
+	keys   *KeySet
+	issuer string
+	now    func() time.Time
+}
+
+var (
+	oauthServiceOnce     sync.Once
+	oauthServiceInstance *OAuthService
+)
+
+// NewOAuthService creates an OAuthService from the configured issuer. Without
+// OAUTH_ISSUER the issuer is the local server address.
+func NewOAuthService() *OAuthService {
+	cfg := config.NewConfig()
+	issuer := cfg.OAuthIssuer
+	if issuer == "" {
+		issuer = fmt.Sprintf("http://localhost:%d", cfg.GraphQLPort)
+	}
+	return NewOAuthServiceWithRepo(NewOAuthRepo(), NewUserRepo(), NewRoleRepo(), GetTokenService(), GetKeySet(), issuer)
+}
+
+// NewOAuthServiceWithRepo creates an OAuthService with explicit dependencies
+func NewOAuthServiceWithRepo(repo *OAuthRepo, users *UserRepo, roles *RoleRepo, tokens *TokenService, keys *KeySet, issuer string) *OAuthService {
+	return &OAuthService{
+		repo:   repo,
+		users:  users,
+		roles:  roles,
+		tokens: tokens,
+		keys:   keys,
+		issuer: strings.TrimSuffix(issuer, "/"),
+		now:    time.Now,
+	}
+}
+
+// GetOAuthService returns the shared OAuthService
+func GetOAuthService() *OAuthService {
+	oauthServiceOnce.Do(func() {
+		oauthServiceInstance = NewOAuthService()
+	})
+	return oauthServiceInstance
+}
+
+// Issuer returns the issuer identifier put in ID tokens
+func (s *OAuthService) Issuer() string {
+	return s.issuer
+}
+
+// RegisterClient registers a client owned by ownerID. Confidential clients get a
+// secret, which is returned once and not stored.
+func (s *OAuthService) RegisterClient(req OAuthClientRequest, ownerID, tenantID int, createdBy string) (*RegisteredOAuthClient, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" || len(req.GrantTypes) == 0 {
+		return nil, ErrInvalidOAuthClient
+	}
+	for _, grantType := range req.GrantTypes {
+		switch grantType {
+		case GrantAuthorizationCode, GrantRefreshToken:
+		case GrantClientCredentials:
+			if req.Public {
+				return nil, ErrInvalidOAuthClient
+			}
+		default:
+			return nil, ErrInvalidOAuthClient
+		}
+	}
+	if containsString(req.GrantTypes, GrantAuthorizationCode) && len(req.RedirectURIs) == 0 {
+		return nil, ErrInvalidOAuthClient
+	}
+	for _, uri := range req.RedirectURIs {
+		if !validRedirectURI(uri) {
+			return nil, ErrInvalidRedirectURI
+		}
+	}
+	for _, scope := range req.Scopes {
+		if !containsString(supportedScopes, scope) {
+			return nil, oauthError("invalid_scope", "supported scopes are "+strings.Join(supportedScopes, ", "))
+		}
+	}
+
+	clientID, err := randomToken(oauthClientIDBytes, false)
+	if err != nil {
+		return nil, err
+	}
+	client := &OAuthClient{
+		ClientID:     "oc_" + clientID,
+		Name:         req.Name,
+		RedirectURIs: req.RedirectURIs,
+		GrantTypes:   req.GrantTypes,
+		Scopes:       req.Scopes,
+		Public:       req.Public,
+		SkipConsent:  req.SkipConsent,
+		UserID:       ownerID,
+		TenantID:     tenantID,
+		CreatedAt:    s.now(),
+		CreatedBy:    createdBy,
+	}
+	if client.RedirectURIs == nil {
+		client.RedirectURIs = []string{}
+	}
+	if client.Scopes == nil {
+		client.Scopes = []string{}
+	}
+
+	registered := &RegisteredOAuthClient{OAuthClient: client}
+	if !client.Public {
+		secret, err := randomToken(oauthSecretBytes, true)
+		if err != nil {
+			return nil, err
+		}
+		client.SecretHash = hashOAuthSecret(secret)
+		registered.ClientSecret = secret
+	}
+	if err := s.repo.InsertClient(client); err != nil {
+		return nil, err
+	}
+	return registered, nil
+}
+
+// Clients lists the clients owned by a user; ownerID 0 lists all clients
+func (s *OAuthService) Clients(ownerID int) ([]*OAuthClient, error) {
+	return s.repo.GetClients(ownerID)
+}
+
+// Client returns a client that has not been deleted
+func (s *OAuthService) Client(clientID string) (*OAuthClient, error) {
+	client, err := s.repo.GetClient(clientID)
+	if err != nil {
+		return nil, err
+	}
+	if client.RevokedAt != nil {
+		return nil, ErrOAuthClientNotFound
+	}
+	return client, nil
+}
+
+// DeleteClient revokes a client; its refresh tokens can no longer be exchanged
+func (s *OAuthService) DeleteClient(clientID string) error {
+	return s.repo.RevokeClient(clientID, s.now())
+}
+
+// AuthenticateClient authenticates a client at the token endpoint. Confidential
+// clients must present their secret; public clients must not have one.
+func (s *OAuthService) AuthenticateClient(clientID, secret string) (*OAuthClient, error) {
+	client, err := s.Client(clientID)
+	if errors.Is(err, ErrOAuthClientNotFound) {
+		return nil, oauthError("invalid_client", "unknown client")
+	}
+	if err != nil {
+		return nil, err
+	}
+	if client.Public {
+		if secret != "" {
+			return nil, oauthError("invalid_client", "public clients do not have a secret")
+		}
+		return client, nil
+	}
+	if secret == "" || subtle.ConstantTimeCompare([]byte(hashOAuthSecret(secret)), []byte(client.SecretHash)) != 1 {
+		return nil, oauthError("invalid_client", "invalid client credentials")
+	}
+	return client, nil
+}
+
+// AuthorizationClient returns the client of an authorization request after checking
+// its redirect URI. Errors here must be shown to the user rather than redirected.
+func (s *OAuthService) AuthorizationClient(req AuthorizationRequest) (*OAuthClient, error) {
+	client, err := s.Client(req.ClientID)
+	if err != nil {
+		return nil, err
+	}
+	if !client.AllowsRedirectURI(req.RedirectURI) {
+		return nil, ErrInvalidRedirectURI
+	}
+	return client, nil
+}
+
+// ValidateAuthorization checks an authorization request of client. The errors are
+// OAuthErrors to send back to the client's redirect URI.
+func (s *OAuthService) ValidateAuthorization(client *OAuthClient, req AuthorizationRequest) error {
+	if req.ResponseType != "code" {
+		return oauthError("unsupported_response_type", "only the code response type is supported")
+	}
+	if !client.AllowsGrant(GrantAuthorizationCode) {
+		return oauthError("unauthorized_client", "the client may not use the authorization code grant")
+	}
+	if !scopeCovers(strings.Join(client.Scopes, " "), req.Scope) {
+		return oauthError("invalid_scope", "the client is not registered for this scope")
+	}
+	if req.CodeChallenge == "" {
+		if client.Public {
+			return oauthError("invalid_request", "public clients must use PKCE")
+		}
+	} else if req.CodeChallengeMethod != pkceMethodS256 {
+		return oauthError("invalid_request", "code_challenge_method must be S256")
+	}
+	return nil
+}
+
+// NeedsConsent reports whether the user has to approve the client for scope
+func (s *OAuthService) NeedsConsent(userID int, client *OAuthClient, scope string) (bool, error) {
+	if client.SkipConsent {
+		return false, nil
+	}
+	granted, ok, err := s.repo.GetConsent(userID, client.ClientID)
+	if err != nil {
+		return false, err
+	}
+	return !ok || !scopeCovers(granted, scope), nil
+}
+
+// GrantConsent records that the user approved the client for scope, in addition to
+// the scopes approved before
+func (s *OAuthService) GrantConsent(userID int, clientID, scope string) error {
+	granted, _, err := s.repo.GetConsent(userID, clientID)
+	if err != nil {
+		return err
+	}
+	return s.repo.SaveConsent(userID, clientID, normalizeScope(granted+" "+scope), s.now())
+}
+
+// CreateCode issues an authorization code for a user who authorized the request of
+// client; claims are those of the user's login session. Users can only authorize
+// clients of their own tenant.
+func (s *OAuthService) CreateCode(client *OAuthClient, req AuthorizationRequest, claims *JwtClaims) (string, error) {
+	// Staff acting as the user must not hand the user's access to a client
+	if claims.Impersonated() {
+		return "", oauthError("access_denied", "impersonation tokens cannot authorize clients")
+	}
+	if claims.Tenant() != client.TenantID {
+		return "", oauthError("access_denied", "the client belongs to another tenant")
+	}
+	code, err := randomToken(oauthCodeBytes, true)
+	if err != nil {
+		return "", err
+	}
+	now := s.now()
+	stored := &OAuthCode{
+		CodeHash:            hashOAuthSecret(code),
+		ClientID:            client.ClientID,
+		UserID:              claims.UserID,
+		RedirectURI:         req.RedirectURI,
+		Scope:               req.Scope,
+		CodeChallenge:       req.CodeChallenge,
+		CodeChallengeMethod: req.CodeChallengeMethod,
+		Nonce:               req.Nonce,
+		AMR:                 claims.AMR,
+		AuthTime:            time.Unix(claims.IssuedAt, 0),
+		ExpiresAt:           now.Add(oauthCodeTTL),
+	}
+	if err := s.repo.InsertCode(stored); err != nil {
+		return "", err
+	}
+	return code, nil
+}
+
+// ExchangeCode redeems an authorization code issued to client. The code is used up
+// before tokens are issued, so that it cannot be redeemed twice.
+func (s *OAuthService) ExchangeCode(client *OAuthClient, code, redirectURI, verifier string) (*OAuthTokenResponse, error) {
+	if code == "" {
+		return nil, oauthError("invalid_request", "code is required")
+	}
+	stored, err := s.repo.GetCode(hashOAuthSecret(code))
+	if err != nil {
+		return nil, err
+	}
+	now := s.now()
+	if stored == nil || stored.ClientID != client.ClientID || stored.UsedAt != nil || !now.Before(stored.ExpiresAt) {
+		return nil, oauthError("invalid_grant", "invalid, expired or used authorization code")
+	}
+	if stored.RedirectURI != redirectURI {
+		return nil, oauthError("invalid_grant", "redirect_uri does not match the authorization request")
+	}
+	if stored.CodeChallenge != "" && !verifyPKCE(stored.CodeChallenge, verifier) {
+		return nil, oauthError("invalid_grant", "invalid code_verifier")
+	}
+	claimed, err := s.repo.UseCode(stored.CodeHash, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		return nil, oauthError("invalid_grant", "invalid, expired or used authorization code")
+	}
+
+	user, err := s.users.GetUserByID(stored.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+	var tokens *JwtToken
+	if client.AllowsGrant(GrantRefreshToken) {
+		tokens, err = s.tokens.IssueClientTokens(user.UserID, user.Username, stored.AMR, client.ClientID, stored.Scope)
+	} else {
+		tokens, err = s.tokens.IssueClientAccessToken(user.UserID, user.Username, stored.AMR, client.ClientID, stored.Scope)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	resp := s.tokenResponse(tokens, stored.Scope)
+	if hasScope(stored.Scope, ScopeOpenID) {
+		idToken, err := s.idToken(user, client.ClientID, stored)
+		if err != nil {
+			return nil, err
+		}
+		resp.IDToken = idToken
+	}
+	return resp, nil
+}
+
+// ClientCredentials issues an access token to a confidential client acting as the
+// user that owns it
+func (s *OAuthService) ClientCredentials(client *OAuthClient, scope string) (*OAuthTokenResponse, error) {
+	scope = normalizeScope(scope)
+	if !scopeCovers(strings.Join(client.Scopes, " "), scope) {
+		return nil, oauthError("invalid_scope", "the client is not registered for this scope")
+	}
+	owner, err := s.users.GetUserByID(client.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading client owner: %w", err)
+	}
+	tokens, err := s.tokens.IssueClientAccessToken(owner.UserID, owner.Username, nil, client.ClientID, scope)
+	if err != nil {
+		return nil, err
+	}
+	return s.tokenResponse(tokens, scope), nil
+}
+
+// RefreshToken exchanges a refresh token issued to client
+func (s *OAuthService) RefreshToken(client *OAuthClient, refreshToken, scope string) (*OAuthTokenResponse, error) {
+	tokens, granted, err := s.tokens.RefreshClient(refreshToken, client.ClientID, normalizeScope(scope))
+	switch {
+	case errors.Is(err, ErrScopeNotGranted):
+		return nil, oauthError("invalid_scope", err.Error())
+	case errors.Is(err, ErrInvalidRefreshToken), errors.Is(err, ErrRefreshTokenReused):
+		return nil, oauthError("invalid_grant", err.Error())
+	case err != nil:
+		return nil, err
+	}
+	return s.tokenResponse(tokens, granted), nil
+}
+
+// UserInfo returns the claims about the user of an access token that its scope
+// allows; the token must have been issued for the openid scope
+func (s *OAuthService) UserInfo(claims *JwtClaims) (map[string]interface{}, error) {
+	if !hasScope(claims.Scope, ScopeOpenID) {
+		return nil, oauthError("insufficient_scope", "the access token was not issued for the openid scope")
+	}
+	user, err := s.users.GetUserByID(claims.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error loading user: %w", err)
+	}
+
+	info := map[string]interface{}{"sub": strconv.Itoa(user.UserID)}
+	if hasScope(claims.Scope, ScopeProfile) {
+		info["preferred_username"] = user.Username
+		info["tenant_id"] = user.TenantID
+	}
+	if hasScope(claims.Scope, ScopeEmail) {
+		info["email"] = user.Email
+		info["email_verified"] = user.EmailVerifiedAt != nil
+	}
+	if hasScope(claims.Scope, ScopeRoles) {
+		roles, err := s.roles.GetRolesByUserID(user.UserID)
+		if err != nil {
+			return nil, err
+		}
+		names := make([]string, 0, len(roles))
+		for _, role := range roles {
+			names = append(names, role.RoleName)
+		}
+		info["roles"] = names
+	}
+	return info, nil
+}
+
+// Discovery returns the OpenID Provider metadata
+func (s *OAuthService) Discovery() OIDCDiscovery {
+	algorithms := []string{}
+	for _, key := range s.keys.Keys() {
+		if !containsString(algorithms, key.Algorithm) {
+			algorithms = append(algorithms, key.Algorithm)
+		}
+	}
+	return OIDCDiscovery{
+		Issuer:                            s.issuer,
+		AuthorizationEndpoint:             s.issuer + OAuthAuthorizePath,
+		TokenEndpoint:                     s.issuer + OAuthTokenPath,
+		UserInfoEndpoint:                  s.issuer + OAuthUserInfoPath,
+		JWKSURI:                           s.issuer + "/.well-known/jwks.json",
+		ResponseTypesSupported:            []string{"code"},
+		GrantTypesSupported:               []string{GrantAuthorizationCode, GrantClientCredentials, GrantRefreshToken},
+		SubjectTypesSupported:             []string{"public"},
+		IDTokenSigningAlgValuesSupported:  algorithms,
+		ScopesSupported:                   supportedScopes,
+		TokenEndpointAuthMethodsSupported: []string{"client_secret_basic", "client_secret_post", "none"},
+		CodeChallengeMethodsSupported:     []string{pkceMethodS256},
+		ClaimsSupported: []string{"sub", "iss", "aud", "exp", "iat", "auth_time", "nonce", "amr",
+			"preferred_username", "email", "email_verified", "tenant_id", "roles"},
+	}
+}
+
+// SessionToken issues the short-lived token that keeps a user logged in to the
+// authorization pages
+func (s *OAuthService) SessionToken(user *User, amr []string) (*JwtToken, error) {
+	claims, token, err := s.tokens.signAccessToken(tokenGrant{UserID: user.UserID, Username: user.Username, AMR: amr}, s.now())
+	if err != nil {
+		return nil, err
+	}
+	return &JwtToken{Token: token, ExpiredAt: claims.ExpiresAt}, nil
+}
+
+// tokenResponse converts issued tokens to a token endpoint response
+func (s *OAuthService) tokenResponse(tokens *JwtToken, scope string) *OAuthTokenResponse {
+	expiresIn := tokens.ExpiredAt - s.now().Unix()
+	if expiresIn < 0 {
+		expiresIn = 0
+	}
+	return &OAuthTokenResponse{
+		AccessToken:  tokens.Token,
+		TokenType:    "Bearer",
+		ExpiresIn:    expiresIn,
+		RefreshToken: tokens.RefreshToken,
+		Scope:        scope,
+	}
+}
+
+// idToken signs the ID token of an authorization code exchange
+func (s *OAuthService) idToken(user *User, clientID string, code *OAuthCode) (string, error) {
+	now := s.now()
+	claims := &IDTokenClaims{
+		AuthTime: code.AuthTime.Unix(),
+		Nonce:    code.Nonce,
+		AMR:      code.AMR,
+		Purpose:  idTokenPurpose,
+		StandardClaims: jwt.StandardClaims{
+			Issuer:    s.issuer,
+			Subject:   strconv.Itoa(user.UserID),
+			Audience:  clientID,
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(s.tokens.accessTTL).Unix(),
+		},
+	}
+	if hasScope(code.Scope, ScopeProfile) {
+		claims.PreferredUsername = user.Username
+		claims.TenantID = user.TenantID
+	}
+	if hasScope(code.Scope, ScopeEmail) {
+		verified := user.EmailVerifiedAt != nil
+		claims.Email = user.Email
+		claims.EmailVerified = &verified
+	}
+	token, err := s.keys.Sign(claims)
+	if err != nil {
+		return "", fmt.Errorf("error signing ID token: %w", err)
+	}
+	return token, nil
+}
+
+// verifyPKCE checks a code verifier against an S256 code challenge (RFC 7636)
+func verifyPKCE(challenge, verifier string) bool {
+	if len(verifier) < 43 || len(verifier) > 128 {
+		return false
+	}
+	sum := sha256.Sum256([]byte(verifier))
+	expected := base64.RawURLEncoding.EncodeToString(sum[:])
+	return subtle.ConstantTimeCompare([]byte(expected), []byte(challenge)) == 1
+}
+
+// validRedirectURI reports whether uri can be registered as a redirect URI
+func validRedirectURI(uri string) bool {
+	parsed, err := url.Parse(uri)
+	if err != nil || parsed.Host == "" || parsed.Fragment != "" {
+		return false
+	}
+	return parsed.Scheme == "https" || parsed.Scheme == "http"
+}
+
+// normalizeScope removes duplicate and extra spaces from a scope
+func normalizeScope(scope string) string {
+	var scopes []string
+	for _, s := range strings.Fields(scope) {
+		if !containsString(scopes, s) {
+			scopes = append(scopes, s)
+		}
+	}
+	return strings.Join(scopes, " ")
+}
+
+// scopeCovers reports whether every scope of requested is part of granted
+func scopeCovers(granted, requested string) bool {
+	for _, scope := range strings.Fields(requested) {
+		if !hasScope(granted, scope) {
+			return false
+		}
+	}
+	return true
+}
+
+// hasScope reports whether a space-separated scope contains one scope
+func hasScope(scope, want string) bool {
+	return containsString(strings.Fields(scope), want)
+}
+
+// containsString reports whether values contains value
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// randomToken returns n random bytes encoded as hex, or as base64url when urlSafe is set
+func randomToken(n int, urlSafe bool) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating OAuth token: %w", err)
+	}
+	if urlSafe {
+		return base64.RawURLEncoding.EncodeToString(b), nil
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashOAuthSecret hashes client secrets and authorization codes for storage
+func hashOAuthSecret(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,687 @@
+package auth
+
+import (
+	"api/internal/router"
+	"crypto/subtle"
+	"embed"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// oauthSessionCookie keeps a user logged in to the authorization pages
+	oauthSessionCookie = "oauth_session"
+	// oauthCSRFCookie holds the token that forms of the authorization pages must echo
+	oauthCSRFCookie = "oauth_csrf"
+	// oauthCookiePath limits the cookies of the authorization pages to their endpoints
+	oauthCookiePath = "/oauth"
+	// oauthCSRFBytes is the entropy of a CSRF token
+	oauthCSRFBytes = 32
+)
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var oauthTemplates = template.Must(template.ParseFS(templateFiles, "templates/*.tmpl"))
+
+// scopeDescriptions explain scopes on the consent page
+var scopeDescriptions = map[string]string{
+	ScopeOpenID:  "Your user ID",
+	ScopeProfile: "Your username and tenant",
+	ScopeEmail:   "Your email address",
+	ScopeRoles:   "Your roles",
+}
+
+// Login form errors
+var (
+	errOAuthInvalidLogin = errors.New("invalid username or password")
+	errOAuthMFARequired  = errors.New("enter the code from your authenticator app or a recovery code")
+)
+
+// oauthPage is the data of the authorization page templates
+type oauthPage struct {
+	Title       string
+	Error       string
+	ClientName  string
+	Action      string
+	CSRFToken   string
+	Authorize   string
+	Username    string
+	MFARequired bool
+	Scopes      []string
+}
+
+// OAuthAuthorizeHandler godoc
+// @Summary OAuth 2.0 authorization endpoint
+// @Description Start the authorization code flow (response_type=code). Users who are not signed in get a login page, then a consent page unless they approved the client's scopes before; they are then redirected to redirect_uri with a code. Public clients must use PKCE with S256. The consent page posts back to this endpoint.
+// @Tags oauth
+// @Produce html
+// @Param response_type query string true "code"
+// @Param client_id query string true "Client ID"
+// @Param redirect_uri query string true "A redirect URI registered for the client"
+// @Param scope query string false "Space-separated scopes, such as openid profile email"
+// @Param state query string false "Opaque value returned to the client"
+// @Param nonce query string false "Value put in the ID token"
+// @Param code_challenge query string false "PKCE code challenge"
+// @Param code_challenge_method query string false "S256"
+// @Param prompt query string false "none to fail instead of showing a page"
+// @Success 302
+// @Failure 400 {string} string "Invalid client or redirect URI"
+// @Router /oauth/authorize [get]
+func OAuthAuthorizeHandler(w http.ResponseWriter, r *http.Request) {
+	service := GetOAuthService()
+
+	values := r.URL.Query()
+	if r.Method == http.MethodPost {
+		if err := r.ParseForm(); err != nil {
+			renderOAuthError(w, r, http.StatusBadRequest, "Invalid request")
+			return
+		}
+		// The consent form carries the original request
+		values, _ = url.ParseQuery(r.PostForm.Get("authorize"))
+	}
+	req := ParseAuthorizationRequest(values)
+
+	client, err := service.AuthorizationClient(req)
+	if err != nil {
+		renderOAuthClientError(w, r, err)
+		return
+	}
+	if err := service.ValidateAuthorization(client, req); err != nil {
+		redirectOAuthError(w, r, req, err)
+		return
+	}
+
+	page := oauthPage{ClientName: client.Name, Authorize: req.Values().Encode()}
+	claims := oauthSession(r)
+	if claims == nil {
+		if req.Prompt == "none" {
+			redirectOAuthError(w, r, req, oauthError("login_required", "the user is not signed in"))
+			return
+		}
+		page.Title, page.Action = "Sign in", OAuthLoginPath
+		renderOAuthPage(w, r, http.StatusOK, "oauth_login", page)
+		return
+	}
+
+	if r.Method == http.MethodPost {
+		if !validOAuthCSRF(r) {
+			renderOAuthError(w, r, http.StatusForbidden, "The form expired; go back to the application and try again")
+			return
+		}
+		if r.PostForm.Get("decision") != "allow" {
+			redirectOAuthError(w, r, req, oauthError("access_denied", "the user denied the request"))
+			return
+		}
+		if err := service.GrantConsent(claims.UserID, client.ClientID, req.Scope); err != nil {
+			redirectOAuthError(w, r, req, err)
+			return
+		}
+		RecordAuthEvent(r, AuthEvent{EventType: EventOAuthConsent, UserID: claims.UserID, Username: claims.Username, Success: true, Detail: client.ClientID + " " + req.Scope})
+	} else {
+		needsConsent, err := service.NeedsConsent(claims.UserID, client, req.Scope)
+		if err != nil {
+			redirectOAuthError(w, r, req, err)
+			return
+		}
+		if needsConsent {
+			if req.Prompt == "none" {
+				redirectOAuthError(w, r, req, oauthError("consent_required", "the user has not approved the client"))
+				return
+			}
+			page.Title, page.Action, page.Username = "Authorize "+client.Name, OAuthAuthorizePath, claims.Username
+			page.Scopes = []string{"Use the API with your permissions"}
+			for _, scope := range strings.Fields(req.Scope) {
+				page.Scopes = append(page.Scopes, scopeDescriptions[scope])
+			}
+			renderOAuthPage(w, r, http.StatusOK, "oauth_consent", page)
+			return
+		}
+	}
+
+	code, err := service.CreateCode(client, req, claims)
+	if err != nil {
+		redirectOAuthError(w, r, req, err)
+		return
+	}
+	redirectOAuth(w, r, req.RedirectURI, url.Values{"code": {code}, "state": {req.State}})
+}
+
+// OAuthLoginHandler godoc
+// @Summary OAuth 2.0 login form
+// @Description Sign in from the login page of the authorization endpoint. Users with MFA are asked for a code. On success the user is sent back to the authorization endpoint.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce html
+// @Param username formData string true "Username"
+// @Param password formData string true "Password"
+// @Param mfa_code formData string false "TOTP code"
+// @Param recovery_code formData string false "Recovery code"
+// @Param authorize formData string true "The encoded authorization request"
+// @Param csrf_token formData string true "CSRF token of the form"
+// @Success 303
+// @Failure 401 {string} string "Login page with an error"
+// @Router /oauth/login [post]
+func OAuthLoginHandler(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		renderOAuthError(w, r, http.StatusBadRequest, "Invalid request")
+		return
+	}
+	values, _ := url.ParseQuery(r.PostForm.Get("authorize"))
+	req := ParseAuthorizationRequest(values)
+	client, err := GetOAuthService().AuthorizationClient(req)
+	if err != nil {
+		renderOAuthClientError(w, r, err)
+		return
+	}
+
+	username := strings.TrimSpace(r.PostForm.Get("username"))
+	page := oauthPage{
+		Title:      "Sign in",
+		ClientName: client.Name,
+		Action:     OAuthLoginPath,
+		Authorize:  req.Values().Encode(),
+		Username:   username,
+	}
+	if !validOAuthCSRF(r) {
+		page.Error = "The form expired; please sign in again"
+		renderOAuthPage(w, r, http.StatusForbidden, "oauth_login", page)
+		return
+	}
+
+	user, amr, err := oauthAuthenticate(r, username, r.PostForm.Get("password"), r.PostForm.Get("mfa_code"), r.PostForm.Get("recovery_code"))
+	if err != nil {
+		var blocked *LoginBlockedError
+		switch {
+		case errors.As(err, &blocked):
+			page.Error = blocked.Error()
+			renderOAuthPage(w, r, http.StatusTooManyRequests, "oauth_login", page)
+		case errors.Is(err, errOAuthMFARequired), errors.Is(err, ErrInvalidMFACode), errors.Is(err, ErrTooManyMFAAttempts):
+			page.Error, page.MFARequired = err.Error(), true
+			renderOAuthPage(w, r, http.StatusUnauthorized, "oauth_login", page)
+		case errors.Is(err, errOAuthInvalidLogin):
+			page.Error = err.Error()
+			renderOAuthPage(w, r, http.StatusUnauthorized, "oauth_login", page)
+		default:
+			log.Printf("[error] - OAuth login of user %s: %v", username, err)
+			renderOAuthError(w, r, http.StatusInternalServerError, "Sign in failed; please try again later")
+		}
+		return
+	}
+
+	session, err := GetOAuthService().SessionToken(user, amr)
+	if err != nil {
+		log.Printf("[error] - OAuth session of user %s: %v", user.Username, err)
+		renderOAuthError(w, r, http.StatusInternalServerError, "Sign in failed; please try again later")
+		return
+	}
+	RecordAuthEvent(r, AuthEvent{EventType: EventLoginSuccess, UserID: user.UserID, Username: user.Username, Success: true, Detail: "oauth " + client.ClientID})
+	http.SetCookie(w, &http.Cookie{
+		Name:     oauthSessionCookie,
+		Value:    session.Token,
+		Path:     oauthCookiePath,
+		Expires:  time.Unix(session.ExpiredAt, 0),
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, OAuthAuthorizePath+"?"+req.Values().Encode(), http.StatusSeeOther)
+}
+
+// OAuthTokenHandler godoc
+// @Summary OAuth 2.0 token endpoint
+// @Description Exchange an authorization code (with its PKCE code_verifier), client credentials or a refresh token for tokens. Confidential clients authenticate with HTTP Basic or client_id and client_secret form fields; public clients send only client_id. The openid scope adds an ID token to code exchanges.
+// @Tags oauth
+// @Accept x-www-form-urlencoded
+// @Produce json
+// @Param grant_type formData string true "authorization_code, client_credentials or refresh_token"
+// @Param code formData string false "Authorization code"
+// @Param redirect_uri formData string false "Redirect URI of the authorization request"
+// @Param code_verifier formData string false "PKCE code verifier"
+// @Param refresh_token formData string false "Refresh token"
+// @Param scope formData string false "Requested scope"
+// @Param client_id formData string false "Client ID"
+// @Param client_secret formData string false "Client secret"
+// @Success 200 {object} OAuthTokenResponse
+// @Failure 400 {object} OAuthError
+// @Failure 401 {object} OAuthError
+// @Router /oauth/token [post]
+func OAuthTokenHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+	if r.Method == http.MethodOptions {
+		return
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Pragma", "no-cache")
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		writeOAuthError(w, r, oauthError("invalid_request", "the token endpoint only accepts POST"))
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		writeOAuthError(w, r, oauthError("invalid_request", "invalid form body"))
+		return
+	}
+	clientID, secret, basic := r.BasicAuth()
+	if basic {
+		// RFC 6749 form-encodes the credentials before they are base64 encoded
+		clientID, _ = url.QueryUnescape(clientID)
+		secret, _ = url.QueryUnescape(secret)
+	} else {
+		clientID, secret = r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	}
+
+	service := GetOAuthService()
+	client, err := service.AuthenticateClient(clientID, secret)
+	if err != nil {
+		if basic {
+			w.Header().Set("WWW-Authenticate", `Basic realm="oauth"`)
+		}
+		writeOAuthError(w, r, err)
+		return
+	}
+
+	grantType := r.PostForm.Get("grant_type")
+	if !client.AllowsGrant(grantType) {
+		switch grantType {
+		case GrantAuthorizationCode, GrantClientCredentials, GrantRefreshToken:
+			writeOAuthError(w, r, oauthError("unauthorized_client", "the client may not use this grant type"))
+		default:
+			writeOAuthError(w, r, oauthError("unsupported_grant_type", "grant_type must be authorization_code, client_credentials or refresh_token"))
+		}
+		return
+	}
+
+	var resp *OAuthTokenResponse
+	switch grantType {
+	case GrantAuthorizationCode:
+		resp, err = service.ExchangeCode(client, r.PostForm.Get("code"), r.PostForm.Get("redirect_uri"), r.PostForm.Get("code_verifier"))
+	case GrantClientCredentials:
+		resp, err = service.ClientCredentials(client, r.PostForm.Get("scope"))
+	case GrantRefreshToken:
+		resp, err = service.RefreshToken(client, r.PostForm.Get("refresh_token"), r.PostForm.Get("scope"))
+	}
+	if err != nil {
+		writeOAuthError(w, r, err)
+		return
+	}
+
+	event := AuthEvent{EventType: EventOAuthToken, Success: true, Detail: client.ClientID + " " + grantType}
+	if claims, err := GetTokenService().ParseAccessToken(resp.AccessToken); err == nil {
+		event.UserID, event.Username = claims.UserID, claims.Username
+	}
+	RecordAuthEvent(r, event)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// OAuthUserInfoHandler godoc
+// @Summary OpenID Connect userinfo endpoint
+// @Description Claims about the user of an access token issued with the openid scope: sub, plus preferred_username and tenant_id (profile), email and email_verified (email) and roles (roles).
+// @Tags oauth
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} map[string]interface{}
+// @Failure 401 {object} OAuthError
+// @Failure 403 {object} OAuthError
+// @Router /oauth/userinfo [get]
+func OAuthUserInfoHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+	if r.Method == http.MethodOptions {
+		return
+	}
+
+	claims, err := verifyOAuthAccessToken(getTokenFromRequest(r))
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeOAuthError(w, r, oauthError("invalid_token", "invalid, expired or revoked access token"))
+		return
+	}
+	info, err := GetOAuthService().UserInfo(claims)
+	if err != nil {
+		var oauthErr *OAuthError
+		if errors.As(err, &oauthErr) {
+			w.Header().Set("WWW-Authenticate", `Bearer error="`+oauthErr.Code+`"`)
+		}
+		writeOAuthError(w, r, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(info)
+}
+
+// OIDCDiscoveryHandler godoc
+// @Summary OpenID Connect discovery document
+// @Description The OpenID Provider metadata: endpoints, supported grants, scopes and signing algorithms
+// @Tags oauth
+// @Produce json
+// @Success 200 {object} OIDCDiscovery
+// @Router /.well-known/openid-configuration [get]
+func OIDCDiscoveryHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(GetOAuthService().Discovery())
+}
+
+// CreateOAuthClientHandler godoc
+// @Summary Register an OAuth client
+// @Description Register an application owned by the current user. Confidential clients get a client secret, which is only returned once. Only super admins may register first-party clients that skip consent.
+// @Tags oauth
+// @Accept json
+// @Produce json
+// @Param request body OAuthClientRequest true "Client name, redirect URIs, grant types and scopes"
+// @Success 200 {object} RegisteredOAuthClient
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Router /oauth/clients [post]
+func CreateOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	var body OAuthClientRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+	if body.SkipConsent && !isSuperAdminUser(claims.UserID) {
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "FORBIDDEN", "Only super admins can register clients that skip consent")
+		return
+	}
+
+	client, err := GetOAuthService().RegisterClient(body, claims.UserID, requestTenantID(r.Context(), claims), claims.Username)
+	if err != nil {
+		writeOAuthClientError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventOAuthClientCreate, UserID: claims.UserID, Actor: claims.Username, Success: true, Detail: client.ClientID})
+	writeAuthResponse(w, r, client)
+}
+
+// GetOAuthClientsHandler godoc
+// @Summary List OAuth clients
+// @Description List the clients of the current user; super admins see all clients. Secrets are never returned.
+// @Tags oauth
+// @Produce json
+// @Success 200 {array} OAuthClient
+// @Router /oauth/clients [get]
+func GetOAuthClientsHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	ownerID := claims.UserID
+	if isSuperAdminUser(claims.UserID) {
+		ownerID = 0
+	}
+	clients, err := GetOAuthService().Clients(ownerID)
+	if err != nil {
+		writeOAuthClientError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, clients)
+}
+
+// DeleteOAuthClientHandler godoc
+// @Summary Delete an OAuth client
+// @Description Delete one of the current user's clients; super admins may delete any client. Its tokens can no longer be refreshed.
+// @Tags oauth
+// @Produce json
+// @Param id path string true "Client ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Router /oauth/clients/{id} [delete]
+func DeleteOAuthClientHandler(w http.ResponseWriter, r *http.Request) {
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return
+	}
+
+	service := GetOAuthService()
+	client, err := service.Client(router.Param(r, "id"))
+	if err != nil {
+		writeOAuthClientError(w, r, err)
+		return
+	}
+	// Clients of other users are reported as missing rather than forbidden
+	if client.UserID != claims.UserID && !isSuperAdminUser(claims.UserID) {
+		writeOAuthClientError(w, r, ErrOAuthClientNotFound)
+		return
+	}
+
+	if err := service.DeleteClient(client.ClientID); err != nil {
+		writeOAuthClientError(w, r, err)
+		return
+	}
+
+	RecordAuthEvent(r, AuthEvent{EventType: EventOAuthClientDelete, UserID: client.UserID, Actor: claims.Username, Success: true, Detail: client.ClientID})
+	writeAuthResponse(w, r, map[string]string{"message": "OAuth client deleted"})
+}
+
+// oauthAuthenticate checks the credentials of the login form the way LoginHandler
+// does, including the login throttle. Users with MFA must also send a code.
+func oauthAuthenticate(r *http.Request, username, password, code, recoveryCode string) (*User, []string, error) {
+	ip := ClientIP(r)
+	throttle := GetLoginThrottle()
+	if err := throttle.Check(username, ip); err != nil {
+		var blocked *LoginBlockedError
+		if errors.As(err, &blocked) {
+			RecordAuthEvent(r, AuthEvent{EventType: EventLoginBlocked, Username: username, Detail: blocked.Error()})
+		}
+		return nil, nil, err
+	}
+
+	userRepo := NewUserRepo()
+	user, err := userRepo.GetUserByName(username)
+	if err != nil {
+		recordLoginFailure(r, throttle, username, ip, 0, "unknown username")
+		return nil, nil, errOAuthInvalidLogin
+	}
+	params := NewPasswordParams()
+	valid, needsRehash, err := VerifyPassword(user, password, params)
+	if err != nil {
+		log.Printf("[error] - Verify password of user %s: %v", user.Username, err)
+	}
+	if !valid {
+		recordLoginFailure(r, throttle, user.Username, ip, user.UserID, "invalid password")
+		return nil, nil, errOAuthInvalidLogin
+	}
+	if needsRehash {
+		if err := upgradePasswordHash(userRepo, user, password, params); err != nil {
+			log.Printf("[error] - Upgrade password hash of user %s: %v", user.Username, err)
+		}
+	}
+
+	amr := []string{AMRPassword}
+	mfaEnabled, err := GetMFAService().Enabled(user.UserID)
+	if err != nil {
+		return nil, nil, err
+	}
+	if mfaEnabled {
+		if code == "" && recoveryCode == "" {
+			return nil, nil, errOAuthMFARequired
+		}
+		amr, err = GetMFAService().Verify(user.UserID, code, recoveryCode)
+		if err != nil {
+			recordMFAFailure(r, throttle, user.Username, ip, user.UserID, err)
+			return nil, nil, err
+		}
+	}
+
+	if err := throttle.RecordSuccess(user.Username); err != nil {
+		log.Printf("[error] - Reset failed logins of user %s: %v", user.Username, err)
+	}
+	return user, amr, nil
+}
+
+// verifyOAuthAccessToken verifies an access token and checks that it was not revoked
+func verifyOAuthAccessToken(token string) (*JwtClaims, error) {
+	claims, err := GetTokenService().ParseAccessToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if IsTokenRevoked(claims.Id) {
+		return nil, errors.New("token has been revoked")
+	}
+	return claims, nil
+}
+
+// oauthSession returns the claims of the user signed in to the authorization pages, if any.
+// Impersonation tokens are no session: staff acting as a user cannot authorize clients.
+func oauthSession(r *http.Request) *JwtClaims {
+	cookie, err := r.Cookie(oauthSessionCookie)
+	if err != nil {
+		return nil
+	}
+	claims, err := verifyOAuthAccessToken(cookie.Value)
+	if err != nil || claims.Impersonated() {
+		return nil
+	}
+	return claims
+}
+
+// validOAuthCSRF checks that a form echoes the CSRF cookie (double-submit)
+func validOAuthCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie(oauthCSRFCookie)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(r.PostForm.Get("csrf_token"))) == 1
+}
+
+// renderOAuthPage renders a form of the authorization pages with a CSRF token
+func renderOAuthPage(w http.ResponseWriter, r *http.Request, status int, name string, page oauthPage) {
+	token := ""
+	if cookie, err := r.Cookie(oauthCSRFCookie); err == nil && cookie.Value != "" {
+		token = cookie.Value
+	} else {
+		value, err := randomToken(oauthCSRFBytes, true)
+		if err != nil {
+			renderOAuthError(w, r, http.StatusInternalServerError, "Please try again later")
+			return
+		}
+		token = value
+		http.SetCookie(w, &http.Cookie{
+			Name:     oauthCSRFCookie,
+			Value:    token,
+			Path:     oauthCookiePath,
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+	}
+	page.CSRFToken = token
+	writeOAuthPage(w, status, name, page)
+}
+
+// renderOAuthError shows an error page to the user
+func renderOAuthError(w http.ResponseWriter, r *http.Request, status int, message string) {
+	writeOAuthPage(w, status, "oauth_error", oauthPage{Title: "Authorization failed", Error: message})
+}
+
+// renderOAuthClientError shows why an authorization request cannot be sent back to its client
+func renderOAuthClientError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrOAuthClientNotFound):
+		renderOAuthError(w, r, http.StatusBadRequest, "Unknown client")
+	case errors.Is(err, ErrInvalidRedirectURI):
+		renderOAuthError(w, r, http.StatusBadRequest, "The redirect URI is not registered for this client")
+	default:
+		log.Printf("[error] - OAuth authorization request: %v", err)
+		renderOAuthError(w, r, http.StatusInternalServerError, "Please try again later")
+	}
+}
+
+// writeOAuthPage executes a page template
+func writeOAuthPage(w http.ResponseWriter, status int, name string, page oauthPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	// The pages must not be framed, so that consent cannot be clickjacked
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("Content-Security-Policy", "frame-ancestors 'none'")
+	w.WriteHeader(status)
+	if err := oauthTemplates.ExecuteTemplate(w, name, page); err != nil {
+		log.Printf("[error] - Render %s: %v", name, err)
+	}
+}
+
+// redirectOAuthError sends an authorization error back to the client's redirect URI
+func redirectOAuthError(w http.ResponseWriter, r *http.Request, req AuthorizationRequest, err error) {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("[error] - OAuth authorization: %v", err)
+		oauthErr = oauthError("server_error", "")
+	}
+	params := url.Values{"error": {oauthErr.Code}, "state": {req.State}}
+	if oauthErr.Description != "" {
+		params.Set("error_description", oauthErr.Description)
+	}
+	redirectOAuth(w, r, req.RedirectURI, params)
+}
+
+// redirectOAuth redirects to a client's redirect URI with params added to its query
+func redirectOAuth(w http.ResponseWriter, r *http.Request, redirectURI string, params url.Values) {
+	target, err := url.Parse(redirectURI)
+	if err != nil {
+		renderOAuthError(w, r, http.StatusBadRequest, "The redirect URI is not registered for this client")
+		return
+	}
+	query := target.Query()
+	for key, values := range params {
+		if len(values) > 0 && values[0] != "" {
+			query.Set(key, values[0])
+		}
+	}
+	target.RawQuery = query.Encode()
+	http.Redirect(w, r, target.String(), http.StatusFound)
+}
+
+// writeOAuthError writes an error of the token and userinfo endpoints (RFC 6749 section 5.2)
+func writeOAuthError(w http.ResponseWriter, r *http.Request, err error) {
+	var oauthErr *OAuthError
+	if !errors.As(err, &oauthErr) {
+		log.Printf("[error] - OAuth request: %v", err)
+		oauthErr = oauthError("server_error", "")
+	}
+	status := http.StatusBadRequest
+	switch oauthErr.Code {
+	case "invalid_client", "invalid_token":
+		status = http.StatusUnauthorized
+	case "insufficient_scope":
+		status = http.StatusForbidden
+	case "server_error":
+		status = http.StatusInternalServerError
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(oauthErr)
+}
+
+// writeOAuthClientError maps client registration errors to responses
+func writeOAuthClientError(w http.ResponseWriter, r *http.Request, err error) {
+	var oauthErr *OAuthError
+	switch {
+	case errors.Is(err, ErrOAuthClientNotFound):
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "OAUTH_CLIENT_NOT_FOUND", err.Error())
+	case errors.Is(err, ErrInvalidOAuthClient), errors.Is(err, ErrInvalidRedirectURI):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_OAUTH_CLIENT", err.Error())
+	case errors.As(err, &oauthErr):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_SCOPE", oauthErr.Description)
+	default:
+		log.Printf("[error] - OAuth client request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "OAUTH_CLIENT_REQUEST_FAILED", "Request failed")
+	}
+}
@@ -0,0 +1,246 @@
+package auth
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// OAuthRepo represents the repository for OAuth clients, authorization codes and consents
+type OAuthRepo struct {
+	DB *db.DB
+}
+
+// NewOAuthRepo creates a new instance of OAuthRepo
+func NewOAuthRepo() *OAuthRepo {
+	db := db.NewDB()
+	return &OAuthRepo{DB: db}
+}
+
+// oauthClientColumns are the oauth_clients columns read by scanOAuthClient
+const oauthClientColumns = `client_id, name, secret_hash, redirect_uris, grant_types, scopes, is_public,
+	skip_consent, user_id, tenant_id, created_at, created_by, revoked_at`
+
+// InsertClient stores a new client
+func (or *OAuthRepo) InsertClient(client *OAuthClient) error {
+	_, err := or.DB.Insert(`
+		INSERT INTO oauth_clients (client_id, name, secret_hash, redirect_uris, grant_types, scopes, is_public,
+			skip_consent, user_id, tenant_id, created_at, created_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		client.ClientID,
+		client.Name,
+		client.SecretHash,
+		strings.Join(client.RedirectURIs, " "),
+		strings.Join(client.GrantTypes, " "),
+		strings.Join(client.Scopes, " "),
+		client.Public,
+		client.SkipConsent,
+		client.UserID,
+		client.TenantID,
+		client.CreatedAt.UTC(),
+		client.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting OAuth client: %w", err)
+	}
+	return nil
+}
+
+// GetClient retrieves a client by id; ErrOAuthClientNotFound when there is none
+func (or *OAuthRepo) GetClient(clientID string) (*OAuthClient, error) {
+	row, err := or.DB.QueryRow("SELECT "+oauthClientColumns+" FROM oauth_clients WHERE client_id = ?", clientID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying OAuth client: %w", err)
+	}
+	client, err := scanOAuthClient(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrOAuthClientNotFound
+	}
+	return client, err
+}
+
+// GetClients lists the clients that have not been deleted, of one owner or of all
+// owners when userID is 0
+func (or *OAuthRepo) GetClients(userID int) ([]*OAuthClient, error) {
+	rows, err := or.DB.Query(`
+		SELECT `+oauthClientColumns+` FROM oauth_clients
+		WHERE revoked_at IS NULL AND (? = 0 OR user_id = ?)
+		ORDER BY created_at`, userID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying OAuth clients: %w", err)
+	}
+	defer rows.Close()
+
+	clients := []*OAuthClient{}
+	for rows.Next() {
+		client, err := scanOAuthClient(rows)
+		if err != nil {
+			return nil, err
+		}
+		clients = append(clients, client)
+	}
+	return clients, rows.Err()
+}
+
+// RevokeClient marks a client as deleted; ErrOAuthClientNotFound when there is none
+func (or *OAuthRepo) RevokeClient(clientID string, at time.Time) error {
+	result, err := or.DB.Update("UPDATE oauth_clients SET revoked_at = ? WHERE client_id = ? AND revoked_at IS NULL", at.UTC(), clientID)
+	if err != nil {
+		return fmt.Errorf("error revoking OAuth client: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("error revoking OAuth client: %w", err)
+	}
+	if affected == 0 {
+		return ErrOAuthClientNotFound
+	}
+	return nil
+}
+
+// InsertCode stores a new authorization code
+func (or *OAuthRepo) InsertCode(code *OAuthCode) error {
+	_, err := or.DB.Insert(`
+		INSERT INTO oauth_codes (code_hash, client_id, user_id, redirect_uri, scope, code_challenge,
+			code_challenge_method, nonce, amr, auth_time, expires_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		code.CodeHash,
+		code.ClientID,
+		code.UserID,
+		code.RedirectURI,
+		code.Scope,
+		code.CodeChallenge,
+		code.CodeChallengeMethod,
+		code.Nonce,
+		strings.Join(code.AMR, " "),
+		code.AuthTime.UTC(),
+		code.ExpiresAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting authorization code: %w", err)
+	}
+	return nil
+}
+
+// GetCode retrieves an authorization code by its hash; nil when there is none
+func (or *OAuthRepo) GetCode(codeHash string) (*OAuthCode, error) {
+	row, err := or.DB.QueryRow(`
+		SELECT code_hash, client_id, user_id, redirect_uri, scope, code_challenge,
+			code_challenge_method, nonce, amr, auth_time, expires_at, used_at
+		FROM oauth_codes WHERE code_hash = ?`, codeHash)
+	if err != nil {
+		return nil, fmt.Errorf("error querying authorization code: %w", err)
+	}
+
+	var code OAuthCode
+	var amr string
+	var usedAt sql.NullTime
+	err = row.Scan(
+		&code.CodeHash,
+		&code.ClientID,
+		&code.UserID,
+		&code.RedirectURI,
+		&code.Scope,
+		&code.CodeChallenge,
+		&code.CodeChallengeMethod,
+		&code.Nonce,
+		&amr,
+		&code.AuthTime,
+		&code.ExpiresAt,
+		&usedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning authorization code: %w", err)
+	}
+	code.AMR = strings.Fields(amr)
+	if usedAt.Valid {
+		code.UsedAt = &usedAt.Time
+	}
+	return &code, nil
+}
+
+// UseCode marks an authorization code as used. It reports false when the code had
+// already been used, so that concurrent exchanges cannot both succeed.
+func (or *OAuthRepo) UseCode(codeHash string, at time.Time) (bool, error) {
+	result, err := or.DB.Update("UPDATE oauth_codes SET used_at = ? WHERE code_hash = ? AND used_at IS NULL", at.UTC(), codeHash)
+	if err != nil {
+		return false, fmt.Errorf("error using authorization code: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error using authorization code: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// GetConsent returns the scope a user approved for a client and whether they approved it at all
+func (or *OAuthRepo) GetConsent(userID int, clientID string) (string, bool, error) {
+	row, err := or.DB.QueryRow("SELECT scope FROM oauth_consents WHERE user_id = ? AND client_id = ?", userID, clientID)
+	if err != nil {
+		return "", false, fmt.Errorf("error querying OAuth consent: %w", err)
+	}
+	var scope string
+	err = row.Scan(&scope)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, fmt.Errorf("error scanning OAuth consent: %w", err)
+	}
+	return scope, true, nil
+}
+
+// SaveConsent stores the scope a user approved for a client
+func (or *OAuthRepo) SaveConsent(userID int, clientID, scope string, at time.Time) error {
+	_, err := or.DB.Exec(`
+		INSERT INTO oauth_consents (user_id, client_id, scope, granted_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT(user_id, client_id) DO UPDATE SET scope = excluded.scope, granted_at = excluded.granted_at`,
+		userID, clientID, scope, at.UTC())
+	if err != nil {
+		return fmt.Errorf("error saving OAuth consent: %w", err)
+	}
+	return nil
+}
+
+// scanOAuthClient scans a row selected with oauthClientColumns
+func scanOAuthClient(row interface{ Scan(...any) error }) (*OAuthClient, error) {
+	var client OAuthClient
+	var redirectURIs, grantTypes, scopes string
+	var revokedAt sql.NullTime
+	err := row.Scan(
+		&client.ClientID,
+		&client.Name,
+		&client.SecretHash,
+		&redirectURIs,
+		&grantTypes,
+		&scopes,
+		&client.Public,
+		&client.SkipConsent,
+		&client.UserID,
+		&client.TenantID,
+		&client.CreatedAt,
+		&client.CreatedBy,
+		&revokedAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning OAuth client: %w", err)
+	}
+	client.RedirectURIs = strings.Fields(redirectURIs)
+	client.GrantTypes = strings.Fields(grantTypes)
+	client.Scopes = strings.Fields(scopes)
+	if revokedAt.Valid {
+		client.RevokedAt = &revokedAt.Time
+	}
+	return &client, nil
+}
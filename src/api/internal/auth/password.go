@@ -0,0 +1,161 @@
+package auth
+
+import (
+	"api/config"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// argon2idPrefix starts every PHC string produced by HashPassword
+const argon2idPrefix = "$argon2id$"
+
+// ErrInvalidHash is returned when a stored password hash cannot be parsed
+var ErrInvalidHash = errors.New("invalid password hash")
+
+// ErrInvalidPasswordParams is returned when argon2id cost parameters are out of range
+var ErrInvalidPasswordParams = errors.New("invalid password hashing parameters")
+
+// PasswordParams are the argon2id cost parameters. Memory is in KiB.
+type PasswordParams struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultPasswordParams follow the OWASP argon2id recommendation
+var DefaultPasswordParams = PasswordParams{
+	Memory:      64 * 1024,
+	Iterations:  3,
+	Parallelism: 2,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Validate checks that argon2id can hash with the parameters; argon2 panics on zero
+// iterations or parallelism
+func (p PasswordParams) Validate() error {
+	if p.Memory == 0 || p.Iterations == 0 || p.Parallelism == 0 || p.KeyLength == 0 {
+		return fmt.Errorf("%w: memory, iterations, parallelism and key length must be positive", ErrInvalidPasswordParams)
+	}
+	return nil
+}
+
+// NewPasswordParams returns the configured cost parameters, falling back to
+// DefaultPasswordParams for anything unset or out of range
+func NewPasswordParams() PasswordParams {
+	params := DefaultPasswordParams
+	cfg := config.NewConfig()
+	if cfg == nil {
+		return params
+	}
+	if cfg.PasswordMemory > 0 {
+		params.Memory = uint32(cfg.PasswordMemory)
+	}
+	if cfg.PasswordTime > 0 {
+		params.Iterations = uint32(cfg.PasswordTime)
+	}
+	if cfg.PasswordThreads != 0 {
+		if cfg.PasswordThreads < 1 || cfg.PasswordThreads > 255 {
+			log.Printf("[warn] - %s must be between 1 and 255, using %d", config.PasswordThreads, params.Parallelism)
+		} else {
+			params.Parallelism = uint8(cfg.PasswordThreads)
+		}
+	}
+	return params
+}
+
+// HashPassword hashes a password with argon2id and a random salt. The result is a
+// PHC string such as $argon2id$v=19$m=65536,t=3,p=2$<salt>$<hash>.
+func HashPassword(password string, params PasswordParams) (string, error) {
+	if err := params.Validate(); err != nil {
+		return "", err
+	}
+	salt := make([]byte, params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", fmt.Errorf("error generating salt: %w", err)
+	}
+
+	key := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version,
+		params.Memory, params.Iterations, params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// IsLegacyHash reports whether a stored hash predates argon2id
+func IsLegacyHash(hash string) bool {
+	return !strings.HasPrefix(hash, argon2idPrefix)
+}
+
+// VerifyPassword checks a password against the user's stored hash in constant time.
+// Legacy MD5 hashes of username+password+salt are still accepted. needsRehash is true
+// when the password matched but the hash is legacy or uses other cost parameters.
+func VerifyPassword(user *User, password string, params PasswordParams) (ok bool, needsRehash bool, err error) {
+	if user.Password == "" {
+		return false, false, nil
+	}
+
+	if IsLegacyHash(user.Password) {
+		legacy := HashString(user.Username + password + user.Salt)
+		ok = subtle.ConstantTimeCompare([]byte(legacy), []byte(user.Password)) == 1
+		return ok, ok, nil
+	}
+
+	stored, salt, key, err := decodePasswordHash(user.Password)
+	if err != nil {
+		return false, false, err
+	}
+
+	computed := argon2.IDKey([]byte(password), salt, stored.Iterations, stored.Memory, stored.Parallelism, stored.KeyLength)
+	if subtle.ConstantTimeCompare(computed, key) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash = stored.Memory != params.Memory || stored.Iterations != params.Iterations ||
+		stored.Parallelism != params.Parallelism || stored.KeyLength != params.KeyLength
+	return true, needsRehash, nil
+}
+
+// decodePasswordHash splits an argon2id PHC string into its parameters, salt and key
+func decodePasswordHash(hash string) (PasswordParams, []byte, []byte, error) {
+	var params PasswordParams
+
+	// "", "argon2id", "v=19", "m=..,t=..,p=..", salt, key
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return params, nil, nil, ErrInvalidHash
+	}
+	if params.Memory == 0 || params.Iterations == 0 || params.Parallelism == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return params, nil, nil, ErrInvalidHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
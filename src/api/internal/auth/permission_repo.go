@@ -20,12 +20,14 @@ func NewUserPermissionRepo() *UserPermissionRepo {
 func (cr *UserPermissionRepo) GetUserPermissionView(userID int) ([]*UserPermissionView, error) {
 	var users []*UserPermissionView
 
-	query := fmt.Sprintf(`
-            SELECT * FROM vw_user_permissions
+	query := `
+            SELECT user_id, role_id, role_name, is_super_admin, role_permission_id, resource_type_id,
+                   resource_name, can_execute, can_read, can_write, can_delete
+            FROM vw_user_permissions
              Where user_id = ?
-        `, userID)
+        `
 
-	rows, err := cr.DB.Query(query)
+	rows, err := cr.DB.Query(query, userID)
 
 	if err != nil {
 		return nil, err
@@ -108,3 +110,38 @@ func (cr *UserPermissionRepo) GetUserApiPermissionView(userID int, resourceName
 
 	return &user, nil
 }
+
+// GetUserGraphQLPermissions returns the GraphQL permissions a user may execute.
+// A deny rule for a permission overrides any role that allows it.
+func (cr *UserPermissionRepo) GetUserGraphQLPermissions(userID int) (map[string]bool, error) {
+	query := `SELECT resource_name, effect FROM vw_user_permissions
+             Where user_id = ? AND resource_type_id = ? AND can_execute = 1`
+
+	rows, err := cr.DB.Query(query, userID, ResourceTypeGraphQL)
+	if err != nil {
+		return nil, fmt.Errorf("error getting graphql permissions: %w", err)
+	}
+	defer rows.Close()
+
+	allowed := make(map[string]bool)
+	denied := make(map[string]bool)
+	for rows.Next() {
+		var name, effect string
+		if err := rows.Scan(&name, &effect); err != nil {
+			return nil, fmt.Errorf("error scanning graphql permission: %w", err)
+		}
+		if effect == EffectDeny {
+			denied[name] = true
+		} else {
+			allowed[name] = true
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting graphql permissions: %w", err)
+	}
+
+	for name := range denied {
+		delete(allowed, name)
+	}
+	return allowed, nil
+}
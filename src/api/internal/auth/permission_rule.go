@@ -0,0 +1,339 @@
+package auth
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Permission effects; a matching deny rule overrides every allow rule
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// permissionCacheTTL bounds how long compiled rules are kept, so changes made outside
+// the RBAC API, or by another instance, are picked up
+const permissionCacheTTL = 5 * time.Minute
+
+// PermissionRule is a compiled role permission. The resource name is a route pattern:
+// "*" and "{name}" match one path segment, and a final "**" matches any number of
+// segments, including none. With Methods set, the rule applies to those HTTP methods
+// ("*" for all); otherwise the can_* flags apply to the method's action.
+type PermissionRule struct {
+	RoleID           int      `json:"role_id"`
+	RolePermissionID int      `json:"role_permission_id"`
+	Pattern          string   `json:"pattern"`
+	Methods          []string `json:"methods,omitempty"`
+	Effect           string   `json:"effect"`
+	CanExecute       bool     `json:"can_execute"`
+	CanRead          bool     `json:"can_read"`
+	CanWrite         bool     `json:"can_write"`
+	CanDelete        bool     `json:"can_delete"`
+	segments         []string
+}
+
+// PermissionDecision is the outcome of evaluating a request against a user's rules
+type PermissionDecision struct {
+	Allowed bool            `json:"allowed"`
+	Reason  string          `json:"reason"`
+	Rule    *PermissionRule `json:"rule,omitempty"`
+}
+
+// CompileRule compiles a role permission into a rule
+func CompileRule(permission *RolePermissions) (*PermissionRule, error) {
+	pattern := normalizeResource(permission.ResourceName)
+	segments := splitPath(pattern)
+	for i, segment := range segments {
+		if segment == "**" && i != len(segments)-1 {
+			return nil, fmt.Errorf("%w: ** must be the last segment of %q", ErrInvalidPermission, permission.ResourceName)
+		}
+		if strings.HasPrefix(segment, "{") != strings.HasSuffix(segment, "}") {
+			return nil, fmt.Errorf("%w: unbalanced braces in %q", ErrInvalidPermission, permission.ResourceName)
+		}
+	}
+
+	effect := permission.Effect
+	if effect == "" {
+		effect = EffectAllow
+	}
+	if effect != EffectAllow && effect != EffectDeny {
+		return nil, fmt.Errorf("%w: effect must be %q or %q", ErrInvalidPermission, EffectAllow, EffectDeny)
+	}
+
+	methods := make([]string, 0, len(permission.Methods))
+	for _, method := range permission.Methods {
+		method = strings.ToUpper(strings.TrimSpace(method))
+		if method == "" {
+			continue
+		}
+		if method != "*" && methodAction(method) == "" {
+			return nil, fmt.Errorf("%w: unknown method %q", ErrInvalidPermission, method)
+		}
+		methods = append(methods, method)
+	}
+
+	return &PermissionRule{
+		RoleID:           permission.RoleID,
+		RolePermissionID: permission.RolePermissionID,
+		Pattern:          pattern,
+		Methods:          methods,
+		Effect:           effect,
+		CanExecute:       permission.CanExecute,
+		CanRead:          permission.CanRead,
+		CanWrite:         permission.CanWrite,
+		CanDelete:        permission.CanDelete,
+		segments:         segments,
+	}, nil
+}
+
+// MatchPath reports whether a normalised request path matches the rule's pattern
+func (rule *PermissionRule) MatchPath(path string) bool {
+	return matchSegments(rule.segments, splitPath(path))
+}
+
+// AppliesTo reports whether the rule covers an HTTP method
+func (rule *PermissionRule) AppliesTo(method string) bool {
+	if len(rule.Methods) > 0 {
+		for _, m := range rule.Methods {
+			if m == "*" || m == method {
+				return true
+			}
+		}
+		return false
+	}
+	return rule.grants(methodAction(method))
+}
+
+// AppliesToAction reports whether the rule covers an action such as "read"
+func (rule *PermissionRule) AppliesToAction(action string) bool {
+	if len(rule.Methods) > 0 {
+		for _, m := range rule.Methods {
+			if m == "*" || methodAction(m) == action {
+				return true
+			}
+		}
+		return false
+	}
+	return rule.grants(action)
+}
+
+// grants reports whether the rule's flags include an action
+func (rule *PermissionRule) grants(action string) bool {
+	switch action {
+	case ActionRead:
+		return rule.CanRead
+	case ActionWrite:
+		return rule.CanWrite
+	case ActionDelete:
+		return rule.CanDelete
+	case ActionExecute:
+		return rule.CanExecute
+	case ActionAll:
+		return rule.CanRead && rule.CanWrite && rule.CanDelete && rule.CanExecute
+	}
+	return false
+}
+
+// decide applies deny-overrides to the rules matching a path
+func decide(rules []*PermissionRule, path string, applies func(*PermissionRule) bool) PermissionDecision {
+	var allow *PermissionRule
+	for _, rule := range rules {
+		if !rule.MatchPath(path) || !applies(rule) {
+			continue
+		}
+		if rule.Effect == EffectDeny {
+			return PermissionDecision{Allowed: false, Reason: "denied by rule " + rule.Pattern, Rule: rule}
+		}
+		if allow == nil {
+			allow = rule
+		}
+	}
+	if allow != nil {
+		return PermissionDecision{Allowed: true, Reason: "allowed by rule " + allow.Pattern, Rule: allow}
+	}
+	return PermissionDecision{Allowed: false, Reason: "no rule allows this request"}
+}
+
+// PermissionEvaluator checks requests against the compiled rules of a user's roles.
+// Rules are cached per role and invalidated when the role's permissions change.
+type PermissionEvaluator struct {
+	repo  *RoleRepo
+	ttl   time.Duration
+	now   func() time.Time
+	mu    sync.RWMutex
+	cache map[int]cachedRules
+}
+
+// cachedRules are the compiled rules of one role
+type cachedRules struct {
+	rules    []*PermissionRule
+	loadedAt time.Time
+}
+
+var (
+	permissionEvaluatorOnce     sync.Once
+	permissionEvaluatorInstance *PermissionEvaluator
+)
+
+// NewPermissionEvaluator creates a PermissionEvaluator; ttl 0 disables expiry
+func NewPermissionEvaluator(repo *RoleRepo, ttl time.Duration) *PermissionEvaluator {
+	return &PermissionEvaluator{repo: repo, ttl: ttl, now: time.Now, cache: make(map[int]cachedRules)}
+}
+
+// GetPermissionEvaluator returns the shared PermissionEvaluator
+func GetPermissionEvaluator() *PermissionEvaluator {
+	permissionEvaluatorOnce.Do(func() {
+		permissionEvaluatorInstance = NewPermissionEvaluator(NewRoleRepo(), permissionCacheTTL)
+	})
+	return permissionEvaluatorInstance
+}
+
+// Evaluate decides whether a user may call method on path. Super admins are not
+// special here; callers check them first.
+func (e *PermissionEvaluator) Evaluate(userID int, method, path string) (PermissionDecision, error) {
+	rules, err := e.userRules(userID)
+	if err != nil {
+		return PermissionDecision{}, err
+	}
+	method = strings.ToUpper(method)
+	return decide(rules, normalizeResource(path), func(rule *PermissionRule) bool {
+		return rule.AppliesTo(method)
+	}), nil
+}
+
+// EvaluateAction decides whether a user may perform an action, such as "read", on a resource
+func (e *PermissionEvaluator) EvaluateAction(userID int, action, resource string) (PermissionDecision, error) {
+	rules, err := e.userRules(userID)
+	if err != nil {
+		return PermissionDecision{}, err
+	}
+	return decide(rules, normalizeResource(resource), func(rule *PermissionRule) bool {
+		return rule.AppliesToAction(action)
+	}), nil
+}
+
+// Invalidate drops the cached rules of a role
+func (e *PermissionEvaluator) Invalidate(roleID int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	delete(e.cache, roleID)
+}
+
+// InvalidateAll drops every cached rule
+func (e *PermissionEvaluator) InvalidateAll() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.cache = make(map[int]cachedRules)
+}
+
+// userRules returns the API rules of all roles of a user
+func (e *PermissionEvaluator) userRules(userID int) ([]*PermissionRule, error) {
+	roleIDs, err := e.repo.GetRoleIDsByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	var rules []*PermissionRule
+	for _, roleID := range roleIDs {
+		roleRules, err := e.roleRules(roleID)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, roleRules...)
+	}
+	return rules, nil
+}
+
+// roleRules returns the compiled API rules of a role, loading them on a cache miss
+func (e *PermissionEvaluator) roleRules(roleID int) ([]*PermissionRule, error) {
+	now := e.now()
+	e.mu.RLock()
+	cached, ok := e.cache[roleID]
+	e.mu.RUnlock()
+	if ok && (e.ttl == 0 || now.Sub(cached.loadedAt) < e.ttl) {
+		return cached.rules, nil
+	}
+
+	permissions, err := e.repo.GetRolePermissions(roleID)
+	if err != nil {
+		return nil, err
+	}
+	rules := make([]*PermissionRule, 0, len(permissions))
+	for _, permission := range permissions {
+		if permission.ResourceTypeID != ResourceTypeAPI || permission.StatusID != statusActive {
+			continue
+		}
+		rule, err := CompileRule(permission)
+		if err != nil {
+			// Fail the check rather than skip the rule: a skipped deny rule would widen access
+			return nil, fmt.Errorf("error compiling permission %d of role %d: %w", permission.RolePermissionID, roleID, err)
+		}
+		rules = append(rules, rule)
+	}
+
+	e.mu.Lock()
+	e.cache[roleID] = cachedRules{rules: rules, loadedAt: now}
+	e.mu.Unlock()
+	return rules, nil
+}
+
+// normalizeResource strips any query string and trailing slash from a path or pattern
+// and maps versioned prefixes to the resource they are authorized against
+func normalizeResource(pattern string) string {
+	return apiResource(normalizePath(pattern))
+}
+
+// normalizePath strips any query string and trailing slash from a path
+func normalizePath(path string) string {
+	path, _, _ = strings.Cut(strings.TrimSpace(path), "?")
+	if len(path) > 1 {
+		path = strings.TrimRight(path, "/")
+	}
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return path
+}
+
+// splitPath splits a normalised path into its segments
+func splitPath(path string) []string {
+	path = strings.Trim(path, "/")
+	if path == "" {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
+
+// matchSegments matches path segments against pattern segments
+func matchSegments(pattern, path []string) bool {
+	for i, segment := range pattern {
+		if segment == "**" {
+			return true
+		}
+		if i >= len(path) {
+			return false
+		}
+		if segment == "*" || (strings.HasPrefix(segment, "{") && strings.HasSuffix(segment, "}")) {
+			continue
+		}
+		if segment != path[i] {
+			return false
+		}
+	}
+	return len(pattern) == len(path)
+}
+
+// methodAction returns the permission an HTTP method needs
+func methodAction(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return ActionRead
+	case http.MethodPost, http.MethodPut, http.MethodPatch:
+		return ActionWrite
+	case http.MethodDelete:
+		return ActionDelete
+	}
+	return ""
+}
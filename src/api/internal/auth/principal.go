@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// claimsContextKey stores the claims of the access token a request was authenticated with
+	claimsContextKey = ContextKey("claims")
+	// systemPrincipal is recorded as the actor of requests without a user
+	systemPrincipal = "system"
+)
+
+// WithClaims stores the claims of an authenticated access token in a context
+func WithClaims(ctx context.Context, claims *JwtClaims) context.Context {
+	return context.WithValue(ctx, claimsContextKey, claims)
+}
+
+// ClaimsFromContext returns the claims of the access token a request was authenticated with, if any
+func ClaimsFromContext(ctx context.Context) *JwtClaims {
+	claims, _ := ctx.Value(claimsContextKey).(*JwtClaims)
+	return claims
+}
+
+// Principal returns the user a request acts as: the owner of its API key, or the user of its
+// access token from the Authorization header or the token cookie. Requests authenticated in
+// middleware carry the key or claims in their context; others have their token verified here.
+func Principal(r *http.Request) (int, string, bool) {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return key.UserID, "apikey:" + key.Prefix, true
+	}
+	claims := ClaimsFromContext(r.Context())
+	if claims == nil {
+		verified, err := VerifyToken(getTokenFromRequest(r))
+		if err != nil {
+			return 0, "", false
+		}
+		claims = verified
+	}
+	if claims.Username == "" {
+		return 0, "", false
+	}
+	return claims.UserID, claims.Username, true
+}
+
+// Username returns the name recorded as the actor of a request, "system" when it has no user
+func Username(r *http.Request) string {
+	if _, username, ok := Principal(r); ok {
+		return username
+	}
+	return systemPrincipal
+}
@@ -0,0 +1,531 @@
+package auth
+
+import (
+	"database/sql"
+	"errors"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// maxRoleNameLength bounds role names
+	maxRoleNameLength = 64
+	// ResourceTypeAPI is the resource type of REST paths checked by HasUserApiPermission
+	ResourceTypeAPI = 1
+	// ResourceTypeGraphQL is the resource type of GraphQL field permissions such as "contacts.getById"
+	ResourceTypeGraphQL = 2
+	// statusActive is the status_id of active rows
+	statusActive = 1
+)
+
+// RBAC errors
+var (
+	ErrRoleNotFound       = errors.New("role not found")
+	ErrRoleExists         = errors.New("a role with this name already exists")
+	ErrRoleInUse          = errors.New("role is still assigned to users")
+	ErrInvalidRole        = errors.New("role name is required and must be at most 64 characters")
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrPermissionExists   = errors.New("the role already has a rule with this effect on this resource")
+	ErrInvalidPermission  = errors.New("a resource name and methods or at least one of can_read, can_write, can_delete or can_execute are required")
+	ErrUserNotFound       = errors.New("user not found")
+	ErrUserRoleExists     = errors.New("user already has this role")
+	ErrUserRoleNotFound   = errors.New("user does not have this role")
+	ErrSuperAdminRequired = errors.New("only super admins can grant or change super admin roles")
+	ErrLastSuperAdmin     = errors.New("at least one super admin must remain")
+	ErrTenantMismatch     = errors.New("user and role belong to different tenants")
+)
+
+// RBACActor is the user making an RBAC change
+type RBACActor struct {
+	UserID   int
+	Username string
+	// TenantID is the tenant of the actor; roles it creates belong to it
+	TenantID int
+}
+
+// EffectivePermission is what a user may do on a resource, combined over all their roles
+type EffectivePermission struct {
+	ResourceTypeID int      `json:"resource_type_id"`
+	ResourceName   string   `json:"resource_name"`
+	Effect         string   `json:"effect"`
+	Methods        []string `json:"methods,omitempty"`
+	CanExecute     bool     `json:"can_execute"`
+	CanRead        bool     `json:"can_read"`
+	CanWrite       bool     `json:"can_write"`
+	CanDelete      bool     `json:"can_delete"`
+	Roles          []string `json:"roles"`
+}
+
+// UserAccess lists the roles of a user and the permissions they add up to
+type UserAccess struct {
+	UserID       int                    `json:"user_id"`
+	Username     string                 `json:"user_name"`
+	IsSuperAdmin bool                   `json:"is_super_admin"`
+	Roles        []*Role                `json:"roles"`
+	Permissions  []*EffectivePermission `json:"permissions"`
+}
+
+// AssignRoleRequest assigns a role to a user
+type AssignRoleRequest struct {
+	RoleID int `json:"role_id" example:"2"`
+}
+
+// RBACService manages roles, role permissions and user-role assignments
+type RBACService struct {
+	roles *RoleRepo
+	users *UserRepo
+	rules *PermissionEvaluator
+	now   func() time.Time
+}
+
+var (
+	rbacServiceOnce     sync.Once
+	rbacServiceInstance *RBACService
+)
+
+// NewRBACService creates an RBACService with explicit dependencies. Permission changes
+// invalidate the rules cached by the evaluator.
+func NewRBACService(roles *RoleRepo, users *UserRepo, rules *PermissionEvaluator) *RBACService {
+	return &RBACService{roles: roles, users: users, rules: rules, now: time.Now}
+}
+
+// GetRBACService returns the shared RBACService
+func GetRBACService() *RBACService {
+	rbacServiceOnce.Do(func() {
+		rbacServiceInstance = NewRBACService(NewRoleRepo(), NewUserRepo(), GetPermissionEvaluator())
+	})
+	return rbacServiceInstance
+}
+
+// ListRoles returns a page of the actor's tenant roles matching search and the total number of matches
+func (s *RBACService) ListRoles(search string, limit, offset int, actor RBACActor) ([]*Role, int, error) {
+	total, err := s.roles.CountRoles(actor.TenantID, search)
+	if err != nil {
+		return nil, 0, err
+	}
+	roles, err := s.roles.GetRoles(actor.TenantID, search, limit, offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	return roles, total, nil
+}
+
+// GetRole returns a role of the actor's tenant by id
+func (s *RBACService) GetRole(roleID int, actor RBACActor) (*Role, error) {
+	return s.getRole(roleID, actor)
+}
+
+// CreateRole creates a role. Only super admins may create a super admin role.
+func (s *RBACService) CreateRole(input Role, actor RBACActor) (*Role, error) {
+	if err := s.validateRole(&input, 0); err != nil {
+		return nil, err
+	}
+	if input.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+
+	now := s.now()
+	role := &Role{
+		RoleName:     input.RoleName,
+		RoleDesc:     input.RoleDesc,
+		IsSuperAdmin: input.IsSuperAdmin,
+		RequireMFA:   input.RequireMFA,
+		CreatedAt:    now,
+		CreatedBy:    actor.Username,
+		UpdatedAt:    now,
+		UpdatedBy:    actor.Username,
+		StatusID:     input.StatusID,
+		TenantID:     actor.TenantID,
+	}
+	id, err := s.roles.InsertRole(role)
+	if err != nil {
+		return nil, err
+	}
+	role.RoleID = int(id)
+	return role, nil
+}
+
+// UpdateRole changes the name, description and flags of a role. Super admin roles,
+// and making a role super admin, are reserved to super admins.
+func (s *RBACService) UpdateRole(roleID int, input Role, actor RBACActor) (*Role, error) {
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateRole(&input, roleID); err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin || input.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	if role.IsSuperAdmin && !input.IsSuperAdmin {
+		if err := s.keepSuperAdmin(roleID, 0); err != nil {
+			return nil, err
+		}
+	}
+
+	role.RoleName = input.RoleName
+	role.RoleDesc = input.RoleDesc
+	role.IsSuperAdmin = input.IsSuperAdmin
+	role.RequireMFA = input.RequireMFA
+	role.StatusID = input.StatusID
+	role.UpdatedAt = s.now()
+	role.UpdatedBy = actor.Username
+	if _, err := s.roles.UpdateRole(role); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// DeleteRole deletes a role that is no longer assigned to anyone, with its permissions
+func (s *RBACService) DeleteRole(roleID int, actor RBACActor) (*Role, error) {
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	users, err := s.roles.CountRoleUsers(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if users > 0 {
+		return nil, ErrRoleInUse
+	}
+	if _, err := s.roles.DeleteRole(roleID); err != nil {
+		return nil, err
+	}
+	s.rules.Invalidate(roleID)
+	return role, nil
+}
+
+// ListPermissions returns the permissions of a role of the actor's tenant
+func (s *RBACService) ListPermissions(roleID int, actor RBACActor) ([]*RolePermissions, error) {
+	if _, err := s.getRole(roleID, actor); err != nil {
+		return nil, err
+	}
+	return s.roles.GetRolePermissions(roleID)
+}
+
+// AddPermission grants a role access to a resource
+func (s *RBACService) AddPermission(roleID int, input RolePermissions, actor RBACActor) (*RolePermissions, error) {
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	if err := validatePermission(&input); err != nil {
+		return nil, err
+	}
+	_, err = s.roles.GetRolePermissionByResource(roleID, input.ResourceTypeID, input.ResourceName, input.Effect)
+	if err == nil {
+		return nil, ErrPermissionExists
+	}
+	if !errors.Is(err, ErrPermissionNotFound) {
+		return nil, err
+	}
+
+	now := s.now()
+	input.RolePermissionID = 0
+	input.RoleID = roleID
+	input.CreatedAt = now
+	input.CreatedBy = actor.Username
+	input.UpdatedAt = now
+	input.UpdatedBy = actor.Username
+	id, err := s.roles.InsertRolePermission(&input)
+	if err != nil {
+		return nil, err
+	}
+	s.rules.Invalidate(roleID)
+	input.RolePermissionID = int(id)
+	return &input, nil
+}
+
+// UpdatePermission changes the resource or flags of a role permission
+func (s *RBACService) UpdatePermission(roleID, permissionID int, input RolePermissions, actor RBACActor) (*RolePermissions, error) {
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	permission, err := s.roles.GetRolePermission(roleID, permissionID)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePermission(&input); err != nil {
+		return nil, err
+	}
+	existing, err := s.roles.GetRolePermissionByResource(roleID, input.ResourceTypeID, input.ResourceName, input.Effect)
+	if err == nil && existing.RolePermissionID != permissionID {
+		return nil, ErrPermissionExists
+	}
+	if err != nil && !errors.Is(err, ErrPermissionNotFound) {
+		return nil, err
+	}
+
+	permission.ResourceTypeID = input.ResourceTypeID
+	permission.ResourceName = input.ResourceName
+	permission.CanExecute = input.CanExecute
+	permission.CanRead = input.CanRead
+	permission.CanWrite = input.CanWrite
+	permission.CanDelete = input.CanDelete
+	permission.Methods = input.Methods
+	permission.Effect = input.Effect
+	permission.StatusID = input.StatusID
+	permission.UpdatedAt = s.now()
+	permission.UpdatedBy = actor.Username
+	if _, err := s.roles.UpdateRolePermission(permission); err != nil {
+		return nil, err
+	}
+	s.rules.Invalidate(roleID)
+	return permission, nil
+}
+
+// DeletePermission removes a permission from a role
+func (s *RBACService) DeletePermission(roleID, permissionID int, actor RBACActor) (*RolePermissions, error) {
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	permission, err := s.roles.GetRolePermission(roleID, permissionID)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := s.roles.DeleteRolePermission(roleID, permissionID); err != nil {
+		return nil, err
+	}
+	s.rules.Invalidate(roleID)
+	return permission, nil
+}
+
+// UserAccess returns the roles of a user of the actor's tenant and their effective permissions
+func (s *RBACService) UserAccess(userID int, actor RBACActor) (*UserAccess, error) {
+	user, err := s.getUser(userID, actor)
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roles.GetRolesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	permissions, err := s.roles.GetEffectivePermissions(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	access := &UserAccess{UserID: user.UserID, Username: user.Username, Roles: roles, Permissions: permissions}
+	for _, role := range roles {
+		access.IsSuperAdmin = access.IsSuperAdmin || role.IsSuperAdmin
+	}
+	return access, nil
+}
+
+// AssignRole gives a user a role of the same tenant. Only super admins may assign a
+// super admin role.
+func (s *RBACService) AssignRole(userID, roleID int, actor RBACActor) (*Role, error) {
+	user, err := s.getUser(userID, actor)
+	if err != nil {
+		return nil, err
+	}
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.TenantID != tenantOf(user) {
+		return nil, ErrTenantMismatch
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+	}
+	exists, err := s.roles.HasUserRole(userID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrUserRoleExists
+	}
+
+	now := s.now()
+	_, err = s.roles.InsertUserRole(&UserRoles{
+		RoleID:    roleID,
+		UserID:    strconv.Itoa(userID),
+		CreatedAt: now,
+		CreatedBy: actor.Username,
+		UpdatedAt: now,
+		UpdatedBy: actor.Username,
+		StatusID:  statusActive,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// RemoveRole takes a role from a user. The last super admin cannot be removed.
+func (s *RBACService) RemoveRole(userID, roleID int, actor RBACActor) (*Role, error) {
+	if _, err := s.getUser(userID, actor); err != nil {
+		return nil, err
+	}
+	role, err := s.getRole(roleID, actor)
+	if err != nil {
+		return nil, err
+	}
+	if role.IsSuperAdmin {
+		if err := s.requireSuperAdmin(actor); err != nil {
+			return nil, err
+		}
+		if err := s.keepSuperAdmin(roleID, userID); err != nil {
+			return nil, err
+		}
+	}
+	removed, err := s.roles.DeleteUserRole(userID, roleID)
+	if err != nil {
+		return nil, err
+	}
+	if removed == 0 {
+		return nil, ErrUserRoleNotFound
+	}
+	return role, nil
+}
+
+// validateRole checks a role's name, which must be unique apart from the role itself
+func (s *RBACService) validateRole(role *Role, roleID int) error {
+	role.RoleName = strings.TrimSpace(role.RoleName)
+	if role.RoleName == "" || len(role.RoleName) > maxRoleNameLength {
+		return ErrInvalidRole
+	}
+	if role.StatusID == 0 {
+		role.StatusID = statusActive
+	}
+	existing, err := s.roles.GetRoleByName(role.RoleName)
+	if err == nil && existing.RoleID != roleID {
+		return ErrRoleExists
+	}
+	if err != nil && !errors.Is(err, ErrRoleNotFound) {
+		return err
+	}
+	return nil
+}
+
+// requireSuperAdmin fails unless the actor holds a super admin role
+func (s *RBACService) requireSuperAdmin(actor RBACActor) error {
+	isSuperAdmin, err := s.roles.GetUserIsSuperAdminByUserID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !isSuperAdmin {
+		return ErrSuperAdminRequired
+	}
+	return nil
+}
+
+// keepSuperAdmin fails if no super admin would remain without the role, or
+// without the user's assignment of it when userID is set
+func (s *RBACService) keepSuperAdmin(roleID, userID int) error {
+	remaining, err := s.roles.CountSuperAdmins(roleID, userID)
+	if err != nil {
+		return err
+	}
+	if remaining == 0 {
+		return ErrLastSuperAdmin
+	}
+	return nil
+}
+
+// getRole returns a role by id. Roles of another tenant than the actor's are not
+// found, except by super admins of the root tenant.
+func (s *RBACService) getRole(roleID int, actor RBACActor) (*Role, error) {
+	role, err := s.roles.GetRoleByID(roleID)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTenant(role.TenantID, actor, ErrRoleNotFound); err != nil {
+		return nil, err
+	}
+	return role, nil
+}
+
+// getUser returns a user by id, or ErrUserNotFound. Users of another tenant than the
+// actor's are not found, except by super admins of the root tenant.
+func (s *RBACService) getUser(userID int, actor RBACActor) (*User, error) {
+	user, err := s.users.GetUserByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkTenant(tenantOf(user), actor, ErrUserNotFound); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// checkTenant fails with notFound when tenantID is not the actor's tenant and the
+// actor is not a super admin of the root tenant
+func (s *RBACService) checkTenant(tenantID int, actor RBACActor, notFound error) error {
+	if tenantID == actor.TenantID {
+		return nil
+	}
+	if actor.TenantID != DefaultTenantID {
+		return notFound
+	}
+	isSuperAdmin, err := s.roles.GetUserIsSuperAdminByUserID(actor.UserID)
+	if err != nil {
+		return err
+	}
+	if !isSuperAdmin {
+		return notFound
+	}
+	return nil
+}
+
+// validatePermission checks and normalises a role permission
+func validatePermission(permission *RolePermissions) error {
+	permission.ResourceName = strings.TrimSpace(permission.ResourceName)
+	if permission.ResourceTypeID == 0 {
+		permission.ResourceTypeID = ResourceTypeAPI
+	}
+	if permission.StatusID == 0 {
+		permission.StatusID = statusActive
+	}
+	if permission.Effect == "" {
+		permission.Effect = EffectAllow
+	}
+	if permission.ResourceName == "" || permission.ResourceTypeID < 0 ||
+		!(len(permission.Methods) > 0 || permission.CanRead || permission.CanWrite || permission.CanDelete || permission.CanExecute) {
+		return ErrInvalidPermission
+	}
+
+	// Compiling checks the route pattern, effect and methods, and normalises the methods
+	rule, err := CompileRule(permission)
+	if err != nil {
+		return err
+	}
+	permission.Methods = rule.Methods
+	return nil
+}
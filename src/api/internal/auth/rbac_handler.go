@@ -0,0 +1,570 @@
+package auth
+
+import (
+	"api/internal/handler"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// defaultRolesPerPage and maxRolesPerPage bound the page size of the role list
+	defaultRolesPerPage = 20
+	maxRolesPerPage     = 100
+)
+
+// GetRolesHandler godoc
+// @Summary List roles
+// @Description List the roles of the caller's tenant, optionally filtered by name or description
+// @Tags rbac
+// @Produce json
+// @Param search query string false "Text in the role name or description"
+// @Param page query int false "Page number (default 1)"
+// @Param per_page query int false "Page size (default 20, max 100)"
+// @Success 200 {array} Role
+// @Failure 400 {object} types.ErrorResponse
+// @Router /roles [get]
+func GetRolesHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	query := r.URL.Query()
+	page, perPage := 1, defaultRolesPerPage
+	var err error
+	for name, target := range map[string]*int{"page": &page, "per_page": &perPage} {
+		if value := query.Get(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil || *target < 1 {
+				writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_QUERY", "Invalid "+name)
+				return
+			}
+		}
+	}
+	if perPage > maxRolesPerPage {
+		perPage = maxRolesPerPage
+	}
+
+	roles, total, err := GetRBACService().ListRoles(query.Get("search"), perPage, (page-1)*perPage, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	resp := handler.NewResponse(http.StatusOK, "Success", roles, GetRequestID(r)).WithPagination(page, perPage, total)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// GetRoleHandler godoc
+// @Summary Get a role
+// @Description Get a role by id
+// @Tags rbac
+// @Produce json
+// @Param id path int true "Role ID"
+// @Success 200 {object} Role
+// @Failure 404 {object} types.ErrorResponse
+// @Router /roles/{id} [get]
+func GetRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	role, err := GetRBACService().GetRole(roleID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, role)
+}
+
+// CreateRoleHandler godoc
+// @Summary Create a new role
+// @Description Create a new role in the system. Only super admins can create super admin roles.
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param role body Role true "Role information"
+// @Success 201 {object} Role
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /roles [post]
+func CreateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	var body Role
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	role, err := GetRBACService().CreateRole(body, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("created role %d %q (super_admin=%t)", role.RoleID, role.RoleName, role.IsSuperAdmin))
+	writeCreated(w, r, role)
+}
+
+// UpdateRoleHandler godoc
+// @Summary Update a role
+// @Description Change the name, description and flags of a role. Only super admins can change super admin roles.
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param role body Role true "Role information"
+// @Success 200 {object} Role
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /roles/{id} [put]
+func UpdateRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var body Role
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	role, err := GetRBACService().UpdateRole(roleID, body, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("updated role %d %q (super_admin=%t, require_mfa=%t)", role.RoleID, role.RoleName, role.IsSuperAdmin, role.RequireMFA))
+	writeAuthResponse(w, r, role)
+}
+
+// DeleteRoleHandler godoc
+// @Summary Delete a role
+// @Description Delete a role that is not assigned to any user, with its permissions
+// @Tags rbac
+// @Produce json
+// @Param id path int true "Role ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /roles/{id} [delete]
+func DeleteRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+
+	role, err := GetRBACService().DeleteRole(roleID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("deleted role %d %q", role.RoleID, role.RoleName))
+	writeAuthResponse(w, r, map[string]string{"message": "Role deleted"})
+}
+
+// GetRolePermissionsHandler godoc
+// @Summary List role permissions
+// @Description List the resources a role grants access to
+// @Tags rbac
+// @Produce json
+// @Param id path int true "Role ID"
+// @Success 200 {array} RolePermissions
+// @Failure 404 {object} types.ErrorResponse
+// @Router /roles/{id}/permissions [get]
+func GetRolePermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	permissions, err := GetRBACService().ListPermissions(roleID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, permissions)
+}
+
+// CreateRolePermissionHandler godoc
+// @Summary Add a role permission
+// @Description Grant a role read, write, delete or execute access to a resource
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param permission body RolePermissions true "Resource and access flags"
+// @Success 201 {object} RolePermissions
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /roles/{id}/permissions [post]
+func CreateRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	var body RolePermissions
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	permission, err := GetRBACService().AddPermission(roleID, body, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("role %d granted %s", roleID, describePermission(permission)))
+	writeCreated(w, r, permission)
+}
+
+// UpdateRolePermissionHandler godoc
+// @Summary Update a role permission
+// @Description Change the resource or access flags of a role permission
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param permissionId path int true "Role permission ID"
+// @Param permission body RolePermissions true "Resource and access flags"
+// @Success 200 {object} RolePermissions
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /roles/{id}/permissions/{permissionId} [put]
+func UpdateRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	permissionID, ok := pathID(w, r, "permissionId")
+	if !ok {
+		return
+	}
+	var body RolePermissions
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+
+	permission, err := GetRBACService().UpdatePermission(roleID, permissionID, body, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("role %d permission %d set to %s", roleID, permissionID, describePermission(permission)))
+	writeAuthResponse(w, r, permission)
+}
+
+// DeleteRolePermissionHandler godoc
+// @Summary Remove a role permission
+// @Description Remove a permission from a role
+// @Tags rbac
+// @Produce json
+// @Param id path int true "Role ID"
+// @Param permissionId path int true "Role permission ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} types.ErrorResponse
+// @Router /roles/{id}/permissions/{permissionId} [delete]
+func DeleteRolePermissionHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "id")
+	if !ok {
+		return
+	}
+	permissionID, ok := pathID(w, r, "permissionId")
+	if !ok {
+		return
+	}
+
+	permission, err := GetRBACService().DeletePermission(roleID, permissionID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, 0, fmt.Sprintf("role %d revoked %s", roleID, describePermission(permission)))
+	writeAuthResponse(w, r, map[string]string{"message": "Permission removed"})
+}
+
+// GetUserAccessHandler godoc
+// @Summary Get a user's roles and effective permissions
+// @Description List the roles of a user and the permissions they add up to per resource
+// @Tags rbac
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {object} UserAccess
+// @Failure 404 {object} types.ErrorResponse
+// @Router /users/{userId}/permissions [get]
+func GetUserAccessHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+	access, err := GetRBACService().UserAccess(userID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, access)
+}
+
+// GetUserRolesHandler godoc
+// @Summary List a user's roles
+// @Description List the roles assigned to a user
+// @Tags rbac
+// @Produce json
+// @Param userId path int true "User ID"
+// @Success 200 {array} Role
+// @Failure 404 {object} types.ErrorResponse
+// @Router /users/{userId}/roles [get]
+func GetUserRolesHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+	access, err := GetRBACService().UserAccess(userID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+	writeAuthResponse(w, r, access.Roles)
+}
+
+// AssignUserRoleHandler godoc
+// @Summary Assign a role to a user
+// @Description Give a user a role. Only super admins can assign super admin roles.
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param request body AssignRoleRequest true "Role to assign"
+// @Success 201 {object} Role
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /users/{userId}/roles [post]
+func AssignUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+	var body AssignRoleRequest
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+	assignUserRole(w, r, userID, body.RoleID)
+}
+
+// CreateUserRoleHandler godoc
+// @Summary Assign a role to a user
+// @Description Give a user a role. Prefer POST /users/{userId}/roles.
+// @Tags rbac
+// @Accept json
+// @Produce json
+// @Param request body UserRoles true "User and role"
+// @Success 201 {object} Role
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /user-role [post]
+func CreateUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body UserRoles
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+		return
+	}
+	userID, err := strconv.Atoi(body.UserID)
+	if err != nil {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid user_id")
+		return
+	}
+	assignUserRole(w, r, userID, body.RoleID)
+}
+
+// RemoveUserRoleHandler godoc
+// @Summary Remove a role from a user
+// @Description Take a role from a user. The last super admin cannot be removed.
+// @Tags rbac
+// @Produce json
+// @Param userId path int true "User ID"
+// @Param roleId path int true "Role ID"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /users/{userId}/roles/{roleId} [delete]
+func RemoveUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+	userID, ok := pathID(w, r, "userId")
+	if !ok {
+		return
+	}
+	roleID, ok := pathID(w, r, "roleId")
+	if !ok {
+		return
+	}
+
+	role, err := GetRBACService().RemoveRole(userID, roleID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, userID, fmt.Sprintf("removed role %d %q", role.RoleID, role.RoleName))
+	writeAuthResponse(w, r, map[string]string{"message": "Role removed"})
+}
+
+// assignUserRole assigns a role and writes the response
+func assignUserRole(w http.ResponseWriter, r *http.Request, userID, roleID int) {
+	actor, ok := requestRBACActor(w, r)
+	if !ok {
+		return
+	}
+
+	role, err := GetRBACService().AssignRole(userID, roleID, actor)
+	if err != nil {
+		writeRBACError(w, r, err)
+		return
+	}
+
+	recordRoleChange(r, actor, userID, fmt.Sprintf("assigned role %d %q", role.RoleID, role.RoleName))
+	writeCreated(w, r, role)
+}
+
+// requestRBACActor returns the user behind the request's token or API key, or writes a 401
+func requestRBACActor(w http.ResponseWriter, r *http.Request) (RBACActor, bool) {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return RBACActor{UserID: key.UserID, Username: requestActor(r), TenantID: requestTenantID(r.Context(), nil)}, true
+	}
+	claims, ok := requestClaims(w, r)
+	if !ok {
+		return RBACActor{}, false
+	}
+	return RBACActor{UserID: claims.UserID, Username: claims.Username, TenantID: requestTenantID(r.Context(), claims)}, true
+}
+
+// recordRoleChange adds an RBAC change to the audit trail
+func recordRoleChange(r *http.Request, actor RBACActor, userID int, detail string) {
+	RecordAuthEvent(r, AuthEvent{EventType: EventRoleChange, UserID: userID, Actor: actor.Username, Success: true, Detail: detail})
+}
+
+// describePermission summarises a permission for the audit trail
+func describePermission(p *RolePermissions) string {
+	return fmt.Sprintf("%s (execute=%t read=%t write=%t delete=%t)", p.ResourceName, p.CanExecute, p.CanRead, p.CanWrite, p.CanDelete)
+}
+
+// pathID parses a numeric path parameter or writes a 400
+func pathID(w http.ResponseWriter, r *http.Request, name string) (int, bool) {
+	id, err := strconv.Atoi(router.Param(r, name))
+	if err != nil || id < 1 {
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid "+name)
+		return 0, false
+	}
+	return id, true
+}
+
+// writeCreated writes a 201 response
+func writeCreated(w http.ResponseWriter, r *http.Request, data interface{}) {
+	resp := handler.NewResponse(http.StatusCreated, "Created", data, GetRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// writeRBACError maps RBAC errors to responses
+func writeRBACError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrRoleNotFound), errors.Is(err, ErrPermissionNotFound),
+		errors.Is(err, ErrUserNotFound), errors.Is(err, ErrUserRoleNotFound):
+		writeAuthError(w, r, http.StatusNotFound, "Not Found", "NOT_FOUND", err.Error())
+	case errors.Is(err, ErrInvalidRole), errors.Is(err, ErrInvalidPermission), errors.Is(err, ErrTenantMismatch):
+		writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "VALIDATION_ERROR", err.Error())
+	case errors.Is(err, ErrSuperAdminRequired):
+		writeAuthError(w, r, http.StatusForbidden, "Forbidden", "SUPER_ADMIN_REQUIRED", err.Error())
+	case errors.Is(err, ErrRoleExists), errors.Is(err, ErrPermissionExists), errors.Is(err, ErrUserRoleExists),
+		errors.Is(err, ErrRoleInUse), errors.Is(err, ErrLastSuperAdmin):
+		writeAuthError(w, r, http.StatusConflict, "Conflict", "CONFLICT", err.Error())
+	default:
+		log.Printf("[error] - RBAC request: %v", err)
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "DATABASE_ERROR", "Database operation failed")
+	}
+}
@@ -2,13 +2,15 @@ package auth
 
 import (
 	"api/internal/db"
+	"database/sql"
+	"errors"
 	"fmt"
-	"time"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
-// RoleRepo represents the repository for role operations
+// RoleRepo represents the repository for roles, role permissions and user-role assignments
 type RoleRepo struct {
 	DB *db.DB
 }
@@ -19,146 +21,441 @@ func NewRoleRepo() *RoleRepo {
 	return &RoleRepo{DB: db}
 }
 
+// roleColumns are the roles columns read by scanRole
+const roleColumns = `role_id, role_name, COALESCE(role_desc, ''), is_super_admin, require_mfa,
+	created_at, created_by, updated_at, updated_by, status_id, tenant_id`
+
+// rolePermissionColumns are the role_permissions columns read by scanRolePermission
+const rolePermissionColumns = `role_permission_id, role_id, resource_type_id, resource_name,
+	can_execute, can_read, can_write, can_delete, methods, effect, created_at, created_by, updated_at, updated_by, status_id`
+
 // InsertRole inserts a new role into the database
 func (rr *RoleRepo) InsertRole(role *Role) (int64, error) {
-	result, err := rr.DB.Exec("INSERT INTO role (role_name, role_desc, is_super_admin, created_at, created_by, status_id) VALUES (?, ?, ?, ?, ?, ?)",
-		role.RoleName, role.RoleDesc, role.IsSuperAdmin, role.CreatedAt, role.CreatedBy, role.StatusID)
+	if role.TenantID == 0 {
+		role.TenantID = DefaultTenantID
+	}
+	result, err := rr.DB.Exec(`
+		INSERT INTO roles (role_name, role_desc, is_super_admin, require_mfa, created_at, created_by, updated_at, updated_by, status_id, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		role.RoleName, role.RoleDesc, role.IsSuperAdmin, role.RequireMFA, role.CreatedAt, role.CreatedBy, role.UpdatedAt, role.UpdatedBy, role.StatusID, role.TenantID)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("error inserting role: %w", err)
 	}
 	return result.LastInsertId()
 }
 
-// GetRoleByID retrieves a role by its ID
+// GetRoleByID retrieves a role by its ID; ErrRoleNotFound when there is none
 func (rr *RoleRepo) GetRoleByID(roleID int) (*Role, error) {
-	row, err := rr.DB.QueryRow("SELECT * FROM role WHERE role_id = ?", roleID)
-
+	row, err := rr.DB.QueryRow("SELECT "+roleColumns+" FROM roles WHERE role_id = ?", roleID)
 	if err != nil {
-		return nil, fmt.Errorf("error query: %v", err)
+		return nil, fmt.Errorf("error querying role: %w", err)
+	}
+	role, err := scanRole(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
 	}
+	return role, err
+}
 
-	var role Role
-	err = row.Scan(
-		&role.RoleID,
-		&role.RoleName,
-		&role.RoleDesc,
-		&role.IsSuperAdmin,
-		&role.CreatedAt,
-		&role.CreatedBy,
-		&role.StatusID,
-	)
+// GetRoleByName retrieves a role by its name, ignoring case; ErrRoleNotFound when there is none
+func (rr *RoleRepo) GetRoleByName(name string) (*Role, error) {
+	row, err := rr.DB.QueryRow("SELECT "+roleColumns+" FROM roles WHERE lower(role_name) = lower(?)", name)
+	if err != nil {
+		return nil, fmt.Errorf("error querying role: %w", err)
+	}
+	role, err := scanRole(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRoleNotFound
+	}
+	return role, err
+}
 
+// GetRoles lists the roles of a tenant whose name or description contains search, ordered by name
+func (rr *RoleRepo) GetRoles(tenantID int, search string, limit, offset int) ([]*Role, error) {
+	pattern := "%" + strings.ToLower(search) + "%"
+	rows, err := rr.DB.Query(`
+		SELECT `+roleColumns+` FROM roles
+		WHERE tenant_id = ? AND (lower(role_name) LIKE ? OR lower(COALESCE(role_desc, '')) LIKE ?)
+		ORDER BY role_name LIMIT ? OFFSET ?`,
+		tenantID, pattern, pattern, limit, offset)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error querying roles: %w", err)
 	}
+	defer rows.Close()
 
-	return &role, nil
+	roles := []*Role{}
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, err
+		}
+		roles = append(roles, role)
+	}
+	return roles, rows.Err()
+}
+
+// CountRoles counts the roles matched by GetRoles
+func (rr *RoleRepo) CountRoles(tenantID int, search string) (int, error) {
+	pattern := "%" + strings.ToLower(search) + "%"
+	row, err := rr.DB.QueryRow(`
+		SELECT COUNT(*) FROM roles
+		WHERE tenant_id = ? AND (lower(role_name) LIKE ? OR lower(COALESCE(role_desc, '')) LIKE ?)`,
+		tenantID, pattern, pattern)
+	if err != nil {
+		return 0, fmt.Errorf("error counting roles: %w", err)
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting roles: %w", err)
+	}
+	return count, nil
 }
 
 // UpdateRole updates an existing role
 func (rr *RoleRepo) UpdateRole(role *Role) (int64, error) {
-	result, err := rr.DB.Exec("UPDATE role SET role_name = ?, role_desc = ?, is_super_admin = ?, created_at = ?, created_by = ?, status_id = ? WHERE role_id = ?",
-		role.RoleName, role.RoleDesc, role.IsSuperAdmin, role.CreatedAt, role.CreatedBy, role.StatusID, role.RoleID)
+	result, err := rr.DB.Exec(`
+		UPDATE roles SET role_name = ?, role_desc = ?, is_super_admin = ?, require_mfa = ?, updated_at = ?, updated_by = ?, status_id = ?
+		WHERE role_id = ?`,
+		role.RoleName, role.RoleDesc, role.IsSuperAdmin, role.RequireMFA, role.UpdatedAt, role.UpdatedBy, role.StatusID, role.RoleID)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("error updating role: %w", err)
 	}
 	return result.RowsAffected()
 }
 
-// DeleteRole deletes a role by its ID
+// DeleteRole deletes a role and its permissions
 func (rr *RoleRepo) DeleteRole(roleID int) (int64, error) {
-	result, err := rr.DB.Exec("DELETE FROM role WHERE role_id = ?", roleID)
+	tx, err := rr.DB.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting role: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM role_permissions WHERE role_id = ?", roleID); err != nil {
+		return 0, fmt.Errorf("error deleting role permissions: %w", err)
+	}
+	result, err := tx.Exec("DELETE FROM roles WHERE role_id = ?", roleID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting role: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("error deleting role: %w", err)
+	}
+	return affected, tx.Commit()
+}
+
+// InsertRolePermission grants a role access to a resource
+func (rr *RoleRepo) InsertRolePermission(permission *RolePermissions) (int64, error) {
+	result, err := rr.DB.Exec(`
+		INSERT INTO role_permissions (role_id, resource_type_id, resource_name, can_execute, can_read, can_write, can_delete,
+			methods, effect, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		permission.RoleID, permission.ResourceTypeID, permission.ResourceName,
+		permission.CanExecute, permission.CanRead, permission.CanWrite, permission.CanDelete,
+		strings.Join(permission.Methods, ","), permission.Effect,
+		permission.CreatedAt, permission.CreatedBy, permission.UpdatedAt, permission.UpdatedBy, permission.StatusID)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting role permission: %w", err)
+	}
+	return result.LastInsertId()
+}
+
+// GetRolePermission retrieves one permission of a role; ErrPermissionNotFound when there is none
+func (rr *RoleRepo) GetRolePermission(roleID, rolePermissionID int) (*RolePermissions, error) {
+	row, err := rr.DB.QueryRow("SELECT "+rolePermissionColumns+" FROM role_permissions WHERE role_id = ? AND role_permission_id = ?",
+		roleID, rolePermissionID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying role permission: %w", err)
+	}
+	permission, err := scanRolePermission(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPermissionNotFound
+	}
+	return permission, err
+}
+
+// GetRolePermissionByResource retrieves the allow or deny rule of a role on a resource;
+// ErrPermissionNotFound when there is none
+func (rr *RoleRepo) GetRolePermissionByResource(roleID, resourceTypeID int, resourceName, effect string) (*RolePermissions, error) {
+	row, err := rr.DB.QueryRow("SELECT "+rolePermissionColumns+" FROM role_permissions WHERE role_id = ? AND resource_type_id = ? AND resource_name = ? AND effect = ?",
+		roleID, resourceTypeID, resourceName, effect)
+	if err != nil {
+		return nil, fmt.Errorf("error querying role permission: %w", err)
+	}
+	permission, err := scanRolePermission(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPermissionNotFound
+	}
+	return permission, err
+}
+
+// GetRolePermissions lists the permissions of a role, ordered by resource
+func (rr *RoleRepo) GetRolePermissions(roleID int) ([]*RolePermissions, error) {
+	rows, err := rr.DB.Query("SELECT "+rolePermissionColumns+" FROM role_permissions WHERE role_id = ? ORDER BY resource_type_id, resource_name", roleID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying role permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions := []*RolePermissions{}
+	for rows.Next() {
+		permission, err := scanRolePermission(rows)
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, permission)
+	}
+	return permissions, rows.Err()
+}
+
+// UpdateRolePermission updates the flags of a role permission
+func (rr *RoleRepo) UpdateRolePermission(permission *RolePermissions) (int64, error) {
+	result, err := rr.DB.Exec(`
+		UPDATE role_permissions SET resource_type_id = ?, resource_name = ?, can_execute = ?, can_read = ?, can_write = ?, can_delete = ?,
+			methods = ?, effect = ?, updated_at = ?, updated_by = ?, status_id = ?
+		WHERE role_id = ? AND role_permission_id = ?`,
+		permission.ResourceTypeID, permission.ResourceName,
+		permission.CanExecute, permission.CanRead, permission.CanWrite, permission.CanDelete,
+		strings.Join(permission.Methods, ","), permission.Effect,
+		permission.UpdatedAt, permission.UpdatedBy, permission.StatusID, permission.RoleID, permission.RolePermissionID)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("error updating role permission: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// DeleteRolePermission removes a permission from a role
+func (rr *RoleRepo) DeleteRolePermission(roleID, rolePermissionID int) (int64, error) {
+	result, err := rr.DB.Exec("DELETE FROM role_permissions WHERE role_id = ? AND role_permission_id = ?", roleID, rolePermissionID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting role permission: %w", err)
 	}
 	return result.RowsAffected()
 }
 
 // InsertUserRole inserts a new user-role relationship
 func (rr *RoleRepo) InsertUserRole(userRole *UserRoles) (int64, error) {
-	result, err := rr.DB.Exec("INSERT INTO user_roles (role_id, user_id, created_at, created_by) VALUES (?, ?, ?, ?)",
-		userRole.RoleID, userRole.UserID, time.Now(), userRole.CreatedBy)
+	result, err := rr.DB.Exec(`
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		userRole.RoleID, userRole.UserID, userRole.CreatedAt, userRole.CreatedBy, userRole.UpdatedAt, userRole.UpdatedBy, userRole.StatusID)
 	if err != nil {
-		return 0, err
+		return 0, fmt.Errorf("error inserting user role: %w", err)
 	}
 	return result.LastInsertId()
 }
 
-// GetUserRoleByID retrieves a user role by its ID
-func (rr *RoleRepo) GetUserRoleByID(userRoleID int) (*UserRoles, error) {
-	row, err := rr.DB.QueryRow("SELECT * FROM user_roles WHERE user_role_id = ?", userRoleID)
-
+// HasUserRole reports whether a user holds a role
+func (rr *RoleRepo) HasUserRole(userID, roleID int) (bool, error) {
+	row, err := rr.DB.QueryRow("SELECT COUNT(*) FROM user_roles WHERE user_id = ? AND role_id = ?", userID, roleID)
 	if err != nil {
-		return nil, err
+		return false, fmt.Errorf("error querying user role: %w", err)
 	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error querying user role: %w", err)
+	}
+	return count > 0, nil
+}
 
-	var userRole UserRoles
-	err = row.Scan(
-		&userRole.UserRoleID,
-		&userRole.RoleID,
-		&userRole.UserID,
-		&userRole.CreatedAt,
-		&userRole.CreatedBy,
-	)
+// DeleteUserRole removes a role from a user
+func (rr *RoleRepo) DeleteUserRole(userID, roleID int) (int64, error) {
+	result, err := rr.DB.Exec("DELETE FROM user_roles WHERE user_id = ? AND role_id = ?", userID, roleID)
+	if err != nil {
+		return 0, fmt.Errorf("error deleting user role: %w", err)
+	}
+	return result.RowsAffected()
+}
 
+// CountRoleUsers counts the users holding a role
+func (rr *RoleRepo) CountRoleUsers(roleID int) (int, error) {
+	row, err := rr.DB.QueryRow("SELECT COUNT(DISTINCT user_id) FROM user_roles WHERE role_id = ?", roleID)
 	if err != nil {
-		return nil, err
+		return 0, fmt.Errorf("error counting role users: %w", err)
 	}
-	return &userRole, nil
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting role users: %w", err)
+	}
+	return count, nil
 }
 
-// GetUserRoleByID retrieves a user role by its ID
-func (rr *RoleRepo) GetUserRoleByUserID(userID int) ([]*UserRoles, error) {
-	rows, err := rr.DB.Query("SELECT * FROM user_roles WHERE user_id = ?", userID)
+// CountSuperAdmins counts the users holding a super admin role, leaving out the
+// assignments of exceptRoleID; with exceptUserID set, only that user's assignment
+// of the role is left out.
+func (rr *RoleRepo) CountSuperAdmins(exceptRoleID, exceptUserID int) (int, error) {
+	row, err := rr.DB.QueryRow(`
+		SELECT COUNT(DISTINCT ur.user_id) FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE r.is_super_admin = 1 AND NOT (ur.role_id = ? AND (? = 0 OR ur.user_id = ?))`,
+		exceptRoleID, exceptUserID, exceptUserID)
+	if err != nil {
+		return 0, fmt.Errorf("error counting super admins: %w", err)
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, fmt.Errorf("error counting super admins: %w", err)
+	}
+	return count, nil
+}
 
+// GetRoleIDsByUserID lists the ids of the roles held by a user
+func (rr *RoleRepo) GetRoleIDsByUserID(userID int) ([]int, error) {
+	rows, err := rr.DB.Query("SELECT DISTINCT role_id FROM user_roles WHERE user_id = ?", userID)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("error querying user roles: %w", err)
+	}
+	defer rows.Close()
+
+	var roleIDs []int
+	for rows.Next() {
+		var roleID int
+		if err := rows.Scan(&roleID); err != nil {
+			return nil, fmt.Errorf("error scanning user role: %w", err)
+		}
+		roleIDs = append(roleIDs, roleID)
 	}
+	return roleIDs, rows.Err()
+}
 
+// GetRolesByUserID lists the roles held by a user
+func (rr *RoleRepo) GetRolesByUserID(userID int) ([]*Role, error) {
+	rows, err := rr.DB.Query(`
+		SELECT r.role_id, r.role_name, COALESCE(r.role_desc, ''), r.is_super_admin, r.require_mfa,
+			r.created_at, r.created_by, r.updated_at, r.updated_by, r.status_id, r.tenant_id
+		FROM user_roles ur JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.user_id = ? ORDER BY r.role_name`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying user roles: %w", err)
+	}
 	defer rows.Close()
-	var userRoles []*UserRoles
+
+	roles := []*Role{}
 	for rows.Next() {
-		var userRole UserRoles
-		err := rows.Scan(
-			&userRole.UserRoleID,
-			&userRole.RoleID,
-			&userRole.UserID,
-			&userRole.CreatedAt,
-			&userRole.CreatedBy,
-		)
+		role, err := scanRole(rows)
 		if err != nil {
 			return nil, err
 		}
-		userRoles = append(userRoles, &userRole)
+		roles = append(roles, role)
 	}
-	return userRoles, nil
+	return roles, rows.Err()
 }
 
-// GetUserRoleResolveByID retrieves a user role by its ID
-func (rr *RoleRepo) GetUserIsSuperAdminByUserID(userID int) (bool, error) {
-	row, err := rr.DB.QueryRow(`select sum(is_super_admin) as total_super_admin
-	from vw_user_role_permissions 
-	where user_id =?`, userID)
-
-	isSuperAdmin := false
+// GetEffectivePermissions combines the permissions of all roles of a user per resource
+// pattern, effect and method list
+func (rr *RoleRepo) GetEffectivePermissions(userID int) ([]*EffectivePermission, error) {
+	rows, err := rr.DB.Query(`
+		SELECT rp.resource_type_id, rp.resource_name, rp.effect, rp.methods,
+			MAX(rp.can_execute), MAX(rp.can_read), MAX(rp.can_write), MAX(rp.can_delete),
+			GROUP_CONCAT(r.role_name, ',')
+		FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		JOIN role_permissions rp ON r.role_id = rp.role_id
+		WHERE ur.user_id = ?
+		GROUP BY rp.resource_type_id, rp.resource_name, rp.effect, rp.methods
+		ORDER BY rp.resource_type_id, rp.resource_name, rp.effect`, userID)
 	if err != nil {
-		return isSuperAdmin, err
+		return nil, fmt.Errorf("error querying effective permissions: %w", err)
 	}
+	defer rows.Close()
 
-	totalSuperAdmin := 0
-
-	err = row.Scan(
-		&totalSuperAdmin,
-	)
+	permissions := []*EffectivePermission{}
+	for rows.Next() {
+		var permission EffectivePermission
+		var methods, roles string
+		if err := rows.Scan(
+			&permission.ResourceTypeID,
+			&permission.ResourceName,
+			&permission.Effect,
+			&methods,
+			&permission.CanExecute,
+			&permission.CanRead,
+			&permission.CanWrite,
+			&permission.CanDelete,
+			&roles,
+		); err != nil {
+			return nil, fmt.Errorf("error scanning effective permission: %w", err)
+		}
+		if methods != "" {
+			permission.Methods = strings.Split(methods, ",")
+		}
+		permission.Roles = strings.Split(roles, ",")
+		permissions = append(permissions, &permission)
+	}
+	return permissions, rows.Err()
+}
 
+// GetUserIsSuperAdminByUserID reports whether a user holds a super admin role
+func (rr *RoleRepo) GetUserIsSuperAdminByUserID(userID int) (bool, error) {
+	row, err := rr.DB.QueryRow(`
+		SELECT COUNT(*) FROM user_roles ur
+		JOIN roles r ON ur.role_id = r.role_id
+		WHERE ur.user_id = ? AND r.is_super_admin = 1`, userID)
 	if err != nil {
-		return isSuperAdmin, err
+		return false, fmt.Errorf("error querying super admin: %w", err)
 	}
-
-	if totalSuperAdmin > 0 {
-		isSuperAdmin = true
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error querying super admin: %w", err)
 	}
+	return count > 0, nil
+}
 
-	return isSuperAdmin, nil
+// scanRole scans a row selected with roleColumns
+func scanRole(row interface{ Scan(...any) error }) (*Role, error) {
+	var role Role
+	err := row.Scan(
+		&role.RoleID,
+		&role.RoleName,
+		&role.RoleDesc,
+		&role.IsSuperAdmin,
+		&role.RequireMFA,
+		&role.CreatedAt,
+		&role.CreatedBy,
+		&role.UpdatedAt,
+		&role.UpdatedBy,
+		&role.StatusID,
+		&role.TenantID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning role: %w", err)
+	}
+	return &role, nil
 }
 
+// scanRolePermission scans a row selected with rolePermissionColumns
+func scanRolePermission(row interface{ Scan(...any) error }) (*RolePermissions, error) {
+	var permission RolePermissions
+	var methods string
+	err := row.Scan(
+		&permission.RolePermissionID,
+		&permission.RoleID,
+		&permission.ResourceTypeID,
+		&permission.ResourceName,
+		&permission.CanExecute,
+		&permission.CanRead,
+		&permission.CanWrite,
+		&permission.CanDelete,
+		&methods,
+		&permission.Effect,
+		&permission.CreatedAt,
+		&permission.CreatedBy,
+		&permission.UpdatedAt,
+		&permission.UpdatedBy,
+		&permission.StatusID,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning role permission: %w", err)
+	}
+	if methods != "" {
+		permission.Methods = strings.Split(methods, ",")
+	}
+	return &permission, nil
+}
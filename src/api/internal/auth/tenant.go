@@ -0,0 +1,34 @@
+package auth
+
+import "context"
+
+const (
+	// DefaultTenantID is the root tenant. Rows and tokens created before tenants
+	// existed belong to it, and only its super admins may provision other tenants.
+	DefaultTenantID = 1
+	// tenantContextKey stores the tenant of the authenticated request
+	tenantContextKey = ContextKey("tenant_id")
+)
+
+// WithTenantID stores the tenant of an authenticated request in a context
+func WithTenantID(ctx context.Context, tenantID int) context.Context {
+	return context.WithValue(ctx, tenantContextKey, tenantID)
+}
+
+// TenantIDFromContext returns the tenant of an authenticated request, if any
+func TenantIDFromContext(ctx context.Context) (int, bool) {
+	tenantID, ok := ctx.Value(tenantContextKey).(int)
+	return tenantID, ok && tenantID > 0
+}
+
+// requestTenantID returns the tenant of a request, falling back to the token's claims
+// for routes that do not resolve the tenant in middleware
+func requestTenantID(ctx context.Context, claims *JwtClaims) int {
+	if tenantID, ok := TenantIDFromContext(ctx); ok {
+		return tenantID
+	}
+	if claims != nil {
+		return claims.Tenant()
+	}
+	return DefaultTenantID
+}
@@ -0,0 +1,460 @@
+package auth
+
+import (
+	"api/config"
+	"api/internal/cache"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+)
+
+// Refresh token errors
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token was already used; the session has been revoked")
+)
+
+const (
+	// refreshTokenBytes is the entropy of a refresh token value
+	refreshTokenBytes = 32
+	// revokedKeyPrefix prefixes revocation entries in the cache
+	revokedKeyPrefix = "revoked:"
+	// notRevokedTTL bounds how long a "not revoked" answer is cached, which is how
+	// late an instance without a shared cache may notice a revocation made elsewhere
+	notRevokedTTL = 30 * time.Second
+)
+
+// RefreshToken is a stored refresh token. Tokens issued from one login share a
+// family; each refresh uses up the presented token and issues the next one.
+type RefreshToken struct {
+	TokenID         string
+	FamilyID        string
+	UserID          int
+	Username        string
+	TokenHash       string
+	IssuedAt        time.Time
+	ExpiresAt       time.Time
+	UsedAt          *time.Time
+	RevokedAt       *time.Time
+	ReplacedBy      string
+	AccessJTI       string
+	AccessExpiresAt time.Time
+	// AMR is how the user authenticated at login; refreshed access tokens keep it
+	AMR []string
+	// ClientID and Scope are set on tokens issued to an OAuth client
+	ClientID string
+	Scope    string
+}
+
+// RefreshRequest is the body of a token refresh or logout request
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RevocationCache is the part of the cache used for the access token revocation list
+type RevocationCache interface {
+	Get(key string) (string, error)
+	SetWithTTL(key, value string, ttl time.Duration) error
+}
+
+// TokenService issues access and refresh tokens and keeps the revocation list
+type TokenService struct {
+	repo       *TokenRepo
+	cache      RevocationCache
+	keys       *KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+	now        func() time.Time
+	// tenantOf resolves the tenant put in access tokens; nil issues tokens for the default tenant
+	tenantOf func(userID int) (int, error)
+}
+
+var (
+	tokenServiceOnce     sync.Once
+	tokenServiceInstance *TokenService
+)
+
+// NewTokenService creates a TokenService from the configured secret and token ages
+func NewTokenService() *TokenService {
+	cfg := config.NewConfig()
+	service := NewTokenServiceWithRepo(
+		NewTokenRepo(),
+		cache.NewCache(cache.IntToCacheBackend(viper.GetInt("CACHE_PROVIDER"))),
+		GetKeySet(),
+		time.Duration(cfg.TokenAge)*time.Minute,
+		time.Duration(cfg.RefreshTokenAge)*time.Minute,
+	)
+	service.SetTenantResolver(NewUserRepo().GetUserTenantID)
+	return service
+}
+
+// SetTenantResolver sets how the tenant of a user is looked up when tokens are issued
+func (s *TokenService) SetTenantResolver(tenantOf func(userID int) (int, error)) {
+	s.tenantOf = tenantOf
+}
+
+// NewTokenServiceWithRepo creates a TokenService with explicit dependencies
+func NewTokenServiceWithRepo(repo *TokenRepo, revocations RevocationCache, keys *KeySet, accessTTL, refreshTTL time.Duration) *TokenService {
+	return &TokenService{
+		repo:       repo,
+		cache:      revocations,
+		keys:       keys,
+		accessTTL:  accessTTL,
+		refreshTTL: refreshTTL,
+		now:        time.Now,
+	}
+}
+
+// GetTokenService returns the shared TokenService used by the handlers and JWTMiddleware
+func GetTokenService() *TokenService {
+	tokenServiceOnce.Do(func() {
+		tokenServiceInstance = NewTokenService()
+	})
+	return tokenServiceInstance
+}
+
+// IssueTokens starts a new token family for a user who just logged in with the
+// authentication methods in amr
+func (s *TokenService) IssueTokens(userID int, username string, amr []string) (*JwtToken, error) {
+	return s.issue(tokenGrant{UserID: userID, Username: username, AMR: amr}, uuid.NewString(), uuid.NewString())
+}
+
+// IssueClientTokens starts a new token family for a user who authorized an OAuth client
+// with scope. Its refresh tokens can only be exchanged by the same client.
+func (s *TokenService) IssueClientTokens(userID int, username string, amr []string, clientID, scope string) (*JwtToken, error) {
+	grant := tokenGrant{UserID: userID, Username: username, AMR: amr, ClientID: clientID, Scope: scope}
+	return s.issue(grant, uuid.NewString(), uuid.NewString())
+}
+
+// IssueClientAccessToken issues an access token without a refresh token to an OAuth
+// client, for clients that may not refresh or act on behalf of the user that owns them
+func (s *TokenService) IssueClientAccessToken(userID int, username string, amr []string, clientID, scope string) (*JwtToken, error) {
+	grant := tokenGrant{UserID: userID, Username: username, AMR: amr, ClientID: clientID, Scope: scope}
+	claims, accessToken, err := s.signAccessToken(grant, s.now())
+	if err != nil {
+		return nil, err
+	}
+	return &JwtToken{Token: accessToken, ExpiredAt: claims.ExpiresAt}, nil
+}
+
+// IssueImpersonationToken issues an access token, without a refresh token, for actor to
+// act as a user. It lasts ttl, and amr is how the actor authenticated.
+func (s *TokenService) IssueImpersonationToken(userID int, username string, amr []string, actor *ActorClaim, ttl time.Duration) (*JwtToken, error) {
+	grant := tokenGrant{UserID: userID, Username: username, AMR: amr, Actor: actor, TTL: ttl}
+	claims, accessToken, err := s.signAccessToken(grant, s.now())
+	if err != nil {
+		return nil, err
+	}
+	return &JwtToken{Token: accessToken, ExpiredAt: claims.ExpiresAt}, nil
+}
+
+// Refresh exchanges a refresh token for a new access and refresh token.
+// Presenting a token that was already exchanged revokes its whole family,
+// since either the legitimate client or an attacker holds a stolen copy.
+func (s *TokenService) Refresh(value string) (*JwtToken, error) {
+	stored, err := s.lookupRefreshToken(value, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.refresh(stored)
+}
+
+// RefreshClient exchanges a refresh token issued to an OAuth client and returns the
+// scope of the new tokens. Tokens issued to another client or by a login, and requests
+// for a scope wider than the one granted, are rejected without using the token up.
+func (s *TokenService) RefreshClient(value, clientID, scope string) (*JwtToken, string, error) {
+	stored, err := s.lookupRefreshToken(value, clientID)
+	if err != nil {
+		return nil, "", err
+	}
+	if scope != "" && !scopeCovers(stored.Scope, scope) {
+		return nil, "", ErrScopeNotGranted
+	}
+	tokens, err := s.refresh(stored)
+	if err != nil {
+		return nil, "", err
+	}
+	return tokens, stored.Scope, nil
+}
+
+// lookupRefreshToken returns a stored refresh token issued to clientID, which is empty
+// for tokens issued by a login
+func (s *TokenService) lookupRefreshToken(value, clientID string) (*RefreshToken, error) {
+	if value == "" {
+		return nil, ErrInvalidRefreshToken
+	}
+	stored, err := s.repo.GetRefreshTokenByHash(hashRefreshToken(value))
+	if err != nil {
+		return nil, err
+	}
+	if stored.ClientID != clientID {
+		return nil, ErrInvalidRefreshToken
+	}
+	return stored, nil
+}
+
+// refresh uses up a stored refresh token and issues the next tokens of its family
+func (s *TokenService) refresh(stored *RefreshToken) (*JwtToken, error) {
+	now := s.now()
+	if stored.RevokedAt != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+	if stored.UsedAt != nil {
+		return nil, s.revokeReused(stored)
+	}
+	if !now.Before(stored.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	next := uuid.NewString()
+	claimed, err := s.repo.MarkRefreshTokenUsed(stored.TokenID, next, now)
+	if err != nil {
+		return nil, err
+	}
+	if !claimed {
+		// Another request exchanged the same token first
+		return nil, s.revokeReused(stored)
+	}
+
+	grant := tokenGrant{UserID: stored.UserID, Username: stored.Username, AMR: stored.AMR, ClientID: stored.ClientID, Scope: stored.Scope}
+	return s.issue(grant, stored.FamilyID, next)
+}
+
+// Revoke ends a session: the access token is added to the revocation list and,
+// when a refresh token is given, its family can no longer be refreshed
+func (s *TokenService) Revoke(claims *JwtClaims, refreshToken string) error {
+	if claims != nil && claims.Id != "" {
+		if err := s.revokeAccessToken(claims.Id, claims.UserID, time.Unix(claims.ExpiresAt, 0), "logout"); err != nil {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+
+	stored, err := s.repo.GetRefreshTokenByHash(hashRefreshToken(refreshToken))
+	if errors.Is(err, ErrInvalidRefreshToken) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.revokeFamily(stored.FamilyID, "logout")
+}
+
+// RevokeUser ends every session of a user, e.g. after a password reset
+func (s *TokenService) RevokeUser(userID int, reason string) error {
+	families, err := s.repo.GetActiveFamilies(userID)
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := s.revokeFamily(family, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// IsRevoked reports whether an access token id is on the revocation list. The cache is
+// consulted first; on a miss the database answers and the result is cached.
+func (s *TokenService) IsRevoked(jti string) (bool, error) {
+	if jti == "" {
+		return false, nil
+	}
+	if s.cache != nil {
+		if value, err := s.cache.Get(revokedKeyPrefix + jti); err == nil {
+			return value == "1", nil
+		}
+	}
+
+	revoked, err := s.repo.IsTokenRevoked(jti)
+	if err != nil {
+		return false, err
+	}
+	if !revoked {
+		s.cacheRevocation(jti, "0", notRevokedTTL)
+	}
+	return revoked, nil
+}
+
+// ParseAccessToken verifies an access token signed by this service and returns its claims
+func (s *TokenService) ParseAccessToken(tokenString string) (*JwtClaims, error) {
+	return verifyToken(s.keys, tokenString)
+}
+
+// IsTokenRevoked reports whether an access token id was revoked. Lookup failures
+// count as revoked so that an outage cannot resurrect a logged out session.
+func IsTokenRevoked(jti string) bool {
+	revoked, err := GetTokenService().IsRevoked(jti)
+	if err != nil {
+		log.Printf("[error] - Check token revocation: %v", err)
+		return true
+	}
+	return revoked
+}
+
+// tokenGrant is what a token family is issued for: a user and, for tokens issued
+// to an OAuth client, the client and the scope it was granted
+type tokenGrant struct {
+	UserID   int
+	Username string
+	AMR      []string
+	ClientID string
+	Scope    string
+	// Actor and TTL are set on impersonation tokens
+	Actor *ActorClaim
+	TTL   time.Duration
+}
+
+// issue creates an access token and the refresh token tokenID that can replace it
+func (s *TokenService) issue(grant tokenGrant, familyID, tokenID string) (*JwtToken, error) {
+	now := s.now()
+	claims, accessToken, err := s.signAccessToken(grant, now)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := newRefreshTokenValue()
+	if err != nil {
+		return nil, err
+	}
+	refresh := &RefreshToken{
+		TokenID:         tokenID,
+		FamilyID:        familyID,
+		UserID:          grant.UserID,
+		Username:        grant.Username,
+		TokenHash:       hashRefreshToken(value),
+		IssuedAt:        now,
+		ExpiresAt:       now.Add(s.refreshTTL),
+		AccessJTI:       claims.Id,
+		AccessExpiresAt: time.Unix(claims.ExpiresAt, 0),
+		AMR:             grant.AMR,
+		ClientID:        grant.ClientID,
+		Scope:           grant.Scope,
+	}
+	if err := s.repo.InsertRefreshToken(refresh); err != nil {
+		return nil, err
+	}
+
+	return &JwtToken{
+		Token:            accessToken,
+		ExpiredAt:        claims.ExpiresAt,
+		RefreshToken:     value,
+		RefreshExpiredAt: refresh.ExpiresAt.Unix(),
+	}, nil
+}
+
+// signAccessToken creates the access token of a grant
+func (s *TokenService) signAccessToken(grant tokenGrant, now time.Time) (*JwtClaims, string, error) {
+	tenantID := DefaultTenantID
+	if s.tenantOf != nil {
+		id, err := s.tenantOf(grant.UserID)
+		if err != nil {
+			return nil, "", fmt.Errorf("error resolving tenant: %w", err)
+		}
+		tenantID = id
+	}
+
+	ttl := s.accessTTL
+	if grant.TTL > 0 {
+		ttl = grant.TTL
+	}
+	claims := &JwtClaims{
+		UserID:   grant.UserID,
+		Username: grant.Username,
+		AMR:      grant.AMR,
+		TenantID: tenantID,
+		ClientID: grant.ClientID,
+		Scope:    grant.Scope,
+		Act:      grant.Actor,
+		StandardClaims: jwt.StandardClaims{
+			Id:        uuid.NewString(),
+			Subject:   strconv.Itoa(grant.UserID),
+			IssuedAt:  now.Unix(),
+			ExpiresAt: now.Add(ttl).Unix(),
+		},
+	}
+	accessToken, err := s.keys.Sign(claims)
+	if err != nil {
+		return nil, "", fmt.Errorf("error signing access token: %w", err)
+	}
+	return claims, accessToken, nil
+}
+
+// revokeReused revokes the family of a refresh token presented twice and reports the reuse
+func (s *TokenService) revokeReused(token *RefreshToken) error {
+	log.Printf("[warn] - Refresh token reuse for user %s, revoking token family %s", token.Username, token.FamilyID)
+	if err := s.revokeFamily(token.FamilyID, "refresh token reuse"); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// revokeFamily revokes every refresh token of a family and the access tokens still live from it
+func (s *TokenService) revokeFamily(familyID, reason string) error {
+	now := s.now()
+	if _, err := s.repo.RevokeFamily(familyID, now); err != nil {
+		return err
+	}
+
+	live, err := s.repo.GetLiveAccessTokens(familyID, now)
+	if err != nil {
+		return err
+	}
+	for _, token := range live {
+		if err := s.revokeAccessToken(token.AccessJTI, token.UserID, token.AccessExpiresAt, reason); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// revokeAccessToken puts a jti on the revocation list until the token would have expired anyway
+func (s *TokenService) revokeAccessToken(jti string, userID int, expiresAt time.Time, reason string) error {
+	now := s.now()
+	if !now.Before(expiresAt) {
+		return nil
+	}
+	if err := s.repo.InsertRevokedToken(jti, userID, expiresAt, now, reason); err != nil {
+		return err
+	}
+	s.cacheRevocation(jti, "1", expiresAt.Sub(now))
+	return nil
+}
+
+// cacheRevocation records a revocation answer; cache failures only cost a database lookup
+func (s *TokenService) cacheRevocation(jti, value string, ttl time.Duration) {
+	if s.cache == nil {
+		return
+	}
+	if err := s.cache.SetWithTTL(revokedKeyPrefix+jti, value, ttl); err != nil {
+		log.Printf("[error] - Cache token revocation: %v", err)
+	}
+}
+
+// newRefreshTokenValue returns a random, URL-safe refresh token
+func newRefreshTokenValue() (string, error) {
+	value := make([]byte, refreshTokenBytes)
+	if _, err := rand.Read(value); err != nil {
+		return "", fmt.Errorf("error generating refresh token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// hashRefreshToken returns the SHA-256 of a refresh token as stored in the database
+func hashRefreshToken(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
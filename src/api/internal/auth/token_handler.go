@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"api/internal/handler"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+const (
+	// refreshCookieName is the cookie holding the refresh token for browser clients
+	refreshCookieName = "refresh_token"
+	// refreshCookiePath limits the refresh cookie to the token and logout endpoints' common prefix
+	refreshCookiePath = "/api"
+)
+
+// RefreshTokenHandler godoc
+// @Summary Refresh tokens
+// @Description Exchange a refresh token for a new access token and refresh token. Each refresh token can be used once; reusing one revokes the whole session.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body RefreshRequest false "Refresh token (or the refresh_token cookie)"
+// @Success 200 {object} JwtToken
+// @Failure 401 {object} types.ErrorResponse
+// @Router /token/refresh [post]
+func RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	cors(w, r)
+
+	var body RefreshRequest
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			writeAuthError(w, r, http.StatusBadRequest, "Bad Request", "INVALID_REQUEST", "Invalid request body")
+			return
+		}
+	}
+	if body.RefreshToken == "" {
+		if cookie, err := r.Cookie(refreshCookieName); err == nil {
+			body.RefreshToken = cookie.Value
+		}
+	}
+
+	tokens, err := GetTokenService().Refresh(body.RefreshToken)
+	switch {
+	case errors.Is(err, ErrRefreshTokenReused):
+		RecordAuthEvent(r, AuthEvent{EventType: EventTokenReuse, Detail: "refresh token family revoked"})
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "REFRESH_TOKEN_REUSED", err.Error())
+		return
+	case errors.Is(err, ErrInvalidRefreshToken):
+		writeAuthError(w, r, http.StatusUnauthorized, "Unauthorized", "INVALID_REFRESH_TOKEN", err.Error())
+		return
+	case err != nil:
+		writeAuthError(w, r, http.StatusInternalServerError, "Internal Server Error", "TOKEN_GENERATION_FAILED", "Failed to refresh tokens")
+		return
+	}
+
+	if claims, err := GetTokenService().ParseAccessToken(tokens.Token); err == nil {
+		RecordAuthEvent(r, AuthEvent{EventType: EventTokenRefresh, UserID: claims.UserID, Username: claims.Username, Success: true})
+	}
+	setTokenCookies(w, tokens)
+
+	resp := handler.NewResponse(http.StatusOK, "Success", tokens, GetRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
+
+// setTokenCookies stores a freshly issued token pair in cookies
+func setTokenCookies(w http.ResponseWriter, tokens *JwtToken) {
+	http.SetCookie(w, &http.Cookie{
+		Name:    "token",
+		Value:   tokens.Token,
+		Expires: time.Unix(tokens.ExpiredAt, 0),
+	})
+	http.SetCookie(w, &http.Cookie{
+		Name:     refreshCookieName,
+		Value:    tokens.RefreshToken,
+		Path:     refreshCookiePath,
+		Expires:  time.Unix(tokens.RefreshExpiredAt, 0),
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// writeAuthError writes an error response in the format used by the auth handlers
+func writeAuthError(w http.ResponseWriter, r *http.Request, status int, title, code, message string) {
+	resp := handler.NewErrorResponse(status, title, code, message, GetRequestID(r))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(resp.Status)
+	json.NewEncoder(w).Encode(resp)
+}
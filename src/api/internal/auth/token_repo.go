@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// TokenRepo represents the repository for refresh tokens and revoked access tokens
+type TokenRepo struct {
+	DB *db.DB
+}
+
+// NewTokenRepo creates a new instance of TokenRepo
+func NewTokenRepo() *TokenRepo {
+	db := db.NewDB()
+	return &TokenRepo{DB: db}
+}
+
+// InsertRefreshToken stores a refresh token; only its hash is kept
+func (tr *TokenRepo) InsertRefreshToken(token *RefreshToken) error {
+	_, err := tr.DB.Insert(`
+		INSERT INTO refresh_tokens (
+			token_id, family_id, user_id, user_name, token_hash, issued_at, expires_at,
+			access_jti, access_expires_at, amr, client_id, scope
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.TokenID,
+		token.FamilyID,
+		token.UserID,
+		token.Username,
+		token.TokenHash,
+		token.IssuedAt.UTC(),
+		token.ExpiresAt.UTC(),
+		token.AccessJTI,
+		token.AccessExpiresAt.UTC(),
+		strings.Join(token.AMR, " "),
+		token.ClientID,
+		token.Scope,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenByHash retrieves a refresh token by the hash of its value
+func (tr *TokenRepo) GetRefreshTokenByHash(hash string) (*RefreshToken, error) {
+	row, err := tr.DB.QueryRow(`
+		SELECT token_id, family_id, user_id, user_name, token_hash, issued_at, expires_at,
+			used_at, revoked_at, COALESCE(replaced_by, ''), amr, client_id, scope
+		FROM refresh_tokens
+		WHERE token_hash = ?`,
+		hash,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying refresh token: %w", err)
+	}
+
+	var token RefreshToken
+	var usedAt, revokedAt sql.NullTime
+	var amr string
+	err = row.Scan(
+		&token.TokenID,
+		&token.FamilyID,
+		&token.UserID,
+		&token.Username,
+		&token.TokenHash,
+		&token.IssuedAt,
+		&token.ExpiresAt,
+		&usedAt,
+		&revokedAt,
+		&token.ReplacedBy,
+		&amr,
+		&token.ClientID,
+		&token.Scope,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning refresh token: %w", err)
+	}
+	token.AMR = strings.Fields(amr)
+	if usedAt.Valid {
+		token.UsedAt = &usedAt.Time
+	}
+	if revokedAt.Valid {
+		token.RevokedAt = &revokedAt.Time
+	}
+	return &token, nil
+}
+
+// MarkRefreshTokenUsed records that a token was exchanged for replacedBy.
+// It returns false when the token was already used or revoked, so of two
+// concurrent refreshes with the same token only one succeeds.
+func (tr *TokenRepo) MarkRefreshTokenUsed(tokenID, replacedBy string, at time.Time) (bool, error) {
+	result, err := tr.DB.Update(`
+		UPDATE refresh_tokens SET used_at = ?, replaced_by = ?
+		WHERE token_id = ? AND used_at IS NULL AND revoked_at IS NULL`,
+		at.UTC(), replacedBy, tokenID,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error marking refresh token used: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error marking refresh token used: %w", err)
+	}
+	return affected == 1, nil
+}
+
+// GetLiveAccessTokens returns the refresh tokens of a family whose access token has not expired yet
+func (tr *TokenRepo) GetLiveAccessTokens(familyID string, now time.Time) ([]*RefreshToken, error) {
+	rows, err := tr.DB.Query(`
+		SELECT token_id, user_id, access_jti, access_expires_at
+		FROM refresh_tokens
+		WHERE family_id = ? AND access_expires_at > ?`,
+		familyID, now.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	var tokens []*RefreshToken
+	for rows.Next() {
+		token := RefreshToken{FamilyID: familyID}
+		if err := rows.Scan(&token.TokenID, &token.UserID, &token.AccessJTI, &token.AccessExpiresAt); err != nil {
+			return nil, fmt.Errorf("error scanning access token: %w", err)
+		}
+		tokens = append(tokens, &token)
+	}
+	return tokens, rows.Err()
+}
+
+// GetActiveFamilies returns the token families of a user that are not revoked
+func (tr *TokenRepo) GetActiveFamilies(userID int) ([]string, error) {
+	rows, err := tr.DB.Query("SELECT DISTINCT family_id FROM refresh_tokens WHERE user_id = ? AND revoked_at IS NULL", userID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying token families: %w", err)
+	}
+	defer rows.Close()
+
+	var families []string
+	for rows.Next() {
+		var family string
+		if err := rows.Scan(&family); err != nil {
+			return nil, fmt.Errorf("error scanning token family: %w", err)
+		}
+		families = append(families, family)
+	}
+	return families, rows.Err()
+}
+
+// RevokeFamily revokes every refresh token descending from the same login
+func (tr *TokenRepo) RevokeFamily(familyID string, at time.Time) (int64, error) {
+	result, err := tr.DB.Update(`
+		UPDATE refresh_tokens SET revoked_at = ?
+		WHERE family_id = ? AND revoked_at IS NULL`,
+		at.UTC(), familyID,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error revoking refresh token family: %w", err)
+	}
+	return result.RowsAffected()
+}
+
+// InsertRevokedToken adds an access token id to the revocation list
+func (tr *TokenRepo) InsertRevokedToken(jti string, userID int, expiresAt, revokedAt time.Time, reason string) error {
+	_, err := tr.DB.Insert(`
+		INSERT INTO revoked_tokens (jti, user_id, expires_at, revoked_at, reason)
+		VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (jti) DO NOTHING`,
+		jti, userID, expiresAt.UTC(), revokedAt.UTC(), reason,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting revoked token: %w", err)
+	}
+	return nil
+}
+
+// IsTokenRevoked reports whether an access token id is on the revocation list
+func (tr *TokenRepo) IsTokenRevoked(jti string) (bool, error) {
+	row, err := tr.DB.QueryRow("SELECT COUNT(*) FROM revoked_tokens WHERE jti = ?", jti)
+	if err != nil {
+		return false, fmt.Errorf("error querying revoked token: %w", err)
+	}
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error scanning revoked token: %w", err)
+	}
+	return count > 0, nil
+}
@@ -23,9 +23,11 @@ type Role struct {
 	RoleID       int       `json:"role_id"`
 	RoleName     string    `json:"role_name"`
 	RoleDesc     string    `json:"role_desc,omitempty"`
+	TenantID     int       `json:"tenant_id"`
 	Name         string    `json:"name" example:"admin"`
 	Permissions  []string  `json:"permissions" example:"read,write"`
 	IsSuperAdmin bool      `json:"is_super_admin"`
+	RequireMFA   bool      `json:"require_mfa"`
 	CreatedAt    time.Time `json:"created_at"`
 	CreatedBy    string    `json:"created_by"`
 	UpdatedAt    time.Time `json:"updated_at"`
@@ -42,9 +44,12 @@ type User struct {
 	Salt      string    `json:"salt,omitempty"`
 	Email     string    `json:"email" example:"john@example.com"`
 	RoleID    string    `json:"role_id" example:"1"`
+	TenantID  int       `json:"tenant_id"`
 	CreatedAt time.Time `json:"created_at"`
 	CreatedBy string    `json:"created_by"`
 	StatusID  int       `json:"status_id"`
+	// EmailVerifiedAt is set once the user opened the verification link
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
 }
 
 // RegisterRequest represents the registration request payload
@@ -67,6 +72,7 @@ type UserRoles struct {
 
 type RolePermissions struct {
 	RolePermissionID   int       `json:"role_permission_id"`
+	RoleID             int       `json:"role_id"`
 	RolePermissionDesc string    `json:"role_permission_desc"`
 	ResourceTypeID     int       `json:"resource_type_id"`
 	ResourceName       string    `json:"resource_name"`
@@ -74,6 +80,8 @@ type RolePermissions struct {
 	CanRead            bool      `json:"can_read"`
 	CanWrite           bool      `json:"can_write"`
 	CanDelete          bool      `json:"can_delete"`
+	Methods            []string  `json:"methods,omitempty" example:"GET,POST"`
+	Effect             string    `json:"effect,omitempty" example:"allow"`
 	CreatedAt          time.Time `json:"created_at"`
 	CreatedBy          string    `json:"created_by"`
 	UpdatedAt          time.Time `json:"updated_at"`
@@ -95,12 +103,45 @@ type PermissionView struct {
 type JwtClaims struct {
 	UserID   int    `json:"user_id"`
 	Username string `json:"user_name"`
+	// AMR lists how the user authenticated, e.g. ["pwd"] or ["pwd","otp","mfa"]
+	AMR []string `json:"amr,omitempty"`
+	// Purpose is set on tokens that are not access tokens, such as MFA challenges
+	Purpose string `json:"purpose,omitempty"`
+	// TenantID is the tenant of the user; tokens issued before tenants existed have none
+	TenantID int `json:"tenant_id,omitempty"`
+	// ClientID and Scope are set on tokens issued to an OAuth client
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+	// Act is the staff member acting as the user on impersonation tokens (RFC 8693)
+	Act *ActorClaim `json:"act,omitempty"`
 	jwt.StandardClaims
 }
 
+// ActorClaim identifies the user who acts on behalf of the token's subject
+type ActorClaim struct {
+	Subject  string `json:"sub"`
+	UserID   int    `json:"user_id"`
+	Username string `json:"user_name"`
+}
+
+// Impersonated reports whether the token was minted for a staff member acting as the user
+func (c *JwtClaims) Impersonated() bool {
+	return c.Act != nil
+}
+
+// Tenant returns the tenant the token was issued for
+func (c *JwtClaims) Tenant() int {
+	if c.TenantID == 0 {
+		return DefaultTenantID
+	}
+	return c.TenantID
+}
+
 type JwtToken struct {
-	Token     string `json:"token"`
-	ExpiredAt int64  `json:"expiredAt"`
+	Token            string `json:"token"`
+	ExpiredAt        int64  `json:"expiredAt"`
+	RefreshToken     string `json:"refreshToken,omitempty"`
+	RefreshExpiredAt int64  `json:"refreshExpiredAt,omitempty"`
 }
 
 type UserPermissionView struct {
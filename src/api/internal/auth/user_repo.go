@@ -2,6 +2,7 @@ package auth
 
 import (
 	"api/internal/db"
+	"database/sql"
 	"fmt"
 	"time"
 
@@ -19,17 +20,16 @@ func NewUserRepo() *UserRepo {
 	return &UserRepo{DB: db}
 }
 
-// Get Users fetches users from the database with support for text search, limit, and offset
-func (cr *UserRepo) GetUsersBySearchText(searchText string, limit, offset int) ([]*User, error) {
+// Get Users fetches the users of a tenant from the database with support for text search, limit, and offset
+func (cr *UserRepo) GetUsersBySearchText(tenantID int, searchText string, limit, offset int) ([]*User, error) {
 	var users []*User
 
-	query := fmt.Sprintf(`
-            SELECT * FROM users
-             Where user_name like '%%%s%%' OR password like '%%%s%%' OR salt like '%%%s%%'
+	pattern := "%" + searchText + "%"
+	rows, err := cr.DB.Query(`
+            SELECT `+userColumns+` FROM users
+             Where tenant_id = ? AND (user_name like ? OR password like ? OR salt like ?)
             LIMIT ? OFFSET ?
-        `, searchText, searchText, searchText)
-
-	rows, err := cr.DB.Query(query, limit, offset)
+        `, tenantID, pattern, pattern, pattern, limit, offset)
 
 	// fmt.Printf("searchText: %s \n result: %v \n error: %v", searchText, rows, err)
 
@@ -41,20 +41,11 @@ func (cr *UserRepo) GetUsersBySearchText(searchText string, limit, offset int) (
 	time.Sleep(30 * time.Second)
 
 	for rows.Next() {
-		var user User
-		err := rows.Scan(
-			&user.UserID,
-			&user.Username,
-			&user.Password,
-			&user.Salt,
-			&user.CreatedAt,
-			&user.CreatedBy,
-			&user.StatusID,
-		)
+		user, err := scanUser(rows)
 		if err != nil {
 			return nil, err
 		}
-		users = append(users, &user)
+		users = append(users, user)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -68,47 +59,44 @@ func (cr *UserRepo) GetUsersBySearchText(searchText string, limit, offset int) (
 
 // Get UserByID retrieves a user by its ID from the database
 func (cr *UserRepo) GetUserByID(id int) (*User, error) {
-	var user User
-	// Execute query to get a user by ID from the database
-	row, err := cr.DB.QueryRow("SELECT * FROM users WHERE user_id = ?", id)
-
+	row, err := cr.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE user_id = ?", id)
 	if err != nil {
-		return &user, nil
+		return nil, fmt.Errorf("error query: %v", err)
 	}
-
-	row.Scan(
-		&user.UserID,
-		&user.Username,
-		&user.Password,
-		&user.Salt,
-		&user.CreatedAt,
-		&user.CreatedBy,
-	)
-
-	return &user, nil
+	return scanUser(row)
 }
 
-// Get UserByID retrieves a user by its ID from the database
+// Get UserByName retrieves a user by its name from the database
 func (cr *UserRepo) GetUserByName(name string) (*User, error) {
-	var user User
-	// Execute query to get a user by ID from the database
-	row, err := cr.DB.QueryRow("SELECT * FROM users WHERE user_name = ?", name)
-
+	row, err := cr.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE user_name = ?", name)
 	if err != nil {
 		return nil, fmt.Errorf("error query: %v", err)
 	}
+	return scanUser(row)
+}
 
-	row.Scan(
-		&user.UserID,
-		&user.Username,
-		&user.Password,
-		&user.Salt,
-		&user.CreatedAt,
-		&user.CreatedBy,
-		&user.StatusID,
-	)
+// GetUserByEmail retrieves a user by email address, ignoring case
+func (cr *UserRepo) GetUserByEmail(email string) (*User, error) {
+	row, err := cr.DB.QueryRow("SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER(?) ORDER BY user_id LIMIT 1", email)
+	if err != nil {
+		return nil, fmt.Errorf("error query: %v", err)
+	}
+	return scanUser(row)
+}
 
-	return &user, nil
+// SetEmailVerified marks a user's email as verified. It returns false when the
+// user's email is no longer the one that was verified.
+func (cr *UserRepo) SetEmailVerified(userID int, email string, at time.Time) (bool, error) {
+	result, err := cr.DB.Update("UPDATE users SET email_verified_at = ? WHERE user_id = ? AND LOWER(email) = LOWER(?)",
+		at.UTC(), userID, email)
+	if err != nil {
+		return false, fmt.Errorf("error verifying email: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error verifying email: %w", err)
+	}
+	return affected == 1, nil
 }
 
 // Get UserByID retrieves a user by its ID from the database
@@ -151,6 +139,15 @@ func (cr *UserRepo) UpdateUser(user *User) (int64, error) {
 	return result.RowsAffected()
 }
 
+// UpdatePassword stores a new password hash for a user and clears the legacy salt
+func (cr *UserRepo) UpdatePassword(userID int, hash string) error {
+	_, err := cr.DB.Update("UPDATE users SET password=?, salt='' WHERE user_id=?", hash, userID)
+	if err != nil {
+		return fmt.Errorf("error updating password: %w", err)
+	}
+	return nil
+}
+
 // Delete User deletes a user from the database
 func (cr *UserRepo) DeleteUser(id int) (int64, error) {
 	// Execute delete query to delete a user from the database
@@ -162,7 +159,59 @@ func (cr *UserRepo) DeleteUser(id int) (int64, error) {
 }
 
 func (r *UserRepo) CreateUser(user *User) error {
-	query := `INSERT INTO users (user_name, password, salt, created_at, created_by, status_id) VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := r.DB.Exec(query, user.Username, user.Password, user.Salt, user.CreatedAt, user.CreatedBy, user.StatusID)
-	return err
+	if user.TenantID == 0 {
+		user.TenantID = DefaultTenantID
+	}
+	query := `INSERT INTO users (user_name, password, salt, created_at, created_by, status_id, email, tenant_id) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.DB.Exec(query, user.Username, user.Password, user.Salt, user.CreatedAt, user.CreatedBy, user.StatusID, user.Email, user.TenantID)
+	if err != nil {
+		return err
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return err
+	}
+	user.UserID = int(id)
+	return nil
+}
+
+// userColumns are the users columns read by scanUser
+const userColumns = "user_id, user_name, password, salt, created_at, created_by, status_id, COALESCE(email, ''), email_verified_at, tenant_id"
+
+// scanUser scans a row selected with userColumns
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
+	var user User
+	var verifiedAt sql.NullTime
+	err := row.Scan(
+		&user.UserID,
+		&user.Username,
+		&user.Password,
+		&user.Salt,
+		&user.CreatedAt,
+		&user.CreatedBy,
+		&user.StatusID,
+		&user.Email,
+		&verifiedAt,
+		&user.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	if verifiedAt.Valid {
+		user.EmailVerifiedAt = &verifiedAt.Time
+	}
+	return &user, nil
+}
+
+// GetUserTenantID returns the tenant a user belongs to
+func (r *UserRepo) GetUserTenantID(userID int) (int, error) {
+	row, err := r.DB.QueryRow("SELECT tenant_id FROM users WHERE user_id = ?", userID)
+	if err != nil {
+		return 0, fmt.Errorf("error query: %v", err)
+	}
+	var tenantID int
+	if err := row.Scan(&tenantID); err != nil {
+		return 0, err
+	}
+	return tenantID, nil
 }
@@ -2,6 +2,7 @@ package cache
 
 import (
 	"log"
+	"time"
 )
 
 // CacheBackend represents the backend for the cache.
@@ -10,6 +11,7 @@ type CacheBackend int
 const (
 	RedisBackend CacheBackend = iota
 	SQLiteBackend
+	MemoryBackend
 )
 
 func IntToCacheBackend(i int) CacheBackend {
@@ -18,6 +20,8 @@ func IntToCacheBackend(i int) CacheBackend {
 		return RedisBackend
 	case 1:
 		return SQLiteBackend
+	case 2:
+		return MemoryBackend
 	default:
 		return RedisBackend // or return an error, depending on your use case
 	}
@@ -33,10 +37,12 @@ type Cache struct {
 type CacheDB interface {
 	Get(key string) (string, error)
 	Set(key, value string) error
+	SetWithTTL(key, value string, ttl time.Duration) error
 	HSet(key []byte, value []byte) error
 	HGet(key string) ([]byte, error)
 	Remove(key string) error
 	Removes(key string)
+	AllowRate(key string, emission time.Duration, burst int) (RateLimitResult, error)
 	Close() error
 }
 
@@ -47,7 +53,9 @@ func NewCache(backend CacheBackend) *Cache {
 	case RedisBackend:
 		db, err := GetRedisInstance()
 		if err != nil {
-			log.Printf("Failed to get Redis instance: %v", err)
+			// Keep working on a per-process cache rather than failing every call
+			log.Printf("Failed to get Redis instance, falling back to memory: %v", err)
+			return &Cache{backend: MemoryBackend, db: GetMemoryInstance()}
 		}
 		return &Cache{backend: backend, db: db}
 	case SQLiteBackend:
@@ -57,12 +65,28 @@ func NewCache(backend CacheBackend) *Cache {
 			log.Printf("Failed to get SQLite instance: %v", err)
 		}
 		return &Cache{backend: backend, db: db}
+	case MemoryBackend:
+		return &Cache{backend: backend, db: GetMemoryInstance()}
 	default:
 		log.Printf("Unsupported cache backend: %v", backend)
 	}
 	return &Cache{backend: backend, db: db}
 }
 
+// NewSharedCache creates a cache for state every server instance must see, such as rate
+// limits. Unlike NewCache it does not fall back to a per-process memory cache when Redis
+// is unavailable, but returns the error so that the caller decides how to degrade.
+func NewSharedCache(backend CacheBackend) (*Cache, error) {
+	if backend != RedisBackend {
+		return NewCache(backend), nil
+	}
+	db, err := NewRedisClient()
+	if err != nil {
+		return nil, err
+	}
+	return &Cache{backend: backend, db: db}, nil
+}
+
 // Get retrieves the value associated with the given key from the cache.
 func (c *Cache) Get(key string) (string, error) {
 	return c.db.Get(key)
@@ -73,6 +97,16 @@ func (c *Cache) Set(key, value string) error {
 	return c.db.Set(key, value)
 }
 
+// SetWithTTL sets the value associated with the given key; it expires after ttl.
+func (c *Cache) SetWithTTL(key, value string, ttl time.Duration) error {
+	return c.db.SetWithTTL(key, value, ttl)
+}
+
+// Backend returns the backend actually in use, which is MemoryBackend when Redis was unavailable.
+func (c *Cache) Backend() CacheBackend {
+	return c.backend
+}
+
 func (c *Cache) HSet(key, value []byte) error {
 	return c.db.HSet(key, value)
 }
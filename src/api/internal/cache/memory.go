@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+var (
+	memoryOnce     sync.Once
+	memoryInstance *MemoryClient
+)
+
+// memoryEntry is a cached value with an optional expiry
+type memoryEntry struct {
+	value     string
+	expiresAt time.Time
+}
+
+// MemoryClient is a per-process cache. It is used when Redis is not configured or
+// cannot be reached, so entries are not shared between API instances.
+type MemoryClient struct {
+	mu      sync.Mutex
+	entries map[string]memoryEntry
+	now     func() time.Time
+}
+
+// NewMemoryClient creates an empty in-process cache
+func NewMemoryClient() *MemoryClient {
+	return &MemoryClient{entries: make(map[string]memoryEntry), now: time.Now}
+}
+
+// GetMemoryInstance returns the singleton instance of the in-process cache.
+func GetMemoryInstance() *MemoryClient {
+	memoryOnce.Do(func() {
+		memoryInstance = NewMemoryClient()
+	})
+	return memoryInstance
+}
+
+// Close drops every entry
+func (mc *MemoryClient) Close() error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	mc.entries = make(map[string]memoryEntry)
+	return nil
+}
+
+// Get retrieves the value associated with the given key.
+func (mc *MemoryClient) Get(key string) (string, error) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	entry, ok := mc.entries[key]
+	if !ok || mc.expired(entry) {
+		delete(mc.entries, key)
+		return "", fmt.Errorf("key '%s' not found", key)
+	}
+	return entry.value, nil
+}
+
+// Set sets the value associated with the given key; like Redis it expires after CACHE_AGE seconds when set.
+func (mc *MemoryClient) Set(key, value string) error {
+	return mc.SetWithTTL(key, value, time.Duration(viper.GetInt("CACHE_AGE"))*time.Second)
+}
+
+// SetWithTTL sets the value associated with the given key; it expires after ttl, or never when ttl is 0.
+func (mc *MemoryClient) SetWithTTL(key, value string, ttl time.Duration) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	entry := memoryEntry{value: value}
+	if ttl > 0 {
+		entry.expiresAt = mc.now().Add(ttl)
+	}
+	mc.entries[key] = entry
+	return nil
+}
+
+// Remove removes the specified key.
+func (mc *MemoryClient) Remove(key string) error {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	if _, ok := mc.entries[key]; !ok {
+		return fmt.Errorf("key '%s' does not exist", key)
+	}
+	delete(mc.entries, key)
+	return nil
+}
+
+// Removes removes every key starting with prefix.
+func (mc *MemoryClient) Removes(prefix string) {
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+	for key := range mc.entries {
+		if strings.HasPrefix(key, prefix) {
+			delete(mc.entries, key)
+		}
+	}
+}
+
+func (mc *MemoryClient) HGet(key string) ([]byte, error) {
+	value, err := mc.Get("cache:" + key)
+	return []byte(value), err
+}
+
+func (mc *MemoryClient) HSet(key []byte, value []byte) error {
+	return mc.SetWithTTL("cache:"+string(key), string(value), 0)
+}
+
+// expired reports whether an entry is past its expiry; the caller holds mu
+func (mc *MemoryClient) expired(entry memoryEntry) bool {
+	return !entry.expiresAt.IsZero() && !mc.now().Before(entry.expiresAt)
+}
@@ -0,0 +1,142 @@
+package cache
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RateLimitResult is the outcome of counting a request with AllowRate
+type RateLimitResult struct {
+	Allowed    bool
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// gcraScript is the Redis version of gcra. It runs atomically on the Redis clock, so
+// that every server instance shares one limit whatever its own clock says.
+var gcraScript = redis.NewScript(`
+local emission = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) * 1000000 + tonumber(clock[2])
+local tat = tonumber(redis.call('GET', KEYS[1]) or now)
+if tat < now then
+	tat = now
+end
+local new_tat = tat + emission
+local allow_at = new_tat - emission * burst
+if now < allow_at then
+	return {0, 0, allow_at - now}
+end
+redis.call('SET', KEYS[1], new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+return {1, math.floor((now - allow_at) / emission), 0}
+`)
+
+// AllowRate counts a request against the limiter stored under key, which allows bursts of
+// burst requests and then one request per emission interval. It is a GCRA limiter
+// whose only state is the theoretical arrival time of the next request.
+func (c *Cache) AllowRate(key string, emission time.Duration, burst int) (RateLimitResult, error) {
+	if c.db == nil {
+		return RateLimitResult{}, errors.New("cache is unavailable")
+	}
+	return c.db.AllowRate(key, emission, burst)
+}
+
+// gcra counts a request arriving at now against the theoretical arrival time tat of a
+// limiter; it returns the result and the new arrival time to store when allowed
+func gcra(tat, now time.Time, emission time.Duration, burst int) (RateLimitResult, time.Time) {
+	if tat.Before(now) {
+		tat = now
+	}
+	newTat := tat.Add(emission)
+	allowAt := newTat.Add(-emission * time.Duration(burst))
+	if now.Before(allowAt) {
+		return RateLimitResult{RetryAfter: allowAt.Sub(now)}, tat
+	}
+	return RateLimitResult{Allowed: true, Remaining: int(now.Sub(allowAt) / emission)}, newTat
+}
+
+// AllowRate runs the GCRA limiter in Redis
+func (rc *RedisClient) AllowRate(key string, emission time.Duration, burst int) (RateLimitResult, error) {
+	if emission <= 0 || burst < 1 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit for key '%s'", key)
+	}
+	values, err := gcraScript.Run(context.Background(), rc.client, []string{key}, emission.Microseconds(), burst).Int64Slice()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': %v", key, err)
+	}
+	if len(values) != 3 {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': unexpected reply %v", key, values)
+	}
+	return RateLimitResult{
+		Allowed:    values[0] == 1,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Microsecond,
+	}, nil
+}
+
+// AllowRate runs the GCRA limiter in the process; limits are not shared between instances
+func (mc *MemoryClient) AllowRate(key string, emission time.Duration, burst int) (RateLimitResult, error) {
+	if emission <= 0 || burst < 1 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit for key '%s'", key)
+	}
+	mc.mu.Lock()
+	defer mc.mu.Unlock()
+
+	now := mc.now()
+	tat := now
+	if entry, ok := mc.entries[key]; ok && !mc.expired(entry) {
+		if nanos, err := strconv.ParseInt(entry.value, 10, 64); err == nil {
+			tat = time.Unix(0, nanos)
+		}
+	}
+	result, newTat := gcra(tat, now, emission, burst)
+	if result.Allowed {
+		mc.entries[key] = memoryEntry{value: strconv.FormatInt(newTat.UnixNano(), 10), expiresAt: newTat}
+	}
+	return result, nil
+}
+
+// AllowRate runs the GCRA limiter in SQLite within a transaction
+func (sc *SQLiteInMemClient) AllowRate(key string, emission time.Duration, burst int) (RateLimitResult, error) {
+	if emission <= 0 || burst < 1 {
+		return RateLimitResult{}, fmt.Errorf("invalid rate limit for key '%s'", key)
+	}
+	tx, err := sc.db.Begin()
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': %v", key, err)
+	}
+	defer tx.Rollback()
+
+	now := time.Now()
+	tat := now
+	var value string
+	err = tx.QueryRow("SELECT value FROM cache WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)",
+		key, now.UnixNano()).Scan(&value)
+	if err != nil && err != sql.ErrNoRows {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': %v", key, err)
+	}
+	if nanos, err := strconv.ParseInt(value, 10, 64); err == nil {
+		tat = time.Unix(0, nanos)
+	}
+
+	result, newTat := gcra(tat, now, emission, burst)
+	if !result.Allowed {
+		return result, nil
+	}
+	_, err = tx.Exec("INSERT OR REPLACE INTO cache(key, value, expires_at) VALUES(?, ?, ?)",
+		key, strconv.FormatInt(newTat.UnixNano(), 10), newTat.UnixNano())
+	if err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': %v", key, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return RateLimitResult{}, fmt.Errorf("failed to rate limit key '%s': %v", key, err)
+	}
+	return result, nil
+}
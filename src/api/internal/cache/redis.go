@@ -41,6 +41,7 @@ func NewRedisClient() (*RedisClient, error) {
 	defer cancel()
 
 	if _, err := client.Ping(ctx).Result(); err != nil {
+		client.Close()
 		return nil, fmt.Errorf("failed to connect to Redis: %v", err)
 	}
 
@@ -56,6 +57,9 @@ func GetRedisInstance() (*RedisClient, error) {
 			log.Printf("Error creating Redis client: %v", err)
 		}
 	})
+	if redisInstance == nil {
+		return nil, fmt.Errorf("redis is unavailable")
+	}
 	return redisInstance, nil
 }
 
@@ -87,6 +91,16 @@ func (rc *RedisClient) Set(key, value string) error {
 	return nil
 }
 
+// SetWithTTL sets the value associated with the given key in Redis; it expires after ttl.
+func (rc *RedisClient) SetWithTTL(key, value string, ttl time.Duration) error {
+	ctx := context.Background()
+	err := rc.client.Set(ctx, key, value, ttl).Err()
+	if err != nil {
+		return fmt.Errorf("failed to set value for key '%s': %v", key, err)
+	}
+	return nil
+}
+
 // Remove removes the specified key from Redis.
 func (rc *RedisClient) Remove(key string) error {
 	ctx := context.Background()
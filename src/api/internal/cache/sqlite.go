@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"log"
 	"sync"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -25,10 +26,16 @@ func NewSQLiteInMemClient() (*SQLiteInMemClient, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to open SQLite database: %v", err)
 	}
+	// Every connection to :memory: opens a new empty database, so keep exactly one
+	db.SetMaxOpenConns(1)
 	if err := db.Ping(); err != nil {
 		return nil, fmt.Errorf("failed to ping SQLite database: %v", err)
 	}
-	return &SQLiteInMemClient{db: db}, nil
+	client := &SQLiteInMemClient{db: db}
+	if err := client.CreateTable(); err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
 // GetInstance returns the singleton instance of the SQLite client.
@@ -52,7 +59,8 @@ func (sc *SQLiteInMemClient) Close() error {
 func (sc *SQLiteInMemClient) CreateTable() error {
 	_, err := sc.db.Exec(`CREATE TABLE IF NOT EXISTS cache (
 		key TEXT PRIMARY KEY,
-		value TEXT
+		value TEXT,
+		expires_at INTEGER
 	)`)
 	if err != nil {
 		return fmt.Errorf("failed to create table in SQLite database: %v", err)
@@ -63,7 +71,8 @@ func (sc *SQLiteInMemClient) CreateTable() error {
 // Get retrieves the value associated with the given key from the SQLite database.
 func (sc *SQLiteInMemClient) Get(key string) (string, error) {
 	var value string
-	err := sc.db.QueryRow("SELECT value FROM cache WHERE key = ?", key).Scan(&value)
+	err := sc.db.QueryRow("SELECT value FROM cache WHERE key = ? AND (expires_at IS NULL OR expires_at > ?)",
+		key, time.Now().UnixNano()).Scan(&value)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return "", fmt.Errorf("key '%s' not found", key)
@@ -82,6 +91,16 @@ func (sc *SQLiteInMemClient) Set(key, value string) error {
 	return nil
 }
 
+// SetWithTTL sets the value associated with the given key in the SQLite database; it expires after ttl.
+func (sc *SQLiteInMemClient) SetWithTTL(key, value string, ttl time.Duration) error {
+	_, err := sc.db.Exec("INSERT OR REPLACE INTO cache(key, value, expires_at) VALUES(?, ?, ?)",
+		key, value, time.Now().Add(ttl).UnixNano())
+	if err != nil {
+		return fmt.Errorf("failed to set value for key '%s': %v", key, err)
+	}
+	return nil
+}
+
 // Remove removes the specified key from the SQLite database.
 func (sc *SQLiteInMemClient) Remove(key string) error {
 	res, err := sc.db.Exec("DELETE FROM cache WHERE key = ?", key)
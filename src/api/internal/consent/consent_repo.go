@@ -2,6 +2,9 @@ package consent
 
 import (
 	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	_ "github.com/mattn/go-sqlite3"
@@ -18,14 +21,32 @@ func NewConsentRepo() *ConsentRepo {
 	return &ConsentRepo{DB: db}
 }
 
-// GetConsents fetches consents with pagination support
-func (cr *ConsentRepo) GetConsents(params db.PaginationParams) (*db.PaginationResponse, error) {
-	baseQuery := "SELECT * FROM consents"
-	countQuery := "SELECT COUNT(*) FROM consents"
+// consentColumns lists the consent columns in the order scanConsent reads them
+const consentColumns = `
+	consent_id, patient_id, source_hospital, target_hospital, purpose, data_categories,
+	start_date, expiry_date, status, version, signature, created_at, updated_at`
+
+// insertConsentColumns are the consent columns written on insert; the scope adds tenant_id
+var insertConsentColumns = []string{
+	"patient_id", "source_hospital", "target_hospital", "purpose", "data_categories",
+	"start_date", "expiry_date", "status", "version", "signature", "created_at", "updated_at",
+}
+
+// scope returns the consents of the tenant of ctx
+func (cr *ConsentRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, cr.DB.Connection)
+}
+
+// GetConsents fetches the tenant's consents with pagination support
+func (cr *ConsentRepo) GetConsents(ctx context.Context, params db.PaginationParams) (*db.PaginationResponse, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
 
 	// Get total count
 	var total int64
-	row, err := cr.DB.QueryRow(countQuery)
+	row, err := scoped.SelectRow("consents", "COUNT(*)", "")
 	if err != nil {
 		return nil, fmt.Errorf("error counting consents: %w", err)
 	}
@@ -41,7 +62,7 @@ func (cr *ConsentRepo) GetConsents(params db.PaginationParams) (*db.PaginationRe
 	}
 
 	// Execute paginated query
-	rows, err := cr.DB.Query(baseQuery+paginationQuery, args...)
+	rows, err := scoped.SelectClauses("consents", consentColumns, "", paginationQuery, args)
 	if err != nil {
 		return nil, fmt.Errorf("error querying consents: %w", err)
 	}
@@ -51,26 +72,11 @@ func (cr *ConsentRepo) GetConsents(params db.PaginationParams) (*db.PaginationRe
 	var lastID int
 
 	for rows.Next() {
-		var consent Consent
-		err := rows.Scan(
-			&consent.ConsentID,
-			&consent.PatientID,
-			&consent.SourceHospital,
-			&consent.TargetHospital,
-			&consent.Purpose,
-			&consent.DataCategories,
-			&consent.StartDate,
-			&consent.ExpiryDate,
-			&consent.Status,
-			&consent.Version,
-			&consent.Signature,
-			&consent.CreatedAt,
-			&consent.UpdatedAt,
-		)
+		consent, err := scanConsent(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning consent: %w", err)
 		}
-		consents = append(consents, &consent)
+		consents = append(consents, consent)
 		lastID = consent.ConsentID
 	}
 
@@ -106,22 +112,37 @@ func (cr *ConsentRepo) GetConsents(params db.PaginationParams) (*db.PaginationRe
 	return response, nil
 }
 
-// GetConsentByID retrieves a consent by its ID from the database
-func (cr *ConsentRepo) GetConsentByID(id string) (*Consent, error) {
-	var consent Consent
-	row, err := cr.DB.QueryRow("SELECT * FROM consents WHERE consent_id = ?", id)
-
+// GetConsentByID retrieves a consent of the tenant by its ID from the database;
+// consents of other tenants are not found
+func (cr *ConsentRepo) GetConsentByID(ctx context.Context, id string) (*Consent, error) {
+	scoped, err := cr.scope(ctx)
 	if err != nil {
 		return nil, err
 	}
+	row, err := scoped.SelectRow("consents", consentColumns, "consent_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanConsent(row)
+}
 
-	row.Scan(
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+// scanConsent reads a consent row; data categories are stored as a JSON array
+func scanConsent(row rowScanner) (*Consent, error) {
+	var consent Consent
+	var categories string
+	err := row.Scan(
 		&consent.ConsentID,
 		&consent.PatientID,
 		&consent.SourceHospital,
 		&consent.TargetHospital,
 		&consent.Purpose,
-		&consent.DataCategories,
+		&categories,
 		&consent.StartDate,
 		&consent.ExpiryDate,
 		&consent.Status,
@@ -130,34 +151,64 @@ func (cr *ConsentRepo) GetConsentByID(id string) (*Consent, error) {
 		&consent.CreatedAt,
 		&consent.UpdatedAt,
 	)
-
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(categories), &consent.DataCategories); err != nil {
+		return nil, fmt.Errorf("error decoding data categories: %w", err)
+	}
 	return &consent, nil
 }
 
-// InsertConsent inserts a new consent into the database
-func (cr *ConsentRepo) InsertConsent(consent *Consent) (int, error) {
-	_, err := cr.DB.Insert("INSERT INTO consents (patient_id, source_hospital, target_hospital, purpose, data_categories, start_date, expiry_date, status, version, signature, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
-		consent.PatientID, consent.SourceHospital, consent.TargetHospital, consent.Purpose, consent.DataCategories, consent.StartDate, consent.ExpiryDate, consent.Status, consent.Version, consent.Signature, consent.CreatedAt, consent.UpdatedAt)
+// InsertConsent inserts a new consent of the tenant into the database
+func (cr *ConsentRepo) InsertConsent(ctx context.Context, consent *Consent) (int, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	categories, err := json.Marshal(consent.DataCategories)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding data categories: %w", err)
+	}
+	result, err := scoped.Insert("consents", insertConsentColumns,
+		consent.PatientID, consent.SourceHospital, consent.TargetHospital, consent.Purpose, string(categories), consent.StartDate, consent.ExpiryDate, consent.Status, consent.Version, consent.Signature, consent.CreatedAt, consent.UpdatedAt)
 	if err != nil {
 		fmt.Printf("Error inserting consent: %v\n", err)
 		return 0, err
 	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+	consent.ConsentID = int(id)
 	return consent.ConsentID, nil
 }
 
-// UpdateConsent updates an existing consent in the database
-func (cr *ConsentRepo) UpdateConsent(consent *Consent) (int, error) {
-	_, err := cr.DB.Update("UPDATE consents SET patient_id=?, source_hospital=?, target_hospital=?, purpose=?, data_categories=?, start_date=?, expiry_date=?, status=?, version=?, signature=?, updated_at=? WHERE id=?",
-		consent.PatientID, consent.SourceHospital, consent.TargetHospital, consent.Purpose, consent.DataCategories, consent.StartDate, consent.ExpiryDate, consent.Status, consent.Version, consent.Signature, consent.UpdatedAt, consent.ID)
+// UpdateConsent updates an existing consent of the tenant in the database
+func (cr *ConsentRepo) UpdateConsent(ctx context.Context, consent *Consent) (int, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	categories, err := json.Marshal(consent.DataCategories)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding data categories: %w", err)
+	}
+	_, err = scoped.Update("consents", "patient_id=?, source_hospital=?, target_hospital=?, purpose=?, data_categories=?, start_date=?, expiry_date=?, status=?, version=?, signature=?, updated_at=?", "consent_id=?",
+		consent.PatientID, consent.SourceHospital, consent.TargetHospital, consent.Purpose, string(categories), consent.StartDate, consent.ExpiryDate, consent.Status, consent.Version, consent.Signature, consent.UpdatedAt, consent.ConsentID)
 	if err != nil {
 		return 0, err
 	}
 	return consent.ConsentID, nil
 }
 
-// DeleteConsent deletes a consent from the database
-func (cr *ConsentRepo) DeleteConsent(id int) (int, error) {
-	_, err := cr.DB.Delete("DELETE FROM consents WHERE id=?", id)
+// DeleteConsent deletes a consent of the tenant from the database
+func (cr *ConsentRepo) DeleteConsent(ctx context.Context, id int) (int, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	_, err = scoped.Delete("consents", "consent_id=?", id)
 	if err != nil {
 		return 0, err
 	}
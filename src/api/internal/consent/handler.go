@@ -0,0 +1,222 @@
+package consent
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/router"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Consent statuses
+const (
+	StatusPending = "PENDING"
+	StatusActive  = "ACTIVE"
+	StatusRevoked = "REVOKED"
+	StatusExpired = "EXPIRED"
+)
+
+// ConsentHandler handles HTTP requests for data sharing consents
+type ConsentHandler struct {
+	repo *ConsentRepo
+}
+
+// NewConsentHandler creates a new ConsentHandler
+func NewConsentHandler(repo *ConsentRepo) *ConsentHandler {
+	return &ConsentHandler{repo: repo}
+}
+
+// GetConsentsHandler godoc
+// @Summary List consents
+// @Description List consents page by page
+// @Tags consents
+// @Produce json
+// @Param page query int false "Page number"
+// @Param limit query int false "Page size"
+// @Success 200 {object} db.PaginationResponse
+// @Router /consents [get]
+func (h *ConsentHandler) GetConsentsHandler(w http.ResponseWriter, r *http.Request) {
+	params := db.NewPaginationParams(db.PagePagination)
+	if page, err := strconv.Atoi(r.URL.Query().Get("page")); err == nil && page > 0 {
+		params.Page = page
+	}
+	if limit, err := strconv.Atoi(r.URL.Query().Get("limit")); err == nil && limit > 0 {
+		params.Limit = limit
+	}
+	params.KeyID = "consent_id"
+	params.SortFields = []string{"consent_id"}
+
+	result, err := h.repo.GetConsents(r.Context(), params)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch consents", auth.GetRequestID(r))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, result)
+}
+
+// GetConsentHandler godoc
+// @Summary Get a consent
+// @Tags consents
+// @Produce json
+// @Param id path int true "Consent ID"
+// @Success 200 {object} Consent
+// @Failure 404 {object} types.ErrorResponse
+// @Router /consents/{id} [get]
+func (h *ConsentHandler) GetConsentHandler(w http.ResponseWriter, r *http.Request) {
+	consent, err := h.repo.GetConsentByID(r.Context(), router.Param(r, "id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Consent not found", auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to fetch consent", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, consent, "", auth.GetRequestID(r))
+}
+
+// CreateConsentHandler godoc
+// @Summary Create a consent
+// @Description Record a patient's consent to share data between hospitals. Status defaults to PENDING.
+// @Tags consents
+// @Accept json
+// @Produce json
+// @Param consent body Consent true "Consent"
+// @Success 201 {object} Consent
+// @Failure 400 {object} types.ErrorResponse
+// @Router /consents [post]
+func (h *ConsentHandler) CreateConsentHandler(w http.ResponseWriter, r *http.Request) {
+	var consent Consent
+	if err := json.NewDecoder(r.Body).Decode(&consent); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+	if consent.Status == "" {
+		consent.Status = StatusPending
+	}
+	if err := validateConsent(&consent); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	}
+
+	now := time.Now().UTC()
+	consent.Version = 1
+	consent.CreatedAt = now
+	consent.UpdatedAt = now
+	if _, err := h.repo.InsertConsent(r.Context(), &consent); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create consent", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, consent, "Consent created successfully", auth.GetRequestID(r))
+}
+
+// UpdateConsentHandler godoc
+// @Summary Update a consent
+// @Description Replace a consent; every update increments its version
+// @Tags consents
+// @Accept json
+// @Produce json
+// @Param id path int true "Consent ID"
+// @Param consent body Consent true "Consent"
+// @Success 200 {object} Consent
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /consents/{id} [put]
+func (h *ConsentHandler) UpdateConsentHandler(w http.ResponseWriter, r *http.Request) {
+	existing, err := h.repo.GetConsentByID(r.Context(), router.Param(r, "id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Consent not found", auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to fetch consent", auth.GetRequestID(r))
+		return
+	}
+
+	var consent Consent
+	if err := json.NewDecoder(r.Body).Decode(&consent); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+	if consent.Status == "" {
+		consent.Status = existing.Status
+	}
+	if err := validateConsent(&consent); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	}
+
+	consent.ConsentID = existing.ConsentID
+	consent.Version = existing.Version + 1
+	consent.CreatedAt = existing.CreatedAt
+	consent.UpdatedAt = time.Now().UTC()
+	if _, err := h.repo.UpdateConsent(r.Context(), &consent); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to update consent", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, consent, "Consent updated successfully", auth.GetRequestID(r))
+}
+
+// DeleteConsentHandler godoc
+// @Summary Delete a consent
+// @Tags consents
+// @Produce json
+// @Param id path int true "Consent ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} types.ErrorResponse
+// @Router /consents/{id} [delete]
+func (h *ConsentHandler) DeleteConsentHandler(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(router.Param(r, "id"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Consent ID must be a number", auth.GetRequestID(r))
+		return
+	}
+
+	if _, err := h.repo.DeleteConsent(r.Context(), id); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete consent", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, map[string]string{
+		"message": "Consent deleted successfully",
+	}, "Consent deleted successfully", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the consent routes with the given router
+func (h *ConsentHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/consents", h.GetConsentsHandler)
+	r.Post("/consents", h.CreateConsentHandler)
+	r.Get("/consents/{id}", h.GetConsentHandler)
+	r.Put("/consents/{id}", h.UpdateConsentHandler)
+	r.Delete("/consents/{id}", h.DeleteConsentHandler)
+}
+
+// validateConsent checks required fields, the status and the validity period
+func validateConsent(consent *Consent) error {
+	consent.Status = strings.ToUpper(consent.Status)
+	switch consent.Status {
+	case StatusPending, StatusActive, StatusRevoked, StatusExpired:
+	default:
+		return errors.New("status must be PENDING, ACTIVE, REVOKED or EXPIRED")
+	}
+	if consent.PatientID == "" || consent.SourceHospital == "" || consent.TargetHospital == "" ||
+		consent.Purpose == "" || consent.Signature == "" {
+		return errors.New("patient_id, source_hospital, target_hospital, purpose and signature are required")
+	}
+	if consent.StartDate.IsZero() || !consent.ExpiryDate.After(consent.StartDate) {
+		return errors.New("expiry_date must be after start_date")
+	}
+	if consent.DataCategories == nil {
+		consent.DataCategories = []string{}
+	}
+	return nil
+}
@@ -1,10 +1,13 @@
 package contact
 
 import (
-	"fmt"
-	"strings"
+	"api/internal/tenant"
 	"api/pkg/data"
 	"api/pkg/data/models"
+	"context"
+	"fmt"
+	"math"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
@@ -19,42 +22,48 @@ func NewContactRepo() *ContactRepo {
 	return &ContactRepo{DB: db}
 }
 
-// Get Contacts fetches contacts from the database with support for text search, limit, and offset
-func (cr *ContactRepo) GetContactsBySearchText(searchText string, limit, offset int) ([]*models.ContactModel, error) {
-	var contacts []*models.ContactModel
+// contactColumns are the contact columns scanned into ContactModel; listing them keeps
+// columns added later, such as tenant_id, out of the scans
+const contactColumns = "contact_id, name, first_name, last_name, gender_id, dob, email, phone, address, photo_path, created_at, created_by"
 
-	query := fmt.Sprintf(`
-            SELECT * FROM contact
-             Where name like '%%%s%%' OR first_name like '%%%s%%' OR last_name like '%%%s%%' OR email like '%%%s%%' OR phone like '%%%s%%' OR address like '%%%s%%' OR photo_path like '%%%s%%'
-            LIMIT ? OFFSET ?
-        `, searchText, searchText, searchText, searchText, searchText, searchText, searchText)
+// insertContactColumns are the contact columns written on insert; the scope adds tenant_id
+var insertContactColumns = []string{"name", "first_name", "last_name", "gender_id", "dob", "email", "phone", "address", "photo_path", "created_at", "created_by"}
+
+// searchCondition matches contacts whose text fields contain the search text
+const searchCondition = "name LIKE ? OR first_name LIKE ? OR last_name LIKE ? OR email LIKE ? OR phone LIKE ? OR address LIKE ? OR photo_path LIKE ?"
+
+// searchArgs returns the arguments of searchCondition
+func searchArgs(searchText string) []interface{} {
+	pattern := "%" + searchText + "%"
+	return []interface{}{pattern, pattern, pattern, pattern, pattern, pattern, pattern}
+}
+
+// scope returns the contacts of the tenant of ctx
+func (cr *ContactRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, cr.DB.Connection)
+}
+
+// Get Contacts fetches the tenant's contacts from the database with support for text search, limit, and offset
+func (cr *ContactRepo) GetContactsBySearchText(ctx context.Context, searchText string, limit, offset int) ([]*models.ContactModel, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
 
-	rows, err := cr.DB.Query(query, limit, offset)
+	rows, err := scoped.SelectClauses("contact", contactColumns, searchCondition, "LIMIT ? OFFSET ?",
+		[]interface{}{limit, offset}, searchArgs(searchText)...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
+	var contacts []*models.ContactModel
 	for rows.Next() {
-		var contact models.ContactModel
-		err := rows.Scan(
-			&contact.ContactId,
-			&contact.Name,
-			&contact.FirstName,
-			&contact.LastName,
-			&contact.GenderId,
-			&contact.Dob,
-			&contact.Email,
-			&contact.Phone,
-			&contact.Address,
-			&contact.PhotoPath,
-			&contact.CreatedAt,
-			&contact.CreatedBy,
-		)
+		contact, err := scanContact(rows)
 		if err != nil {
 			return nil, err
 		}
-		contacts = append(contacts, &contact)
+		contacts = append(contacts, contact)
 	}
 
 	if err := rows.Err(); err != nil {
@@ -64,196 +73,143 @@ func (cr *ContactRepo) GetContactsBySearchText(searchText string, limit, offset
 	return contacts, nil
 }
 
-
-// Get Contacts fetches contacts from the database with support for text search, limit, and offset
-func (cr *ContactRepo) GetContactsBySearchTextPagination(searchText string, page, pageSize int) ([]*models.ContactModel, *models.PaginationModel, error) {
-	var contacts []*models.ContactModel
-
-	query := fmt.Sprintf(`
-            SELECT * FROM contact
-             Where name like '%%%s%%' OR first_name like '%%%s%%' OR last_name like '%%%s%%' OR email like '%%%s%%' OR phone like '%%%s%%' OR address like '%%%s%%' OR photo_path like '%%%s%%'
-        `, searchText, searchText, searchText, searchText, searchText, searchText, searchText)
-	offset := (page - 1) * pageSize
-	limit := pageSize
-
-	pagination := data.NewPagination(page, pageSize, query, limit, offset)
-
-    pager, err := pagination.GetPageData(cr.DB)
-    if err != nil {
-        return nil, nil, err
-    }
-	
-	query = query + " LIMIT ? OFFSET ?"
-	
-	rows, err := cr.DB.Query(query, limit, offset)
+// Get Contacts fetches a page of the tenant's contacts from the database with support for text search
+func (cr *ContactRepo) GetContactsBySearchTextPagination(ctx context.Context, searchText string, page, pageSize int) ([]*models.ContactModel, *models.PaginationModel, error) {
+	scoped, err := cr.scope(ctx)
 	if err != nil {
-		return nil,nil, err
+		return nil, nil, err
 	}
-	defer rows.Close()
 
-	for rows.Next() {
-		var contact models.ContactModel
-		err := rows.Scan(
-			&contact.ContactId,
-			&contact.Name,
-			&contact.FirstName,
-			&contact.LastName,
-			&contact.GenderId,
-			&contact.Dob,
-			&contact.Email,
-			&contact.Phone,
-			&contact.Address,
-			&contact.PhotoPath,
-			&contact.CreatedAt,
-			&contact.CreatedBy,
-		)
-		if err != nil {
-			return nil,nil, err
-		}
-		contacts = append(contacts, &contact)
+	row, err := scoped.SelectRow("contact", "COUNT(*)", searchCondition, searchArgs(searchText)...)
+	if err != nil {
+		return nil, nil, err
 	}
-
-	if err := rows.Err(); err != nil {
-		return nil,nil, err
+	pager := &models.PaginationModel{Page: page, PageSize: pageSize}
+	if err := row.Scan(&pager.TotalItems); err != nil {
+		return nil, nil, err
 	}
+	pager.TotalPages = int(math.Ceil(float64(pager.TotalItems) / float64(pageSize)))
+	pager.HasNext = page < pager.TotalPages
+	pager.HasPrevious = page > 1
 
-
+	contacts, err := cr.GetContactsBySearchText(ctx, searchText, pageSize, (page-1)*pageSize)
+	if err != nil {
+		return nil, nil, err
+	}
 	return contacts, pager, nil
 }
 
-// Get ContactByID retrieves a contact by its ID from the database
-func (cr *ContactRepo) GetContactByID(id int) (*models.ContactModel, error) {
-	var contact models.ContactModel
-	// Execute query to get a contact by ID from the database
-	row, err := cr.DB.QueryRow("SELECT * FROM contact WHERE contact_id = ?", id)
-
+// Get ContactByID retrieves a contact of the tenant by its ID from the database;
+// contacts of other tenants are not found
+func (cr *ContactRepo) GetContactByID(ctx context.Context, id int) (*models.ContactModel, error) {
+	scoped, err := cr.scope(ctx)
 	if err != nil {
-		return &contact, nil
+		return nil, err
 	}
-
-	row.Scan(
-		&contact.ContactId,
-		&contact.Name,
-		&contact.FirstName,
-		&contact.LastName,
-		&contact.GenderId,
-		&contact.Dob,
-		&contact.Email,
-		&contact.Phone,
-		&contact.Address,
-		&contact.PhotoPath,
-		&contact.CreatedAt,
-		&contact.CreatedBy,
-
-	)
-
-	return &contact, nil
+	row, err := scoped.SelectRow("contact", contactColumns, "contact_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return scanContact(row)
 }
 
-// Insert Contact inserts a new contact into the database
-func (cr *ContactRepo) InsertContact(contact *models.ContactModel) (int64, error) {
-	// Execute insert query to insert a new contact into the database
-	result, err := cr.DB.Insert("INSERT INTO contact (name,first_name,last_name,gender_id,dob,email,phone,address,photo_path,created_at,created_by) VALUES (?,?,?,?,?,?,?,?,?,?,?)",
-		 contact.Name, contact.FirstName, contact.LastName, contact.GenderId, contact.Dob, contact.Email, contact.Phone, contact.Address, contact.PhotoPath, contact.CreatedAt, contact.CreatedBy)
+// Insert Contact inserts a new contact of the tenant into the database
+func (cr *ContactRepo) InsertContact(ctx context.Context, contact *models.ContactModel) (int64, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result, err := scoped.Insert("contact", insertContactColumns, insertArgs(contact)...)
 	if err != nil {
 		return 0, err
 	}
 	return result.LastInsertId()
 }
 
-func (cr *ContactRepo) InsertContacts(contacts []*models.ContactModel) ( int64, error) {
-  
-	// Step 1: Query the count of records before insertion
-    var countBefore int64
-    row,err := cr.DB.QueryRow("SELECT COUNT(*) FROM contact")
-	
-    if err != nil {
-        return 0, fmt.Errorf("failed to query count before insertion: %v", err)
-    }
-	row.Scan(&countBefore)
-
-    // Begin a transaction by starting a deferred transaction
-    _, err = cr.DB.Exec("BEGIN TRANSACTION")
-    if err != nil {
-        return 0, err
-    }
-    defer func() {
-        // Rollback the transaction if there's an error and it hasn't been committed
-        if err != nil {
-            _, rollbackErr := cr.DB.Exec("ROLLBACK")
-            if rollbackErr != nil {
-                err = fmt.Errorf("rollback failed: %v, original error: %v", rollbackErr, err)
-            }
-            return
-        }
-        // Commit the transaction if no error occurred
-        _, commitErr := cr.DB.Exec("COMMIT")
-        if commitErr != nil {
-            err = fmt.Errorf("commit failed: %v", commitErr)
-        }
-    }()
-
-    // Prepare the SQL statement for batch insertion
-    stmt, err := cr.DB.Prepare("INSERT INTO contact (name, first_name, last_name, gender_id, dob, email, phone, address, photo_path, created_at, created_by) VALUES " + placeholders(len(contacts)))
-    if err != nil {
-        return 0, err
-    }
-    defer stmt.Close()
-
-    // Prepare the slice to hold the arguments for the prepared statement
-    args := make([]interface{}, 0, len(contacts)*11)
-
-    // Flatten the contacts into a single slice of values
-    for _, contact := range contacts {
-        args = append(args, contact.Name, contact.FirstName, contact.LastName, contact.GenderId, contact.Dob, contact.Email, contact.Phone, contact.Address, contact.PhotoPath, contact.CreatedAt, contact.CreatedBy)
-    }
-
-    // Execute the prepared statement with the concatenated values
-    _, err = stmt.Exec(args...)
-    if err != nil {
-        return 0, err
-    }
-
-    var countAfter int64
-    row, err = cr.DB.QueryRow("SELECT COUNT(*) FROM contact")
-	row.Scan(&countAfter)
-    if err != nil {
-        return 0, fmt.Errorf("failed to query count after insertion: %v", err)
-    }
-	if countAfter != (countBefore + int64(len(contacts))) {
-		return 0, fmt.Errorf("insert batch is not completed: %v", err)
+// InsertContacts inserts contacts of the tenant in a single transaction and returns
+// how many contacts the tenant has afterwards
+func (cr *ContactRepo) InsertContacts(ctx context.Context, contacts []*models.ContactModel) (int64, error) {
+	tx, err := cr.DB.Connection.Begin()
+	if err != nil {
+		return 0, err
 	}
+	defer tx.Rollback()
 
-    return  countAfter, nil
-}
+	scoped, err := tenant.Scope(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	for _, contact := range contacts {
+		if _, err := scoped.Insert("contact", insertContactColumns, insertArgs(contact)...); err != nil {
+			return 0, err
+		}
+	}
 
-// placeholders returns a string with n question marks separated by commas, for use in a SQL statement.
-func placeholders(n int) string {
-    if n <= 0 {
-        return ""
-    }
-    return strings.Repeat("(?,?,?,?,?,?,?,?,?,?,?),", n-1) + "(?,?,?,?,?,?,?,?,?,?,?)"
+	row, err := scoped.SelectRow("contact", "COUNT(*)", "")
+	if err != nil {
+		return 0, err
+	}
+	var total int64
+	if err := row.Scan(&total); err != nil {
+		return 0, fmt.Errorf("failed to query count after insertion: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("commit failed: %v", err)
+	}
+	return total, nil
 }
 
-
-
-// Update Contact updates an existing contact in the database
-func (cr *ContactRepo) UpdateContact(contact *models.ContactModel) (int64, error) {
-	// Execute update query to update an existing contact in the database
-	result, err := cr.DB.Update("UPDATE contact SET contact_id=?,name=?,first_name=?,last_name=?,gender_id=?,dob=?,email=?,phone=?,address=?,photo_path=? where contact_id=?",
-		contact.ContactId, contact.Name, contact.FirstName, contact.LastName, contact.GenderId, contact.Dob, contact.Email, contact.Phone, contact.Address, contact.PhotoPath, contact.ContactId)
+// Update Contact updates an existing contact of the tenant in the database
+func (cr *ContactRepo) UpdateContact(ctx context.Context, contact *models.ContactModel) (int64, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result, err := scoped.Update("contact", "name=?,first_name=?,last_name=?,gender_id=?,dob=?,email=?,phone=?,address=?,photo_path=?", "contact_id=?",
+		contact.Name, contact.FirstName, contact.LastName, contact.GenderId, contact.Dob, contact.Email, contact.Phone, contact.Address, contact.PhotoPath, contact.ContactId)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
 
-// Delete Contact deletes a contact from the database
-func (cr *ContactRepo) DeleteContact(id int) (int64, error) {
-	// Execute delete query to delete a contact from the database
-	result, err := cr.DB.Delete("DELETE FROM contact WHERE contact_id=?", id)
+// Delete Contact deletes a contact of the tenant from the database
+func (cr *ContactRepo) DeleteContact(ctx context.Context, id int) (int64, error) {
+	scoped, err := cr.scope(ctx)
+	if err != nil {
+		return 0, err
+	}
+	result, err := scoped.Delete("contact", "contact_id=?", id)
 	if err != nil {
 		return 0, err
 	}
 	return result.RowsAffected()
 }
+
+// insertArgs returns the values of insertContactColumns
+func insertArgs(contact *models.ContactModel) []interface{} {
+	return []interface{}{contact.Name, contact.FirstName, contact.LastName, contact.GenderId, contact.Dob, contact.Email, contact.Phone, contact.Address, contact.PhotoPath, contact.CreatedAt, contact.CreatedBy}
+}
+
+// scanContact reads a row selected with contactColumns
+func scanContact(row interface{ Scan(...any) error }) (*models.ContactModel, error) {
+	var contact models.ContactModel
+	err := row.Scan(
+		&contact.ContactId,
+		&contact.Name,
+		&contact.FirstName,
+		&contact.LastName,
+		&contact.GenderId,
+		&contact.Dob,
+		&contact.Email,
+		&contact.Phone,
+		&contact.Address,
+		&contact.PhotoPath,
+		&contact.CreatedAt,
+		&contact.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &contact, nil
+}
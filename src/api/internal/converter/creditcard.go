@@ -1,13 +1,32 @@
 package converter
 
+import "strings"
+
+// MaskCreditCardNumer keeps the first 4 and last 4 digits of a card number.
+// Inputs too short to keep both ends are fully masked instead of panicking.
 func MaskCreditCardNumer(creditCardNumber string) string {
-	return creditCardNumber[:4] + "****" + creditCardNumber[len(creditCardNumber)-4:]
+	digits := stripCardSeparators(creditCardNumber)
+	if len(digits) < 9 {
+		return strings.Repeat("*", len(digits))
+	}
+	return digits[:4] + "****" + digits[len(digits)-4:]
 }
 
+// MaskCreditCardExpiryDate normalizes an expiry date to MM/YY.
+// Inputs shorter than 4 characters are fully masked.
 func MaskCreditCardExpiryDate(expiryDate string) string {
+	if len(expiryDate) < 4 {
+		return strings.Repeat("*", len(expiryDate))
+	}
 	return expiryDate[:2] + "/" + expiryDate[len(expiryDate)-2:]
 }
 
+// MaskCreditCardCVV never reveals any digit of the CVV.
 func MaskCreditCardCVV(cvv string) string {
-	return "**" + cvv[len(cvv)-1:]
+	return "***"
+}
+
+// stripCardSeparators removes spaces and dashes commonly typed in card numbers
+func stripCardSeparators(cardNumber string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(cardNumber)
 }
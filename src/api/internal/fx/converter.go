@@ -0,0 +1,203 @@
+package fx
+
+import (
+	"api/config"
+	"api/internal/ledger"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+// rateDecimals is the precision of derived inverse and cross rates
+const rateDecimals = 1e8
+
+// Converter converts amounts between currencies using the imported rates
+type Converter struct {
+	repo *FXRepo
+	base string
+	now  func() time.Time
+}
+
+// NewConverter creates a Converter reporting in the configured base currency
+func NewConverter() *Converter {
+	base := ledger.DefaultCurrency
+	if cfg := config.NewConfig(); cfg != nil && cfg.BaseCurrency != "" {
+		base = cfg.BaseCurrency
+	}
+	return NewConverterWithRepo(NewFXRepo(), base)
+}
+
+// NewConverterWithRepo creates a Converter on the given repository and base currency
+func NewConverterWithRepo(repo *FXRepo, base string) *Converter {
+	if normalized, err := NormalizeCurrency(base); err == nil {
+		base = normalized
+	} else {
+		base = ledger.DefaultCurrency
+	}
+	return &Converter{repo: repo, base: base, now: time.Now}
+}
+
+// BaseCurrency returns the currency totals are reported in
+func (c *Converter) BaseCurrency() string {
+	return c.base
+}
+
+// Import parses a rate file and stores its rates
+func (c *Converter) Import(fileName string, format Format, r io.Reader, importedBy string) (*RateImport, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if format == "" {
+		format, err = DetectFormat(fileName, content)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	rates, err := ParseRates(format, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	now := c.now().UTC()
+	for i := range rates {
+		rates[i].Source = fileName
+		rates[i].ImportedAt = now
+		rates[i].ImportedBy = importedBy
+	}
+	if err := c.repo.InsertRates(rates); err != nil {
+		return nil, err
+	}
+
+	return &RateImport{Source: fileName, Format: format, Imported: len(rates), Rates: rates}, nil
+}
+
+// Rates lists stored rates, optionally for one base and quote
+func (c *Converter) Rates(base, quote string) ([]Rate, error) {
+	var err error
+	if base != "" {
+		if base, err = NormalizeCurrency(base); err != nil {
+			return nil, err
+		}
+	}
+	if quote != "" {
+		if quote, err = NormalizeCurrency(quote); err != nil {
+			return nil, err
+		}
+	}
+	return c.repo.GetRates(base, quote)
+}
+
+// Rate finds the rate from one currency to another in force on a date.
+// A stored rate is used directly or inverted; otherwise the rate is crossed through the base currency.
+// The effective date of a cross rate is that of its older leg.
+func (c *Converter) Rate(from, to string, asOf time.Time) (*Rate, error) {
+	from, err := NormalizeCurrency(from)
+	if err != nil {
+		return nil, err
+	}
+	to, err = NormalizeCurrency(to)
+	if err != nil {
+		return nil, err
+	}
+	date := asOf.UTC().Format(dateLayout)
+	if from == to {
+		return &Rate{Base: from, Quote: to, Rate: 1, EffectiveDate: date}, nil
+	}
+
+	rate, err := c.pair(from, to, date)
+	if !errors.Is(err, ErrRateNotFound) || from == c.base || to == c.base {
+		return rate, err
+	}
+
+	first, err := c.pair(from, c.base, date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date)
+	}
+	second, err := c.pair(c.base, to, date)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date)
+	}
+	effective := first.EffectiveDate
+	if second.EffectiveDate < effective {
+		effective = second.EffectiveDate
+	}
+	return &Rate{
+		Base:          from,
+		Quote:         to,
+		Rate:          roundRate(first.Rate * second.Rate),
+		EffectiveDate: effective,
+		Source:        "cross:" + c.base,
+	}, nil
+}
+
+// pair finds the most recent direct or inverted rate for a pair
+func (c *Converter) pair(from, to, date string) (*Rate, error) {
+	direct, err := c.repo.FindRate(from, to, date)
+	if err != nil && !errors.Is(err, ErrRateNotFound) {
+		return nil, err
+	}
+	inverse, err := c.repo.FindRate(to, from, date)
+	if err != nil && !errors.Is(err, ErrRateNotFound) {
+		return nil, err
+	}
+
+	switch {
+	case direct != nil && (inverse == nil || direct.EffectiveDate >= inverse.EffectiveDate):
+		return direct, nil
+	case inverse != nil:
+		return &Rate{
+			Base:          from,
+			Quote:         to,
+			Rate:          roundRate(1 / inverse.Rate),
+			EffectiveDate: inverse.EffectiveDate,
+			Source:        inverse.Source,
+			ImportedAt:    inverse.ImportedAt,
+			ImportedBy:    inverse.ImportedBy,
+		}, nil
+	}
+	return nil, fmt.Errorf("%w: %s/%s on %s", ErrRateNotFound, from, to, date)
+}
+
+// Convert converts an amount at the rate in force on a date, rounded to minor units
+func (c *Converter) Convert(amount float64, from, to string, asOf time.Time) (*Conversion, error) {
+	rate, err := c.Rate(from, to, asOf)
+	if err != nil {
+		return nil, err
+	}
+	return &Conversion{
+		From:          rate.Base,
+		To:            rate.Quote,
+		Amount:        amount,
+		Converted:     ledger.FromMinor(ledger.ToMinor(amount * rate.Rate)),
+		Rate:          rate.Rate,
+		EffectiveDate: rate.EffectiveDate,
+	}, nil
+}
+
+// Lock converts a new payment into the base currency at the rate in force at creation.
+// An empty currency is taken to be the base currency.
+func (c *Converter) Lock(amount float64, currency string, at time.Time) (*Lock, error) {
+	if currency == "" {
+		currency = c.base
+	}
+	conversion, err := c.Convert(amount, currency, c.base, at)
+	if err != nil {
+		return nil, err
+	}
+	return &Lock{
+		Currency:     conversion.From,
+		BaseCurrency: c.base,
+		Rate:         conversion.Rate,
+		RateDate:     conversion.EffectiveDate,
+		BaseAmount:   conversion.Converted,
+		LockedAt:     at.UTC(),
+	}, nil
+}
+
+func roundRate(rate float64) float64 {
+	return math.Round(rate*rateDecimals) / rateDecimals
+}
@@ -0,0 +1,146 @@
+package fx
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ErrRateNotFound is returned when no rate for a pair is in force on the requested date
+var ErrRateNotFound = errors.New("FX rate not found")
+
+// FXRepo represents the repository for FX rates
+type FXRepo struct {
+	DB *db.DB
+}
+
+// NewFXRepo creates a new instance of FXRepo
+func NewFXRepo() *FXRepo {
+	db := db.NewDB()
+	return &FXRepo{DB: db}
+}
+
+// InsertRates stores imported rates in a single transaction.
+// A rate for a pair and date that already exists is replaced.
+func (fr *FXRepo) InsertRates(rates []Rate) error {
+	tx, err := fr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, rate := range rates {
+		_, err = tx.Exec(`
+			INSERT INTO fx_rates (
+				base, quote, rate, effective_date, source, imported_at, imported_by
+			) VALUES (?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (base, quote, effective_date) DO UPDATE SET
+				rate = excluded.rate,
+				source = excluded.source,
+				imported_at = excluded.imported_at,
+				imported_by = excluded.imported_by`,
+			rate.Base,
+			rate.Quote,
+			rate.Rate,
+			rate.EffectiveDate,
+			rate.Source,
+			rate.ImportedAt.UTC(),
+			rate.ImportedBy,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting FX rate %s/%s: %w", rate.Base, rate.Quote, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// FindRate retrieves the latest rate for a pair with an effective date on or before date
+func (fr *FXRepo) FindRate(base, quote, date string) (*Rate, error) {
+	row, err := fr.DB.QueryRow(`
+		SELECT base, quote, rate, effective_date, source, imported_at, imported_by
+		FROM fx_rates
+		WHERE base = ? AND quote = ? AND effective_date <= ?
+		ORDER BY effective_date DESC
+		LIMIT 1`,
+		base, quote, date,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying FX rate: %w", err)
+	}
+
+	rate, err := scanRate(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrRateNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning FX rate: %w", err)
+	}
+	return rate, nil
+}
+
+// GetRates retrieves stored rates, optionally for one base and quote, newest first
+func (fr *FXRepo) GetRates(base, quote string) ([]Rate, error) {
+	query := `
+		SELECT base, quote, rate, effective_date, source, imported_at, imported_by
+		FROM fx_rates`
+	var conditions []string
+	var args []interface{}
+	if base != "" {
+		conditions = append(conditions, "base = ?")
+		args = append(args, base)
+	}
+	if quote != "" {
+		conditions = append(conditions, "quote = ?")
+		args = append(args, quote)
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	query += " ORDER BY base, quote, effective_date DESC"
+
+	rows, err := fr.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying FX rates: %w", err)
+	}
+	defer rows.Close()
+
+	rates := []Rate{}
+	for rows.Next() {
+		rate, err := scanRate(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning FX rate: %w", err)
+		}
+		rates = append(rates, *rate)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating FX rates: %w", err)
+	}
+	return rates, nil
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanRate(row rowScanner) (*Rate, error) {
+	var rate Rate
+	err := row.Scan(
+		&rate.Base,
+		&rate.Quote,
+		&rate.Rate,
+		&rate.EffectiveDate,
+		&rate.Source,
+		&rate.ImportedAt,
+		&rate.ImportedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
@@ -0,0 +1,164 @@
+package fx
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRateFileSize limits uploaded rate files to 5 MB
+const maxRateFileSize = 5 << 20
+
+// FXHandler handles HTTP requests for FX rates and conversions
+type FXHandler struct {
+	converter *Converter
+}
+
+// NewFXHandler creates a new FXHandler
+func NewFXHandler(converter *Converter) *FXHandler {
+	return &FXHandler{converter: converter}
+}
+
+// ImportRatesHandler godoc
+// @Summary Import FX rates
+// @Description Upload a CSV (base,quote,rate,effective_date) or JSON rate file as multipart field "file" or as the raw request body. Rates replace any existing rate for the same pair and date.
+// @Tags fx
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file false "Rate file"
+// @Param format query string false "csv or json (detected when omitted)"
+// @Param file_name query string false "File name when sending the raw body"
+// @Success 201 {object} RateImport
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 422 {object} types.ErrorResponse
+// @Router /fx/rates [post]
+func (h *FXHandler) ImportRatesHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxRateFileSize)
+
+	var format Format
+	if value := r.URL.Query().Get("format"); value != "" {
+		parsed, err := ParseFormat(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+			return
+		}
+		format = parsed
+	}
+
+	var body io.Reader = r.Body
+	fileName := r.URL.Query().Get("file_name")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Rate file is required", auth.GetRequestID(r))
+			return
+		}
+		defer file.Close()
+		body = file
+		fileName = header.Filename
+	}
+
+	imported, err := h.converter.Import(fileName, format, body, auth.Username(r))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			writeError(w, http.StatusRequestEntityTooLarge, "Rate file is too large", auth.GetRequestID(r))
+		case errors.Is(err, ErrUnknownFormat):
+			writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		case errors.Is(err, ErrInvalidRate):
+			writeError(w, http.StatusUnprocessableEntity, err.Error(), auth.GetRequestID(r))
+		default:
+			writeError(w, http.StatusInternalServerError, "Failed to import rates", auth.GetRequestID(r))
+		}
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, imported, "Rates imported successfully", auth.GetRequestID(r))
+}
+
+// GetRatesHandler godoc
+// @Summary List FX rates
+// @Description List stored rates, optionally for one base and quote currency, newest first
+// @Tags fx
+// @Produce json
+// @Param base query string false "Base currency"
+// @Param quote query string false "Quote currency"
+// @Success 200 {array} Rate
+// @Failure 400 {object} types.ErrorResponse
+// @Router /fx/rates [get]
+func (h *FXHandler) GetRatesHandler(w http.ResponseWriter, r *http.Request) {
+	rates, err := h.converter.Rates(r.URL.Query().Get("base"), r.URL.Query().Get("quote"))
+	switch {
+	case errors.Is(err, ErrInvalidCurrency):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to fetch rates", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, rates, "", auth.GetRequestID(r))
+}
+
+// ConvertHandler godoc
+// @Summary Convert an amount
+// @Description Convert an amount at the rate in force on a date. The target defaults to the base currency and the date to today.
+// @Tags fx
+// @Produce json
+// @Param amount query number true "Amount"
+// @Param from query string true "Source currency"
+// @Param to query string false "Target currency"
+// @Param date query string false "Rate date (YYYY-MM-DD)"
+// @Success 200 {object} Conversion
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /fx/convert [get]
+func (h *FXHandler) ConvertHandler(w http.ResponseWriter, r *http.Request) {
+	amount, err := strconv.ParseFloat(r.URL.Query().Get("amount"), 64)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Amount must be a number", auth.GetRequestID(r))
+		return
+	}
+
+	asOf := time.Now()
+	if value := r.URL.Query().Get("date"); value != "" {
+		asOf, err = time.Parse(dateLayout, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Date must be YYYY-MM-DD", auth.GetRequestID(r))
+			return
+		}
+	}
+
+	to := r.URL.Query().Get("to")
+	if to == "" {
+		to = h.converter.BaseCurrency()
+	}
+
+	conversion, err := h.converter.Convert(amount, r.URL.Query().Get("from"), to, asOf)
+	switch {
+	case errors.Is(err, ErrInvalidCurrency):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrRateNotFound):
+		writeError(w, http.StatusNotFound, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to convert amount", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, conversion, "", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the FX routes with the given router
+func (h *FXHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/fx/rates", h.GetRatesHandler)
+	r.Post("/fx/rates", h.ImportRatesHandler)
+	r.Get("/fx/convert", h.ConvertHandler)
+}
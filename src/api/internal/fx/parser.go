@@ -0,0 +1,233 @@
+package fx
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	// ErrUnknownFormat is returned when a rate file is neither CSV nor JSON
+	ErrUnknownFormat = errors.New("unknown rate file format")
+	// ErrInvalidRate is returned when a rate file contains an unusable rate
+	ErrInvalidRate = errors.New("invalid FX rate")
+	// ErrInvalidCurrency is returned for a code that is not three letters
+	ErrInvalidCurrency = errors.New("invalid currency code")
+)
+
+const dateLayout = "2006-01-02"
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// csvColumns maps accepted CSV header names to rate fields
+var csvColumns = map[string]string{
+	"base":           "base",
+	"from":           "base",
+	"quote":          "quote",
+	"to":             "quote",
+	"currency":       "quote",
+	"rate":           "rate",
+	"effective_date": "effective_date",
+	"date":           "effective_date",
+}
+
+// NormalizeCurrency upper-cases a currency code and checks it is three letters
+func NormalizeCurrency(code string) (string, error) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if !currencyPattern.MatchString(code) {
+		return "", fmt.Errorf("%w: %q", ErrInvalidCurrency, code)
+	}
+	return code, nil
+}
+
+// ParseFormat parses a format name such as "csv" or "json"
+func ParseFormat(value string) (Format, error) {
+	switch Format(strings.ToLower(strings.TrimSpace(value))) {
+	case FormatCSV:
+		return FormatCSV, nil
+	case FormatJSON:
+		return FormatJSON, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, value)
+}
+
+// DetectFormat determines the rate file format from the file name, falling back to the content
+func DetectFormat(fileName string, content []byte) (Format, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".json":
+		return FormatJSON, nil
+	}
+
+	trimmed := bytes.TrimSpace(bytes.TrimPrefix(content, []byte("\ufeff")))
+	if bytes.HasPrefix(trimmed, []byte("[")) || bytes.HasPrefix(trimmed, []byte("{")) {
+		return FormatJSON, nil
+	}
+	if len(trimmed) > 0 {
+		return FormatCSV, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ParseRates parses rate file content in the given format
+func ParseRates(format Format, r io.Reader) ([]Rate, error) {
+	var rates []Rate
+	var err error
+	switch format {
+	case FormatCSV:
+		rates, err = parseCSV(r)
+	case FormatJSON:
+		rates, err = parseJSON(r)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: the file contains no rates", ErrInvalidRate)
+	}
+	if len(rates) > MaxRateRows {
+		return nil, fmt.Errorf("%w: more than %d rates", ErrInvalidRate, MaxRateRows)
+	}
+	return rates, nil
+}
+
+// parseCSV reads a CSV file with a header row of base, quote, rate and effective_date
+func parseCSV(r io.Reader) ([]Rate, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[key]; ok {
+			if _, exists := columns[field]; !exists {
+				columns[field] = i
+			}
+		}
+	}
+	for _, field := range []string{"base", "quote", "rate", "effective_date"} {
+		if _, ok := columns[field]; !ok {
+			return nil, fmt.Errorf("%w: CSV file is missing a %s column", ErrInvalidRate, field)
+		}
+	}
+
+	get := func(record []string, field string) string {
+		if i := columns[field]; i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var rates []Rate
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", line, err)
+		}
+
+		value, err := strconv.ParseFloat(get(record, "rate"), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: rate %q is not a number", ErrInvalidRate, line, get(record, "rate"))
+		}
+		rate, err := newRate(get(record, "base"), get(record, "quote"), value, get(record, "effective_date"))
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// rateSnapshot is a JSON document with every quote against one base on one date
+type rateSnapshot struct {
+	Base  string             `json:"base"`
+	Date  string             `json:"date"`
+	Rates map[string]float64 `json:"rates"`
+}
+
+// parseJSON reads either an array of rates or a snapshot of quotes against one base
+func parseJSON(r io.Reader) ([]Rate, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading JSON file: %w", err)
+	}
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, []byte("\ufeff")))
+
+	var rates []Rate
+	if bytes.HasPrefix(data, []byte("[")) {
+		var entries []Rate
+		if err := json.Unmarshal(data, &entries); err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+		}
+		for i, entry := range entries {
+			rate, err := newRate(entry.Base, entry.Quote, entry.Rate, entry.EffectiveDate)
+			if err != nil {
+				return nil, fmt.Errorf("entry %d: %w", i+1, err)
+			}
+			rates = append(rates, rate)
+		}
+		return rates, nil
+	}
+
+	var snapshot rateSnapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidRate, err)
+	}
+	quotes := make([]string, 0, len(snapshot.Rates))
+	for quote := range snapshot.Rates {
+		quotes = append(quotes, quote)
+	}
+	sort.Strings(quotes)
+	for _, quote := range quotes {
+		rate, err := newRate(snapshot.Base, quote, snapshot.Rates[quote], snapshot.Date)
+		if err != nil {
+			return nil, fmt.Errorf("rate %s: %w", quote, err)
+		}
+		rates = append(rates, rate)
+	}
+	return rates, nil
+}
+
+// newRate validates and normalises one parsed rate
+func newRate(base, quote string, value float64, effectiveDate string) (Rate, error) {
+	base, err := NormalizeCurrency(base)
+	if err != nil {
+		return Rate{}, fmt.Errorf("%w: base: %v", ErrInvalidRate, err)
+	}
+	quote, err = NormalizeCurrency(quote)
+	if err != nil {
+		return Rate{}, fmt.Errorf("%w: quote: %v", ErrInvalidRate, err)
+	}
+	if base == quote {
+		return Rate{}, fmt.Errorf("%w: base and quote are both %s", ErrInvalidRate, base)
+	}
+	if value <= 0 || math.IsInf(value, 0) || math.IsNaN(value) {
+		return Rate{}, fmt.Errorf("%w: rate must be a positive number", ErrInvalidRate)
+	}
+	effectiveDate = strings.TrimSpace(effectiveDate)
+	if _, err := time.Parse(dateLayout, effectiveDate); err != nil {
+		return Rate{}, fmt.Errorf("%w: effective date %q is not YYYY-MM-DD", ErrInvalidRate, effectiveDate)
+	}
+	return Rate{Base: base, Quote: quote, Rate: value, EffectiveDate: effectiveDate}, nil
+}
@@ -0,0 +1,53 @@
+package fx
+
+import "time"
+
+// Format is the file format of a rate import
+type Format string
+
+const (
+	FormatCSV  Format = "csv"
+	FormatJSON Format = "json"
+)
+
+// MaxRateRows limits the number of rates in one import
+const MaxRateRows = 10000
+
+// Rate is the price of one unit of Base in Quote, in force from EffectiveDate until a newer rate for the pair
+type Rate struct {
+	Base          string    `json:"base" example:"EUR"`
+	Quote         string    `json:"quote" example:"USD"`
+	Rate          float64   `json:"rate" example:"1.0842"`
+	EffectiveDate string    `json:"effective_date" example:"2025-01-31"`
+	Source        string    `json:"source,omitempty" example:"rates-2025-01.csv"`
+	ImportedAt    time.Time `json:"imported_at"`
+	ImportedBy    string    `json:"imported_by,omitempty"`
+}
+
+// RateImport summarises an imported rate file
+type RateImport struct {
+	Source   string `json:"source"`
+	Format   Format `json:"format"`
+	Imported int    `json:"imported"`
+	Rates    []Rate `json:"rates"`
+}
+
+// Conversion is an amount converted at the rate in force on a date
+type Conversion struct {
+	From          string  `json:"from" example:"EUR"`
+	To            string  `json:"to" example:"USD"`
+	Amount        float64 `json:"amount" example:"100.00"`
+	Converted     float64 `json:"converted" example:"108.42"`
+	Rate          float64 `json:"rate" example:"1.0842"`
+	EffectiveDate string  `json:"effective_date" example:"2025-01-31"`
+}
+
+// Lock fixes the base currency value of a payment at the rate in force when it was created
+type Lock struct {
+	Currency     string    `json:"currency" example:"EUR"`
+	BaseCurrency string    `json:"base_currency" example:"USD"`
+	Rate         float64   `json:"fx_rate" example:"1.0842"`
+	RateDate     string    `json:"fx_rate_date" example:"2025-01-31"`
+	BaseAmount   float64   `json:"base_amount" example:"108.42"`
+	LockedAt     time.Time `json:"locked_at"`
+}
@@ -0,0 +1,90 @@
+package iso20022
+
+import (
+	"api/internal/db"
+	"api/internal/payment"
+	"api/internal/tenant"
+	"context"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ExportRepo represents the repository for selecting payments to export
+type ExportRepo struct {
+	DB *db.DB
+}
+
+// NewExportRepo creates a new instance of ExportRepo
+func NewExportRepo() *ExportRepo {
+	db := db.NewDB()
+	return &ExportRepo{DB: db}
+}
+
+// exportColumns lists the payment columns in the order GetPayments reads them
+const exportColumns = `
+	payment_id, COALESCE(id, ''), amount, payment_method,
+	COALESCE(payment_date, ''), COALESCE(pay_to, ''), COALESCE(note, ''),
+	COALESCE(status, ''), COALESCE(description, ''), COALESCE(currency, ''),
+	created_at, updated_at`
+
+// GetPayments selects the payments of the tenant of ctx whose status is one of statuses,
+// filtered by payment date range and batch
+func (er *ExportRepo) GetPayments(ctx context.Context, statuses []string, filter Filter) ([]payment.Payment, error) {
+	scoped, err := tenant.Scope(ctx, er.DB.Connection)
+	if err != nil {
+		return nil, err
+	}
+
+	var conditions []string
+	var args []interface{}
+	if len(statuses) > 0 {
+		conditions = append(conditions, "LOWER(status) IN (?"+strings.Repeat(", ?", len(statuses)-1)+")")
+		for _, status := range statuses {
+			args = append(args, strings.ToLower(status))
+		}
+	}
+	if filter.From != "" {
+		conditions = append(conditions, "payment_date >= ?")
+		args = append(args, filter.From)
+	}
+	if filter.To != "" {
+		conditions = append(conditions, "payment_date <= ?")
+		args = append(args, filter.To)
+	}
+	if filter.BatchID != "" {
+		conditions = append(conditions, "payment_id IN (SELECT payment_id FROM payment_batch_rows WHERE batch_id = ?)")
+		args = append(args, filter.BatchID)
+	}
+
+	rows, err := scoped.Select("payments", exportColumns, strings.Join(conditions, " AND "), "payment_date, created_at, payment_id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payments for export: %w", err)
+	}
+	defer rows.Close()
+
+	var payments []payment.Payment
+	for rows.Next() {
+		var p payment.Payment
+		err := rows.Scan(
+			&p.PaymentID,
+			&p.ID,
+			&p.Amount,
+			&p.PaymentMethod,
+			&p.PaymentDate,
+			&p.PayTo,
+			&p.Note,
+			&p.Status,
+			&p.Description,
+			&p.Currency,
+			&p.CreatedAt,
+			&p.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment for export: %w", err)
+		}
+		payments = append(payments, p)
+	}
+	return payments, rows.Err()
+}
@@ -0,0 +1,109 @@
+package iso20022
+
+import (
+	"api/config"
+	"api/internal/payment"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrInvalidFilter is returned for an unusable export selection
+var ErrInvalidFilter = errors.New("invalid export filter")
+
+// DefaultPain001Status selects payments that have not been sent to the bank yet
+const DefaultPain001Status = "pending"
+
+// settledStatuses are the payment statuses reported in camt.054
+var settledStatuses = []string{payment.StatusCompleted, payment.StatusCaptured}
+
+// Exporter selects payments and renders them as ISO 20022 messages
+type Exporter struct {
+	repo   *ExportRepo
+	debtor Debtor
+	now    func() time.Time
+}
+
+// NewExporter creates an Exporter using the debtor account from configuration
+func NewExporter() *Exporter {
+	var debtor Debtor
+	if cfg := config.NewConfig(); cfg != nil {
+		debtor = Debtor{Name: cfg.DebtorName, IBAN: cfg.DebtorIBAN, BIC: cfg.DebtorBIC}
+	}
+	return NewExporterWithRepo(NewExportRepo(), debtor)
+}
+
+// NewExporterWithRepo creates an Exporter on the given repository and debtor account
+func NewExporterWithRepo(repo *ExportRepo, debtor Debtor) *Exporter {
+	return &Exporter{repo: repo, debtor: debtor, now: time.Now}
+}
+
+// Export renders the selected payments of the tenant of ctx as a pain.001 or camt.054 message
+func (e *Exporter) Export(ctx context.Context, messageType string, filter Filter, messageID string) ([]byte, error) {
+	if err := filter.validate(); err != nil {
+		return nil, err
+	}
+	opts := Options{MessageID: messageID, CreatedAt: e.now(), Debtor: e.debtor}
+
+	switch strings.ToLower(messageType) {
+	case MessagePain001:
+		status := filter.Status
+		if status == "" {
+			status = DefaultPain001Status
+		}
+		payments, err := e.repo.GetPayments(ctx, []string{status}, filter)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := BuildPain001(payments, opts)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(doc)
+
+	case MessageCamt054:
+		statuses := settledStatuses
+		if filter.Status != "" {
+			if !isSettled(filter.Status) {
+				return nil, fmt.Errorf("%w: camt.054 only reports settled payments", ErrInvalidFilter)
+			}
+			statuses = []string{filter.Status}
+		}
+		payments, err := e.repo.GetPayments(ctx, statuses, filter)
+		if err != nil {
+			return nil, err
+		}
+		doc, err := BuildCamt054(payments, opts)
+		if err != nil {
+			return nil, err
+		}
+		return Marshal(doc)
+	}
+	return nil, fmt.Errorf("%w: unsupported message type %q", ErrInvalidFilter, messageType)
+}
+
+func (f Filter) validate() error {
+	for _, date := range []string{f.From, f.To} {
+		if date == "" {
+			continue
+		}
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return fmt.Errorf("%w: %q is not YYYY-MM-DD", ErrInvalidFilter, date)
+		}
+	}
+	if f.From != "" && f.To != "" && f.From > f.To {
+		return fmt.Errorf("%w: from is after to", ErrInvalidFilter)
+	}
+	return nil
+}
+
+func isSettled(status string) bool {
+	for _, settled := range settledStatuses {
+		if strings.EqualFold(status, settled) {
+			return true
+		}
+	}
+	return false
+}
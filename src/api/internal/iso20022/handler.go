@@ -0,0 +1,94 @@
+package iso20022
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// ExportHandler handles HTTP requests for ISO 20022 payment exports
+type ExportHandler struct {
+	exporter *Exporter
+}
+
+// NewExportHandler creates a new ExportHandler
+func NewExportHandler(exporter *Exporter) *ExportHandler {
+	return &ExportHandler{exporter: exporter}
+}
+
+// ExportPain001Handler godoc
+// @Summary Export payments as pain.001
+// @Description Export payments as an ISO 20022 pain.001.001.09 credit transfer initiation, one PmtInf per execution date. status defaults to pending.
+// @Tags payments
+// @Produce xml
+// @Param status query string false "Payment status"
+// @Param from query string false "First payment date (YYYY-MM-DD)"
+// @Param to query string false "Last payment date (YYYY-MM-DD)"
+// @Param batch_id query string false "Payment batch ID"
+// @Param message_id query string false "Message ID (generated when omitted)"
+// @Success 200 {string} string "pain.001 XML"
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /payments/export/pain.001 [get]
+func (h *ExportHandler) ExportPain001Handler(w http.ResponseWriter, r *http.Request) {
+	h.export(w, r, MessagePain001)
+}
+
+// ExportCamt054Handler godoc
+// @Summary Export settled payments as camt.054
+// @Description Export completed and captured payments as an ISO 20022 camt.054.001.08 debit notification
+// @Tags payments
+// @Produce xml
+// @Param status query string false "completed or captured"
+// @Param from query string false "First payment date (YYYY-MM-DD)"
+// @Param to query string false "Last payment date (YYYY-MM-DD)"
+// @Param batch_id query string false "Payment batch ID"
+// @Param message_id query string false "Message ID (generated when omitted)"
+// @Success 200 {string} string "camt.054 XML"
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /payments/export/camt.054 [get]
+func (h *ExportHandler) ExportCamt054Handler(w http.ResponseWriter, r *http.Request) {
+	h.export(w, r, MessageCamt054)
+}
+
+func (h *ExportHandler) export(w http.ResponseWriter, r *http.Request, messageType string) {
+	query := r.URL.Query()
+	filter := Filter{
+		Status:  query.Get("status"),
+		From:    query.Get("from"),
+		To:      query.Get("to"),
+		BatchID: query.Get("batch_id"),
+	}
+
+	body, err := h.exporter.Export(r.Context(), messageType, filter, query.Get("message_id"))
+	switch {
+	case errors.Is(err, ErrInvalidFilter):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrNoPayments):
+		writeError(w, http.StatusNotFound, "No payments match the selection", auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrInvalidMessage):
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to export payments", auth.GetRequestID(r))
+		return
+	}
+
+	fileName := fmt.Sprintf("%s-%s.xml", messageType, time.Now().UTC().Format("20060102150405"))
+	w.Header().Set("Content-Type", "application/xml")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+fileName+`"`)
+	w.WriteHeader(http.StatusOK)
+	w.Write(body)
+}
+
+// RegisterRoutes registers the export routes with the given router
+func (h *ExportHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/payments/export/pain.001", h.ExportPain001Handler)
+	r.Get("/payments/export/camt.054", h.ExportCamt054Handler)
+}
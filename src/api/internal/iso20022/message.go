@@ -0,0 +1,239 @@
+package iso20022
+
+import (
+	"api/internal/ledger"
+	"api/internal/payment"
+	"encoding/xml"
+	"errors"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrNoPayments is returned when the selection is empty
+var ErrNoPayments = errors.New("no payments to export")
+
+const (
+	dateLayout     = "2006-01-02"
+	dateTimeLayout = "2006-01-02T15:04:05"
+)
+
+// BuildPain001 builds a credit transfer initiation with one payment information block per execution date
+func BuildPain001(payments []payment.Payment, opts Options) (*Pain001, error) {
+	if len(payments) == 0 {
+		return nil, ErrNoPayments
+	}
+	opts = opts.withDefaults("PAIN001")
+
+	byDate := make(map[string][]payment.Payment)
+	var dates []string
+	for _, p := range payments {
+		date := executionDate(p, opts.CreatedAt)
+		if _, ok := byDate[date]; !ok {
+			dates = append(dates, date)
+		}
+		byDate[date] = append(byDate[date], p)
+	}
+	sort.Strings(dates)
+
+	doc := &Pain001{Xmlns: Pain001Namespace}
+	var total int64
+	for i, date := range dates {
+		block := PaymentInformation{
+			PaymentInfoID:      truncate(opts.MessageID+"-"+strconv.Itoa(i+1), 35),
+			PaymentMethod:      "TRF",
+			NumberOfTxs:        strconv.Itoa(len(byDate[date])),
+			RequestedExecution: DateChoice{Date: date},
+			Debtor:             Party{Name: truncate(opts.Debtor.Name, 140)},
+			DebtorAccount:      Account{IBAN: opts.Debtor.IBAN},
+			DebtorAgent:        agent(opts.Debtor.BIC),
+		}
+
+		var sum int64
+		for _, p := range byDate[date] {
+			minor := ledger.ToMinor(p.Amount)
+			sum += minor
+			block.CreditTransfers = append(block.CreditTransfers, CreditTransfer{
+				InstructionID: reference(p.PaymentID),
+				EndToEndID:    reference(p.PaymentID),
+				Amount:        amount(minor, p.Currency),
+				Creditor:      Party{Name: truncate(p.PayTo, 140)},
+				Remittance:    remittance(p),
+			})
+		}
+		block.ControlSum = decimal(sum)
+		total += sum
+		doc.Initiate.PaymentInformation = append(doc.Initiate.PaymentInformation, block)
+	}
+
+	doc.Initiate.GroupHeader = GroupHeader{
+		MessageID:           opts.MessageID,
+		CreationDateTime:    opts.CreatedAt.Format(dateTimeLayout),
+		NumberOfTxs:         strconv.Itoa(len(payments)),
+		ControlSum:          decimal(total),
+		InitiatingPartyName: truncate(opts.Debtor.Name, 140),
+	}
+	return doc, doc.Validate()
+}
+
+// BuildCamt054 builds a debit notification for settled payments on the debtor account
+func BuildCamt054(payments []payment.Payment, opts Options) (*Camt054, error) {
+	if len(payments) == 0 {
+		return nil, ErrNoPayments
+	}
+	opts = opts.withDefaults("CAMT054")
+
+	notification := Notification{
+		ID:               opts.MessageID,
+		CreationDateTime: opts.CreatedAt.Format(dateTimeLayout),
+		Account:          Account{IBAN: opts.Debtor.IBAN},
+	}
+
+	var total int64
+	for _, p := range payments {
+		minor := ledger.ToMinor(p.Amount)
+		total += minor
+		date := settlementDate(p, opts.CreatedAt)
+		notification.Entries = append(notification.Entries, Entry{
+			Reference:   reference(p.PaymentID),
+			Amount:      amount(minor, p.Currency),
+			CreditDebit: "DBIT",
+			Status:      "BOOK",
+			BookingDate: DateChoice{Date: date},
+			ValueDate:   DateChoice{Date: date},
+			BankTxCode:  BankTxCode{Domain: "PMNT", Family: "ICDT", SubFamily: "ESCT"},
+			Details: EntryDetails{Transactions: []TransactionDetails{{
+				EndToEndID:  reference(p.PaymentID),
+				Amount:      amount(minor, p.Currency),
+				CreditDebit: "DBIT",
+				Creditor:    Party{Name: truncate(p.PayTo, 140)},
+				Remittance:  remittance(p),
+			}}},
+		})
+	}
+	notification.Summary = &EntriesSummary{
+		NumberOfEntries: strconv.Itoa(len(payments)),
+		Sum:             decimal(total),
+	}
+
+	doc := &Camt054{
+		Xmlns: Camt054Namespace,
+		Notification: DebitCreditNotification{
+			GroupHeader: NotificationHeader{
+				MessageID:        opts.MessageID,
+				CreationDateTime: opts.CreatedAt.Format(dateTimeLayout),
+			},
+			Notifications: []Notification{notification},
+		},
+	}
+	return doc, doc.Validate()
+}
+
+// Marshal renders a message as indented XML with a declaration
+func Marshal(doc interface{}) ([]byte, error) {
+	body, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return append([]byte(xml.Header), append(body, '\n')...), nil
+}
+
+func (opts Options) withDefaults(prefix string) Options {
+	if opts.CreatedAt.IsZero() {
+		opts.CreatedAt = time.Now()
+	}
+	opts.CreatedAt = opts.CreatedAt.UTC().Truncate(time.Second)
+	if opts.MessageID == "" {
+		opts.MessageID = prefix + "-" + opts.CreatedAt.Format("20060102150405")
+	}
+	return opts
+}
+
+// executionDate is the payment date, or the creation date when it is missing or invalid
+func executionDate(p payment.Payment, now time.Time) string {
+	if _, err := time.Parse(dateLayout, p.PaymentDate); err == nil {
+		return p.PaymentDate
+	}
+	return now.Format(dateLayout)
+}
+
+// settlementDate is the payment date, or the last update when it is missing or invalid
+func settlementDate(p payment.Payment, now time.Time) string {
+	if _, err := time.Parse(dateLayout, p.PaymentDate); err == nil {
+		return p.PaymentDate
+	}
+	if !p.UpdatedAt.IsZero() {
+		return p.UpdatedAt.UTC().Format(dateLayout)
+	}
+	return now.Format(dateLayout)
+}
+
+// reference turns a payment id into a Max35Text reference; uuids lose their hyphens to fit
+func reference(id string) string {
+	if len(id) > 35 {
+		id = strings.ReplaceAll(id, "-", "")
+	}
+	return truncate(id, 35)
+}
+
+func agent(bic string) Agent {
+	if bic == "" {
+		return Agent{Other: &OtherAgent{ID: "NOTPROVIDED"}}
+	}
+	return Agent{BIC: bic}
+}
+
+func amount(minor int64, currency string) Amount {
+	if currency == "" {
+		currency = ledger.DefaultCurrency
+	}
+	return Amount{Currency: strings.ToUpper(currency), Value: decimal(minor)}
+}
+
+func remittance(p payment.Payment) *Remittance {
+	text := p.Note
+	if text == "" {
+		text = p.Description
+	}
+	if text == "" {
+		return nil
+	}
+	return &Remittance{Unstructured: truncate(text, 140)}
+}
+
+// decimal formats minor units as a two-decimal amount
+func decimal(minor int64) string {
+	sign := ""
+	if minor < 0 {
+		sign = "-"
+		minor = -minor
+	}
+	return sign + strconv.FormatInt(minor/100, 10) + "." + leftPad(strconv.FormatInt(minor%100, 10))
+}
+
+func leftPad(cents string) string {
+	if len(cents) < 2 {
+		return "0" + cents
+	}
+	return cents
+}
+
+func truncate(s string, max int) string {
+	s = strings.TrimSpace(s)
+	runes := []rune(s)
+	if len(runes) > max {
+		return string(runes[:max])
+	}
+	return s
+}
+
+// parseDecimal reads an amount written by decimal back into minor units
+func parseDecimal(value string) (int64, bool) {
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(f, 0) || math.IsNaN(f) {
+		return 0, false
+	}
+	return ledger.ToMinor(f), true
+}
@@ -0,0 +1,188 @@
+package iso20022
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// Message namespaces
+const (
+	Pain001Namespace = "urn:iso:std:iso:20022:tech:xsd:pain.001.001.09"
+	Camt054Namespace = "urn:iso:std:iso:20022:tech:xsd:camt.054.001.08"
+)
+
+// Message types accepted by the exporter
+const (
+	MessagePain001 = "pain.001"
+	MessageCamt054 = "camt.054"
+)
+
+// Debtor identifies the account payments are made from
+type Debtor struct {
+	Name string `json:"name" example:"Example Co Ltd"`
+	IBAN string `json:"iban" example:"GB33BUKB20201555555555"`
+	BIC  string `json:"bic,omitempty" example:"BUKBGB22"`
+}
+
+// Options control the message header. Zero values are filled in when the message is built.
+type Options struct {
+	MessageID string
+	CreatedAt time.Time
+	Debtor    Debtor
+}
+
+// Filter selects the payments to export
+type Filter struct {
+	Status  string `json:"status"`
+	From    string `json:"from" example:"2025-01-01"`
+	To      string `json:"to" example:"2025-01-31"`
+	BatchID string `json:"batch_id"`
+}
+
+// Amount is an ActiveOrHistoricCurrencyAndAmount
+type Amount struct {
+	Currency string `xml:"Ccy,attr"`
+	Value    string `xml:",chardata"`
+}
+
+// DateChoice is a DateAndDateTime2Choice holding a date
+type DateChoice struct {
+	Date string `xml:"Dt"`
+}
+
+// Party holds a party name
+type Party struct {
+	Name string `xml:"Nm"`
+}
+
+// Account identifies an account by IBAN
+type Account struct {
+	IBAN string `xml:"Id>IBAN"`
+}
+
+// Agent identifies a financial institution by BIC, or as not provided
+type Agent struct {
+	BIC   string      `xml:"FinInstnId>BICFI,omitempty"`
+	Other *OtherAgent `xml:"FinInstnId>Othr,omitempty"`
+}
+
+// OtherAgent identifies an institution without a BIC
+type OtherAgent struct {
+	ID string `xml:"Id"`
+}
+
+// Remittance holds unstructured remittance information
+type Remittance struct {
+	Unstructured string `xml:"Ustrd"`
+}
+
+// Pain001 is a CustomerCreditTransferInitiationV09 document
+type Pain001 struct {
+	XMLName  xml.Name               `xml:"Document"`
+	Xmlns    string                 `xml:"xmlns,attr"`
+	Initiate CustomerCreditTransfer `xml:"CstmrCdtTrfInitn"`
+}
+
+// CustomerCreditTransfer is the body of a pain.001 message
+type CustomerCreditTransfer struct {
+	GroupHeader        GroupHeader          `xml:"GrpHdr"`
+	PaymentInformation []PaymentInformation `xml:"PmtInf"`
+}
+
+// GroupHeader is the pain.001 group header
+type GroupHeader struct {
+	MessageID           string `xml:"MsgId"`
+	CreationDateTime    string `xml:"CreDtTm"`
+	NumberOfTxs         string `xml:"NbOfTxs"`
+	ControlSum          string `xml:"CtrlSum"`
+	InitiatingPartyName string `xml:"InitgPty>Nm"`
+}
+
+// PaymentInformation groups the credit transfers requested for one execution date
+type PaymentInformation struct {
+	PaymentInfoID      string           `xml:"PmtInfId"`
+	PaymentMethod      string           `xml:"PmtMtd"`
+	NumberOfTxs        string           `xml:"NbOfTxs"`
+	ControlSum         string           `xml:"CtrlSum"`
+	RequestedExecution DateChoice       `xml:"ReqdExctnDt"`
+	Debtor             Party            `xml:"Dbtr"`
+	DebtorAccount      Account          `xml:"DbtrAcct"`
+	DebtorAgent        Agent            `xml:"DbtrAgt"`
+	CreditTransfers    []CreditTransfer `xml:"CdtTrfTxInf"`
+}
+
+// CreditTransfer is one CreditTransferTransaction
+type CreditTransfer struct {
+	InstructionID string      `xml:"PmtId>InstrId"`
+	EndToEndID    string      `xml:"PmtId>EndToEndId"`
+	Amount        Amount      `xml:"Amt>InstdAmt"`
+	Creditor      Party       `xml:"Cdtr"`
+	Remittance    *Remittance `xml:"RmtInf,omitempty"`
+}
+
+// Camt054 is a BankToCustomerDebitCreditNotificationV08 document
+type Camt054 struct {
+	XMLName      xml.Name                `xml:"Document"`
+	Xmlns        string                  `xml:"xmlns,attr"`
+	Notification DebitCreditNotification `xml:"BkToCstmrDbtCdtNtfctn"`
+}
+
+// DebitCreditNotification is the body of a camt.054 message
+type DebitCreditNotification struct {
+	GroupHeader   NotificationHeader `xml:"GrpHdr"`
+	Notifications []Notification     `xml:"Ntfctn"`
+}
+
+// NotificationHeader is the camt.054 group header
+type NotificationHeader struct {
+	MessageID        string `xml:"MsgId"`
+	CreationDateTime string `xml:"CreDtTm"`
+}
+
+// Notification reports the entries booked on one account
+type Notification struct {
+	ID               string          `xml:"Id"`
+	CreationDateTime string          `xml:"CreDtTm"`
+	Account          Account         `xml:"Acct"`
+	Summary          *EntriesSummary `xml:"TxsSummry,omitempty"`
+	Entries          []Entry         `xml:"Ntry"`
+}
+
+// EntriesSummary totals the entries of a notification
+type EntriesSummary struct {
+	NumberOfEntries string `xml:"TtlNtries>NbOfNtries"`
+	Sum             string `xml:"TtlNtries>Sum"`
+}
+
+// Entry is one booked entry
+type Entry struct {
+	Reference   string       `xml:"NtryRef"`
+	Amount      Amount       `xml:"Amt"`
+	CreditDebit string       `xml:"CdtDbtInd"`
+	Status      string       `xml:"Sts>Cd"`
+	BookingDate DateChoice   `xml:"BookgDt"`
+	ValueDate   DateChoice   `xml:"ValDt"`
+	BankTxCode  BankTxCode   `xml:"BkTxCd"`
+	Details     EntryDetails `xml:"NtryDtls"`
+}
+
+// BankTxCode is the domain bank transaction code of an entry
+type BankTxCode struct {
+	Domain    string `xml:"Domn>Cd"`
+	Family    string `xml:"Domn>Fmly>Cd"`
+	SubFamily string `xml:"Domn>Fmly>SubFmlyCd"`
+}
+
+// EntryDetails holds the transactions behind an entry
+type EntryDetails struct {
+	Transactions []TransactionDetails `xml:"TxDtls"`
+}
+
+// TransactionDetails describes one settled payment
+type TransactionDetails struct {
+	EndToEndID  string      `xml:"Refs>EndToEndId"`
+	Amount      Amount      `xml:"Amt"`
+	CreditDebit string      `xml:"CdtDbtInd"`
+	Creditor    Party       `xml:"RltdPties>Cdtr>Pty"`
+	Remittance  *Remittance `xml:"RmtInf,omitempty"`
+}
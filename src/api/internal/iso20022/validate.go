@@ -0,0 +1,202 @@
+package iso20022
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// ErrInvalidMessage is returned when a message breaks the schema's structural rules
+var ErrInvalidMessage = errors.New("invalid ISO 20022 message")
+
+// Patterns and facets from the pain.001.001.09 and camt.054.001.08 schemas
+var (
+	ibanPattern     = regexp.MustCompile(`^[A-Z]{2}[0-9]{2}[a-zA-Z0-9]{1,30}$`)
+	bicPattern      = regexp.MustCompile(`^[A-Z0-9]{4}[A-Z]{2}[A-Z0-9]{2}([A-Z0-9]{3})?$`)
+	currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+	countPattern    = regexp.MustCompile(`^[0-9]{1,15}$`)
+	amountPattern   = regexp.MustCompile(`^[0-9]{1,13}(\.[0-9]{1,5})?$`)
+)
+
+// validator collects every violation instead of stopping at the first
+type validator struct {
+	problems []string
+}
+
+func (v *validator) fail(path, format string, args ...interface{}) {
+	v.problems = append(v.problems, path+": "+fmt.Sprintf(format, args...))
+}
+
+func (v *validator) text(path, value string, max int) {
+	switch {
+	case strings.TrimSpace(value) == "":
+		v.fail(path, "is required")
+	case utf8.RuneCountInString(value) > max:
+		v.fail(path, "exceeds %d characters", max)
+	}
+}
+
+func (v *validator) pattern(path, value string, pattern *regexp.Regexp) {
+	if !pattern.MatchString(value) {
+		v.fail(path, "%q does not match %s", value, pattern)
+	}
+}
+
+func (v *validator) date(path, value string) {
+	if _, err := time.Parse(dateLayout, value); err != nil {
+		v.fail(path, "%q is not an ISODate", value)
+	}
+}
+
+func (v *validator) dateTime(path, value string) {
+	if _, err := time.Parse(dateTimeLayout, value); err != nil {
+		v.fail(path, "%q is not an ISODateTime", value)
+	}
+}
+
+func (v *validator) amount(path string, value Amount) int64 {
+	v.pattern(path+"/@Ccy", value.Currency, currencyPattern)
+	v.pattern(path, value.Value, amountPattern)
+	minor, _ := parseDecimal(value.Value)
+	if minor <= 0 {
+		v.fail(path, "must be greater than zero")
+	}
+	return minor
+}
+
+func (v *validator) count(path, value string, want int) {
+	v.pattern(path, value, countPattern)
+	if value != strconv.Itoa(want) {
+		v.fail(path, "is %s but there are %d transactions", value, want)
+	}
+}
+
+func (v *validator) sum(path, value string, want int64) {
+	v.pattern(path, value, amountPattern)
+	if got, _ := parseDecimal(value); got != want {
+		v.fail(path, "is %s but the transactions sum to %s", value, decimal(want))
+	}
+}
+
+func (v *validator) account(path string, account Account) {
+	v.pattern(path+"/Id/IBAN", account.IBAN, ibanPattern)
+}
+
+func (v *validator) err() error {
+	if len(v.problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("%w: %s", ErrInvalidMessage, strings.Join(v.problems, "; "))
+}
+
+// Validate checks mandatory elements, facets and totals of a pain.001 message
+func (doc *Pain001) Validate() error {
+	v := &validator{}
+	if doc.Xmlns != Pain001Namespace {
+		v.fail("Document/@xmlns", "must be %s", Pain001Namespace)
+	}
+
+	header := doc.Initiate.GroupHeader
+	v.text("GrpHdr/MsgId", header.MessageID, 35)
+	v.dateTime("GrpHdr/CreDtTm", header.CreationDateTime)
+	v.text("GrpHdr/InitgPty/Nm", header.InitiatingPartyName, 140)
+	if len(doc.Initiate.PaymentInformation) == 0 {
+		v.fail("PmtInf", "at least one is required")
+	}
+
+	transactions := 0
+	var total int64
+	for i, block := range doc.Initiate.PaymentInformation {
+		path := fmt.Sprintf("PmtInf[%d]", i+1)
+		v.text(path+"/PmtInfId", block.PaymentInfoID, 35)
+		if block.PaymentMethod != "TRF" {
+			v.fail(path+"/PmtMtd", "must be TRF")
+		}
+		v.date(path+"/ReqdExctnDt/Dt", block.RequestedExecution.Date)
+		v.text(path+"/Dbtr/Nm", block.Debtor.Name, 140)
+		v.account(path+"/DbtrAcct", block.DebtorAccount)
+		switch {
+		case block.DebtorAgent.BIC != "":
+			v.pattern(path+"/DbtrAgt/FinInstnId/BICFI", block.DebtorAgent.BIC, bicPattern)
+		case block.DebtorAgent.Other != nil:
+			v.text(path+"/DbtrAgt/FinInstnId/Othr/Id", block.DebtorAgent.Other.ID, 35)
+		default:
+			v.fail(path+"/DbtrAgt/FinInstnId", "BICFI or Othr is required")
+		}
+		if len(block.CreditTransfers) == 0 {
+			v.fail(path+"/CdtTrfTxInf", "at least one is required")
+		}
+
+		var sum int64
+		for j, tx := range block.CreditTransfers {
+			txPath := fmt.Sprintf("%s/CdtTrfTxInf[%d]", path, j+1)
+			v.text(txPath+"/PmtId/InstrId", tx.InstructionID, 35)
+			v.text(txPath+"/PmtId/EndToEndId", tx.EndToEndID, 35)
+			sum += v.amount(txPath+"/Amt/InstdAmt", tx.Amount)
+			v.text(txPath+"/Cdtr/Nm", tx.Creditor.Name, 140)
+			if tx.Remittance != nil {
+				v.text(txPath+"/RmtInf/Ustrd", tx.Remittance.Unstructured, 140)
+			}
+		}
+		v.count(path+"/NbOfTxs", block.NumberOfTxs, len(block.CreditTransfers))
+		v.sum(path+"/CtrlSum", block.ControlSum, sum)
+		transactions += len(block.CreditTransfers)
+		total += sum
+	}
+	v.count("GrpHdr/NbOfTxs", header.NumberOfTxs, transactions)
+	v.sum("GrpHdr/CtrlSum", header.ControlSum, total)
+	return v.err()
+}
+
+// Validate checks mandatory elements, facets and totals of a camt.054 message
+func (doc *Camt054) Validate() error {
+	v := &validator{}
+	if doc.Xmlns != Camt054Namespace {
+		v.fail("Document/@xmlns", "must be %s", Camt054Namespace)
+	}
+
+	header := doc.Notification.GroupHeader
+	v.text("GrpHdr/MsgId", header.MessageID, 35)
+	v.dateTime("GrpHdr/CreDtTm", header.CreationDateTime)
+	if len(doc.Notification.Notifications) == 0 {
+		v.fail("Ntfctn", "at least one is required")
+	}
+
+	for i, notification := range doc.Notification.Notifications {
+		path := fmt.Sprintf("Ntfctn[%d]", i+1)
+		v.text(path+"/Id", notification.ID, 35)
+		v.dateTime(path+"/CreDtTm", notification.CreationDateTime)
+		v.account(path+"/Acct", notification.Account)
+
+		var sum int64
+		for j, entry := range notification.Entries {
+			entryPath := fmt.Sprintf("%s/Ntry[%d]", path, j+1)
+			v.text(entryPath+"/NtryRef", entry.Reference, 35)
+			sum += v.amount(entryPath+"/Amt", entry.Amount)
+			if entry.CreditDebit != "CRDT" && entry.CreditDebit != "DBIT" {
+				v.fail(entryPath+"/CdtDbtInd", "must be CRDT or DBIT")
+			}
+			v.text(entryPath+"/Sts/Cd", entry.Status, 4)
+			v.date(entryPath+"/BookgDt/Dt", entry.BookingDate.Date)
+			v.date(entryPath+"/ValDt/Dt", entry.ValueDate.Date)
+			v.text(entryPath+"/BkTxCd/Domn/Cd", entry.BankTxCode.Domain, 4)
+			v.text(entryPath+"/BkTxCd/Domn/Fmly/Cd", entry.BankTxCode.Family, 4)
+			v.text(entryPath+"/BkTxCd/Domn/Fmly/SubFmlyCd", entry.BankTxCode.SubFamily, 4)
+			for k, tx := range entry.Details.Transactions {
+				txPath := fmt.Sprintf("%s/NtryDtls/TxDtls[%d]", entryPath, k+1)
+				v.text(txPath+"/Refs/EndToEndId", tx.EndToEndID, 35)
+				v.amount(txPath+"/Amt", tx.Amount)
+				v.text(txPath+"/RltdPties/Cdtr/Pty/Nm", tx.Creditor.Name, 140)
+			}
+		}
+		if notification.Summary != nil {
+			v.count(path+"/TxsSummry/TtlNtries/NbOfNtries", notification.Summary.NumberOfEntries, len(notification.Entries))
+			v.sum(path+"/TxsSummry/TtlNtries/Sum", notification.Summary.Sum, sum)
+		}
+	}
+	return v.err()
+}
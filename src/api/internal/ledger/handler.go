@@ -0,0 +1,274 @@
+package ledger
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// LedgerHandler handles HTTP requests for the general ledger
+type LedgerHandler struct {
+	ledger *Ledger
+}
+
+// NewLedgerHandler creates a new LedgerHandler
+func NewLedgerHandler(ledger *Ledger) *LedgerHandler {
+	return &LedgerHandler{ledger: ledger}
+}
+
+// ReverseRequest represents the body of a reversal request
+type ReverseRequest struct {
+	Reason string `json:"reason" example:"Posted to the wrong account"`
+}
+
+// GetAccountsHandler godoc
+// @Summary List ledger accounts
+// @Description Get the chart of accounts
+// @Tags ledger
+// @Produce json
+// @Success 200 {array} Account
+// @Router /ledger/accounts [get]
+func (h *LedgerHandler) GetAccountsHandler(w http.ResponseWriter, r *http.Request) {
+	accounts, err := h.ledger.GetAccounts()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch ledger accounts", auth.GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, accounts, "", auth.GetRequestID(r))
+}
+
+// CreateAccountHandler godoc
+// @Summary Create a ledger account
+// @Description Add an account to the chart of accounts
+// @Tags ledger
+// @Accept json
+// @Produce json
+// @Param account body Account true "Account"
+// @Success 201 {object} Account
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /ledger/accounts [post]
+func (h *LedgerHandler) CreateAccountHandler(w http.ResponseWriter, r *http.Request) {
+	var account Account
+	if err := json.NewDecoder(r.Body).Decode(&account); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+
+	account.CreatedBy = auth.Username(r)
+	err := h.ledger.CreateAccount(&account)
+	switch {
+	case errors.Is(err, ErrInvalidAccount):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrAccountExists):
+		writeError(w, http.StatusConflict, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to create ledger account", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, account, "Ledger account created successfully", auth.GetRequestID(r))
+}
+
+// GetBalanceHandler godoc
+// @Summary Get an account balance
+// @Description Get the balance of an account and its sub-accounts as of a date (defaults to now)
+// @Tags ledger
+// @Produce json
+// @Param code query string true "Account code"
+// @Param as_of query string false "Date (YYYY-MM-DD) or RFC3339 timestamp"
+// @Success 200 {object} Balance
+// @Failure 404 {object} types.ErrorResponse
+// @Router /ledger/accounts/balance [get]
+func (h *LedgerHandler) GetBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	code := r.URL.Query().Get("code")
+	if code == "" {
+		writeError(w, http.StatusBadRequest, "Account code is required", auth.GetRequestID(r))
+		return
+	}
+
+	asOf, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid as_of date", auth.GetRequestID(r))
+		return
+	}
+
+	balance, err := h.ledger.Balance(code, asOf)
+	if errors.Is(err, ErrAccountNotFound) {
+		writeError(w, http.StatusNotFound, "Ledger account not found", auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to calculate balance", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, balance, "", auth.GetRequestID(r))
+}
+
+// PostEntryHandler godoc
+// @Summary Post a manual journal entry
+// @Description Post a balanced journal entry. Amounts are in minor units; debits are positive and credits negative.
+// @Tags ledger
+// @Accept json
+// @Produce json
+// @Param entry body JournalEntry true "Journal entry"
+// @Success 201 {object} JournalEntry
+// @Failure 422 {object} types.ErrorResponse
+// @Router /ledger/entries [post]
+func (h *LedgerHandler) PostEntryHandler(w http.ResponseWriter, r *http.Request) {
+	var entry JournalEntry
+	if err := json.NewDecoder(r.Body).Decode(&entry); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+
+	entry.ReferenceType = RefManual
+	entry.ReversalOf = ""
+	entry.CreatedBy = auth.Username(r)
+	err := h.ledger.PostEntry(&entry)
+	if isEntryError(err) {
+		writeError(w, http.StatusUnprocessableEntity, err.Error(), auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to post journal entry", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, entry, "Journal entry posted successfully", auth.GetRequestID(r))
+}
+
+// GetEntryHandler godoc
+// @Summary Get a journal entry
+// @Description Get a journal entry with its postings
+// @Tags ledger
+// @Produce json
+// @Param id query string true "Entry ID"
+// @Success 200 {object} JournalEntry
+// @Failure 404 {object} types.ErrorResponse
+// @Router /ledger/entries [get]
+func (h *LedgerHandler) GetEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Entry ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	entry, err := h.ledger.GetEntry(id)
+	if errors.Is(err, ErrEntryNotFound) {
+		writeError(w, http.StatusNotFound, "Journal entry not found", auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch journal entry", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, entry, "", auth.GetRequestID(r))
+}
+
+// ReverseEntryHandler godoc
+// @Summary Reverse a journal entry
+// @Description Post a new entry that negates the original. Entries are never edited or deleted.
+// @Tags ledger
+// @Accept json
+// @Produce json
+// @Param id query string true "Entry ID"
+// @Param request body ReverseRequest false "Reason"
+// @Success 201 {object} JournalEntry
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /ledger/entries/reverse [post]
+func (h *LedgerHandler) ReverseEntryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Entry ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	var req ReverseRequest
+	if r.ContentLength > 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+			return
+		}
+	}
+
+	reversal, err := h.ledger.Reverse(id, req.Reason, auth.Username(r))
+	switch {
+	case errors.Is(err, ErrEntryNotFound):
+		writeError(w, http.StatusNotFound, "Journal entry not found", auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrAlreadyReversed), errors.Is(err, ErrReverseReversal):
+		writeError(w, http.StatusConflict, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to reverse journal entry", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, reversal, "Journal entry reversed successfully", auth.GetRequestID(r))
+}
+
+// TrialBalanceHandler godoc
+// @Summary Get the trial balance
+// @Description Get the net debit or credit of every account as of a date (defaults to now)
+// @Tags ledger
+// @Produce json
+// @Param as_of query string false "Date (YYYY-MM-DD) or RFC3339 timestamp"
+// @Success 200 {object} TrialBalance
+// @Router /ledger/trial-balance [get]
+func (h *LedgerHandler) TrialBalanceHandler(w http.ResponseWriter, r *http.Request) {
+	asOf, err := parseAsOf(r.URL.Query().Get("as_of"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid as_of date", auth.GetRequestID(r))
+		return
+	}
+
+	trialBalance, err := h.ledger.TrialBalance(asOf)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to build trial balance", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, trialBalance, "", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the ledger routes with the given router
+func (h *LedgerHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/ledger/accounts", h.GetAccountsHandler)
+	r.Post("/ledger/accounts", h.CreateAccountHandler)
+	r.Get("/ledger/accounts/balance", h.GetBalanceHandler)
+	r.Get("/ledger/entries", h.GetEntryHandler)
+	r.Post("/ledger/entries", h.PostEntryHandler)
+	r.Post("/ledger/entries/reverse", h.ReverseEntryHandler)
+	r.Get("/ledger/trial-balance", h.TrialBalanceHandler)
+}
+
+// parseAsOf parses an as_of parameter; a bare date means the end of that day
+func parseAsOf(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	day, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, err
+	}
+	return day.Add(24*time.Hour - time.Nanosecond), nil
+}
+
+func isEntryError(err error) bool {
+	return errors.Is(err, ErrUnbalancedEntry) ||
+		errors.Is(err, ErrInvalidPosting) ||
+		errors.Is(err, ErrAccountNotFound) ||
+		errors.Is(err, ErrCurrencyMismatch)
+}
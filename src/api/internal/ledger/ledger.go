@@ -0,0 +1,298 @@
+package ledger
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Ledger errors
+var (
+	ErrUnbalancedEntry   = errors.New("journal entry does not balance")
+	ErrInvalidPosting    = errors.New("journal entry needs at least two non-zero postings")
+	ErrAccountNotFound   = errors.New("ledger account not found")
+	ErrAccountExists     = errors.New("ledger account already exists")
+	ErrInvalidAccount    = errors.New("invalid ledger account")
+	ErrEntryNotFound     = errors.New("journal entry not found")
+	ErrAlreadyReversed   = errors.New("journal entry has already been reversed")
+	ErrReverseReversal   = errors.New("a reversal entry cannot be reversed")
+	ErrCurrencyMismatch  = errors.New("account currency does not match entry currency")
+	ErrNonPositiveAmount = errors.New("amount must be greater than zero")
+)
+
+// Account status values
+const (
+	AccountStatusActive   = 1
+	AccountStatusInactive = 0
+)
+
+// Ledger posts balanced journal entries and reports balances.
+// Entries are never updated or deleted; corrections are made with reversals.
+type Ledger struct {
+	repo *LedgerRepo
+	now  func() time.Time
+}
+
+// NewLedger creates a new Ledger backed by the application database
+func NewLedger() *Ledger {
+	return NewLedgerWithRepo(NewLedgerRepo())
+}
+
+// NewLedgerWithRepo creates a new Ledger backed by the given repository
+func NewLedgerWithRepo(repo *LedgerRepo) *Ledger {
+	return &Ledger{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// ToMinor converts a decimal amount into minor units (cents)
+func ToMinor(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
+// FromMinor converts minor units (cents) back into a decimal amount
+func FromMinor(amount int64) float64 {
+	return float64(amount) / 100
+}
+
+// ValidateEntry checks that an entry has at least two non-zero postings that sum to zero
+func ValidateEntry(entry *JournalEntry) error {
+	if len(entry.Postings) < 2 {
+		return ErrInvalidPosting
+	}
+
+	var sum int64
+	for _, posting := range entry.Postings {
+		if posting.Amount == 0 || strings.TrimSpace(posting.AccountCode) == "" {
+			return ErrInvalidPosting
+		}
+		sum += posting.Amount
+	}
+	if sum != 0 {
+		return fmt.Errorf("%w: postings sum to %d", ErrUnbalancedEntry, sum)
+	}
+	return nil
+}
+
+// CreateAccount creates a new top-level or sub-account
+func (l *Ledger) CreateAccount(account *Account) error {
+	account.Code = strings.ToUpper(strings.TrimSpace(account.Code))
+	if account.Code == "" || account.Name == "" {
+		return ErrInvalidAccount
+	}
+	switch account.Type {
+	case AccountTypeAsset, AccountTypeLiability, AccountTypeEquity, AccountTypeIncome, AccountTypeExpense:
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidAccount, account.Type)
+	}
+	if account.Currency == "" {
+		account.Currency = DefaultCurrency
+	}
+
+	if _, err := l.repo.GetAccount(account.Code); err == nil {
+		return ErrAccountExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	account.ParentCode = parentCode(account.Code)
+	account.CreatedAt = l.now()
+	account.StatusID = AccountStatusActive
+	return l.repo.InsertAccount(account)
+}
+
+// GetAccounts returns all ledger accounts
+func (l *Ledger) GetAccounts() ([]Account, error) {
+	return l.repo.GetAccounts()
+}
+
+// PostEntry validates and stores a balanced journal entry.
+// Sub-accounts such as LOANS_RECEIVABLE:LOAN-001 are created on first use.
+func (l *Ledger) PostEntry(entry *JournalEntry) error {
+	if err := ValidateEntry(entry); err != nil {
+		return err
+	}
+	if entry.Currency == "" {
+		entry.Currency = DefaultCurrency
+	}
+
+	var subAccounts []Account
+	for _, posting := range entry.Postings {
+		account, isNew, err := l.resolveAccount(posting.AccountCode, entry.CreatedBy)
+		if err != nil {
+			return err
+		}
+		if account.Currency != entry.Currency {
+			return fmt.Errorf("%w: %s is %s", ErrCurrencyMismatch, account.Code, account.Currency)
+		}
+		if isNew {
+			subAccounts = append(subAccounts, *account)
+		}
+	}
+
+	now := l.now()
+	entry.EntryID = uuid.New().String()
+	entry.CreatedAt = now
+	if entry.EffectiveAt.IsZero() {
+		entry.EffectiveAt = now
+	}
+	for i := range entry.Postings {
+		entry.Postings[i].PostingID = uuid.New().String()
+		entry.Postings[i].EntryID = entry.EntryID
+	}
+
+	return l.repo.InsertEntry(entry, subAccounts)
+}
+
+// Transfer posts a two-line entry moving amount (in minor units) from one account to another:
+// the destination is debited and the source is credited.
+func (l *Ledger) Transfer(fromAccount, toAccount string, amount int64, referenceType, referenceID, description, createdBy string) (*JournalEntry, error) {
+	if amount <= 0 {
+		return nil, ErrNonPositiveAmount
+	}
+
+	entry := &JournalEntry{
+		ReferenceType: referenceType,
+		ReferenceID:   referenceID,
+		Description:   description,
+		CreatedBy:     createdBy,
+		Postings: []Posting{
+			{AccountCode: toAccount, Amount: amount},
+			{AccountCode: fromAccount, Amount: -amount},
+		},
+	}
+	if err := l.PostEntry(entry); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+// HasEntry reports whether an entry was already posted for a business reference
+func (l *Ledger) HasEntry(referenceType, referenceID string) (bool, error) {
+	count, err := l.repo.CountEntriesByReference(referenceType, referenceID)
+	if err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// GetEntry returns a journal entry with its postings
+func (l *Ledger) GetEntry(entryID string) (*JournalEntry, error) {
+	entry, err := l.repo.GetEntry(entryID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrEntryNotFound
+	}
+	return entry, err
+}
+
+// Reverse posts a new entry that negates every posting of the original entry
+func (l *Ledger) Reverse(entryID, reason, createdBy string) (*JournalEntry, error) {
+	original, err := l.GetEntry(entryID)
+	if err != nil {
+		return nil, err
+	}
+	if original.ReversalOf != "" {
+		return nil, ErrReverseReversal
+	}
+
+	reversalID, err := l.repo.GetReversalEntryID(entryID)
+	if err != nil {
+		return nil, err
+	}
+	if reversalID != "" {
+		return nil, ErrAlreadyReversed
+	}
+
+	description := "Reversal of " + entryID
+	if reason != "" {
+		description += ": " + reason
+	}
+
+	reversal := &JournalEntry{
+		ReferenceType: RefReversal,
+		ReferenceID:   original.ReferenceID,
+		Description:   description,
+		Currency:      original.Currency,
+		ReversalOf:    original.EntryID,
+		CreatedBy:     createdBy,
+	}
+	for _, posting := range original.Postings {
+		reversal.Postings = append(reversal.Postings, Posting{
+			AccountCode: posting.AccountCode,
+			Amount:      -posting.Amount,
+		})
+	}
+
+	if err := l.PostEntry(reversal); err != nil {
+		return nil, err
+	}
+	return reversal, nil
+}
+
+// Balance returns the balance of an account, including its sub-accounts, as of the given time
+func (l *Ledger) Balance(code string, asOf time.Time) (*Balance, error) {
+	if _, err := l.repo.GetAccount(code); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrAccountNotFound
+		}
+		return nil, err
+	}
+	return l.repo.GetBalance(code, asOf)
+}
+
+// TrialBalance lists the net debit or credit of every account as of the given time
+func (l *Ledger) TrialBalance(asOf time.Time) (*TrialBalance, error) {
+	lines, err := l.repo.GetTrialBalanceLines(asOf)
+	if err != nil {
+		return nil, err
+	}
+
+	trialBalance := &TrialBalance{AsOf: asOf, Lines: lines}
+	for _, line := range lines {
+		trialBalance.TotalDebit += line.Debit
+		trialBalance.TotalCredit += line.Credit
+	}
+	trialBalance.Balanced = trialBalance.TotalDebit == trialBalance.TotalCredit
+	return trialBalance, nil
+}
+
+// resolveAccount returns an account, or a new sub-account derived from its parent when the
+// code does not exist yet; new sub-accounts are stored together with the entry that uses them
+func (l *Ledger) resolveAccount(code, createdBy string) (*Account, bool, error) {
+	account, err := l.repo.GetAccount(code)
+	if err == nil {
+		return account, false, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, false, err
+	}
+
+	parent := parentCode(code)
+	if parent == "" {
+		return nil, false, fmt.Errorf("%w: %s", ErrAccountNotFound, code)
+	}
+	parentAccount, err := l.repo.GetAccount(parent)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, false, fmt.Errorf("%w: %s", ErrAccountNotFound, parent)
+		}
+		return nil, false, err
+	}
+
+	return &Account{
+		Code:       code,
+		Name:       parentAccount.Name + " - " + code[len(parent)+1:],
+		Type:       parentAccount.Type,
+		Currency:   parentAccount.Currency,
+		ParentCode: parent,
+		CreatedAt:  l.now(),
+		CreatedBy:  createdBy,
+		StatusID:   AccountStatusActive,
+	}, true, nil
+}
@@ -0,0 +1,321 @@
+package ledger
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// LedgerRepo represents the repository for ledger operations
+type LedgerRepo struct {
+	DB *db.DB
+}
+
+// NewLedgerRepo creates a new instance of LedgerRepo
+func NewLedgerRepo() *LedgerRepo {
+	db := db.NewDB()
+	return &LedgerRepo{DB: db}
+}
+
+// InsertAccount inserts a new ledger account into the database
+func (lr *LedgerRepo) InsertAccount(account *Account) error {
+	_, err := lr.DB.Insert(`
+		INSERT INTO ledger_accounts (
+			code, name, type, currency, parent_code,
+			created_at, created_by, status_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		account.Code,
+		account.Name,
+		account.Type,
+		account.Currency,
+		account.ParentCode,
+		account.CreatedAt.UTC(),
+		account.CreatedBy,
+		account.StatusID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting ledger account: %w", err)
+	}
+	return nil
+}
+
+// GetAccount retrieves a ledger account by its code
+func (lr *LedgerRepo) GetAccount(code string) (*Account, error) {
+	row, err := lr.DB.QueryRow(`
+		SELECT code, name, type, currency, parent_code, created_at, created_by, status_id
+		FROM ledger_accounts
+		WHERE code = ?`, code)
+	if err != nil {
+		return nil, err
+	}
+
+	var account Account
+	err = row.Scan(
+		&account.Code,
+		&account.Name,
+		&account.Type,
+		&account.Currency,
+		&account.ParentCode,
+		&account.CreatedAt,
+		&account.CreatedBy,
+		&account.StatusID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &account, nil
+}
+
+// GetAccounts retrieves all ledger accounts ordered by code
+func (lr *LedgerRepo) GetAccounts() ([]Account, error) {
+	rows, err := lr.DB.Query(`
+		SELECT code, name, type, currency, parent_code, created_at, created_by, status_id
+		FROM ledger_accounts
+		ORDER BY code`)
+	if err != nil {
+		return nil, fmt.Errorf("error querying ledger accounts: %w", err)
+	}
+	defer rows.Close()
+
+	var accounts []Account
+	for rows.Next() {
+		var account Account
+		err := rows.Scan(
+			&account.Code,
+			&account.Name,
+			&account.Type,
+			&account.Currency,
+			&account.ParentCode,
+			&account.CreatedAt,
+			&account.CreatedBy,
+			&account.StatusID,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning ledger account: %w", err)
+		}
+		accounts = append(accounts, account)
+	}
+	return accounts, rows.Err()
+}
+
+// InsertEntry inserts a journal entry, its postings and the sub-accounts it opens in a single
+// transaction. Sub-accounts opened concurrently by another entry are kept as they are.
+func (lr *LedgerRepo) InsertEntry(entry *JournalEntry, subAccounts []Account) error {
+	tx, err := lr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	for _, account := range subAccounts {
+		_, err = tx.Exec(`
+			INSERT INTO ledger_accounts (
+				code, name, type, currency, parent_code,
+				created_at, created_by, status_id
+			) VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (code) DO NOTHING`,
+			account.Code,
+			account.Name,
+			account.Type,
+			account.Currency,
+			account.ParentCode,
+			account.CreatedAt.UTC(),
+			account.CreatedBy,
+			account.StatusID,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting ledger account: %w", err)
+		}
+	}
+
+	var reversalOf interface{}
+	if entry.ReversalOf != "" {
+		reversalOf = entry.ReversalOf
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO journal_entries (
+			entry_id, reference_type, reference_id, description, currency,
+			effective_at, reversal_of, created_at, created_by
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		entry.EntryID,
+		entry.ReferenceType,
+		entry.ReferenceID,
+		entry.Description,
+		entry.Currency,
+		entry.EffectiveAt.UTC(),
+		reversalOf,
+		entry.CreatedAt.UTC(),
+		entry.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting journal entry: %w", err)
+	}
+
+	for _, posting := range entry.Postings {
+		_, err = tx.Exec(`
+			INSERT INTO journal_postings (posting_id, entry_id, account_code, amount)
+			VALUES (?, ?, ?, ?)`,
+			posting.PostingID,
+			entry.EntryID,
+			posting.AccountCode,
+			posting.Amount,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting journal posting: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetEntry retrieves a journal entry with its postings
+func (lr *LedgerRepo) GetEntry(entryID string) (*JournalEntry, error) {
+	row, err := lr.DB.QueryRow(`
+		SELECT entry_id, reference_type, reference_id, description, currency,
+			effective_at, COALESCE(reversal_of, ''), created_at, created_by
+		FROM journal_entries
+		WHERE entry_id = ?`, entryID)
+	if err != nil {
+		return nil, err
+	}
+
+	var entry JournalEntry
+	err = row.Scan(
+		&entry.EntryID,
+		&entry.ReferenceType,
+		&entry.ReferenceID,
+		&entry.Description,
+		&entry.Currency,
+		&entry.EffectiveAt,
+		&entry.ReversalOf,
+		&entry.CreatedAt,
+		&entry.CreatedBy,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Postings, err = lr.getPostings(entry.EntryID)
+	if err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// GetReversalEntryID returns the id of the entry that reversed the given entry, if any
+func (lr *LedgerRepo) GetReversalEntryID(entryID string) (string, error) {
+	row, err := lr.DB.QueryRow("SELECT entry_id FROM journal_entries WHERE reversal_of = ?", entryID)
+	if err != nil {
+		return "", err
+	}
+
+	var reversalID string
+	if err := row.Scan(&reversalID); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil
+		}
+		return "", err
+	}
+	return reversalID, nil
+}
+
+// CountEntriesByReference counts the journal entries posted for a business reference
+func (lr *LedgerRepo) CountEntriesByReference(referenceType, referenceID string) (int, error) {
+	row, err := lr.DB.QueryRow(
+		"SELECT COUNT(*) FROM journal_entries WHERE reference_type = ? AND reference_id = ?",
+		referenceType, referenceID,
+	)
+	if err != nil {
+		return 0, err
+	}
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// GetBalance sums the postings of an account and its sub-accounts effective on or before asOf
+func (lr *LedgerRepo) GetBalance(code string, asOf time.Time) (*Balance, error) {
+	row, err := lr.DB.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN p.amount > 0 THEN p.amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN p.amount < 0 THEN -p.amount ELSE 0 END), 0)
+		FROM journal_postings p
+		JOIN journal_entries e ON e.entry_id = p.entry_id
+		WHERE (p.account_code = ? OR p.account_code LIKE ?)
+			AND e.effective_at <= ?`,
+		code, code+subAccountSeparator+"%", asOf.UTC(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	balance := Balance{AccountCode: code, AsOf: asOf}
+	if err := row.Scan(&balance.Debit, &balance.Credit); err != nil {
+		return nil, fmt.Errorf("error calculating balance: %w", err)
+	}
+	balance.Balance = balance.Debit - balance.Credit
+	return &balance, nil
+}
+
+// GetTrialBalanceLines returns the net position of every account effective on or before asOf
+func (lr *LedgerRepo) GetTrialBalanceLines(asOf time.Time) ([]TrialBalanceLine, error) {
+	rows, err := lr.DB.Query(`
+		SELECT a.code, a.name, a.type, COALESCE(SUM(p.amount), 0) AS net
+		FROM ledger_accounts a
+		JOIN journal_postings p ON p.account_code = a.code
+		JOIN journal_entries e ON e.entry_id = p.entry_id
+		WHERE e.effective_at <= ?
+		GROUP BY a.code, a.name, a.type
+		ORDER BY a.code`,
+		asOf.UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying trial balance: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []TrialBalanceLine
+	for rows.Next() {
+		var line TrialBalanceLine
+		var net int64
+		if err := rows.Scan(&line.AccountCode, &line.AccountName, &line.AccountType, &net); err != nil {
+			return nil, fmt.Errorf("error scanning trial balance: %w", err)
+		}
+		if net >= 0 {
+			line.Debit = net
+		} else {
+			line.Credit = -net
+		}
+		lines = append(lines, line)
+	}
+	return lines, rows.Err()
+}
+
+func (lr *LedgerRepo) getPostings(entryID string) ([]Posting, error) {
+	rows, err := lr.DB.Query(`
+		SELECT posting_id, entry_id, account_code, amount
+		FROM journal_postings
+		WHERE entry_id = ?
+		ORDER BY rowid`, entryID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying journal postings: %w", err)
+	}
+	defer rows.Close()
+
+	var postings []Posting
+	for rows.Next() {
+		var posting Posting
+		if err := rows.Scan(&posting.PostingID, &posting.EntryID, &posting.AccountCode, &posting.Amount); err != nil {
+			return nil, fmt.Errorf("error scanning journal posting: %w", err)
+		}
+		postings = append(postings, posting)
+	}
+	return postings, rows.Err()
+}
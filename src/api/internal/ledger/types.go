@@ -0,0 +1,115 @@
+package ledger
+
+import (
+	"strings"
+	"time"
+)
+
+// AccountType represents the classification of a ledger account
+type AccountType string
+
+const (
+	AccountTypeAsset     AccountType = "ASSET"
+	AccountTypeLiability AccountType = "LIABILITY"
+	AccountTypeEquity    AccountType = "EQUITY"
+	AccountTypeIncome    AccountType = "INCOME"
+	AccountTypeExpense   AccountType = "EXPENSE"
+)
+
+// System accounts used by loans and payments
+const (
+	AccountCash             = "CASH"
+	AccountLoansReceivable  = "LOANS_RECEIVABLE"
+	AccountInterestIncome   = "INTEREST_INCOME"
+	AccountFeeIncome        = "FEE_INCOME"
+	AccountPaymentsClearing = "PAYMENTS_CLEARING"
+	AccountOwnerEquity      = "OWNER_EQUITY"
+)
+
+// Reference types describe what business event produced a journal entry
+const (
+	RefTransfer       = "TRANSFER"
+	RefManual         = "MANUAL"
+	RefReversal       = "REVERSAL"
+	RefPaymentCapture = "PAYMENT_CAPTURE"
+)
+
+// DefaultCurrency is used when an entry does not specify one
+const DefaultCurrency = "USD"
+
+// subAccountSeparator separates a parent account code from its sub-account key
+const subAccountSeparator = ":"
+
+// Account represents a ledger account
+type Account struct {
+	Code       string      `json:"code" example:"CASH"`
+	Name       string      `json:"name" example:"Cash at bank"`
+	Type       AccountType `json:"type" example:"ASSET"`
+	Currency   string      `json:"currency" example:"USD"`
+	ParentCode string      `json:"parent_code,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+	CreatedBy  string      `json:"created_by"`
+	StatusID   int         `json:"status_id"`
+}
+
+// JournalEntry represents an immutable, balanced set of postings
+type JournalEntry struct {
+	EntryID       string    `json:"entry_id"`
+	ReferenceType string    `json:"reference_type" example:"LOAN_DISBURSEMENT"`
+	ReferenceID   string    `json:"reference_id"`
+	Description   string    `json:"description"`
+	Currency      string    `json:"currency" example:"USD"`
+	EffectiveAt   time.Time `json:"effective_at"`
+	ReversalOf    string    `json:"reversal_of,omitempty"`
+	CreatedAt     time.Time `json:"created_at"`
+	CreatedBy     string    `json:"created_by"`
+	Postings      []Posting `json:"postings"`
+}
+
+// Posting is a single debit (positive) or credit (negative) line in minor units
+type Posting struct {
+	PostingID   string `json:"posting_id"`
+	EntryID     string `json:"entry_id"`
+	AccountCode string `json:"account_code"`
+	Amount      int64  `json:"amount"`
+}
+
+// Balance represents the balance of an account at a point in time
+type Balance struct {
+	AccountCode string    `json:"account_code"`
+	AsOf        time.Time `json:"as_of"`
+	Debit       int64     `json:"debit"`
+	Credit      int64     `json:"credit"`
+	Balance     int64     `json:"balance"`
+}
+
+// TrialBalanceLine is the net position of one account in the trial balance
+type TrialBalanceLine struct {
+	AccountCode string      `json:"account_code"`
+	AccountName string      `json:"account_name"`
+	AccountType AccountType `json:"account_type"`
+	Debit       int64       `json:"debit"`
+	Credit      int64       `json:"credit"`
+}
+
+// TrialBalance lists every account with postings and the debit/credit totals
+type TrialBalance struct {
+	AsOf        time.Time          `json:"as_of"`
+	Lines       []TrialBalanceLine `json:"lines"`
+	TotalDebit  int64              `json:"total_debit"`
+	TotalCredit int64              `json:"total_credit"`
+	Balanced    bool               `json:"balanced"`
+}
+
+// SubAccount returns the code of a sub-account, e.g. LOANS_RECEIVABLE:LOAN-001
+func SubAccount(parent, key string) string {
+	return parent + subAccountSeparator + key
+}
+
+// parentCode returns the parent account code of a sub-account, or "" for top-level accounts
+func parentCode(code string) string {
+	if i := strings.Index(code, subAccountSeparator); i > 0 {
+		return code[:i]
+	}
+	return ""
+}
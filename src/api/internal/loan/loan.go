@@ -1,9 +1,16 @@
 package loan
 
 import (
+	"api/internal/auth"
+	"api/internal/ledger"
+	"api/internal/policy"
+	"api/internal/tenant"
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"log"
+	"math"
 	"time"
 )
 
@@ -20,6 +27,21 @@ const (
 	StatusDefaulted Status = "DEFAULTED"
 )
 
+// Applicant data-sharing consent statuses; staff cannot review loans without consent
+const (
+	ConsentGranted = "GRANTED"
+	ConsentRevoked = "REVOKED"
+)
+
+// PolicyResource is the resource type of loan applications in attribute-based policies
+const PolicyResource = "loan"
+
+// ErrLoanNotFound is returned when a loan application does not exist
+var ErrLoanNotFound = errors.New("loan application not found")
+
+// ErrPaymentPeriodNotFound is returned when a loan has no such payment period
+var ErrPaymentPeriodNotFound = errors.New("payment period not found")
+
 // PaymentStatus represents the status of a payment period
 type PaymentStatus string
 
@@ -46,6 +68,9 @@ type LoanApplication struct {
 	Amount          float64         `json:"amount"`
 	Term            int             `json:"term"`
 	Purpose         string          `json:"purpose"`
+	Branch          string          `json:"branch"`
+	ConsentStatus   string          `json:"consent_status"`
+	TenantID        int             `json:"tenant_id"`
 	Status          Status          `json:"status"`
 	Evidence        []Evidence      `json:"evidence"`
 	CreditScore     int             `json:"credit_score"`
@@ -74,6 +99,8 @@ type PaymentPeriod struct {
 // LoanService handles loan-related operations
 type LoanService interface {
 	ApplyForLoan(application *LoanApplication, evidence []Evidence) error
+	GetApplication(ctx context.Context, loanID string) (*LoanApplication, error)
+	ListApplications(ctx context.Context, filter *policy.SQLFilter) ([]*LoanApplication, error)
 	ReviewApplication(loanID string) (*LoanApplication, error)
 	ApproveLoan(loanID string, interestRate float64) (*LoanApplication, error)
 	RejectLoan(loanID string, reason string) error
@@ -93,7 +120,8 @@ type CreditService interface {
 
 // PaymentService handles payment processing
 type PaymentService interface {
-	TransferFunds(fromAccount, toAccount string, amount float64) error
+	TransferFunds(fromAccount, toAccount string, amount float64, reference string) error
+	HasTransfer(reference string) (bool, error)
 	ValidatePayment(paymentID string) error
 	CalculateFine(dueDate time.Time, amount float64) float64
 }
@@ -141,18 +169,21 @@ func (s *loanService) ApplyForLoan(application *LoanApplication, evidence []Evid
 	application.Status = StatusPending
 	application.AppliedAt = now
 	application.LastUpdatedAt = now
+	if application.TenantID == 0 {
+		application.TenantID = auth.DefaultTenantID
+	}
 
 	// fmt.Println("application", application)
 
 	_, err = tx.Exec(`
 		INSERT INTO loan_applications (
-			id, applicant_id, amount, term, purpose, status,credit_score,interest_rate,
-			applied_at, last_updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			id, applicant_id, amount, term, purpose, branch, consent_status, status,credit_score,interest_rate,
+			applied_at, last_updated_at, tenant_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
 		application.ID, application.ApplicantID, application.Amount,
-		application.Term, application.Purpose, application.Status,
+		application.Term, application.Purpose, application.Branch, application.ConsentStatus, application.Status,
 		application.CreditScore, application.InterestRate,
-		application.AppliedAt, application.LastUpdatedAt,
+		application.AppliedAt, application.LastUpdatedAt, application.TenantID,
 	)
 	if err != nil {
 		return err
@@ -178,6 +209,102 @@ func (s *loanService) ApplyForLoan(application *LoanApplication, evidence []Evid
 	return tx.Commit()
 }
 
+// loanColumns are the loan_applications columns read by GetApplication and ListApplications
+const loanColumns = `id, applicant_id, amount, term, COALESCE(purpose, ''), COALESCE(branch, ''),
+	COALESCE(consent_status, ''), status, COALESCE(credit_score, 0), COALESCE(interest_rate, 0),
+	applied_at, last_updated_at, approved_at, disbursed_at, tenant_id`
+
+// PolicyColumns maps the policy attributes of a loan application to loan_applications columns
+var PolicyColumns = map[string]string{
+	"id":             "id",
+	"applicant_id":   "applicant_id",
+	"amount":         "amount",
+	"term":           "term",
+	"branch":         "branch",
+	"consent_status": "consent_status",
+	"status":         "status",
+}
+
+// Attributes returns the attributes policies are evaluated on
+func (a *LoanApplication) Attributes() policy.Attributes {
+	return policy.Attributes{
+		"id":             a.ID,
+		"applicant_id":   a.ApplicantID,
+		"amount":         a.Amount,
+		"term":           a.Term,
+		"branch":         a.Branch,
+		"consent_status": a.ConsentStatus,
+		"status":         string(a.Status),
+	}
+}
+
+// GetApplication returns a loan application of the tenant of ctx, or ErrLoanNotFound;
+// applications of other tenants are not found
+func (s *loanService) GetApplication(ctx context.Context, loanID string) (*LoanApplication, error) {
+	scoped, err := tenant.Scope(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("loan_applications", loanColumns, "id = ?", loanID)
+	if err != nil {
+		return nil, err
+	}
+	application, err := scanApplication(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoanNotFound
+	}
+	return application, err
+}
+
+// ListApplications returns the loan applications of the tenant of ctx matching filter, newest first
+func (s *loanService) ListApplications(ctx context.Context, filter *policy.SQLFilter) ([]*LoanApplication, error) {
+	scoped, err := tenant.Scope(ctx, s.db)
+	if err != nil {
+		return nil, err
+	}
+	var where string
+	var args []interface{}
+	if filter != nil {
+		where, args = filter.Where, filter.Args
+	}
+	rows, err := scoped.Select("loan_applications", loanColumns, where, "applied_at DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error listing loan applications: %w", err)
+	}
+	defer rows.Close()
+
+	applications := []*LoanApplication{}
+	for rows.Next() {
+		application, err := scanApplication(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning loan application: %w", err)
+		}
+		applications = append(applications, application)
+	}
+	return applications, rows.Err()
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanApplication(row rowScanner) (*LoanApplication, error) {
+	application := &LoanApplication{}
+	err := row.Scan(
+		&application.ID, &application.ApplicantID, &application.Amount,
+		&application.Term, &application.Purpose, &application.Branch,
+		&application.ConsentStatus, &application.Status,
+		&application.CreditScore, &application.InterestRate,
+		&application.AppliedAt, &application.LastUpdatedAt,
+		&application.ApprovedAt, &application.DisbursedAt,
+		&application.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return application, nil
+}
+
 // ReviewApplication reviews loan application and checks credit
 func (s *loanService) ReviewApplication(loanID string) (*LoanApplication, error) {
 	// Fetch application from database
@@ -261,53 +388,123 @@ func (s *loanService) ApproveLoan(loanID string, interestRate float64) (*LoanApp
 	return application, err
 }
 
-// DisburseLoan handles the money transfer to borrower's account
+// DisburseLoan handles the money transfer to borrower's account. The loan is claimed with a
+// conditional status change in a transaction that only commits once the disbursement is posted,
+// and a disbursement already in the ledger is not posted again.
 func (s *loanService) DisburseLoan(loanID string) error {
-	// Fetch application
-	application := &LoanApplication{} // In real implementation, fetch from database
-
+	application, err := s.getLoan(loanID)
+	if err != nil {
+		return err
+	}
 	if application.Status != StatusApproved {
 		return errors.New("loan is not approved")
 	}
 
-	// Transfer funds
-	if err := s.paymentService.TransferFunds("LOAN_ACCOUNT", "BORROWER_ACCOUNT", application.Amount); err != nil {
+	tx, err := s.db.Begin()
+	if err != nil {
 		return err
 	}
+	defer tx.Rollback()
 
 	now := time.Now()
-	application.Status = StatusDisbursed
-	application.DisbursedAt = &now
-	application.LastUpdatedAt = now
+	result, err := tx.Exec(`
+		UPDATE loan_applications
+		SET status = ?, disbursed_at = ?, last_updated_at = ?
+		WHERE id = ? AND status = ?`,
+		StatusDisbursed, now, now, loanID, StatusApproved,
+	)
+	if err != nil {
+		return err
+	}
+	if rows, err := result.RowsAffected(); err != nil {
+		return err
+	} else if rows == 0 {
+		return errors.New("loan is not approved")
+	}
 
-	return nil
+	posted, err := s.paymentService.HasTransfer(loanID)
+	if err != nil {
+		return err
+	}
+	if !posted {
+		// Transfer funds: Dr loans receivable for this loan, Cr cash
+		if err := s.paymentService.TransferFunds(ledger.AccountCash, loanAccount(loanID), application.Amount, loanID); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
 }
 
-// ProcessPayment handles loan payments
+// ProcessPayment handles loan payments. Interest still due on the period is settled first and
+// recognised as income; the rest, including any fine, reduces the loan receivable.
 func (s *loanService) ProcessPayment(loanID string, periodID string, amount float64) error {
-	// Fetch payment period
-	period := &PaymentPeriod{} // In real implementation, fetch from database
+	if amount <= 0 {
+		return errors.New("payment amount must be greater than zero")
+	}
+
+	application, err := s.getLoan(loanID)
+	if err != nil {
+		return err
+	}
+	if application.Status != StatusDisbursed {
+		return errors.New("loan is not disbursed")
+	}
+
+	period, err := s.getPaymentPeriod(loanID, periodID)
+	if err != nil {
+		return err
+	}
+	if period.Status == PaymentPaid {
+		return errors.New("payment period is already paid")
+	}
 
 	// Validate payment
 	if err := s.paymentService.ValidatePayment(periodID); err != nil {
 		return err
 	}
 
-	// Calculate fine if payment is late
-	if time.Now().After(period.DueDate) {
-		fine := s.paymentService.CalculateFine(period.DueDate, period.Amount)
-		period.FineAmount = fine
-		period.Amount += fine
+	// Charge the fine if payment is late
+	now := time.Now()
+	if now.After(period.DueDate) {
+		if err := s.chargeFine(period); err != nil {
+			return err
+		}
+	}
+
+	due := period.Amount + period.FineAmount - period.PaidAmount
+	if ledger.ToMinor(amount) > ledger.ToMinor(due) {
+		return fmt.Errorf("payment amount exceeds the %.2f due", due)
+	}
+
+	// Record the repayment: interest is recognised as income, the rest reduces the receivable
+	interest := math.Min(amount, math.Max(0, period.InterestAmount-period.PaidAmount))
+	if interest > 0 {
+		if err := s.paymentService.TransferFunds(ledger.AccountInterestIncome, ledger.AccountCash, interest, periodID); err != nil {
+			return err
+		}
+	}
+	if principal := amount - interest; principal > 0 {
+		if err := s.paymentService.TransferFunds(loanAccount(loanID), ledger.AccountCash, principal, periodID); err != nil {
+			return err
+		}
 	}
 
 	// Process payment
-	if amount >= period.Amount {
+	period.PaidAmount += amount
+	if ledger.ToMinor(period.PaidAmount) >= ledger.ToMinor(period.Amount+period.FineAmount) {
 		period.Status = PaymentPaid
-		now := time.Now()
 		period.PaidAt = &now
 	} else {
 		period.Status = PaymentIncomplete
-		period.PaidAmount = amount
+	}
+	_, err = s.db.Exec(`
+		UPDATE payment_periods
+		SET paid_amount = ?, status = ?, paid_at = ?
+		WHERE id = ?`,
+		period.PaidAmount, period.Status, period.PaidAt, periodID,
+	)
+	if err != nil {
+		return err
 	}
 
 	// Generate invoice/statement
@@ -316,14 +513,24 @@ func (s *loanService) ProcessPayment(loanID string, periodID string, amount floa
 
 // CheckPaymentStatus verifies payment status and updates accordingly
 func (s *loanService) CheckPaymentStatus(loanID string, periodID string) error {
-	// Fetch payment period
-	period := &PaymentPeriod{} // In real implementation, fetch from database
+	if _, err := s.getLoan(loanID); err != nil {
+		return err
+	}
+	period, err := s.getPaymentPeriod(loanID, periodID)
+	if err != nil {
+		return err
+	}
 
 	if time.Now().After(period.DueDate) && period.Status == PaymentPending {
+		if err := s.chargeFine(period); err != nil {
+			return err
+		}
+
 		period.Status = PaymentOverdue
-		fine := s.paymentService.CalculateFine(period.DueDate, period.Amount)
-		period.FineAmount = fine
-		period.Amount += fine
+		_, err = s.db.Exec("UPDATE payment_periods SET status = ? WHERE id = ?", PaymentOverdue, periodID)
+		if err != nil {
+			return err
+		}
 
 		// Generate overdue statement
 		return s.documentService.GenerateStatement(loanID)
@@ -332,6 +539,86 @@ func (s *loanService) CheckPaymentStatus(loanID string, periodID string) error {
 	return nil
 }
 
+// getLoan returns a loan application by ID, or ErrLoanNotFound
+func (s *loanService) getLoan(loanID string) (*LoanApplication, error) {
+	application, err := scanApplication(s.db.QueryRow("SELECT "+loanColumns+" FROM loan_applications WHERE id = ?", loanID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLoanNotFound
+	}
+	return application, err
+}
+
+// getPaymentPeriod returns a payment period of a loan, or ErrPaymentPeriodNotFound
+func (s *loanService) getPaymentPeriod(loanID, periodID string) (*PaymentPeriod, error) {
+	period := &PaymentPeriod{}
+	err := s.db.QueryRow(`
+		SELECT id, loan_id, due_date, amount, interest_amount, principal_amount,
+			   COALESCE(paid_amount, 0), COALESCE(fine_amount, 0), status, paid_at
+		FROM payment_periods WHERE id = ? AND loan_id = ?`, periodID, loanID,
+	).Scan(
+		&period.ID, &period.LoanID, &period.DueDate, &period.Amount,
+		&period.InterestAmount, &period.PrincipalAmount,
+		&period.PaidAmount, &period.FineAmount, &period.Status, &period.PaidAt,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrPaymentPeriodNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return period, nil
+}
+
+// chargeFine charges the late payment fine of a period once: the fine is claimed on the
+// period before it is posted, so repeated status checks and payments do not post it again
+func (s *loanService) chargeFine(period *PaymentPeriod) error {
+	if period.FineAmount > 0 {
+		return nil
+	}
+	fine := s.paymentService.CalculateFine(period.DueDate, period.Amount)
+	if fine <= 0 {
+		return nil
+	}
+
+	result, err := s.db.Exec(
+		"UPDATE payment_periods SET fine_amount = ? WHERE id = ? AND COALESCE(fine_amount, 0) = 0",
+		fine, period.ID,
+	)
+	if err != nil {
+		return err
+	}
+	claimed, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if claimed == 0 {
+		// Charged concurrently; pick up the fine that was posted
+		return s.db.QueryRow("SELECT fine_amount FROM payment_periods WHERE id = ?", period.ID).Scan(&period.FineAmount)
+	}
+
+	if err := s.recordFine(period.LoanID, period.ID, fine); err != nil {
+		if _, resetErr := s.db.Exec("UPDATE payment_periods SET fine_amount = 0 WHERE id = ?", period.ID); resetErr != nil {
+			log.Printf("[error] - Failed to release fine of payment period %s: %v", period.ID, resetErr)
+		}
+		return err
+	}
+	period.FineAmount = fine
+	return nil
+}
+
+// recordFine charges a late payment fine to the loan: Dr loans receivable, Cr fee income
+func (s *loanService) recordFine(loanID, periodID string, fine float64) error {
+	if fine <= 0 {
+		return nil
+	}
+	return s.paymentService.TransferFunds(ledger.AccountFeeIncome, loanAccount(loanID), fine, periodID)
+}
+
+// loanAccount returns the receivable sub-account that tracks what the borrower owes on a loan
+func loanAccount(loanID string) string {
+	return ledger.SubAccount(ledger.AccountLoansReceivable, loanID)
+}
+
 // Helper function to calculate monthly payment
 func calculateMonthlyPayment(principal float64, annualRate float64, terms int) float64 {
 	monthlyRate := annualRate / 12 / 100
@@ -1,18 +1,76 @@
 package loan
 
 import (
+	"api/internal/auth"
+	"api/internal/policy"
+	"api/internal/router"
+	"api/internal/tenant"
 	"encoding/json"
+	"errors"
 	"net/http"
 )
 
+// Policy actions on loan applications
+const (
+	ActionRead    = "read"
+	ActionApply   = "apply"
+	ActionReview  = "review"
+	ActionApprove = "approve"
+	ActionReject  = "reject"
+)
+
 // LoanHandler handles HTTP requests for loan operations
 type LoanHandler struct {
-	service LoanService
+	service  LoanService
+	policies *policy.Service
+}
+
+// NewLoanHandler creates a new LoanHandler. Each request is checked against the
+// attribute-based policies; a nil policies service disables the checks.
+func NewLoanHandler(service LoanService, policies *policy.Service) *LoanHandler {
+	return &LoanHandler{service: service, policies: policies}
+}
+
+// GetApplication handles the get loan application request
+func (h *LoanHandler) GetApplication(w http.ResponseWriter, r *http.Request) {
+	application, ok := h.authorizedApplication(w, r, r.URL.Query().Get("loanID"), ActionRead)
+	if !ok {
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(application)
 }
 
-// NewLoanHandler creates a new LoanHandler
-func NewLoanHandler(service LoanService) *LoanHandler {
-	return &LoanHandler{service: service}
+// ListApplications handles the list loan applications request, returning only the
+// applications the policies allow the caller to read
+func (h *LoanHandler) ListApplications(w http.ResponseWriter, r *http.Request) {
+	var filter *policy.SQLFilter
+	if h.policies != nil {
+		subject, err := h.policies.RequestSubject(r)
+		if err != nil {
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		filter, err = h.policies.Filter(subject, PolicyResource, ActionRead, PolicyColumns)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	applications, err := h.service.ListApplications(r.Context(), filter)
+	if errors.Is(err, tenant.ErrNoTenant) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	json.NewEncoder(w).Encode(applications)
 }
 
 // ApplyForLoan handles the loan application request
@@ -23,6 +81,18 @@ func (h *LoanHandler) ApplyForLoan(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Applications belong to the caller's tenant whatever the body says
+	tenantID, ok := auth.TenantIDFromContext(r.Context())
+	if !ok {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+	application.TenantID = tenantID
+
+	if !h.authorize(w, r, ActionApply, &application) {
+		return
+	}
+
 	if err := h.service.ApplyForLoan(&application, application.Evidence); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
@@ -35,6 +105,10 @@ func (h *LoanHandler) ApplyForLoan(w http.ResponseWriter, r *http.Request) {
 // ReviewApplication handles the review application request
 func (h *LoanHandler) ReviewApplication(w http.ResponseWriter, r *http.Request) {
 	loanID := r.URL.Query().Get("loanID")
+	if _, ok := h.authorizedApplication(w, r, loanID, ActionReview); !ok {
+		return
+	}
+
 	application, err := h.service.ReviewApplication(loanID)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -55,6 +129,9 @@ func (h *LoanHandler) ApproveLoan(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, ok := h.authorizedApplication(w, r, request.LoanID, ActionApprove); !ok {
+		return
+	}
 
 	application, err := h.service.ApproveLoan(request.LoanID, request.InterestRate)
 	if err != nil {
@@ -76,6 +153,9 @@ func (h *LoanHandler) RejectLoan(w http.ResponseWriter, r *http.Request) {
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, ok := h.authorizedApplication(w, r, loanID, ActionReject); !ok {
+		return
+	}
 
 	if err := h.service.RejectLoan(loanID, request.Reason); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -96,6 +176,9 @@ func (h *LoanHandler) UpdateCreditScore(w http.ResponseWriter, r *http.Request)
 		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
+	if _, ok := h.authorizedApplication(w, r, loanID, ActionReview); !ok {
+		return
+	}
 
 	if err := h.service.UpdateCreditScore(loanID, request.CreditScore, request.InterestRate); err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -105,41 +188,50 @@ func (h *LoanHandler) UpdateCreditScore(w http.ResponseWriter, r *http.Request)
 	w.WriteHeader(http.StatusOK)
 }
 
-// RegisterRoutes registers the loan routes with the given HTTP mux
-func (h *LoanHandler) RegisterRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/loans/apply", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			h.ApplyForLoan(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/loans/review", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodGet {
-			h.ReviewApplication(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/loans/approve", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			h.ApproveLoan(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/loans/reject", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			h.RejectLoan(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
-	mux.HandleFunc("/loans/updateCreditScore", func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodPost {
-			h.UpdateCreditScore(w, r)
-		} else {
-			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
-		}
-	})
+// authorizedApplication loads a loan application of the caller's tenant and checks an
+// action on it; applications of other tenants are not found
+func (h *LoanHandler) authorizedApplication(w http.ResponseWriter, r *http.Request, loanID, action string) (*LoanApplication, bool) {
+	application, err := h.service.GetApplication(r.Context(), loanID)
+	if errors.Is(err, tenant.ErrNoTenant) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	if errors.Is(err, ErrLoanNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return nil, false
+	}
+	return application, h.authorize(w, r, action, application)
+}
+
+// authorize checks an action on a loan application against the attribute-based policies
+func (h *LoanHandler) authorize(w http.ResponseWriter, r *http.Request, action string, application *LoanApplication) bool {
+	if h.policies == nil {
+		return true
+	}
+	subject, err := h.policies.RequestSubject(r)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return false
+	}
+	decision := h.policies.Check(subject, PolicyResource, action, application.Attributes())
+	if !decision.Allowed {
+		http.Error(w, "Forbidden: "+decision.Reason, http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// RegisterRoutes registers the loan routes with the given router
+func (h *LoanHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/loans/list", h.ListApplications)
+	r.Get("/loans/application", h.GetApplication)
+	r.Post("/loans/apply", h.ApplyForLoan)
+	r.Get("/loans/review", h.ReviewApplication)
+	r.Post("/loans/approve", h.ApproveLoan)
+	r.Post("/loans/reject", h.RejectLoan)
+	r.Post("/loans/updateCreditScore", h.UpdateCreditScore)
 }
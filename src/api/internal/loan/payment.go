@@ -1,45 +1,75 @@
 package loan
 
-import "time"
+import (
+	"api/internal/ledger"
+	"errors"
+	"fmt"
+	"time"
+)
 
-type paymentService struct{}
+type paymentService struct {
+	ledger *ledger.Ledger
+}
 
-func NewPaymentService() PaymentService {
-    return &paymentService{}
+// NewPaymentService creates a PaymentService that records every transfer in the general ledger
+func NewPaymentService(l *ledger.Ledger) PaymentService {
+	return &paymentService{ledger: l}
 }
 
-func (s *paymentService) TransferFunds(fromAccount, toAccount string, amount float64) error {
-    return nil
+// TransferFunds posts a balanced journal entry crediting fromAccount and debiting toAccount.
+// The reference is the loan or payment period the transfer belongs to.
+func (s *paymentService) TransferFunds(fromAccount, toAccount string, amount float64, reference string) error {
+	if s.ledger == nil {
+		return errors.New("ledger is not configured")
+	}
+	_, err := s.ledger.Transfer(
+		fromAccount,
+		toAccount,
+		ledger.ToMinor(amount),
+		ledger.RefTransfer,
+		reference,
+		fmt.Sprintf("Transfer from %s to %s", fromAccount, toAccount),
+		"system",
+	)
+	return err
+}
+
+// HasTransfer reports whether a transfer was already posted for a reference
+func (s *paymentService) HasTransfer(reference string) (bool, error) {
+	if s.ledger == nil {
+		return false, errors.New("ledger is not configured")
+	}
+	return s.ledger.HasEntry(ledger.RefTransfer, reference)
 }
 
 func (s *paymentService) ValidatePayment(paymentID string) error {
-    return nil
+	return nil
 }
 
 func (s *paymentService) CalculateFine(dueDate time.Time, amount float64) float64 {
-    return 0.0
+	return 0.0
 }
 
 type LoanPayment struct {
-    ID          string    `json:"id" db:"id"`
-    LoanID      string    `json:"loanId" db:"loan_id"`
-    Amount      float64   `json:"amount" db:"amount"`
-    DueDate     time.Time `json:"dueDate" db:"due_date"`
-    Status      string    `json:"status" db:"status"`
-    PaymentDate *time.Time `json:"paymentDate,omitempty" db:"payment_date"`
-    CreatedAt   time.Time `json:"createdAt" db:"created_at"`
-    UpdatedAt   time.Time `json:"updatedAt" db:"updated_at"`
+	ID          string     `json:"id" db:"id"`
+	LoanID      string     `json:"loanId" db:"loan_id"`
+	Amount      float64    `json:"amount" db:"amount"`
+	DueDate     time.Time  `json:"dueDate" db:"due_date"`
+	Status      string     `json:"status" db:"status"`
+	PaymentDate *time.Time `json:"paymentDate,omitempty" db:"payment_date"`
+	CreatedAt   time.Time  `json:"createdAt" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updatedAt" db:"updated_at"`
 }
 
 const (
-    PaymentStatusPending = "PENDING"
-    PaymentStatusPaid    = "PAID"
-    PaymentStatusOverdue = "OVERDUE"
+	PaymentStatusPending = "PENDING"
+	PaymentStatusPaid    = "PAID"
+	PaymentStatusOverdue = "OVERDUE"
 )
 
 // PaymentSchedule represents a collection of payments for a loan
 type PaymentSchedule struct {
-    LoanID      string    `json:"loanId"`
-    Payments    []LoanPayment `json:"payments"`
-    TotalAmount float64   `json:"totalAmount"`
-} 
\ No newline at end of file
+	LoanID      string        `json:"loanId"`
+	Payments    []LoanPayment `json:"payments"`
+	TotalAmount float64       `json:"totalAmount"`
+}
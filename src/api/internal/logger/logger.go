@@ -31,6 +31,7 @@ type ApiLog struct {
 	ClientOSVersion      string
 	ClientDevice         string
 	UserID               int
+	ImpersonatorID       int
 	Error                string
 }
 
@@ -35,8 +35,9 @@ func (logger *PostgresLogger) InsertLog(logEntries []models.LogModel) error {
         client_browser,
         client_browser_ver,
         duration,
-        errors
-    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+        errors,
+        actor_id
+    ) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15)
     `
 
 	// Prepare the SQL statement
@@ -63,6 +64,7 @@ func (logger *PostgresLogger) InsertLog(logEntries []models.LogModel) error {
 			logEntry.ClientBrowserVersion,
 			logEntry.Duration.Nanoseconds(),
 			logEntry.Errors,
+			logEntry.ActorId,
 		)
 
 		if err != nil {
@@ -125,8 +125,9 @@ func (logger *SqliteLogger) InsertLog(logEntries []models.LogModel) error {
         client_browser,
         client_browser_ver,
         duration,
-        errors
-    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+        errors,
+        actor_id
+    ) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
     `
 
 	// Prepare the SQL statement
@@ -153,6 +154,7 @@ func (logger *SqliteLogger) InsertLog(logEntries []models.LogModel) error {
 			logEntry.ClientBrowserVersion,
 			logEntry.Duration.Nanoseconds(),
 			logEntry.Errors,
+			logEntry.ActorId,
 		)
 
 		if err != nil {
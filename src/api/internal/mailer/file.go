@@ -0,0 +1,42 @@
+package mailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// FileMailer writes each message as an .eml file into an outbox directory, for
+// local development and tests
+type FileMailer struct {
+	dir  string
+	from string
+}
+
+// NewFileMailer creates a FileMailer
+func NewFileMailer(dir, from string) *FileMailer {
+	return &FileMailer{dir: dir, from: from}
+}
+
+// Send writes a message to the outbox
+func (m *FileMailer) Send(msg *Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	body, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(m.dir, 0o700); err != nil {
+		return fmt.Errorf("error creating outbox: %w", err)
+	}
+	name := time.Now().UTC().Format("20060102T150405.000000000Z") + "-" + uuid.NewString() + ".eml"
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o600); err != nil {
+		return fmt.Errorf("error writing mail to outbox: %w", err)
+	}
+	return nil
+}
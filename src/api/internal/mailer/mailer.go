@@ -0,0 +1,91 @@
+// Package mailer sends transactional email through SMTP or a local outbox directory
+package mailer
+
+import (
+	"api/config"
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"log"
+	"strings"
+	"sync"
+	texttemplate "text/template"
+)
+
+// Mail providers
+const (
+	ProviderSMTP = "smtp"
+	ProviderFile = "file"
+)
+
+// Message is an email with a plain text and an optional HTML body
+type Message struct {
+	From     string
+	To       []string
+	Subject  string
+	TextBody string
+	HTMLBody string
+}
+
+// Mailer sends messages
+type Mailer interface {
+	Send(msg *Message) error
+}
+
+//go:embed templates/*.tmpl
+var templateFiles embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFiles, "templates/*.tmpl"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFiles, "templates/*.tmpl"))
+)
+
+var (
+	mailerOnce     sync.Once
+	mailerInstance Mailer
+)
+
+// NewMailer creates the mailer selected by MAIL_PROVIDER. Without a provider,
+// messages go to the outbox directory so that nothing is sent by accident.
+func NewMailer() Mailer {
+	cfg := config.NewConfig()
+	switch cfg.MailProvider {
+	case ProviderSMTP:
+		return NewSMTPMailer(cfg.SMTPHost, cfg.SMTPPort, cfg.SMTPUsername, cfg.SMTPPassword, cfg.MailFrom)
+	case ProviderFile, "":
+		return NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+	default:
+		log.Printf("[error] - Unknown MAIL_PROVIDER %q, writing mail to the outbox", cfg.MailProvider)
+		return NewFileMailer(cfg.MailOutboxDir, cfg.MailFrom)
+	}
+}
+
+// GetMailer returns the shared mailer
+func GetMailer() Mailer {
+	mailerOnce.Do(func() {
+		mailerInstance = NewMailer()
+	})
+	return mailerInstance
+}
+
+// Render builds a message from a template. Each template file defines the blocks
+// "<name>_subject", "<name>_text" and "<name>_html"; the HTML block is escaped.
+func Render(name string, data interface{}, to ...string) (*Message, error) {
+	var subject, text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&subject, name+"_subject", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s subject: %w", name, err)
+	}
+	if err := textTemplates.ExecuteTemplate(&text, name+"_text", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s text: %w", name, err)
+	}
+	if err := htmlTemplates.ExecuteTemplate(&html, name+"_html", data); err != nil {
+		return nil, fmt.Errorf("error rendering %s html: %w", name, err)
+	}
+	return &Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		TextBody: strings.TrimSpace(text.String()),
+		HTMLBody: strings.TrimSpace(html.String()),
+	}, nil
+}
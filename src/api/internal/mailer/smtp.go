@@ -0,0 +1,44 @@
+package mailer
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+)
+
+// SMTPMailer sends messages through an SMTP server. It authenticates with PLAIN
+// when a username is set, which net/smtp only allows over TLS or to localhost.
+type SMTPMailer struct {
+	host     string
+	port     int
+	username string
+	password string
+	from     string
+}
+
+// NewSMTPMailer creates an SMTPMailer
+func NewSMTPMailer(host string, port int, username, password, from string) *SMTPMailer {
+	return &SMTPMailer{host: host, port: port, username: username, password: password, from: from}
+}
+
+// Send delivers a message
+func (m *SMTPMailer) Send(msg *Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+	body, err := encodeMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.username != "" {
+		auth = smtp.PlainAuth("", m.username, m.password, m.host)
+	}
+	addr := net.JoinHostPort(m.host, strconv.Itoa(m.port))
+	if err := smtp.SendMail(addr, auth, msg.From, msg.To, body); err != nil {
+		return fmt.Errorf("error sending mail to %v: %w", msg.To, err)
+	}
+	return nil
+}
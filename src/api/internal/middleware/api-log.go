@@ -1,6 +1,7 @@
 package middleware
 
 import (
+	"api/internal/converter"
 	"api/internal/handler"
 	"api/internal/logger"
 	"bytes"
@@ -122,7 +123,7 @@ func prepareApiLog(r *http.Request, w http.ResponseWriter, body []byte, start ti
 	// Get the operating system name
 	osInfo := ua.OS()
 	device := ua.Model()
-	userId := getUserIdFromJWT(token)
+	userId, impersonatorId := getUserFromJWT(token)
 	status := getStatusCode(w)
 	fmt.Printf("Status: %+v\n", status)
 	level := getLogLevel(status.Status)
@@ -148,12 +149,21 @@ func prepareApiLog(r *http.Request, w http.ResponseWriter, body []byte, start ti
 		ClientOSVersion:      ua.OSInfo().Version,
 		ClientDevice:         device,
 		UserID:               userId,
+		ImpersonatorID:       impersonatorId,
 		Error:                errorMsg,
 	}
 	return logData, nil
 }
 
 func maskSensitiveData(data string) string {
+	// Mask values of sensitive fields when the payload is JSON
+	var payload interface{}
+	if err := json.Unmarshal([]byte(data), &payload); err == nil {
+		if masked, err := json.Marshal(maskSensitiveValue("", payload)); err == nil {
+			return string(masked)
+		}
+	}
+
 	// Define sensitive keywords
 	sensitiveKeywords := []string{"password", "id_card", "credit_card", "ssid", "card_number", "expiry_date", "cvv", "phone", "mobile_no"}
 
@@ -165,6 +175,32 @@ func maskSensitiveData(data string) string {
 	return data
 }
 
+// maskSensitiveValue walks a decoded JSON value and masks the values of sensitive keys
+func maskSensitiveValue(key string, value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for k, item := range v {
+			v[k] = maskSensitiveValue(k, item)
+		}
+		return v
+	case []interface{}:
+		for i, item := range v {
+			v[i] = maskSensitiveValue(key, item)
+		}
+		return v
+	case string:
+		switch strings.ToLower(key) {
+		case "card_number", "pan":
+			return converter.MaskCreditCardNumer(v)
+		case "expiry_date":
+			return converter.MaskCreditCardExpiryDate(v)
+		case "cvv", "cvc", "password", "id_card", "ssid", "phone", "mobile_no":
+			return "****"
+		}
+	}
+	return value
+}
+
 func getLogLevel(statusCode int) string {
 	switch {
 	case statusCode >= 500:
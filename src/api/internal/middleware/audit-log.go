@@ -5,7 +5,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"api/config"
 	"api/internal/auth"
 	"api/internal/logger"
 	"api/pkg/data/models"
@@ -127,7 +126,7 @@ func prepareLog(r *http.Request, crw *CustomResponseWriter, bodyString string, s
 	// Get the operating system name
 	osInfo := ua.OS()
 	device := ua.Model()
-	userId := getUserIdFromJWT(token)
+	userId, actorId := getUserFromJWT(token)
 
 	// Parse the GraphQL response
 	var gqlResponse graphql.GraphQLResponse
@@ -149,6 +148,7 @@ func prepareLog(r *http.Request, crw *CustomResponseWriter, bodyString string, s
 		ClientOsVersion:      ua.OSInfo().Version,
 		ClientDevice:         device,
 		UserId:               userId,
+		ActorId:              actorId,
 		Actions:              actions,
 		Resource:             "GraphQLApi",
 		Errors:               errors,
@@ -157,15 +157,19 @@ func prepareLog(r *http.Request, crw *CustomResponseWriter, bodyString string, s
 	return logData, nil
 }
 
-func getUserIdFromJWT(token string) int {
-	config := config.NewConfig()
-	user, err := auth.DecodeJWTToken(token, config.SecretKey)
-	userId := -1
+// getUserFromJWT returns the user of a token and, when a staff member is impersonating
+// that user, the staff member's id; -1 and 0 when the token is invalid
+func getUserFromJWT(token string) (int, int) {
+	user, err := auth.VerifyToken(token)
+	userId, actorId := -1, 0
 	if err == nil {
 		userId = user.UserID
+		if user.Impersonated() {
+			actorId = user.Act.UserID
+		}
 	}
 
-	return userId
+	return userId, actorId
 }
 
 func transformGraphResolves(query string) (string, error) {
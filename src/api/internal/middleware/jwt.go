@@ -1,12 +1,11 @@
 package middleware
 
 import (
-	"api/config"
 	"api/internal/auth"
+	"api/internal/tenant"
+	"errors"
 	"net/http"
 	"strings"
-
-	"github.com/dgrijalva/jwt-go"
 )
 
 func JWTMiddleware(excludedRoutes []string) func(http.Handler) http.Handler {
@@ -22,16 +21,42 @@ func JWTMiddleware(excludedRoutes []string) func(http.Handler) http.Handler {
 				}
 			}
 
-			// Validate the JWT token
-			tokenString := getTokenFromRequest(r)
-			if tokenString == "" {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			// Service-to-service calls authenticate with a scoped API key instead of a token.
+			// Keys cannot answer an MFA challenge, so MFA is enforced when they are created.
+			if value := r.Header.Get(auth.APIKeyHeader); value != "" {
+				var ok bool
+				key, err := auth.GetAPIKeyService().Authenticate(value, auth.ClientIP(r))
+				if err != nil {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				r = r.WithContext(auth.WithAPIKey(r.Context(), key))
+				if r, ok = attachTenant(w, r, nil); !ok {
+					return
+				}
+
+				authorized, err := auth.HasUserApiPermission(r)
+				if err != nil || !authorized {
+					http.Error(w, "Unauthorized", http.StatusUnauthorized)
+					return
+				}
+				next.ServeHTTP(w, r)
 				return
 			}
 
-			token, err := validateToken(tokenString)
-			if err != nil || !token.Valid {
-				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			claims, ok := authenticate(w, r)
+			if !ok || !allowImpersonated(w, r, claims) {
+				return
+			}
+			r = r.WithContext(auth.WithClaims(r.Context(), claims))
+
+			// Roles that require MFA only accept tokens from a login that passed it
+			satisfied, err := auth.GetMFAService().SatisfiesMFA(claims)
+			if err != nil || !satisfied {
+				http.Error(w, "MFA required", http.StatusForbidden)
+				return
+			}
+			if r, ok = attachTenant(w, r, claims); !ok {
 				return
 			}
 
@@ -48,6 +73,90 @@ func JWTMiddleware(excludedRoutes []string) func(http.Handler) http.Handler {
 	}
 }
 
+// AuthenticatedMiddleware only requires a valid, unrevoked access token. It guards
+// the user's own account endpoints, such as MFA enrollment, which need no API
+// permission and must stay reachable before a required MFA is set up.
+func AuthenticatedMiddleware() func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			claims, ok := authenticate(w, r)
+			if !ok || !allowImpersonated(w, r, claims) {
+				return
+			}
+			r = r.WithContext(auth.WithClaims(r.Context(), claims))
+			if r, ok = attachTenant(w, r, claims); !ok {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// authenticate validates the request's access token or writes a 401
+func authenticate(w http.ResponseWriter, r *http.Request) (*auth.JwtClaims, bool) {
+	tokenString := getTokenFromRequest(r)
+	if tokenString == "" {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	claims, err := auth.VerifyToken(tokenString)
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Tokens revoked by logout or refresh token reuse are rejected before they expire
+	if auth.IsTokenRevoked(claims.Id) {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+
+	// Every request made while impersonating is kept in the audit trail
+	if claims.Impersonated() {
+		auth.RecordAuthEvent(r, auth.AuthEvent{
+			EventType: auth.EventImpersonatedCall,
+			UserID:    claims.UserID,
+			Username:  claims.Username,
+			Actor:     claims.Act.Username,
+			Success:   true,
+			Detail:    r.Method + " " + r.URL.Path,
+		})
+	}
+	return claims, true
+}
+
+// allowImpersonated writes a 403 for requests that change anything with an impersonation
+// token. Support staff see what the user sees but must not act for them, e.g. create
+// API keys, OAuth clients or other credentials, or move money.
+func allowImpersonated(w http.ResponseWriter, r *http.Request, claims *auth.JwtClaims) bool {
+	if claims.Impersonated() && !isReadOnlyMethod(r.Method) {
+		http.Error(w, "Not allowed while impersonating", http.StatusForbidden)
+		return false
+	}
+	return true
+}
+
+// isReadOnlyMethod reports whether a request method does not change anything
+func isReadOnlyMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// attachTenant stores the tenant of an authenticated request in its context, or writes
+// a 403 when the tenant is suspended. Handlers and the tenant-scoped database rely on it.
+func attachTenant(w http.ResponseWriter, r *http.Request, claims *auth.JwtClaims) (*http.Request, bool) {
+	scoped, err := tenant.GetService().Attach(r, claims)
+	if errors.Is(err, tenant.ErrTenantSuspended) {
+		http.Error(w, "Tenant suspended", http.StatusForbidden)
+		return nil, false
+	}
+	if err != nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return nil, false
+	}
+	return scoped, true
+}
+
 func getTokenFromRequest(r *http.Request) string {
 	// Check if the token is present in the request header
 	token := r.Header.Get("Authorization")
@@ -63,18 +172,3 @@ func getTokenFromRequest(r *http.Request) string {
 
 	return ""
 }
-
-func validateToken(tokenString string) (*jwt.Token, error) {
-	config := config.NewConfig()
-	// Parse and validate the JWT token
-	token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		return []byte(config.SecretKey), nil // Replace "your-secret-key" with your actual secret key
-	})
-
-	// fmt.Println("Token", token)
-	if err != nil {
-		return nil, err
-	}
-
-	return token, nil
-}
@@ -3,52 +3,484 @@ package middleware
 import (
 	"encoding/json"
 	"fmt"
+	"log"
+	"math"
 	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	"api/config"
+	"api/internal/auth"
+	"api/internal/cache"
 	"api/internal/handler"
+	"api/internal/tenant"
 
+	"github.com/spf13/viper"
 	"golang.org/x/time/rate"
 )
 
-// Define the rate limiter middleware
-func RateLimitMiddleware(limit rate.Limit, burst int) func(http.Handler) http.Handler {
-	limiter := rate.NewLimiter(limit, burst)
+const (
+	// defaultRateLimitIdle is how long an unused client limiter is kept when no idle time is configured
+	defaultRateLimitIdle = 10 * time.Minute
+	// rateLimitKeyPrefix separates the limiters from other entries of a shared store
+	rateLimitKeyPrefix = "ratelimit:"
+	// rateLimitPolicyTTL is how long a client keeps its resolved policy before its roles
+	// and tenant rate are looked up again
+	rateLimitPolicyTTL = time.Minute
+	// rateLimitStoreRetry is how often a shared store that could not be created is tried again
+	rateLimitStoreRetry = 30 * time.Second
+)
 
-	return func(next http.Handler) http.Handler {
-		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			requestID, ok := r.Context().Value(requestContextKey).(string)
-			if !ok {
-				requestID = ""
-			}
-			fmt.Printf("RateLimitMiddleware: Got RequestID: %s\n", requestID)
+// RateLimitPolicy is the rate a client may send requests at. Every client gets a token
+// bucket of Burst requests that refills at Limit requests per second.
+type RateLimitPolicy struct {
+	Name  string
+	Limit rate.Limit
+	Burst int
+}
+
+// RateLimitResult is the outcome of counting a request against a client's limiter.
+// Unavailable is set when a shared store could not be reached and the limiter fails closed.
+type RateLimitResult struct {
+	Allowed     bool
+	Unavailable bool
+	Limit       int
+	Remaining   int
+	RetryAfter  time.Duration
+}
+
+// RateLimitStore counts requests in a backend shared by every server instance
+type RateLimitStore interface {
+	AllowRate(key string, emission time.Duration, burst int) (cache.RateLimitResult, error)
+}
+
+// sharedRateLimitStore creates a shared store on first use. While it cannot be created,
+// AllowRate fails with the error, so that the limiter applies its fail open or closed
+// policy, and creating the store is tried again at most once per retry.
+type sharedRateLimitStore struct {
+	open  func() (RateLimitStore, error)
+	retry time.Duration
+
+	mu      sync.Mutex
+	store   RateLimitStore
+	err     error
+	retryAt time.Time
+}
+
+// NewSharedRateLimitStore returns a store that counts requests in the store open creates
+func NewSharedRateLimitStore(open func() (RateLimitStore, error), retry time.Duration) RateLimitStore {
+	return &sharedRateLimitStore{open: open, retry: retry}
+}
+
+// AllowRate counts a request in the shared store, or fails while there is none
+func (s *sharedRateLimitStore) AllowRate(key string, emission time.Duration, burst int) (cache.RateLimitResult, error) {
+	store, err := s.get()
+	if err != nil {
+		return cache.RateLimitResult{}, err
+	}
+	return store.AllowRate(key, emission, burst)
+}
+
+// get returns the shared store, creating it when it is due
+func (s *sharedRateLimitStore) get() (RateLimitStore, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.store != nil {
+		return s.store, nil
+	}
+	if time.Now().Before(s.retryAt) {
+		return nil, s.err
+	}
+	store, err := s.open()
+	if err != nil {
+		s.err = fmt.Errorf("shared rate limit store unavailable: %w", err)
+		s.retryAt = time.Now().Add(s.retry)
+		log.Printf("[error] - %v", s.err)
+		return nil, s.err
+	}
+	s.store = store
+	return store, nil
+}
+
+// clientLimiter is the token bucket of one client under one policy. With a shared
+// store the bucket lives in the store, and only the resolved policy is kept here.
+type clientLimiter struct {
+	policy     RateLimitPolicy
+	limiter    *rate.Limiter
+	resolvedAt time.Time
+	lastSeen   time.Time
+}
+
+// RateLimiter keeps a limiter per client and policy. Clients are API keys, users and,
+// for anonymous requests, client IPs. Limiters unused for the idle time are evicted.
+type RateLimiter struct {
+	mu        sync.Mutex
+	clients   map[string]*clientLimiter
+	idle      time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+	// roles and rolesOf give users with some roles a policy of their own
+	roles   map[string]RateLimitPolicy
+	rolesOf func(userID int) ([]string, error)
+	// tenantOf returns the rate a tenant configured for its clients, if any
+	tenantOf func(tenantID int) (RateLimitPolicy, bool)
+	// store shares the limits between instances; failOpen allows requests it cannot count
+	store    RateLimitStore
+	failOpen bool
+}
 
-			/*cookie, err := r.Cookie("request_id")
-			if err == nil {
-				requestID = cookie.Value
+var (
+	rateLimiterOnce     sync.Once
+	rateLimiterInstance *RateLimiter
+)
+
+// NewRateLimiter creates a RateLimiter that evicts limiters unused for idle
+func NewRateLimiter(idle time.Duration) *RateLimiter {
+	if idle <= 0 {
+		idle = defaultRateLimitIdle
+	}
+	return &RateLimiter{clients: make(map[string]*clientLimiter), idle: idle, now: time.Now}
+}
+
+// GetRateLimiter returns the shared RateLimiter, with the role policies of RATE_LIMIT_ROLES
+// and the rates tenants configured
+func GetRateLimiter() *RateLimiter {
+	rateLimiterOnce.Do(func() {
+		cfg := config.NewConfig()
+		limiter := NewRateLimiter(time.Duration(cfg.RateLimitIdle) * time.Minute)
+
+		roles, err := ParseRoleRateLimits(cfg.RateLimitRoles)
+		if err != nil {
+			log.Printf("[error] - %s: %v", config.RateLimitRoles, err)
+		}
+		roleRepo := auth.NewRoleRepo()
+		limiter.SetRolePolicies(roles, func(userID int) ([]string, error) {
+			userRoles, err := roleRepo.GetRolesByUserID(userID)
+			if err != nil {
+				return nil, err
+			}
+			names := make([]string, 0, len(userRoles))
+			for _, role := range userRoles {
+				names = append(names, role.RoleName)
 			}
+			return names, nil
+		})
+		limiter.SetTenantPolicies(tenantRateLimit)
+		// Instances behind the load balancer share their limits through the cache. Without
+		// Redis the limits are not counted per process: requests fail open or closed as configured.
+		if cfg.RateLimitShared {
+			backend := cache.IntToCacheBackend(viper.GetInt("CACHE_PROVIDER"))
+			store := NewSharedRateLimitStore(func() (RateLimitStore, error) {
+				shared, err := cache.NewSharedCache(backend)
+				if err != nil {
+					return nil, err
+				}
+				return shared, nil
+			}, rateLimitStoreRetry)
+			limiter.SetStore(store, cfg.RateLimitOpen)
+		}
+		rateLimiterInstance = limiter
+	})
+	return rateLimiterInstance
+}
+
+// SetRolePolicies gives users holding one of the roles its policy instead of the route's
+func (l *RateLimiter) SetRolePolicies(roles map[string]RateLimitPolicy, rolesOf func(userID int) ([]string, error)) {
+	l.roles = roles
+	l.rolesOf = rolesOf
+}
+
+// SetTenantPolicies sets how the rate a tenant configured for its clients is looked up
+func (l *RateLimiter) SetTenantPolicies(tenantOf func(tenantID int) (RateLimitPolicy, bool)) {
+	l.tenantOf = tenantOf
+}
 
-			fmt.Printf("RateLimitMiddleware: Got RequestID from cookie: %s\n", requestID)
-			*/
+// SetStore counts requests in a store shared by server instances instead of in the
+// process. When the store fails, requests are allowed if failOpen and refused otherwise.
+func (l *RateLimiter) SetStore(store RateLimitStore, failOpen bool) {
+	l.store = store
+	l.failOpen = failOpen
+}
 
-			// Check if the request exceeds the rate limit
-			if !limiter.Allow() {
-				w.Header().Set("Content-Type", "application/json")
-				w.WriteHeader(http.StatusTooManyRequests)
+// SetClock replaces the time source; the limiters refill by it
+func (l *RateLimiter) SetClock(now func() time.Time) {
+	l.now = now
+}
 
-				errResp := handler.NewErrorResponse(
-					http.StatusTooManyRequests,
-					"Rate limit exceeded",
-					"RATE_LIMIT_EXCEEDED",
-					"Too many requests, please try again later",
-					requestID,
-				)
+// Clients returns the number of limiters kept
+func (l *RateLimiter) Clients() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.clients)
+}
 
-				json.NewEncoder(w).Encode(errResp)
+// Middleware limits each client of the routes it guards to policy. On authenticated
+// route groups it must come after the authentication middleware, so that API keys
+// and the request's tenant are known.
+func (l *RateLimiter) Middleware(policy RateLimitPolicy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			client, userID := rateLimitClient(r)
+			tenantID, _ := auth.TenantIDFromContext(r.Context())
+			result := l.Allow(client, userID, tenantID, policy)
+
+			w.Header().Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+			w.Header().Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+			if result.Unavailable {
+				w.Header().Set("Retry-After", "1")
+				writeRateLimitUnavailable(w, r)
+				return
+			}
+			if !result.Allowed {
+				retryAfter := int(math.Max(1, math.Ceil(result.RetryAfter.Seconds())))
+				w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+				writeRateLimitExceeded(w, r)
 				return
 			}
-
-			// Proceed to the next handler if the rate limit is not exceeded
 			next.ServeHTTP(w, r)
 		})
 	}
 }
+
+// Allow counts a request of client against its limiter for policy. Authenticated clients
+// get the rate of their tenant or of their roles when one is configured.
+func (l *RateLimiter) Allow(client string, userID, tenantID int, policy RateLimitPolicy) RateLimitResult {
+	key := policy.Name + "|" + client
+	entry := l.client(key, policy, userID, tenantID)
+	if l.store != nil && entry.policy.Limit > 0 && entry.policy.Limit != rate.Inf {
+		return l.allowShared(key, entry.policy)
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	result := RateLimitResult{Limit: entry.limiter.Burst()}
+	if entry.limiter.AllowN(now, 1) {
+		result.Allowed = true
+		result.Remaining = int(math.Max(0, entry.limiter.TokensAt(now)))
+		return result
+	}
+	// The wait until a whole token is back; a limiter that never refills asks for a second
+	result.RetryAfter = time.Second
+	if limit := entry.limiter.Limit(); limit > 0 {
+		missing := 1 - entry.limiter.TokensAt(now)
+		result.RetryAfter = time.Duration(missing / float64(limit) * float64(time.Second))
+	}
+	return result
+}
+
+// client returns the limiter of a client, creating it with the client's policy on
+// first use, and evicts idle limiters. The policy is resolved again once it is older
+// than rateLimitPolicyTTL, so that role and tenant rate changes reach active clients.
+func (l *RateLimiter) client(key string, policy RateLimitPolicy, userID, tenantID int) *clientLimiter {
+	l.mu.Lock()
+	entry, ok := l.clients[key]
+	stale := ok && l.now().Sub(entry.resolvedAt) >= rateLimitPolicyTTL
+	l.mu.Unlock()
+	if !ok || stale {
+		// Policies are resolved outside the lock, since roles and tenants come from the database
+		resolved := l.resolve(policy, userID, tenantID)
+		l.mu.Lock()
+		now := l.now()
+		if entry, ok = l.clients[key]; !ok {
+			entry = &clientLimiter{policy: resolved, limiter: rate.NewLimiter(resolved.Limit, resolved.Burst), lastSeen: now}
+			l.clients[key] = entry
+		} else if entry.policy != resolved {
+			// The bucket keeps the tokens already spent under the old policy
+			entry.policy = resolved
+			entry.limiter.SetLimitAt(now, resolved.Limit)
+			entry.limiter.SetBurstAt(now, resolved.Burst)
+		}
+		entry.resolvedAt = now
+		l.mu.Unlock()
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := l.now()
+	entry.lastSeen = now
+	l.sweep(now)
+	return entry
+}
+
+// allowShared counts a request in the shared store
+func (l *RateLimiter) allowShared(key string, policy RateLimitPolicy) RateLimitResult {
+	emission := time.Duration(float64(time.Second) / float64(policy.Limit))
+	shared, err := l.store.AllowRate(rateLimitKeyPrefix+key, emission, policy.Burst)
+	if err != nil {
+		log.Printf("[error] - Rate limit store: %v", err)
+		if l.failOpen {
+			return RateLimitResult{Allowed: true, Limit: policy.Burst, Remaining: policy.Burst}
+		}
+		return RateLimitResult{Unavailable: true, Limit: policy.Burst, RetryAfter: time.Second}
+	}
+	return RateLimitResult{Allowed: shared.Allowed, Limit: policy.Burst, Remaining: shared.Remaining, RetryAfter: shared.RetryAfter}
+}
+
+// resolve returns the policy a client gets: the most generous policy of the user's roles,
+// else the rate of the tenant, else the route's policy
+func (l *RateLimiter) resolve(policy RateLimitPolicy, userID, tenantID int) RateLimitPolicy {
+	resolved := policy
+	if userID == 0 {
+		return resolved
+	}
+	if l.tenantOf != nil && tenantID > 0 {
+		if tenantPolicy, ok := l.tenantOf(tenantID); ok {
+			resolved.Limit, resolved.Burst = tenantPolicy.Limit, tenantPolicy.Burst
+		}
+	}
+	if l.rolesOf == nil || len(l.roles) == 0 {
+		return resolved
+	}
+	names, err := l.rolesOf(userID)
+	if err != nil {
+		log.Printf("[error] - Rate limit roles of user %d: %v", userID, err)
+		return resolved
+	}
+	var best *RateLimitPolicy
+	for _, name := range names {
+		if rolePolicy, ok := l.roles[name]; ok && (best == nil || rolePolicy.Limit > best.Limit) {
+			best = &rolePolicy
+		}
+	}
+	if best != nil {
+		resolved.Limit, resolved.Burst = best.Limit, best.Burst
+	}
+	return resolved
+}
+
+// sweep evicts the limiters that have been idle; it runs at most once per idle time
+func (l *RateLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < l.idle {
+		return
+	}
+	l.lastSweep = now
+	for key, entry := range l.clients {
+		if now.Sub(entry.lastSeen) >= l.idle {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// rateLimitClient returns who a request is counted against: its API key, the user of
+// its access token, or else its client IP. The user id is 0 for anonymous clients.
+func rateLimitClient(r *http.Request) (string, int) {
+	if key := auth.APIKeyFromContext(r.Context()); key != nil {
+		return "key:" + key.ID, key.UserID
+	}
+	if token := getTokenFromRequest(r); token != "" {
+		if claims, err := auth.VerifyToken(token); err == nil {
+			return "user:" + strconv.Itoa(claims.UserID), claims.UserID
+		}
+	}
+	return "ip:" + auth.ClientIP(r), 0
+}
+
+// tenantRateLimit returns the rate a tenant configured, if any
+func tenantRateLimit(tenantID int) (RateLimitPolicy, bool) {
+	tenantConfig, err := tenant.GetService().Config(tenantID)
+	if err != nil || tenantConfig.RateLimit.RequestsPerSecond <= 0 {
+		return RateLimitPolicy{}, false
+	}
+	burst := tenantConfig.RateLimit.Burst
+	if burst <= 0 {
+		burst = int(math.Ceil(tenantConfig.RateLimit.RequestsPerSecond))
+	}
+	return RateLimitPolicy{Limit: rate.Limit(tenantConfig.RateLimit.RequestsPerSecond), Burst: burst}, true
+}
+
+// APIRateLimitPolicy is the configured policy of authenticated routes
+func APIRateLimitPolicy() RateLimitPolicy {
+	cfg := config.NewConfig()
+	return RateLimitPolicy{Name: "api", Limit: rate.Limit(cfg.RateLimitReqSec), Burst: cfg.RateLimitBurst}
+}
+
+// PublicRateLimitPolicy is the configured policy of routes that need no token, such as login
+func PublicRateLimitPolicy() RateLimitPolicy {
+	spec := config.NewConfig().RateLimitPublic
+	policy, err := ParseRateLimitPolicy("public", spec)
+	if err != nil {
+		log.Printf("[error] - %s: %v", config.RateLimitPublic, err)
+		policy, _ = ParseRateLimitPolicy("public", "2:10")
+	}
+	return policy
+}
+
+// ParseRateLimitPolicy parses a policy written as "<requests per second>:<burst>"
+func ParseRateLimitPolicy(name, spec string) (RateLimitPolicy, error) {
+	limitText, burstText, ok := strings.Cut(strings.TrimSpace(spec), ":")
+	limit, err := strconv.ParseFloat(limitText, 64)
+	if !ok || err != nil || limit <= 0 {
+		return RateLimitPolicy{}, fmt.Errorf("invalid rate limit %q; expected <requests per second>:<burst>", spec)
+	}
+	burst, err := strconv.Atoi(burstText)
+	if err != nil || burst < 1 {
+		return RateLimitPolicy{}, fmt.Errorf("invalid rate limit %q; expected <requests per second>:<burst>", spec)
+	}
+	return RateLimitPolicy{Name: name, Limit: rate.Limit(limit), Burst: burst}, nil
+}
+
+// ParseRoleRateLimits parses role policies written as "<role>=<requests per second>:<burst>,...".
+// The policies that could be parsed are returned along with the first error.
+func ParseRoleRateLimits(spec string) (map[string]RateLimitPolicy, error) {
+	policies := map[string]RateLimitPolicy{}
+	var firstErr error
+	for _, entry := range strings.Split(spec, ",") {
+		if strings.TrimSpace(entry) == "" {
+			continue
+		}
+		role, policySpec, _ := strings.Cut(entry, "=")
+		role = strings.TrimSpace(role)
+		policy, err := ParseRateLimitPolicy(role, policySpec)
+		if err == nil && role == "" {
+			err = fmt.Errorf("invalid role rate limit %q; expected <role>=<requests per second>:<burst>", entry)
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		policies[role] = policy
+	}
+	return policies, firstErr
+}
+
+// RateLimitMiddleware limits every client to limit requests per second with bursts of burst
+func RateLimitMiddleware(limit rate.Limit, burst int) func(http.Handler) http.Handler {
+	return NewRateLimiter(defaultRateLimitIdle).Middleware(RateLimitPolicy{Name: "default", Limit: limit, Burst: burst})
+}
+
+// writeRateLimitUnavailable writes a 503 response for a limiter that fails closed
+func writeRateLimitUnavailable(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusServiceUnavailable)
+
+	errResp := handler.NewErrorResponse(
+		http.StatusServiceUnavailable,
+		"Service Unavailable",
+		"RATE_LIMIT_UNAVAILABLE",
+		"Requests cannot be rate limited right now, please try again later",
+		GetRequestID(r),
+	)
+
+	json.NewEncoder(w).Encode(errResp)
+}
+
+// writeRateLimitExceeded writes a 429 response
+func writeRateLimitExceeded(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusTooManyRequests)
+
+	errResp := handler.NewErrorResponse(
+		http.StatusTooManyRequests,
+		"Rate limit exceeded",
+		"RATE_LIMIT_EXCEEDED",
+		"Too many requests, please try again later",
+		GetRequestID(r),
+	)
+
+	json.NewEncoder(w).Encode(errResp)
+}
@@ -0,0 +1,376 @@
+package payment
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BatchFormat is the file format of an uploaded batch
+type BatchFormat string
+
+const (
+	BatchCSV   BatchFormat = "csv"
+	BatchJSONL BatchFormat = "jsonl"
+)
+
+// BatchMode controls how the valid rows of a batch are inserted
+type BatchMode string
+
+const (
+	// BatchAtomic inserts every valid row in one transaction, or none of them
+	BatchAtomic BatchMode = "ATOMIC"
+	// BatchPerRow inserts each valid row on its own, so one failure does not affect the others
+	BatchPerRow BatchMode = "PER_ROW"
+)
+
+// BatchStatus represents the lifecycle state of a payment batch
+type BatchStatus string
+
+const (
+	BatchPending   BatchStatus = "PENDING"
+	BatchExecuting BatchStatus = "EXECUTING"
+	BatchCompleted BatchStatus = "COMPLETED"
+	BatchPartial   BatchStatus = "PARTIAL"
+	BatchFailed    BatchStatus = "FAILED"
+	BatchCancelled BatchStatus = "CANCELLED"
+)
+
+// RowStatus represents the outcome of one batch row
+type RowStatus string
+
+const (
+	RowValid    RowStatus = "VALID"
+	RowInvalid  RowStatus = "INVALID"
+	RowInserted RowStatus = "INSERTED"
+	// RowReview rows are inserted but their payment is held until the risk review queue approves it
+	RowReview  RowStatus = "REVIEW"
+	RowFailed  RowStatus = "FAILED"
+	RowSkipped RowStatus = "SKIPPED"
+)
+
+// Payment methods accepted in batches
+const (
+	MethodBankTransfer = "bank_transfer"
+	MethodCreditCard   = "credit_card"
+	MethodDebitCard    = "debit_card"
+	MethodCash         = "cash"
+	MethodCheque       = "cheque"
+	MethodEWallet      = "e_wallet"
+)
+
+var batchMethods = map[string]bool{
+	MethodBankTransfer: true,
+	MethodCreditCard:   true,
+	MethodDebitCard:    true,
+	MethodCash:         true,
+	MethodCheque:       true,
+	MethodEWallet:      true,
+}
+
+// MaxBatchRows limits the number of rows in one upload
+const MaxBatchRows = 5000
+
+// BatchPaymentStatus is the status of payments created by a batch
+const BatchPaymentStatus = "pending"
+
+// ErrInvalidBatch is returned when an upload cannot be read as a batch at all
+var ErrInvalidBatch = errors.New("invalid payment batch")
+
+var currencyPattern = regexp.MustCompile(`^[A-Z]{3}$`)
+
+// PaymentBatch is an uploaded set of payouts and its per-row report
+type PaymentBatch struct {
+	BatchID      string      `json:"batch_id"`
+	FileName     string      `json:"file_name"`
+	Format       BatchFormat `json:"format" example:"csv"`
+	Mode         BatchMode   `json:"mode" example:"PER_ROW"`
+	Status       BatchStatus `json:"status"`
+	TotalRows    int         `json:"total_rows"`
+	ValidRows    int         `json:"valid_rows"`
+	InvalidRows  int         `json:"invalid_rows"`
+	InsertedRows int         `json:"inserted_rows"`
+	FailedRows   int         `json:"failed_rows"`
+	ExecuteAt    *time.Time  `json:"execute_at,omitempty"`
+	ExecutedAt   *time.Time  `json:"executed_at,omitempty"`
+	CreatedAt    time.Time   `json:"created_at"`
+	CreatedBy    string      `json:"created_by"`
+	UpdatedAt    time.Time   `json:"updated_at"`
+	TenantID     int         `json:"tenant_id"`
+	Rows         []BatchRow  `json:"rows,omitempty"`
+}
+
+// BatchRow is one payout in a batch with its validation and execution result
+type BatchRow struct {
+	BatchID       string    `json:"-"`
+	RowNumber     int       `json:"row_number"`
+	PaymentID     string    `json:"payment_id"`
+	Amount        float64   `json:"amount" example:"120.50"`
+	Currency      string    `json:"currency" example:"USD"`
+	PayTo         string    `json:"pay_to" example:"ACME Ltd"`
+	PaymentMethod string    `json:"payment_method" example:"bank_transfer"`
+	PaymentDate   string    `json:"payment_date,omitempty" example:"2025-01-31"`
+	Note          string    `json:"note,omitempty"`
+	Description   string    `json:"description,omitempty"`
+	Status        RowStatus `json:"status"`
+	Errors        []string  `json:"errors,omitempty"`
+}
+
+// batchInput is the shape of one JSON line
+type batchInput struct {
+	Amount        json.Number `json:"amount"`
+	Currency      string      `json:"currency"`
+	PayTo         string      `json:"pay_to"`
+	PaymentMethod string      `json:"payment_method"`
+	PaymentDate   string      `json:"payment_date"`
+	Note          string      `json:"note"`
+	Description   string      `json:"description"`
+}
+
+// ParseBatchMode converts a mode flag, defaulting to per-row
+func ParseBatchMode(mode string) (BatchMode, error) {
+	switch strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(mode), "-", "_")) {
+	case "", string(BatchPerRow):
+		return BatchPerRow, nil
+	case string(BatchAtomic):
+		return BatchAtomic, nil
+	}
+	return "", fmt.Errorf("%w: unsupported mode %q", ErrInvalidBatch, mode)
+}
+
+// DetectBatchFormat picks the format from an explicit flag, the content type, the file name or the content
+func DetectBatchFormat(format, contentType, fileName string, data []byte) (BatchFormat, error) {
+	switch strings.ToLower(strings.TrimSpace(format)) {
+	case "csv":
+		return BatchCSV, nil
+	case "jsonl", "ndjson", "json":
+		return BatchJSONL, nil
+	case "":
+	default:
+		return "", fmt.Errorf("%w: unsupported format %q", ErrInvalidBatch, format)
+	}
+
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "csv"):
+		return BatchCSV, nil
+	case strings.Contains(contentType, "ndjson"), strings.Contains(contentType, "jsonl"):
+		return BatchJSONL, nil
+	}
+
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return BatchCSV, nil
+	case ".jsonl", ".ndjson":
+		return BatchJSONL, nil
+	}
+
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("{")) {
+		return BatchJSONL, nil
+	}
+	return BatchCSV, nil
+}
+
+// ParseBatch reads the rows of an upload and validates each of them.
+// Row-level problems are reported on the row; only an unreadable file returns an error.
+func ParseBatch(format BatchFormat, data []byte) ([]BatchRow, error) {
+	var rows []BatchRow
+	var err error
+	switch format {
+	case BatchCSV:
+		rows, err = parseBatchCSV(data)
+	case BatchJSONL:
+		rows, err = parseBatchJSONL(data)
+	default:
+		return nil, fmt.Errorf("%w: unsupported format %q", ErrInvalidBatch, format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("%w: no rows", ErrInvalidBatch)
+	}
+	if len(rows) > MaxBatchRows {
+		return nil, fmt.Errorf("%w: more than %d rows", ErrInvalidBatch, MaxBatchRows)
+	}
+
+	for i := range rows {
+		rows[i].Validate()
+	}
+	return rows, nil
+}
+
+func parseBatchCSV(data []byte) ([]BatchRow, error) {
+	reader := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(data, []byte("\ufeff"))))
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: empty file", ErrInvalidBatch)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"amount", "currency", "pay_to", "payment_method"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing column %q", ErrInvalidBatch, required)
+		}
+	}
+
+	var rows []BatchRow
+	// Row numbers count data rows from 1, so the header is line 1 and row 1 is line 2
+	for rowNumber := 1; ; rowNumber++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: row %d: %v", ErrInvalidBatch, rowNumber, err)
+		}
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := BatchRow{
+			RowNumber:     rowNumber,
+			Currency:      field("currency"),
+			PayTo:         field("pay_to"),
+			PaymentMethod: field("payment_method"),
+			PaymentDate:   field("payment_date"),
+			Note:          field("note"),
+			Description:   field("description"),
+		}
+		row.setAmount(field("amount"))
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+func parseBatchJSONL(data []byte) ([]BatchRow, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	var rows []BatchRow
+	rowNumber := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		rowNumber++
+
+		row := BatchRow{RowNumber: rowNumber}
+		var input batchInput
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		if err := decoder.Decode(&input); err != nil {
+			row.Errors = append(row.Errors, "invalid JSON: "+err.Error())
+			rows = append(rows, row)
+			continue
+		}
+
+		row.Currency = strings.TrimSpace(input.Currency)
+		row.PayTo = strings.TrimSpace(input.PayTo)
+		row.PaymentMethod = strings.TrimSpace(input.PaymentMethod)
+		row.PaymentDate = strings.TrimSpace(input.PaymentDate)
+		row.Note = input.Note
+		row.Description = input.Description
+		row.setAmount(input.Amount.String())
+		rows = append(rows, row)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidBatch, err)
+	}
+	return rows, nil
+}
+
+func (row *BatchRow) setAmount(value string) {
+	if value == "" {
+		return
+	}
+	amount, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		row.Errors = append(row.Errors, fmt.Sprintf("amount %q is not a number", value))
+		return
+	}
+	row.Amount = amount
+}
+
+// Validate checks amount, currency, pay_to and payment_method and sets the row status.
+// Errors found while parsing the row are kept.
+func (row *BatchRow) Validate() {
+	row.Currency = strings.ToUpper(row.Currency)
+	row.PaymentMethod = strings.ToLower(row.PaymentMethod)
+
+	switch {
+	case row.Amount <= 0 && len(row.Errors) == 0:
+		row.Errors = append(row.Errors, "amount must be greater than zero")
+	case math.IsInf(row.Amount, 0) || math.IsNaN(row.Amount):
+		row.Errors = append(row.Errors, "amount must be a finite number")
+	case math.Abs(row.Amount*100-math.Round(row.Amount*100)) > 1e-6:
+		row.Errors = append(row.Errors, "amount must have at most two decimal places")
+	}
+	if !currencyPattern.MatchString(row.Currency) {
+		row.Errors = append(row.Errors, "currency must be a three-letter ISO 4217 code")
+	}
+	if row.PayTo == "" {
+		row.Errors = append(row.Errors, "pay_to is required")
+	} else if len(row.PayTo) > 255 {
+		row.Errors = append(row.Errors, "pay_to must be at most 255 characters")
+	}
+	if !batchMethods[row.PaymentMethod] {
+		row.Errors = append(row.Errors, fmt.Sprintf("payment_method %q is not supported", row.PaymentMethod))
+	}
+	if row.PaymentDate != "" {
+		if _, err := time.Parse("2006-01-02", row.PaymentDate); err != nil {
+			row.Errors = append(row.Errors, "payment_date must be YYYY-MM-DD")
+		}
+	}
+
+	if len(row.Errors) > 0 {
+		row.Status = RowInvalid
+	} else {
+		row.Status = RowValid
+	}
+}
+
+// payment builds the payment inserted for a valid row
+func (row *BatchRow) payment(now time.Time) *Payment {
+	paymentDate := row.PaymentDate
+	if paymentDate == "" {
+		paymentDate = now.Format("2006-01-02")
+	}
+	return &Payment{
+		ID:            row.PaymentID,
+		PaymentID:     row.PaymentID,
+		Amount:        row.Amount,
+		Currency:      row.Currency,
+		PaymentMethod: row.PaymentMethod,
+		PaymentDate:   paymentDate,
+		PayTo:         row.PayTo,
+		Note:          row.Note,
+		Status:        BatchPaymentStatus,
+		Description:   row.Description,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
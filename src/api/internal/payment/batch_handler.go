@@ -0,0 +1,175 @@
+package payment
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"api/internal/tenant"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxBatchSize limits uploaded batch files to 10 MB
+const maxBatchSize = 10 << 20
+
+// BatchHandler handles HTTP requests for bulk payment batches
+type BatchHandler struct {
+	processor *BatchProcessor
+}
+
+// NewBatchHandler creates a new BatchHandler
+func NewBatchHandler(processor *BatchProcessor) *BatchHandler {
+	return &BatchHandler{processor: processor}
+}
+
+// CreateBatchHandler godoc
+// @Summary Upload a payment batch
+// @Description Upload CSV (header: amount,currency,pay_to,payment_method[,payment_date,note,description]) or JSON lines as multipart field "file" or as the raw body. Every row is validated and reported. Valid rows are scored by the risk engine; flagged rows are inserted with their payment held for review and reported as REVIEW. mode=ATOMIC inserts all valid rows in one transaction; PER_ROW (default) inserts them independently. With execute_at in the future the batch stays PENDING and can be cancelled until then.
+// @Tags payments
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file false "Batch file"
+// @Param mode query string false "ATOMIC or PER_ROW"
+// @Param format query string false "csv or jsonl (detected when omitted)"
+// @Param execute_at query string false "RFC 3339 execution time"
+// @Param file_name query string false "File name when sending the raw body"
+// @Success 201 {object} PaymentBatch
+// @Failure 400 {object} types.ErrorResponse
+// @Router /payments/batches [post]
+func (h *BatchHandler) CreateBatchHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxBatchSize)
+
+	upload := BatchUpload{
+		FileName:    r.URL.Query().Get("file_name"),
+		Format:      r.URL.Query().Get("format"),
+		ContentType: r.Header.Get("Content-Type"),
+		Mode:        r.URL.Query().Get("mode"),
+	}
+	if value := r.URL.Query().Get("execute_at"); value != "" {
+		executeAt, err := time.Parse(time.RFC3339, value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "execute_at must be an RFC 3339 time", GetRequestID(r))
+			return
+		}
+		upload.ExecuteAt = &executeAt
+	}
+
+	var body io.Reader = r.Body
+	if strings.HasPrefix(upload.ContentType, "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Batch file is required", GetRequestID(r))
+			return
+		}
+		defer file.Close()
+		body = file
+		upload.FileName = header.Filename
+		upload.ContentType = header.Header.Get("Content-Type")
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			writeError(w, http.StatusRequestEntityTooLarge, "Batch file is too large", GetRequestID(r))
+			return
+		}
+		writeError(w, http.StatusBadRequest, "Failed to read batch file", GetRequestID(r))
+		return
+	}
+	upload.Data = data
+
+	batch, err := h.processor.Submit(r.Context(), upload, auth.Username(r))
+	if errors.Is(err, tenant.ErrNoTenant) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", GetRequestID(r))
+		return
+	}
+	if errors.Is(err, ErrInvalidBatch) {
+		writeError(w, http.StatusBadRequest, err.Error(), GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to process payment batch", GetRequestID(r))
+		return
+	}
+
+	message := "Payment batch executed"
+	if batch.Status == BatchPending {
+		message = "Payment batch scheduled"
+	}
+	writeSuccess(w, http.StatusCreated, batch, message, GetRequestID(r))
+}
+
+// GetBatchesHandler godoc
+// @Summary Get payment batches
+// @Description Get one batch with its per-row report by id, or list batches optionally filtered by status
+// @Tags payments
+// @Produce json
+// @Param id query string false "Batch ID"
+// @Param status query string false "PENDING, EXECUTING, COMPLETED, PARTIAL, FAILED or CANCELLED"
+// @Success 200 {array} PaymentBatch
+// @Failure 404 {object} types.ErrorResponse
+// @Router /payments/batches [get]
+func (h *BatchHandler) GetBatchesHandler(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		batch, err := h.processor.GetBatch(r.Context(), id)
+		if errors.Is(err, ErrBatchNotFound) {
+			writeError(w, http.StatusNotFound, "Payment batch not found", GetRequestID(r))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to fetch payment batch", GetRequestID(r))
+			return
+		}
+		writeSuccess(w, http.StatusOK, batch, "", GetRequestID(r))
+		return
+	}
+
+	status := BatchStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	batches, err := h.processor.ListBatches(r.Context(), status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch payment batches", GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, batches, "", GetRequestID(r))
+}
+
+// CancelBatchHandler godoc
+// @Summary Cancel a payment batch
+// @Description Only batches that have not started executing can be cancelled
+// @Tags payments
+// @Produce json
+// @Param id query string true "Batch ID"
+// @Success 200 {object} PaymentBatch
+// @Failure 409 {object} types.ErrorResponse
+// @Router /payments/batches/cancel [post]
+func (h *BatchHandler) CancelBatchHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Batch ID is required", GetRequestID(r))
+		return
+	}
+
+	batch, err := h.processor.Cancel(r.Context(), id)
+	switch {
+	case errors.Is(err, ErrBatchNotFound):
+		writeError(w, http.StatusNotFound, "Payment batch not found", GetRequestID(r))
+		return
+	case errors.Is(err, ErrBatchState):
+		writeError(w, http.StatusConflict, err.Error(), GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to cancel payment batch", GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, batch, "Payment batch cancelled successfully", GetRequestID(r))
+}
+
+// RegisterRoutes registers the payment batch routes with the given router
+func (h *BatchHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/payments/batches", h.GetBatchesHandler)
+	r.Post("/payments/batches", h.CreateBatchHandler)
+	r.Post("/payments/batches/cancel", h.CancelBatchHandler)
+}
@@ -0,0 +1,417 @@
+package payment
+
+import (
+	"api/internal/auth"
+	"api/internal/fx"
+	"api/internal/risk"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Batch errors
+var (
+	ErrBatchNotFound = errors.New("payment batch not found")
+	ErrBatchState    = errors.New("payment batch is no longer pending")
+)
+
+// BatchUpload is a batch file with its execution options
+type BatchUpload struct {
+	FileName    string
+	Format      string
+	ContentType string
+	Mode        string
+	ExecuteAt   *time.Time
+	Data        []byte
+}
+
+// BatchProcessor validates uploaded batches, scores their rows and inserts their payments
+type BatchProcessor struct {
+	repo *BatchRepo
+	risk *risk.Service
+	fx   *fx.Converter
+	now  func() time.Time
+}
+
+// NewBatchProcessor creates a new BatchProcessor
+func NewBatchProcessor() *BatchProcessor {
+	return NewBatchProcessorWithRepo(NewBatchRepo(), risk.NewService(), fx.NewConverter())
+}
+
+// NewBatchProcessorWithRepo creates a BatchProcessor on the given repository, risk service and converter
+func NewBatchProcessorWithRepo(repo *BatchRepo, riskService *risk.Service, converter *fx.Converter) *BatchProcessor {
+	return &BatchProcessor{repo: repo, risk: riskService, fx: converter, now: time.Now}
+}
+
+// Submit validates and stores an upload for the tenant of ctx. Without an execution time in the
+// future the batch is executed straight away; otherwise it stays PENDING and can be cancelled until then.
+func (p *BatchProcessor) Submit(ctx context.Context, upload BatchUpload, createdBy string) (*PaymentBatch, error) {
+	tenantID, ok := auth.TenantIDFromContext(ctx)
+	if !ok {
+		return nil, tenant.ErrNoTenant
+	}
+	mode, err := ParseBatchMode(upload.Mode)
+	if err != nil {
+		return nil, err
+	}
+	format, err := DetectBatchFormat(upload.Format, upload.ContentType, upload.FileName, upload.Data)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := ParseBatch(format, upload.Data)
+	if err != nil {
+		return nil, err
+	}
+
+	now := p.now()
+	batch := &PaymentBatch{
+		BatchID:   uuid.New().String(),
+		FileName:  upload.FileName,
+		Format:    format,
+		Mode:      mode,
+		Status:    BatchPending,
+		TotalRows: len(rows),
+		ExecuteAt: upload.ExecuteAt,
+		CreatedAt: now,
+		CreatedBy: createdBy,
+		UpdatedAt: now,
+		TenantID:  tenantID,
+	}
+	for i := range rows {
+		rows[i].BatchID = batch.BatchID
+		if rows[i].Status == RowValid {
+			// The payment id is fixed at upload so a re-run cannot insert a row twice
+			rows[i].PaymentID = uuid.New().String()
+			batch.ValidRows++
+		} else {
+			batch.InvalidRows++
+		}
+	}
+
+	if err := p.repo.InsertBatch(batch, rows); err != nil {
+		return nil, err
+	}
+	if batch.ExecuteAt != nil && batch.ExecuteAt.After(now) {
+		batch.Rows = rows
+		return batch, nil
+	}
+	return p.Execute(ctx, batch.BatchID)
+}
+
+// GetBatch returns a batch of the tenant of ctx with its per-row report
+func (p *BatchProcessor) GetBatch(ctx context.Context, id string) (*PaymentBatch, error) {
+	batch, err := p.repo.GetBatch(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrBatchNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	batch.Rows, err = p.repo.GetBatchRows(id)
+	return batch, err
+}
+
+// ListBatches returns the tenant's batches without their rows, optionally filtered by status
+func (p *BatchProcessor) ListBatches(ctx context.Context, status BatchStatus) ([]PaymentBatch, error) {
+	return p.repo.GetBatches(ctx, status)
+}
+
+// Cancel stops a pending batch of the tenant of ctx from executing
+func (p *BatchProcessor) Cancel(ctx context.Context, id string) (*PaymentBatch, error) {
+	if _, err := p.GetBatch(ctx, id); err != nil {
+		return nil, err
+	}
+	ok, err := p.repo.TransitionBatch(ctx, id, BatchPending, BatchCancelled, p.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBatchState
+	}
+
+	rows, err := p.repo.GetBatchRows(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		if rows[i].Status == RowValid {
+			rows[i].Status = RowSkipped
+			rows[i].Errors = []string{"batch cancelled"}
+			if err := p.repo.UpdateRowStatus(&rows[i]); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return p.GetBatch(ctx, id)
+}
+
+// Execute scores the valid rows of a pending batch and inserts their payments according to its mode.
+// Rows the risk engine flags are inserted with their payment held for review.
+func (p *BatchProcessor) Execute(ctx context.Context, id string) (*PaymentBatch, error) {
+	batch, err := p.GetBatch(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := p.repo.TransitionBatch(ctx, id, BatchPending, BatchExecuting, p.now())
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrBatchState
+	}
+
+	if err := p.execute(ctx, batch); err != nil {
+		return nil, p.fail(ctx, batch, err)
+	}
+	return batch, nil
+}
+
+// execute inserts the valid rows of a batch that was moved to EXECUTING and saves the outcome
+func (p *BatchProcessor) execute(ctx context.Context, batch *PaymentBatch) error {
+	now := p.now()
+	var valid []*BatchRow
+	var payments []*Payment
+	for i := range batch.Rows {
+		if batch.Rows[i].Status == RowValid {
+			valid = append(valid, &batch.Rows[i])
+			payments = append(payments, batch.Rows[i].payment(now))
+		}
+	}
+
+	unpriced := len(valid)
+	valid, payments, err := p.lockRates(batch.Mode, valid, payments)
+	if err != nil {
+		return err
+	}
+	unpriced -= len(valid)
+
+	assessments, err := p.assess(batch.CreatedBy, payments)
+	if err != nil {
+		return err
+	}
+	if batch.Mode == BatchAtomic {
+		err = p.executeAtomic(batch.TenantID, valid, payments)
+	} else {
+		err = p.executePerRow(batch.TenantID, valid, payments)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Rows without a rate to lock were failed before insertion
+	batch.InsertedRows, batch.FailedRows = 0, unpriced
+	for i, row := range valid {
+		if row.Status == RowInserted || row.Status == RowReview {
+			if err := p.risk.Record(ctx, assessments[i]); err != nil {
+				return err
+			}
+			batch.InsertedRows++
+		} else {
+			batch.FailedRows++
+		}
+	}
+	switch {
+	case batch.InsertedRows == 0:
+		batch.Status = BatchFailed
+	case batch.InsertedRows == batch.TotalRows:
+		batch.Status = BatchCompleted
+	default:
+		batch.Status = BatchPartial
+	}
+	executedAt := p.now()
+	batch.ExecutedAt = &executedAt
+	batch.UpdatedAt = executedAt
+	return p.repo.UpdateBatchResult(ctx, batch)
+}
+
+// fail marks a batch whose execution stopped on an error as FAILED, so it does not stay EXECUTING,
+// and returns the error. Rows inserted before the error still count as inserted.
+func (p *BatchProcessor) fail(ctx context.Context, batch *PaymentBatch, err error) error {
+	batch.InsertedRows, batch.FailedRows = 0, 0
+	for _, row := range batch.Rows {
+		switch row.Status {
+		case RowInserted, RowReview:
+			batch.InsertedRows++
+		case RowFailed:
+			batch.FailedRows++
+		}
+	}
+	batch.Status = BatchFailed
+	failedAt := p.now()
+	batch.ExecutedAt = &failedAt
+	batch.UpdatedAt = failedAt
+	if updateErr := p.repo.UpdateBatchResult(ctx, batch); updateErr != nil {
+		log.Printf("[error] - Mark payment batch %s failed: %v", batch.BatchID, updateErr)
+	}
+	return err
+}
+
+// lockRates locks the FX rate of each payment as payment creation does. A row without a rate fails;
+// in atomic mode the other rows are skipped with it. It returns the rows that can still be inserted.
+func (p *BatchProcessor) lockRates(mode BatchMode, rows []*BatchRow, payments []*Payment) ([]*BatchRow, []*Payment, error) {
+	var lockedRows []*BatchRow
+	var locked []*Payment
+	for i, row := range rows {
+		err := lockRate(p.fx, payments[i])
+		if err == nil {
+			lockedRows = append(lockedRows, row)
+			locked = append(locked, payments[i])
+			continue
+		}
+		if !errors.Is(err, fx.ErrRateNotFound) && !errors.Is(err, fx.ErrInvalidCurrency) {
+			return nil, nil, fmt.Errorf("error locking FX rate: %w", err)
+		}
+
+		row.Status = RowFailed
+		row.Errors = []string{err.Error()}
+		if err := p.repo.UpdateRowStatus(row); err != nil {
+			return nil, nil, err
+		}
+		if mode == BatchAtomic {
+			for _, other := range rows {
+				if other == row {
+					continue
+				}
+				other.Status = RowSkipped
+				other.Errors = []string{fmt.Sprintf("rolled back because row %d failed", row.RowNumber)}
+				if err := p.repo.UpdateRowStatus(other); err != nil {
+					return nil, nil, err
+				}
+			}
+			return nil, nil, nil
+		}
+	}
+	return lockedRows, locked, nil
+}
+
+// assess scores the payments of a batch as payments by its uploader and holds the flagged ones for review.
+// Thresholds are in the base currency, so payments are scored on their base amount.
+func (p *BatchProcessor) assess(createdBy string, payments []*Payment) ([]*risk.Assessment, error) {
+	inputs := make([]risk.Input, len(payments))
+	for i, payment := range payments {
+		inputs[i] = risk.Input{
+			PaymentID: payment.PaymentID,
+			Username:  createdBy,
+			PayTo:     payment.PayTo,
+			Amount:    payment.BaseAmount,
+			CreatedAt: payment.CreatedAt,
+		}
+	}
+	assessments, err := p.risk.AssessAll(inputs)
+	if err != nil {
+		return nil, fmt.Errorf("error assessing batch payments: %w", err)
+	}
+	for i, assessment := range assessments {
+		if assessment.Decision == risk.DecisionReview {
+			payments[i].Status = risk.PaymentStatusReview
+		}
+	}
+	return assessments, nil
+}
+
+// insertedStatus is the status of a row once its payment is inserted
+func insertedStatus(payment *Payment) RowStatus {
+	if payment.Status == risk.PaymentStatusReview {
+		return RowReview
+	}
+	return RowInserted
+}
+
+// executeAtomic inserts every valid row in one transaction. When any row fails
+// that row is reported as failed and the others as skipped.
+func (p *BatchProcessor) executeAtomic(tenantID int, rows []*BatchRow, payments []*Payment) error {
+	if len(rows) == 0 {
+		return nil
+	}
+	err := p.repo.InsertRowPayments(tenantID, rows, payments)
+	var rowErr *RowError
+	if err != nil && !errors.As(err, &rowErr) {
+		return err
+	}
+	if err == nil {
+		return nil
+	}
+
+	for _, row := range rows {
+		if row.RowNumber == rowErr.RowNumber {
+			row.Status = RowFailed
+			row.Errors = []string{rowErr.Err.Error()}
+		} else {
+			row.Status = RowSkipped
+			row.Errors = []string{fmt.Sprintf("rolled back because row %d failed", rowErr.RowNumber)}
+		}
+		if err := p.repo.UpdateRowStatus(row); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executePerRow inserts each valid row on its own and records failures on the row
+func (p *BatchProcessor) executePerRow(tenantID int, rows []*BatchRow, payments []*Payment) error {
+	for i, row := range rows {
+		err := p.repo.InsertRowPayment(tenantID, row, payments[i])
+		var rowErr *RowError
+		if err != nil && !errors.As(err, &rowErr) {
+			return err
+		}
+		if err != nil {
+			row.Status = RowFailed
+			row.Errors = []string{rowErr.Err.Error()}
+			if err := p.repo.UpdateRowStatus(row); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// RunDue executes pending batches whose execution time has passed and returns how many ran
+func (p *BatchProcessor) RunDue() (int, error) {
+	batches, err := p.repo.GetDueBatches(p.now())
+	if err != nil {
+		return 0, err
+	}
+
+	executed := 0
+	for _, batch := range batches {
+		// Batches run in the background, so the tenant comes from the batch rather than a request
+		ctx := auth.WithTenantID(context.Background(), batch.TenantID)
+		_, err := p.Execute(ctx, batch.BatchID)
+		if errors.Is(err, ErrBatchState) {
+			// Cancelled or picked up by another worker in the meantime
+			continue
+		}
+		if err != nil {
+			return executed, err
+		}
+		executed++
+	}
+	return executed, nil
+}
+
+// Run executes due batches every interval until the context is cancelled
+func (p *BatchProcessor) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := p.RunDue(); err != nil {
+			log.Printf("[error] - Run payment batches: %v", err)
+		} else if n > 0 {
+			log.Printf("[info] - Executed %d payment batches", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
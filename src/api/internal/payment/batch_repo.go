@@ -0,0 +1,350 @@
+package payment
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// BatchRepo represents the repository for bulk payment batches
+type BatchRepo struct {
+	DB *db.DB
+}
+
+// NewBatchRepo creates a new instance of BatchRepo
+func NewBatchRepo() *BatchRepo {
+	db := db.NewDB()
+	return &BatchRepo{DB: db}
+}
+
+const batchColumns = `
+	batch_id, file_name, format, mode, status, total_rows, valid_rows, invalid_rows,
+	inserted_rows, failed_rows, execute_at, executed_at, created_at, created_by, updated_at, tenant_id`
+
+const batchRowColumns = `
+	batch_id, row_number, payment_id, amount, currency, pay_to, payment_method,
+	payment_date, note, description, status, errors`
+
+// InsertBatch stores a validated batch and its rows in a single transaction
+func (br *BatchRepo) InsertBatch(batch *PaymentBatch, rows []BatchRow) error {
+	tx, err := br.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`
+		INSERT INTO payment_batches (`+batchColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		batch.BatchID,
+		batch.FileName,
+		batch.Format,
+		batch.Mode,
+		batch.Status,
+		batch.TotalRows,
+		batch.ValidRows,
+		batch.InvalidRows,
+		batch.InsertedRows,
+		batch.FailedRows,
+		utcPtr(batch.ExecuteAt),
+		utcPtr(batch.ExecutedAt),
+		batch.CreatedAt,
+		batch.CreatedBy,
+		batch.UpdatedAt,
+		batch.TenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting payment batch: %w", err)
+	}
+
+	for _, row := range rows {
+		errs, err := json.Marshal(row.Errors)
+		if err != nil {
+			return fmt.Errorf("error encoding row errors: %w", err)
+		}
+		_, err = tx.Exec(`
+			INSERT INTO payment_batch_rows (`+batchRowColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			batch.BatchID,
+			row.RowNumber,
+			row.PaymentID,
+			row.Amount,
+			row.Currency,
+			row.PayTo,
+			row.PaymentMethod,
+			row.PaymentDate,
+			row.Note,
+			row.Description,
+			row.Status,
+			string(errs),
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting batch row %d: %w", row.RowNumber, err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// scope returns the batches of the tenant of ctx
+func (br *BatchRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, br.DB.Connection)
+}
+
+// GetBatch retrieves a payment batch of the tenant of ctx by its id, without its rows;
+// batches of other tenants are not found
+func (br *BatchRepo) GetBatch(ctx context.Context, id string) (*PaymentBatch, error) {
+	scoped, err := br.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("payment_batches", batchColumns, "batch_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return scanBatch(row)
+}
+
+// GetBatches retrieves the tenant's payment batches, optionally filtered by status
+func (br *BatchRepo) GetBatches(ctx context.Context, status BatchStatus) ([]PaymentBatch, error) {
+	scoped, err := br.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where := ""
+	var args []interface{}
+	if status != "" {
+		where = "status = ?"
+		args = append(args, status)
+	}
+	rows, err := scoped.Select("payment_batches", batchColumns, where, "created_at DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment batches: %w", err)
+	}
+	return scanBatches(rows)
+}
+
+// GetDueBatches retrieves the pending batches of every tenant whose execution time is at or before now
+func (br *BatchRepo) GetDueBatches(now time.Time) ([]PaymentBatch, error) {
+	return br.queryBatches(
+		"SELECT "+batchColumns+" FROM payment_batches WHERE status = ? AND execute_at <= ? ORDER BY execute_at",
+		BatchPending, now.UTC(),
+	)
+}
+
+// GetBatchRows retrieves the rows of a batch in upload order
+func (br *BatchRepo) GetBatchRows(batchID string) ([]BatchRow, error) {
+	rows, err := br.DB.Query("SELECT "+batchRowColumns+" FROM payment_batch_rows WHERE batch_id = ? ORDER BY row_number", batchID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying batch rows: %w", err)
+	}
+	defer rows.Close()
+
+	var result []BatchRow
+	for rows.Next() {
+		var row BatchRow
+		var errs string
+		err := rows.Scan(
+			&row.BatchID,
+			&row.RowNumber,
+			&row.PaymentID,
+			&row.Amount,
+			&row.Currency,
+			&row.PayTo,
+			&row.PaymentMethod,
+			&row.PaymentDate,
+			&row.Note,
+			&row.Description,
+			&row.Status,
+			&errs,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning batch row: %w", err)
+		}
+		if err := json.Unmarshal([]byte(errs), &row.Errors); err != nil {
+			return nil, fmt.Errorf("error decoding row errors: %w", err)
+		}
+		result = append(result, row)
+	}
+	return result, rows.Err()
+}
+
+// TransitionBatch moves a batch from one status to another and reports whether it was in the expected status.
+// Execution and cancellation both go through it, so only one of them can win.
+func (br *BatchRepo) TransitionBatch(ctx context.Context, id string, from, to BatchStatus, now time.Time) (bool, error) {
+	scoped, err := br.scope(ctx)
+	if err != nil {
+		return false, err
+	}
+	result, err := scoped.Update("payment_batches",
+		"status = ?, updated_at = ?",
+		"batch_id = ? AND status = ?",
+		to, now, id, from,
+	)
+	if err != nil {
+		return false, fmt.Errorf("error updating payment batch: %w", err)
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// UpdateBatchResult saves the outcome of executing a batch
+func (br *BatchRepo) UpdateBatchResult(ctx context.Context, batch *PaymentBatch) error {
+	scoped, err := br.scope(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = scoped.Update("payment_batches",
+		"status = ?, inserted_rows = ?, failed_rows = ?, executed_at = ?, updated_at = ?",
+		"batch_id = ?",
+		batch.Status,
+		batch.InsertedRows,
+		batch.FailedRows,
+		utcPtr(batch.ExecutedAt),
+		batch.UpdatedAt,
+		batch.BatchID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating payment batch: %w", err)
+	}
+	return nil
+}
+
+// UpdateRowStatus saves the outcome of one row
+func (br *BatchRepo) UpdateRowStatus(row *BatchRow) error {
+	return updateRowStatus(br.DB, row)
+}
+
+// InsertRowPayment inserts the payment for one row and marks the row inserted in a single transaction
+func (br *BatchRepo) InsertRowPayment(tenantID int, row *BatchRow, payment *Payment) error {
+	return br.InsertRowPayments(tenantID, []*BatchRow{row}, []*Payment{payment})
+}
+
+// InsertRowPayments inserts the payments for several rows into a tenant in a single transaction.
+// On failure nothing is written and the error names the row that failed.
+func (br *BatchRepo) InsertRowPayments(tenantID int, rows []*BatchRow, payments []*Payment) error {
+	tx, err := br.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	// Batches run in the background, so the tenant comes from the batch rather than a request
+	scoped, err := tenant.Scope(auth.WithTenantID(context.Background(), tenantID), tx)
+	if err != nil {
+		return err
+	}
+	for i, row := range rows {
+		if _, err := scoped.Insert("payments", insertPaymentColumns, payments[i].insertArgs()...); err != nil {
+			return &RowError{RowNumber: row.RowNumber, Err: err}
+		}
+		inserted := *row
+		inserted.Status = insertedStatus(payments[i])
+		inserted.Errors = nil
+		if err := updateRowStatus(tx, &inserted); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing batch payments: %w", err)
+	}
+	for i, row := range rows {
+		row.Status = insertedStatus(payments[i])
+		row.Errors = nil
+	}
+	return nil
+}
+
+// RowError reports the batch row whose payment could not be inserted
+type RowError struct {
+	RowNumber int
+	Err       error
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %v", e.RowNumber, e.Err)
+}
+
+func (e *RowError) Unwrap() error {
+	return e.Err
+}
+
+// execer is implemented by both *db.DB and *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+func updateRowStatus(exec execer, row *BatchRow) error {
+	errs, err := json.Marshal(row.Errors)
+	if err != nil {
+		return fmt.Errorf("error encoding row errors: %w", err)
+	}
+	_, err = exec.Exec(
+		"UPDATE payment_batch_rows SET status = ?, errors = ? WHERE batch_id = ? AND row_number = ?",
+		row.Status, string(errs), row.BatchID, row.RowNumber,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating batch row %d: %w", row.RowNumber, err)
+	}
+	return nil
+}
+
+func (br *BatchRepo) queryBatches(query string, args ...interface{}) ([]PaymentBatch, error) {
+	rows, err := br.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment batches: %w", err)
+	}
+	return scanBatches(rows)
+}
+
+func scanBatches(rows *sql.Rows) ([]PaymentBatch, error) {
+	defer rows.Close()
+
+	var batches []PaymentBatch
+	for rows.Next() {
+		batch, err := scanBatch(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment batch: %w", err)
+		}
+		batches = append(batches, *batch)
+	}
+	return batches, rows.Err()
+}
+
+func scanBatch(row rowScanner) (*PaymentBatch, error) {
+	var batch PaymentBatch
+	var executeAt, executedAt sql.NullTime
+	err := row.Scan(
+		&batch.BatchID,
+		&batch.FileName,
+		&batch.Format,
+		&batch.Mode,
+		&batch.Status,
+		&batch.TotalRows,
+		&batch.ValidRows,
+		&batch.InvalidRows,
+		&batch.InsertedRows,
+		&batch.FailedRows,
+		&executeAt,
+		&executedAt,
+		&batch.CreatedAt,
+		&batch.CreatedBy,
+		&batch.UpdatedAt,
+		&batch.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	batch.ExecuteAt = timePtr(executeAt)
+	batch.ExecutedAt = timePtr(executedAt)
+	return &batch, nil
+}
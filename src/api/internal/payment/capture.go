@@ -0,0 +1,51 @@
+package payment
+
+import (
+	"api/internal/ledger"
+	"errors"
+	"strings"
+)
+
+// ErrNoLockedRate is returned when capturing a payment whose FX rate was never locked
+var ErrNoLockedRate = errors.New("payment has no locked FX rate")
+
+// Payment status values that mean the funds have been captured
+const (
+	StatusCompleted = "completed"
+	StatusCaptured  = "captured"
+)
+
+// IsCaptured reports whether the payment status means the funds have been captured
+func (p *Payment) IsCaptured() bool {
+	status := strings.ToLower(p.Status)
+	return status == StatusCompleted || status == StatusCaptured
+}
+
+// RecordCapture posts a captured payment to the ledger: Dr cash, Cr payments clearing.
+// The ledger is kept in the base currency, so the payment is posted at its locked base amount.
+// It is idempotent, so re-saving a completed payment does not post it twice.
+func RecordCapture(l *ledger.Ledger, p *Payment, createdBy string) error {
+	if !p.IsCaptured() {
+		return nil
+	}
+
+	if p.BaseCurrency == "" {
+		return ErrNoLockedRate
+	}
+
+	posted, err := l.HasEntry(ledger.RefPaymentCapture, p.PaymentID)
+	if err != nil || posted {
+		return err
+	}
+
+	_, err = l.Transfer(
+		ledger.AccountPaymentsClearing,
+		ledger.AccountCash,
+		ledger.ToMinor(p.BaseAmount),
+		ledger.RefPaymentCapture,
+		p.PaymentID,
+		"Payment captured for "+p.PayTo,
+		createdBy,
+	)
+	return err
+}
@@ -1,21 +1,38 @@
 package payment
 
 import (
+	"api/internal/auth"
 	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/ledger"
+	"api/internal/risk"
+	"api/internal/router"
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 type PaymentHandler struct {
-	repo *PaymentRepo
+	repo   *PaymentRepo
+	ledger *ledger.Ledger
+	risk   *risk.Service
+	fx     *fx.Converter
 }
 
 func NewPaymentHandler() *PaymentHandler {
 	return &PaymentHandler{
-		repo: NewPaymentRepo(),
+		repo:   NewPaymentRepo(),
+		ledger: ledger.NewLedger(),
+		risk:   risk.NewService(),
+		fx:     fx.NewConverter(),
 	}
 }
 
@@ -43,15 +60,63 @@ func (h *PaymentHandler) CreatePaymentHandler(w http.ResponseWriter, r *http.Req
 
 	payment.CreatedAt = time.Now()
 	payment.UpdatedAt = time.Now()
-	id, err := h.repo.InsertPayment(&payment)
+	if payment.PaymentID == "" {
+		payment.PaymentID = uuid.New().String()
+	}
+
+	if err := lockRate(h.fx, &payment); err != nil {
+		writeFXError(w, r, err)
+		return
+	}
+
+	// Risk thresholds are in the base currency
+	assessment, err := h.risk.Assess(risk.Input{
+		PaymentID: payment.PaymentID,
+		Username:  auth.Username(r),
+		PayTo:     payment.PayTo,
+		Amount:    payment.BaseAmount,
+		CreatedAt: payment.CreatedAt,
+	})
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to assess payment risk", GetRequestID(r))
+		return
+	}
+	// Held payments are not captured until the review queue approves them
+	if assessment.Decision == risk.DecisionReview {
+		payment.Status = risk.PaymentStatusReview
+	}
+
+	id, err := h.repo.InsertPayment(r.Context(), &payment)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to create payment", GetRequestID(r))
 		return
 	}
 
+	if err := h.risk.Record(r.Context(), assessment); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to record risk assessment", GetRequestID(r))
+		return
+	}
+
+	if err := RecordCapture(h.ledger, &payment, "system"); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to record payment in ledger", GetRequestID(r))
+		return
+	}
+
+	message := "Payment created successfully"
+	if assessment.Decision == risk.DecisionReview {
+		message = "Payment created and held for review"
+	}
 	writeSuccess(w, http.StatusCreated, map[string]interface{}{
-		"id": id,
-	}, "Payment created successfully", GetRequestID(r))
+		"id":              id,
+		"status":          payment.Status,
+		"currency":        payment.Currency,
+		"base_currency":   payment.BaseCurrency,
+		"base_amount":     payment.BaseAmount,
+		"fx_rate":         payment.FXRate,
+		"fx_rate_date":    payment.FXRateDate,
+		"risk_score":      assessment.Score,
+		"triggered_rules": assessment.TriggeredRules,
+	}, message, GetRequestID(r))
 }
 
 // UpdatePaymentHandler handles payment updates
@@ -61,28 +126,107 @@ func (h *PaymentHandler) UpdatePaymentHandler(w http.ResponseWriter, r *http.Req
 		writeError(w, http.StatusBadRequest, "Invalid request payload", GetRequestID(r))
 		return
 	}
+	if id := router.Param(r, "id"); id != "" {
+		payment.PaymentID = id
+	}
+
+	// Only the review queue moves payments into or out of review
+	if isReviewStatus(payment.Status) {
+		writeError(w, http.StatusBadRequest, "Payment status is set by risk review", GetRequestID(r))
+		return
+	}
 
+	existing, err := h.repo.GetPaymentByID(r.Context(), payment.PaymentID)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Payment not found", GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to fetch payment", GetRequestID(r))
+		return
+	}
+	if isReviewStatus(existing.Status) {
+		writeError(w, http.StatusConflict, "Payment is held or rejected by risk review", GetRequestID(r))
+		return
+	}
+
+	// The payment may have been looked up by its id alias
+	payment.PaymentID = existing.PaymentID
 	payment.UpdatedAt = time.Now()
-	_, err := h.repo.UpdatePayment(&payment)
-	if err != nil {
+	if err := relockRate(h.fx, &payment, existing); err != nil {
+		writeFXError(w, r, err)
+		return
+	}
+
+	// A payment is scored again when what is paid or to whom changes, approved or not
+	var assessment *risk.Assessment
+	if existing.Amount != payment.Amount ||
+		!equalCurrency(existing.Currency, payment.Currency) || existing.PayTo != payment.PayTo {
+		assessment, err = h.risk.Reassess(risk.Input{
+			PaymentID: payment.PaymentID,
+			Username:  auth.Username(r),
+			PayTo:     payment.PayTo,
+			Amount:    payment.BaseAmount,
+			CreatedAt: payment.UpdatedAt,
+		})
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to assess payment risk", GetRequestID(r))
+			return
+		}
+		if assessment.Decision == risk.DecisionReview {
+			payment.Status = risk.PaymentStatusReview
+		}
+	}
+
+	// Only a payment that was actually updated is captured
+	_, err = h.repo.UpdatePayment(r.Context(), &payment)
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Payment not found", GetRequestID(r))
+		return
+	case err != nil:
 		writeError(w, http.StatusInternalServerError, "Failed to update payment", GetRequestID(r))
 		return
 	}
 
+	if assessment != nil {
+		if err := h.risk.Replace(r.Context(), assessment); err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to record risk assessment", GetRequestID(r))
+			return
+		}
+	}
+
+	if err := RecordCapture(h.ledger, &payment, "system"); err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to record payment in ledger", GetRequestID(r))
+		return
+	}
+
+	message := "Payment updated successfully"
+	if payment.Status == risk.PaymentStatusReview {
+		message = "Payment updated and held for review"
+	}
 	writeSuccess(w, http.StatusOK, map[string]string{
-		"message": "Payment updated successfully",
-	}, "Payment updated successfully", GetRequestID(r))
+		"message": message,
+	}, message, GetRequestID(r))
+}
+
+// isReviewStatus reports whether a payment status is owned by the risk review queue
+func isReviewStatus(status string) bool {
+	return strings.EqualFold(status, risk.PaymentStatusReview) || strings.EqualFold(status, risk.PaymentStatusRejected)
 }
 
 // DeletePaymentHandler handles payment deletion
 func (h *PaymentHandler) DeletePaymentHandler(w http.ResponseWriter, r *http.Request) {
-	id := r.URL.Query().Get("id")
+	id := router.Param(r, "id")
+	if id == "" {
+		id = r.URL.Query().Get("id")
+	}
 	if id == "" {
 		writeError(w, http.StatusBadRequest, "Payment ID is required", GetRequestID(r))
 		return
 	}
 
-	_, err := h.repo.DeletePayment(id)
+	_, err := h.repo.DeletePayment(r.Context(), id)
 	if err != nil {
 		writeError(w, http.StatusInternalServerError, "Failed to delete payment", GetRequestID(r))
 		return
@@ -93,6 +237,21 @@ func (h *PaymentHandler) DeletePaymentHandler(w http.ResponseWriter, r *http.Req
 	}, "Payment deleted successfully", GetRequestID(r))
 }
 
+// GetPaymentHandler handles retrieval of a single payment by payment ID or ID
+func (h *PaymentHandler) GetPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	payment, err := h.repo.GetPaymentByID(r.Context(), router.Param(r, "id"))
+	switch {
+	case errors.Is(err, sql.ErrNoRows):
+		writeError(w, http.StatusNotFound, "Payment not found", GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to fetch payment", GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, payment, "", GetRequestID(r))
+}
+
 // GetPaymentsHandler handles paginated payment retrieval
 func (h *PaymentHandler) GetPaymentsHandler(w http.ResponseWriter, r *http.Request) {
 	paginationType := r.URL.Query().Get("pagination_type")
@@ -128,8 +287,17 @@ func (h *PaymentHandler) GetPaymentsHandler(w http.ResponseWriter, r *http.Reque
 	params.KeyID = "payment_id"
 	params.SortFields = []string{"payment_id"}
 
-	// Handle search if present
+	// Handle search and currency filters if present
 	search := r.URL.Query().Get("search")
+	filter := PaymentFilter{}
+	if currency := r.URL.Query().Get("currency"); currency != "" {
+		normalized, err := fx.NormalizeCurrency(currency)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), GetRequestID(r))
+			return
+		}
+		filter.Currency = normalized
+	}
 
 	fmt.Printf("search:%v", search)
 	var result *db.PaginationResponse
@@ -138,14 +306,16 @@ func (h *PaymentHandler) GetPaymentsHandler(w http.ResponseWriter, r *http.Reque
 	if search != "" {
 		switch paginationType {
 		case "cursor":
-			result, err = h.repo.SearchPaymentWithCursorPagination(search, params)
+			filter.Search = search
+			result, err = h.repo.SearchPaymentWithCursorPagination(r.Context(), search, filter.Currency, params)
 		case "offset":
-			result, err = h.repo.SearchPaymentWithOffsetPagination(search, params)
+			filter.Search = search
+			result, err = h.repo.SearchPaymentWithOffsetPagination(r.Context(), search, filter.Currency, params)
 		default:
-			result, err = h.repo.GetPayments(params)
+			result, err = h.repo.GetPayments(r.Context(), params, filter.Currency)
 		}
 	} else {
-		result, err = h.repo.GetPayments(params)
+		result, err = h.repo.GetPayments(r.Context(), params, filter.Currency)
 	}
 
 	if err != nil {
@@ -153,7 +323,92 @@ func (h *PaymentHandler) GetPaymentsHandler(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	writeJSON(w, http.StatusOK, result)
+	totals, err := h.totals(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to total payments", GetRequestID(r))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, PaymentList{PaginationResponse: result, Totals: totals})
+}
+
+// GetPaymentTotalsHandler godoc
+// @Summary Payment totals in the base currency
+// @Description Totals per currency and in the base currency. Payments use the base amount locked at creation; older payments are converted at the rate on their payment date.
+// @Tags payments
+// @Produce json
+// @Param currency query string false "Currency"
+// @Param status query string false "Payment status"
+// @Param from query string false "Payment date from (YYYY-MM-DD)"
+// @Param to query string false "Payment date to (YYYY-MM-DD)"
+// @Param search query string false "Search term"
+// @Success 200 {object} Totals
+// @Failure 400 {object} types.ErrorResponse
+// @Router /payments/totals [get]
+func (h *PaymentHandler) GetPaymentTotalsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	filter := PaymentFilter{
+		Search: query.Get("search"),
+		Status: query.Get("status"),
+		From:   query.Get("from"),
+		To:     query.Get("to"),
+	}
+	if currency := query.Get("currency"); currency != "" {
+		normalized, err := fx.NormalizeCurrency(currency)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), GetRequestID(r))
+			return
+		}
+		filter.Currency = normalized
+	}
+	for _, date := range []string{filter.From, filter.To} {
+		if _, err := time.Parse("2006-01-02", date); date != "" && err != nil {
+			writeError(w, http.StatusBadRequest, "Dates must be YYYY-MM-DD", GetRequestID(r))
+			return
+		}
+	}
+
+	totals, err := h.totals(r.Context(), filter)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to total payments", GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, totals, "", GetRequestID(r))
+}
+
+func (h *PaymentHandler) totals(ctx context.Context, filter PaymentFilter) (*Totals, error) {
+	groups, err := h.repo.GetTotalGroups(ctx, filter)
+	if err != nil {
+		return nil, err
+	}
+	return BuildTotals(groups, h.fx, time.Now())
+}
+
+// RegisterRoutes registers the payment routes with the given router.
+// Update and delete also accept the payment ID in the body or the id query parameter.
+func (h *PaymentHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/payments", h.GetPaymentsHandler)
+	r.Post("/payments", h.CreatePaymentHandler)
+	r.Put("/payments", h.UpdatePaymentHandler)
+	r.Delete("/payments", h.DeletePaymentHandler)
+	r.Get("/payments/search", h.GetPaymentsHandler)
+	r.Get("/payments/totals", h.GetPaymentTotalsHandler)
+	r.Get("/payments/{id}", h.GetPaymentHandler)
+	r.Put("/payments/{id}", h.UpdatePaymentHandler)
+	r.Delete("/payments/{id}", h.DeletePaymentHandler)
+}
+
+// writeFXError reports a payment whose currency cannot be converted to the base currency
+func writeFXError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, fx.ErrInvalidCurrency):
+		writeError(w, http.StatusBadRequest, err.Error(), GetRequestID(r))
+	case errors.Is(err, fx.ErrRateNotFound):
+		writeError(w, http.StatusUnprocessableEntity, "No FX rate to lock: "+err.Error(), GetRequestID(r))
+	default:
+		writeError(w, http.StatusInternalServerError, "Failed to lock FX rate", GetRequestID(r))
+	}
 }
 
 // Add these package-level handler functions
@@ -175,6 +430,10 @@ func GetPaymentsHandler(w http.ResponseWriter, r *http.Request) {
 	paymentHandler.GetPaymentsHandler(w, r)
 }
 
+func GetPaymentTotalsHandler(w http.ResponseWriter, r *http.Request) {
+	paymentHandler.GetPaymentTotalsHandler(w, r)
+}
+
 func SearchPaymentsHandler(w http.ResponseWriter, r *http.Request) {
 	// Reuse GetPaymentsHandler since it already has search functionality
 	GetPaymentsHandler(w, r)
@@ -2,7 +2,11 @@ package payment
 
 import (
 	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	_ "github.com/mattn/go-sqlite3"
@@ -19,14 +23,22 @@ func NewPaymentRepo() *PaymentRepo {
 	return &PaymentRepo{DB: db}
 }
 
-// GetPayments fetches payments with pagination support
-func (pr *PaymentRepo) GetPayments(params db.PaginationParams) (*db.PaginationResponse, error) {
-	baseQuery := "SELECT * FROM payments"
-	countQuery := "SELECT COUNT(*) FROM payments"
+// scope returns the payments of the tenant of ctx
+func (pr *PaymentRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, pr.DB.Connection)
+}
+
+// GetPayments fetches the tenant's payments with pagination support, optionally in one currency
+func (pr *PaymentRepo) GetPayments(ctx context.Context, params db.PaginationParams, currency string) (*db.PaginationResponse, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, filterArgs := PaymentFilter{Currency: currency}.where()
 
 	// Get total count
 	var total int64
-	row, err := pr.DB.QueryRow(countQuery)
+	row, err := scoped.SelectRow("payments", "COUNT(*)", where, filterArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error counting payments: %w", err)
 	}
@@ -42,7 +54,7 @@ func (pr *PaymentRepo) GetPayments(params db.PaginationParams) (*db.PaginationRe
 	}
 
 	// Execute paginated query
-	rows, err := pr.DB.Query(baseQuery+paginationQuery, args...)
+	rows, err := scoped.SelectClauses("payments", paymentColumns, where, paginationQuery, args, filterArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying payments: %w", err)
 	}
@@ -52,22 +64,11 @@ func (pr *PaymentRepo) GetPayments(params db.PaginationParams) (*db.PaginationRe
 	var lastID string
 
 	for rows.Next() {
-		var payment Payment
-		err := rows.Scan(
-			&payment.PaymentID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.PaymentDate,
-			&payment.PayTo,
-			&payment.Note,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		)
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning payment: %w", err)
 		}
-		payments = append(payments, &payment)
+		payments = append(payments, payment)
 		lastID = fmt.Sprintf("%v", payment.PaymentID)
 	}
 
@@ -103,24 +104,31 @@ func (pr *PaymentRepo) GetPayments(params db.PaginationParams) (*db.PaginationRe
 	return response, nil
 }
 
-// Get PaymentByID retrieves a payment by its ID from the database
-func (pr *PaymentRepo) GetPaymentByID(id string) (*Payment, error) {
-	var payment Payment
-	row, err := pr.DB.QueryRow(`
-		SELECT 
-			payment_id, id, amount, payment_method, payment_date,
-			pay_to, note, status, description, currency,
-			created_at, updated_at 
-		FROM payments 
-		WHERE payment_id = ? OR id = ?`,
-		id, id,
-	)
-
+// Get PaymentByID retrieves a payment of the tenant by its ID from the database;
+// payments of other tenants are not found
+func (pr *PaymentRepo) GetPaymentByID(ctx context.Context, id string) (*Payment, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("payments", paymentColumns, "payment_id = ? OR id = ?", id, id)
 	if err != nil {
 		return nil, err
 	}
 
-	err = row.Scan(
+	return scanPayment(row)
+}
+
+// paymentColumns lists the payment columns in the order scanPayment reads them
+const paymentColumns = `
+	payment_id, COALESCE(id, ''), amount, payment_method, COALESCE(payment_date, ''),
+	COALESCE(pay_to, ''), COALESCE(note, ''), COALESCE(status, ''), COALESCE(description, ''),
+	COALESCE(currency, ''), COALESCE(base_currency, ''), COALESCE(base_amount, 0),
+	COALESCE(fx_rate, 0), COALESCE(fx_rate_date, ''), created_at, updated_at`
+
+func scanPayment(row rowScanner) (*Payment, error) {
+	var payment Payment
+	err := row.Scan(
 		&payment.PaymentID,
 		&payment.ID,
 		&payment.Amount,
@@ -131,31 +139,32 @@ func (pr *PaymentRepo) GetPaymentByID(id string) (*Payment, error) {
 		&payment.Status,
 		&payment.Description,
 		&payment.Currency,
+		&payment.BaseCurrency,
+		&payment.BaseAmount,
+		&payment.FXRate,
+		&payment.FXRateDate,
 		&payment.CreatedAt,
 		&payment.UpdatedAt,
 	)
 	if err != nil {
 		return nil, err
 	}
-
 	return &payment, nil
 }
 
-// Insert Payment inserts a new payment into the database
-func (pr *PaymentRepo) InsertPayment(payment *Payment) (string, error) {
-	if payment.PaymentID == "" {
-		payment.PaymentID = uuid.New().String()
-	}
-	if payment.ID == "" {
-		payment.ID = payment.PaymentID
-	}
+// insertPaymentColumns are the payment columns written on insert; the scope adds tenant_id
+var insertPaymentColumns = []string{
+	"payment_id", "id", "amount", "payment_method", "payment_date",
+	"pay_to", "note", "status", "description", "currency",
+	"base_currency", "base_amount", "fx_rate", "fx_rate_date",
+	"created_at", "updated_at",
+}
 
-	_, err := pr.DB.Insert(`
-		INSERT INTO payments (
-			payment_id, id, amount, payment_method, payment_date, 
-			pay_to, note, status, description, currency, 
-			created_at, updated_at
-		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+// insertArgs returns the values of insertPaymentColumns.
+// Payments without a locked rate store NULL base values.
+func (payment *Payment) insertArgs() []interface{} {
+	baseCurrency, baseAmount, fxRate, fxRateDate := payment.lockArgs()
+	return []interface{}{
 		payment.PaymentID,
 		payment.ID,
 		payment.Amount,
@@ -166,9 +175,37 @@ func (pr *PaymentRepo) InsertPayment(payment *Payment) (string, error) {
 		payment.Status,
 		payment.Description,
 		payment.Currency,
+		baseCurrency,
+		baseAmount,
+		fxRate,
+		fxRateDate,
 		payment.CreatedAt,
 		payment.UpdatedAt,
-	)
+	}
+}
+
+// lockArgs returns the locked base currency values, or NULLs when no rate is locked
+func (payment *Payment) lockArgs() (interface{}, interface{}, interface{}, interface{}) {
+	if payment.BaseCurrency == "" {
+		return nil, nil, nil, nil
+	}
+	return payment.BaseCurrency, payment.BaseAmount, payment.FXRate, payment.FXRateDate
+}
+
+// Insert Payment inserts a new payment of the tenant into the database
+func (pr *PaymentRepo) InsertPayment(ctx context.Context, payment *Payment) (string, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return "", err
+	}
+	if payment.PaymentID == "" {
+		payment.PaymentID = uuid.New().String()
+	}
+	if payment.ID == "" {
+		payment.ID = payment.PaymentID
+	}
+
+	_, err = scoped.Insert("payments", insertPaymentColumns, payment.insertArgs()...)
 	if err != nil {
 		fmt.Printf("Error inserting payment: %v\n", err)
 		return "", err
@@ -176,14 +213,20 @@ func (pr *PaymentRepo) InsertPayment(payment *Payment) (string, error) {
 	return payment.PaymentID, nil
 }
 
-// Update Payment updates an existing payment in the database
-func (pr *PaymentRepo) UpdatePayment(payment *Payment) (string, error) {
-	_, err := pr.DB.Update(`
-		UPDATE payments SET 
-			amount=?, payment_method=?, payment_date=?, 
-			pay_to=?, note=?, status=?, description=?, 
-			currency=?, updated_at=? 
-		WHERE payment_id=?`,
+// Update Payment updates an existing payment of the tenant in the database;
+// sql.ErrNoRows when the tenant has no such payment
+func (pr *PaymentRepo) UpdatePayment(ctx context.Context, payment *Payment) (string, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return "", err
+	}
+	baseCurrency, baseAmount, fxRate, fxRateDate := payment.lockArgs()
+	result, err := scoped.Update("payments", `
+			amount=?, payment_method=?, payment_date=?,
+			pay_to=?, note=?, status=?, description=?,
+			currency=?, base_currency=?, base_amount=?,
+			fx_rate=?, fx_rate_date=?, updated_at=?`,
+		"payment_id=?",
 		payment.Amount,
 		payment.PaymentMethod,
 		payment.PaymentDate,
@@ -192,39 +235,50 @@ func (pr *PaymentRepo) UpdatePayment(payment *Payment) (string, error) {
 		payment.Status,
 		payment.Description,
 		payment.Currency,
+		baseCurrency,
+		baseAmount,
+		fxRate,
+		fxRateDate,
 		payment.UpdatedAt,
 		payment.PaymentID,
 	)
 	if err != nil {
 		return "", err
 	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return "", err
+	}
+	if affected == 0 {
+		return "", sql.ErrNoRows
+	}
 	return payment.PaymentID, nil
 }
 
-// Delete Payment deletes a payment from the database
-func (pr *PaymentRepo) DeletePayment(id string) (string, error) {
-	_, err := pr.DB.Delete("DELETE FROM payments WHERE payment_id=?", id)
+// Delete Payment deletes a payment of the tenant from the database
+func (pr *PaymentRepo) DeletePayment(ctx context.Context, id string) (string, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return "", err
+	}
+	_, err = scoped.Delete("payments", "payment_id=?", id)
 	if err != nil {
 		return "", err
 	}
 	return id, nil
 }
 
-// SearchPaymentWithCursorPagination searches payments with cursor pagination
-func (pr *PaymentRepo) SearchPaymentWithCursorPagination(search string, params db.PaginationParams) (*db.PaginationResponse, error) {
-	baseQuery := `SELECT * FROM payments 
-		WHERE payment_method LIKE ? OR pay_to LIKE ? OR note LIKE ?`
-	countQuery := `SELECT COUNT(*) FROM payments 
-		WHERE payment_method LIKE ? OR pay_to LIKE ? OR note LIKE ?`
-
-	searchTerm := "%" + search + "%"
-	searchArgs := []interface{}{searchTerm, searchTerm, searchTerm}
+// SearchPaymentWithCursorPagination searches the tenant's payments with cursor pagination, optionally in one currency
+func (pr *PaymentRepo) SearchPaymentWithCursorPagination(ctx context.Context, search, currency string, params db.PaginationParams) (*db.PaginationResponse, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, searchArgs := PaymentFilter{Search: search, Currency: currency}.where()
 
 	// Get total count
 	var total int64
-	row, err := pr.DB.QueryRow(countQuery, searchArgs...)
-	fmt.Printf("countQuery:%v, searchArgs:%v, err:%v", countQuery, searchArgs, err)
-
+	row, err := scoped.SelectRow("payments", "COUNT(*)", where, searchArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error counting payments: %w", err)
 	}
@@ -235,17 +289,12 @@ func (pr *PaymentRepo) SearchPaymentWithCursorPagination(search string, params d
 
 	// Build pagination query
 	paginationQuery, paginationArgs, err := db.BuildPaginationQuery(params)
-	fmt.Printf("paginationQuery:%v, paginationArgs:%v, err:%v", paginationQuery, paginationArgs, err)
 	if err != nil {
 		return nil, fmt.Errorf("error building pagination: %w", err)
 	}
 
-	// Combine search and pagination args
-	args := append(searchArgs, paginationArgs...)
-
 	// Execute search with pagination
-	rows, err := pr.DB.Query(baseQuery+paginationQuery, args...)
-	fmt.Printf("baseQuery:%v, paginationQuery:%v, args:%v, err:%v", baseQuery, paginationQuery, args, err)
+	rows, err := scoped.SelectClauses("payments", paymentColumns, where, paginationQuery, paginationArgs, searchArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying payments: %w", err)
 	}
@@ -255,22 +304,11 @@ func (pr *PaymentRepo) SearchPaymentWithCursorPagination(search string, params d
 	var lastID string
 
 	for rows.Next() {
-		var payment Payment
-		err := rows.Scan(
-			&payment.PaymentID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.PaymentDate,
-			&payment.PayTo,
-			&payment.Note,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		)
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning payment: %w", err)
 		}
-		payments = append(payments, &payment)
+		payments = append(payments, payment)
 		lastID = fmt.Sprintf("%v", payment.PaymentID)
 	}
 
@@ -285,19 +323,17 @@ func (pr *PaymentRepo) SearchPaymentWithCursorPagination(search string, params d
 	return response, nil
 }
 
-// SearchPaymentWithOffsetPagination searches payments with offset pagination
-func (pr *PaymentRepo) SearchPaymentWithOffsetPagination(search string, params db.PaginationParams) (*db.PaginationResponse, error) {
-	baseQuery := `SELECT * FROM payments 
-		WHERE payment_method LIKE ? OR pay_to LIKE ? OR note LIKE ?`
-	countQuery := `SELECT COUNT(*) FROM payments 
-		WHERE payment_method LIKE ? OR pay_to LIKE ? OR note LIKE ?`
-
-	searchTerm := "%" + search + "%"
-	searchArgs := []interface{}{searchTerm, searchTerm, searchTerm}
+// SearchPaymentWithOffsetPagination searches the tenant's payments with offset pagination, optionally in one currency
+func (pr *PaymentRepo) SearchPaymentWithOffsetPagination(ctx context.Context, search, currency string, params db.PaginationParams) (*db.PaginationResponse, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, searchArgs := PaymentFilter{Search: search, Currency: currency}.where()
 
 	// Get total count
 	var total int64
-	row, err := pr.DB.QueryRow(countQuery, searchArgs...)
+	row, err := scoped.SelectRow("payments", "COUNT(*)", where, searchArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error counting payments: %w", err)
 	}
@@ -312,11 +348,8 @@ func (pr *PaymentRepo) SearchPaymentWithOffsetPagination(search string, params d
 		return nil, fmt.Errorf("error building pagination: %w", err)
 	}
 
-	// Combine search and pagination args
-	args := append(searchArgs, paginationArgs...)
-
 	// Execute search with pagination
-	rows, err := pr.DB.Query(baseQuery+paginationQuery, args...)
+	rows, err := scoped.SelectClauses("payments", paymentColumns, where, paginationQuery, paginationArgs, searchArgs...)
 	if err != nil {
 		return nil, fmt.Errorf("error querying payments: %w", err)
 	}
@@ -324,22 +357,11 @@ func (pr *PaymentRepo) SearchPaymentWithOffsetPagination(search string, params d
 
 	var payments []*Payment
 	for rows.Next() {
-		var payment Payment
-		err := rows.Scan(
-			&payment.PaymentID,
-			&payment.Amount,
-			&payment.PaymentMethod,
-			&payment.PaymentDate,
-			&payment.PayTo,
-			&payment.Note,
-			&payment.Status,
-			&payment.CreatedAt,
-			&payment.UpdatedAt,
-		)
+		payment, err := scanPayment(rows)
 		if err != nil {
 			return nil, fmt.Errorf("error scanning payment: %w", err)
 		}
-		payments = append(payments, &payment)
+		payments = append(payments, payment)
 	}
 
 	hasMore := params.Offset+len(payments) < int(total)
@@ -351,3 +373,81 @@ func (pr *PaymentRepo) SearchPaymentWithOffsetPagination(search string, params d
 
 	return response, nil
 }
+
+// PaymentFilter narrows payment listings and totals; empty fields match every payment
+type PaymentFilter struct {
+	Search   string `json:"search,omitempty"`
+	Currency string `json:"currency,omitempty" example:"EUR"`
+	Status   string `json:"status,omitempty" example:"completed"`
+	From     string `json:"from,omitempty" example:"2025-01-01"`
+	To       string `json:"to,omitempty" example:"2025-01-31"`
+}
+
+// where builds the condition and arguments for the filter; the scope adds the tenant
+func (f PaymentFilter) where() (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Search != "" {
+		searchTerm := "%" + f.Search + "%"
+		conditions = append(conditions, "(payment_method LIKE ? OR pay_to LIKE ? OR note LIKE ?)")
+		args = append(args, searchTerm, searchTerm, searchTerm)
+	}
+	if f.Currency != "" {
+		conditions = append(conditions, "UPPER(currency) = ?")
+		args = append(args, strings.ToUpper(f.Currency))
+	}
+	if f.Status != "" {
+		conditions = append(conditions, "LOWER(status) = ?")
+		args = append(args, strings.ToLower(f.Status))
+	}
+	if f.From != "" {
+		conditions = append(conditions, "payment_date >= ?")
+		args = append(args, f.From)
+	}
+	if f.To != "" {
+		conditions = append(conditions, "payment_date <= ?")
+		args = append(args, f.To)
+	}
+	return strings.Join(conditions, " AND "), args
+}
+
+// GetTotalGroups sums the tenant's filtered payments per currency, payment date and whether a rate is locked
+func (pr *PaymentRepo) GetTotalGroups(ctx context.Context, filter PaymentFilter) ([]TotalGroup, error) {
+	scoped, err := pr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where, args := filter.where()
+	rows, err := scoped.SelectClauses("payments", `
+			UPPER(COALESCE(currency, '')), COALESCE(payment_date, ''),
+			base_amount IS NOT NULL, COUNT(*), COALESCE(SUM(amount), 0),
+			COALESCE(SUM(base_amount), 0)`,
+		where, "GROUP BY 1, 2, 3 ORDER BY 1, 2, 3", nil, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment totals: %w", err)
+	}
+	defer rows.Close()
+
+	var groups []TotalGroup
+	for rows.Next() {
+		var group TotalGroup
+		var locked sql.NullBool
+		err := rows.Scan(
+			&group.Currency,
+			&group.PaymentDate,
+			&locked,
+			&group.Count,
+			&group.Amount,
+			&group.BaseAmount,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment totals: %w", err)
+		}
+		group.Locked = locked.Bool
+		groups = append(groups, group)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating payment totals: %w", err)
+	}
+	return groups, nil
+}
@@ -0,0 +1,236 @@
+package payment
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Frequency is the base period of a recurrence rule
+type Frequency string
+
+const (
+	FrequencyDaily   Frequency = "DAILY"
+	FrequencyWeekly  Frequency = "WEEKLY"
+	FrequencyMonthly Frequency = "MONTHLY"
+)
+
+// ErrInvalidRecurrence is returned when a recurrence rule cannot be parsed
+var ErrInvalidRecurrence = errors.New("invalid recurrence rule")
+
+var weekdays = map[string]time.Weekday{
+	"SU": time.Sunday,
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// Recurrence is a subset of the iCalendar RRULE: FREQ (DAILY, WEEKLY, MONTHLY),
+// INTERVAL, BYDAY (weekly only), BYMONTHDAY (monthly only, -1 for the last day), COUNT and UNTIL.
+type Recurrence struct {
+	Freq       Frequency
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonthDay int
+	Count      int
+	Until      *time.Time
+}
+
+// ParseRecurrence parses a plain frequency such as "monthly" or an RRULE such as
+// "FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR"
+func ParseRecurrence(value string) (*Recurrence, error) {
+	value = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(value)), "RRULE:")
+	if value == "" {
+		return nil, fmt.Errorf("%w: empty rule", ErrInvalidRecurrence)
+	}
+	if !strings.Contains(value, "=") {
+		value = "FREQ=" + value
+	}
+
+	rule := &Recurrence{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		key, val, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRecurrence, part)
+		}
+
+		switch key {
+		case "FREQ":
+			switch Frequency(val) {
+			case FrequencyDaily, FrequencyWeekly, FrequencyMonthly:
+				rule.Freq = Frequency(val)
+			default:
+				return nil, fmt.Errorf("%w: unsupported FREQ %q", ErrInvalidRecurrence, val)
+			}
+		case "INTERVAL":
+			interval, err := strconv.Atoi(val)
+			if err != nil || interval < 1 {
+				return nil, fmt.Errorf("%w: INTERVAL must be a positive number", ErrInvalidRecurrence)
+			}
+			rule.Interval = interval
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				weekday, ok := weekdays[day]
+				if !ok {
+					return nil, fmt.Errorf("%w: unsupported BYDAY %q", ErrInvalidRecurrence, day)
+				}
+				rule.ByDay = append(rule.ByDay, weekday)
+			}
+			sort.Slice(rule.ByDay, func(i, j int) bool {
+				return mondayOffset(rule.ByDay[i]) < mondayOffset(rule.ByDay[j])
+			})
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(val)
+			if err != nil || day == 0 || day < -1 || day > 31 {
+				return nil, fmt.Errorf("%w: BYMONTHDAY must be 1-31 or -1", ErrInvalidRecurrence)
+			}
+			rule.ByMonthDay = day
+		case "COUNT":
+			count, err := strconv.Atoi(val)
+			if err != nil || count < 1 {
+				return nil, fmt.Errorf("%w: COUNT must be a positive number", ErrInvalidRecurrence)
+			}
+			rule.Count = count
+		case "UNTIL":
+			until, err := parseUntil(val)
+			if err != nil {
+				return nil, err
+			}
+			rule.Until = &until
+		default:
+			return nil, fmt.Errorf("%w: unsupported part %q", ErrInvalidRecurrence, key)
+		}
+	}
+
+	if rule.Freq == "" {
+		return nil, fmt.Errorf("%w: FREQ is required", ErrInvalidRecurrence)
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != FrequencyWeekly {
+		return nil, fmt.Errorf("%w: BYDAY is only supported with FREQ=WEEKLY", ErrInvalidRecurrence)
+	}
+	if rule.ByMonthDay != 0 && rule.Freq != FrequencyMonthly {
+		return nil, fmt.Errorf("%w: BYMONTHDAY is only supported with FREQ=MONTHLY", ErrInvalidRecurrence)
+	}
+	return rule, nil
+}
+
+// Next returns the first occurrence on or after start that is strictly after the given time.
+// Pass a zero time to get the first occurrence. It returns false once UNTIL has passed.
+func (r *Recurrence) Next(start, after time.Time) (time.Time, bool) {
+	var next time.Time
+	switch r.Freq {
+	case FrequencyDaily:
+		next = r.nextFixed(start, after, 1)
+	case FrequencyWeekly:
+		if len(r.ByDay) == 0 {
+			next = r.nextFixed(start, after, 7)
+		} else {
+			next = r.nextWeekday(start, after)
+		}
+	case FrequencyMonthly:
+		next = r.nextMonthly(start, after)
+	default:
+		return time.Time{}, false
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return time.Time{}, false
+	}
+	return next, true
+}
+
+// nextFixed handles rules that repeat every interval*days days from start
+func (r *Recurrence) nextFixed(start, after time.Time, days int) time.Time {
+	step := r.Interval * days
+	if after.Before(start) {
+		return start
+	}
+	elapsed := int(after.Sub(start).Hours() / 24)
+	next := start.AddDate(0, 0, elapsed/step*step)
+	for !next.After(after) {
+		next = next.AddDate(0, 0, step)
+	}
+	return next
+}
+
+// nextWeekday handles FREQ=WEEKLY with BYDAY, counting weeks from the week of start
+func (r *Recurrence) nextWeekday(start, after time.Time) time.Time {
+	weekStart := start.AddDate(0, 0, -mondayOffset(start.Weekday()))
+	week := 0
+	if after.After(start) {
+		weeks := int(after.Sub(weekStart).Hours() / (24 * 7))
+		week = weeks / r.Interval * r.Interval
+	}
+
+	for ; ; week += r.Interval {
+		for _, day := range r.ByDay {
+			candidate := weekStart.AddDate(0, 0, week*7+mondayOffset(day))
+			if candidate.Before(start) || !candidate.After(after) {
+				continue
+			}
+			return candidate
+		}
+	}
+}
+
+// nextMonthly handles FREQ=MONTHLY, clamping days such as the 31st to the end of shorter months
+func (r *Recurrence) nextMonthly(start, after time.Time) time.Time {
+	day := r.ByMonthDay
+	if day == 0 {
+		day = start.Day()
+	}
+
+	month := 0
+	if after.After(start) {
+		months := (after.Year()-start.Year())*12 + int(after.Month()-start.Month())
+		month = (months - 1) / r.Interval * r.Interval
+		if month < 0 {
+			month = 0
+		}
+	}
+
+	for ; ; month += r.Interval {
+		candidate := monthDay(start, month, day)
+		if candidate.Before(start) || !candidate.After(after) {
+			continue
+		}
+		return candidate
+	}
+}
+
+// monthDay returns the given day in the month that is offset months after start, keeping the time of day
+func monthDay(start time.Time, offset, day int) time.Time {
+	first := time.Date(start.Year(), start.Month()+time.Month(offset), 1,
+		start.Hour(), start.Minute(), start.Second(), 0, start.Location())
+	lastDay := first.AddDate(0, 1, -1).Day()
+	if day == -1 || day > lastDay {
+		day = lastDay
+	}
+	return first.AddDate(0, 0, day-1)
+}
+
+// mondayOffset returns the number of days from Monday to the given weekday
+func mondayOffset(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102", "2006-01-02"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			if len(value) != len("20060102T150405Z") {
+				t = t.Add(24*time.Hour - time.Second)
+			}
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w: invalid UNTIL %q", ErrInvalidRecurrence, value)
+}
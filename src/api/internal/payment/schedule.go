@@ -0,0 +1,100 @@
+package payment
+
+import "time"
+
+// ScheduleStatus represents the lifecycle state of a payment schedule
+type ScheduleStatus string
+
+const (
+	ScheduleActive    ScheduleStatus = "ACTIVE"
+	SchedulePaused    ScheduleStatus = "PAUSED"
+	ScheduleCancelled ScheduleStatus = "CANCELLED"
+	ScheduleCompleted ScheduleStatus = "COMPLETED"
+)
+
+// RunStatus represents the outcome of one scheduled occurrence
+type RunStatus string
+
+const (
+	RunPending   RunStatus = "PENDING"
+	RunSucceeded RunStatus = "SUCCEEDED"
+	RunFailed    RunStatus = "FAILED"
+	RunExhausted RunStatus = "EXHAUSTED"
+)
+
+// Default retry policy for failed occurrences
+const (
+	DefaultMaxAttempts  = 3
+	DefaultRetryMinutes = 15
+)
+
+// Limits of the retry policy of a schedule
+const (
+	MaxRetryAttempts = 10
+	MaxRetryDelay    = 24 * time.Hour
+)
+
+// ScheduledPaymentStatus is the status of payments created by a schedule
+const ScheduledPaymentStatus = "pending"
+
+// PaymentTemplate holds the payment fields copied into every occurrence
+type PaymentTemplate struct {
+	Amount        float64 `json:"amount" example:"250.00"`
+	Currency      string  `json:"currency" example:"USD"`
+	PaymentMethod string  `json:"payment_method" example:"bank_transfer"`
+	PayTo         string  `json:"pay_to" example:"ACME Ltd"`
+	Note          string  `json:"note"`
+	Description   string  `json:"description" example:"Office rent"`
+}
+
+// RetryPolicy controls how failed occurrences are retried.
+// The delay doubles after every attempt, starting at RetryMinutes, up to MaxRetryDelay.
+type RetryPolicy struct {
+	MaxAttempts  int `json:"max_attempts" example:"3"`
+	RetryMinutes int `json:"retry_minutes" example:"15"`
+}
+
+// delay returns how long to wait after the given attempt failed
+func (policy RetryPolicy) delay(attempts int) time.Duration {
+	delay := time.Duration(policy.RetryMinutes) * time.Minute
+	for i := 1; i < attempts && delay < MaxRetryDelay; i++ {
+		delay *= 2
+	}
+	if delay > MaxRetryDelay {
+		return MaxRetryDelay
+	}
+	return delay
+}
+
+// PaymentSchedule is a standing order that materializes payments on each due date
+type PaymentSchedule struct {
+	ScheduleID     string          `json:"schedule_id"`
+	Template       PaymentTemplate `json:"template"`
+	Recurrence     string          `json:"recurrence" example:"FREQ=MONTHLY;BYMONTHDAY=1"`
+	StartDate      time.Time       `json:"start_date"`
+	EndDate        *time.Time      `json:"end_date,omitempty"`
+	MaxOccurrences int             `json:"max_occurrences,omitempty" example:"12"`
+	Occurrences    int             `json:"occurrences"`
+	NextRunAt      *time.Time      `json:"next_run_at,omitempty"`
+	Retry          RetryPolicy     `json:"retry"`
+	Status         ScheduleStatus  `json:"status"`
+	CreatedAt      time.Time       `json:"created_at"`
+	CreatedBy      string          `json:"created_by"`
+	UpdatedAt      time.Time       `json:"updated_at"`
+	TenantID       int             `json:"tenant_id"`
+}
+
+// ScheduleRun links one occurrence of a schedule to the payment it produced
+type ScheduleRun struct {
+	RunID         string     `json:"run_id"`
+	ScheduleID    string     `json:"schedule_id"`
+	Occurrence    int        `json:"occurrence"`
+	DueDate       time.Time  `json:"due_date"`
+	PaymentID     string     `json:"payment_id"`
+	Status        RunStatus  `json:"status"`
+	Attempts      int        `json:"attempts"`
+	LastError     string     `json:"last_error,omitempty"`
+	NextAttemptAt *time.Time `json:"next_attempt_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
@@ -0,0 +1,188 @@
+package payment
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"api/internal/tenant"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// ScheduleHandler handles HTTP requests for recurring payment schedules
+type ScheduleHandler struct {
+	scheduler *Scheduler
+}
+
+// NewScheduleHandler creates a new ScheduleHandler
+func NewScheduleHandler(scheduler *Scheduler) *ScheduleHandler {
+	return &ScheduleHandler{scheduler: scheduler}
+}
+
+// CreateScheduleHandler godoc
+// @Summary Create a payment schedule
+// @Description Create a standing order. recurrence accepts DAILY, WEEKLY, MONTHLY or an RRULE subset such as FREQ=WEEKLY;INTERVAL=2;BYDAY=MO
+// @Tags payments
+// @Accept json
+// @Produce json
+// @Param schedule body PaymentSchedule true "Payment schedule"
+// @Success 201 {object} PaymentSchedule
+// @Failure 400 {object} types.ErrorResponse
+// @Router /payments/schedules [post]
+func (h *ScheduleHandler) CreateScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var schedule PaymentSchedule
+	if err := json.NewDecoder(r.Body).Decode(&schedule); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", GetRequestID(r))
+		return
+	}
+
+	err := h.scheduler.CreateSchedule(r.Context(), &schedule, auth.Username(r))
+	if errors.Is(err, tenant.ErrNoTenant) {
+		writeError(w, http.StatusUnauthorized, "Unauthorized", GetRequestID(r))
+		return
+	}
+	if errors.Is(err, ErrInvalidSchedule) || errors.Is(err, ErrInvalidRecurrence) {
+		writeError(w, http.StatusBadRequest, err.Error(), GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to create payment schedule", GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, schedule, "Payment schedule created successfully", GetRequestID(r))
+}
+
+// GetSchedulesHandler godoc
+// @Summary Get payment schedules
+// @Description Get one schedule by id, or list schedules optionally filtered by status
+// @Tags payments
+// @Produce json
+// @Param id query string false "Schedule ID"
+// @Param status query string false "ACTIVE, PAUSED, CANCELLED or COMPLETED"
+// @Success 200 {array} PaymentSchedule
+// @Failure 404 {object} types.ErrorResponse
+// @Router /payments/schedules [get]
+func (h *ScheduleHandler) GetSchedulesHandler(w http.ResponseWriter, r *http.Request) {
+	if id := r.URL.Query().Get("id"); id != "" {
+		schedule, err := h.scheduler.GetSchedule(r.Context(), id)
+		if errors.Is(err, ErrScheduleNotFound) {
+			writeError(w, http.StatusNotFound, "Payment schedule not found", GetRequestID(r))
+			return
+		}
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, "Failed to fetch payment schedule", GetRequestID(r))
+			return
+		}
+		writeSuccess(w, http.StatusOK, schedule, "", GetRequestID(r))
+		return
+	}
+
+	status := ScheduleStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	schedules, err := h.scheduler.ListSchedules(r.Context(), status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch payment schedules", GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, schedules, "", GetRequestID(r))
+}
+
+// ScheduleHistoryHandler godoc
+// @Summary Get payment schedule history
+// @Description Get every occurrence of a schedule with the payment it produced and its retry state
+// @Tags payments
+// @Produce json
+// @Param id query string true "Schedule ID"
+// @Success 200 {array} ScheduleRun
+// @Failure 404 {object} types.ErrorResponse
+// @Router /payments/schedules/history [get]
+func (h *ScheduleHandler) ScheduleHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Schedule ID is required", GetRequestID(r))
+		return
+	}
+
+	runs, err := h.scheduler.History(r.Context(), id)
+	if errors.Is(err, ErrScheduleNotFound) {
+		writeError(w, http.StatusNotFound, "Payment schedule not found", GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch payment schedule history", GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, runs, "", GetRequestID(r))
+}
+
+// changeState handles the pause, resume and cancel endpoints
+func (h *ScheduleHandler) changeState(w http.ResponseWriter, r *http.Request, change func(context.Context, string) (*PaymentSchedule, error), message string) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Schedule ID is required", GetRequestID(r))
+		return
+	}
+
+	schedule, err := change(r.Context(), id)
+	switch {
+	case errors.Is(err, ErrScheduleNotFound):
+		writeError(w, http.StatusNotFound, "Payment schedule not found", GetRequestID(r))
+		return
+	case errors.Is(err, ErrScheduleState):
+		writeError(w, http.StatusConflict, err.Error(), GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to update payment schedule", GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, schedule, message, GetRequestID(r))
+}
+
+// PauseScheduleHandler godoc
+// @Summary Pause a payment schedule
+// @Tags payments
+// @Produce json
+// @Param id query string true "Schedule ID"
+// @Success 200 {object} PaymentSchedule
+// @Failure 409 {object} types.ErrorResponse
+// @Router /payments/schedules/pause [post]
+func (h *ScheduleHandler) PauseScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, h.scheduler.Pause, "Payment schedule paused successfully")
+}
+
+// ResumeScheduleHandler godoc
+// @Summary Resume a payment schedule
+// @Description Occurrences missed while paused are skipped
+// @Tags payments
+// @Produce json
+// @Param id query string true "Schedule ID"
+// @Success 200 {object} PaymentSchedule
+// @Failure 409 {object} types.ErrorResponse
+// @Router /payments/schedules/resume [post]
+func (h *ScheduleHandler) ResumeScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, h.scheduler.Resume, "Payment schedule resumed successfully")
+}
+
+// CancelScheduleHandler godoc
+// @Summary Cancel a payment schedule
+// @Tags payments
+// @Produce json
+// @Param id query string true "Schedule ID"
+// @Success 200 {object} PaymentSchedule
+// @Failure 409 {object} types.ErrorResponse
+// @Router /payments/schedules/cancel [post]
+func (h *ScheduleHandler) CancelScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	h.changeState(w, r, h.scheduler.Cancel, "Payment schedule cancelled successfully")
+}
+
+// RegisterRoutes registers the payment schedule routes with the given router
+func (h *ScheduleHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/payments/schedules", h.GetSchedulesHandler)
+	r.Post("/payments/schedules", h.CreateScheduleHandler)
+	r.Post("/payments/schedules/pause", h.PauseScheduleHandler)
+	r.Post("/payments/schedules/resume", h.ResumeScheduleHandler)
+	r.Post("/payments/schedules/cancel", h.CancelScheduleHandler)
+	r.Get("/payments/schedules/history", h.ScheduleHistoryHandler)
+}
@@ -0,0 +1,321 @@
+package payment
+
+import (
+	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+// ScheduleRepo represents the repository for payment schedule operations
+type ScheduleRepo struct {
+	DB *db.DB
+}
+
+// NewScheduleRepo creates a new instance of ScheduleRepo
+func NewScheduleRepo() *ScheduleRepo {
+	db := db.NewDB()
+	return &ScheduleRepo{DB: db}
+}
+
+const scheduleColumns = `
+	schedule_id, amount, currency, payment_method, pay_to, note, description,
+	recurrence, start_date, end_date, max_occurrences, occurrences, next_run_at,
+	max_attempts, retry_minutes, status, created_at, created_by, updated_at, tenant_id`
+
+const runColumns = `
+	run_id, schedule_id, occurrence, due_date, payment_id, status, attempts,
+	last_error, next_attempt_at, created_at, updated_at`
+
+// InsertSchedule inserts a new payment schedule
+func (sr *ScheduleRepo) InsertSchedule(s *PaymentSchedule) error {
+	_, err := sr.DB.Insert(`
+		INSERT INTO payment_schedules (`+scheduleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		s.ScheduleID,
+		s.Template.Amount,
+		s.Template.Currency,
+		s.Template.PaymentMethod,
+		s.Template.PayTo,
+		s.Template.Note,
+		s.Template.Description,
+		s.Recurrence,
+		s.StartDate.UTC(),
+		utcPtr(s.EndDate),
+		s.MaxOccurrences,
+		s.Occurrences,
+		utcPtr(s.NextRunAt),
+		s.Retry.MaxAttempts,
+		s.Retry.RetryMinutes,
+		s.Status,
+		s.CreatedAt,
+		s.CreatedBy,
+		s.UpdatedAt,
+		s.TenantID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting payment schedule: %w", err)
+	}
+	return nil
+}
+
+// scope returns the schedules of the tenant of ctx
+func (sr *ScheduleRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, sr.DB.Connection)
+}
+
+// UpdateScheduleState saves the status, occurrence count and next run of a schedule of the tenant of ctx
+func (sr *ScheduleRepo) UpdateScheduleState(ctx context.Context, s *PaymentSchedule) error {
+	scoped, err := sr.scope(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = scoped.Update("payment_schedules",
+		"status = ?, occurrences = ?, next_run_at = ?, updated_at = ?",
+		"schedule_id = ?",
+		s.Status,
+		s.Occurrences,
+		utcPtr(s.NextRunAt),
+		s.UpdatedAt,
+		s.ScheduleID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating payment schedule: %w", err)
+	}
+	return nil
+}
+
+// GetSchedule retrieves a payment schedule of the tenant of ctx by its id;
+// schedules of other tenants are not found
+func (sr *ScheduleRepo) GetSchedule(ctx context.Context, id string) (*PaymentSchedule, error) {
+	scoped, err := sr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("payment_schedules", scheduleColumns, "schedule_id = ?", id)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedule(row)
+}
+
+// GetSchedules retrieves the tenant's payment schedules, optionally filtered by status
+func (sr *ScheduleRepo) GetSchedules(ctx context.Context, status ScheduleStatus) ([]PaymentSchedule, error) {
+	scoped, err := sr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where := ""
+	var args []interface{}
+	if status != "" {
+		where = "status = ?"
+		args = append(args, status)
+	}
+	rows, err := scoped.Select("payment_schedules", scheduleColumns, where, "created_at", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment schedules: %w", err)
+	}
+	return scanSchedules(rows)
+}
+
+// GetRunSchedule retrieves the schedule of a run of any tenant; only the background runner uses it
+func (sr *ScheduleRepo) GetRunSchedule(run *ScheduleRun) (*PaymentSchedule, error) {
+	row, err := sr.DB.QueryRow("SELECT "+scheduleColumns+" FROM payment_schedules WHERE schedule_id = ?", run.ScheduleID)
+	if err != nil {
+		return nil, err
+	}
+	return scanSchedule(row)
+}
+
+// GetDueSchedules retrieves the active schedules of every tenant whose next run is at or before now
+func (sr *ScheduleRepo) GetDueSchedules(now time.Time) ([]PaymentSchedule, error) {
+	return sr.querySchedules(
+		"SELECT "+scheduleColumns+" FROM payment_schedules WHERE status = ? AND next_run_at <= ? ORDER BY next_run_at",
+		ScheduleActive, now.UTC(),
+	)
+}
+
+// InsertRun claims an occurrence of a schedule; the unique key stops it being materialized twice
+func (sr *ScheduleRepo) InsertRun(run *ScheduleRun) error {
+	_, err := sr.DB.Insert(`
+		INSERT INTO payment_schedule_runs (`+runColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		run.RunID,
+		run.ScheduleID,
+		run.Occurrence,
+		run.DueDate.UTC(),
+		run.PaymentID,
+		run.Status,
+		run.Attempts,
+		run.LastError,
+		utcPtr(run.NextAttemptAt),
+		run.CreatedAt,
+		run.UpdatedAt,
+	)
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code == sqlite3.ErrConstraint {
+		return ErrOccurrenceClaimed
+	}
+	if err != nil {
+		return fmt.Errorf("error inserting schedule run: %w", err)
+	}
+	return nil
+}
+
+// UpdateRun saves the outcome of an attempt
+func (sr *ScheduleRepo) UpdateRun(run *ScheduleRun) error {
+	_, err := sr.DB.Update(`
+		UPDATE payment_schedule_runs
+		SET status = ?, attempts = ?, last_error = ?, next_attempt_at = ?, updated_at = ?
+		WHERE run_id = ?`,
+		run.Status,
+		run.Attempts,
+		run.LastError,
+		utcPtr(run.NextAttemptAt),
+		run.UpdatedAt,
+		run.RunID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating schedule run: %w", err)
+	}
+	return nil
+}
+
+// GetRuns retrieves the payment history of a schedule
+func (sr *ScheduleRepo) GetRuns(scheduleID string) ([]ScheduleRun, error) {
+	return sr.queryRuns("SELECT "+runColumns+" FROM payment_schedule_runs WHERE schedule_id = ? ORDER BY occurrence", scheduleID)
+}
+
+// GetRetryableRuns retrieves failed or stuck runs whose next attempt is due
+func (sr *ScheduleRepo) GetRetryableRuns(now time.Time) ([]ScheduleRun, error) {
+	return sr.queryRuns(
+		"SELECT "+runColumns+" FROM payment_schedule_runs WHERE status IN (?, ?) AND next_attempt_at <= ? ORDER BY next_attempt_at",
+		RunFailed, RunPending, now.UTC(),
+	)
+}
+
+// PaymentExists reports whether a payment row was already written
+func (sr *ScheduleRepo) PaymentExists(paymentID string) (bool, error) {
+	row, err := sr.DB.QueryRow("SELECT COUNT(*) FROM payments WHERE payment_id = ?", paymentID)
+	if err != nil {
+		return false, err
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+func (sr *ScheduleRepo) querySchedules(query string, args ...interface{}) ([]PaymentSchedule, error) {
+	rows, err := sr.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment schedules: %w", err)
+	}
+	return scanSchedules(rows)
+}
+
+func scanSchedules(rows *sql.Rows) ([]PaymentSchedule, error) {
+	defer rows.Close()
+
+	var schedules []PaymentSchedule
+	for rows.Next() {
+		schedule, err := scanSchedule(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning payment schedule: %w", err)
+		}
+		schedules = append(schedules, *schedule)
+	}
+	return schedules, rows.Err()
+}
+
+func (sr *ScheduleRepo) queryRuns(query string, args ...interface{}) ([]ScheduleRun, error) {
+	rows, err := sr.DB.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying schedule runs: %w", err)
+	}
+	defer rows.Close()
+
+	var runs []ScheduleRun
+	for rows.Next() {
+		var run ScheduleRun
+		var nextAttemptAt sql.NullTime
+		err := rows.Scan(
+			&run.RunID,
+			&run.ScheduleID,
+			&run.Occurrence,
+			&run.DueDate,
+			&run.PaymentID,
+			&run.Status,
+			&run.Attempts,
+			&run.LastError,
+			&nextAttemptAt,
+			&run.CreatedAt,
+			&run.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning schedule run: %w", err)
+		}
+		run.NextAttemptAt = timePtr(nextAttemptAt)
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanSchedule(row rowScanner) (*PaymentSchedule, error) {
+	var s PaymentSchedule
+	var endDate, nextRunAt sql.NullTime
+	err := row.Scan(
+		&s.ScheduleID,
+		&s.Template.Amount,
+		&s.Template.Currency,
+		&s.Template.PaymentMethod,
+		&s.Template.PayTo,
+		&s.Template.Note,
+		&s.Template.Description,
+		&s.Recurrence,
+		&s.StartDate,
+		&endDate,
+		&s.MaxOccurrences,
+		&s.Occurrences,
+		&nextRunAt,
+		&s.Retry.MaxAttempts,
+		&s.Retry.RetryMinutes,
+		&s.Status,
+		&s.CreatedAt,
+		&s.CreatedBy,
+		&s.UpdatedAt,
+		&s.TenantID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	s.EndDate = timePtr(endDate)
+	s.NextRunAt = timePtr(nextRunAt)
+	return &s, nil
+}
+
+func utcPtr(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
+
+func timePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	value := t.Time
+	return &value
+}
@@ -0,0 +1,365 @@
+package payment
+
+import (
+	"api/internal/auth"
+	"api/internal/fx"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Schedule errors
+var (
+	ErrScheduleNotFound  = errors.New("payment schedule not found")
+	ErrInvalidSchedule   = errors.New("invalid payment schedule")
+	ErrScheduleState     = errors.New("payment schedule cannot change to the requested state")
+	ErrOccurrenceClaimed = errors.New("schedule occurrence already materialized")
+)
+
+// maxCatchUp bounds how many missed occurrences of one schedule are materialized in a single pass
+const maxCatchUp = 100
+
+// Scheduler manages standing orders and materializes their payments when due
+type Scheduler struct {
+	repo     *ScheduleRepo
+	payments *PaymentRepo
+	fx       *fx.Converter
+	now      func() time.Time
+}
+
+// NewScheduler creates a new Scheduler
+func NewScheduler() *Scheduler {
+	return NewSchedulerWithRepo(NewScheduleRepo(), NewPaymentRepo(), fx.NewConverter())
+}
+
+// NewSchedulerWithRepo creates a Scheduler on the given schedule and payment repositories and converter
+func NewSchedulerWithRepo(repo *ScheduleRepo, payments *PaymentRepo, converter *fx.Converter) *Scheduler {
+	return &Scheduler{repo: repo, payments: payments, fx: converter, now: time.Now}
+}
+
+// CreateSchedule validates a schedule and stores it for the tenant of ctx with its first due date
+func (s *Scheduler) CreateSchedule(ctx context.Context, schedule *PaymentSchedule, createdBy string) error {
+	tenantID, ok := auth.TenantIDFromContext(ctx)
+	if !ok {
+		return tenant.ErrNoTenant
+	}
+	if schedule.Template.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidSchedule)
+	}
+	if schedule.Template.PayTo == "" || schedule.Template.PaymentMethod == "" {
+		return fmt.Errorf("%w: pay_to and payment_method are required", ErrInvalidSchedule)
+	}
+	if schedule.MaxOccurrences < 0 {
+		return fmt.Errorf("%w: max_occurrences cannot be negative", ErrInvalidSchedule)
+	}
+
+	rule, err := ParseRecurrence(schedule.Recurrence)
+	if err != nil {
+		return err
+	}
+	if rule.Count > 0 && (schedule.MaxOccurrences == 0 || rule.Count < schedule.MaxOccurrences) {
+		schedule.MaxOccurrences = rule.Count
+	}
+
+	now := s.now()
+	if schedule.StartDate.IsZero() {
+		schedule.StartDate = now
+	}
+	if schedule.EndDate != nil && schedule.EndDate.Before(schedule.StartDate) {
+		return fmt.Errorf("%w: end_date is before start_date", ErrInvalidSchedule)
+	}
+	if schedule.Retry.MaxAttempts < 0 || schedule.Retry.MaxAttempts > MaxRetryAttempts {
+		return fmt.Errorf("%w: max_attempts must be between 1 and %d", ErrInvalidSchedule, MaxRetryAttempts)
+	}
+	if schedule.Retry.RetryMinutes < 0 || schedule.Retry.RetryMinutes > int(MaxRetryDelay/time.Minute) {
+		return fmt.Errorf("%w: retry_minutes must be between 1 and %d", ErrInvalidSchedule, int(MaxRetryDelay/time.Minute))
+	}
+	if schedule.Retry.MaxAttempts <= 0 {
+		schedule.Retry.MaxAttempts = DefaultMaxAttempts
+	}
+	if schedule.Retry.RetryMinutes <= 0 {
+		schedule.Retry.RetryMinutes = DefaultRetryMinutes
+	}
+
+	schedule.ScheduleID = uuid.New().String()
+	schedule.Occurrences = 0
+	schedule.Status = ScheduleActive
+	schedule.CreatedAt = now
+	schedule.CreatedBy = createdBy
+	schedule.UpdatedAt = now
+	schedule.TenantID = tenantID
+	schedule.NextRunAt = nil
+	s.setNextRun(schedule, rule, time.Time{})
+	if schedule.Status != ScheduleActive {
+		return fmt.Errorf("%w: the schedule has no occurrences", ErrInvalidSchedule)
+	}
+
+	return s.repo.InsertSchedule(schedule)
+}
+
+// GetSchedule returns a payment schedule of the tenant of ctx
+func (s *Scheduler) GetSchedule(ctx context.Context, id string) (*PaymentSchedule, error) {
+	schedule, err := s.repo.GetSchedule(ctx, id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrScheduleNotFound
+	}
+	return schedule, err
+}
+
+// ListSchedules returns the tenant's payment schedules, optionally filtered by status
+func (s *Scheduler) ListSchedules(ctx context.Context, status ScheduleStatus) ([]PaymentSchedule, error) {
+	return s.repo.GetSchedules(ctx, status)
+}
+
+// History returns every occurrence of a schedule and the payment it produced
+func (s *Scheduler) History(ctx context.Context, id string) ([]ScheduleRun, error) {
+	if _, err := s.GetSchedule(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.GetRuns(id)
+}
+
+// Pause stops an active schedule from materializing payments
+func (s *Scheduler) Pause(ctx context.Context, id string) (*PaymentSchedule, error) {
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != ScheduleActive {
+		return nil, ErrScheduleState
+	}
+
+	schedule.Status = SchedulePaused
+	return schedule, s.save(ctx, schedule)
+}
+
+// Resume re-activates a paused schedule. Occurrences missed while paused are skipped.
+func (s *Scheduler) Resume(ctx context.Context, id string) (*PaymentSchedule, error) {
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != SchedulePaused {
+		return nil, ErrScheduleState
+	}
+	rule, err := ParseRecurrence(schedule.Recurrence)
+	if err != nil {
+		return nil, err
+	}
+
+	schedule.Status = ScheduleActive
+	s.setNextRun(schedule, rule, s.now())
+	return schedule, s.save(ctx, schedule)
+}
+
+// Cancel permanently stops a schedule
+func (s *Scheduler) Cancel(ctx context.Context, id string) (*PaymentSchedule, error) {
+	schedule, err := s.GetSchedule(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if schedule.Status != ScheduleActive && schedule.Status != SchedulePaused {
+		return nil, ErrScheduleState
+	}
+
+	schedule.Status = ScheduleCancelled
+	schedule.NextRunAt = nil
+	return schedule, s.save(ctx, schedule)
+}
+
+// RunDue materializes a payment for every due occurrence of every active schedule
+// and returns the number of occurrences processed
+func (s *Scheduler) RunDue() (int, error) {
+	now := s.now()
+	schedules, err := s.repo.GetDueSchedules(now)
+	if err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for i := range schedules {
+		schedule := &schedules[i]
+		rule, err := ParseRecurrence(schedule.Recurrence)
+		if err != nil {
+			log.Printf("[error] - schedule %s has an invalid rule: %v", schedule.ScheduleID, err)
+			continue
+		}
+
+		for n := 0; n < maxCatchUp && schedule.Status == ScheduleActive &&
+			schedule.NextRunAt != nil && !schedule.NextRunAt.After(now); n++ {
+			due := *schedule.NextRunAt
+			if err := s.materialize(schedule, due); err != nil && !errors.Is(err, ErrOccurrenceClaimed) {
+				return processed, err
+			}
+			schedule.Occurrences++
+			s.setNextRun(schedule, rule, due)
+			if err := s.save(schedule.tenantContext(), schedule); err != nil {
+				return processed, err
+			}
+			processed++
+		}
+	}
+	return processed, nil
+}
+
+// RetryFailed retries failed occurrences whose backoff has elapsed
+func (s *Scheduler) RetryFailed() (int, error) {
+	runs, err := s.repo.GetRetryableRuns(s.now())
+	if err != nil {
+		return 0, err
+	}
+
+	for i := range runs {
+		run := &runs[i]
+		schedule, err := s.repo.GetRunSchedule(run)
+		if err != nil {
+			return i, err
+		}
+		if schedule.Status == SchedulePaused {
+			continue
+		}
+		if schedule.Status == ScheduleCancelled {
+			run.Status = RunExhausted
+			run.LastError = "schedule cancelled"
+			run.NextAttemptAt = nil
+			run.UpdatedAt = s.now()
+			if err := s.repo.UpdateRun(run); err != nil {
+				return i, err
+			}
+			continue
+		}
+		if err := s.attempt(schedule, run); err != nil {
+			return i, err
+		}
+	}
+	return len(runs), nil
+}
+
+// Run processes due schedules and retries every interval until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if n, err := s.RunDue(); err != nil {
+			log.Printf("[error] - Run scheduled payments: %v", err)
+		} else if n > 0 {
+			log.Printf("[info] - Materialized %d scheduled payments", n)
+		}
+		if n, err := s.RetryFailed(); err != nil {
+			log.Printf("[error] - Retry scheduled payments: %v", err)
+		} else if n > 0 {
+			log.Printf("[info] - Retried %d scheduled payments", n)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// materialize claims an occurrence and makes the first attempt at creating its payment
+func (s *Scheduler) materialize(schedule *PaymentSchedule, due time.Time) error {
+	now := s.now()
+	// A pending run is retried later if the process stops before recording the outcome
+	retryAt := now.Add(time.Duration(schedule.Retry.RetryMinutes) * time.Minute)
+	run := &ScheduleRun{
+		RunID:         uuid.New().String(),
+		ScheduleID:    schedule.ScheduleID,
+		Occurrence:    schedule.Occurrences + 1,
+		DueDate:       due,
+		PaymentID:     uuid.New().String(),
+		Status:        RunPending,
+		NextAttemptAt: &retryAt,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := s.repo.InsertRun(run); err != nil {
+		return err
+	}
+	return s.attempt(schedule, run)
+}
+
+// attempt inserts the payment for a run with its FX rate locked and records the outcome.
+// The payment id is fixed per run, so an attempt after a crash does not create a duplicate.
+// A missing rate fails the attempt, so it is retried once rates are imported.
+func (s *Scheduler) attempt(schedule *PaymentSchedule, run *ScheduleRun) error {
+	run.Attempts++
+
+	exists, err := s.repo.PaymentExists(run.PaymentID)
+	if err == nil && !exists {
+		payment := schedule.paymentFor(run, s.now())
+		if err = lockRate(s.fx, payment); err == nil {
+			_, err = s.payments.InsertPayment(schedule.tenantContext(), payment)
+		}
+	}
+
+	now := s.now()
+	run.UpdatedAt = now
+	switch {
+	case err == nil:
+		run.Status = RunSucceeded
+		run.LastError = ""
+		run.NextAttemptAt = nil
+	case run.Attempts >= schedule.Retry.MaxAttempts:
+		run.Status = RunExhausted
+		run.LastError = err.Error()
+		run.NextAttemptAt = nil
+	default:
+		next := now.Add(schedule.Retry.delay(run.Attempts))
+		run.Status = RunFailed
+		run.LastError = err.Error()
+		run.NextAttemptAt = &next
+	}
+	return s.repo.UpdateRun(run)
+}
+
+// setNextRun moves the schedule to its next occurrence after the given time, completing it when none is left
+func (s *Scheduler) setNextRun(schedule *PaymentSchedule, rule *Recurrence, after time.Time) {
+	next, ok := rule.Next(schedule.StartDate, after)
+	exhausted := schedule.MaxOccurrences > 0 && schedule.Occurrences >= schedule.MaxOccurrences
+	pastEnd := schedule.EndDate != nil && next.After(*schedule.EndDate)
+	if !ok || exhausted || pastEnd {
+		schedule.Status = ScheduleCompleted
+		schedule.NextRunAt = nil
+		return
+	}
+	schedule.NextRunAt = &next
+}
+
+func (s *Scheduler) save(ctx context.Context, schedule *PaymentSchedule) error {
+	schedule.UpdatedAt = s.now()
+	return s.repo.UpdateScheduleState(ctx, schedule)
+}
+
+// tenantContext returns the context the background runner uses for a schedule;
+// schedules run without a request, so their tenant comes from the schedule
+func (schedule *PaymentSchedule) tenantContext() context.Context {
+	return auth.WithTenantID(context.Background(), schedule.TenantID)
+}
+
+// paymentFor builds the payment for one occurrence from the schedule template
+func (schedule *PaymentSchedule) paymentFor(run *ScheduleRun, now time.Time) *Payment {
+	return &Payment{
+		ID:            run.PaymentID,
+		PaymentID:     run.PaymentID,
+		Amount:        schedule.Template.Amount,
+		Currency:      schedule.Template.Currency,
+		PaymentMethod: schedule.Template.PaymentMethod,
+		PaymentDate:   run.DueDate.Format("2006-01-02"),
+		PayTo:         schedule.Template.PayTo,
+		Note:          schedule.Template.Note,
+		Status:        ScheduledPaymentStatus,
+		Description:   schedule.Template.Description,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+}
@@ -0,0 +1,160 @@
+package payment
+
+import (
+	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/ledger"
+	"errors"
+	"sort"
+	"time"
+)
+
+// TotalGroup sums the payments sharing a currency, payment date and lock state
+type TotalGroup struct {
+	Currency    string
+	PaymentDate string
+	Locked      bool
+	Count       int64
+	Amount      float64
+	BaseAmount  float64
+}
+
+// CurrencyTotal sums the payments in one currency and their value in the base currency
+type CurrencyTotal struct {
+	Currency   string  `json:"currency" example:"EUR"`
+	Count      int64   `json:"count" example:"12"`
+	Amount     float64 `json:"amount" example:"1250.50"`
+	BaseAmount float64 `json:"base_amount" example:"1355.79"`
+	// Unconverted counts payments left out of BaseAmount because no rate was in force on their payment date
+	Unconverted int64 `json:"unconverted,omitempty"`
+}
+
+// Totals breaks payment amounts down per currency and adds them up in the base currency
+type Totals struct {
+	BaseCurrency string          `json:"base_currency" example:"USD"`
+	BaseTotal    float64         `json:"base_total" example:"2605.79"`
+	Count        int64           `json:"count" example:"20"`
+	Unconverted  int64           `json:"unconverted"`
+	Currencies   []CurrencyTotal `json:"currencies"`
+}
+
+// PaymentList is a page of payments with totals over every payment matching the query
+type PaymentList struct {
+	*db.PaginationResponse
+	Totals *Totals `json:"totals"`
+}
+
+// BuildTotals adds up payment groups in the converter's base currency.
+// Payments use the base amount locked at creation; older payments without a lock are
+// converted at the rate in force on their payment date, or on their creation day when the date is invalid.
+// Payments without a currency are taken to be in the base currency.
+func BuildTotals(groups []TotalGroup, converter *fx.Converter, now time.Time) (*Totals, error) {
+	base := converter.BaseCurrency()
+	byCurrency := make(map[string]*CurrencyTotal)
+	var baseMinor int64
+	minors := make(map[string]int64)
+
+	for _, group := range groups {
+		currency := group.Currency
+		if currency == "" {
+			currency = base
+		}
+		total, ok := byCurrency[currency]
+		if !ok {
+			total = &CurrencyTotal{Currency: currency}
+			byCurrency[currency] = total
+		}
+		total.Count += group.Count
+		total.Amount = ledger.FromMinor(ledger.ToMinor(total.Amount + group.Amount))
+
+		value := group.BaseAmount
+		if !group.Locked {
+			asOf, err := time.Parse("2006-01-02", group.PaymentDate)
+			if err != nil {
+				asOf = now
+			}
+			conversion, err := converter.Convert(group.Amount, currency, base, asOf)
+			if errors.Is(err, fx.ErrRateNotFound) || errors.Is(err, fx.ErrInvalidCurrency) {
+				total.Unconverted += group.Count
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			value = conversion.Converted
+		}
+		minors[currency] += ledger.ToMinor(value)
+		baseMinor += ledger.ToMinor(value)
+	}
+
+	totals := &Totals{
+		BaseCurrency: base,
+		BaseTotal:    ledger.FromMinor(baseMinor),
+		Currencies:   []CurrencyTotal{},
+	}
+	for currency, total := range byCurrency {
+		total.BaseAmount = ledger.FromMinor(minors[currency])
+		totals.Count += total.Count
+		totals.Unconverted += total.Unconverted
+		totals.Currencies = append(totals.Currencies, *total)
+	}
+	sort.Slice(totals.Currencies, func(i, j int) bool {
+		return totals.Currencies[i].Currency < totals.Currencies[j].Currency
+	})
+	return totals, nil
+}
+
+// lockRate fixes the base currency value of a new payment at the rate in force when it is created.
+// A missing currency defaults to the base currency.
+func lockRate(converter *fx.Converter, payment *Payment) error {
+	if payment.Currency == "" {
+		payment.Currency = converter.BaseCurrency()
+	}
+	currency, err := fx.NormalizeCurrency(payment.Currency)
+	if err != nil {
+		return err
+	}
+	payment.Currency = currency
+
+	lock, err := converter.Lock(payment.Amount, payment.Currency, payment.CreatedAt)
+	if err != nil {
+		return err
+	}
+	payment.BaseCurrency = lock.BaseCurrency
+	payment.BaseAmount = lock.BaseAmount
+	payment.FXRate = lock.Rate
+	payment.FXRateDate = lock.RateDate
+	return nil
+}
+
+// relockRate keeps the rate locked when the payment was created across updates.
+// A new rate is only locked when the currency changes or the payment predates rate locking.
+func relockRate(converter *fx.Converter, payment, existing *Payment) error {
+	if payment.Currency == "" {
+		payment.Currency = existing.Currency
+	}
+	currency, err := fx.NormalizeCurrency(payment.Currency)
+	if err != nil {
+		return err
+	}
+	payment.Currency = currency
+
+	if existing.BaseCurrency == "" || !equalCurrency(existing.Currency, currency) {
+		payment.CreatedAt = existing.CreatedAt
+		if payment.CreatedAt.IsZero() {
+			payment.CreatedAt = payment.UpdatedAt
+		}
+		return lockRate(converter, payment)
+	}
+	payment.BaseCurrency = existing.BaseCurrency
+	payment.FXRate = existing.FXRate
+	payment.FXRateDate = existing.FXRateDate
+	payment.BaseAmount = ledger.FromMinor(ledger.ToMinor(payment.Amount * existing.FXRate))
+	return nil
+}
+
+func equalCurrency(a, b string) bool {
+	a, errA := fx.NormalizeCurrency(a)
+	b, errB := fx.NormalizeCurrency(b)
+	return errA == nil && errB == nil && a == b
+}
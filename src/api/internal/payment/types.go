@@ -14,23 +14,25 @@ type Payment struct {
 	Note          string    `json:"note"`
 	Status        string    `json:"status" example:"completed"`
 	Description   string    `json:"description" example:"Payment for services"`
+	BaseCurrency  string    `json:"base_currency,omitempty" example:"USD"`
+	BaseAmount    float64   `json:"base_amount,omitempty" example:"108.42"`
+	FXRate        float64   `json:"fx_rate,omitempty" example:"1.0842"`
+	FXRateDate    string    `json:"fx_rate_date,omitempty" example:"2025-01-31"`
 	CreatedAt     time.Time `json:"created_at"`
 	UpdatedAt     time.Time `json:"updated_at"`
 }
 
-type CreditCard struct {
-	CardNumber string `json:"card_number"`
-	ExpiryDate string `json:"expiry_date"`
-	CVV        string `json:"cvv"`
-}
-
+// CreditCardPayment references a card by its vault token. Raw card details never
+// reach the payment package; they are exchanged for a token by the vault first.
 type CreditCardPayment struct {
-	PaymentID      string     `json:"payment_id"`
-	CreditCardInfo CreditCard `json:"credit_card_info"`
-	Amount         float64    `json:"amount"`
-	PayTo          string     `json:"pay_to"`
-	Note           string     `json:"note"`
-	Status         string     `json:"status"`
-	CreatedAt      string     `json:"created_at"`
-	UpdatedAt      string     `json:"updated_at"`
+	PaymentID    string  `json:"payment_id"`
+	CardToken    string  `json:"card_token"`
+	CardBrand    string  `json:"card_brand,omitempty"`
+	MaskedNumber string  `json:"masked_number,omitempty"`
+	Amount       float64 `json:"amount"`
+	PayTo        string  `json:"pay_to"`
+	Note         string  `json:"note"`
+	Status       string  `json:"status"`
+	CreatedAt    string  `json:"created_at"`
+	UpdatedAt    string  `json:"updated_at"`
 }
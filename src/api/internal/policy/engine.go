@@ -0,0 +1,444 @@
+package policy
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// ErrNotFilterable is returned when a policy cannot be translated into a query filter
+var ErrNotFilterable = errors.New("policy cannot be applied to a query")
+
+// Engine evaluates requests against the configured policies.
+// Policies are swapped atomically when the policy file changes.
+type Engine struct {
+	mu       sync.RWMutex
+	policies *PolicySet
+}
+
+// NewEngine creates an Engine with the given policies
+func NewEngine(policies *PolicySet) *Engine {
+	return &Engine{policies: policies}
+}
+
+// Policies returns the policies currently in use
+func (e *Engine) Policies() *PolicySet {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.policies
+}
+
+// SetPolicies replaces the policies in use
+func (e *Engine) SetPolicies(policies *PolicySet) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.policies = policies
+}
+
+// LoadPolicies reads and validates a policy file (YAML, JSON or TOML by extension)
+func LoadPolicies(path string) (*PolicySet, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading policies: %w", err)
+	}
+	return decodePolicies(v)
+}
+
+// WatchPolicies loads the policy file into the engine and reloads it whenever it changes.
+// An invalid file is logged and the previous policies stay in effect.
+func (e *Engine) WatchPolicies(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading policies: %w", err)
+	}
+	policies, err := decodePolicies(v)
+	if err != nil {
+		return err
+	}
+	e.SetPolicies(policies)
+
+	v.OnConfigChange(func(event fsnotify.Event) {
+		policies, err := decodePolicies(v)
+		if err != nil {
+			log.Printf("[error] - Policies not reloaded from %s: %v", event.Name, err)
+			return
+		}
+		e.SetPolicies(policies)
+		log.Printf("[info] - Policies reloaded from %s (version %s)", event.Name, policies.Version)
+	})
+	v.WatchConfig()
+	return nil
+}
+
+func decodePolicies(v *viper.Viper) (*PolicySet, error) {
+	var policies PolicySet
+	if err := v.Unmarshal(&policies); err != nil {
+		return nil, fmt.Errorf("error decoding policies: %w", err)
+	}
+	if err := policies.Validate(); err != nil {
+		return nil, err
+	}
+	return &policies, nil
+}
+
+// Validate checks the policies for values that would make evaluation meaningless
+func (ps *PolicySet) Validate() error {
+	if ps.DefaultEffect == "" {
+		ps.DefaultEffect = EffectDeny
+	}
+	if !validEffect(ps.DefaultEffect) {
+		return fmt.Errorf("default_effect must be %q or %q", EffectAllow, EffectDeny)
+	}
+
+	names := make(map[string]bool)
+	for _, p := range ps.Policies {
+		if p.Name == "" {
+			return errors.New("policy name is required")
+		}
+		if names[p.Name] {
+			return fmt.Errorf("policy %q is defined twice", p.Name)
+		}
+		names[p.Name] = true
+		if p.Resource == "" || len(p.Actions) == 0 {
+			return fmt.Errorf("policy %q: resource and actions are required", p.Name)
+		}
+		if !validEffect(p.Effect) {
+			return fmt.Errorf("policy %q: effect must be %q or %q", p.Name, EffectAllow, EffectDeny)
+		}
+		for _, c := range p.Conditions {
+			if err := c.validate(); err != nil {
+				return fmt.Errorf("policy %q: %w", p.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+func (c Condition) validate() error {
+	if !validAttribute(c.Attribute) {
+		return fmt.Errorf("attribute %q must start with %q or %q", c.Attribute, SubjectPrefix, ResourcePrefix)
+	}
+	if (c.Value == nil) == (c.ValueFrom == "") {
+		return fmt.Errorf("condition on %s needs either value or value_from", c.Attribute)
+	}
+	if c.ValueFrom != "" && !validAttribute(c.ValueFrom) {
+		return fmt.Errorf("value_from %q must start with %q or %q", c.ValueFrom, SubjectPrefix, ResourcePrefix)
+	}
+	switch c.Operator {
+	case OpEq, OpNe:
+	case OpLt, OpLte, OpGt, OpGte:
+		if _, ok := toNumber(c.Value); c.Value != nil && !ok {
+			return fmt.Errorf("condition on %s: %s needs a number", c.Attribute, c.Operator)
+		}
+	case OpIn, OpNotIn:
+		if _, ok := toList(c.Value); c.Value != nil && !ok {
+			return fmt.Errorf("condition on %s: %s needs a list", c.Attribute, c.Operator)
+		}
+	default:
+		return fmt.Errorf("condition on %s: unknown operator %q", c.Attribute, c.Operator)
+	}
+	return nil
+}
+
+// Evaluate decides a request. A matching deny policy wins, then a matching allow
+// policy; otherwise the default effect applies. Super admins are always allowed.
+func (e *Engine) Evaluate(req Request) Decision {
+	if req.Subject == nil {
+		return Decision{Allowed: false, Effect: EffectDeny, Reason: "no subject"}
+	}
+	if req.Subject.SuperAdmin {
+		return Decision{Allowed: true, Effect: EffectAllow, Reason: "super admin"}
+	}
+	policies := e.Policies()
+	if policies == nil {
+		return Decision{Allowed: false, Effect: EffectDeny, Reason: "policies are not loaded"}
+	}
+
+	subject := req.Subject.attributes()
+	var decision Decision
+	var allow, deny string
+	for _, p := range policies.Policies {
+		if p.Resource != req.Resource {
+			continue
+		}
+		evaluation := p.evaluate(req.Subject, req.Action, subject, req.Attributes)
+		decision.Evaluations = append(decision.Evaluations, evaluation)
+		if !evaluation.Matched {
+			continue
+		}
+		if p.Effect == EffectDeny && deny == "" {
+			deny = p.Name
+		}
+		if p.Effect == EffectAllow && allow == "" {
+			allow = p.Name
+		}
+	}
+
+	switch {
+	case deny != "":
+		decision.Effect, decision.Policy, decision.Reason = EffectDeny, deny, "denied by policy "+deny
+	case allow != "":
+		decision.Effect, decision.Policy, decision.Reason = EffectAllow, allow, "allowed by policy "+allow
+	default:
+		decision.Effect, decision.Reason = policies.DefaultEffect, "no policy matched; default effect is "+policies.DefaultEffect
+	}
+	decision.Allowed = decision.Effect == EffectAllow
+	return decision
+}
+
+// evaluate explains whether a policy applies to the subject and action, and whether its conditions hold
+func (p Policy) evaluate(s *Subject, action string, subject, resource Attributes) PolicyEvaluation {
+	evaluation := PolicyEvaluation{Policy: p.Name, Effect: p.Effect}
+	if reason := p.applies(s, action); reason != "" {
+		evaluation.Reason = reason
+		return evaluation
+	}
+	evaluation.Applicable = true
+	evaluation.Matched = true
+	for _, c := range p.Conditions {
+		actual := lookup(c.Attribute, subject, resource)
+		expected := c.Value
+		if c.ValueFrom != "" {
+			expected = lookup(c.ValueFrom, subject, resource)
+		}
+		matched := compare(c.Operator, actual, expected)
+		evaluation.Conditions = append(evaluation.Conditions, ConditionResult{
+			Attribute: c.Attribute,
+			Operator:  c.Operator,
+			Actual:    actual,
+			Expected:  expected,
+			Matched:   matched,
+		})
+		if !matched {
+			evaluation.Matched = false
+		}
+	}
+	if !evaluation.Matched {
+		evaluation.Reason = "conditions not met"
+	}
+	return evaluation
+}
+
+// applies returns why a policy does not apply to a subject and action, or "" when it does
+func (p Policy) applies(s *Subject, action string) string {
+	if !contains(p.Actions, AnyAction) && !contains(p.Actions, action) {
+		return "action not covered"
+	}
+	if len(p.Roles) == 0 {
+		return ""
+	}
+	for _, role := range s.Roles {
+		if contains(p.Roles, role) {
+			return ""
+		}
+	}
+	return "role not held"
+}
+
+// Filter translates the policies of a resource and action into a WHERE clause, so
+// list queries return only the rows Evaluate would allow. columns maps resource
+// attributes to column names; a condition on an unmapped attribute is an error.
+func (e *Engine) Filter(s *Subject, resource, action string, columns map[string]string) (*SQLFilter, error) {
+	if s == nil {
+		return &SQLFilter{Where: "1 = 0"}, nil
+	}
+	if s.SuperAdmin {
+		return &SQLFilter{Where: "1 = 1"}, nil
+	}
+	policies := e.Policies()
+	if policies == nil {
+		return &SQLFilter{Where: "1 = 0"}, nil
+	}
+
+	subject := s.attributes()
+	var allows, denies []string
+	var allowArgs, denyArgs []interface{}
+	for _, p := range policies.Policies {
+		if p.Resource != resource || p.applies(s, action) != "" {
+			continue
+		}
+		clause, args, ok, err := p.clause(subject, columns)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			continue
+		}
+		if p.Effect == EffectDeny {
+			denies = append(denies, clause)
+			denyArgs = append(denyArgs, args...)
+		} else {
+			allows = append(allows, clause)
+			allowArgs = append(allowArgs, args...)
+		}
+	}
+
+	allow := "1 = 0"
+	if policies.DefaultEffect == EffectAllow {
+		allow, allowArgs = "1 = 1", nil
+	} else if len(allows) > 0 {
+		allow = strings.Join(allows, " OR ")
+	}
+	if len(denies) == 0 {
+		return &SQLFilter{Where: fmt.Sprintf("((%s) IS TRUE)", allow), Args: allowArgs}, nil
+	}
+	return &SQLFilter{
+		Where: fmt.Sprintf("((%s) IS TRUE) AND NOT ((%s) IS TRUE)", allow, strings.Join(denies, " OR ")),
+		Args:  append(allowArgs, denyArgs...),
+	}, nil
+}
+
+// clause translates a policy's conditions into SQL. Conditions on subject attributes are
+// decided up front; ok is false when one of them fails, so the policy never matches.
+func (p Policy) clause(subject Attributes, columns map[string]string) (string, []interface{}, bool, error) {
+	var parts []string
+	var args []interface{}
+	for _, c := range p.Conditions {
+		var expected interface{} = c.Value
+		var expectedColumn string
+		if c.ValueFrom != "" {
+			if strings.HasPrefix(c.ValueFrom, ResourcePrefix) {
+				column, ok := columns[strings.TrimPrefix(c.ValueFrom, ResourcePrefix)]
+				if !ok {
+					return "", nil, false, fmt.Errorf("%w: %s has no column", ErrNotFilterable, c.ValueFrom)
+				}
+				expectedColumn = column
+			} else {
+				expected = lookup(c.ValueFrom, subject, nil)
+			}
+		}
+
+		if strings.HasPrefix(c.Attribute, SubjectPrefix) {
+			if expectedColumn != "" {
+				return "", nil, false, fmt.Errorf("%w: %s compared with %s", ErrNotFilterable, c.Attribute, c.ValueFrom)
+			}
+			if !compare(c.Operator, lookup(c.Attribute, subject, nil), expected) {
+				return "", nil, false, nil
+			}
+			continue
+		}
+
+		column, ok := columns[strings.TrimPrefix(c.Attribute, ResourcePrefix)]
+		if !ok {
+			return "", nil, false, fmt.Errorf("%w: %s has no column", ErrNotFilterable, c.Attribute)
+		}
+		if expectedColumn != "" {
+			parts = append(parts, columnComparison(c.Operator, column, expectedColumn))
+			continue
+		}
+		part, partArgs, ok := valueComparison(c.Operator, column, expected)
+		if !ok {
+			return "", nil, false, nil
+		}
+		if part != "" {
+			parts = append(parts, part)
+			args = append(args, partArgs...)
+		}
+	}
+	if len(parts) == 0 {
+		return "1 = 1", nil, true, nil
+	}
+	return "(" + strings.Join(parts, " AND ") + ")", args, true, nil
+}
+
+// valueComparison compares a column with a value. A missing column value only
+// satisfies ne and not_in, as in compare. ok is false when the comparison can never
+// hold, and part is empty when it always holds.
+func valueComparison(op, column string, value interface{}) (part string, args []interface{}, ok bool) {
+	if value == nil {
+		return "", nil, op == OpNe || op == OpNotIn
+	}
+	switch op {
+	case OpEq:
+		return column + " = ?", []interface{}{value}, true
+	case OpNe:
+		return "(" + column + " IS NULL OR " + column + " <> ?)", []interface{}{value}, true
+	case OpLt:
+		return column + " < ?", []interface{}{value}, true
+	case OpLte:
+		return column + " <= ?", []interface{}{value}, true
+	case OpGt:
+		return column + " > ?", []interface{}{value}, true
+	case OpGte:
+		return column + " >= ?", []interface{}{value}, true
+	case OpIn, OpNotIn:
+		values, _ := toList(value)
+		if len(values) == 0 {
+			return "", nil, op == OpNotIn
+		}
+		placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(values)), ", ")
+		if op == OpIn {
+			return column + " IN (" + placeholders + ")", values, true
+		}
+		return "(" + column + " IS NULL OR " + column + " NOT IN (" + placeholders + "))", values, true
+	}
+	return "", nil, false
+}
+
+// columnComparison compares two columns of the same row
+func columnComparison(op, column, other string) string {
+	switch op {
+	case OpNe:
+		return "(" + column + " IS NULL OR " + column + " <> " + other + ")"
+	case OpLt:
+		return column + " < " + other
+	case OpLte:
+		return column + " <= " + other
+	case OpGt:
+		return column + " > " + other
+	case OpGte:
+		return column + " >= " + other
+	}
+	return column + " = " + other
+}
+
+// attributes returns the subject's attributes under their subject.* names
+func (s *Subject) attributes() Attributes {
+	attributes := Attributes{
+		"user_id":  s.UserID,
+		"username": s.Username,
+		"roles":    s.Roles,
+	}
+	for name, value := range s.Attributes {
+		if _, ok := attributes[name]; !ok {
+			attributes[name] = value
+		}
+	}
+	return attributes
+}
+
+// lookup returns a subject.* or resource.* attribute, or nil when it is not set
+func lookup(name string, subject, resource Attributes) interface{} {
+	if attribute, ok := strings.CutPrefix(name, SubjectPrefix); ok {
+		return subject[attribute]
+	}
+	if attribute, ok := strings.CutPrefix(name, ResourcePrefix); ok {
+		return resource[attribute]
+	}
+	return nil
+}
+
+func validEffect(effect string) bool {
+	return effect == EffectAllow || effect == EffectDeny
+}
+
+func validAttribute(name string) bool {
+	return (strings.HasPrefix(name, SubjectPrefix) && len(name) > len(SubjectPrefix)) ||
+		(strings.HasPrefix(name, ResourcePrefix) && len(name) > len(ResourcePrefix))
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
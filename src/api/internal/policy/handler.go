@@ -0,0 +1,76 @@
+package policy
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// PolicyHandler handles HTTP requests for attribute-based policies
+type PolicyHandler struct {
+	service *Service
+}
+
+// NewPolicyHandler creates a new PolicyHandler
+func NewPolicyHandler(service *Service) *PolicyHandler {
+	return &PolicyHandler{service: service}
+}
+
+// GetPoliciesHandler godoc
+// @Summary Get the policies in use
+// @Description Get the attribute-based policies loaded from the policy file
+// @Tags policies
+// @Produce json
+// @Success 200 {object} PolicySet
+// @Router /policies [get]
+func (h *PolicyHandler) GetPoliciesHandler(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, http.StatusOK, h.service.Policies(), "", auth.GetRequestID(r))
+}
+
+// ExplainHandler godoc
+// @Summary Explain a policy decision
+// @Description Dry-run an action on a resource with the given attributes and return the decision
+// @Description with the evaluation of every policy and condition. user_id defaults to the caller.
+// @Tags policies
+// @Accept json
+// @Produce json
+// @Param request body ExplainRequest true "Request to evaluate"
+// @Success 200 {object} Explanation
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /policies/explain [post]
+func (h *PolicyHandler) ExplainHandler(w http.ResponseWriter, r *http.Request) {
+	var req ExplainRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", auth.GetRequestID(r))
+		return
+	}
+	if req.UserID == 0 {
+		subject, err := h.service.RequestSubject(r)
+		if err != nil {
+			writeError(w, http.StatusUnauthorized, err.Error(), auth.GetRequestID(r))
+			return
+		}
+		req.UserID = subject.UserID
+	}
+
+	explanation, err := h.service.Explain(req)
+	switch {
+	case errors.Is(err, ErrInvalidRequest):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+	case errors.Is(err, ErrSubjectNotFound):
+		writeError(w, http.StatusNotFound, err.Error(), auth.GetRequestID(r))
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to evaluate policies", auth.GetRequestID(r))
+	default:
+		writeSuccess(w, http.StatusOK, explanation, "", auth.GetRequestID(r))
+	}
+}
+
+// RegisterRoutes registers the policy routes with the given router
+func (h *PolicyHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/policies", h.GetPoliciesHandler)
+	r.Post("/policies/explain", h.ExplainHandler)
+}
@@ -0,0 +1,143 @@
+package policy
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"api/internal/handler"
+)
+
+// compare applies an operator. A missing value (nil) equals nothing, so it only
+// satisfies ne and not_in. Numbers compare by value, anything else by its text,
+// so a user ID of 7 equals an applicant ID of "7".
+func compare(op string, actual, expected interface{}) bool {
+	switch op {
+	case OpEq:
+		return equal(actual, expected)
+	case OpNe:
+		return !equal(actual, expected)
+	case OpLt, OpLte, OpGt, OpGte:
+		a, ok := toNumber(actual)
+		if !ok {
+			return false
+		}
+		b, ok := toNumber(expected)
+		if !ok {
+			return false
+		}
+		switch op {
+		case OpLt:
+			return a < b
+		case OpLte:
+			return a <= b
+		case OpGt:
+			return a > b
+		}
+		return a >= b
+	case OpIn:
+		return in(actual, expected)
+	case OpNotIn:
+		return !in(actual, expected)
+	}
+	return false
+}
+
+// in reports whether actual, or any of its items when it is a list such as roles, is in expected
+func in(actual, expected interface{}) bool {
+	values, ok := toList(expected)
+	if !ok {
+		return false
+	}
+	items, ok := toList(actual)
+	if !ok {
+		items = []interface{}{actual}
+	}
+	for _, item := range items {
+		for _, value := range values {
+			if equal(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func equal(a, b interface{}) bool {
+	if a == nil || b == nil {
+		return false
+	}
+	if x, ok := toNumber(a); ok {
+		if y, ok := toNumber(b); ok {
+			return x == y
+		}
+	}
+	return fmt.Sprint(a) == fmt.Sprint(b)
+}
+
+func toNumber(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint:
+		return float64(n), true
+	case uint32:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toList(v interface{}) ([]interface{}, bool) {
+	switch list := v.(type) {
+	case []interface{}:
+		return list, true
+	case []string:
+		values := make([]interface{}, len(list))
+		for i, s := range list {
+			values[i] = s
+		}
+		return values, true
+	}
+	return nil, false
+}
+
+func writeError(w http.ResponseWriter, code int, message string, requestID string) {
+	resp := handler.NewErrorResponse(
+		code,
+		http.StatusText(code),
+		"POLICY_ERROR",
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeSuccess(w http.ResponseWriter, code int, data interface{}, message string, requestID string) {
+	resp := handler.NewSuccessResponse(
+		code,
+		http.StatusText(code),
+		data,
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
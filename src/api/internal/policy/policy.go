@@ -0,0 +1,115 @@
+package policy
+
+import (
+	"api/config"
+	"api/internal/auth"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// Policy errors
+var (
+	ErrSubjectNotFound = errors.New("user not found")
+	ErrUnauthenticated = errors.New("authentication required")
+	ErrInvalidRequest  = errors.New("resource and action are required")
+)
+
+// Service resolves subjects and evaluates policies for handlers and repository queries
+type Service struct {
+	engine *Engine
+	repo   *PolicyRepo
+	roles  *auth.RoleRepo
+	users  *auth.UserRepo
+}
+
+// NewService creates a Service that watches the configured policy file.
+// When the file cannot be loaded only super admins are allowed until it is fixed.
+func NewService() *Service {
+	engine := NewEngine(&PolicySet{DefaultEffect: EffectDeny})
+	if cfg := config.NewConfig(); cfg != nil {
+		if err := engine.WatchPolicies(cfg.PolicyFile); err != nil {
+			log.Printf("[error] - Policies not loaded: %v", err)
+		}
+	}
+	return NewServiceWithEngine(engine, NewPolicyRepo(), auth.NewRoleRepo(), auth.NewUserRepo())
+}
+
+// NewServiceWithEngine creates a Service with the given engine and repositories
+func NewServiceWithEngine(engine *Engine, repo *PolicyRepo, roles *auth.RoleRepo, users *auth.UserRepo) *Service {
+	return &Service{engine: engine, repo: repo, roles: roles, users: users}
+}
+
+// Policies returns the policies currently in use
+func (s *Service) Policies() *PolicySet {
+	return s.engine.Policies()
+}
+
+// Subject loads a user with their active roles and attributes
+func (s *Service) Subject(userID int) (*Subject, error) {
+	user, err := s.users.GetUserByID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrSubjectNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	roles, err := s.roles.GetRolesByUserID(userID)
+	if err != nil {
+		return nil, err
+	}
+	attributes, err := s.repo.GetUserAttributes(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := &Subject{UserID: user.UserID, Username: user.Username, Roles: []string{}, Attributes: attributes}
+	for _, role := range roles {
+		if role.StatusID != 1 {
+			continue
+		}
+		subject.Roles = append(subject.Roles, role.RoleName)
+		subject.SuperAdmin = subject.SuperAdmin || role.IsSuperAdmin
+	}
+	return subject, nil
+}
+
+// RequestSubject loads the subject of a request authenticated by API key or access token
+func (s *Service) RequestSubject(r *http.Request) (*Subject, error) {
+	userID, _, ok := auth.Principal(r)
+	if !ok {
+		return nil, ErrUnauthenticated
+	}
+	return s.Subject(userID)
+}
+
+// Check evaluates an action on a resource with the given attributes
+func (s *Service) Check(subject *Subject, resource, action string, attributes Attributes) Decision {
+	return s.engine.Evaluate(Request{Subject: subject, Resource: resource, Action: action, Attributes: attributes})
+}
+
+// Filter returns the WHERE clause limiting a query on resource to the rows subject may perform action on
+func (s *Service) Filter(subject *Subject, resource, action string, columns map[string]string) (*SQLFilter, error) {
+	return s.engine.Filter(subject, resource, action, columns)
+}
+
+// Explain evaluates a request without performing it, with the evaluation of every policy
+func (s *Service) Explain(req ExplainRequest) (*Explanation, error) {
+	req.Resource = strings.TrimSpace(req.Resource)
+	req.Action = strings.TrimSpace(req.Action)
+	if req.Resource == "" || req.Action == "" {
+		return nil, ErrInvalidRequest
+	}
+	subject, err := s.Subject(req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	return &Explanation{
+		Subject:  subject,
+		Resource: req.Resource,
+		Action:   req.Action,
+		Decision: s.Check(subject, req.Resource, req.Action, req.Attributes),
+	}, nil
+}
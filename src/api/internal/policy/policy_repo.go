@@ -0,0 +1,54 @@
+package policy
+
+import (
+	"api/internal/db"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// PolicyRepo represents the repository for the user attributes policies are evaluated on
+type PolicyRepo struct {
+	DB *db.DB
+}
+
+// NewPolicyRepo creates a new instance of PolicyRepo
+func NewPolicyRepo() *PolicyRepo {
+	db := db.NewDB()
+	return &PolicyRepo{DB: db}
+}
+
+// GetUserAttributes returns the attributes of a user, such as branch
+func (pr *PolicyRepo) GetUserAttributes(userID int) (Attributes, error) {
+	rows, err := pr.DB.Query(`SELECT name, value FROM user_attributes WHERE user_id = ?`, userID)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user attributes: %w", err)
+	}
+	defer rows.Close()
+
+	attributes := make(Attributes)
+	for rows.Next() {
+		var name, value string
+		if err := rows.Scan(&name, &value); err != nil {
+			return nil, fmt.Errorf("error scanning user attribute: %w", err)
+		}
+		attributes[name] = value
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting user attributes: %w", err)
+	}
+	return attributes, nil
+}
+
+// SetUserAttribute sets an attribute of a user, replacing its previous value
+func (pr *PolicyRepo) SetUserAttribute(userID int, name, value string) error {
+	_, err := pr.DB.Insert(`
+		INSERT INTO user_attributes (user_id, name, value) VALUES (?, ?, ?)
+		ON CONFLICT (user_id, name) DO UPDATE SET value = excluded.value`,
+		userID, name, value,
+	)
+	if err != nil {
+		return fmt.Errorf("error setting user attribute: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,125 @@
+package policy
+
+// Policy effects
+const (
+	EffectAllow = "allow"
+	EffectDeny  = "deny"
+)
+
+// Condition operators
+const (
+	OpEq    = "eq"
+	OpNe    = "ne"
+	OpLt    = "lt"
+	OpLte   = "lte"
+	OpGt    = "gt"
+	OpGte   = "gte"
+	OpIn    = "in"
+	OpNotIn = "not_in"
+)
+
+// Attribute prefixes name the side of the request an attribute is read from
+const (
+	SubjectPrefix  = "subject."
+	ResourcePrefix = "resource."
+)
+
+// AnyAction matches every action of a resource
+const AnyAction = "*"
+
+// PolicySet is the policy configuration loaded from the policy file
+type PolicySet struct {
+	Version       string   `mapstructure:"version" json:"version"`
+	DefaultEffect string   `mapstructure:"default_effect" json:"default_effect"`
+	Policies      []Policy `mapstructure:"policies" json:"policies"`
+}
+
+// Policy allows or denies actions on a resource type when all its conditions hold.
+// With Roles set, it only applies to users holding one of them.
+type Policy struct {
+	Name        string      `mapstructure:"name" json:"name"`
+	Description string      `mapstructure:"description" json:"description,omitempty"`
+	Resource    string      `mapstructure:"resource" json:"resource"`
+	Actions     []string    `mapstructure:"actions" json:"actions"`
+	Roles       []string    `mapstructure:"roles" json:"roles,omitempty"`
+	Effect      string      `mapstructure:"effect" json:"effect"`
+	Conditions  []Condition `mapstructure:"conditions" json:"conditions,omitempty"`
+}
+
+// Condition compares an attribute with a literal value, or with another attribute named by ValueFrom
+type Condition struct {
+	Attribute string      `mapstructure:"attribute" json:"attribute"`
+	Operator  string      `mapstructure:"operator" json:"operator"`
+	Value     interface{} `mapstructure:"value" json:"value,omitempty"`
+	ValueFrom string      `mapstructure:"value_from" json:"value_from,omitempty"`
+}
+
+// Attributes are the named values of a subject or resource
+type Attributes map[string]interface{}
+
+// Subject is the user a decision is made for
+type Subject struct {
+	UserID     int        `json:"user_id"`
+	Username   string     `json:"username"`
+	Roles      []string   `json:"roles"`
+	SuperAdmin bool       `json:"super_admin"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Request is an action a subject wants to perform on a resource
+type Request struct {
+	Subject    *Subject   `json:"subject"`
+	Resource   string     `json:"resource"`
+	Action     string     `json:"action"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Decision is the outcome of evaluating a request, with the evaluation of every policy
+type Decision struct {
+	Allowed     bool               `json:"allowed"`
+	Effect      string             `json:"effect"`
+	Policy      string             `json:"policy,omitempty"`
+	Reason      string             `json:"reason"`
+	Evaluations []PolicyEvaluation `json:"evaluations,omitempty"`
+}
+
+// PolicyEvaluation explains how one policy was evaluated
+type PolicyEvaluation struct {
+	Policy     string            `json:"policy"`
+	Effect     string            `json:"effect"`
+	Applicable bool              `json:"applicable"`
+	Matched    bool              `json:"matched"`
+	Reason     string            `json:"reason,omitempty"`
+	Conditions []ConditionResult `json:"conditions,omitempty"`
+}
+
+// ConditionResult explains how one condition was evaluated
+type ConditionResult struct {
+	Attribute string      `json:"attribute"`
+	Operator  string      `json:"operator"`
+	Actual    interface{} `json:"actual"`
+	Expected  interface{} `json:"expected"`
+	Matched   bool        `json:"matched"`
+}
+
+// SQLFilter is a WHERE clause restricting a query to the rows a subject may access
+type SQLFilter struct {
+	Where string        `json:"where"`
+	Args  []interface{} `json:"args"`
+}
+
+// ExplainRequest is a dry-run policy evaluation. UserID defaults to the caller.
+type ExplainRequest struct {
+	UserID     int        `json:"user_id"`
+	Resource   string     `json:"resource"`
+	Action     string     `json:"action"`
+	Attributes Attributes `json:"attributes"`
+}
+
+// Explanation is the result of a dry-run evaluation
+type Explanation struct {
+	Subject  *Subject `json:"subject"`
+	Resource string   `json:"resource"`
+	Action   string   `json:"action"`
+	Decision Decision `json:"decision"`
+}
@@ -0,0 +1,191 @@
+package reconcile
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// maxStatementSize limits uploaded statement files to 10 MB
+const maxStatementSize = 10 << 20
+
+// ReconcileHandler handles HTTP requests for bank statement reconciliation
+type ReconcileHandler struct {
+	reconciler *Reconciler
+}
+
+// NewReconcileHandler creates a new ReconcileHandler
+func NewReconcileHandler(reconciler *Reconciler) *ReconcileHandler {
+	return &ReconcileHandler{reconciler: reconciler}
+}
+
+// ImportStatementHandler godoc
+// @Summary Import a bank statement
+// @Description Upload a CSV or camt.053 statement as multipart field "file" or as the raw request body. Transactions are matched to payments and loan installments.
+// @Tags reconciliation
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file false "Statement file"
+// @Param format query string false "csv or camt.053 (detected when omitted)"
+// @Param file_name query string false "File name when sending the raw body"
+// @Success 201 {object} StatementImport
+// @Failure 400 {object} types.ErrorResponse
+// @Router /reconciliation/imports [post]
+func (h *ReconcileHandler) ImportStatementHandler(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxStatementSize)
+
+	var format Format
+	if value := r.URL.Query().Get("format"); value != "" {
+		parsed, err := ParseFormat(value)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+			return
+		}
+		format = parsed
+	}
+
+	var body io.Reader = r.Body
+	fileName := r.URL.Query().Get("file_name")
+	if strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		file, header, err := r.FormFile("file")
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "Statement file is required", auth.GetRequestID(r))
+			return
+		}
+		defer file.Close()
+		body = file
+		fileName = header.Filename
+	}
+
+	statement, err := h.reconciler.Import(r.Context(), fileName, format, body, auth.Username(r))
+	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		switch {
+		case errors.As(err, &maxBytesErr):
+			writeError(w, http.StatusRequestEntityTooLarge, "Statement file is too large", auth.GetRequestID(r))
+		case errors.Is(err, ErrUnknownFormat), errors.Is(err, ErrEmptyStatement):
+			writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		default:
+			writeError(w, http.StatusUnprocessableEntity, "Failed to import statement: "+err.Error(), auth.GetRequestID(r))
+		}
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, statement, "Statement imported successfully", auth.GetRequestID(r))
+}
+
+// GetLinesHandler godoc
+// @Summary List statement lines
+// @Description List imported statement lines, optionally by queue (MATCHED, SUGGESTED, UNMATCHED, CONFIRMED) and import
+// @Tags reconciliation
+// @Produce json
+// @Param status query string false "Queue status"
+// @Param import_id query string false "Import ID"
+// @Success 200 {array} StatementLine
+// @Router /reconciliation/lines [get]
+func (h *ReconcileHandler) GetLinesHandler(w http.ResponseWriter, r *http.Request) {
+	status := MatchStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	switch status {
+	case "", StatusMatched, StatusSuggested, StatusUnmatched, StatusConfirmed:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid status", auth.GetRequestID(r))
+		return
+	}
+
+	lines, err := h.reconciler.Lines(r.Context(), status, r.URL.Query().Get("import_id"))
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch statement lines", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, lines, "", auth.GetRequestID(r))
+}
+
+// ConfirmLineHandler godoc
+// @Summary Confirm a match
+// @Description Accept the matched or suggested payment/installment for a statement line
+// @Tags reconciliation
+// @Produce json
+// @Param id query string true "Line ID"
+// @Success 200 {object} StatementLine
+// @Failure 404 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /reconciliation/lines/confirm [post]
+func (h *ReconcileHandler) ConfirmLineHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Line ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	line, err := h.reconciler.Confirm(r.Context(), id, auth.Username(r))
+	switch {
+	case errors.Is(err, ErrLineNotFound):
+		writeError(w, http.StatusNotFound, "Statement line not found", auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrNothingToConfirm):
+		writeError(w, http.StatusConflict, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to confirm match", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, line, "Match confirmed successfully", auth.GetRequestID(r))
+}
+
+// OverrideLineHandler godoc
+// @Summary Override a match
+// @Description Assign a statement line to another payment or loan installment, or clear the match with an empty match_id
+// @Tags reconciliation
+// @Accept json
+// @Produce json
+// @Param id query string true "Line ID"
+// @Param request body OverrideRequest true "New match"
+// @Success 200 {object} StatementLine
+// @Failure 404 {object} types.ErrorResponse
+// @Router /reconciliation/lines/override [post]
+func (h *ReconcileHandler) OverrideLineHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		writeError(w, http.StatusBadRequest, "Line ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	var req OverrideRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+	req.MatchType = MatchType(strings.ToUpper(string(req.MatchType)))
+
+	line, err := h.reconciler.Override(r.Context(), id, req, auth.Username(r))
+	switch {
+	case errors.Is(err, ErrLineNotFound):
+		writeError(w, http.StatusNotFound, "Statement line not found", auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrCandidateNotFound):
+		writeError(w, http.StatusNotFound, err.Error(), auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrInvalidMatchType):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to override match", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, line, "Match updated successfully", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the reconciliation routes with the given router
+func (h *ReconcileHandler) RegisterRoutes(r *router.Router) {
+	r.Post("/reconciliation/imports", h.ImportStatementHandler)
+	r.Get("/reconciliation/lines", h.GetLinesHandler)
+	r.Post("/reconciliation/lines/confirm", h.ConfirmLineHandler)
+	r.Post("/reconciliation/lines/override", h.OverrideLineHandler)
+}
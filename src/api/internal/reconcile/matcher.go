@@ -0,0 +1,104 @@
+package reconcile
+
+import (
+	"math"
+	"strings"
+	"time"
+)
+
+// Match scores; an automatic match needs reference, amount and date to agree
+const (
+	scoreReference = 50
+	scoreAmount    = 30
+	scoreDate      = 20
+
+	matchThreshold   = scoreReference + scoreAmount + scoreDate
+	suggestThreshold = scoreAmount + scoreDate
+)
+
+// amountTolerance absorbs floating point rounding on amounts
+const amountTolerance = 0.005
+
+// Matcher matches statement transactions to payments and loan installments
+type Matcher struct {
+	DateTolerance time.Duration
+}
+
+// NewMatcher creates a Matcher that accepts booking dates within the given number of days
+func NewMatcher(toleranceDays int) *Matcher {
+	return &Matcher{DateTolerance: time.Duration(toleranceDays) * 24 * time.Hour}
+}
+
+// Match finds the best candidate for a transaction.
+// A unique candidate agreeing on reference, amount and date is MATCHED; weaker or ambiguous hits are SUGGESTED.
+func (m *Matcher) Match(txn Transaction, candidates []Candidate) MatchResult {
+	best := MatchResult{Status: StatusUnmatched}
+	ties := 0
+
+	for i := range candidates {
+		score := m.Score(txn, candidates[i])
+		if score < suggestThreshold {
+			continue
+		}
+		switch {
+		case score > best.Score:
+			best.Score = score
+			best.Candidate = &candidates[i]
+			ties = 0
+		case score == best.Score:
+			ties++
+		}
+	}
+
+	switch {
+	case best.Candidate == nil:
+		best.Status = StatusUnmatched
+	case best.Score >= matchThreshold && ties == 0:
+		best.Status = StatusMatched
+	default:
+		best.Status = StatusSuggested
+	}
+	return best
+}
+
+// Score rates how well a candidate fits a transaction
+func (m *Matcher) Score(txn Transaction, candidate Candidate) int {
+	score := 0
+	if referenceMatches(txn, candidate) {
+		score += scoreReference
+	}
+	if math.Abs(math.Abs(txn.Amount)-candidate.Amount) < amountTolerance {
+		score += scoreAmount
+	}
+	if !candidate.Date.IsZero() && absDuration(txn.BookingDate.Sub(candidate.Date)) <= m.DateTolerance {
+		score += scoreDate
+	}
+	return score
+}
+
+// referenceMatches reports whether the transaction reference or description mentions the candidate
+func referenceMatches(txn Transaction, candidate Candidate) bool {
+	text := normalizeReference(txn.Reference + " " + txn.Description)
+	if text == "" {
+		return false
+	}
+	for _, ref := range []string{candidate.ID, candidate.Reference} {
+		ref = normalizeReference(ref)
+		if len(ref) >= 4 && strings.Contains(text, ref) {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeReference upper-cases a reference and drops spaces so "loan 001" matches "LOAN001"
+func normalizeReference(value string) string {
+	return strings.ToUpper(strings.Join(strings.Fields(value), ""))
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
@@ -0,0 +1,271 @@
+package reconcile
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrUnknownFormat is returned when a statement format cannot be determined
+var ErrUnknownFormat = errors.New("unknown statement format")
+
+// dateLayouts are the booking date formats accepted in CSV statements
+var dateLayouts = []string{
+	"2006-01-02",
+	"2006-01-02T15:04:05",
+	time.RFC3339,
+	"02/01/2006",
+	"02-01-2006",
+	"20060102",
+}
+
+// csvColumns maps the accepted CSV header names to transaction fields
+var csvColumns = map[string]string{
+	"date":             "date",
+	"booking_date":     "date",
+	"value_date":       "date",
+	"amount":           "amount",
+	"currency":         "currency",
+	"ccy":              "currency",
+	"reference":        "reference",
+	"ref":              "reference",
+	"end_to_end_id":    "reference",
+	"description":      "description",
+	"details":          "description",
+	"remittance":       "description",
+	"counterparty":     "counterparty",
+	"name":             "counterparty",
+	"bank_reference":   "bank_reference",
+	"transaction_id":   "bank_reference",
+	"credit_debit":     "credit_debit",
+	"credit_debit_ind": "credit_debit",
+}
+
+// DetectFormat determines the statement format from the file name, falling back to the content
+func DetectFormat(fileName string, content []byte) (Format, error) {
+	switch strings.ToLower(filepath.Ext(fileName)) {
+	case ".csv":
+		return FormatCSV, nil
+	case ".xml", ".053":
+		return FormatCAMT053, nil
+	}
+
+	trimmed := bytes.TrimSpace(content)
+	if bytes.HasPrefix(trimmed, []byte("<")) {
+		return FormatCAMT053, nil
+	}
+	if len(trimmed) > 0 {
+		return FormatCSV, nil
+	}
+	return "", ErrUnknownFormat
+}
+
+// ParseFormat parses a format name such as "csv" or "camt.053"
+func ParseFormat(value string) (Format, error) {
+	switch strings.ToUpper(strings.ReplaceAll(value, ".", "")) {
+	case "CSV":
+		return FormatCSV, nil
+	case "CAMT053", "CAMT", "XML":
+		return FormatCAMT053, nil
+	}
+	return "", fmt.Errorf("%w: %s", ErrUnknownFormat, value)
+}
+
+// Parse parses statement content in the given format
+func Parse(format Format, r io.Reader) ([]Transaction, error) {
+	switch format {
+	case FormatCSV:
+		return ParseCSV(r)
+	case FormatCAMT053:
+		return ParseCAMT053(r)
+	}
+	return nil, ErrUnknownFormat
+}
+
+// ParseCSV parses a CSV statement with a header row.
+// Required columns are date and amount; debits may be given as negative amounts or with a credit_debit column.
+func ParseCSV(r io.Reader) ([]Transaction, error) {
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV header: %w", err)
+	}
+
+	columns := make(map[string]int)
+	for i, name := range header {
+		key := strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		if field, ok := csvColumns[key]; ok {
+			if _, exists := columns[field]; !exists {
+				columns[field] = i
+			}
+		}
+	}
+	if _, ok := columns["date"]; !ok {
+		return nil, errors.New("CSV statement is missing a date column")
+	}
+	if _, ok := columns["amount"]; !ok {
+		return nil, errors.New("CSV statement is missing an amount column")
+	}
+
+	get := func(record []string, field string) string {
+		if i, ok := columns[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	var transactions []Transaction
+	for line := 2; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading CSV line %d: %w", line, err)
+		}
+
+		date, err := parseDate(get(record, "date"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid date on line %d: %w", line, err)
+		}
+		amount, err := parseAmount(get(record, "amount"))
+		if err != nil {
+			return nil, fmt.Errorf("invalid amount on line %d: %w", line, err)
+		}
+		if strings.EqualFold(get(record, "credit_debit"), "DBIT") || strings.EqualFold(get(record, "credit_debit"), "D") {
+			amount = -absAmount(amount)
+		}
+
+		transactions = append(transactions, Transaction{
+			BankReference: get(record, "bank_reference"),
+			BookingDate:   date,
+			Amount:        amount,
+			Currency:      strings.ToUpper(get(record, "currency")),
+			Reference:     get(record, "reference"),
+			Description:   get(record, "description"),
+			Counterparty:  get(record, "counterparty"),
+		})
+	}
+	return transactions, nil
+}
+
+// camtDocument is the subset of a camt.053 BankToCustomerStatement used for reconciliation
+type camtDocument struct {
+	Statements []struct {
+		Entries []camtEntry `xml:"Ntry"`
+	} `xml:"BkToCstmrStmt>Stmt"`
+}
+
+type camtEntry struct {
+	Amount struct {
+		Value    string `xml:",chardata"`
+		Currency string `xml:"Ccy,attr"`
+	} `xml:"Amt"`
+	CreditDebit    string `xml:"CdtDbtInd"`
+	BookingDate    string `xml:"BookgDt>Dt"`
+	BookingTime    string `xml:"BookgDt>DtTm"`
+	ValueDate      string `xml:"ValDt>Dt"`
+	ServicerRef    string `xml:"AcctSvcrRef"`
+	AdditionalInfo string `xml:"AddtlNtryInf"`
+	Details        []struct {
+		EndToEndID   string   `xml:"Refs>EndToEndId"`
+		InstrID      string   `xml:"Refs>InstrId"`
+		Unstructured []string `xml:"RmtInf>Ustrd"`
+		CreditorRef  string   `xml:"RmtInf>Strd>CdtrRefInf>Ref"`
+		DebtorName   string   `xml:"RltdPties>Dbtr>Nm"`
+		CreditorName string   `xml:"RltdPties>Cdtr>Nm"`
+	} `xml:"NtryDtls>TxDtls"`
+}
+
+// ParseCAMT053 parses an ISO 20022 camt.053 bank-to-customer statement
+func ParseCAMT053(r io.Reader) ([]Transaction, error) {
+	var doc camtDocument
+	if err := xml.NewDecoder(r).Decode(&doc); err != nil {
+		return nil, fmt.Errorf("error decoding camt.053: %w", err)
+	}
+
+	var transactions []Transaction
+	for _, statement := range doc.Statements {
+		for i, entry := range statement.Entries {
+			amount, err := parseAmount(entry.Amount.Value)
+			if err != nil {
+				return nil, fmt.Errorf("invalid amount in entry %d: %w", i+1, err)
+			}
+			if entry.CreditDebit == "DBIT" {
+				amount = -amount
+			}
+
+			dateValue := firstNonEmpty(entry.BookingDate, entry.BookingTime, entry.ValueDate)
+			date, err := parseDate(dateValue)
+			if err != nil {
+				return nil, fmt.Errorf("invalid booking date in entry %d: %w", i+1, err)
+			}
+
+			txn := Transaction{
+				BankReference: entry.ServicerRef,
+				BookingDate:   date,
+				Amount:        amount,
+				Currency:      entry.Amount.Currency,
+				Description:   entry.AdditionalInfo,
+			}
+			if len(entry.Details) > 0 {
+				details := entry.Details[0]
+				reference := firstNonEmpty(details.CreditorRef, details.EndToEndID, details.InstrID)
+				if reference == "NOTPROVIDED" {
+					reference = ""
+				}
+				txn.Reference = reference
+				if len(details.Unstructured) > 0 {
+					txn.Description = strings.Join(details.Unstructured, " ")
+				}
+				if amount >= 0 {
+					txn.Counterparty = details.DebtorName
+				} else {
+					txn.Counterparty = details.CreditorName
+				}
+			}
+			transactions = append(transactions, txn)
+		}
+	}
+	return transactions, nil
+}
+
+func parseDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognised date %q", value)
+}
+
+func parseAmount(value string) (float64, error) {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ",", "")
+	return strconv.ParseFloat(value, 64)
+}
+
+func absAmount(amount float64) float64 {
+	if amount < 0 {
+		return -amount
+	}
+	return amount
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, value := range values {
+		if strings.TrimSpace(value) != "" {
+			return strings.TrimSpace(value)
+		}
+	}
+	return ""
+}
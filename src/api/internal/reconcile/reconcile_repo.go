@@ -0,0 +1,312 @@
+package reconcile
+
+import (
+	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// ReconcileRepo represents the repository for bank statement reconciliation
+type ReconcileRepo struct {
+	DB *db.DB
+}
+
+// NewReconcileRepo creates a new instance of ReconcileRepo
+func NewReconcileRepo() *ReconcileRepo {
+	db := db.NewDB()
+	return &ReconcileRepo{DB: db}
+}
+
+// lineColumns lists the statement line columns in the order scanLine reads them
+const lineColumns = `
+	line_id, import_id, bank_reference, booking_date, amount, currency,
+	reference, description, counterparty, status, match_type, match_id,
+	score, reviewed_by, reviewed_at, created_at`
+
+// Columns written on insert; the scope adds tenant_id
+var (
+	insertImportColumns = []string{
+		"import_id", "file_name", "format", "line_count", "matched", "suggested",
+		"unmatched", "created_at", "created_by",
+	}
+	insertLineColumns = []string{
+		"line_id", "import_id", "bank_reference", "booking_date", "amount", "currency",
+		"reference", "description", "counterparty", "status", "match_type", "match_id",
+		"score", "created_at",
+	}
+)
+
+// scope returns the statements and payments of the tenant of ctx
+func (rr *ReconcileRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, rr.DB.Connection)
+}
+
+// InsertImport stores an imported statement of the tenant of ctx and its lines in a single transaction
+func (rr *ReconcileRepo) InsertImport(ctx context.Context, statement *StatementImport, lines []StatementLine) error {
+	tx, err := rr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scoped, err := tenant.Scope(ctx, tx)
+	if err != nil {
+		return err
+	}
+	_, err = scoped.Insert("statement_imports", insertImportColumns,
+		statement.ImportID,
+		statement.FileName,
+		statement.Format,
+		statement.LineCount,
+		statement.Matched,
+		statement.Suggested,
+		statement.Unmatched,
+		statement.CreatedAt,
+		statement.CreatedBy,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting statement import: %w", err)
+	}
+
+	for _, line := range lines {
+		_, err = scoped.Insert("statement_lines", insertLineColumns,
+			line.LineID,
+			line.ImportID,
+			line.BankReference,
+			line.BookingDate,
+			line.Amount,
+			line.Currency,
+			line.Reference,
+			line.Description,
+			line.Counterparty,
+			line.Status,
+			line.MatchType,
+			line.MatchID,
+			line.Score,
+			line.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting statement line: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetLines retrieves the tenant's statement lines, optionally filtered by queue status and import
+func (rr *ReconcileRepo) GetLines(ctx context.Context, status MatchStatus, importID string) ([]StatementLine, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var conditions []string
+	var args []interface{}
+	if status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, status)
+	}
+	if importID != "" {
+		conditions = append(conditions, "import_id = ?")
+		args = append(args, importID)
+	}
+
+	rows, err := scoped.Select("statement_lines", lineColumns, strings.Join(conditions, " AND "), "booking_date, line_id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying statement lines: %w", err)
+	}
+	defer rows.Close()
+
+	var lines []StatementLine
+	for rows.Next() {
+		line, err := scanLine(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning statement line: %w", err)
+		}
+		lines = append(lines, *line)
+	}
+	return lines, rows.Err()
+}
+
+// GetLine retrieves a statement line of the tenant by its id; lines of other tenants are not found
+func (rr *ReconcileRepo) GetLine(ctx context.Context, lineID string) (*StatementLine, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("statement_lines", lineColumns, "line_id = ?", lineID)
+	if err != nil {
+		return nil, err
+	}
+	return scanLine(row)
+}
+
+// UpdateLineMatch records a reviewer's decision on a statement line of the tenant
+func (rr *ReconcileRepo) UpdateLineMatch(ctx context.Context, line *StatementLine) error {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return err
+	}
+	_, err = scoped.Update("statement_lines",
+		"status = ?, match_type = ?, match_id = ?, score = ?, reviewed_by = ?, reviewed_at = ?",
+		"line_id = ?",
+		line.Status,
+		line.MatchType,
+		line.MatchID,
+		line.Score,
+		line.ReviewedBy,
+		line.ReviewedAt,
+		line.LineID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating statement line: %w", err)
+	}
+	return nil
+}
+
+// GetPaymentCandidates returns the tenant's payments that are not yet reconciled against a statement line
+func (rr *ReconcileRepo) GetPaymentCandidates(ctx context.Context) ([]Candidate, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := scoped.Select("payments",
+		"payment_id, amount, COALESCE(payment_date, ''), COALESCE(note, '')",
+		`payment_id NOT IN (
+			SELECT match_id FROM statement_lines
+			WHERE match_type = ? AND status IN (?, ?)
+		)`, "",
+		MatchPayment, StatusMatched, StatusConfirmed,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying payment candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []Candidate
+	for rows.Next() {
+		var candidate Candidate
+		var paymentDate string
+		if err := rows.Scan(&candidate.ID, &candidate.Amount, &paymentDate, &candidate.Reference); err != nil {
+			return nil, fmt.Errorf("error scanning payment candidate: %w", err)
+		}
+		candidate.Type = MatchPayment
+		candidate.Date, _ = parseDate(paymentDate)
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// tenantLoans restricts payment_periods, which have no tenant of their own, to the loans of a tenant
+const tenantLoans = "loan_id IN (SELECT id FROM loan_applications WHERE tenant_id = ?)"
+
+// GetInstallmentCandidates returns the unpaid loan installments of the tenant that are not yet reconciled
+func (rr *ReconcileRepo) GetInstallmentCandidates(ctx context.Context) ([]Candidate, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rows, err := rr.DB.Query(`
+		SELECT id, loan_id, amount, due_date
+		FROM payment_periods
+		WHERE status <> 'PAID'
+			AND id NOT IN (
+				SELECT match_id FROM statement_lines
+				WHERE match_type = ? AND status IN (?, ?)
+			)
+			AND `+tenantLoans,
+		MatchLoanInstallment, StatusMatched, StatusConfirmed, scoped.TenantID(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error querying installment candidates: %w", err)
+	}
+	defer rows.Close()
+
+	var candidates []Candidate
+	for rows.Next() {
+		var candidate Candidate
+		if err := rows.Scan(&candidate.ID, &candidate.Reference, &candidate.Amount, &candidate.Date); err != nil {
+			return nil, fmt.Errorf("error scanning installment candidate: %w", err)
+		}
+		candidate.Type = MatchLoanInstallment
+		candidates = append(candidates, candidate)
+	}
+	return candidates, rows.Err()
+}
+
+// GetCandidate returns a single payment or loan installment of the tenant by type and id
+func (rr *ReconcileRepo) GetCandidate(ctx context.Context, matchType MatchType, id string) (*Candidate, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	candidate := Candidate{Type: matchType, ID: id}
+	switch matchType {
+	case MatchPayment:
+		row, err := scoped.SelectRow("payments",
+			"amount, COALESCE(payment_date, ''), COALESCE(note, '')", "payment_id = ?", id)
+		if err != nil {
+			return nil, err
+		}
+		var paymentDate string
+		if err := row.Scan(&candidate.Amount, &paymentDate, &candidate.Reference); err != nil {
+			return nil, err
+		}
+		candidate.Date, _ = parseDate(paymentDate)
+	case MatchLoanInstallment:
+		row, err := rr.DB.QueryRow(`
+			SELECT loan_id, amount, due_date
+			FROM payment_periods WHERE id = ? AND `+tenantLoans, id, scoped.TenantID())
+		if err != nil {
+			return nil, err
+		}
+		if err := row.Scan(&candidate.Reference, &candidate.Amount, &candidate.Date); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, sql.ErrNoRows
+	}
+	return &candidate, nil
+}
+
+// scanner is implemented by both *sql.Row and *sql.Rows
+type scanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanLine(row scanner) (*StatementLine, error) {
+	var line StatementLine
+	var reviewedBy sql.NullString
+	var reviewedAt sql.NullTime
+	err := row.Scan(
+		&line.LineID,
+		&line.ImportID,
+		&line.BankReference,
+		&line.BookingDate,
+		&line.Amount,
+		&line.Currency,
+		&line.Reference,
+		&line.Description,
+		&line.Counterparty,
+		&line.Status,
+		&line.MatchType,
+		&line.MatchID,
+		&line.Score,
+		&reviewedBy,
+		&reviewedAt,
+		&line.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	line.ReviewedBy = reviewedBy.String
+	if reviewedAt.Valid {
+		t := reviewedAt.Time
+		line.ReviewedAt = &t
+	}
+	return &line, nil
+}
@@ -0,0 +1,205 @@
+package reconcile
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Reconciliation errors
+var (
+	ErrLineNotFound      = errors.New("statement line not found")
+	ErrCandidateNotFound = errors.New("payment or loan installment not found")
+	ErrNothingToConfirm  = errors.New("statement line has no match to confirm")
+	ErrInvalidMatchType  = errors.New("invalid match type")
+	ErrEmptyStatement    = errors.New("statement contains no transactions")
+)
+
+// Reconciler imports bank statements and matches their transactions
+type Reconciler struct {
+	repo    *ReconcileRepo
+	matcher *Matcher
+	now     func() time.Time
+}
+
+// NewReconciler creates a new Reconciler with the given date tolerance in days
+func NewReconciler(toleranceDays int) *Reconciler {
+	return NewReconcilerWithRepo(NewReconcileRepo(), toleranceDays)
+}
+
+// NewReconcilerWithRepo creates a Reconciler on the given repository
+func NewReconcilerWithRepo(repo *ReconcileRepo, toleranceDays int) *Reconciler {
+	return &Reconciler{
+		repo:    repo,
+		matcher: NewMatcher(toleranceDays),
+		now:     time.Now,
+	}
+}
+
+// Import parses a statement file, matches every transaction against the payments and
+// installments of the tenant of ctx and stores the results for that tenant.
+// When format is empty it is detected from the file name and content.
+func (rc *Reconciler) Import(ctx context.Context, fileName string, format Format, r io.Reader, createdBy string) (*StatementImport, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error reading statement: %w", err)
+	}
+	if format == "" {
+		if format, err = DetectFormat(fileName, content); err != nil {
+			return nil, err
+		}
+	}
+
+	transactions, err := Parse(format, bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if len(transactions) == 0 {
+		return nil, ErrEmptyStatement
+	}
+
+	candidates, err := rc.loadCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statement := &StatementImport{
+		ImportID:  uuid.New().String(),
+		FileName:  fileName,
+		Format:    format,
+		LineCount: len(transactions),
+		CreatedAt: rc.now(),
+		CreatedBy: createdBy,
+	}
+
+	lines := make([]StatementLine, 0, len(transactions))
+	for _, txn := range transactions {
+		result := rc.matcher.Match(txn, candidates)
+		line := StatementLine{
+			LineID:      uuid.New().String(),
+			ImportID:    statement.ImportID,
+			Transaction: txn,
+			Status:      result.Status,
+			Score:       result.Score,
+			CreatedAt:   statement.CreatedAt,
+		}
+		if result.Candidate != nil {
+			line.MatchType = result.Candidate.Type
+			line.MatchID = result.Candidate.ID
+		}
+
+		switch result.Status {
+		case StatusMatched:
+			statement.Matched++
+			// A record can only be matched once, so later lines cannot claim it
+			candidates = removeCandidate(candidates, *result.Candidate)
+		case StatusSuggested:
+			statement.Suggested++
+		default:
+			statement.Unmatched++
+		}
+		lines = append(lines, line)
+	}
+
+	if err := rc.repo.InsertImport(ctx, statement, lines); err != nil {
+		return nil, err
+	}
+	return statement, nil
+}
+
+// Lines returns the tenant's statement lines in a queue; an empty status returns every line
+func (rc *Reconciler) Lines(ctx context.Context, status MatchStatus, importID string) ([]StatementLine, error) {
+	return rc.repo.GetLines(ctx, status, importID)
+}
+
+// Confirm accepts the match proposed for a statement line
+func (rc *Reconciler) Confirm(ctx context.Context, lineID, reviewedBy string) (*StatementLine, error) {
+	line, err := rc.getLine(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+	if line.MatchID == "" {
+		return nil, ErrNothingToConfirm
+	}
+
+	return rc.review(ctx, line, StatusConfirmed, reviewedBy)
+}
+
+// Override assigns a statement line to another payment or installment.
+// An empty match id clears the match and moves the line to the unmatched queue.
+func (rc *Reconciler) Override(ctx context.Context, lineID string, req OverrideRequest, reviewedBy string) (*StatementLine, error) {
+	line, err := rc.getLine(ctx, lineID)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.MatchID == "" {
+		line.MatchType = ""
+		line.MatchID = ""
+		line.Score = 0
+		return rc.review(ctx, line, StatusUnmatched, reviewedBy)
+	}
+
+	if req.MatchType != MatchPayment && req.MatchType != MatchLoanInstallment {
+		return nil, ErrInvalidMatchType
+	}
+	candidate, err := rc.repo.GetCandidate(ctx, req.MatchType, req.MatchID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrCandidateNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	line.MatchType = candidate.Type
+	line.MatchID = candidate.ID
+	line.Score = rc.matcher.Score(line.Transaction, *candidate)
+	return rc.review(ctx, line, StatusConfirmed, reviewedBy)
+}
+
+func (rc *Reconciler) getLine(ctx context.Context, lineID string) (*StatementLine, error) {
+	line, err := rc.repo.GetLine(ctx, lineID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrLineNotFound
+	}
+	return line, err
+}
+
+func (rc *Reconciler) review(ctx context.Context, line *StatementLine, status MatchStatus, reviewedBy string) (*StatementLine, error) {
+	reviewedAt := rc.now()
+	line.Status = status
+	line.ReviewedBy = reviewedBy
+	line.ReviewedAt = &reviewedAt
+	if err := rc.repo.UpdateLineMatch(ctx, line); err != nil {
+		return nil, err
+	}
+	return line, nil
+}
+
+func (rc *Reconciler) loadCandidates(ctx context.Context) ([]Candidate, error) {
+	payments, err := rc.repo.GetPaymentCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	installments, err := rc.repo.GetInstallmentCandidates(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return append(payments, installments...), nil
+}
+
+func removeCandidate(candidates []Candidate, matched Candidate) []Candidate {
+	remaining := candidates[:0]
+	for _, candidate := range candidates {
+		if candidate.Type != matched.Type || candidate.ID != matched.ID {
+			remaining = append(remaining, candidate)
+		}
+	}
+	return remaining
+}
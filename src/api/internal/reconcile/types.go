@@ -0,0 +1,92 @@
+package reconcile
+
+import "time"
+
+// Format represents a bank statement file format
+type Format string
+
+const (
+	FormatCSV     Format = "CSV"
+	FormatCAMT053 Format = "CAMT053"
+)
+
+// MatchStatus represents the reconciliation queue a statement line is in
+type MatchStatus string
+
+const (
+	StatusMatched   MatchStatus = "MATCHED"
+	StatusSuggested MatchStatus = "SUGGESTED"
+	StatusUnmatched MatchStatus = "UNMATCHED"
+	StatusConfirmed MatchStatus = "CONFIRMED"
+)
+
+// MatchType represents the kind of record a statement line was matched to
+type MatchType string
+
+const (
+	MatchPayment         MatchType = "PAYMENT"
+	MatchLoanInstallment MatchType = "LOAN_INSTALLMENT"
+)
+
+// DefaultDateToleranceDays is how far a booking date may be from the expected date
+const DefaultDateToleranceDays = 3
+
+// Transaction is a single transaction parsed from a bank statement
+type Transaction struct {
+	BankReference string    `json:"bank_reference"`
+	BookingDate   time.Time `json:"booking_date"`
+	Amount        float64   `json:"amount" example:"1000.00"`
+	Currency      string    `json:"currency" example:"USD"`
+	Reference     string    `json:"reference" example:"LOAN-001"`
+	Description   string    `json:"description"`
+	Counterparty  string    `json:"counterparty"`
+}
+
+// Candidate is a payment or loan installment a transaction may be matched to
+type Candidate struct {
+	Type      MatchType `json:"type"`
+	ID        string    `json:"id"`
+	Amount    float64   `json:"amount"`
+	Date      time.Time `json:"date"`
+	Reference string    `json:"reference"`
+}
+
+// MatchResult is the outcome of matching one transaction against the candidates
+type MatchResult struct {
+	Status    MatchStatus `json:"status"`
+	Candidate *Candidate  `json:"candidate,omitempty"`
+	Score     int         `json:"score"`
+}
+
+// StatementImport records an imported statement file
+type StatementImport struct {
+	ImportID  string    `json:"import_id"`
+	FileName  string    `json:"file_name"`
+	Format    Format    `json:"format"`
+	LineCount int       `json:"line_count"`
+	Matched   int       `json:"matched"`
+	Suggested int       `json:"suggested"`
+	Unmatched int       `json:"unmatched"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+}
+
+// StatementLine is an imported transaction and its place in the reconciliation queues
+type StatementLine struct {
+	LineID   string `json:"line_id"`
+	ImportID string `json:"import_id"`
+	Transaction
+	Status     MatchStatus `json:"status"`
+	MatchType  MatchType   `json:"match_type,omitempty"`
+	MatchID    string      `json:"match_id,omitempty"`
+	Score      int         `json:"score"`
+	ReviewedBy string      `json:"reviewed_by,omitempty"`
+	ReviewedAt *time.Time  `json:"reviewed_at,omitempty"`
+	CreatedAt  time.Time   `json:"created_at"`
+}
+
+// OverrideRequest assigns a statement line to a different record, or clears the match when MatchID is empty
+type OverrideRequest struct {
+	MatchType MatchType `json:"match_type" example:"PAYMENT"`
+	MatchID   string    `json:"match_id" example:"6b1f0c1e-7a4e-4a53-9d0c-1e2f3a4b5c6d"`
+}
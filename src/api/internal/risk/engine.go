@@ -0,0 +1,274 @@
+package risk
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+)
+
+// History provides the past activity used by velocity and new-payee rules
+type History interface {
+	// Activity returns the number and total amount of payments for a user or payee since the given time
+	Activity(scope, key string, since time.Time) (int, float64, error)
+	// HasPaid reports whether the user has paid the payee before
+	HasPaid(username, payee string) (bool, error)
+}
+
+// Engine evaluates payments against the configured rules.
+// Rules are swapped atomically when the rules file changes.
+type Engine struct {
+	mu      sync.RWMutex
+	rules   *RuleSet
+	history History
+}
+
+// NewEngine creates an Engine with the given rules and history source
+func NewEngine(rules *RuleSet, history History) *Engine {
+	return &Engine{rules: rules, history: history}
+}
+
+// Rules returns the rules currently in use
+func (e *Engine) Rules() *RuleSet {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return e.rules
+}
+
+// SetRules replaces the rules in use
+func (e *Engine) SetRules(rules *RuleSet) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.rules = rules
+}
+
+// LoadRules reads and validates a rules file (YAML, JSON or TOML by extension)
+func LoadRules(path string) (*RuleSet, error) {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return nil, fmt.Errorf("error reading risk rules: %w", err)
+	}
+	return decodeRules(v)
+}
+
+// WatchRules loads the rules file into the engine and reloads it whenever it changes.
+// An invalid file is logged and the previous rules stay in effect.
+func (e *Engine) WatchRules(path string) error {
+	v := viper.New()
+	v.SetConfigFile(path)
+	if err := v.ReadInConfig(); err != nil {
+		return fmt.Errorf("error reading risk rules: %w", err)
+	}
+	rules, err := decodeRules(v)
+	if err != nil {
+		return err
+	}
+	e.SetRules(rules)
+
+	v.OnConfigChange(func(event fsnotify.Event) {
+		rules, err := decodeRules(v)
+		if err != nil {
+			log.Printf("[error] - Risk rules not reloaded from %s: %v", event.Name, err)
+			return
+		}
+		e.SetRules(rules)
+		log.Printf("[info] - Risk rules reloaded from %s (version %s)", event.Name, rules.Version)
+	})
+	v.WatchConfig()
+	return nil
+}
+
+func decodeRules(v *viper.Viper) (*RuleSet, error) {
+	var rules RuleSet
+	if err := v.Unmarshal(&rules); err != nil {
+		return nil, fmt.Errorf("error decoding risk rules: %w", err)
+	}
+	if err := rules.Validate(); err != nil {
+		return nil, err
+	}
+	return &rules, nil
+}
+
+// Validate checks the rules for values that would make evaluation meaningless
+func (rs *RuleSet) Validate() error {
+	if rs.ReviewThreshold == 0 {
+		rs.ReviewThreshold = DefaultReviewThreshold
+	}
+	if rs.ReviewThreshold < 0 || rs.ReviewThreshold > MaxScore {
+		return fmt.Errorf("review_threshold must be between 1 and %d", MaxScore)
+	}
+	for _, rule := range rs.Velocity {
+		if rule.Scope != ScopeUser && rule.Scope != ScopePayee {
+			return fmt.Errorf("velocity rule %q: scope must be %q or %q", rule.Name, ScopeUser, ScopePayee)
+		}
+		if rule.Window <= 0 {
+			return fmt.Errorf("velocity rule %q: window must be positive", rule.Name)
+		}
+		if rule.MaxCount <= 0 && rule.MaxAmount <= 0 {
+			return fmt.Errorf("velocity rule %q: max_count or max_amount is required", rule.Name)
+		}
+	}
+	if rs.UnusualHours.Enabled {
+		if rs.UnusualHours.StartHour < 0 || rs.UnusualHours.StartHour > 23 ||
+			rs.UnusualHours.EndHour < 0 || rs.UnusualHours.EndHour > 23 {
+			return errors.New("unusual_hours: hours must be between 0 and 23")
+		}
+		if _, err := time.LoadLocation(rs.UnusualHours.Timezone); err != nil {
+			return fmt.Errorf("unusual_hours: %w", err)
+		}
+	}
+	return nil
+}
+
+// Evaluate scores a payment. The score is the capped sum of the triggered rules;
+// payments at or above the review threshold must be held for review.
+func (e *Engine) Evaluate(input Input) (*Assessment, error) {
+	return e.evaluate(input, e.history)
+}
+
+func (e *Engine) evaluate(input Input, history History) (*Assessment, error) {
+	rules := e.Rules()
+	if rules == nil {
+		return nil, errors.New("risk rules are not loaded")
+	}
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = time.Now()
+	}
+
+	var triggered []TriggeredRule
+	add := func(rule string, score int, reason string) {
+		if score > 0 {
+			triggered = append(triggered, TriggeredRule{Rule: rule, Score: score, Reason: reason})
+		}
+	}
+
+	for _, rule := range rules.Velocity {
+		key := input.Username
+		if rule.Scope == ScopePayee {
+			key = input.PayTo
+		}
+		if key == "" {
+			continue
+		}
+		count, total, err := history.Activity(rule.Scope, key, input.CreatedAt.Add(-rule.Window))
+		if err != nil {
+			return nil, err
+		}
+		// Include the payment being assessed
+		count++
+		total += input.Amount
+		switch {
+		case rule.MaxCount > 0 && count > rule.MaxCount:
+			add(rule.Name, rule.Score, fmt.Sprintf("%d payments by %s %q within %s (max %d)", count, rule.Scope, key, rule.Window, rule.MaxCount))
+		case rule.MaxAmount > 0 && total > rule.MaxAmount:
+			add(rule.Name, rule.Score, fmt.Sprintf("%.2f paid by %s %q within %s (max %.2f)", total, rule.Scope, key, rule.Window, rule.MaxAmount))
+		}
+	}
+
+	var highest *AmountThreshold
+	for i, threshold := range rules.AmountThresholds {
+		if input.Amount >= threshold.MinAmount && (highest == nil || threshold.MinAmount > highest.MinAmount) {
+			highest = &rules.AmountThresholds[i]
+		}
+	}
+	if highest != nil {
+		add(highest.Name, highest.Score, fmt.Sprintf("amount %.2f is at least %.2f", input.Amount, highest.MinAmount))
+	}
+
+	if rules.NewPayee.Enabled && input.Username != "" && input.PayTo != "" {
+		paid, err := history.HasPaid(input.Username, input.PayTo)
+		if err != nil {
+			return nil, err
+		}
+		if !paid {
+			add("new_payee", rules.NewPayee.Score, fmt.Sprintf("first payment from %q to %q", input.Username, input.PayTo))
+		}
+	}
+
+	for _, payee := range rules.DenyList.Payees {
+		if strings.EqualFold(strings.TrimSpace(payee), strings.TrimSpace(input.PayTo)) {
+			add("deny_list", rules.DenyList.Score, fmt.Sprintf("payee %q is on the deny list", input.PayTo))
+			break
+		}
+	}
+
+	if rules.UnusualHours.Enabled {
+		location, _ := time.LoadLocation(rules.UnusualHours.Timezone)
+		hour := input.CreatedAt.In(location).Hour()
+		if inHours(hour, rules.UnusualHours.StartHour, rules.UnusualHours.EndHour) {
+			add("unusual_hours", rules.UnusualHours.Score, fmt.Sprintf("created at %02d:00 %s", hour, location))
+		}
+	}
+
+	score := 0
+	for _, rule := range triggered {
+		score += rule.Score
+	}
+	if score > MaxScore {
+		score = MaxScore
+	}
+
+	assessment := &Assessment{
+		PaymentID:      input.PaymentID,
+		Username:       input.Username,
+		PayTo:          input.PayTo,
+		Amount:         input.Amount,
+		Score:          score,
+		Decision:       DecisionApprove,
+		TriggeredRules: triggered,
+		RulesVersion:   rules.Version,
+		CreatedAt:      input.CreatedAt,
+	}
+	if score >= rules.ReviewThreshold {
+		assessment.Decision = DecisionReview
+		assessment.ReviewStatus = ReviewPending
+	}
+	return assessment, nil
+}
+
+// pendingHistory adds assessments that are not recorded yet to a history
+type pendingHistory struct {
+	History
+	pending []*Assessment
+}
+
+func (h *pendingHistory) Activity(scope, key string, since time.Time) (int, float64, error) {
+	count, total, err := h.History.Activity(scope, key, since)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, assessment := range h.pending {
+		match := assessment.Username
+		if scope == ScopePayee {
+			match = assessment.PayTo
+		}
+		if match == key && !assessment.CreatedAt.Before(since) {
+			count++
+			total += assessment.Amount
+		}
+	}
+	return count, total, nil
+}
+
+func (h *pendingHistory) HasPaid(username, payee string) (bool, error) {
+	for _, assessment := range h.pending {
+		if assessment.Username == username && assessment.PayTo == payee {
+			return true, nil
+		}
+	}
+	return h.History.HasPaid(username, payee)
+}
+
+// inHours reports whether hour falls in [start, end), wrapping past midnight when start > end
+func inHours(hour, start, end int) bool {
+	if start <= end {
+		return hour >= start && hour < end
+	}
+	return hour >= start || hour < end
+}
@@ -0,0 +1,157 @@
+package risk
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// RiskHandler handles HTTP requests for risk assessments and the review queue
+type RiskHandler struct {
+	service *Service
+}
+
+// NewRiskHandler creates a new RiskHandler
+func NewRiskHandler(service *Service) *RiskHandler {
+	return &RiskHandler{service: service}
+}
+
+// GetReviewsHandler godoc
+// @Summary Get the review queue
+// @Description List payments held for review, highest score first. status defaults to PENDING.
+// @Tags risk
+// @Produce json
+// @Param status query string false "PENDING, APPROVED or REJECTED"
+// @Success 200 {array} Assessment
+// @Failure 400 {object} types.ErrorResponse
+// @Router /risk/reviews [get]
+func (h *RiskHandler) GetReviewsHandler(w http.ResponseWriter, r *http.Request) {
+	status := ReviewStatus(strings.ToUpper(r.URL.Query().Get("status")))
+	switch status {
+	case ReviewNone:
+		status = ReviewPending
+	case ReviewPending, ReviewApproved, ReviewRejected:
+	default:
+		writeError(w, http.StatusBadRequest, "Invalid review status", auth.GetRequestID(r))
+		return
+	}
+
+	assessments, err := h.service.Reviews(r.Context(), status)
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch review queue", auth.GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, assessments, "", auth.GetRequestID(r))
+}
+
+// GetAssessmentHandler godoc
+// @Summary Get a payment's risk assessment
+// @Description Get the score, triggered rules and review outcome recorded when the payment was created
+// @Tags risk
+// @Produce json
+// @Param payment_id query string true "Payment ID"
+// @Success 200 {object} Assessment
+// @Failure 404 {object} types.ErrorResponse
+// @Router /risk/assessments [get]
+func (h *RiskHandler) GetAssessmentHandler(w http.ResponseWriter, r *http.Request) {
+	paymentID := r.URL.Query().Get("payment_id")
+	if paymentID == "" {
+		writeError(w, http.StatusBadRequest, "Payment ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	assessment, err := h.service.GetAssessment(r.Context(), paymentID)
+	if errors.Is(err, ErrAssessmentNotFound) {
+		writeError(w, http.StatusNotFound, "Risk assessment not found", auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch risk assessment", auth.GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, assessment, "", auth.GetRequestID(r))
+}
+
+// GetRulesHandler godoc
+// @Summary Get the active risk rules
+// @Description Get the rules currently in effect, as last loaded from the rules file
+// @Tags risk
+// @Produce json
+// @Success 200 {object} RuleSet
+// @Router /risk/rules [get]
+func (h *RiskHandler) GetRulesHandler(w http.ResponseWriter, r *http.Request) {
+	writeSuccess(w, http.StatusOK, h.service.engine.Rules(), "", auth.GetRequestID(r))
+}
+
+// review handles the approve and reject endpoints
+func (h *RiskHandler) review(w http.ResponseWriter, r *http.Request, decide func(context.Context, string, string, string) (*Assessment, error), message string) {
+	paymentID := r.URL.Query().Get("payment_id")
+	if paymentID == "" {
+		writeError(w, http.StatusBadRequest, "Payment ID is required", auth.GetRequestID(r))
+		return
+	}
+
+	var req ReviewRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil && !errors.Is(err, io.EOF) {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+
+	assessment, err := decide(r.Context(), paymentID, auth.Username(r), req.Note)
+	switch {
+	case errors.Is(err, ErrAssessmentNotFound):
+		writeError(w, http.StatusNotFound, "Risk assessment not found", auth.GetRequestID(r))
+		return
+	case errors.Is(err, ErrNotInReview):
+		writeError(w, http.StatusConflict, err.Error(), auth.GetRequestID(r))
+		return
+	case err != nil:
+		writeError(w, http.StatusInternalServerError, "Failed to update review", auth.GetRequestID(r))
+		return
+	}
+	writeSuccess(w, http.StatusOK, assessment, message, auth.GetRequestID(r))
+}
+
+// ApproveReviewHandler godoc
+// @Summary Approve a payment held for review
+// @Description The payment moves from REVIEW to pending
+// @Tags risk
+// @Accept json
+// @Produce json
+// @Param payment_id query string true "Payment ID"
+// @Param review body ReviewRequest false "Reviewer note"
+// @Success 200 {object} Assessment
+// @Failure 409 {object} types.ErrorResponse
+// @Router /risk/reviews/approve [post]
+func (h *RiskHandler) ApproveReviewHandler(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.service.Approve, "Payment approved successfully")
+}
+
+// RejectReviewHandler godoc
+// @Summary Reject a payment held for review
+// @Description The payment moves from REVIEW to rejected
+// @Tags risk
+// @Accept json
+// @Produce json
+// @Param payment_id query string true "Payment ID"
+// @Param review body ReviewRequest false "Reviewer note"
+// @Success 200 {object} Assessment
+// @Failure 409 {object} types.ErrorResponse
+// @Router /risk/reviews/reject [post]
+func (h *RiskHandler) RejectReviewHandler(w http.ResponseWriter, r *http.Request) {
+	h.review(w, r, h.service.Reject, "Payment rejected successfully")
+}
+
+// RegisterRoutes registers the risk routes with the given router
+func (h *RiskHandler) RegisterRoutes(r *router.Router) {
+	r.Get("/risk/reviews", h.GetReviewsHandler)
+	r.Post("/risk/reviews/approve", h.ApproveReviewHandler)
+	r.Post("/risk/reviews/reject", h.RejectReviewHandler)
+	r.Get("/risk/assessments", h.GetAssessmentHandler)
+	r.Get("/risk/rules", h.GetRulesHandler)
+}
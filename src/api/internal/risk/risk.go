@@ -0,0 +1,144 @@
+package risk
+
+import (
+	"api/config"
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Review errors
+var (
+	ErrAssessmentNotFound = errors.New("risk assessment not found")
+	ErrNotInReview        = errors.New("payment is not pending review")
+)
+
+// Service scores payments and manages the review queue
+type Service struct {
+	engine *Engine
+	repo   *RiskRepo
+	now    func() time.Time
+}
+
+// NewService creates a Service that watches the configured rules file.
+// When the file cannot be loaded no rules trigger until it is fixed.
+func NewService() *Service {
+	repo := NewRiskRepo()
+	engine := NewEngine(&RuleSet{ReviewThreshold: DefaultReviewThreshold}, repo)
+	if cfg := config.NewConfig(); cfg != nil {
+		if err := engine.WatchRules(cfg.RiskRulesFile); err != nil {
+			log.Printf("[error] - Risk rules not loaded: %v", err)
+		}
+	}
+	return NewServiceWithEngine(engine, repo)
+}
+
+// NewServiceWithEngine creates a Service with the given engine and repository
+func NewServiceWithEngine(engine *Engine, repo *RiskRepo) *Service {
+	return &Service{engine: engine, repo: repo, now: time.Now}
+}
+
+// Assess evaluates a payment without storing the result
+func (s *Service) Assess(input Input) (*Assessment, error) {
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = s.now()
+	}
+	assessment, err := s.engine.Evaluate(input)
+	if err != nil {
+		return nil, err
+	}
+	assessment.AssessmentID = uuid.New().String()
+	return assessment, nil
+}
+
+// AssessAll evaluates several payments without storing the results. Each payment
+// counts towards the velocity and new-payee rules of the ones after it.
+func (s *Service) AssessAll(inputs []Input) ([]*Assessment, error) {
+	history := &pendingHistory{History: s.engine.history}
+	assessments := make([]*Assessment, 0, len(inputs))
+	for _, input := range inputs {
+		if input.CreatedAt.IsZero() {
+			input.CreatedAt = s.now()
+		}
+		assessment, err := s.engine.evaluate(input, history)
+		if err != nil {
+			return nil, err
+		}
+		assessment.AssessmentID = uuid.New().String()
+		history.pending = append(history.pending, assessment)
+		assessments = append(assessments, assessment)
+	}
+	return assessments, nil
+}
+
+// Reassess evaluates an updated payment without storing the result.
+// The payment's earlier assessment does not count towards its own velocity and new-payee rules.
+func (s *Service) Reassess(input Input) (*Assessment, error) {
+	if input.CreatedAt.IsZero() {
+		input.CreatedAt = s.now()
+	}
+	assessment, err := s.engine.evaluate(input, otherPayments{repo: s.repo, paymentID: input.PaymentID})
+	if err != nil {
+		return nil, err
+	}
+	assessment.AssessmentID = uuid.New().String()
+	return assessment, nil
+}
+
+// Record stores an assessment for audit once its payment exists, in the tenant of ctx
+func (s *Service) Record(ctx context.Context, assessment *Assessment) error {
+	return s.repo.InsertAssessment(ctx, assessment)
+}
+
+// Replace stores the assessment of an updated payment of the tenant of ctx in place of its earlier one
+func (s *Service) Replace(ctx context.Context, assessment *Assessment) error {
+	return s.repo.ReplaceAssessment(ctx, assessment)
+}
+
+// GetAssessment returns the stored assessment of a payment of the tenant of ctx
+func (s *Service) GetAssessment(ctx context.Context, paymentID string) (*Assessment, error) {
+	assessment, err := s.repo.GetAssessment(ctx, paymentID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrAssessmentNotFound
+	}
+	return assessment, err
+}
+
+// Reviews returns the tenant's assessments with the given review status, highest score first
+func (s *Service) Reviews(ctx context.Context, status ReviewStatus) ([]Assessment, error) {
+	return s.repo.GetAssessments(ctx, status)
+}
+
+// Approve releases a payment of the tenant of ctx held for review
+func (s *Service) Approve(ctx context.Context, paymentID, reviewer, note string) (*Assessment, error) {
+	return s.review(ctx, paymentID, ReviewApproved, PaymentStatusApproved, reviewer, note)
+}
+
+// Reject rejects a payment of the tenant of ctx held for review
+func (s *Service) Reject(ctx context.Context, paymentID, reviewer, note string) (*Assessment, error) {
+	return s.review(ctx, paymentID, ReviewRejected, PaymentStatusRejected, reviewer, note)
+}
+
+func (s *Service) review(ctx context.Context, paymentID string, status ReviewStatus, paymentStatus, reviewer, note string) (*Assessment, error) {
+	assessment, err := s.GetAssessment(ctx, paymentID)
+	if err != nil {
+		return nil, err
+	}
+	if assessment.ReviewStatus != ReviewPending {
+		return nil, ErrNotInReview
+	}
+
+	reviewedAt := s.now().UTC()
+	assessment.ReviewStatus = status
+	assessment.ReviewedBy = reviewer
+	assessment.ReviewedAt = &reviewedAt
+	assessment.ReviewNote = note
+	if err := s.repo.UpdateReview(ctx, assessment, paymentStatus); err != nil {
+		return nil, err
+	}
+	return assessment, nil
+}
@@ -0,0 +1,283 @@
+package risk
+
+import (
+	"api/internal/db"
+	"api/internal/tenant"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// RiskRepo represents the repository for risk assessments and the review queue.
+// Stored assessments are the audit trail and the history used by velocity rules.
+type RiskRepo struct {
+	DB *db.DB
+}
+
+// NewRiskRepo creates a new instance of RiskRepo
+func NewRiskRepo() *RiskRepo {
+	db := db.NewDB()
+	return &RiskRepo{DB: db}
+}
+
+// assessmentColumns lists the assessment columns in the order scanAssessment reads them
+const assessmentColumns = `
+	assessment_id, payment_id, username, pay_to, amount, score, decision,
+	triggered_rules, rules_version, review_status, reviewed_by, reviewed_at,
+	review_note, created_at`
+
+// insertAssessmentColumns are the assessment columns written on insert; the scope adds tenant_id
+var insertAssessmentColumns = []string{
+	"assessment_id", "payment_id", "username", "pay_to", "amount", "score", "decision",
+	"triggered_rules", "rules_version", "review_status", "created_at",
+}
+
+// scope returns the assessments of the tenant of ctx
+func (rr *RiskRepo) scope(ctx context.Context) (*tenant.ScopedDB, error) {
+	return tenant.Scope(ctx, rr.DB.Connection)
+}
+
+// InsertAssessment stores the result of evaluating a payment of the tenant of ctx
+func (rr *RiskRepo) InsertAssessment(ctx context.Context, assessment *Assessment) error {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return err
+	}
+	return insertAssessment(scoped, assessment)
+}
+
+// ReplaceAssessment stores the assessment of an updated payment of the tenant of ctx
+// in place of its earlier one in one transaction
+func (rr *RiskRepo) ReplaceAssessment(ctx context.Context, assessment *Assessment) error {
+	tx, err := rr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scoped, err := tenant.Scope(ctx, tx)
+	if err != nil {
+		return err
+	}
+	if _, err := scoped.Delete("risk_assessments", "payment_id = ?", assessment.PaymentID); err != nil {
+		return fmt.Errorf("error deleting risk assessment: %w", err)
+	}
+	if err := insertAssessment(scoped, assessment); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertAssessment(scoped *tenant.ScopedDB, assessment *Assessment) error {
+	triggered, err := json.Marshal(assessment.TriggeredRules)
+	if err != nil {
+		return fmt.Errorf("error encoding triggered rules: %w", err)
+	}
+
+	_, err = scoped.Insert("risk_assessments", insertAssessmentColumns,
+		assessment.AssessmentID,
+		assessment.PaymentID,
+		assessment.Username,
+		assessment.PayTo,
+		assessment.Amount,
+		assessment.Score,
+		assessment.Decision,
+		string(triggered),
+		assessment.RulesVersion,
+		assessment.ReviewStatus,
+		assessment.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting risk assessment: %w", err)
+	}
+	return nil
+}
+
+// GetAssessment retrieves the assessment of a payment of the tenant of ctx;
+// assessments of other tenants are not found
+func (rr *RiskRepo) GetAssessment(ctx context.Context, paymentID string) (*Assessment, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	row, err := scoped.SelectRow("risk_assessments", assessmentColumns, "payment_id = ?", paymentID)
+	if err != nil {
+		return nil, err
+	}
+	return scanAssessment(row)
+}
+
+// GetAssessments retrieves the tenant's assessments, optionally filtered by review status
+func (rr *RiskRepo) GetAssessments(ctx context.Context, status ReviewStatus) ([]Assessment, error) {
+	scoped, err := rr.scope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	where := ""
+	var args []interface{}
+	if status != ReviewNone {
+		where = "review_status = ?"
+		args = append(args, status)
+	}
+
+	rows, err := scoped.Select("risk_assessments", assessmentColumns, where, "score DESC, created_at", args...)
+	if err != nil {
+		return nil, fmt.Errorf("error querying risk assessments: %w", err)
+	}
+	defer rows.Close()
+
+	var assessments []Assessment
+	for rows.Next() {
+		assessment, err := scanAssessment(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error scanning risk assessment: %w", err)
+		}
+		assessments = append(assessments, *assessment)
+	}
+	return assessments, rows.Err()
+}
+
+// UpdateReview records a reviewer's decision and moves the payment out of review in one
+// transaction; both the assessment and the payment must belong to the tenant of ctx
+func (rr *RiskRepo) UpdateReview(ctx context.Context, assessment *Assessment, paymentStatus string) error {
+	tx, err := rr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	scoped, err := tenant.Scope(ctx, tx)
+	if err != nil {
+		return err
+	}
+	_, err = scoped.Update("risk_assessments",
+		"review_status = ?, reviewed_by = ?, reviewed_at = ?, review_note = ?",
+		"assessment_id = ?",
+		assessment.ReviewStatus,
+		assessment.ReviewedBy,
+		assessment.ReviewedAt,
+		assessment.ReviewNote,
+		assessment.AssessmentID,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating risk assessment: %w", err)
+	}
+
+	_, err = scoped.Update("payments", "status = ?, updated_at = ?", "payment_id = ? AND status = ?",
+		paymentStatus, time.Now(), assessment.PaymentID, PaymentStatusReview,
+	)
+	if err != nil {
+		return fmt.Errorf("error updating payment status: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// Activity returns the number and total amount of assessed payments for a user or payee since the given time
+func (rr *RiskRepo) Activity(scope, key string, since time.Time) (int, float64, error) {
+	return rr.activity(scope, key, since, "")
+}
+
+// HasPaid reports whether the user has a payment to the payee that was not rejected in review
+func (rr *RiskRepo) HasPaid(username, payee string) (bool, error) {
+	return rr.hasPaid(username, payee, "")
+}
+
+// otherPayments is the history of every payment but one, used when that payment is assessed again
+type otherPayments struct {
+	repo      *RiskRepo
+	paymentID string
+}
+
+func (h otherPayments) Activity(scope, key string, since time.Time) (int, float64, error) {
+	return h.repo.activity(scope, key, since, h.paymentID)
+}
+
+func (h otherPayments) HasPaid(username, payee string) (bool, error) {
+	return h.repo.hasPaid(username, payee, h.paymentID)
+}
+
+func (rr *RiskRepo) activity(scope, key string, since time.Time, excludePaymentID string) (int, float64, error) {
+	column := "username"
+	if scope == ScopePayee {
+		column = "pay_to"
+	}
+
+	row, err := rr.DB.QueryRow(`
+		SELECT COUNT(*), COALESCE(SUM(amount), 0)
+		FROM risk_assessments
+		WHERE `+column+` = ? AND created_at >= ? AND payment_id <> ?`,
+		key, since.UTC(), excludePaymentID,
+	)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var count int
+	var total float64
+	if err := row.Scan(&count, &total); err != nil {
+		return 0, 0, fmt.Errorf("error querying %s activity: %w", scope, err)
+	}
+	return count, total, nil
+}
+
+func (rr *RiskRepo) hasPaid(username, payee, excludePaymentID string) (bool, error) {
+	row, err := rr.DB.QueryRow(`
+		SELECT COUNT(*) FROM risk_assessments
+		WHERE username = ? AND pay_to = ? AND review_status <> ? AND payment_id <> ?`,
+		username, payee, ReviewRejected, excludePaymentID,
+	)
+	if err != nil {
+		return false, err
+	}
+
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error querying payee history: %w", err)
+	}
+	return count > 0, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanAssessment(row rowScanner) (*Assessment, error) {
+	var assessment Assessment
+	var triggered string
+	var reviewedBy, reviewNote sql.NullString
+	var reviewedAt sql.NullTime
+	err := row.Scan(
+		&assessment.AssessmentID,
+		&assessment.PaymentID,
+		&assessment.Username,
+		&assessment.PayTo,
+		&assessment.Amount,
+		&assessment.Score,
+		&assessment.Decision,
+		&triggered,
+		&assessment.RulesVersion,
+		&assessment.ReviewStatus,
+		&reviewedBy,
+		&reviewedAt,
+		&reviewNote,
+		&assessment.CreatedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal([]byte(triggered), &assessment.TriggeredRules); err != nil {
+		return nil, fmt.Errorf("error decoding triggered rules: %w", err)
+	}
+	assessment.ReviewedBy = reviewedBy.String
+	assessment.ReviewNote = reviewNote.String
+	if reviewedAt.Valid {
+		assessment.ReviewedAt = &reviewedAt.Time
+	}
+	return &assessment, nil
+}
@@ -0,0 +1,130 @@
+package risk
+
+import "time"
+
+// Decision is the outcome of a risk assessment
+type Decision string
+
+const (
+	DecisionApprove Decision = "APPROVE"
+	DecisionReview  Decision = "REVIEW"
+)
+
+// ReviewStatus tracks a payment held for manual review
+type ReviewStatus string
+
+const (
+	ReviewNone     ReviewStatus = ""
+	ReviewPending  ReviewStatus = "PENDING"
+	ReviewApproved ReviewStatus = "APPROVED"
+	ReviewRejected ReviewStatus = "REJECTED"
+)
+
+// Payment statuses set by the risk engine and the review queue
+const (
+	PaymentStatusReview   = "REVIEW"
+	PaymentStatusApproved = "pending"
+	PaymentStatusRejected = "rejected"
+)
+
+// Velocity scopes
+const (
+	ScopeUser  = "user"
+	ScopePayee = "payee"
+)
+
+// MaxScore caps the sum of triggered rule scores
+const MaxScore = 100
+
+// DefaultReviewThreshold is used when the rules file does not set one
+const DefaultReviewThreshold = 70
+
+// RuleSet is the rules configuration loaded from the rules file
+type RuleSet struct {
+	Version          string            `mapstructure:"version" json:"version"`
+	ReviewThreshold  int               `mapstructure:"review_threshold" json:"review_threshold"`
+	Velocity         []VelocityRule    `mapstructure:"velocity" json:"velocity"`
+	AmountThresholds []AmountThreshold `mapstructure:"amount_thresholds" json:"amount_thresholds"`
+	NewPayee         NewPayeeRule      `mapstructure:"new_payee" json:"new_payee"`
+	DenyList         DenyListRule      `mapstructure:"deny_list" json:"deny_list"`
+	UnusualHours     UnusualHoursRule  `mapstructure:"unusual_hours" json:"unusual_hours"`
+}
+
+// VelocityRule triggers when a user or payee exceeds a count or amount within a window
+type VelocityRule struct {
+	Name      string        `mapstructure:"name" json:"name"`
+	Scope     string        `mapstructure:"scope" json:"scope"`
+	Window    time.Duration `mapstructure:"window" json:"window"`
+	MaxCount  int           `mapstructure:"max_count" json:"max_count"`
+	MaxAmount float64       `mapstructure:"max_amount" json:"max_amount"`
+	Score     int           `mapstructure:"score" json:"score"`
+}
+
+// AmountThreshold triggers when a single payment is at least MinAmount.
+// Only the highest matching threshold counts.
+type AmountThreshold struct {
+	Name      string  `mapstructure:"name" json:"name"`
+	MinAmount float64 `mapstructure:"min_amount" json:"min_amount"`
+	Score     int     `mapstructure:"score" json:"score"`
+}
+
+// NewPayeeRule triggers the first time a user pays a payee
+type NewPayeeRule struct {
+	Enabled bool `mapstructure:"enabled" json:"enabled"`
+	Score   int  `mapstructure:"score" json:"score"`
+}
+
+// DenyListRule triggers for payees on the deny list (case-insensitive)
+type DenyListRule struct {
+	Payees []string `mapstructure:"payees" json:"payees"`
+	Score  int      `mapstructure:"score" json:"score"`
+}
+
+// UnusualHoursRule triggers for payments created between StartHour and EndHour in Timezone.
+// The range wraps past midnight when StartHour is greater than EndHour.
+type UnusualHoursRule struct {
+	Enabled   bool   `mapstructure:"enabled" json:"enabled"`
+	StartHour int    `mapstructure:"start_hour" json:"start_hour"`
+	EndHour   int    `mapstructure:"end_hour" json:"end_hour"`
+	Timezone  string `mapstructure:"timezone" json:"timezone"`
+	Score     int    `mapstructure:"score" json:"score"`
+}
+
+// Input is the payment being assessed
+type Input struct {
+	PaymentID string    `json:"payment_id"`
+	Username  string    `json:"username"`
+	PayTo     string    `json:"pay_to"`
+	Amount    float64   `json:"amount"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TriggeredRule records why a rule contributed to the score
+type TriggeredRule struct {
+	Rule   string `json:"rule"`
+	Score  int    `json:"score"`
+	Reason string `json:"reason"`
+}
+
+// Assessment is the stored result of evaluating a payment
+type Assessment struct {
+	AssessmentID   string          `json:"assessment_id"`
+	PaymentID      string          `json:"payment_id"`
+	Username       string          `json:"username"`
+	PayTo          string          `json:"pay_to"`
+	Amount         float64         `json:"amount"`
+	Score          int             `json:"score"`
+	Decision       Decision        `json:"decision"`
+	TriggeredRules []TriggeredRule `json:"triggered_rules"`
+	RulesVersion   string          `json:"rules_version"`
+	ReviewStatus   ReviewStatus    `json:"review_status,omitempty"`
+	ReviewedBy     string          `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time      `json:"reviewed_at,omitempty"`
+	ReviewNote     string          `json:"review_note,omitempty"`
+	CreatedAt      time.Time       `json:"created_at"`
+}
+
+// ReviewRequest carries the reviewer's note when approving or rejecting
+type ReviewRequest struct {
+	Note string `json:"note" example:"Confirmed with the customer by phone"`
+}
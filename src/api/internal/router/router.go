@@ -0,0 +1,129 @@
+package router
+
+import (
+	"net/http"
+	"strings"
+)
+
+// Middleware wraps a handler with cross-cutting behaviour
+type Middleware func(http.Handler) http.Handler
+
+// Router registers method-aware routes with path parameters such as /payments/{id}.
+// Groups share one route table and add a path prefix and middleware of their own.
+type Router struct {
+	routes     *routes
+	prefix     string
+	middleware []Middleware
+}
+
+// routes is the route table shared by a router and its groups
+type routes struct {
+	mux     *http.ServeMux
+	aliases []alias
+}
+
+// alias maps a legacy path prefix onto its current one
+type alias struct {
+	legacy  string
+	current string
+}
+
+// NewRouter creates an empty Router
+func NewRouter() *Router {
+	return &Router{routes: &routes{mux: http.NewServeMux()}}
+}
+
+// Group creates a sub-router whose routes are under prefix and wrapped by the
+// parent's middleware followed by the given middleware
+func (r *Router) Group(prefix string, middleware ...Middleware) *Router {
+	chain := make([]Middleware, 0, len(r.middleware)+len(middleware))
+	chain = append(chain, r.middleware...)
+	chain = append(chain, middleware...)
+	return &Router{routes: r.routes, prefix: r.prefix + prefix, middleware: chain}
+}
+
+// Use adds middleware to the routes registered on this router from now on
+func (r *Router) Use(middleware ...Middleware) {
+	r.middleware = append(r.middleware, middleware...)
+}
+
+// Handle registers a handler for a method and path. An empty method matches every method.
+// Requests with another method on a registered path are answered with 405 Method Not Allowed.
+func (r *Router) Handle(method, path string, handler http.Handler) {
+	for i := len(r.middleware) - 1; i >= 0; i-- {
+		handler = r.middleware[i](handler)
+	}
+	pattern := r.Path(path)
+	if method != "" {
+		pattern = method + " " + pattern
+	}
+	r.routes.mux.Handle(pattern, handler)
+}
+
+// HandleFunc registers a handler function for a method and path
+func (r *Router) HandleFunc(method, path string, handler http.HandlerFunc) {
+	r.Handle(method, path, handler)
+}
+
+// Get registers a GET route; HEAD requests are served by the same handler
+func (r *Router) Get(path string, handler http.HandlerFunc) {
+	r.Handle(http.MethodGet, path, handler)
+}
+
+// Post registers a POST route
+func (r *Router) Post(path string, handler http.HandlerFunc) {
+	r.Handle(http.MethodPost, path, handler)
+}
+
+// Put registers a PUT route
+func (r *Router) Put(path string, handler http.HandlerFunc) {
+	r.Handle(http.MethodPut, path, handler)
+}
+
+// Patch registers a PATCH route
+func (r *Router) Patch(path string, handler http.HandlerFunc) {
+	r.Handle(http.MethodPatch, path, handler)
+}
+
+// Delete registers a DELETE route
+func (r *Router) Delete(path string, handler http.HandlerFunc) {
+	r.Handle(http.MethodDelete, path, handler)
+}
+
+// Path returns the full path of a route registered on this router
+func (r *Router) Path(path string) string {
+	return r.prefix + path
+}
+
+// Alias keeps legacy paths working by serving every request under legacy as the
+// same path under current, for example /api/payments as /api/v1/payments.
+// Aliased responses carry Deprecation and Link headers pointing at the new path.
+func (r *Router) Alias(legacy, current string) {
+	r.routes.aliases = append(r.routes.aliases, alias{legacy: legacy, current: current})
+}
+
+// ServeHTTP dispatches the request to the matching route, rewriting legacy paths first
+func (r *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	for _, a := range r.routes.aliases {
+		// Paths already under current may share the legacy prefix; leave them alone
+		if !strings.HasPrefix(req.URL.Path, a.legacy) || strings.HasPrefix(req.URL.Path, a.current) {
+			continue
+		}
+
+		path := a.current + strings.TrimPrefix(req.URL.Path, a.legacy)
+		w.Header().Set("Deprecation", "true")
+		w.Header().Set("Link", "<"+path+">; rel=\"successor-version\"")
+
+		aliased := req.Clone(req.Context())
+		aliased.URL.Path = path
+		aliased.URL.RawPath = ""
+		r.routes.mux.ServeHTTP(w, aliased)
+		return
+	}
+	r.routes.mux.ServeHTTP(w, req)
+}
+
+// Param returns a path parameter of the matched route, or "" when it is absent
+func Param(r *http.Request, name string) string {
+	return r.PathValue(name)
+}
@@ -10,8 +10,19 @@ import (
 
 	"api/config"
 	"api/internal/auth"
+	"api/internal/consent"
+	"api/internal/fx"
+	"api/internal/iso20022"
+	"api/internal/ledger"
 	"api/internal/loan"
 	"api/internal/middleware"
+	"api/internal/payment"
+	"api/internal/policy"
+	"api/internal/reconcile"
+	"api/internal/risk"
+	"api/internal/router"
+	"api/internal/tenant"
+	"api/internal/vault"
 
 	_ "api/cmd/server/docs" // Import swagger docs
 
@@ -24,7 +35,7 @@ type Server struct {
 	config *config.Config
 	loan   loan.LoanService
 	server *http.Server
-	router *http.ServeMux
+	router *router.Router
 }
 
 // NewServer creates a new server instance
@@ -39,7 +50,7 @@ func NewServer(cfg *config.Config) (*Server, error) {
 
 	// Initialize services
 	creditService := loan.NewCreditService(db)
-	paymentService := loan.NewPaymentService()
+	paymentService := loan.NewPaymentService(ledger.NewLedger())
 	documentService := loan.NewDocumentService() // You'll need to create this too
 	loanService := loan.NewLoanService(
 		db,
@@ -80,30 +91,120 @@ func (s *Server) Run(ctx context.Context, port int) {
 	s.shutdownServer()
 }
 
+// apiVersion is the path prefix of the current API version
+const apiVersion = "/api/v1"
+
 // createServer creates and configures a new HTTP server
 func (s *Server) createServer(port int) *http.Server {
-	mux := http.NewServeMux()
+	mux := router.NewRouter()
+	s.router = mux
+
+	// Each client is rate limited per route group: anonymous clients by IP, others by
+	// user or API key, after authentication so that their tenant and roles are known
+	rateLimits := middleware.GetRateLimiter()
+	publicLimit := rateLimits.Middleware(middleware.PublicRateLimitPolicy())
+	apiLimit := rateLimits.Middleware(middleware.APIRateLimitPolicy())
+
+	// Unversioned routes of the server itself
+	root := mux.Group("", publicLimit)
 
 	// Update Swagger configuration
-	mux.Handle("/swagger/", httpSwagger.Handler(
+	root.Handle("", "/swagger/", httpSwagger.Handler(
 		httpSwagger.URL(fmt.Sprintf("http://localhost:%d/swagger/doc.json", port)),
 		httpSwagger.DeepLinking(true),
 		httpSwagger.DocExpansion("none"),
 		httpSwagger.DomID("swagger-ui"),
 	))
 
+	// Public keys for services that verify our tokens
+	root.Get("/.well-known/jwks.json", auth.JWKSHandler)
+
+	// OAuth 2.0 / OpenID Connect authorization server
+	root.Get("/.well-known/openid-configuration", auth.OIDCDiscoveryHandler)
+	root.Get(auth.OAuthAuthorizePath, auth.OAuthAuthorizeHandler)
+	root.Post(auth.OAuthAuthorizePath, auth.OAuthAuthorizeHandler)
+	root.Post(auth.OAuthLoginPath, auth.OAuthLoginHandler)
+	root.HandleFunc("", auth.OAuthTokenPath, auth.OAuthTokenHandler)
+	root.HandleFunc("", auth.OAuthUserInfoPath, auth.OAuthUserInfoHandler)
+
+	// Unversioned paths are kept as aliases of the current version
+	mux.Alias("/api/", apiVersion+"/")
+	mux.Alias("/loans/", apiVersion+"/loans/")
+
+	// Routes that do not need a token
+	public := mux.Group(apiVersion, publicLimit)
+
 	// @Summary Health check endpoint
 	// @Description Get the health status of the API
 	// @Tags health
 	// @Produce json
 	// @Success 200 {object} map[string]string
 	// @Router /health [get]
-	mux.HandleFunc("/api/health", func(w http.ResponseWriter, r *http.Request) {
+	public.Get("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.Header().Set("Content-Type", "application/json")
 		w.WriteHeader(http.StatusOK)
 		w.Write([]byte(`{"status":"ok"}`))
 	})
 
+	// @Summary User login
+	// @Description Authenticate a user and get JWT token
+	// @Tags auth
+	// @Accept json
+	// @Produce json
+	// @Param credentials body auth.LoginRequest true "Login credentials"
+	// @Success 200 {object} auth.LoginResponse
+	// @Failure 401 {object} ErrorResponse
+	// @Router /login [post]
+	public.Post("/login", auth.LoginHandler)
+
+	// @Summary Register a user
+	// @Description Create a user account
+	// @Tags auth
+	// @Accept json
+	// @Produce json
+	// @Param user body auth.RegisterRequest true "User registration details"
+	// @Success 201 {object} auth.User
+	// @Failure 400 {object} ErrorResponse
+	// @Router /register [post]
+	public.Post("/register", auth.RegisterHandler)
+
+	// Refresh tokens authenticate themselves, so the endpoint sits outside the JWT group
+	public.Post("/token/refresh", auth.RefreshTokenHandler)
+
+	// @Summary User logout
+	// @Description Revoke the access token and refresh token and clear the authentication cookies
+	// @Tags auth
+	// @Produce json
+	// @Success 200 {object} map[string]string
+	// @Router /logout [post]
+	public.HandleFunc("", "/logout", auth.LogoutHandler)
+
+	// Password reset and email verification links; the tokens in them authenticate the requests
+	public.Post("/password/forgot", auth.ForgotPasswordHandler)
+	public.Post("/password/reset", auth.ResetPasswordHandler)
+	public.Post("/email/verify", auth.VerifyEmailHandler)
+	public.Post("/email/verify/resend", auth.ResendVerificationHandler)
+
+	// Second login step for users with MFA; the challenge token authenticates it
+	public.Post("/mfa/verify", auth.MFAVerifyHandler)
+
+	// Ends an impersonation; the impersonation token authenticates it
+	public.Post("/impersonate/stop", auth.StopImpersonationHandler)
+
+	// The user's own MFA settings and API keys need a token but no API permission
+	account := mux.Group(apiVersion, middleware.AuthenticatedMiddleware(), apiLimit)
+	account.Get("/mfa", auth.MFAStatusHandler)
+	account.Delete("/mfa", auth.MFADisableHandler)
+	account.Post("/mfa/enroll", auth.MFAEnrollHandler)
+	account.Post("/mfa/confirm", auth.MFAConfirmHandler)
+	account.Post("/mfa/recovery-codes", auth.MFARecoveryCodesHandler)
+	account.Post("/api-keys", auth.CreateAPIKeyHandler)
+	account.Get("/api-keys", auth.GetAPIKeysHandler)
+	account.Delete("/api-keys/{id}", auth.RevokeAPIKeyHandler)
+
+	// Routes that need a valid token and API permission
+	protected := mux.Group(apiVersion, middleware.JWTMiddleware(nil), apiLimit)
+
 	// @Summary Create a new role
 	// @Description Create a new role in the system
 	// @Tags auth
@@ -113,22 +214,106 @@ func (s *Server) createServer(port int) *http.Server {
 	// @Success 201 {object} auth.Role
 	// @Failure 400 {object} ErrorResponse
 	// @Router /role [post]
-	mux.HandleFunc("/api/role", auth.CreateRoleHandler)
+	protected.Post("/role", auth.CreateRoleHandler)
 
-	// @Summary User login
-	// @Description Authenticate a user and get JWT token
+	// @Summary Assign a role to a user
 	// @Tags auth
 	// @Accept json
 	// @Produce json
-	// @Param credentials body auth.LoginRequest true "Login credentials"
-	// @Success 200 {object} auth.LoginResponse
-	// @Failure 401 {object} ErrorResponse
-	// @Router /login [post]
-	mux.HandleFunc("/api/login", auth.LoginHandler)
+	// @Param user_role body auth.UserRoles true "User role"
+	// @Success 201 {object} map[string]string
+	// @Router /user-role [post]
+	protected.Post("/user-role", auth.CreateUserRoleHandler)
+
+	// RBAC administration: roles, role permissions and user-role assignments
+	protected.Get("/roles", auth.GetRolesHandler)
+	protected.Post("/roles", auth.CreateRoleHandler)
+	protected.Get("/roles/{id}", auth.GetRoleHandler)
+	protected.Put("/roles/{id}", auth.UpdateRoleHandler)
+	protected.Delete("/roles/{id}", auth.DeleteRoleHandler)
+	protected.Get("/roles/{id}/permissions", auth.GetRolePermissionsHandler)
+	protected.Post("/roles/{id}/permissions", auth.CreateRolePermissionHandler)
+	protected.Put("/roles/{id}/permissions/{permissionId}", auth.UpdateRolePermissionHandler)
+	protected.Delete("/roles/{id}/permissions/{permissionId}", auth.DeleteRolePermissionHandler)
+	protected.Get("/users/{userId}/roles", auth.GetUserRolesHandler)
+	protected.Post("/users/{userId}/roles", auth.AssignUserRoleHandler)
+	protected.Delete("/users/{userId}/roles/{roleId}", auth.RemoveUserRoleHandler)
+	protected.Get("/users/{userId}/permissions", auth.GetUserAccessHandler)
+
+	// @Summary Search users
+	// @Tags auth
+	// @Produce json
+	// @Param searchText query string false "Search text"
+	// @Success 200 {array} auth.User
+	// @Router /users [get]
+	protected.Get("/users", auth.GetUsersHandler)
+
+	// Admin endpoints for failed login lockouts and the authentication audit trail
+	protected.Post("/users/{username}/unlock", auth.UnlockUserHandler)
+	protected.Get("/lockouts", auth.GetLockoutsHandler)
+	protected.Delete("/lockouts/ip/{ip}", auth.UnlockIPHandler)
+	protected.Get("/auth-events", auth.GetAuthEventsHandler)
+	protected.Post("/oauth/clients", auth.CreateOAuthClientHandler)
+	protected.Get("/oauth/clients", auth.GetOAuthClientsHandler)
+	protected.Delete("/oauth/clients/{id}", auth.DeleteOAuthClientHandler)
+
+	// Support staff act as a user with a short-lived token; the route permission decides who may
+	protected.Post("/users/{userId}/impersonate", auth.ImpersonateHandler)
+
+	// Create and register payment handler
+	paymentHandler := payment.NewPaymentHandler()
+	paymentHandler.RegisterRoutes(protected)
+
+	// Create and register consent handler
+	consentHandler := consent.NewConsentHandler(consent.NewConsentRepo())
+	consentHandler.RegisterRoutes(protected)
+
+	// Create and register loan handler; loans are checked against attribute-based policies
+	policies := policy.NewService()
+	loanHandler := loan.NewLoanHandler(s.loan, policies)
+	loanHandler.RegisterRoutes(protected)
+
+	// Create and register policy explain handler
+	policyHandler := policy.NewPolicyHandler(policies)
+	policyHandler.RegisterRoutes(protected)
+
+	// Create and register tenant handler; any user may read their own tenant's config,
+	// provisioning and managing tenants is reserved to root tenant super admins
+	tenantHandler := tenant.NewTenantHandler(tenant.GetService())
+	tenantHandler.RegisterRoutes(protected)
+	account.Get("/tenant", tenantHandler.GetCurrentTenantHandler)
+
+	// Create and register card vault handler
+	vaultHandler := vault.NewVaultHandler(vault.NewVault())
+	vaultHandler.RegisterRoutes(protected)
+
+	// Create and register general ledger handler
+	ledgerHandler := ledger.NewLedgerHandler(ledger.NewLedger())
+	ledgerHandler.RegisterRoutes(protected)
+
+	// Create and register bank statement reconciliation handler
+	reconcileHandler := reconcile.NewReconcileHandler(reconcile.NewReconciler(reconcile.DefaultDateToleranceDays))
+	reconcileHandler.RegisterRoutes(protected)
+
+	// Create and register recurring payment schedule handler
+	scheduleHandler := payment.NewScheduleHandler(payment.NewScheduler())
+	scheduleHandler.RegisterRoutes(protected)
+
+	// Create and register bulk payment batch handler
+	batchHandler := payment.NewBatchHandler(payment.NewBatchProcessor())
+	batchHandler.RegisterRoutes(protected)
+
+	// Create and register ISO 20022 payment export handler
+	exportHandler := iso20022.NewExportHandler(iso20022.NewExporter())
+	exportHandler.RegisterRoutes(protected)
+
+	// Create and register payment risk review handler
+	riskHandler := risk.NewRiskHandler(risk.NewService())
+	riskHandler.RegisterRoutes(protected)
 
-	// Create and register loan handler
-	loanHandler := loan.NewLoanHandler(s.loan)
-	loanHandler.RegisterRoutes(mux)
+	// Create and register FX rate handler
+	fxHandler := fx.NewFXHandler(fx.NewConverter())
+	fxHandler.RegisterRoutes(protected)
 
 	handler := middleware.ChainMiddleware(
 		mux,
@@ -136,9 +321,7 @@ func (s *Server) createServer(port int) *http.Server {
 		// middleware.CacheMiddleware(middleware.NewCacheConfig()),
 		middleware.ApiLogMiddleware,
 		middleware.TracingMiddleware,
-		middleware.JWTMiddleware([]string{"/swagger", "/api/health", "/api/login", "/api/register", "/api/logout"}),
 		middleware.CircuitBreakerMiddleware(10*time.Second),
-		middleware.RateLimitMiddleware(1, 10),
 		middleware.RequestContextMiddleware,
 		middleware.CorsMiddleware,
 	)
@@ -0,0 +1,236 @@
+package tenant
+
+import (
+	"api/internal/auth"
+	"api/internal/handler"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+)
+
+// TenantHandler handles HTTP requests for tenants
+type TenantHandler struct {
+	service *Service
+}
+
+// NewTenantHandler creates a new TenantHandler
+func NewTenantHandler(service *Service) *TenantHandler {
+	return &TenantHandler{service: service}
+}
+
+// ProvisionTenantHandler godoc
+// @Summary Provision a tenant
+// @Description Create a tenant with its configuration and first administrator. Root tenant super admins only.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param request body ProvisionRequest true "Tenant to provision"
+// @Success 201 {object} Provisioned
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 409 {object} types.ErrorResponse
+// @Router /tenants [post]
+func (h *TenantHandler) ProvisionTenantHandler(w http.ResponseWriter, r *http.Request) {
+	_, actor, ok := h.rootAdmin(w, r)
+	if !ok {
+		return
+	}
+	var req ProvisionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", auth.GetRequestID(r))
+		return
+	}
+
+	provisioned, err := h.service.Provision(req, actor)
+	if err != nil {
+		writeTenantError(w, r, err)
+		return
+	}
+	writeSuccess(w, http.StatusCreated, provisioned, "Tenant provisioned", auth.GetRequestID(r))
+}
+
+// GetTenantsHandler godoc
+// @Summary List tenants
+// @Description List all tenants with their configuration. Root tenant super admins only.
+// @Tags tenants
+// @Produce json
+// @Success 200 {array} Tenant
+// @Failure 403 {object} types.ErrorResponse
+// @Router /tenants [get]
+func (h *TenantHandler) GetTenantsHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := h.rootAdmin(w, r); !ok {
+		return
+	}
+	tenants, err := h.service.List()
+	if err != nil {
+		writeTenantError(w, r, err)
+		return
+	}
+	writeSuccess(w, http.StatusOK, tenants, "", auth.GetRequestID(r))
+}
+
+// GetTenantHandler godoc
+// @Summary Get a tenant
+// @Description Get a tenant with its configuration. Root tenant super admins only.
+// @Tags tenants
+// @Produce json
+// @Param id path int true "Tenant ID"
+// @Success 200 {object} Tenant
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /tenants/{id} [get]
+func (h *TenantHandler) GetTenantHandler(w http.ResponseWriter, r *http.Request) {
+	if _, _, ok := h.rootAdmin(w, r); !ok {
+		return
+	}
+	tenantID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	tenant, err := h.service.Get(tenantID)
+	if err != nil {
+		writeTenantError(w, r, err)
+		return
+	}
+	writeSuccess(w, http.StatusOK, tenant, "", auth.GetRequestID(r))
+}
+
+// UpdateTenantHandler godoc
+// @Summary Update a tenant
+// @Description Change the name, status or configuration of a tenant. Suspending a tenant locks out
+// @Description its users and API keys. Root tenant super admins only.
+// @Tags tenants
+// @Accept json
+// @Produce json
+// @Param id path int true "Tenant ID"
+// @Param request body UpdateRequest true "Changes"
+// @Success 200 {object} Tenant
+// @Failure 400 {object} types.ErrorResponse
+// @Failure 403 {object} types.ErrorResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /tenants/{id} [put]
+func (h *TenantHandler) UpdateTenantHandler(w http.ResponseWriter, r *http.Request) {
+	_, actor, ok := h.rootAdmin(w, r)
+	if !ok {
+		return
+	}
+	tenantID, ok := pathID(w, r)
+	if !ok {
+		return
+	}
+	var req UpdateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request body", auth.GetRequestID(r))
+		return
+	}
+
+	tenant, err := h.service.Update(tenantID, req, actor)
+	if err != nil {
+		writeTenantError(w, r, err)
+		return
+	}
+	writeSuccess(w, http.StatusOK, tenant, "Tenant updated", auth.GetRequestID(r))
+}
+
+// GetCurrentTenantHandler godoc
+// @Summary Get the caller's tenant
+// @Description Get the tenant of the authenticated user with its products, branding and rate limits
+// @Tags tenants
+// @Produce json
+// @Success 200 {object} Tenant
+// @Failure 401 {object} types.ErrorResponse
+// @Router /tenant [get]
+func (h *TenantHandler) GetCurrentTenantHandler(w http.ResponseWriter, r *http.Request) {
+	tenantID, ok := auth.TenantIDFromContext(r.Context())
+	if !ok {
+		writeError(w, http.StatusUnauthorized, ErrNoTenant.Error(), auth.GetRequestID(r))
+		return
+	}
+	tenant, err := h.service.Get(tenantID)
+	if err != nil {
+		writeTenantError(w, r, err)
+		return
+	}
+	writeSuccess(w, http.StatusOK, tenant, "", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the tenant administration routes with the given router
+func (h *TenantHandler) RegisterRoutes(r *router.Router) {
+	r.Post("/tenants", h.ProvisionTenantHandler)
+	r.Get("/tenants", h.GetTenantsHandler)
+	r.Get("/tenants/{id}", h.GetTenantHandler)
+	r.Put("/tenants/{id}", h.UpdateTenantHandler)
+}
+
+// rootAdmin returns the caller when they are a super admin of the root tenant, or writes an error
+func (h *TenantHandler) rootAdmin(w http.ResponseWriter, r *http.Request) (int, string, bool) {
+	userID, username, ok := auth.Principal(r)
+	if !ok {
+		writeError(w, http.StatusUnauthorized, "Invalid or expired token", auth.GetRequestID(r))
+		return 0, "", false
+	}
+	if err := h.service.RequireRootAdmin(userID); err != nil {
+		writeTenantError(w, r, err)
+		return 0, "", false
+	}
+	return userID, username, true
+}
+
+// pathID parses the tenant ID path parameter or writes a 400
+func pathID(w http.ResponseWriter, r *http.Request) (int, bool) {
+	id, err := strconv.Atoi(router.Param(r, "id"))
+	if err != nil || id < 1 {
+		writeError(w, http.StatusBadRequest, "Invalid tenant id", auth.GetRequestID(r))
+		return 0, false
+	}
+	return id, true
+}
+
+// writeTenantError maps tenant errors to responses
+func writeTenantError(w http.ResponseWriter, r *http.Request, err error) {
+	switch {
+	case errors.Is(err, ErrTenantNotFound):
+		writeError(w, http.StatusNotFound, err.Error(), auth.GetRequestID(r))
+	case errors.Is(err, ErrInvalidTenant), errors.Is(err, ErrInvalidConfig), errors.Is(err, ErrInvalidStatus),
+		errors.Is(err, ErrInvalidAdmin), errors.Is(err, ErrRootTenant):
+		writeError(w, http.StatusBadRequest, err.Error(), auth.GetRequestID(r))
+	case errors.Is(err, ErrRootAdminRequired), errors.Is(err, ErrTenantSuspended):
+		writeError(w, http.StatusForbidden, err.Error(), auth.GetRequestID(r))
+	case errors.Is(err, ErrTenantExists), errors.Is(err, ErrAdminExists):
+		writeError(w, http.StatusConflict, err.Error(), auth.GetRequestID(r))
+	default:
+		log.Printf("[error] - Tenant request: %v", err)
+		writeError(w, http.StatusInternalServerError, "Tenant operation failed", auth.GetRequestID(r))
+	}
+}
+
+func writeError(w http.ResponseWriter, code int, message string, requestID string) {
+	resp := handler.NewErrorResponse(
+		code,
+		http.StatusText(code),
+		"TENANT_ERROR",
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeSuccess(w http.ResponseWriter, code int, data interface{}, message string, requestID string) {
+	resp := handler.NewSuccessResponse(
+		code,
+		http.StatusText(code),
+		data,
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
@@ -0,0 +1,144 @@
+package tenant
+
+import (
+	"api/internal/auth"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// TenantColumn is the column that holds the tenant of a row in every tenant-owned table
+const TenantColumn = "tenant_id"
+
+// Scoped database errors
+var (
+	ErrNoTenant      = errors.New("no tenant in context")
+	ErrInvalidQuery  = errors.New("invalid table, column or order in scoped query")
+	ErrTenantColumn  = errors.New("tenant_id is set by the scope and cannot be written")
+	ErrColumnsValues = errors.New("number of columns and values differ")
+)
+
+// identifier matches table and column names; order matches ORDER BY lists
+var (
+	identifier = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+	order      = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*( (?i:asc|desc))?(, ?[A-Za-z_][A-Za-z0-9_]*( (?i:asc|desc))?)*$`)
+)
+
+// Querier is the part of *sql.DB and *sql.Tx used by ScopedDB
+type Querier interface {
+	Query(query string, args ...interface{}) (*sql.Rows, error)
+	QueryRow(query string, args ...interface{}) *sql.Row
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// ScopedDB runs queries on tenant-owned tables restricted to one tenant. Every
+// statement it builds compares tenant_id with the tenant of the context, so rows
+// of other tenants can neither be read nor changed through it.
+type ScopedDB struct {
+	db       Querier
+	tenantID int
+}
+
+// Scope returns a ScopedDB for the tenant of ctx. Without a tenant in ctx it
+// fails with ErrNoTenant rather than running unscoped queries.
+func Scope(ctx context.Context, db Querier) (*ScopedDB, error) {
+	tenantID, ok := auth.TenantIDFromContext(ctx)
+	if !ok {
+		return nil, ErrNoTenant
+	}
+	return &ScopedDB{db: db, tenantID: tenantID}, nil
+}
+
+// TenantID returns the tenant queries are scoped to
+func (s *ScopedDB) TenantID() int {
+	return s.tenantID
+}
+
+// Select queries columns of the tenant's rows of table matching where, which may be
+// empty, sorted by orderBy when it is not empty
+func (s *ScopedDB) Select(table, columns, where, orderBy string, args ...interface{}) (*sql.Rows, error) {
+	if !identifier.MatchString(table) || (orderBy != "" && !order.MatchString(orderBy)) {
+		return nil, ErrInvalidQuery
+	}
+	query := "SELECT " + columns + " FROM " + table + s.where(where)
+	if orderBy != "" {
+		query += " ORDER BY " + orderBy
+	}
+	return s.db.Query(query, s.args(args)...)
+}
+
+// SelectClauses is Select followed by clauses such as GROUP BY, ORDER BY or LIMIT, which
+// come after the tenant condition; clauseArgs fill the placeholders of clauses
+func (s *ScopedDB) SelectClauses(table, columns, where, clauses string, clauseArgs []interface{}, args ...interface{}) (*sql.Rows, error) {
+	if !identifier.MatchString(table) {
+		return nil, ErrInvalidQuery
+	}
+	return s.db.Query("SELECT "+columns+" FROM "+table+s.where(where)+" "+clauses, append(s.args(args), clauseArgs...)...)
+}
+
+// SelectRow queries columns of the tenant's row of table matching where
+func (s *ScopedDB) SelectRow(table, columns, where string, args ...interface{}) (*sql.Row, error) {
+	if !identifier.MatchString(table) {
+		return nil, ErrInvalidQuery
+	}
+	return s.db.QueryRow("SELECT "+columns+" FROM "+table+s.where(where), s.args(args)...), nil
+}
+
+// Insert inserts a row of the tenant into table; values are given in the order of columns
+func (s *ScopedDB) Insert(table string, columns []string, values ...interface{}) (sql.Result, error) {
+	if len(columns) != len(values) {
+		return nil, ErrColumnsValues
+	}
+	if !identifier.MatchString(table) {
+		return nil, ErrInvalidQuery
+	}
+	for _, column := range columns {
+		if !identifier.MatchString(column) {
+			return nil, ErrInvalidQuery
+		}
+		if strings.EqualFold(column, TenantColumn) {
+			return nil, ErrTenantColumn
+		}
+	}
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(columns)+1), ", ")
+	query := fmt.Sprintf("INSERT INTO %s (%s, %s) VALUES (%s)", table, strings.Join(columns, ", "), TenantColumn, placeholders)
+	return s.db.Exec(query, s.args(values)...)
+}
+
+// Update runs set on the tenant's rows of table matching where; args fill the
+// placeholders of set and then those of where
+func (s *ScopedDB) Update(table, set, where string, args ...interface{}) (sql.Result, error) {
+	if !identifier.MatchString(table) {
+		return nil, ErrInvalidQuery
+	}
+	if strings.Contains(strings.ToLower(set), TenantColumn) {
+		return nil, ErrTenantColumn
+	}
+	return s.db.Exec("UPDATE "+table+" SET "+set+s.where(where), s.args(args)...)
+}
+
+// Delete deletes the tenant's rows of table matching where
+func (s *ScopedDB) Delete(table, where string, args ...interface{}) (sql.Result, error) {
+	if !identifier.MatchString(table) {
+		return nil, ErrInvalidQuery
+	}
+	return s.db.Exec("DELETE FROM "+table+s.where(where), s.args(args)...)
+}
+
+// where returns the WHERE clause combining the caller's condition with the tenant;
+// the condition is parenthesised so that an OR in it cannot widen the scope
+func (s *ScopedDB) where(condition string) string {
+	if strings.TrimSpace(condition) == "" {
+		return " WHERE " + TenantColumn + " = ?"
+	}
+	return " WHERE (" + condition + ") AND " + TenantColumn + " = ?"
+}
+
+// args returns a copy of args followed by the tenant, leaving the caller's slice untouched
+func (s *ScopedDB) args(args []interface{}) []interface{} {
+	scoped := make([]interface{}, 0, len(args)+1)
+	return append(append(scoped, args...), s.tenantID)
+}
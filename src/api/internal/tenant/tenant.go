@@ -0,0 +1,343 @@
+package tenant
+
+import (
+	"api/internal/auth"
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// tenantCacheTTL bounds how long another instance may keep serving a suspended tenant
+	tenantCacheTTL = time.Minute
+	// minAdminPasswordLength matches the shortest password a reset accepts
+	minAdminPasswordLength = 8
+)
+
+// Tenant errors
+var (
+	ErrTenantNotFound    = errors.New("tenant not found")
+	ErrTenantExists      = errors.New("a tenant with this slug already exists")
+	ErrInvalidTenant     = errors.New("slug must be 2 to 63 lowercase letters, digits or dashes and name is required")
+	ErrInvalidConfig     = errors.New("invalid tenant config")
+	ErrInvalidStatus     = errors.New("status_id must be 1 (active) or 2 (suspended)")
+	ErrInvalidAdmin      = errors.New("admin_username and an admin_password of at least 8 characters are required")
+	ErrAdminExists       = errors.New("admin username already exists")
+	ErrTenantSuspended   = errors.New("tenant is suspended")
+	ErrRootTenant        = errors.New("the root tenant cannot be suspended")
+	ErrRootAdminRequired = errors.New("only super admins of the root tenant can manage tenants")
+)
+
+var slugPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{1,62}$`)
+
+// adminRoutes are the routes granted to the administrator role of a provisioned tenant.
+// Their handlers only read and change the data of the caller's tenant; routes over data
+// shared by all tenants are left to the root tenant's super admins.
+var adminRoutes = []string{
+	"/api/role",
+	"/api/roles/**",
+	"/api/user-role",
+	"/api/users",
+	"/api/users/{userId}/roles/**",
+	"/api/users/{userId}/permissions",
+	"/api/payments/**",
+	"/api/consents/**",
+	"/loans/**",
+}
+
+// adminFields are the GraphQL fields granted to the administrator role of a provisioned
+// tenant; contacts are scoped to the tenant like the routes above
+var adminFields = []string{"contacts.getById", "contacts.getPagination", "contactMutations.createContact"}
+
+// cachedTenant is a tenant kept by Service until expiresAt
+type cachedTenant struct {
+	tenant    *Tenant
+	expiresAt time.Time
+}
+
+// Service provisions tenants, serves their configuration and resolves the tenant of requests
+type Service struct {
+	repo   *TenantRepo
+	users  *auth.UserRepo
+	perms  *auth.UserPermissionRepo
+	params auth.PasswordParams
+	now    func() time.Time
+
+	mu     sync.RWMutex
+	cached map[int]cachedTenant
+}
+
+var (
+	serviceOnce     sync.Once
+	serviceInstance *Service
+)
+
+// NewService creates a Service with explicit dependencies; params hash the passwords
+// of provisioned administrators
+func NewService(repo *TenantRepo, users *auth.UserRepo, perms *auth.UserPermissionRepo, params auth.PasswordParams) *Service {
+	return &Service{
+		repo:   repo,
+		users:  users,
+		perms:  perms,
+		params: params,
+		now:    time.Now,
+		cached: make(map[int]cachedTenant),
+	}
+}
+
+// GetService returns the shared Service used by the handlers and JWTMiddleware
+func GetService() *Service {
+	serviceOnce.Do(func() {
+		serviceInstance = NewService(NewTenantRepo(), auth.NewUserRepo(), auth.NewUserPermissionRepo(), auth.NewPasswordParams())
+	})
+	return serviceInstance
+}
+
+// List returns all tenants
+func (s *Service) List() ([]*Tenant, error) {
+	return s.repo.GetTenants()
+}
+
+// Get returns a tenant, from the cache when it was read recently
+func (s *Service) Get(tenantID int) (*Tenant, error) {
+	s.mu.RLock()
+	entry, ok := s.cached[tenantID]
+	s.mu.RUnlock()
+	if ok && s.now().Before(entry.expiresAt) {
+		return entry.tenant, nil
+	}
+
+	tenant, err := s.repo.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	s.cached[tenantID] = cachedTenant{tenant: tenant, expiresAt: s.now().Add(tenantCacheTTL)}
+	s.mu.Unlock()
+	return tenant, nil
+}
+
+// Active returns a tenant that may serve requests; ErrTenantSuspended when it is suspended
+func (s *Service) Active(tenantID int) (*Tenant, error) {
+	tenant, err := s.Get(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if tenant.StatusID != StatusActive {
+		return nil, ErrTenantSuspended
+	}
+	return tenant, nil
+}
+
+// Config returns the configuration of a tenant
+func (s *Service) Config(tenantID int) (*Config, error) {
+	tenant, err := s.Get(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	return &tenant.Config, nil
+}
+
+// Attach resolves the tenant of an authenticated request and stores it in the request
+// context: the tenant of the API key's owner, or else the tenant of the access token.
+func (s *Service) Attach(r *http.Request, claims *auth.JwtClaims) (*http.Request, error) {
+	tenantID := auth.DefaultTenantID
+	if key := auth.APIKeyFromContext(r.Context()); key != nil {
+		id, err := s.users.GetUserTenantID(key.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("error resolving tenant: %w", err)
+		}
+		tenantID = id
+	} else if claims != nil {
+		tenantID = claims.Tenant()
+	}
+
+	if _, err := s.Active(tenantID); err != nil {
+		return nil, err
+	}
+	return r.WithContext(auth.WithTenantID(r.Context(), tenantID)), nil
+}
+
+// RequireRootAdmin checks that a user is a super admin of the root tenant
+func (s *Service) RequireRootAdmin(userID int) error {
+	tenantID, err := s.users.GetUserTenantID(userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrRootAdminRequired
+	}
+	if err != nil {
+		return err
+	}
+	if tenantID != auth.DefaultTenantID {
+		return ErrRootAdminRequired
+	}
+	isSuperAdmin, err := s.perms.IsSuperAdmin(userID)
+	if err != nil {
+		return err
+	}
+	if !isSuperAdmin {
+		return ErrRootAdminRequired
+	}
+	return nil
+}
+
+// Provision creates a tenant with an administrator role and its first administrator.
+// The role is not a super admin role: it is granted adminRoutes and adminFields only,
+// so the administrator cannot reach other tenants or data shared by all tenants.
+func (s *Service) Provision(req ProvisionRequest, actor string) (*Provisioned, error) {
+	req.Slug = strings.ToLower(strings.TrimSpace(req.Slug))
+	req.Name = strings.TrimSpace(req.Name)
+	req.AdminUsername = strings.TrimSpace(req.AdminUsername)
+	if !slugPattern.MatchString(req.Slug) || req.Name == "" {
+		return nil, ErrInvalidTenant
+	}
+	if req.AdminUsername == "" || len(req.AdminPassword) < minAdminPasswordLength {
+		return nil, ErrInvalidAdmin
+	}
+	if err := validateConfig(&req.Config); err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.SlugExists(req.Slug)
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		return nil, ErrTenantExists
+	}
+	if _, err := s.users.GetUserByName(req.AdminUsername); err == nil {
+		return nil, ErrAdminExists
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	hash, err := auth.HashPassword(req.AdminPassword, s.params)
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	tenant := &Tenant{
+		Slug:      req.Slug,
+		Name:      req.Name,
+		Config:    req.Config,
+		StatusID:  StatusActive,
+		CreatedAt: now,
+		CreatedBy: actor,
+		UpdatedAt: now,
+		UpdatedBy: actor,
+	}
+	// Role names are unique across tenants, so the slug keeps them apart
+	role := &auth.Role{
+		RoleName:  req.Slug + "-admin",
+		RoleDesc:  "Administrator of " + req.Name,
+		CreatedAt: now,
+		CreatedBy: actor,
+		UpdatedAt: now,
+		UpdatedBy: actor,
+		StatusID:  1,
+	}
+	permissions := make([]*auth.RolePermissions, 0, len(adminRoutes)+len(adminFields))
+	for _, route := range adminRoutes {
+		permissions = append(permissions, &auth.RolePermissions{
+			ResourceTypeID: auth.ResourceTypeAPI,
+			ResourceName:   route,
+			Methods:        []string{"*"},
+			CanRead:        true,
+			CanWrite:       true,
+			CanDelete:      true,
+		})
+	}
+	for _, field := range adminFields {
+		permissions = append(permissions, &auth.RolePermissions{
+			ResourceTypeID: auth.ResourceTypeGraphQL,
+			ResourceName:   field,
+			CanExecute:     true,
+		})
+	}
+	user := &auth.User{
+		Username:  req.AdminUsername,
+		Password:  hash,
+		Email:     strings.TrimSpace(req.AdminEmail),
+		CreatedAt: now,
+		CreatedBy: actor,
+		StatusID:  1,
+	}
+	if err := s.repo.ProvisionTenant(tenant, role, permissions, user); err != nil {
+		return nil, err
+	}
+	return &Provisioned{Tenant: tenant, AdminUserID: user.UserID, AdminRoleID: role.RoleID}, nil
+}
+
+// Update changes the name, status or config of a tenant. Suspending a tenant locks
+// out its users and API keys; the root tenant cannot be suspended.
+func (s *Service) Update(tenantID int, req UpdateRequest, actor string) (*Tenant, error) {
+	tenant, err := s.repo.GetTenant(tenantID)
+	if err != nil {
+		return nil, err
+	}
+	if req.Name != nil {
+		if strings.TrimSpace(*req.Name) == "" {
+			return nil, ErrInvalidTenant
+		}
+		tenant.Name = strings.TrimSpace(*req.Name)
+	}
+	if req.StatusID != nil {
+		if *req.StatusID != StatusActive && *req.StatusID != StatusSuspended {
+			return nil, ErrInvalidStatus
+		}
+		if tenantID == auth.DefaultTenantID && *req.StatusID != StatusActive {
+			return nil, ErrRootTenant
+		}
+		tenant.StatusID = *req.StatusID
+	}
+	if req.Config != nil {
+		if err := validateConfig(req.Config); err != nil {
+			return nil, err
+		}
+		tenant.Config = *req.Config
+	}
+	tenant.UpdatedAt = s.now()
+	tenant.UpdatedBy = actor
+
+	if err := s.repo.UpdateTenant(tenant); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	delete(s.cached, tenantID)
+	s.mu.Unlock()
+	return tenant, nil
+}
+
+// validateConfig checks and normalises a tenant config
+func validateConfig(config *Config) error {
+	codes := make(map[string]bool)
+	for i := range config.Products {
+		product := &config.Products[i]
+		product.Code = strings.TrimSpace(product.Code)
+		if product.Code == "" || codes[product.Code] {
+			return fmt.Errorf("%w: product codes must be set and unique", ErrInvalidConfig)
+		}
+		codes[product.Code] = true
+		if product.MinAmount < 0 || (product.MaxAmount > 0 && product.MaxAmount < product.MinAmount) {
+			return fmt.Errorf("%w: product %s has an invalid amount range", ErrInvalidConfig, product.Code)
+		}
+		if product.MinTerm < 0 || (product.MaxTerm > 0 && product.MaxTerm < product.MinTerm) {
+			return fmt.Errorf("%w: product %s has an invalid term range", ErrInvalidConfig, product.Code)
+		}
+		if product.InterestRate < 0 {
+			return fmt.Errorf("%w: product %s has a negative interest rate", ErrInvalidConfig, product.Code)
+		}
+	}
+	if config.RateLimit.RequestsPerSecond < 0 || config.RateLimit.Burst < 0 {
+		return fmt.Errorf("%w: rate limits cannot be negative", ErrInvalidConfig)
+	}
+	if config.Products == nil {
+		config.Products = []Product{}
+	}
+	return nil
+}
@@ -0,0 +1,198 @@
+package tenant
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// tenantColumns are the tenants columns read by scanTenant
+const tenantColumns = `id, slug, name, config, status_id, created_at, created_by, updated_at, updated_by`
+
+// TenantRepo represents the repository for tenants
+type TenantRepo struct {
+	DB *db.DB
+}
+
+// NewTenantRepo creates a new instance of TenantRepo
+func NewTenantRepo() *TenantRepo {
+	db := db.NewDB()
+	return &TenantRepo{DB: db}
+}
+
+// ProvisionTenant inserts a tenant with its administrator role, the role's permissions and
+// the administrator in one transaction
+func (tr *TenantRepo) ProvisionTenant(tenant *Tenant, role *auth.Role, permissions []*auth.RolePermissions, user *auth.User) error {
+	config, err := json.Marshal(tenant.Config)
+	if err != nil {
+		return fmt.Errorf("error encoding tenant config: %w", err)
+	}
+
+	tx, err := tr.DB.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO tenants (slug, name, config, status_id, created_at, created_by, updated_at, updated_by)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		tenant.Slug, tenant.Name, string(config), tenant.StatusID, tenant.CreatedAt, tenant.CreatedBy, tenant.UpdatedAt, tenant.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("error inserting tenant: %w", err)
+	}
+	tenantID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error inserting tenant: %w", err)
+	}
+
+	result, err = tx.Exec(`
+		INSERT INTO roles (role_name, role_desc, is_super_admin, require_mfa, created_at, created_by, updated_at, updated_by, status_id, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		role.RoleName, role.RoleDesc, role.IsSuperAdmin, role.RequireMFA, role.CreatedAt, role.CreatedBy, role.UpdatedAt, role.UpdatedBy, role.StatusID, tenantID)
+	if err != nil {
+		return fmt.Errorf("error inserting tenant role: %w", err)
+	}
+	roleID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error inserting tenant role: %w", err)
+	}
+
+	for _, permission := range permissions {
+		_, err = tx.Exec(`
+			INSERT INTO role_permissions (role_id, resource_type_id, resource_name, can_execute, can_read, can_write, can_delete,
+				methods, effect, created_at, created_by, updated_at, updated_by, status_id)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+			roleID, permission.ResourceTypeID, permission.ResourceName,
+			permission.CanExecute, permission.CanRead, permission.CanWrite, permission.CanDelete,
+			strings.Join(permission.Methods, ","), auth.EffectAllow,
+			role.CreatedAt, role.CreatedBy, role.UpdatedAt, role.UpdatedBy, 1)
+		if err != nil {
+			return fmt.Errorf("error inserting tenant role permission: %w", err)
+		}
+	}
+
+	result, err = tx.Exec(`
+		INSERT INTO users (user_name, password, salt, created_at, created_by, status_id, email, tenant_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		user.Username, user.Password, user.Salt, user.CreatedAt, user.CreatedBy, user.StatusID, user.Email, tenantID)
+	if err != nil {
+		return fmt.Errorf("error inserting tenant user: %w", err)
+	}
+	userID, err := result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("error inserting tenant user: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		roleID, userID, role.CreatedAt, role.CreatedBy, role.UpdatedAt, role.UpdatedBy, 1)
+	if err != nil {
+		return fmt.Errorf("error inserting tenant user role: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error committing transaction: %w", err)
+	}
+	tenant.ID = int(tenantID)
+	role.RoleID, role.TenantID = int(roleID), int(tenantID)
+	user.UserID, user.TenantID = int(userID), int(tenantID)
+	return nil
+}
+
+// GetTenant retrieves a tenant by its ID; ErrTenantNotFound when there is none
+func (tr *TenantRepo) GetTenant(tenantID int) (*Tenant, error) {
+	row, err := tr.DB.QueryRow("SELECT "+tenantColumns+" FROM tenants WHERE id = ?", tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("error querying tenant: %w", err)
+	}
+	tenant, err := scanTenant(row)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTenantNotFound
+	}
+	return tenant, err
+}
+
+// SlugExists reports whether a tenant uses slug
+func (tr *TenantRepo) SlugExists(slug string) (bool, error) {
+	row, err := tr.DB.QueryRow("SELECT COUNT(*) FROM tenants WHERE slug = ?", slug)
+	if err != nil {
+		return false, fmt.Errorf("error querying tenant: %w", err)
+	}
+	var count int
+	if err := row.Scan(&count); err != nil {
+		return false, fmt.Errorf("error querying tenant: %w", err)
+	}
+	return count > 0, nil
+}
+
+// GetTenants lists all tenants ordered by ID
+func (tr *TenantRepo) GetTenants() ([]*Tenant, error) {
+	rows, err := tr.DB.Query("SELECT " + tenantColumns + " FROM tenants ORDER BY id")
+	if err != nil {
+		return nil, fmt.Errorf("error querying tenants: %w", err)
+	}
+	defer rows.Close()
+
+	tenants := []*Tenant{}
+	for rows.Next() {
+		tenant, err := scanTenant(rows)
+		if err != nil {
+			return nil, err
+		}
+		tenants = append(tenants, tenant)
+	}
+	return tenants, rows.Err()
+}
+
+// UpdateTenant saves the name, config and status of a tenant
+func (tr *TenantRepo) UpdateTenant(tenant *Tenant) error {
+	config, err := json.Marshal(tenant.Config)
+	if err != nil {
+		return fmt.Errorf("error encoding tenant config: %w", err)
+	}
+	_, err = tr.DB.Update(`
+		UPDATE tenants SET name = ?, config = ?, status_id = ?, updated_at = ?, updated_by = ?
+		WHERE id = ?`,
+		tenant.Name, string(config), tenant.StatusID, tenant.UpdatedAt, tenant.UpdatedBy, tenant.ID)
+	if err != nil {
+		return fmt.Errorf("error updating tenant: %w", err)
+	}
+	return nil
+}
+
+// scanTenant scans a row selected with tenantColumns
+func scanTenant(row interface{ Scan(...any) error }) (*Tenant, error) {
+	var tenant Tenant
+	var config string
+	err := row.Scan(
+		&tenant.ID,
+		&tenant.Slug,
+		&tenant.Name,
+		&config,
+		&tenant.StatusID,
+		&tenant.CreatedAt,
+		&tenant.CreatedBy,
+		&tenant.UpdatedAt,
+		&tenant.UpdatedBy,
+	)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error scanning tenant: %w", err)
+	}
+	if config != "" {
+		if err := json.Unmarshal([]byte(config), &tenant.Config); err != nil {
+			return nil, fmt.Errorf("error decoding tenant config: %w", err)
+		}
+	}
+	return &tenant, nil
+}
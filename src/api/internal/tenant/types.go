@@ -0,0 +1,79 @@
+package tenant
+
+import "time"
+
+// Tenant statuses
+const (
+	StatusActive    = 1
+	StatusSuspended = 2
+)
+
+// Tenant is a lending brand hosted on the deployment. Users, roles and their
+// data belong to exactly one tenant.
+type Tenant struct {
+	ID        int       `json:"id"`
+	Slug      string    `json:"slug" example:"acme"`
+	Name      string    `json:"name" example:"Acme Lending"`
+	Config    Config    `json:"config"`
+	StatusID  int       `json:"status_id"`
+	CreatedAt time.Time `json:"created_at"`
+	CreatedBy string    `json:"created_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UpdatedBy string    `json:"updated_by"`
+}
+
+// Config is the configuration of a tenant
+type Config struct {
+	Products  []Product `json:"products"`
+	Branding  Branding  `json:"branding"`
+	RateLimit RateLimit `json:"rate_limit"`
+}
+
+// Product is a loan product a tenant offers
+type Product struct {
+	Code         string  `json:"code" example:"personal"`
+	Name         string  `json:"name" example:"Personal loan"`
+	MinAmount    float64 `json:"min_amount"`
+	MaxAmount    float64 `json:"max_amount"`
+	MinTerm      int     `json:"min_term"`
+	MaxTerm      int     `json:"max_term"`
+	InterestRate float64 `json:"interest_rate"`
+}
+
+// Branding is how a tenant's brand is presented to its borrowers
+type Branding struct {
+	DisplayName  string `json:"display_name"`
+	LogoURL      string `json:"logo_url"`
+	PrimaryColor string `json:"primary_color" example:"#0055ff"`
+	SupportEmail string `json:"support_email"`
+}
+
+// RateLimit is the request rate a tenant's clients are allowed; zero uses the server default
+type RateLimit struct {
+	RequestsPerSecond float64 `json:"requests_per_second"`
+	Burst             int     `json:"burst"`
+}
+
+// ProvisionRequest creates a tenant with its first administrator
+type ProvisionRequest struct {
+	Slug          string `json:"slug" example:"acme"`
+	Name          string `json:"name" example:"Acme Lending"`
+	Config        Config `json:"config"`
+	AdminUsername string `json:"admin_username" example:"acme-admin"`
+	AdminEmail    string `json:"admin_email" example:"admin@acme.example"`
+	AdminPassword string `json:"admin_password"`
+}
+
+// UpdateRequest changes a tenant; fields left out are unchanged
+type UpdateRequest struct {
+	Name     *string `json:"name,omitempty"`
+	StatusID *int    `json:"status_id,omitempty"`
+	Config   *Config `json:"config,omitempty"`
+}
+
+// Provisioned is a newly provisioned tenant with the administrator created for it
+type Provisioned struct {
+	Tenant      *Tenant `json:"tenant"`
+	AdminUserID int     `json:"admin_user_id"`
+	AdminRoleID int     `json:"admin_role_id"`
+}
@@ -0,0 +1,137 @@
+package vault
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NormalizeCardNumber removes spaces and dashes from a card number
+func NormalizeCardNumber(cardNumber string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(cardNumber)
+}
+
+// LuhnValid reports whether the card number passes the Luhn checksum
+func LuhnValid(cardNumber string) bool {
+	if len(cardNumber) == 0 {
+		return false
+	}
+
+	sum := 0
+	double := false
+	for i := len(cardNumber) - 1; i >= 0; i-- {
+		c := cardNumber[i]
+		if c < '0' || c > '9' {
+			return false
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
+
+// DetectBrand detects the card scheme from the IIN prefix and length
+func DetectBrand(cardNumber string) CardBrand {
+	n := len(cardNumber)
+	prefix := func(length int) int {
+		if n < length {
+			return -1
+		}
+		v, err := strconv.Atoi(cardNumber[:length])
+		if err != nil {
+			return -1
+		}
+		return v
+	}
+
+	switch {
+	case strings.HasPrefix(cardNumber, "4") && (n == 13 || n == 16 || n == 19):
+		return BrandVisa
+	case (prefix(2) >= 51 && prefix(2) <= 55 || prefix(4) >= 2221 && prefix(4) <= 2720) && n == 16:
+		return BrandMastercard
+	case (prefix(2) == 34 || prefix(2) == 37) && n == 15:
+		return BrandAmex
+	case (prefix(4) == 6011 || prefix(2) == 65 || prefix(3) >= 644 && prefix(3) <= 649) && n >= 16 && n <= 19:
+		return BrandDiscover
+	case prefix(4) >= 3528 && prefix(4) <= 3589 && n >= 16 && n <= 19:
+		return BrandJCB
+	case prefix(2) == 62 && n >= 16 && n <= 19:
+		return BrandUnionPay
+	}
+	return BrandUnknown
+}
+
+// ParseExpiry parses MM/YY, MM/YYYY, MMYY or MM-YY expiry dates
+func ParseExpiry(expiryDate string) (month int, year int, err error) {
+	value := strings.NewReplacer("/", "", "-", "", " ", "").Replace(expiryDate)
+	if len(value) != 4 && len(value) != 6 {
+		return 0, 0, &ValidationError{Field: "expiry_date", Reason: "must be in MM/YY or MM/YYYY format"}
+	}
+
+	month, err = strconv.Atoi(value[:2])
+	if err != nil || month < 1 || month > 12 {
+		return 0, 0, &ValidationError{Field: "expiry_date", Reason: "invalid month"}
+	}
+
+	year, err = strconv.Atoi(value[2:])
+	if err != nil {
+		return 0, 0, &ValidationError{Field: "expiry_date", Reason: "invalid year"}
+	}
+	if len(value) == 4 {
+		year += 2000
+	}
+	return month, year, nil
+}
+
+// IsExpired reports whether the card is expired at the given time.
+// A card is valid until the end of its expiry month.
+func IsExpired(month, year int, now time.Time) bool {
+	endOfMonth := time.Date(year, time.Month(month)+1, 1, 0, 0, 0, 0, now.Location())
+	return !now.Before(endOfMonth)
+}
+
+// ValidateCard validates the card number, expiry and CVV and returns the normalized number
+func ValidateCard(card CardRequest, now time.Time) (string, CardBrand, int, int, error) {
+	number := NormalizeCardNumber(card.CardNumber)
+	if len(number) < 12 || len(number) > 19 {
+		return "", "", 0, 0, &ValidationError{Field: "card_number", Reason: "must be between 12 and 19 digits"}
+	}
+	if !LuhnValid(number) {
+		return "", "", 0, 0, &ValidationError{Field: "card_number", Reason: "failed checksum validation"}
+	}
+
+	brand := DetectBrand(number)
+	if brand == BrandUnknown {
+		return "", "", 0, 0, &ValidationError{Field: "card_number", Reason: "unsupported card brand"}
+	}
+
+	month, year, err := ParseExpiry(card.ExpiryDate)
+	if err != nil {
+		return "", "", 0, 0, err
+	}
+	if IsExpired(month, year, now) {
+		return "", "", 0, 0, &ValidationError{Field: "expiry_date", Reason: "card is expired"}
+	}
+
+	if card.CVV != "" {
+		cvvLength := 3
+		if brand == BrandAmex {
+			cvvLength = 4
+		}
+		if len(card.CVV) != cvvLength {
+			return "", "", 0, 0, &ValidationError{Field: "cvv", Reason: "invalid length"}
+		}
+		if _, err := strconv.Atoi(card.CVV); err != nil {
+			return "", "", 0, 0, &ValidationError{Field: "cvv", Reason: "must be numeric"}
+		}
+	}
+
+	return number, brand, month, year, nil
+}
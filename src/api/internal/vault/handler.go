@@ -0,0 +1,117 @@
+package vault
+
+import (
+	"api/internal/auth"
+	"api/internal/router"
+	"encoding/json"
+	"errors"
+	"net/http"
+)
+
+// VaultHandler handles HTTP requests for card tokenization
+type VaultHandler struct {
+	vault *Vault
+}
+
+// NewVaultHandler creates a new VaultHandler
+func NewVaultHandler(vault *Vault) *VaultHandler {
+	return &VaultHandler{vault: vault}
+}
+
+// TokenizeCardHandler godoc
+// @Summary Tokenize a card
+// @Description Validate a card and store it in the vault. Only the token and masked values are returned; the CVV is never stored.
+// @Tags vault
+// @Accept json
+// @Produce json
+// @Param card body CardRequest true "Card details"
+// @Success 201 {object} CardToken
+// @Failure 422 {object} types.ErrorResponse
+// @Router /vault/cards [post]
+func (h *VaultHandler) TokenizeCardHandler(w http.ResponseWriter, r *http.Request) {
+	var card CardRequest
+	if err := json.NewDecoder(r.Body).Decode(&card); err != nil {
+		writeError(w, http.StatusBadRequest, "Invalid request payload", auth.GetRequestID(r))
+		return
+	}
+
+	token, err := h.vault.Tokenize(card, auth.Username(r))
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		writeValidationError(w, validationErr, auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to tokenize card", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusCreated, token, "Card tokenized successfully", auth.GetRequestID(r))
+}
+
+// GetCardTokenHandler godoc
+// @Summary Get a card token
+// @Description Get the masked card details for a token created by the caller
+// @Tags vault
+// @Produce json
+// @Param token query string true "Card token"
+// @Success 200 {object} CardToken
+// @Failure 404 {object} types.ErrorResponse
+// @Router /vault/cards [get]
+func (h *VaultHandler) GetCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenValue := r.URL.Query().Get("token")
+	if tokenValue == "" {
+		writeError(w, http.StatusBadRequest, "Token is required", auth.GetRequestID(r))
+		return
+	}
+
+	token, err := h.vault.GetToken(tokenValue, auth.Username(r))
+	if errors.Is(err, ErrTokenNotFound) {
+		writeError(w, http.StatusNotFound, "Card token not found", auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to fetch card token", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, token, "", auth.GetRequestID(r))
+}
+
+// DeleteCardTokenHandler godoc
+// @Summary Delete a card token
+// @Description Remove a card the caller tokenized from the vault
+// @Tags vault
+// @Produce json
+// @Param token query string true "Card token"
+// @Success 200 {object} types.SuccessResponse
+// @Failure 404 {object} types.ErrorResponse
+// @Router /vault/cards [delete]
+func (h *VaultHandler) DeleteCardTokenHandler(w http.ResponseWriter, r *http.Request) {
+	tokenValue := r.URL.Query().Get("token")
+	if tokenValue == "" {
+		writeError(w, http.StatusBadRequest, "Token is required", auth.GetRequestID(r))
+		return
+	}
+
+	err := h.vault.DeleteToken(tokenValue, auth.Username(r))
+	if errors.Is(err, ErrTokenNotFound) {
+		writeError(w, http.StatusNotFound, "Card token not found", auth.GetRequestID(r))
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, "Failed to delete card token", auth.GetRequestID(r))
+		return
+	}
+
+	writeSuccess(w, http.StatusOK, map[string]string{
+		"message": "Card token deleted successfully",
+	}, "Card token deleted successfully", auth.GetRequestID(r))
+}
+
+// RegisterRoutes registers the vault routes with the given router
+func (h *VaultHandler) RegisterRoutes(r *router.Router) {
+	r.Post("/vault/cards", h.TokenizeCardHandler)
+	r.Get("/vault/cards", h.GetCardTokenHandler)
+	r.Delete("/vault/cards", h.DeleteCardTokenHandler)
+}
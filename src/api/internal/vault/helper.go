@@ -0,0 +1,46 @@
+package vault
+
+import (
+	"api/internal/handler"
+	"encoding/json"
+	"net/http"
+)
+
+func writeError(w http.ResponseWriter, code int, message string, requestID string) {
+	resp := handler.NewErrorResponse(
+		code,
+		http.StatusText(code),
+		"VAULT_ERROR",
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
+
+func writeValidationError(w http.ResponseWriter, err *ValidationError, requestID string) {
+	resp := handler.NewErrorResponse(
+		http.StatusUnprocessableEntity,
+		http.StatusText(http.StatusUnprocessableEntity),
+		"INVALID_CARD",
+		"Card validation failed",
+		requestID,
+	).WithDetails(err.Field, err.Reason)
+	writeJSON(w, http.StatusUnprocessableEntity, resp)
+}
+
+func writeJSON(w http.ResponseWriter, code int, data interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(data)
+}
+
+func writeSuccess(w http.ResponseWriter, code int, data interface{}, message string, requestID string) {
+	resp := handler.NewSuccessResponse(
+		code,
+		http.StatusText(code),
+		data,
+		message,
+		requestID,
+	)
+	writeJSON(w, code, resp)
+}
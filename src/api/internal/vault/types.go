@@ -0,0 +1,64 @@
+package vault
+
+import "time"
+
+// CardBrand represents the card scheme detected from the card number
+type CardBrand string
+
+const (
+	BrandVisa       CardBrand = "VISA"
+	BrandMastercard CardBrand = "MASTERCARD"
+	BrandAmex       CardBrand = "AMEX"
+	BrandDiscover   CardBrand = "DISCOVER"
+	BrandJCB        CardBrand = "JCB"
+	BrandUnionPay   CardBrand = "UNIONPAY"
+	BrandUnknown    CardBrand = "UNKNOWN"
+)
+
+// Token status values
+const (
+	TokenStatusActive  = 1
+	TokenStatusDeleted = 0
+)
+
+// CardRequest represents the raw card details submitted for tokenization.
+// It is never persisted or logged as-is.
+type CardRequest struct {
+	CardNumber     string `json:"card_number" example:"4111111111111111"`
+	ExpiryDate     string `json:"expiry_date" example:"12/29"`
+	CVV            string `json:"cvv" example:"123"`
+	CardholderName string `json:"cardholder_name" example:"John Doe"`
+}
+
+// CardToken represents a stored card. Only masked values are exposed in JSON.
+type CardToken struct {
+	Token          string    `json:"token" example:"tok_3f1c9a0e5b7d4c2a8e6f0b1d3c5a7e9f"`
+	Fingerprint    string    `json:"-"`
+	EncryptedCard  string    `json:"-"`
+	Brand          CardBrand `json:"brand" example:"VISA"`
+	MaskedNumber   string    `json:"masked_number" example:"4111****1111"`
+	Last4          string    `json:"last4" example:"1111"`
+	ExpiryMonth    int       `json:"expiry_month" example:"12"`
+	ExpiryYear     int       `json:"expiry_year" example:"2029"`
+	CardholderName string    `json:"cardholder_name,omitempty"`
+	CreatedAt      time.Time `json:"created_at"`
+	CreatedBy      string    `json:"created_by"`
+	StatusID       int       `json:"status_id"`
+}
+
+// CardDetails is the encrypted payload kept in the vault; the CVV is deliberately absent
+type CardDetails struct {
+	CardNumber  string `json:"card_number"`
+	ExpiryMonth int    `json:"expiry_month"`
+	ExpiryYear  int    `json:"expiry_year"`
+}
+
+// ValidationError describes why a card was rejected
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return e.Field + ": " + e.Reason
+}
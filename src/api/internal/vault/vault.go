@@ -0,0 +1,181 @@
+package vault
+
+import (
+	"api/config"
+	"api/internal/converter"
+	"api/internal/security"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrVaultKeyMissing is returned when VAULT_KEY is not configured
+var ErrVaultKeyMissing = errors.New("vault key is not configured")
+
+// ErrTokenNotFound is returned when a token does not exist or was deleted
+var ErrTokenNotFound = errors.New("card token not found")
+
+// Vault tokenizes card details and keeps them encrypted at rest
+type Vault struct {
+	repo *VaultRepo
+	key  string
+	now  func() time.Time
+}
+
+// NewVault creates a new Vault using the VAULT_KEY from configuration
+func NewVault() *Vault {
+	cfg := config.NewConfig()
+	key := ""
+	if cfg != nil {
+		key = cfg.VaultKey
+	}
+	return NewVaultWithRepo(NewVaultRepo(), key)
+}
+
+// NewVaultWithRepo creates a new Vault backed by the given repository and key
+func NewVaultWithRepo(repo *VaultRepo, key string) *Vault {
+	return &Vault{
+		repo: repo,
+		key:  key,
+		now:  time.Now,
+	}
+}
+
+// Tokenize validates a card and stores it encrypted, returning an opaque token.
+// Tokenizing the same card and expiry twice for the same user returns the existing token.
+func (v *Vault) Tokenize(card CardRequest, createdBy string) (*CardToken, error) {
+	if v.key == "" {
+		return nil, ErrVaultKeyMissing
+	}
+
+	number, brand, month, year, err := ValidateCard(card, v.now())
+	if err != nil {
+		return nil, err
+	}
+
+	fingerprint, err := v.fingerprint(number, month, year)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, err := v.repo.GetCardTokenByFingerprint(fingerprint, createdBy)
+	if err == nil {
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(CardDetails{
+		CardNumber:  number,
+		ExpiryMonth: month,
+		ExpiryYear:  year,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	encrypted, err := security.Encrypt(string(payload), v.key)
+	if err != nil {
+		return nil, fmt.Errorf("error encrypting card: %w", err)
+	}
+
+	tokenValue, err := generateToken()
+	if err != nil {
+		return nil, err
+	}
+
+	token := &CardToken{
+		Token:          tokenValue,
+		Fingerprint:    fingerprint,
+		EncryptedCard:  encrypted,
+		Brand:          brand,
+		MaskedNumber:   converter.MaskCreditCardNumer(number),
+		Last4:          number[len(number)-4:],
+		ExpiryMonth:    month,
+		ExpiryYear:     year,
+		CardholderName: card.CardholderName,
+		CreatedAt:      v.now(),
+		CreatedBy:      createdBy,
+		StatusID:       TokenStatusActive,
+	}
+
+	if err := v.repo.InsertCardToken(token); err != nil {
+		return nil, err
+	}
+	return token, nil
+}
+
+// GetToken returns the masked card details for a token created by owner.
+// Tokens of other users are reported as not found.
+func (v *Vault) GetToken(token, owner string) (*CardToken, error) {
+	cardToken, err := v.repo.GetUserCardToken(token, owner)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	return cardToken, err
+}
+
+// Detokenize decrypts the card details for a token.
+// It is meant for payment processors only and must never be exposed over the API.
+func (v *Vault) Detokenize(token string) (*CardDetails, error) {
+	if v.key == "" {
+		return nil, ErrVaultKeyMissing
+	}
+
+	cardToken, err := v.repo.GetCardToken(token)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, ErrTokenNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := security.Decrypt(cardToken.EncryptedCard, v.key)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting card: %w", err)
+	}
+
+	var details CardDetails
+	if err := json.Unmarshal([]byte(plaintext), &details); err != nil {
+		return nil, err
+	}
+	return &details, nil
+}
+
+// DeleteToken removes a token created by owner from the vault
+func (v *Vault) DeleteToken(token, owner string) error {
+	affected, err := v.repo.DeleteCardToken(token, owner)
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return ErrTokenNotFound
+	}
+	return nil
+}
+
+// fingerprint derives a keyed hash so identical cards can be detected without decrypting
+func (v *Vault) fingerprint(number string, month, year int) (string, error) {
+	key, err := hex.DecodeString(v.key)
+	if err != nil {
+		return "", fmt.Errorf("error decoding key: %v", err)
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(fmt.Sprintf("%s|%02d|%04d", number, month, year)))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+func generateToken() (string, error) {
+	bytes := make([]byte, 16)
+	if _, err := rand.Read(bytes); err != nil {
+		return "", fmt.Errorf("error generating token: %v", err)
+	}
+	return "tok_" + hex.EncodeToString(bytes), nil
+}
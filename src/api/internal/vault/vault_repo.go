@@ -0,0 +1,129 @@
+package vault
+
+import (
+	"api/internal/db"
+	"database/sql"
+	"fmt"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+// VaultRepo represents the repository for card token operations
+type VaultRepo struct {
+	DB *db.DB
+}
+
+// NewVaultRepo creates a new instance of VaultRepo
+func NewVaultRepo() *VaultRepo {
+	db := db.NewDB()
+	return &VaultRepo{DB: db}
+}
+
+// InsertCardToken inserts a new card token into the database
+func (vr *VaultRepo) InsertCardToken(token *CardToken) error {
+	_, err := vr.DB.Insert(`
+		INSERT INTO card_tokens (
+			token, fingerprint, encrypted_card, brand, masked_number,
+			last4, expiry_month, expiry_year, cardholder_name,
+			created_at, created_by, status_id
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		token.Token,
+		token.Fingerprint,
+		token.EncryptedCard,
+		token.Brand,
+		token.MaskedNumber,
+		token.Last4,
+		token.ExpiryMonth,
+		token.ExpiryYear,
+		token.CardholderName,
+		token.CreatedAt,
+		token.CreatedBy,
+		token.StatusID,
+	)
+	if err != nil {
+		return fmt.Errorf("error inserting card token: %w", err)
+	}
+	return nil
+}
+
+// GetCardToken retrieves an active card token by its token value
+func (vr *VaultRepo) GetCardToken(token string) (*CardToken, error) {
+	row, err := vr.DB.QueryRow(`
+		SELECT token, fingerprint, encrypted_card, brand, masked_number,
+			last4, expiry_month, expiry_year, cardholder_name,
+			created_at, created_by, status_id
+		FROM card_tokens
+		WHERE token = ? AND status_id = ?`,
+		token, TokenStatusActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCardToken(row)
+}
+
+// GetUserCardToken retrieves an active card token created by the given user
+func (vr *VaultRepo) GetUserCardToken(token, createdBy string) (*CardToken, error) {
+	row, err := vr.DB.QueryRow(`
+		SELECT token, fingerprint, encrypted_card, brand, masked_number,
+			last4, expiry_month, expiry_year, cardholder_name,
+			created_at, created_by, status_id
+		FROM card_tokens
+		WHERE token = ? AND created_by = ? AND status_id = ?`,
+		token, createdBy, TokenStatusActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCardToken(row)
+}
+
+// GetCardTokenByFingerprint retrieves the user's active card token for the same card and expiry
+func (vr *VaultRepo) GetCardTokenByFingerprint(fingerprint, createdBy string) (*CardToken, error) {
+	row, err := vr.DB.QueryRow(`
+		SELECT token, fingerprint, encrypted_card, brand, masked_number,
+			last4, expiry_month, expiry_year, cardholder_name,
+			created_at, created_by, status_id
+		FROM card_tokens
+		WHERE fingerprint = ? AND created_by = ? AND status_id = ?`,
+		fingerprint, createdBy, TokenStatusActive,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return scanCardToken(row)
+}
+
+// DeleteCardToken soft deletes a user's card token and wipes its encrypted payload
+func (vr *VaultRepo) DeleteCardToken(token, createdBy string) (int64, error) {
+	result, err := vr.DB.Update(
+		"UPDATE card_tokens SET status_id = ?, encrypted_card = '' WHERE token = ? AND created_by = ? AND status_id = ?",
+		TokenStatusDeleted, token, createdBy, TokenStatusActive,
+	)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+func scanCardToken(row *sql.Row) (*CardToken, error) {
+	var token CardToken
+	err := row.Scan(
+		&token.Token,
+		&token.Fingerprint,
+		&token.EncryptedCard,
+		&token.Brand,
+		&token.MaskedNumber,
+		&token.Last4,
+		&token.ExpiryMonth,
+		&token.ExpiryYear,
+		&token.CardholderName,
+		&token.CreatedAt,
+		&token.CreatedBy,
+		&token.StatusID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
@@ -8,6 +8,7 @@ type LogModel struct {
 	LogId string `json:"log_id"`
 	Timestamp time.Time `json:"timestamp"`
 	UserId int `json:"user_id"`
+	ActorId int `json:"actor_id,omitempty"`
 	Actions string `json:"action"`
 	Resource string `json:"resource"`
 	Status string `json:"status"`
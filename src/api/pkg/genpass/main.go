@@ -1,25 +1,47 @@
 package main
 
 import (
+	"api/internal/auth"
+	"flag"
 	"fmt"
+	"math"
 	"os"
-	"api/internal/auth"
 )
 
 func main() {
+	params := auth.DefaultPasswordParams
+	memory := flag.Uint("memory", uint(params.Memory), "argon2id memory in KiB")
+	iterations := flag.Uint("iterations", uint(params.Iterations), "argon2id iterations")
+	threads := flag.Uint("threads", uint(params.Parallelism), "argon2id parallelism")
+	flag.Parse()
 
-	fmt.Println(os.Args)
-	if len(os.Args) < 3 {
-		fmt.Println("Please provide user and password")
-		return
+	if flag.NArg() < 2 {
+		fmt.Println("Usage: genpass [-memory KiB] [-iterations n] [-threads n] <user> <password>")
+		os.Exit(1)
 	}
 
-	user := os.Args[1]
-	password := os.Args[2]
-	fmt.Printf("User :%s, Password: %s", user, password)
+	user := flag.Arg(0)
+	password := flag.Arg(1)
 
-	salt := auth.GenerateRandomSalt(8)
-	userhash := user+password +  salt
+	if *memory < 1 || *memory > math.MaxUint32 || *iterations < 1 || *iterations > math.MaxUint32 {
+		fmt.Println("Memory and iterations must be between 1 and", uint32(math.MaxUint32))
+		os.Exit(1)
+	}
+	if *threads < 1 || *threads > math.MaxUint8 {
+		fmt.Println("Threads must be between 1 and", math.MaxUint8)
+		os.Exit(1)
+	}
+
+	params.Memory = uint32(*memory)
+	params.Iterations = uint32(*iterations)
+	params.Parallelism = uint8(*threads)
+
+	hash, err := auth.HashPassword(password, params)
+	if err != nil {
+		fmt.Println("Failed to hash password:", err)
+		os.Exit(1)
+	}
 
-	fmt.Printf("\n Salt:%s, Hash:%s", salt, auth.HashString(userhash) )
+	// The salt is embedded in the hash, so the users.salt column stays empty
+	fmt.Printf("User: %s\nSalt: \nHash: %s\n", user, hash)
 }
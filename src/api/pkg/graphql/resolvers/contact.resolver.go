@@ -60,7 +60,7 @@ func CreateContactResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Insert Contact to the database
-	id, err := contactRepo.InsertContact(&contactInput)
+	id, err := contactRepo.InsertContact(params.Context, &contactInput)
 	if err != nil {
 		return nil, err
 	}
@@ -107,7 +107,7 @@ func CreateContactsResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Insert Contact to the database
-	total, err := contactRepo.InsertContacts(contacts)
+	total, err := contactRepo.InsertContacts(params.Context, contacts)
 	fmt.Println("total", total)
 	if err != nil {
 		return nil, err
@@ -145,7 +145,7 @@ func GetContactResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Fetch contacts from the database
-	contacts, err := contactRepo.GetContactsBySearchText(searchText, limit, offset)
+	contacts, err := contactRepo.GetContactsBySearchText(params.Context, searchText, limit, offset)
 	if err != nil {
 		return nil, err
 	}
@@ -175,7 +175,7 @@ func GetContactsPaginationResolve(params graphql.ResolveParams) (interface{}, er
 	contactRepo := contact.NewContactRepo()
 
 	// Fetch contacts from the database
-	contacts, pager, err := contactRepo.GetContactsBySearchTextPagination(searchText, page, pageSize)
+	contacts, pager, err := contactRepo.GetContactsBySearchTextPagination(params.Context, searchText, page, pageSize)
 	var contactPagination = models.ContactPaginationModel{
 		Contacts:   contacts,
 		Pagination: pager,
@@ -195,7 +195,7 @@ func GetContactByIdResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Fetch contacts from the database
-	contact, err := contactRepo.GetContactByID(id)
+	contact, err := contactRepo.GetContactByID(params.Context, id)
 	if err != nil {
 		return nil, err
 	}
@@ -232,7 +232,7 @@ func UpdateContactResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Update Contact to the database
-	_, err := contactRepo.UpdateContact(&contactInput)
+	_, err := contactRepo.UpdateContact(params.Context, &contactInput)
 	if err != nil {
 		return nil, err
 	}
@@ -246,7 +246,7 @@ func DeleteContactResolve(params graphql.ResolveParams) (interface{}, error) {
 	contactRepo := contact.NewContactRepo()
 
 	// Delete Contact to the database
-	_, err := contactRepo.DeleteContact(contact_id)
+	_, err := contactRepo.DeleteContact(params.Context, contact_id)
 	if err != nil {
 		return nil, err
 	}
@@ -1,6 +1,7 @@
 package graphql
 
 import (
+	"api/internal/auth"
 	"api/internal/cache"
 	"api/internal/monitoring"
 	"api/internal/subscription"
@@ -59,14 +60,12 @@ var ContactQueriesType = graphql.NewObject(graphql.ObjectConfig{
 		"getPagination": &graphql.Field{
 			Type: ContactPaginationGraphQLType,
 			Args: SearhTextPaginationQueryArgument,
-			Resolve:// auth.AuthorizeResolverClean("contacts.getPagination", cache.GetCacheResolver(resolvers.GetContactsPaginationResolve)),
-			 monitoring.TraceResolver(cache.GetCacheResolver(resolvers.GetContactsPaginationResolve)),
+			Resolve: monitoring.TraceResolver(auth.AuthorizeResolverClean("contacts.getPagination", cache.GetCacheResolver(resolvers.GetContactsPaginationResolve))),
 		},
 		"getById": &graphql.Field{
 			Type: ContactGraphQLType,
 			Args: IdArgument,
-			Resolve:// auth.AuthorizeResolverClean("contacts.getById", cache.GetCacheResolver(resolvers.GetContactByIdResolve)),
-			 monitoring.TraceResolver(cache.GetCacheResolver(resolvers.GetContactByIdResolve)),
+			Resolve: monitoring.TraceResolver(auth.AuthorizeResolverClean("contacts.getById", cache.GetCacheResolver(resolvers.GetContactByIdResolve))),
 		},
 	},
 })
@@ -95,8 +94,7 @@ var ContactMutationsType = graphql.NewObject(graphql.ObjectConfig{
 		"createContact": &graphql.Field{
 			Type: ContactGraphQLType,
 			Args: CreateContactArgument,
-			Resolve:// auth.AuthorizeResolverClean("contactMutations.createContact", resolvers.CreateContactResolve),
-			monitoring.TraceResolver(cache.GetCacheResolver(resolvers.CreateContactResolve)),
+			Resolve: monitoring.TraceResolver(auth.AuthorizeResolverClean("contactMutations.createContact", cache.GetCacheResolver(resolvers.CreateContactResolve))),
 		},
 		"createContacts": &graphql.Field{
 			Type:    StatusGraphQLType,
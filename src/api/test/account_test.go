@@ -0,0 +1,179 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/mailer"
+	"database/sql"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const accountSchema = `
+CREATE TABLE users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT NOT NULL,
+    password TEXT NOT NULL,
+    salt TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL,
+    email TEXT,
+    email_verified_at DATETIME,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE action_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    purpose TEXT NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    created_at DATETIME NOT NULL
+);`
+
+// outbox is a mailer that keeps the messages it is asked to send
+type outbox struct {
+	messages []*mailer.Message
+}
+
+func (o *outbox) Send(msg *mailer.Message) error {
+	o.messages = append(o.messages, msg)
+	return nil
+}
+
+var linkToken = regexp.MustCompile(`\?token=(\S+)`)
+
+// lastToken returns the token in the link of the newest message
+func (o *outbox) lastToken(t *testing.T) string {
+	assert.NotEmpty(t, o.messages)
+	match := linkToken.FindStringSubmatch(o.messages[len(o.messages)-1].TextBody)
+	assert.Len(t, match, 2)
+	token, err := url.QueryUnescape(match[1])
+	assert.NoError(t, err)
+	return token
+}
+
+func setupTestAccounts(t *testing.T) (*auth.AccountService, *auth.UserRepo, *outbox) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(accountSchema)
+	assert.NoError(t, err)
+
+	keys, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmEdDSA, 0, time.Hour)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	mails := &outbox{}
+	service := auth.NewAccountServiceWithRepo(users, &auth.ActionTokenRepo{DB: database}, keys, mails, nil, "https://app.example.com", cheapPasswordParams)
+
+	user := &auth.User{Username: "alice", Password: "old", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1, Email: "alice@example.com"}
+	assert.NoError(t, users.CreateUser(user))
+	assert.NotZero(t, user.UserID)
+	return service, users, mails
+}
+
+func TestPasswordReset(t *testing.T) {
+	service, users, mails := setupTestAccounts(t)
+
+	// Unknown addresses are ignored without an error
+	assert.NoError(t, service.RequestPasswordReset("nobody@example.com"))
+	assert.Empty(t, mails.messages)
+
+	assert.NoError(t, service.RequestPasswordReset("ALICE@example.com"))
+	assert.Len(t, mails.messages, 1)
+	msg := mails.messages[0]
+	assert.Equal(t, []string{"alice@example.com"}, msg.To)
+	assert.Equal(t, "Reset your password", msg.Subject)
+	assert.Contains(t, msg.TextBody, "https://app.example.com/reset-password?token=")
+	assert.Contains(t, msg.HTMLBody, "expires in 1 hour")
+	first := mails.lastToken(t)
+
+	// A newer link replaces the older one
+	assert.NoError(t, service.RequestPasswordReset("alice@example.com"))
+	token := mails.lastToken(t)
+	_, err := service.ResetPassword(first, "new-password")
+	assert.ErrorIs(t, err, auth.ErrInvalidActionToken)
+
+	_, err = service.ResetPassword(token, "short")
+	assert.ErrorIs(t, err, auth.ErrWeakPassword)
+
+	user, err := service.ResetPassword(token, "new-password")
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", user.Username)
+
+	stored, err := users.GetUserByName("alice")
+	assert.NoError(t, err)
+	ok, _, err := auth.VerifyPassword(stored, "new-password", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// Links are single use
+	_, err = service.ResetPassword(token, "another-password")
+	assert.ErrorIs(t, err, auth.ErrInvalidActionToken)
+}
+
+func TestEmailVerification(t *testing.T) {
+	service, users, mails := setupTestAccounts(t)
+
+	user, err := users.GetUserByName("alice")
+	assert.NoError(t, err)
+	assert.Nil(t, user.EmailVerifiedAt)
+
+	assert.NoError(t, service.SendVerification(user))
+	assert.Equal(t, "Verify your email address", mails.messages[0].Subject)
+	token := mails.lastToken(t)
+
+	// A verification token cannot reset a password
+	_, err = service.ResetPassword(token, "new-password")
+	assert.ErrorIs(t, err, auth.ErrInvalidActionToken)
+
+	verified, err := service.VerifyEmail(token)
+	assert.NoError(t, err)
+	assert.NotNil(t, verified.EmailVerifiedAt)
+
+	_, err = service.VerifyEmail(token)
+	assert.ErrorIs(t, err, auth.ErrInvalidActionToken)
+
+	// Verified addresses get no more links
+	assert.NoError(t, service.ResendVerification("alice@example.com"))
+	assert.Len(t, mails.messages, 1)
+	assert.ErrorIs(t, service.SendVerification(verified), auth.ErrEmailAlreadyVerified)
+}
+
+func TestFileMailer(t *testing.T) {
+	dir := t.TempDir()
+	m := mailer.NewFileMailer(dir, "no-reply@example.com")
+
+	msg, err := mailer.Render("verify_email", map[string]string{
+		"Username":  "<bob>",
+		"Email":     "bob@example.com",
+		"Link":      "https://app.example.com/verify-email?token=abc",
+		"ExpiresIn": "24 hours",
+	}, "bob@example.com")
+	assert.NoError(t, err)
+	assert.Contains(t, msg.HTMLBody, "&lt;bob&gt;", "HTML bodies are escaped")
+	assert.Contains(t, msg.TextBody, "Hello <bob>")
+	assert.NoError(t, m.Send(msg))
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.eml"))
+	assert.NoError(t, err)
+	assert.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "From: no-reply@example.com\r\nTo: bob@example.com\r\n"))
+	assert.Contains(t, string(content), "multipart/alternative")
+
+	assert.Error(t, m.Send(&mailer.Message{Subject: "no recipient"}))
+}
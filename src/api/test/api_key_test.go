@@ -0,0 +1,164 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const apiKeySchema = `
+CREATE TABLE roles (role_id INTEGER PRIMARY KEY, role_name TEXT NOT NULL, is_super_admin BOOLEAN NOT NULL DEFAULT 0);
+CREATE TABLE user_roles (user_role_id INTEGER PRIMARY KEY AUTOINCREMENT, role_id INTEGER NOT NULL, user_id INTEGER NOT NULL);
+CREATE TABLE role_permissions (
+    role_permission_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INTEGER NOT NULL,
+    resource_type_id INTEGER NOT NULL,
+    resource_name TEXT NOT NULL,
+    can_execute BOOLEAN NOT NULL DEFAULT 0,
+    can_read BOOLEAN NOT NULL DEFAULT 0,
+    can_write BOOLEAN NOT NULL DEFAULT 0,
+    can_delete BOOLEAN NOT NULL DEFAULT 0,
+    methods TEXT NOT NULL DEFAULT '',
+    effect TEXT NOT NULL DEFAULT 'allow',
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    created_by TEXT NOT NULL DEFAULT 'test',
+    updated_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_by TEXT NOT NULL DEFAULT 'test',
+    status_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE VIEW vw_user_permissions AS
+SELECT ur.user_id, r.role_id, r.role_name, r.is_super_admin, rp.role_permission_id, rp.resource_type_id,
+       rp.resource_name, rp.can_execute, rp.can_read, rp.can_write, rp.can_delete
+FROM user_roles ur
+JOIN roles r ON ur.role_id = r.role_id
+JOIN role_permissions rp ON r.role_id = rp.role_id;
+CREATE TABLE api_keys (
+    key_id TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    name TEXT NOT NULL,
+    prefix TEXT NOT NULL,
+    scopes TEXT NOT NULL,
+    key_hash TEXT NOT NULL UNIQUE,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    expires_at DATETIME,
+    last_used_at DATETIME,
+    last_used_ip TEXT,
+    revoked_at DATETIME
+);
+INSERT INTO roles (role_id, role_name, is_super_admin) VALUES (1, 'admin', 1), (2, 'clerk', 0);
+INSERT INTO user_roles (role_id, user_id) VALUES (1, 1), (2, 2);
+INSERT INTO role_permissions (role_id, resource_type_id, resource_name, can_read, can_write)
+VALUES (2, 1, '/api/payments', 1, 1);`
+
+func setupTestAPIKeys(t *testing.T) (*auth.APIKeyService, *sql.DB) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(apiKeySchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	rules := auth.NewPermissionEvaluator(&auth.RoleRepo{DB: database}, 0)
+	return auth.NewAPIKeyService(&auth.APIKeyRepo{DB: database}, &auth.UserPermissionRepo{DB: database}, rules), conn
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	service, _ := setupTestAPIKeys(t)
+
+	// Scopes are limited to the owner's role permissions
+	_, err := service.Create(auth.CreateAPIKeyRequest{Name: "sync", UserID: 2, Scopes: []string{"/api/payments:delete"}}, "clerk")
+	assert.ErrorIs(t, err, auth.ErrScopeNotAllowed)
+	_, err = service.Create(auth.CreateAPIKeyRequest{Name: "sync", UserID: 2, Scopes: []string{"*:read"}}, "clerk")
+	assert.ErrorIs(t, err, auth.ErrScopeNotAllowed)
+	_, err = service.Create(auth.CreateAPIKeyRequest{Name: "sync", UserID: 2, Scopes: []string{"/api/payments"}}, "clerk")
+	assert.ErrorIs(t, err, auth.ErrInvalidScope)
+	_, err = service.Create(auth.CreateAPIKeyRequest{Name: " ", UserID: 2, Scopes: []string{"/api/payments:read"}}, "clerk")
+	assert.ErrorIs(t, err, auth.ErrAPIKeyName)
+
+	created, err := service.Create(auth.CreateAPIKeyRequest{Name: "sync", UserID: 2, Scopes: []string{"/api/payments:read"}}, "clerk")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(created.Key, created.Prefix+"_"))
+	assert.True(t, created.Allows("/api/payments", auth.ActionRead))
+	assert.False(t, created.Allows("/api/payments", auth.ActionWrite))
+	assert.False(t, created.Allows("/api/loans", auth.ActionRead))
+
+	// Super admins may grant any scope
+	admin, err := service.Create(auth.CreateAPIKeyRequest{Name: "ops", UserID: 1, Scopes: []string{"*:*"}}, "admin")
+	assert.NoError(t, err)
+	assert.True(t, admin.Allows("/api/loans", auth.ActionDelete))
+}
+
+func TestAPIKeyAuthenticate(t *testing.T) {
+	service, conn := setupTestAPIKeys(t)
+
+	created, err := service.Create(auth.CreateAPIKeyRequest{Name: "sync", UserID: 2, Scopes: []string{"/api/payments:read", "/api/payments:write"}, ExpiresInDays: 30}, "clerk")
+	assert.NoError(t, err)
+	assert.NotNil(t, created.ExpiresAt)
+
+	key, err := service.Authenticate(created.Key, "10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, key.UserID)
+	assert.Equal(t, []string{"/api/payments:read", "/api/payments:write"}, key.Scopes)
+
+	// The key value is not stored, and last use is tracked
+	keys, err := service.List(2)
+	assert.NoError(t, err)
+	assert.Len(t, keys, 1)
+	assert.NotContains(t, keys[0].KeyHash, created.Key)
+	assert.NotNil(t, keys[0].LastUsedAt)
+	assert.Equal(t, "10.0.0.1", keys[0].LastUsedIP)
+
+	for _, value := range []string{"", "not-a-key", created.Key + "x", created.Prefix + "_wrongsecret"} {
+		_, err = service.Authenticate(value, "10.0.0.1")
+		assert.ErrorIs(t, err, auth.ErrInvalidAPIKey, value)
+	}
+
+	// Expired keys are refused
+	_, err = conn.Exec("UPDATE api_keys SET expires_at = ? WHERE key_id = ?", time.Now().Add(-time.Minute).UTC(), created.ID)
+	assert.NoError(t, err)
+	_, err = service.Authenticate(created.Key, "10.0.0.1")
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+
+	// Revoked keys are refused
+	other, err := service.Create(auth.CreateAPIKeyRequest{Name: "report", UserID: 2, Scopes: []string{"/api/payments:read"}}, "clerk")
+	assert.NoError(t, err)
+	assert.NoError(t, service.Revoke(other.ID))
+	assert.ErrorIs(t, service.Revoke(other.ID), auth.ErrAPIKeyNotFound)
+	_, err = service.Authenticate(other.Key, "10.0.0.1")
+	assert.ErrorIs(t, err, auth.ErrInvalidAPIKey)
+}
+
+func TestRequestPrincipal(t *testing.T) {
+	// Without a key or token the request acts as the system
+	r := httptest.NewRequest(http.MethodPost, "/api/vault/cards", nil)
+	_, _, ok := auth.Principal(r)
+	assert.False(t, ok)
+	assert.Equal(t, "system", auth.Username(r))
+
+	// Tokens read by the middleware from the header or the cookie
+	r = r.WithContext(auth.WithClaims(r.Context(), &auth.JwtClaims{UserID: 7, Username: "alice"}))
+	userID, username, ok := auth.Principal(r)
+	assert.True(t, ok)
+	assert.Equal(t, 7, userID)
+	assert.Equal(t, "alice", username)
+
+	// API keys act as their owner
+	r = httptest.NewRequest(http.MethodPost, "/api/vault/cards", nil)
+	r = r.WithContext(auth.WithAPIKey(r.Context(), &auth.APIKey{UserID: 9, Prefix: "fk_ab12"}))
+	userID, username, ok = auth.Principal(r)
+	assert.True(t, ok)
+	assert.Equal(t, 9, userID)
+	assert.Equal(t, "apikey:fk_ab12", auth.Username(r))
+	assert.Equal(t, username, auth.Username(r))
+}
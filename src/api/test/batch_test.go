@@ -0,0 +1,402 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/payment"
+	"api/internal/risk"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// The payments check lets a test force one row to fail on insert
+const batchSchema = `
+CREATE TABLE payments (
+    payment_id TEXT PRIMARY KEY,
+    id TEXT,
+    amount REAL NOT NULL,
+    payment_method TEXT NOT NULL,
+    payment_date TEXT,
+    pay_to TEXT,
+    note TEXT,
+    status TEXT,
+    description TEXT,
+    currency TEXT,
+    base_currency TEXT,
+    base_amount REAL,
+    fx_rate REAL,
+    fx_rate_date TEXT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    tenant_id INTEGER NOT NULL DEFAULT 1,
+    CHECK (pay_to <> 'Broken Ltd')
+);
+CREATE TABLE payment_batches (
+    batch_id TEXT PRIMARY KEY,
+    file_name TEXT NOT NULL DEFAULT '',
+    format TEXT NOT NULL,
+    mode TEXT NOT NULL,
+    status TEXT NOT NULL,
+    total_rows INTEGER NOT NULL,
+    valid_rows INTEGER NOT NULL,
+    invalid_rows INTEGER NOT NULL,
+    inserted_rows INTEGER NOT NULL DEFAULT 0,
+    failed_rows INTEGER NOT NULL DEFAULT 0,
+    execute_at DATETIME,
+    executed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE payment_batch_rows (
+    batch_id TEXT NOT NULL,
+    row_number INTEGER NOT NULL,
+    payment_id TEXT NOT NULL DEFAULT '',
+    amount REAL NOT NULL DEFAULT 0,
+    currency TEXT NOT NULL DEFAULT '',
+    pay_to TEXT NOT NULL DEFAULT '',
+    payment_method TEXT NOT NULL DEFAULT '',
+    payment_date TEXT NOT NULL DEFAULT '',
+    note TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    status TEXT NOT NULL,
+    errors TEXT NOT NULL DEFAULT 'null',
+    PRIMARY KEY (batch_id, row_number)
+);
+CREATE TABLE risk_assessments (
+    assessment_id TEXT PRIMARY KEY,
+    payment_id TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    pay_to TEXT NOT NULL,
+    amount REAL NOT NULL,
+    score INTEGER NOT NULL,
+    decision TEXT NOT NULL,
+    triggered_rules TEXT NOT NULL,
+    rules_version TEXT NOT NULL DEFAULT '',
+    review_status TEXT NOT NULL DEFAULT '',
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    review_note TEXT,
+    created_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE fx_rates (
+    base TEXT NOT NULL,
+    quote TEXT NOT NULL,
+    rate REAL NOT NULL,
+    effective_date TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    imported_at DATETIME NOT NULL,
+    imported_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (base, quote, effective_date)
+);`
+
+// batchRiskRules hold payments to denied payees, large payments and a second payment to a payee within the hour
+var batchRiskRules = risk.RuleSet{
+	Version:          "batch-test",
+	ReviewThreshold:  70,
+	Velocity:         []risk.VelocityRule{{Name: "payee_hourly_count", Scope: risk.ScopePayee, Window: time.Hour, MaxCount: 1, Score: 70}},
+	AmountThresholds: []risk.AmountThreshold{{Name: "very_large_amount", MinAmount: 10000, Score: 70}},
+	DenyList:         risk.DenyListRule{Score: 100, Payees: []string{"Blocked Ltd"}},
+}
+
+const batchCSV = `amount,currency,pay_to,payment_method,note
+100.50,usd,ACME Ltd,bank_transfer,invoice 1
+abc,USD,ACME Ltd,bank_transfer,
+250,USD,Broken Ltd,bank_transfer,
+75,EUR,Globex,e_wallet,invoice 2
+`
+
+func setupTestBatches(t *testing.T) (*payment.BatchProcessor, *sql.DB) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(batchSchema)
+	assert.NoError(t, err)
+
+	converter := fx.NewConverterWithRepo(&fx.FXRepo{DB: &db.DB{Connection: conn}}, "usd")
+	_, err = converter.Import("rates.csv", "", strings.NewReader("base,quote,rate,effective_date\nEUR,USD,1.10,2025-01-01\n"), "tester")
+	assert.NoError(t, err)
+
+	rules := batchRiskRules
+	riskRepo := &risk.RiskRepo{DB: &db.DB{Connection: conn}}
+	riskService := risk.NewServiceWithEngine(risk.NewEngine(&rules, riskRepo), riskRepo)
+	return payment.NewBatchProcessorWithRepo(&payment.BatchRepo{DB: &db.DB{Connection: conn}}, riskService, converter), conn
+}
+
+func countPayments(t *testing.T, conn *sql.DB) int {
+	var count int
+	assert.NoError(t, conn.QueryRow("SELECT COUNT(*) FROM payments").Scan(&count))
+	return count
+}
+
+func TestParseBatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		format   payment.BatchFormat
+		data     string
+		statuses []payment.RowStatus
+		wantErr  bool
+	}{
+		{
+			name:     "CSV rows",
+			format:   payment.BatchCSV,
+			data:     batchCSV,
+			statuses: []payment.RowStatus{payment.RowValid, payment.RowInvalid, payment.RowValid, payment.RowValid},
+		},
+		{
+			name:   "JSON lines",
+			format: payment.BatchJSONL,
+			data: `{"amount": 10, "currency": "USD", "pay_to": "ACME Ltd", "payment_method": "cash"}
+{"amount": 10.123, "currency": "US", "pay_to": "", "payment_method": "barter"}
+not json
+`,
+			statuses: []payment.RowStatus{payment.RowValid, payment.RowInvalid, payment.RowInvalid},
+		},
+		{name: "Missing column", format: payment.BatchCSV, data: "amount,currency\n1,USD\n", wantErr: true},
+		{name: "No rows", format: payment.BatchJSONL, data: "\n\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rows, err := payment.ParseBatch(tt.format, []byte(tt.data))
+			if tt.wantErr {
+				assert.ErrorIs(t, err, payment.ErrInvalidBatch)
+				return
+			}
+			assert.NoError(t, err)
+
+			var statuses []payment.RowStatus
+			for _, row := range rows {
+				statuses = append(statuses, row.Status)
+			}
+			assert.Equal(t, tt.statuses, statuses)
+		})
+	}
+
+	rows, err := payment.ParseBatch(payment.BatchJSONL, []byte(`{"amount": 10.123, "currency": "US", "pay_to": "", "payment_method": "barter"}`))
+	assert.NoError(t, err)
+	assert.Len(t, rows[0].Errors, 4)
+
+	format, err := payment.DetectBatchFormat("", "", "payouts.ndjson", nil)
+	assert.NoError(t, err)
+	assert.Equal(t, payment.BatchJSONL, format)
+}
+
+func TestBatchExecutionModes(t *testing.T) {
+	t.Run("Per row", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(batchCSV), Mode: "per_row"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchPartial, batch.Status)
+		assert.Equal(t, 3, batch.ValidRows)
+		assert.Equal(t, 1, batch.InvalidRows)
+		assert.Equal(t, 2, batch.InsertedRows)
+		assert.Equal(t, 1, batch.FailedRows)
+		assert.Equal(t, 2, countPayments(t, conn))
+
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.RowInserted, stored.Rows[0].Status)
+		assert.Equal(t, "USD", stored.Rows[0].Currency)
+		assert.Equal(t, payment.RowInvalid, stored.Rows[1].Status)
+		assert.Equal(t, payment.RowFailed, stored.Rows[2].Status)
+		assert.Equal(t, payment.RowInserted, stored.Rows[3].Status)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(batchCSV), Mode: "atomic"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchFailed, batch.Status)
+		assert.Equal(t, 0, batch.InsertedRows)
+		assert.Equal(t, 0, countPayments(t, conn))
+
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.RowSkipped, stored.Rows[0].Status)
+		assert.Equal(t, payment.RowFailed, stored.Rows[2].Status)
+		assert.Equal(t, payment.RowSkipped, stored.Rows[3].Status)
+	})
+
+	t.Run("Scheduled batch can be cancelled", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+		executeAt := time.Now().Add(time.Hour)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(batchCSV), ExecuteAt: &executeAt}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchPending, batch.Status)
+
+		cancelled, err := processor.Cancel(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchCancelled, cancelled.Status)
+		assert.Equal(t, payment.RowSkipped, cancelled.Rows[0].Status)
+
+		_, err = processor.Execute(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.ErrorIs(t, err, payment.ErrBatchState)
+		_, err = processor.Cancel(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.ErrorIs(t, err, payment.ErrBatchState)
+		assert.Equal(t, 0, countPayments(t, conn))
+	})
+}
+
+const riskyBatchCSV = `amount,currency,pay_to,payment_method
+100,USD,ACME Ltd,bank_transfer
+50,USD,Blocked Ltd,bank_transfer
+20000,USD,Initech,bank_transfer
+10,USD,Globex,cash
+20,USD,Globex,cash
+30,USD,Broken Ltd,cash
+`
+
+func TestBatchRiskReview(t *testing.T) {
+	paymentStatus := func(t *testing.T, conn *sql.DB, paymentID string) string {
+		var status string
+		assert.NoError(t, conn.QueryRow("SELECT status FROM payments WHERE payment_id = ?", paymentID).Scan(&status))
+		return status
+	}
+	countAssessments := func(t *testing.T, conn *sql.DB) int {
+		var count int
+		assert.NoError(t, conn.QueryRow("SELECT COUNT(*) FROM risk_assessments").Scan(&count))
+		return count
+	}
+
+	t.Run("Per row", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(riskyBatchCSV)}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchPartial, batch.Status)
+		assert.Equal(t, 5, batch.InsertedRows)
+		assert.Equal(t, 1, batch.FailedRows)
+
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		var statuses []payment.RowStatus
+		for _, row := range stored.Rows {
+			statuses = append(statuses, row.Status)
+		}
+		// The second Globex row is flagged by the payments before it in the same batch
+		assert.Equal(t, []payment.RowStatus{
+			payment.RowInserted, payment.RowReview, payment.RowReview, payment.RowInserted, payment.RowReview, payment.RowFailed,
+		}, statuses)
+
+		assert.Equal(t, payment.BatchPaymentStatus, paymentStatus(t, conn, stored.Rows[0].PaymentID))
+		assert.Equal(t, risk.PaymentStatusReview, paymentStatus(t, conn, stored.Rows[1].PaymentID))
+
+		// Only inserted payments have an assessment, and flagged ones wait in the review queue
+		assert.Equal(t, 5, countAssessments(t, conn))
+		repo := &risk.RiskRepo{DB: &db.DB{Connection: conn}}
+		reviews, err := repo.GetAssessments(tenantContext(auth.DefaultTenantID), risk.ReviewPending)
+		assert.NoError(t, err)
+		assert.Len(t, reviews, 3)
+		assessment, err := repo.GetAssessment(tenantContext(auth.DefaultTenantID), stored.Rows[1].PaymentID)
+		assert.NoError(t, err)
+		assert.Equal(t, risk.DecisionReview, assessment.Decision)
+		assert.Equal(t, "ops", assessment.Username)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+		data := strings.TrimSuffix(riskyBatchCSV, "30,USD,Broken Ltd,cash\n")
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(data), Mode: "atomic"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchCompleted, batch.Status)
+		assert.Equal(t, 5, batch.InsertedRows)
+		assert.Equal(t, 5, countAssessments(t, conn))
+
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.RowReview, stored.Rows[2].Status)
+		assert.Equal(t, risk.PaymentStatusReview, paymentStatus(t, conn, stored.Rows[2].PaymentID))
+	})
+
+	t.Run("Rolled back batch records no assessments", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(riskyBatchCSV), Mode: "atomic"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchFailed, batch.Status)
+		assert.Equal(t, 0, countAssessments(t, conn))
+	})
+}
+
+func TestBatchFXRates(t *testing.T) {
+	const data = `amount,currency,pay_to,payment_method
+9500,EUR,Initech,bank_transfer
+9500,USD,Umbrella,bank_transfer
+100,JPY,Hooli,bank_transfer
+`
+
+	t.Run("Per row", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(data), Mode: "per_row"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchPartial, batch.Status)
+		assert.Equal(t, 2, batch.InsertedRows)
+		assert.Equal(t, 1, batch.FailedRows)
+
+		// Rows are scored on their base amount, so only the EUR row crosses the USD threshold
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.RowReview, stored.Rows[0].Status)
+		assert.Equal(t, payment.RowInserted, stored.Rows[1].Status)
+		assert.Equal(t, payment.RowFailed, stored.Rows[2].Status)
+
+		var baseCurrency string
+		var baseAmount float64
+		assert.NoError(t, conn.QueryRow("SELECT base_currency, base_amount FROM payments WHERE payment_id = ?", stored.Rows[0].PaymentID).Scan(&baseCurrency, &baseAmount))
+		assert.Equal(t, "USD", baseCurrency)
+		assert.Equal(t, 10450.0, baseAmount)
+	})
+
+	t.Run("Atomic", func(t *testing.T) {
+		processor, conn := setupTestBatches(t)
+
+		batch, err := processor.Submit(tenantContext(auth.DefaultTenantID), payment.BatchUpload{Data: []byte(data), Mode: "atomic"}, "ops")
+		assert.NoError(t, err)
+		assert.Equal(t, payment.BatchFailed, batch.Status)
+		assert.Equal(t, 3, batch.FailedRows)
+		assert.Equal(t, 0, countPayments(t, conn))
+
+		stored, err := processor.GetBatch(tenantContext(auth.DefaultTenantID), batch.BatchID)
+		assert.NoError(t, err)
+		assert.Equal(t, payment.RowSkipped, stored.Rows[0].Status)
+		assert.Equal(t, payment.RowFailed, stored.Rows[2].Status)
+	})
+}
+
+func TestBatchExecutionError(t *testing.T) {
+	processor, conn := setupTestBatches(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	executeAt := time.Now().Add(time.Hour)
+
+	batch, err := processor.Submit(ctx, payment.BatchUpload{Data: []byte(batchCSV), ExecuteAt: &executeAt}, "ops")
+	assert.NoError(t, err)
+
+	// A batch whose execution stops on an error is failed rather than left executing
+	_, err = conn.Exec("DROP TABLE risk_assessments")
+	assert.NoError(t, err)
+	_, err = processor.Execute(ctx, batch.BatchID)
+	assert.Error(t, err)
+
+	stored, err := processor.GetBatch(ctx, batch.BatchID)
+	assert.NoError(t, err)
+	assert.Equal(t, payment.BatchFailed, stored.Status)
+	assert.NotNil(t, stored.ExecutedAt)
+	_, err = processor.Execute(ctx, batch.BatchID)
+	assert.ErrorIs(t, err, payment.ErrBatchState)
+}
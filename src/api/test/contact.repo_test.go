@@ -1,6 +1,7 @@
 package test
 
 import (
+	"api/internal/auth"
 	"api/internal/contact"
 	"testing"
 
@@ -9,7 +10,7 @@ import (
 
 func TestGetContacts(t *testing.T) {
 	repo:=  contact.NewContactRepo()
-	contacts, err := repo.GetContactsBySearchText("", 10, 0)
+	contacts, err := repo.GetContactsBySearchText(tenantContext(auth.DefaultTenantID), "", 10, 0)
 	if err != nil {
 		t.Fatalf("error: %v", err)
 	}
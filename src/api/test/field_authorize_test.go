@@ -0,0 +1,132 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fieldPermissionSchema = `
+CREATE TABLE roles (
+    role_id INTEGER PRIMARY KEY,
+    role_name TEXT NOT NULL,
+    is_super_admin BOOLEAN NOT NULL DEFAULT 0,
+    status_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE user_roles (
+    role_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    status_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE role_permissions (
+    role_permission_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INTEGER NOT NULL,
+    resource_type_id INTEGER NOT NULL,
+    resource_name TEXT NOT NULL,
+    can_execute BOOLEAN NOT NULL DEFAULT 0,
+    can_read BOOLEAN NOT NULL DEFAULT 0,
+    can_write BOOLEAN NOT NULL DEFAULT 0,
+    can_delete BOOLEAN NOT NULL DEFAULT 0,
+    effect TEXT NOT NULL DEFAULT 'allow',
+    status_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE VIEW vw_user_permissions AS
+SELECT ur.user_id, r.role_id, r.role_name, r.is_super_admin, rp.role_permission_id, rp.resource_type_id,
+    rp.resource_name, rp.can_execute, rp.can_read, rp.can_write, rp.can_delete, rp.effect
+FROM user_roles ur
+    INNER JOIN roles r ON ur.role_id = r.role_id
+    LEFT JOIN role_permissions rp ON r.role_id = rp.role_id
+WHERE ur.status_id = 1 AND r.status_id = 1 AND rp.status_id = 1;
+
+INSERT INTO roles (role_id, role_name, is_super_admin) VALUES (1, 'super', 1), (2, 'viewer', 0), (3, 'auditor', 0);
+INSERT INTO user_roles (role_id, user_id) VALUES (1, 1), (2, 2), (2, 3), (3, 3);
+INSERT INTO role_permissions (role_id, resource_type_id, resource_name, can_execute) VALUES
+(2, 2, 'contacts.getById', 1),
+(2, 2, 'contacts.getPagination', 1),
+(2, 1, 'contacts.secret', 1);
+INSERT INTO role_permissions (role_id, resource_type_id, resource_name, can_execute, effect) VALUES
+(3, 2, 'contacts.getPagination', 1, 'deny');`
+
+func setupTestFieldAuthorizer(t *testing.T) (*sql.DB, graphql.Schema) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(fieldPermissionSchema)
+	assert.NoError(t, err)
+
+	authorizer := auth.NewFieldAuthorizer(&auth.UserPermissionRepo{DB: &db.DB{Connection: conn}})
+	resolve := func(value string) graphql.FieldResolveFn {
+		return func(p graphql.ResolveParams) (interface{}, error) {
+			return value, nil
+		}
+	}
+	query := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"public":        &graphql.Field{Type: graphql.String, Resolve: resolve("hello")},
+			"getById":       &graphql.Field{Type: graphql.String, Resolve: authorizer.Resolver("contacts.getById", resolve("contact"))},
+			"getPagination": &graphql.Field{Type: graphql.String, Resolve: authorizer.Resolver("contacts.getPagination", resolve("page"))},
+			"secret":        &graphql.Field{Type: graphql.String, Resolve: authorizer.Resolver("contacts.secret", resolve("secret"))},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{Query: query})
+	assert.NoError(t, err)
+	return conn, schema
+}
+
+func runFieldQuery(schema graphql.Schema, ctx context.Context) *graphql.Result {
+	return graphql.Do(graphql.Params{
+		Schema:        schema,
+		RequestString: "{ public getById getPagination secret }",
+		Context:       ctx,
+	})
+}
+
+func TestFieldAuthorization(t *testing.T) {
+	conn, schema := setupTestFieldAuthorizer(t)
+
+	// Unauthorized fields resolve to null with a structured error; the rest of the query succeeds
+	result := runFieldQuery(schema, auth.WithFieldPermissions(context.Background(), 2))
+	data := result.Data.(map[string]interface{})
+	assert.Equal(t, "hello", data["public"])
+	assert.Equal(t, "contact", data["getById"])
+	assert.Equal(t, "page", data["getPagination"])
+	assert.Nil(t, data["secret"], "API permissions do not grant GraphQL fields")
+	assert.Len(t, result.Errors, 1)
+	assert.Equal(t, []interface{}{"secret"}, result.Errors[0].Path)
+	assert.Equal(t, auth.FieldErrForbidden, result.Errors[0].Extensions["code"])
+	assert.Equal(t, "contacts.secret", result.Errors[0].Extensions["permission"])
+
+	// Deny rules override allows from other roles
+	result = runFieldQuery(schema, auth.WithFieldPermissions(context.Background(), 3))
+	data = result.Data.(map[string]interface{})
+	assert.Equal(t, "contact", data["getById"])
+	assert.Nil(t, data["getPagination"])
+
+	result = runFieldQuery(schema, auth.WithFieldPermissions(context.Background(), 1))
+	assert.Empty(t, result.Errors)
+	assert.Equal(t, "secret", result.Data.(map[string]interface{})["secret"])
+
+	// Permissions are loaded once per request
+	ctx := auth.WithFieldPermissions(context.Background(), 2)
+	authorizer := auth.NewFieldAuthorizer(&auth.UserPermissionRepo{DB: &db.DB{Connection: conn}})
+	assert.NoError(t, authorizer.Authorize(ctx, "contacts.getById"))
+	_, err := conn.Exec("DELETE FROM role_permissions WHERE resource_name = 'contacts.getById'")
+	assert.NoError(t, err)
+	assert.NoError(t, authorizer.Authorize(ctx, "contacts.getById"))
+	assert.ErrorIs(t, authorizer.Authorize(auth.WithFieldPermissions(context.Background(), 2), "contacts.getById"), auth.ErrFieldForbidden)
+
+	// Without a verified token the field is unauthenticated
+	err = authorizer.Authorize(context.Background(), "contacts.getById")
+	assert.ErrorIs(t, err, auth.ErrFieldUnauthenticated)
+	var fieldErr *auth.FieldAuthError
+	assert.ErrorAs(t, err, &fieldErr)
+	assert.Equal(t, auth.FieldErrUnauthenticated, fieldErr.Code)
+}
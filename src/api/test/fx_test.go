@@ -0,0 +1,208 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/payment"
+	"database/sql"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const fxSchema = `
+CREATE TABLE fx_rates (
+    base TEXT NOT NULL,
+    quote TEXT NOT NULL,
+    rate REAL NOT NULL,
+    effective_date TEXT NOT NULL,
+    source TEXT NOT NULL DEFAULT '',
+    imported_at DATETIME NOT NULL,
+    imported_by TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (base, quote, effective_date)
+);
+CREATE TABLE payments (
+    payment_id TEXT PRIMARY KEY,
+    id TEXT,
+    amount REAL NOT NULL,
+    payment_method TEXT NOT NULL,
+    payment_date TEXT,
+    pay_to TEXT,
+    note TEXT,
+    status TEXT,
+    description TEXT,
+    currency TEXT,
+    base_currency TEXT,
+    base_amount REAL,
+    fx_rate REAL,
+    fx_rate_date TEXT,
+    created_at DATETIME,
+    updated_at DATETIME,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);`
+
+const fxRatesCSV = `base,quote,rate,effective_date
+EUR,USD,1.10,2025-01-01
+EUR,USD,1.20,2025-02-01
+usd,gbp,0.80,2025-01-01
+`
+
+const fxRatesJSON = `{"base": "USD", "date": "2025-01-15", "rates": {"JPY": 150, "CHF": 0.9}}`
+
+func setupTestFX(t *testing.T) (*fx.Converter, *payment.PaymentRepo) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(fxSchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	converter := fx.NewConverterWithRepo(&fx.FXRepo{DB: database}, "usd")
+
+	_, err = converter.Import("rates.csv", "", strings.NewReader(fxRatesCSV), "tester")
+	assert.NoError(t, err)
+	_, err = converter.Import("rates.json", "", strings.NewReader(fxRatesJSON), "tester")
+	assert.NoError(t, err)
+	return converter, &payment.PaymentRepo{DB: database}
+}
+
+func TestFXParseRates(t *testing.T) {
+	rates, err := fx.ParseRates(fx.FormatCSV, strings.NewReader(fxRatesCSV))
+	assert.NoError(t, err)
+	assert.Len(t, rates, 3)
+	assert.Equal(t, fx.Rate{Base: "USD", Quote: "GBP", Rate: 0.8, EffectiveDate: "2025-01-01"}, rates[2])
+
+	rates, err = fx.ParseRates(fx.FormatJSON, strings.NewReader(`[{"base":"eur","quote":"usd","rate":1.1,"effective_date":"2025-01-01"}]`))
+	assert.NoError(t, err)
+	assert.Equal(t, "EUR", rates[0].Base)
+
+	rates, err = fx.ParseRates(fx.FormatJSON, strings.NewReader(fxRatesJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CHF", "JPY"}, []string{rates[0].Quote, rates[1].Quote})
+
+	format, err := fx.DetectFormat("", []byte(fxRatesJSON))
+	assert.NoError(t, err)
+	assert.Equal(t, fx.FormatJSON, format)
+
+	invalid := []string{
+		"base,quote,rate\nEUR,USD,1.1\n",
+		"base,quote,rate,effective_date\nEUR,USD,-1,2025-01-01\n",
+		"base,quote,rate,effective_date\nEUR,EUR,1,2025-01-01\n",
+		"base,quote,rate,effective_date\nEURO,USD,1,2025-01-01\n",
+		"base,quote,rate,effective_date\nEUR,USD,1,01/02/2025\n",
+		"base,quote,rate,effective_date\n",
+	}
+	for _, content := range invalid {
+		_, err := fx.ParseRates(fx.FormatCSV, strings.NewReader(content))
+		assert.ErrorIs(t, err, fx.ErrInvalidRate, content)
+	}
+}
+
+func TestFXConvert(t *testing.T) {
+	converter, _ := setupTestFX(t)
+	day := func(date string) time.Time {
+		value, _ := time.Parse("2006-01-02", date)
+		return value
+	}
+
+	tests := []struct {
+		name      string
+		from, to  string
+		date      string
+		rate      float64
+		effective string
+		converted float64
+	}{
+		{"Direct rate", "EUR", "USD", "2025-01-20", 1.1, "2025-01-01", 110},
+		{"Latest effective rate", "EUR", "USD", "2025-02-10", 1.2, "2025-02-01", 120},
+		{"Inverse rate", "USD", "EUR", "2025-02-10", 0.83333333, "2025-02-01", 83.33},
+		{"Cross rate uses the older leg", "EUR", "GBP", "2025-02-10", 0.96, "2025-01-01", 96},
+		{"Same currency", "jpy", "JPY", "2025-01-01", 1, "2025-01-01", 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conversion, err := converter.Convert(100, tt.from, tt.to, day(tt.date))
+			assert.NoError(t, err)
+			assert.InDelta(t, tt.rate, conversion.Rate, 1e-9)
+			assert.Equal(t, tt.effective, conversion.EffectiveDate)
+			assert.Equal(t, tt.converted, conversion.Converted)
+		})
+	}
+
+	_, err := converter.Convert(100, "EUR", "USD", day("2024-12-31"))
+	assert.ErrorIs(t, err, fx.ErrRateNotFound)
+	_, err = converter.Convert(100, "EUR", "US", day("2025-01-20"))
+	assert.ErrorIs(t, err, fx.ErrInvalidCurrency)
+
+	lock, err := converter.Lock(250, "EUR", day("2025-02-03"))
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", lock.BaseCurrency)
+	assert.Equal(t, 300.0, lock.BaseAmount)
+	assert.Equal(t, "2025-02-01", lock.RateDate)
+}
+
+func TestFXPaymentTotals(t *testing.T) {
+	converter, repo := setupTestFX(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	created := time.Date(2025, 2, 3, 12, 0, 0, 0, time.UTC)
+
+	locked := &payment.Payment{
+		PaymentID: "PAY-EUR-1", Amount: 100, Currency: "EUR", PaymentMethod: "bank_transfer",
+		PaymentDate: "2025-02-03", Status: "completed", CreatedAt: created, UpdatedAt: created,
+		BaseCurrency: "USD", BaseAmount: 120, FXRate: 1.2, FXRateDate: "2025-02-01",
+	}
+	payments := []*payment.Payment{
+		locked,
+		// No lock: converted at the rate on its payment date
+		{PaymentID: "PAY-EUR-2", Amount: 50, Currency: "EUR", PaymentMethod: "cash", PaymentDate: "2025-01-10", Status: "completed", CreatedAt: created, UpdatedAt: created},
+		{PaymentID: "PAY-USD-1", Amount: 30, Currency: "USD", PaymentMethod: "cash", PaymentDate: "2025-02-03", Status: "pending", CreatedAt: created, UpdatedAt: created},
+		// No rate before 2025-01-15
+		{PaymentID: "PAY-JPY-1", Amount: 1500, Currency: "JPY", PaymentMethod: "cash", PaymentDate: "2025-01-02", Status: "completed", CreatedAt: created, UpdatedAt: created},
+	}
+	for _, p := range payments {
+		_, err := repo.InsertPayment(ctx, p)
+		assert.NoError(t, err)
+	}
+
+	stored, err := repo.GetPaymentByID(ctx, "PAY-EUR-1")
+	assert.NoError(t, err)
+	assert.Equal(t, 120.0, stored.BaseAmount)
+	assert.Equal(t, "2025-02-01", stored.FXRateDate)
+
+	groups, err := repo.GetTotalGroups(ctx, payment.PaymentFilter{})
+	assert.NoError(t, err)
+	totals, err := payment.BuildTotals(groups, converter, created)
+	assert.NoError(t, err)
+	assert.Equal(t, "USD", totals.BaseCurrency)
+	assert.Equal(t, int64(4), totals.Count)
+	assert.Equal(t, int64(1), totals.Unconverted)
+	assert.Equal(t, 205.0, totals.BaseTotal)
+	assert.Equal(t, []payment.CurrencyTotal{
+		{Currency: "EUR", Count: 2, Amount: 150, BaseAmount: 175},
+		{Currency: "JPY", Count: 1, Amount: 1500, BaseAmount: 0, Unconverted: 1},
+		{Currency: "USD", Count: 1, Amount: 30, BaseAmount: 30},
+	}, totals.Currencies)
+
+	groups, err = repo.GetTotalGroups(ctx, payment.PaymentFilter{Currency: "eur", Status: "completed", From: "2025-02-01"})
+	assert.NoError(t, err)
+	totals, err = payment.BuildTotals(groups, converter, created)
+	assert.NoError(t, err)
+	assert.Equal(t, 120.0, totals.BaseTotal)
+
+	params := db.NewPaginationParams(db.OffsetPagination)
+	params.SortFields = []string{"payment_id"}
+	page, err := repo.GetPayments(ctx, params, "EUR")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), page.Total)
+
+	page, err = repo.SearchPaymentWithOffsetPagination(ctx, "cash", "EUR", params)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), page.Total)
+	assert.Equal(t, "PAY-EUR-2", page.Data.([]*payment.Payment)[0].PaymentID)
+}
@@ -0,0 +1,104 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func setupTestImpersonation(t *testing.T) (*auth.ImpersonationService, *auth.TokenService, *auth.JwtClaims) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(rbacSchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	for _, name := range []string{"admin", "support", "borrower", "former"} {
+		status := 1
+		if name == "former" {
+			status = 2
+		}
+		assert.NoError(t, users.CreateUser(&auth.User{Username: name, Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: status}))
+	}
+	_, err = conn.Exec(`
+		INSERT INTO roles (role_id, role_name, is_super_admin, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 'super', 1, datetime('now'), 'test', datetime('now'), 'test', 1);
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 1, datetime('now'), 'test', datetime('now'), 'test', 1);`)
+	assert.NoError(t, err)
+
+	tokens := setupTestTokens(t, time.Hour)
+	service := auth.NewImpersonationService(users, &auth.UserPermissionRepo{DB: database}, tokens, 10*time.Minute)
+
+	issued, err := tokens.IssueTokens(2, "support", []string{auth.AMRPassword, auth.AMRMFA})
+	assert.NoError(t, err)
+	support, err := tokens.ParseAccessToken(issued.Token)
+	assert.NoError(t, err)
+	return service, tokens, support
+}
+
+func TestImpersonationToken(t *testing.T) {
+	service, tokens, support := setupTestImpersonation(t)
+	assert.False(t, support.Impersonated())
+	assert.Equal(t, "2", support.Subject)
+
+	token, err := service.Start(support, 3, "Ticket 4821")
+	assert.NoError(t, err)
+	assert.Empty(t, token.RefreshToken)
+	assert.Equal(t, "borrower", token.Username)
+
+	claims, err := tokens.ParseAccessToken(token.Token)
+	assert.NoError(t, err)
+	assert.True(t, claims.Impersonated())
+	assert.Equal(t, 3, claims.UserID)
+	assert.Equal(t, "3", claims.Subject)
+	assert.Equal(t, &auth.ActorClaim{Subject: "2", UserID: 2, Username: "support"}, claims.Act)
+	assert.Equal(t, support.AMR, claims.AMR)
+	// The token is short-lived whatever the normal access token age is
+	assert.InDelta(t, time.Now().Add(10*time.Minute).Unix(), claims.ExpiresAt, 5)
+
+	// An impersonation token cannot be used to impersonate someone else
+	_, err = service.Start(claims, 4, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrImpersonateNested)
+
+	// Stopping revokes the token; a normal token cannot be stopped
+	assert.ErrorIs(t, service.Stop(support), auth.ErrNotImpersonating)
+	assert.NoError(t, service.Stop(claims))
+	revoked, err := tokens.IsRevoked(claims.Id)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+}
+
+func TestImpersonationRules(t *testing.T) {
+	service, _, support := setupTestImpersonation(t)
+
+	_, err := service.Start(support, 1, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrImpersonateSuperAdmin)
+
+	_, err = service.Start(support, 2, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrImpersonateSelf)
+
+	_, err = service.Start(support, 3, " ")
+	assert.ErrorIs(t, err, auth.ErrImpersonateReason)
+
+	_, err = service.Start(support, 4, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrImpersonateInactive)
+
+	_, err = service.Start(support, 99, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrImpersonateNotFound)
+
+	// Staff of another tenant cannot reach the borrower
+	other := *support
+	other.TenantID = 2
+	_, err = service.Start(&other, 3, "Ticket 4821")
+	assert.ErrorIs(t, err, auth.ErrTenantMismatch)
+}
@@ -0,0 +1,108 @@
+package test
+
+import (
+	"api/internal/iso20022"
+	"api/internal/payment"
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files")
+
+var exportOptions = iso20022.Options{
+	MessageID: "MSG-20250131-0001",
+	CreatedAt: time.Date(2025, 1, 31, 9, 30, 0, 0, time.UTC),
+	Debtor: iso20022.Debtor{
+		Name: "Example Co Ltd",
+		IBAN: "GB33BUKB20201555555555",
+		BIC:  "BUKBGB22",
+	},
+}
+
+var exportPayments = []payment.Payment{
+	{
+		PaymentID:   "6f1c2a9e-4b7d-4c2e-9a51-0d3b8f7e2c10",
+		Amount:      1250.5,
+		Currency:    "EUR",
+		PaymentDate: "2025-02-03",
+		PayTo:       "ACME Ltd",
+		Note:        "Invoice 2025-001",
+		Status:      "completed",
+	},
+	{
+		PaymentID:   "PAY-0002",
+		Amount:      99.99,
+		Currency:    "usd",
+		PaymentDate: "2025-02-01",
+		PayTo:       "Globex & Partners",
+		Description: "Office supplies",
+		Status:      "completed",
+	},
+	{
+		PaymentID:   "PAY-0003",
+		Amount:      20,
+		PaymentDate: "2025-02-03",
+		PayTo:       "Initech",
+		Status:      "captured",
+	},
+}
+
+// assertGolden compares output with testdata/iso20022/name, rewriting it with -update
+func assertGolden(t *testing.T, name string, got []byte) {
+	path := filepath.Join("testdata", "iso20022", name)
+	if *updateGolden {
+		assert.NoError(t, os.MkdirAll(filepath.Dir(path), 0o755))
+		assert.NoError(t, os.WriteFile(path, got, 0o644))
+	}
+	want, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, string(want), string(got))
+}
+
+func TestPain001Golden(t *testing.T) {
+	doc, err := iso20022.BuildPain001(exportPayments, exportOptions)
+	assert.NoError(t, err)
+
+	body, err := iso20022.Marshal(doc)
+	assert.NoError(t, err)
+	assertGolden(t, "pain001.golden.xml", body)
+}
+
+func TestCamt054Golden(t *testing.T) {
+	doc, err := iso20022.BuildCamt054(exportPayments, exportOptions)
+	assert.NoError(t, err)
+
+	body, err := iso20022.Marshal(doc)
+	assert.NoError(t, err)
+	assertGolden(t, "camt054.golden.xml", body)
+}
+
+func TestISO20022Validation(t *testing.T) {
+	_, err := iso20022.BuildPain001(nil, exportOptions)
+	assert.ErrorIs(t, err, iso20022.ErrNoPayments)
+
+	noAccount := exportOptions
+	noAccount.Debtor.IBAN = ""
+	_, err = iso20022.BuildPain001(exportPayments, noAccount)
+	assert.ErrorIs(t, err, iso20022.ErrInvalidMessage)
+	assert.Contains(t, err.Error(), "PmtInf[1]/DbtrAcct/Id/IBAN")
+
+	doc, err := iso20022.BuildPain001(exportPayments, exportOptions)
+	assert.NoError(t, err)
+	doc.Initiate.GroupHeader.ControlSum = "1.00"
+	doc.Initiate.PaymentInformation[0].CreditTransfers[0].Creditor.Name = ""
+	err = doc.Validate()
+	assert.ErrorIs(t, err, iso20022.ErrInvalidMessage)
+	assert.Contains(t, err.Error(), "GrpHdr/CtrlSum")
+	assert.Contains(t, err.Error(), "CdtTrfTxInf[1]/Cdtr/Nm: is required")
+
+	negative := append([]payment.Payment{}, exportPayments...)
+	negative[0].Amount = -5
+	_, err = iso20022.BuildCamt054(negative, exportOptions)
+	assert.ErrorIs(t, err, iso20022.ErrInvalidMessage)
+}
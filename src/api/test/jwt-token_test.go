@@ -18,6 +18,6 @@ func TestJWTTokenParse(t *testing.T) {
 	if err != nil {
 		fmt.Println(err)
 	}
-	assert.Greater(t, jwt.UserId, 0)
+	assert.Greater(t, jwt.UserID, 0)
 	assert.Greater(t, len(jwt.Username), 0)
 }
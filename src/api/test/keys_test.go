@@ -0,0 +1,132 @@
+package test
+
+import (
+	"api/internal/auth"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func testClaims() *auth.JwtClaims {
+	return &auth.JwtClaims{
+		UserID:         5,
+		Username:       "dave",
+		StandardClaims: jwt.StandardClaims{Id: "jti-1", ExpiresAt: time.Now().Add(time.Hour).Unix()},
+	}
+}
+
+// ageKey renames a generated key so that its id says it was created age ago
+func ageKey(t *testing.T, dir string, key *auth.SigningKey, age time.Duration) string {
+	_, suffix, _ := strings.Cut(key.ID, "-")
+	id := time.Now().UTC().Add(-age).Format("20060102T150405.000Z") + "-" + suffix
+	assert.NoError(t, os.Rename(filepath.Join(dir, key.ID+".pem"), filepath.Join(dir, id+".pem")))
+	return id
+}
+
+func TestKeySetSignAndVerify(t *testing.T) {
+	for _, algorithm := range []string{auth.AlgorithmRS256, auth.AlgorithmEdDSA} {
+		t.Run(algorithm, func(t *testing.T) {
+			keys, err := auth.NewKeySet(t.TempDir(), algorithm, 0, time.Hour)
+			assert.NoError(t, err)
+			assert.Len(t, keys.Keys(), 1)
+
+			signed, err := keys.Sign(testClaims())
+			assert.NoError(t, err)
+
+			claims := &auth.JwtClaims{}
+			token, err := keys.Verify(signed, claims)
+			assert.NoError(t, err)
+			assert.Equal(t, algorithm, token.Method.Alg())
+			assert.Equal(t, keys.Keys()[0].ID, token.Header["kid"])
+			assert.Equal(t, "dave", claims.Username)
+			assert.Equal(t, "jti-1", claims.Id)
+
+			jwks := keys.JWKS()
+			assert.Len(t, jwks.Keys, 1)
+			assert.Equal(t, keys.Keys()[0].ID, jwks.Keys[0].KeyID)
+			assert.Equal(t, algorithm, jwks.Keys[0].Algorithm)
+		})
+	}
+
+	_, err := auth.NewKeySet(t.TempDir(), "HS256", 0, time.Hour)
+	assert.ErrorIs(t, err, auth.ErrUnknownAlgorithm)
+}
+
+func TestKeySetRejectsForeignTokens(t *testing.T) {
+	keys, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmRS256, 0, time.Hour)
+	assert.NoError(t, err)
+	other, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmRS256, 0, time.Hour)
+	assert.NoError(t, err)
+
+	// Signed by a key this set does not have
+	signed, err := other.Sign(testClaims())
+	assert.NoError(t, err)
+	_, err = keys.Verify(signed, &auth.JwtClaims{})
+	assert.Error(t, err)
+
+	// The old shared-secret tokens, even when they name a known kid
+	hmac := jwt.NewWithClaims(jwt.SigningMethodHS256, testClaims())
+	hmac.Header["kid"] = keys.Keys()[0].ID
+	signed, err = hmac.SignedString([]byte("secret"))
+	assert.NoError(t, err)
+	_, err = keys.Verify(signed, &auth.JwtClaims{})
+	assert.Error(t, err)
+
+	// Expired tokens
+	claims := testClaims()
+	claims.ExpiresAt = time.Now().Add(-time.Minute).Unix()
+	signed, err = keys.Sign(claims)
+	assert.NoError(t, err)
+	_, err = keys.Verify(signed, &auth.JwtClaims{})
+	assert.Error(t, err)
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	keys, err := auth.NewKeySet(dir, auth.AlgorithmEdDSA, 24*time.Hour, time.Hour)
+	assert.NoError(t, err)
+	first := keys.Keys()[0]
+
+	// A fresh key is not rotated
+	rotated, err := keys.RotateIfDue()
+	assert.NoError(t, err)
+	assert.False(t, rotated)
+
+	// A key older than the rotation age is replaced but keeps verifying during the grace period
+	ageKey(t, dir, first, 25*time.Hour)
+	assert.NoError(t, keys.Load())
+	oldToken, err := keys.Sign(testClaims())
+	assert.NoError(t, err)
+
+	rotated, err = keys.RotateIfDue()
+	assert.NoError(t, err)
+	assert.True(t, rotated)
+	assert.Len(t, keys.Keys(), 2)
+	assert.Len(t, keys.JWKS().Keys, 2)
+
+	newToken, err := keys.Sign(testClaims())
+	assert.NoError(t, err)
+	assert.NotEqual(t, strings.Split(oldToken, ".")[0], strings.Split(newToken, ".")[0], "new tokens name the new kid")
+
+	_, err = keys.Verify(oldToken, &auth.JwtClaims{})
+	assert.NoError(t, err)
+
+	// Once the newer key is older than the grace period, the old key is deleted
+	current := keys.Keys()[1]
+	ageKey(t, dir, current, 2*time.Hour)
+	rotated, err = keys.RotateIfDue()
+	assert.NoError(t, err)
+	assert.False(t, rotated)
+	assert.Len(t, keys.Keys(), 1)
+
+	_, err = keys.Verify(oldToken, &auth.JwtClaims{})
+	assert.Error(t, err)
+
+	files, _ := filepath.Glob(filepath.Join(dir, "*.pem"))
+	assert.Len(t, files, 1)
+}
@@ -0,0 +1,194 @@
+package test
+
+import (
+	"api/internal/db"
+	"api/internal/ledger"
+	"api/internal/payment"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const ledgerSchema = `
+CREATE TABLE ledger_accounts (
+    code TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    type TEXT NOT NULL,
+    currency TEXT NOT NULL DEFAULT 'USD',
+    parent_code TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL
+);
+CREATE TABLE journal_entries (
+    entry_id TEXT PRIMARY KEY,
+    reference_type TEXT NOT NULL,
+    reference_id TEXT NOT NULL DEFAULT '',
+    description TEXT,
+    currency TEXT NOT NULL,
+    effective_at DATETIME NOT NULL,
+    reversal_of TEXT UNIQUE,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL
+);
+CREATE TABLE journal_postings (
+    posting_id TEXT PRIMARY KEY,
+    entry_id TEXT NOT NULL,
+    account_code TEXT NOT NULL,
+    amount INTEGER NOT NULL
+);
+INSERT INTO ledger_accounts (code, name, type, currency, parent_code, created_at, created_by, status_id) VALUES
+    ('CASH', 'Cash at bank', 'ASSET', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('LOANS_RECEIVABLE', 'Loans receivable', 'ASSET', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('OWNER_EQUITY', 'Owner equity', 'EQUITY', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('FEE_INCOME', 'Fee and fine income', 'INCOME', 'USD', '', CURRENT_TIMESTAMP, 'system', 1),
+    ('PAYMENTS_CLEARING', 'Payments clearing', 'LIABILITY', 'USD', '', CURRENT_TIMESTAMP, 'system', 1);`
+
+func setupTestLedger(t *testing.T) *ledger.Ledger {
+	l, _ := setupTestLedgerDB(t)
+	return l
+}
+
+func setupTestLedgerDB(t *testing.T) (*ledger.Ledger, *sql.DB) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(ledgerSchema)
+	assert.NoError(t, err)
+
+	return ledger.NewLedgerWithRepo(&ledger.LedgerRepo{DB: &db.DB{Connection: conn}}), conn
+}
+
+func TestValidateEntry(t *testing.T) {
+	tests := []struct {
+		name     string
+		postings []ledger.Posting
+		wantErr  error
+	}{
+		{
+			name: "Balanced",
+			postings: []ledger.Posting{
+				{AccountCode: "CASH", Amount: 1000},
+				{AccountCode: "OWNER_EQUITY", Amount: -1000},
+			},
+		},
+		{
+			name: "Unbalanced",
+			postings: []ledger.Posting{
+				{AccountCode: "CASH", Amount: 1000},
+				{AccountCode: "OWNER_EQUITY", Amount: -999},
+			},
+			wantErr: ledger.ErrUnbalancedEntry,
+		},
+		{
+			name:     "Single posting",
+			postings: []ledger.Posting{{AccountCode: "CASH", Amount: 0}},
+			wantErr:  ledger.ErrInvalidPosting,
+		},
+		{
+			name: "Zero posting",
+			postings: []ledger.Posting{
+				{AccountCode: "CASH", Amount: 0},
+				{AccountCode: "OWNER_EQUITY", Amount: 0},
+			},
+			wantErr: ledger.ErrInvalidPosting,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ledger.ValidateEntry(&ledger.JournalEntry{Postings: tt.postings})
+			if tt.wantErr == nil {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorIs(t, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLedgerPostingAndReversal(t *testing.T) {
+	l := setupTestLedger(t)
+	loanAccount := ledger.SubAccount(ledger.AccountLoansReceivable, "LOAN-001")
+
+	_, err := l.Transfer(ledger.AccountOwnerEquity, ledger.AccountCash, ledger.ToMinor(5000), ledger.RefManual, "", "Capital", "tester")
+	assert.NoError(t, err)
+
+	disbursement, err := l.Transfer(ledger.AccountCash, loanAccount, ledger.ToMinor(1200.50), ledger.RefTransfer, "", "Disbursement", "tester")
+	assert.NoError(t, err)
+
+	_, err = l.Transfer(ledger.AccountFeeIncome, loanAccount, ledger.ToMinor(25), ledger.RefTransfer, "", "Late fine", "tester")
+	assert.NoError(t, err)
+
+	receivable, err := l.Balance(ledger.AccountLoansReceivable, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(122550), receivable.Balance)
+
+	_, err = l.Transfer(ledger.AccountCash, "UNKNOWN", 100, ledger.RefManual, "", "", "tester")
+	assert.ErrorIs(t, err, ledger.ErrAccountNotFound)
+
+	reversal, err := l.Reverse(disbursement.EntryID, "duplicate", "tester")
+	assert.NoError(t, err)
+	assert.Equal(t, disbursement.EntryID, reversal.ReversalOf)
+
+	_, err = l.Reverse(disbursement.EntryID, "", "tester")
+	assert.ErrorIs(t, err, ledger.ErrAlreadyReversed)
+
+	_, err = l.Reverse(reversal.EntryID, "", "tester")
+	assert.ErrorIs(t, err, ledger.ErrReverseReversal)
+
+	cash, err := l.Balance(ledger.AccountCash, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, int64(500000), cash.Balance)
+
+	before, err := l.Balance(ledger.AccountCash, time.Now().Add(-time.Hour))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), before.Balance)
+
+	trialBalance, err := l.TrialBalance(time.Now())
+	assert.NoError(t, err)
+	assert.True(t, trialBalance.Balanced)
+	assert.Equal(t, int64(502500), trialBalance.TotalDebit)
+}
+
+func TestLedgerSubAccountRollback(t *testing.T) {
+	l, conn := setupTestLedgerDB(t)
+	loanAccount := ledger.SubAccount(ledger.AccountLoansReceivable, "LOAN-001")
+
+	// A sub-account is only opened when the entry that uses it is stored
+	_, err := conn.Exec("DROP TABLE journal_postings")
+	assert.NoError(t, err)
+	_, err = l.Transfer(ledger.AccountCash, loanAccount, 100, ledger.RefTransfer, "LOAN-001", "Disbursement", "tester")
+	assert.Error(t, err)
+
+	accounts, err := l.GetAccounts()
+	assert.NoError(t, err)
+	for _, account := range accounts {
+		assert.NotEqual(t, loanAccount, account.Code)
+	}
+}
+
+func TestLedgerPaymentCapture(t *testing.T) {
+	l := setupTestLedger(t)
+	captured := &payment.Payment{
+		PaymentID: "PAY-001", Amount: 100, Currency: "EUR", PayTo: "ACME Ltd", Status: payment.StatusCompleted,
+		BaseCurrency: "USD", BaseAmount: 110, FXRate: 1.1, FXRateDate: "2025-01-01",
+	}
+
+	// The ledger is in the base currency, and a capture is posted once
+	assert.NoError(t, payment.RecordCapture(l, captured, "tester"))
+	assert.NoError(t, payment.RecordCapture(l, captured, "tester"))
+	balance, err := l.Balance(ledger.AccountCash, time.Now())
+	assert.NoError(t, err)
+	assert.Equal(t, ledger.ToMinor(110), balance.Debit)
+
+	unlocked := &payment.Payment{PaymentID: "PAY-002", Amount: 100, Currency: "EUR", Status: payment.StatusCompleted}
+	assert.ErrorIs(t, payment.RecordCapture(l, unlocked, "tester"), payment.ErrNoLockedRate)
+	unlocked.Status = "pending"
+	assert.NoError(t, payment.RecordCapture(l, unlocked, "tester"))
+}
@@ -1,6 +1,7 @@
 package test
 
 import (
+	"api/internal/ledger"
 	"api/internal/loan"
 	"database/sql"
 	"testing"
@@ -22,7 +23,10 @@ func (m *mockCreditService) CalculateRisk(creditScore int, amount float64) (floa
 	return 0.05, nil
 }
 
-func (m *mockPaymentService) TransferFunds(from, to string, amount float64) error     { return nil }
+func (m *mockPaymentService) TransferFunds(from, to string, amount float64, reference string) error {
+	return nil
+}
+func (m *mockPaymentService) HasTransfer(reference string) (bool, error)              { return false, nil }
 func (m *mockPaymentService) ValidatePayment(paymentID string) error                  { return nil }
 func (m *mockPaymentService) CalculateFine(dueDate time.Time, amount float64) float64 { return 0.0 }
 
@@ -38,13 +42,16 @@ CREATE TABLE IF NOT EXISTS loan_applications (
     amount REAL NOT NULL,
     term INTEGER NOT NULL,
     purpose TEXT,
+    branch TEXT,
+    consent_status TEXT,
     status TEXT NOT NULL,
     credit_score INTEGER,
     interest_rate REAL,
     applied_at TIMESTAMP NOT NULL,
     last_updated_at TIMESTAMP NOT NULL,
     approved_at TIMESTAMP,
-    disbursed_at TIMESTAMP
+    disbursed_at TIMESTAMP,
+    tenant_id INTEGER NOT NULL DEFAULT 1
 );
 
 CREATE TABLE IF NOT EXISTS evidence (
@@ -72,6 +79,11 @@ CREATE TABLE IF NOT EXISTS payment_periods (
 );`
 
 func setupTestService(t *testing.T) loan.LoanService {
+	service, _ := setupTestServiceDB(t)
+	return service
+}
+
+func setupTestServiceDB(t *testing.T) (loan.LoanService, *sql.DB) {
 	db, err := sql.Open("sqlite3", ":memory:")
 	assert.NoError(t, err)
 
@@ -84,7 +96,7 @@ func setupTestService(t *testing.T) loan.LoanService {
 		&mockCreditService{},
 		&mockPaymentService{},
 		&mockDocumentService{},
-	)
+	), db
 }
 
 func TestLoanApplication(t *testing.T) {
@@ -271,7 +283,7 @@ func TestLoanApproval(t *testing.T) {
 }
 
 func TestPaymentProcessing(t *testing.T) {
-	service := setupTestService(t)
+	service, db := setupTestServiceDB(t)
 	now := time.Now()
 
 	initialLoan := &loan.LoanApplication{
@@ -314,6 +326,14 @@ func TestPaymentProcessing(t *testing.T) {
 
 	err = service.GeneratePaymentSchedule("LOAN-001")
 	assert.NoError(t, err)
+	err = service.DisburseLoan("LOAN-001")
+	assert.NoError(t, err)
+	for _, id := range []string{"PAY-001", "PAY-002"} {
+		_, err = db.Exec(`
+			INSERT INTO payment_periods (id, loan_id, due_date, amount, interest_amount, principal_amount, status)
+			VALUES (?, 'LOAN-001', ?, 1000, 50, 950, ?)`, id, now.AddDate(0, 1, 0), loan.PaymentPending)
+		assert.NoError(t, err)
+	}
 
 	tests := []struct {
 		name     string
@@ -363,3 +383,138 @@ func TestPaymentProcessing(t *testing.T) {
 		})
 	}
 }
+
+// loanTransfer is a transfer recorded by recordingPaymentService
+type loanTransfer struct {
+	from, to  string
+	amount    float64
+	reference string
+}
+
+// recordingPaymentService records transfers and charges a fixed fine
+type recordingPaymentService struct {
+	transfers []loanTransfer
+	fine      float64
+}
+
+func (m *recordingPaymentService) TransferFunds(from, to string, amount float64, reference string) error {
+	m.transfers = append(m.transfers, loanTransfer{from: from, to: to, amount: amount, reference: reference})
+	return nil
+}
+func (m *recordingPaymentService) HasTransfer(reference string) (bool, error) {
+	for _, transfer := range m.transfers {
+		if transfer.reference == reference {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+func (m *recordingPaymentService) ValidatePayment(paymentID string) error { return nil }
+func (m *recordingPaymentService) CalculateFine(dueDate time.Time, amount float64) float64 {
+	return m.fine
+}
+
+func setupTestRepayments(t *testing.T) (loan.LoanService, *recordingPaymentService, *sql.DB) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(schema)
+	assert.NoError(t, err)
+
+	now := time.Now()
+	_, err = conn.Exec(`
+		INSERT INTO loan_applications (id, applicant_id, amount, term, status, applied_at, last_updated_at, disbursed_at)
+		VALUES ('LOAN-001', 'APP-001', 12000, 12, ?, ?, ?, ?), ('LOAN-002', 'APP-002', 5000, 6, ?, ?, ?, NULL)`,
+		loan.StatusDisbursed, now, now, now, loan.StatusApproved, now, now)
+	assert.NoError(t, err)
+	_, err = conn.Exec(`
+		INSERT INTO payment_periods (id, loan_id, due_date, amount, interest_amount, principal_amount, status)
+		VALUES ('PAY-001', 'LOAN-001', ?, 1000, 50, 950, ?), ('PAY-002', 'LOAN-001', ?, 1000, 40, 960, ?)`,
+		now.AddDate(0, 1, 0), loan.PaymentPending, now.AddDate(0, 0, -5), loan.PaymentPending)
+	assert.NoError(t, err)
+
+	payments := &recordingPaymentService{fine: 25}
+	return loan.NewLoanService(conn, &mockCreditService{}, payments, &mockDocumentService{}), payments, conn
+}
+
+func TestLoanRepaymentSplit(t *testing.T) {
+	service, payments, conn := setupTestRepayments(t)
+	loanAccount := ledger.SubAccount(ledger.AccountLoansReceivable, "LOAN-001")
+
+	// A partial payment settles interest first
+	assert.NoError(t, service.ProcessPayment("LOAN-001", "PAY-001", 30))
+	assert.Equal(t, []loanTransfer{{ledger.AccountInterestIncome, ledger.AccountCash, 30, "PAY-001"}}, payments.transfers)
+
+	var status string
+	var paid float64
+	assert.NoError(t, conn.QueryRow("SELECT status, paid_amount FROM payment_periods WHERE id = 'PAY-001'").Scan(&status, &paid))
+	assert.Equal(t, string(loan.PaymentIncomplete), status)
+	assert.Equal(t, 30.0, paid)
+
+	// The rest of the interest, then the principal
+	payments.transfers = nil
+	assert.NoError(t, service.ProcessPayment("LOAN-001", "PAY-001", 970))
+	assert.Equal(t, []loanTransfer{
+		{ledger.AccountInterestIncome, ledger.AccountCash, 20, "PAY-001"},
+		{loanAccount, ledger.AccountCash, 950, "PAY-001"},
+	}, payments.transfers)
+	assert.NoError(t, conn.QueryRow("SELECT status FROM payment_periods WHERE id = 'PAY-001'").Scan(&status))
+	assert.Equal(t, string(loan.PaymentPaid), status)
+
+	payments.transfers = nil
+	assert.Error(t, service.ProcessPayment("LOAN-001", "PAY-001", 10))
+	assert.ErrorIs(t, service.ProcessPayment("LOAN-999", "PAY-001", 10), loan.ErrLoanNotFound)
+	assert.ErrorIs(t, service.ProcessPayment("LOAN-001", "PAY-999", 10), loan.ErrPaymentPeriodNotFound)
+	assert.Error(t, service.ProcessPayment("LOAN-002", "PAY-001", 10))
+	assert.Empty(t, payments.transfers)
+}
+
+func TestLoanDisbursement(t *testing.T) {
+	service, payments, conn := setupTestRepayments(t)
+	disbursement := loanTransfer{ledger.AccountCash, ledger.SubAccount(ledger.AccountLoansReceivable, "LOAN-002"), 5000, "LOAN-002"}
+
+	// Disbursing twice posts the transfer once
+	assert.NoError(t, service.DisburseLoan("LOAN-002"))
+	assert.Error(t, service.DisburseLoan("LOAN-002"))
+	assert.Equal(t, []loanTransfer{disbursement}, payments.transfers)
+
+	var status string
+	assert.NoError(t, conn.QueryRow("SELECT status FROM loan_applications WHERE id = 'LOAN-002'").Scan(&status))
+	assert.Equal(t, string(loan.StatusDisbursed), status)
+
+	// A loan whose disbursement was posted but whose status change was lost is not posted again
+	_, err := conn.Exec("UPDATE loan_applications SET status = ? WHERE id = 'LOAN-002'", loan.StatusApproved)
+	assert.NoError(t, err)
+	assert.NoError(t, service.DisburseLoan("LOAN-002"))
+	assert.Equal(t, []loanTransfer{disbursement}, payments.transfers)
+
+	assert.ErrorIs(t, service.DisburseLoan("LOAN-999"), loan.ErrLoanNotFound)
+}
+
+func TestLoanFineCharge(t *testing.T) {
+	service, payments, conn := setupTestRepayments(t)
+	loanAccount := ledger.SubAccount(ledger.AccountLoansReceivable, "LOAN-001")
+	fine := loanTransfer{ledger.AccountFeeIncome, loanAccount, 25, "PAY-002"}
+
+	// Checking an overdue period twice charges the fine once
+	assert.NoError(t, service.CheckPaymentStatus("LOAN-001", "PAY-002"))
+	assert.NoError(t, service.CheckPaymentStatus("LOAN-001", "PAY-002"))
+	assert.Equal(t, []loanTransfer{fine}, payments.transfers)
+
+	var status string
+	var fineAmount float64
+	assert.NoError(t, conn.QueryRow("SELECT status, fine_amount FROM payment_periods WHERE id = 'PAY-002'").Scan(&status, &fineAmount))
+	assert.Equal(t, string(loan.PaymentOverdue), status)
+	assert.Equal(t, 25.0, fineAmount)
+
+	// Paying late does not charge it again, and the fine is part of what is due
+	assert.Error(t, service.ProcessPayment("LOAN-001", "PAY-002", 1030))
+	assert.NoError(t, service.ProcessPayment("LOAN-001", "PAY-002", 1025))
+	assert.Equal(t, []loanTransfer{
+		fine,
+		{ledger.AccountInterestIncome, ledger.AccountCash, 40, "PAY-002"},
+		{loanAccount, ledger.AccountCash, 985, "PAY-002"},
+	}, payments.transfers)
+
+	assert.ErrorIs(t, service.CheckPaymentStatus("LOAN-999", "PAY-002"), loan.ErrLoanNotFound)
+}
@@ -0,0 +1,181 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"errors"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const lockoutSchema = `
+CREATE TABLE login_failures (
+    attempt_key TEXT PRIMARY KEY,
+    failures INTEGER NOT NULL DEFAULT 0,
+    last_failed_at DATETIME NOT NULL,
+    locked_until DATETIME
+);
+CREATE TABLE auth_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    event_type TEXT NOT NULL,
+    user_id INTEGER NOT NULL DEFAULT 0,
+    user_name TEXT NOT NULL DEFAULT '',
+    actor TEXT NOT NULL DEFAULT '',
+    ip_address TEXT NOT NULL DEFAULT '',
+    user_agent TEXT NOT NULL DEFAULT '',
+    success BOOLEAN NOT NULL,
+    detail TEXT NOT NULL DEFAULT '',
+    created_at DATETIME NOT NULL
+);`
+
+func setupTestLockoutDB(t *testing.T) *db.DB {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(lockoutSchema)
+	assert.NoError(t, err)
+	return &db.DB{Connection: conn}
+}
+
+func blockedError(t *testing.T, err error) *auth.LoginBlockedError {
+	var blocked *auth.LoginBlockedError
+	assert.True(t, errors.As(err, &blocked), "expected a LoginBlockedError, got %v", err)
+	return blocked
+}
+
+func TestLoginThrottleDelay(t *testing.T) {
+	throttle := auth.NewLoginThrottle(&auth.LockoutRepo{DB: setupTestLockoutDB(t)}, auth.LoginPolicy{
+		DelayAfter:      2,
+		BaseDelay:       time.Hour,
+		MaxDelay:        4 * time.Hour,
+		MaxFailures:     10,
+		IPMaxFailures:   10,
+		LockoutDuration: time.Hour,
+	})
+
+	// The first failure is free
+	_, err := throttle.RecordFailure("alice", "10.0.0.1")
+	assert.NoError(t, err)
+	assert.NoError(t, throttle.Check("alice", "10.0.0.1"))
+
+	// From the second failure on, the next attempt has to wait
+	_, err = throttle.RecordFailure("Alice", "10.0.0.1")
+	assert.NoError(t, err)
+	blocked := blockedError(t, throttle.Check("alice", "10.0.0.2"))
+	assert.False(t, blocked.Locked)
+	assert.InDelta(t, time.Hour.Seconds(), blocked.RetryAfter.Seconds(), 5)
+
+	_, err = throttle.RecordFailure("alice", "10.0.0.1")
+	assert.NoError(t, err)
+	blocked = blockedError(t, throttle.Check("alice", ""))
+	assert.InDelta(t, (2 * time.Hour).Seconds(), blocked.RetryAfter.Seconds(), 5)
+
+	// The address is throttled for other usernames too
+	_, err = throttle.RecordFailure("bob", "10.0.0.1")
+	assert.NoError(t, err)
+	blockedError(t, throttle.Check("carol", "10.0.0.1"))
+
+	// A successful login resets the username but not the address
+	assert.NoError(t, throttle.RecordSuccess("alice"))
+	assert.NoError(t, throttle.Check("alice", "10.0.0.2"))
+	blockedError(t, throttle.Check("alice", "10.0.0.1"))
+}
+
+func TestLoginThrottleLockout(t *testing.T) {
+	throttle := auth.NewLoginThrottle(&auth.LockoutRepo{DB: setupTestLockoutDB(t)}, auth.LoginPolicy{
+		DelayAfter:      100,
+		MaxFailures:     3,
+		IPMaxFailures:   100,
+		LockoutDuration: 200 * time.Millisecond,
+	})
+
+	for i := 1; i <= 3; i++ {
+		locked, err := throttle.RecordFailure("dave", "10.0.0.9")
+		assert.NoError(t, err)
+		assert.Equal(t, i == 3, locked, "failure %d", i)
+	}
+
+	blocked := blockedError(t, throttle.Check("dave", ""))
+	assert.True(t, blocked.Locked)
+
+	lockouts, err := throttle.Lockouts()
+	assert.NoError(t, err)
+	assert.Len(t, lockouts, 1)
+	assert.Equal(t, "user:dave", lockouts[0].Key)
+
+	// An admin unlock lifts it immediately
+	unlocked, err := throttle.Unlock("DAVE")
+	assert.NoError(t, err)
+	assert.True(t, unlocked)
+	assert.NoError(t, throttle.Check("dave", ""))
+
+	unlocked, err = throttle.Unlock("dave")
+	assert.NoError(t, err)
+	assert.False(t, unlocked)
+
+	// Otherwise the lockout expires
+	for i := 0; i < 3; i++ {
+		_, err := throttle.RecordFailure("dave", "")
+		assert.NoError(t, err)
+	}
+	blockedError(t, throttle.Check("dave", ""))
+	time.Sleep(250 * time.Millisecond)
+	assert.NoError(t, throttle.Check("dave", ""))
+}
+
+func TestAuthEvents(t *testing.T) {
+	repo := &auth.AuthEventRepo{DB: setupTestLockoutDB(t)}
+	start := time.Now().Add(-time.Minute)
+
+	for _, event := range []auth.AuthEvent{
+		{EventType: auth.EventLoginFailure, UserID: 1, Username: "alice", IPAddress: "10.0.0.1"},
+		{EventType: auth.EventLoginSuccess, UserID: 1, Username: "alice", IPAddress: "10.0.0.1", Success: true},
+		{EventType: auth.EventRoleChange, UserID: 2, Actor: "admin", Success: true, Detail: "assigned role 3"},
+	} {
+		event.CreatedAt = time.Now()
+		id, err := repo.InsertAuthEvent(&event)
+		assert.NoError(t, err)
+		assert.NotZero(t, id)
+	}
+
+	events, err := repo.GetAuthEvents(auth.AuthEventFilter{Username: "ALICE", Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, events, 2)
+	assert.Equal(t, auth.EventLoginSuccess, events[0].EventType, "newest first")
+
+	events, err = repo.GetAuthEvents(auth.AuthEventFilter{EventType: auth.EventRoleChange, From: start, Limit: 10})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+	assert.Equal(t, "admin", events[0].Actor)
+
+	events, err = repo.GetAuthEvents(auth.AuthEventFilter{To: start, Limit: 10})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	events, err = repo.GetAuthEvents(auth.AuthEventFilter{Limit: 1, Offset: 1})
+	assert.NoError(t, err)
+	assert.Len(t, events, 1)
+}
+
+func TestClientIP(t *testing.T) {
+	r := httptest.NewRequest("POST", "/api/v1/login", nil)
+	r.RemoteAddr = "192.0.2.7:51234"
+	r.Header.Set("X-Forwarded-For", "203.0.113.5")
+
+	// Forwarded headers are ignored unless proxies are trusted
+	assert.Equal(t, "192.0.2.7", auth.ClientIP(r))
+
+	// Trusting only the direct peer, as TRUST_PROXY_HEADERS does, takes the hop it appended
+	// rather than the leftmost one, which the client chose
+	peer, err := auth.ParseTrustedProxies("192.0.2.7")
+	assert.NoError(t, err)
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.5")
+	assert.Equal(t, "203.0.113.5", peer.ClientIP(r))
+}
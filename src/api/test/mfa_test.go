@@ -0,0 +1,237 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/security"
+	"database/sql"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const mfaSchema = `
+CREATE TABLE user_mfa (
+    user_id INTEGER PRIMARY KEY,
+    secret TEXT NOT NULL,
+    confirmed_at DATETIME,
+    last_used_step INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL
+);
+CREATE TABLE mfa_recovery_codes (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id INTEGER NOT NULL,
+    code_hash TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    used_at DATETIME
+);
+CREATE TABLE mfa_challenge_attempts (
+    challenge_id TEXT PRIMARY KEY,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME NOT NULL
+);
+CREATE TABLE roles (
+    role_id INTEGER PRIMARY KEY,
+    require_mfa BOOLEAN NOT NULL DEFAULT 0
+);
+CREATE TABLE user_roles (
+    role_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL
+);
+INSERT INTO roles (role_id, require_mfa) VALUES (1, 0), (2, 1);
+INSERT INTO user_roles (role_id, user_id) VALUES (1, 1), (2, 2);`
+
+func setupTestMFA(t *testing.T) (*auth.MFAService, *auth.KeySet) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(mfaSchema)
+	assert.NoError(t, err)
+
+	keys, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmEdDSA, 0, time.Hour)
+	assert.NoError(t, err)
+	vaultKey, err := security.GenerateKey()
+	assert.NoError(t, err)
+
+	repo := &auth.MFARepo{DB: &db.DB{Connection: conn}}
+	return auth.NewMFAServiceWithRepo(repo, keys, vaultKey, "FinTech API"), keys
+}
+
+// enrollTestMFA enrolls a user and returns the secret and recovery codes
+func enrollTestMFA(t *testing.T, service *auth.MFAService, userID int) (string, []string) {
+	enrollment, err := service.Enroll(userID, "alice")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(enrollment.URI, "otpauth://totp/FinTech%20API:alice?"))
+	assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+
+	code, err := auth.TOTPCode(enrollment.Secret, time.Now())
+	assert.NoError(t, err)
+	codes, err := service.Confirm(userID, code)
+	assert.NoError(t, err)
+	return enrollment.Secret, codes
+}
+
+func TestTOTPCode(t *testing.T) {
+	// RFC 6238 test vectors for SHA-1, truncated to six digits
+	secret := "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+	for unix, want := range map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1234567890: "005924",
+		2000000000: "279037",
+	} {
+		code, err := auth.TOTPCode(secret, time.Unix(unix, 0))
+		assert.NoError(t, err)
+		assert.Equal(t, want, code)
+	}
+
+	now := time.Unix(1234567890, 0)
+	previous, _ := auth.TOTPCode(secret, now.Add(-30*time.Second))
+	step, ok := auth.ValidateTOTP(secret, previous, now, 0)
+	assert.True(t, ok, "one step of clock skew is accepted")
+
+	_, ok = auth.ValidateTOTP(secret, previous, now, step)
+	assert.False(t, ok, "a used step is refused")
+
+	old, _ := auth.TOTPCode(secret, now.Add(-2*time.Minute))
+	_, ok = auth.ValidateTOTP(secret, old, now, 0)
+	assert.False(t, ok)
+}
+
+func TestMFAEnrollAndVerify(t *testing.T) {
+	service, _ := setupTestMFA(t)
+
+	status, err := service.Status(1)
+	assert.NoError(t, err)
+	assert.False(t, status.Enabled)
+
+	// A wrong code does not confirm the enrollment
+	_, err = service.Enroll(1, "alice")
+	assert.NoError(t, err)
+	_, err = service.Confirm(1, "000000")
+	assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+
+	secret, codes := enrollTestMFA(t, service, 1)
+	assert.Len(t, codes, 10)
+
+	status, err = service.Status(1)
+	assert.NoError(t, err)
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 10, status.RecoveryCodesLeft)
+
+	_, err = service.Enroll(1, "alice")
+	assert.ErrorIs(t, err, auth.ErrMFAAlreadyEnabled)
+
+	// The code used to confirm cannot be replayed, the next one works once
+	current, _ := auth.TOTPCode(secret, time.Now())
+	_, err = service.Verify(1, current, "")
+	assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+
+	next, _ := auth.TOTPCode(secret, time.Now().Add(30*time.Second))
+	amr, err := service.Verify(1, next, "")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{auth.AMROTP, auth.AMRMFA}, amr)
+
+	_, err = service.Verify(1, next, "")
+	assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+
+	// Recovery codes work once, ignoring case and dashes
+	_, err = service.Verify(1, "", strings.ToLower(strings.ReplaceAll(codes[0], "-", "")))
+	assert.NoError(t, err)
+	_, err = service.Verify(1, "", codes[0])
+	assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+
+	status, err = service.Status(1)
+	assert.NoError(t, err)
+	assert.Equal(t, 9, status.RecoveryCodesLeft)
+
+	assert.NoError(t, service.Disable(1, "", codes[1]))
+	enabled, err := service.Enabled(1)
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+}
+
+func TestMFAChallenge(t *testing.T) {
+	service, keys := setupTestMFA(t)
+	_, codes := enrollTestMFA(t, service, 1)
+
+	challenge, err := service.NewChallenge(1, "alice")
+	assert.NoError(t, err)
+	assert.True(t, challenge.MFARequired)
+
+	// A challenge is not an access token
+	_, err = auth.NewTokenServiceWithRepo(nil, nil, keys, time.Minute, time.Minute).ParseAccessToken(challenge.ChallengeToken)
+	assert.Error(t, err)
+
+	_, err = service.CompleteChallenge("not-a-token", "", codes[0])
+	assert.ErrorIs(t, err, auth.ErrInvalidMFAChallenge)
+
+	// The user of a challenge is known before its code is checked, for the login throttle
+	_, err = service.Challenge("not-a-token")
+	assert.ErrorIs(t, err, auth.ErrInvalidMFAChallenge)
+	issued, err := service.Challenge(challenge.ChallengeToken)
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", issued.Username)
+
+	claims, err := service.CompleteChallenge(challenge.ChallengeToken, "", codes[0])
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+	assert.Equal(t, []string{auth.AMRPassword, auth.AMRMFA}, claims.AMR)
+
+	// Each challenge completes once
+	_, err = service.CompleteChallenge(challenge.ChallengeToken, "", codes[1])
+	assert.ErrorIs(t, err, auth.ErrTooManyMFAAttempts)
+
+	// Wrong codes are limited per challenge
+	challenge, err = service.NewChallenge(1, "alice")
+	assert.NoError(t, err)
+	for i := 0; i < 5; i++ {
+		_, err = service.CompleteChallenge(challenge.ChallengeToken, "000000", "")
+		assert.ErrorIs(t, err, auth.ErrInvalidMFACode)
+	}
+	_, err = service.CompleteChallenge(challenge.ChallengeToken, "", codes[1])
+	assert.ErrorIs(t, err, auth.ErrTooManyMFAAttempts)
+
+	// Concurrent attempts cannot check more codes than the limit
+	challenge, err = service.NewChallenge(1, "alice")
+	assert.NoError(t, err)
+	var wg sync.WaitGroup
+	var checked atomic.Int32
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := service.CompleteChallenge(challenge.ChallengeToken, "000000", ""); errors.Is(err, auth.ErrInvalidMFACode) {
+				checked.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	assert.Equal(t, int32(5), checked.Load())
+}
+
+func TestMFARequiredByRole(t *testing.T) {
+	service, _ := setupTestMFA(t)
+
+	for userID, required := range map[int]bool{1: false, 2: true} {
+		got, err := service.RequiresMFA(userID)
+		assert.NoError(t, err)
+		assert.Equal(t, required, got)
+
+		satisfied, err := service.SatisfiesMFA(&auth.JwtClaims{UserID: userID, AMR: []string{auth.AMRPassword}})
+		assert.NoError(t, err)
+		assert.Equal(t, !required, satisfied)
+
+		satisfied, err = service.SatisfiesMFA(&auth.JwtClaims{UserID: userID, AMR: []string{auth.AMRPassword, auth.AMROTP, auth.AMRMFA}})
+		assert.NoError(t, err)
+		assert.True(t, satisfied)
+	}
+}
@@ -0,0 +1,361 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/cache"
+	"api/internal/db"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const oauthSchema = `
+CREATE TABLE oauth_clients (
+    client_id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    secret_hash TEXT NOT NULL DEFAULT '',
+    redirect_uris TEXT NOT NULL DEFAULT '',
+    grant_types TEXT NOT NULL,
+    scopes TEXT NOT NULL DEFAULT '',
+    is_public BOOLEAN NOT NULL DEFAULT 0,
+    skip_consent BOOLEAN NOT NULL DEFAULT 0,
+    user_id INTEGER NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    revoked_at DATETIME
+);
+CREATE TABLE oauth_codes (
+    code_hash TEXT PRIMARY KEY,
+    client_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    redirect_uri TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    code_challenge TEXT NOT NULL DEFAULT '',
+    code_challenge_method TEXT NOT NULL DEFAULT '',
+    nonce TEXT NOT NULL DEFAULT '',
+    amr TEXT NOT NULL DEFAULT '',
+    auth_time DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME
+);
+CREATE TABLE oauth_consents (
+    user_id INTEGER NOT NULL,
+    client_id TEXT NOT NULL,
+    scope TEXT NOT NULL DEFAULT '',
+    granted_at DATETIME NOT NULL,
+    PRIMARY KEY (user_id, client_id)
+);`
+
+const oauthRedirectURI = "https://app.example/callback"
+
+// setupTestOAuth creates alice (user 1, with a verified email and the clerk role)
+// and the service account owner svc (user 2)
+func setupTestOAuth(t *testing.T) (*auth.OAuthService, *auth.TokenService, *auth.KeySet) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	for _, schema := range []string{rbacSchema, tokenSchema, oauthSchema} {
+		_, err = conn.Exec(schema)
+		assert.NoError(t, err)
+	}
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "alice", Password: "x", Email: "alice@example.com", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "svc", Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	_, err = conn.Exec(`
+		UPDATE users SET email_verified_at = datetime('now') WHERE user_id = 1;
+		INSERT INTO roles (role_id, role_name, is_super_admin, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 'clerk', 0, datetime('now'), 'test', datetime('now'), 'test', 1);
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 1, datetime('now'), 'test', datetime('now'), 'test', 1);`)
+	assert.NoError(t, err)
+
+	keys, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmEdDSA, 0, time.Hour)
+	assert.NoError(t, err)
+	tokens := auth.NewTokenServiceWithRepo(&auth.TokenRepo{DB: database}, cache.NewMemoryClient(), keys, 15*time.Minute, time.Hour)
+	service := auth.NewOAuthServiceWithRepo(&auth.OAuthRepo{DB: database}, users, &auth.RoleRepo{DB: database}, tokens, keys, "https://id.example/")
+	return service, tokens, keys
+}
+
+// oauthSessionClaims signs alice in to the authorization pages
+func oauthSessionClaims(t *testing.T, service *auth.OAuthService, tokens *auth.TokenService) *auth.JwtClaims {
+	session, err := service.SessionToken(&auth.User{UserID: 1, Username: "alice"}, []string{auth.AMRPassword})
+	assert.NoError(t, err)
+	claims, err := tokens.ParseAccessToken(session.Token)
+	assert.NoError(t, err)
+	return claims
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func assertOAuthError(t *testing.T, err error, code string) {
+	var oauthErr *auth.OAuthError
+	if assert.True(t, errors.As(err, &oauthErr), "expected an OAuth error, got %v", err) {
+		assert.Equal(t, code, oauthErr.Code)
+	}
+}
+
+func TestOAuthClientRegistration(t *testing.T) {
+	service, _, _ := setupTestOAuth(t)
+
+	invalid := []auth.OAuthClientRequest{
+		{GrantTypes: []string{auth.GrantClientCredentials}},
+		{Name: "no grants"},
+		{Name: "no redirect", GrantTypes: []string{auth.GrantAuthorizationCode}},
+		{Name: "public machine", GrantTypes: []string{auth.GrantClientCredentials}, Public: true},
+		{Name: "implicit", GrantTypes: []string{"implicit"}, RedirectURIs: []string{oauthRedirectURI}},
+	}
+	for _, req := range invalid {
+		_, err := service.RegisterClient(req, 1, auth.DefaultTenantID, "alice")
+		assert.ErrorIs(t, err, auth.ErrInvalidOAuthClient, req.Name)
+	}
+	_, err := service.RegisterClient(auth.OAuthClientRequest{Name: "bad uri", GrantTypes: []string{auth.GrantAuthorizationCode}, RedirectURIs: []string{"app.example/callback#x"}}, 1, auth.DefaultTenantID, "alice")
+	assert.ErrorIs(t, err, auth.ErrInvalidRedirectURI)
+	_, err = service.RegisterClient(auth.OAuthClientRequest{Name: "bad scope", GrantTypes: []string{auth.GrantClientCredentials}, Scopes: []string{"admin"}}, 1, auth.DefaultTenantID, "alice")
+	assertOAuthError(t, err, "invalid_scope")
+
+	confidential, err := service.RegisterClient(auth.OAuthClientRequest{
+		Name:         "Partner portal",
+		RedirectURIs: []string{oauthRedirectURI},
+		GrantTypes:   []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken},
+		Scopes:       []string{auth.ScopeOpenID, auth.ScopeEmail},
+	}, 1, auth.DefaultTenantID, "alice")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(confidential.ClientID, "oc_"))
+	assert.NotEmpty(t, confidential.ClientSecret)
+
+	client, err := service.AuthenticateClient(confidential.ClientID, confidential.ClientSecret)
+	assert.NoError(t, err)
+	assert.Equal(t, "Partner portal", client.Name)
+	assert.NotEqual(t, confidential.ClientSecret, client.SecretHash, "only the hash is stored")
+	_, err = service.AuthenticateClient(confidential.ClientID, "wrong")
+	assertOAuthError(t, err, "invalid_client")
+	_, err = service.AuthenticateClient(confidential.ClientID, "")
+	assertOAuthError(t, err, "invalid_client")
+
+	public, err := service.RegisterClient(auth.OAuthClientRequest{
+		Name:         "SPA",
+		RedirectURIs: []string{oauthRedirectURI},
+		GrantTypes:   []string{auth.GrantAuthorizationCode},
+		Public:       true,
+	}, 2, auth.DefaultTenantID, "svc")
+	assert.NoError(t, err)
+	assert.Empty(t, public.ClientSecret)
+	_, err = service.AuthenticateClient(public.ClientID, "")
+	assert.NoError(t, err)
+
+	owned, err := service.Clients(1)
+	assert.NoError(t, err)
+	assert.Len(t, owned, 1)
+	all, err := service.Clients(0)
+	assert.NoError(t, err)
+	assert.Len(t, all, 2)
+
+	assert.NoError(t, service.DeleteClient(public.ClientID))
+	_, err = service.AuthenticateClient(public.ClientID, "")
+	assertOAuthError(t, err, "invalid_client")
+	assert.ErrorIs(t, service.DeleteClient(public.ClientID), auth.ErrOAuthClientNotFound)
+}
+
+func TestOAuthAuthorizationCodeFlow(t *testing.T) {
+	service, tokens, keys := setupTestOAuth(t)
+
+	registered, err := service.RegisterClient(auth.OAuthClientRequest{
+		Name:         "SPA",
+		RedirectURIs: []string{oauthRedirectURI},
+		GrantTypes:   []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken},
+		Scopes:       []string{auth.ScopeOpenID, auth.ScopeProfile, auth.ScopeEmail},
+		Public:       true,
+	}, 2, auth.DefaultTenantID, "svc")
+	assert.NoError(t, err)
+	client := registered.OAuthClient
+
+	verifier := strings.Repeat("v", 43)
+	req := auth.AuthorizationRequest{
+		ResponseType:        "code",
+		ClientID:            client.ClientID,
+		RedirectURI:         oauthRedirectURI,
+		Scope:               "openid email",
+		State:               "xyz",
+		Nonce:               "n-0S6",
+		CodeChallenge:       pkceChallenge(verifier),
+		CodeChallengeMethod: "S256",
+	}
+
+	// Unregistered redirect URIs are never redirected to
+	wrongRedirect := req
+	wrongRedirect.RedirectURI = "https://evil.example/callback"
+	_, err = service.AuthorizationClient(wrongRedirect)
+	assert.ErrorIs(t, err, auth.ErrInvalidRedirectURI)
+
+	_, err = service.AuthorizationClient(req)
+	assert.NoError(t, err)
+	assert.NoError(t, service.ValidateAuthorization(client, req))
+
+	noPKCE := req
+	noPKCE.CodeChallenge, noPKCE.CodeChallengeMethod = "", ""
+	assertOAuthError(t, service.ValidateAuthorization(client, noPKCE), "invalid_request")
+	plain := req
+	plain.CodeChallengeMethod = "plain"
+	assertOAuthError(t, service.ValidateAuthorization(client, plain), "invalid_request")
+	wideScope := req
+	wideScope.Scope = "openid roles"
+	assertOAuthError(t, service.ValidateAuthorization(client, wideScope), "invalid_scope")
+
+	// Consent is asked once per scope
+	needs, err := service.NeedsConsent(1, client, req.Scope)
+	assert.NoError(t, err)
+	assert.True(t, needs)
+	assert.NoError(t, service.GrantConsent(1, client.ClientID, req.Scope))
+	needs, err = service.NeedsConsent(1, client, "openid")
+	assert.NoError(t, err)
+	assert.False(t, needs)
+	needs, err = service.NeedsConsent(1, client, "openid profile")
+	assert.NoError(t, err)
+	assert.True(t, needs)
+
+	session := oauthSessionClaims(t, service, tokens)
+	code, err := service.CreateCode(client, req, session)
+	assert.NoError(t, err)
+
+	_, err = service.ExchangeCode(client, code, oauthRedirectURI, strings.Repeat("w", 43))
+	assertOAuthError(t, err, "invalid_grant")
+	_, err = service.ExchangeCode(client, code, "https://app.example/other", verifier)
+	assertOAuthError(t, err, "invalid_grant")
+
+	resp, err := service.ExchangeCode(client, code, oauthRedirectURI, verifier)
+	assert.NoError(t, err)
+	assert.Equal(t, "Bearer", resp.TokenType)
+	assert.Equal(t, "openid email", resp.Scope)
+	assert.NotEmpty(t, resp.RefreshToken)
+	assert.Greater(t, resp.ExpiresIn, int64(0))
+
+	claims, err := tokens.ParseAccessToken(resp.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claims.UserID)
+	assert.Equal(t, client.ClientID, claims.ClientID)
+	assert.Equal(t, "openid email", claims.Scope)
+	assert.Equal(t, []string{auth.AMRPassword}, claims.AMR)
+
+	idClaims := &auth.IDTokenClaims{}
+	_, err = keys.Verify(resp.IDToken, idClaims)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://id.example", idClaims.Issuer)
+	assert.Equal(t, "1", idClaims.Subject)
+	assert.Equal(t, client.ClientID, idClaims.Audience)
+	assert.Equal(t, "n-0S6", idClaims.Nonce)
+	assert.Equal(t, "alice@example.com", idClaims.Email)
+	assert.Empty(t, idClaims.PreferredUsername, "profile was not requested")
+	_, err = tokens.ParseAccessToken(resp.IDToken)
+	assert.Error(t, err, "ID tokens are not access tokens")
+
+	// Codes can be exchanged once
+	_, err = service.ExchangeCode(client, code, oauthRedirectURI, verifier)
+	assertOAuthError(t, err, "invalid_grant")
+
+	// Staff impersonating the user cannot authorize clients for them
+	impersonated := oauthSessionClaims(t, service, tokens)
+	impersonated.Act = &auth.ActorClaim{Subject: "2", UserID: 2, Username: "svc"}
+	_, err = service.CreateCode(client, req, impersonated)
+	assertOAuthError(t, err, "access_denied")
+
+	// Users cannot authorize clients of another tenant
+	session.TenantID = 2
+	_, err = service.CreateCode(client, req, session)
+	assertOAuthError(t, err, "access_denied")
+}
+
+func TestOAuthClientCredentialsAndRefresh(t *testing.T) {
+	service, tokens, _ := setupTestOAuth(t)
+
+	machine, err := service.RegisterClient(auth.OAuthClientRequest{
+		Name:       "Settlement job",
+		GrantTypes: []string{auth.GrantClientCredentials},
+		Scopes:     []string{auth.ScopeProfile},
+	}, 2, auth.DefaultTenantID, "svc")
+	assert.NoError(t, err)
+
+	resp, err := service.ClientCredentials(machine.OAuthClient, "profile")
+	assert.NoError(t, err)
+	assert.Empty(t, resp.RefreshToken)
+	claims, err := tokens.ParseAccessToken(resp.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, claims.UserID, "the client acts as its owner")
+	assert.Equal(t, "svc", claims.Username)
+	assert.Equal(t, machine.ClientID, claims.ClientID)
+
+	_, err = service.ClientCredentials(machine.OAuthClient, "openid")
+	assertOAuthError(t, err, "invalid_scope")
+
+	app, err := service.RegisterClient(auth.OAuthClientRequest{
+		Name:         "Partner portal",
+		RedirectURIs: []string{oauthRedirectURI},
+		GrantTypes:   []string{auth.GrantAuthorizationCode, auth.GrantRefreshToken},
+		Scopes:       []string{auth.ScopeOpenID, auth.ScopeProfile},
+	}, 2, auth.DefaultTenantID, "svc")
+	assert.NoError(t, err)
+	issued, err := tokens.IssueClientTokens(1, "alice", []string{auth.AMRPassword}, app.ClientID, "openid profile")
+	assert.NoError(t, err)
+
+	// Refresh tokens are bound to their client and are not used up by a rejected exchange
+	_, err = service.RefreshToken(machine.OAuthClient, issued.RefreshToken, "")
+	assertOAuthError(t, err, "invalid_grant")
+	_, err = service.RefreshToken(app.OAuthClient, issued.RefreshToken, "openid roles")
+	assertOAuthError(t, err, "invalid_scope")
+	_, err = tokens.Refresh(issued.RefreshToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken, "client tokens cannot be refreshed by /token/refresh")
+
+	refreshed, err := service.RefreshToken(app.OAuthClient, issued.RefreshToken, "openid")
+	assert.NoError(t, err)
+	assert.Equal(t, "openid profile", refreshed.Scope)
+	claims, err = tokens.ParseAccessToken(refreshed.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, app.ClientID, claims.ClientID)
+	assert.Equal(t, "openid profile", claims.Scope)
+
+	_, err = service.RefreshToken(app.OAuthClient, issued.RefreshToken, "")
+	assertOAuthError(t, err, "invalid_grant")
+}
+
+func TestOAuthUserInfoAndDiscovery(t *testing.T) {
+	service, _, _ := setupTestOAuth(t)
+
+	_, err := service.UserInfo(&auth.JwtClaims{UserID: 1, Scope: "profile"})
+	assertOAuthError(t, err, "insufficient_scope")
+
+	info, err := service.UserInfo(&auth.JwtClaims{UserID: 1})
+	assert.Nil(t, info)
+	assertOAuthError(t, err, "insufficient_scope")
+
+	info, err = service.UserInfo(&auth.JwtClaims{UserID: 1, Scope: "openid"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]interface{}{"sub": "1"}, info)
+
+	info, err = service.UserInfo(&auth.JwtClaims{UserID: 1, Scope: "openid profile email roles"})
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", info["preferred_username"])
+	assert.Equal(t, "alice@example.com", info["email"])
+	assert.Equal(t, true, info["email_verified"])
+	assert.Equal(t, []string{"clerk"}, info["roles"])
+
+	discovery := service.Discovery()
+	assert.Equal(t, "https://id.example", discovery.Issuer)
+	assert.Equal(t, "https://id.example"+auth.OAuthTokenPath, discovery.TokenEndpoint)
+	assert.Equal(t, "https://id.example/.well-known/jwks.json", discovery.JWKSURI)
+	assert.Equal(t, []string{auth.AlgorithmEdDSA}, discovery.IDTokenSigningAlgValuesSupported)
+	assert.Equal(t, []string{"S256"}, discovery.CodeChallengeMethodsSupported)
+}
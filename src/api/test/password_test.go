@@ -0,0 +1,92 @@
+package test
+
+import (
+	"api/internal/auth"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cheapPasswordParams keep the tests fast
+var cheapPasswordParams = auth.PasswordParams{Memory: 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHashPassword(t *testing.T) {
+	hash, err := auth.HashPassword("s3cret", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=1024,t=1,p=1$"))
+	assert.False(t, auth.IsLegacyHash(hash))
+
+	other, err := auth.HashPassword("s3cret", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, other, "every hash has its own salt")
+
+	user := &auth.User{Username: "alice", Password: hash}
+	ok, rehash, err := auth.VerifyPassword(user, "s3cret", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, err = auth.VerifyPassword(user, "wrong", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	stronger := cheapPasswordParams
+	stronger.Iterations = 2
+	ok, rehash, err = auth.VerifyPassword(user, "s3cret", stronger)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash, "hashes with outdated parameters are upgraded")
+}
+
+func TestVerifyLegacyPassword(t *testing.T) {
+	user := &auth.User{
+		Username: "bob",
+		Password: auth.HashString("bob" + "hunter2" + "xYz12345"),
+		Salt:     "xYz12345",
+	}
+	assert.True(t, auth.IsLegacyHash(user.Password))
+
+	ok, rehash, err := auth.VerifyPassword(user, "hunter2", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.True(t, rehash)
+
+	ok, rehash, err = auth.VerifyPassword(user, "hunter3", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	assert.False(t, rehash)
+
+	ok, _, _ = auth.VerifyPassword(&auth.User{Username: "nobody"}, "", cheapPasswordParams)
+	assert.False(t, ok, "users without a stored hash never match")
+}
+
+func TestVerifyPasswordInvalidHash(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=1,p=1$c2FsdA",
+		"$argon2id$v=18$m=1024,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=x,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=1$!!!$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=0$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=0,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=0,t=1,p=1$c2FsdHNhbHQ$a2V5",
+		"$argon2id$v=19$m=1024,t=1,p=256$c2FsdHNhbHQ$a2V5",
+	} {
+		ok, _, err := auth.VerifyPassword(&auth.User{Username: "carol", Password: hash}, "pw", cheapPasswordParams)
+		assert.ErrorIs(t, err, auth.ErrInvalidHash, hash)
+		assert.False(t, ok)
+	}
+}
+
+func TestHashPasswordInvalidParams(t *testing.T) {
+	for _, change := range []func(*auth.PasswordParams){
+		func(p *auth.PasswordParams) { p.Memory = 0 },
+		func(p *auth.PasswordParams) { p.Iterations = 0 },
+		func(p *auth.PasswordParams) { p.Parallelism = 0 },
+	} {
+		params := cheapPasswordParams
+		change(&params)
+		_, err := auth.HashPassword("s3cret", params)
+		assert.ErrorIs(t, err, auth.ErrInvalidPasswordParams)
+	}
+}
@@ -0,0 +1,130 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"net/http"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPermissionRuleMatching(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		match   bool
+	}{
+		{"/api/payments", "/api/payments", true},
+		{"/api/payments", "/api/payments/", true},
+		{"/api/payments", "/api/payments/123", false},
+		{"/api/payments/*", "/api/payments/123", true},
+		{"/api/payments/*", "/api/payments/123/refund", false},
+		{"/api/payments/**", "/api/payments", true},
+		{"/api/payments/**", "/api/payments/123/refund", true},
+		{"/api/v1/payments/*", "/api/v1/payments/123", true},
+		{"/api/v1/payments/*", "/api/payments/123", true},
+		{"/loans/{id}/approve", "/api/v1/loans/42/approve", true},
+		{"/loans/{id}/approve", "/api/v1/loans/42/reject", false},
+		{"/api/payments?currency=EUR", "/api/payments", true},
+	}
+	for _, tt := range tests {
+		rule, err := auth.CompileRule(&auth.RolePermissions{ResourceName: tt.pattern, CanRead: true})
+		assert.NoError(t, err, tt.pattern)
+		assert.Equal(t, tt.match, rule.MatchPath(normalizedPath(tt.path)), "%s ~ %s", tt.pattern, tt.path)
+	}
+
+	for _, pattern := range []string{"/api/**/payments", "/api/{id"} {
+		_, err := auth.CompileRule(&auth.RolePermissions{ResourceName: pattern})
+		assert.ErrorIs(t, err, auth.ErrInvalidPermission, pattern)
+	}
+	_, err := auth.CompileRule(&auth.RolePermissions{ResourceName: "/api", Methods: []string{"FETCH"}})
+	assert.ErrorIs(t, err, auth.ErrInvalidPermission)
+	_, err = auth.CompileRule(&auth.RolePermissions{ResourceName: "/api", Effect: "maybe"})
+	assert.ErrorIs(t, err, auth.ErrInvalidPermission)
+
+	// Flags apply to the method's action; PATCH writes and HEAD reads
+	flags, err := auth.CompileRule(&auth.RolePermissions{ResourceName: "/api/payments", CanRead: true, CanWrite: true})
+	assert.NoError(t, err)
+	assert.True(t, flags.AppliesTo(http.MethodPatch))
+	assert.True(t, flags.AppliesTo(http.MethodHead))
+	assert.False(t, flags.AppliesTo(http.MethodDelete))
+
+	// Method lists replace the flags
+	methods, err := auth.CompileRule(&auth.RolePermissions{ResourceName: "/api/payments", CanRead: true, Methods: []string{"post"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"POST"}, methods.Methods)
+	assert.True(t, methods.AppliesTo(http.MethodPost))
+	assert.False(t, methods.AppliesTo(http.MethodGet))
+}
+
+// normalizedPath mirrors how request paths are matched: without the API version
+func normalizedPath(path string) string {
+	rule, _ := auth.CompileRule(&auth.RolePermissions{ResourceName: path})
+	return rule.Pattern
+}
+
+func TestPermissionEvaluator(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(rbacSchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "bob", Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	roles := &auth.RoleRepo{DB: database}
+	rules := auth.NewPermissionEvaluator(roles, 0)
+	service := auth.NewRBACService(roles, users, rules)
+	actor := auth.RBACActor{UserID: 1, Username: "admin", TenantID: auth.DefaultTenantID}
+
+	clerk, err := service.CreateRole(auth.Role{RoleName: "clerk"}, actor)
+	assert.NoError(t, err)
+	_, err = service.AssignRole(1, clerk.RoleID, actor)
+	assert.NoError(t, err)
+
+	decision, err := rules.Evaluate(1, http.MethodGet, "/api/v1/payments/123")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	_, err = service.AddPermission(clerk.RoleID, auth.RolePermissions{ResourceName: "/api/v1/payments/**", CanRead: true, CanWrite: true}, actor)
+	assert.NoError(t, err)
+	decision, err = rules.Evaluate(1, http.MethodPatch, "/api/v1/payments/123?expand=true")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed, decision.Reason)
+
+	// Deny rules override allows, and the cached rules of the role are replaced
+	deny, err := service.AddPermission(clerk.RoleID, auth.RolePermissions{ResourceName: "/api/payments/{id}/refund", Methods: []string{"POST"}, Effect: auth.EffectDeny}, actor)
+	assert.NoError(t, err)
+	decision, err = rules.Evaluate(1, http.MethodPost, "/api/v1/payments/123/refund")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	assert.Equal(t, auth.EffectDeny, decision.Rule.Effect)
+	decision, err = rules.Evaluate(1, http.MethodGet, "/api/v1/payments/123/refund")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	decision, err = rules.EvaluateAction(1, auth.ActionWrite, "/api/payments/9/refund")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	// Changes made outside the service show after invalidation
+	_, err = conn.Exec("DELETE FROM role_permissions WHERE role_permission_id = ?", deny.RolePermissionID)
+	assert.NoError(t, err)
+	decision, _ = rules.Evaluate(1, http.MethodPost, "/api/v1/payments/123/refund")
+	assert.False(t, decision.Allowed, "rules are cached")
+	rules.Invalidate(clerk.RoleID)
+	decision, _ = rules.Evaluate(1, http.MethodPost, "/api/v1/payments/123/refund")
+	assert.True(t, decision.Allowed)
+
+	// API key scopes use the same patterns
+	key := &auth.APIKey{Scopes: []string{"/api/payments/*:read"}}
+	assert.True(t, key.Allows("/api/v1/payments/123", auth.ActionRead))
+	assert.False(t, key.Allows("/api/v1/payments/123", auth.ActionWrite))
+	assert.False(t, key.Allows("/api/v1/payments", auth.ActionRead))
+}
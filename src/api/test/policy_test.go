@@ -0,0 +1,193 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/loan"
+	"api/internal/policy"
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadTestPolicies(t *testing.T) *policy.Engine {
+	policies, err := policy.LoadPolicies("../config/policies.yaml")
+	assert.NoError(t, err)
+	return policy.NewEngine(policies)
+}
+
+func TestPolicyEvaluation(t *testing.T) {
+	engine := loadTestPolicies(t)
+	borrower := &policy.Subject{UserID: 7, Roles: []string{"borrower"}}
+	officer := &policy.Subject{UserID: 8, Roles: []string{"loan_officer"}, Attributes: policy.Attributes{"branch": "BKK"}}
+	manager := &policy.Subject{UserID: 9, Roles: []string{"credit_manager"}}
+	admin := &policy.Subject{UserID: 1, SuperAdmin: true}
+
+	own := policy.Attributes{"applicant_id": "7", "branch": "BKK", "amount": 40000.0, "consent_status": "GRANTED"}
+	other := policy.Attributes{"applicant_id": "12", "branch": "BKK", "amount": 60000.0}
+
+	tests := []struct {
+		name     string
+		subject  *policy.Subject
+		action   string
+		resource policy.Attributes
+		allowed  bool
+		policy   string
+	}{
+		{"borrower reads own loan", borrower, "read", own, true, "borrower_own_loans"},
+		{"borrower reads other loan", borrower, "read", other, false, ""},
+		{"borrower cannot approve", borrower, "approve", own, false, ""},
+		{"officer reviews branch loan", officer, "review", own, true, "officer_branch_loans"},
+		{"officer over approval limit", officer, "review", policy.Attributes{"branch": "BKK", "amount": 60000.0, "consent_status": "GRANTED"}, false, ""},
+		{"officer other branch", officer, "read", policy.Attributes{"branch": "CNX", "amount": 100.0}, false, ""},
+		{"consent required for review", officer, "review", policy.Attributes{"branch": "BKK", "amount": 100.0, "consent_status": "REVOKED"}, false, "consent_required"},
+		{"consent not required for read", officer, "read", policy.Attributes{"branch": "BKK", "amount": 100.0}, true, "officer_branch_loans"},
+		{"manager any loan", manager, "read", other, true, "credit_manager_loans"},
+		{"manager without consent", manager, "approve", other, false, "consent_required"},
+		{"super admin", admin, "approve", other, true, ""},
+	}
+	for _, tt := range tests {
+		decision := engine.Evaluate(policy.Request{Subject: tt.subject, Resource: "loan", Action: tt.action, Attributes: tt.resource})
+		assert.Equal(t, tt.allowed, decision.Allowed, "%s: %s", tt.name, decision.Reason)
+		assert.Equal(t, tt.policy, decision.Policy, tt.name)
+	}
+
+	// Decisions explain every condition
+	decision := engine.Evaluate(policy.Request{Subject: borrower, Resource: "loan", Action: "read", Attributes: other})
+	assert.Equal(t, "no policy matched; default effect is deny", decision.Reason)
+	assert.Len(t, decision.Evaluations, 4)
+	assert.True(t, decision.Evaluations[0].Applicable)
+	assert.Equal(t, []policy.ConditionResult{{Attribute: "resource.applicant_id", Operator: "eq", Actual: "12", Expected: 7, Matched: false}}, decision.Evaluations[0].Conditions)
+	assert.Equal(t, "role not held", decision.Evaluations[1].Reason)
+}
+
+func TestPolicyValidation(t *testing.T) {
+	invalid := map[string]string{
+		"operator":   "policies:\n  - {name: a, resource: loan, actions: [read], effect: allow, conditions: [{attribute: resource.amount, operator: like, value: 1}]}",
+		"value":      "policies:\n  - {name: a, resource: loan, actions: [read], effect: allow, conditions: [{attribute: resource.amount, operator: eq}]}",
+		"attribute":  "policies:\n  - {name: a, resource: loan, actions: [read], effect: allow, conditions: [{attribute: amount, operator: eq, value: 1}]}",
+		"list":       "policies:\n  - {name: a, resource: loan, actions: [read], effect: allow, conditions: [{attribute: resource.branch, operator: in, value: BKK}]}",
+		"effect":     "policies:\n  - {name: a, resource: loan, actions: [read], effect: maybe}",
+		"duplicate":  "policies:\n  - {name: a, resource: loan, actions: [read], effect: allow}\n  - {name: a, resource: loan, actions: [read], effect: deny}",
+		"default":    "default_effect: maybe",
+		"no actions": "policies:\n  - {name: a, resource: loan, effect: allow}",
+	}
+	for name, content := range invalid {
+		path := filepath.Join(t.TempDir(), "policies.yaml")
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		_, err := policy.LoadPolicies(path)
+		assert.Error(t, err, name)
+	}
+
+	path := filepath.Join(t.TempDir(), "policies.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte("policies: []"), 0o600))
+	policies, err := policy.LoadPolicies(path)
+	assert.NoError(t, err)
+	assert.Equal(t, policy.EffectDeny, policies.DefaultEffect)
+}
+
+func TestPolicyFilterMatchesEvaluation(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	_, err = conn.Exec(schema)
+	assert.NoError(t, err)
+	service := loan.NewLoanService(conn, &mockCreditService{}, &mockPaymentService{}, &mockDocumentService{})
+	ctx := auth.WithTenantID(context.Background(), auth.DefaultTenantID)
+
+	loans := []*loan.LoanApplication{
+		{ID: "L1", ApplicantID: "7", Amount: 1000, Term: 12, Branch: "BKK", ConsentStatus: loan.ConsentGranted},
+		{ID: "L2", ApplicantID: "7", Amount: 90000, Term: 12, Branch: "CNX"},
+		{ID: "L3", ApplicantID: "12", Amount: 50000, Term: 12, Branch: "BKK", ConsentStatus: loan.ConsentRevoked},
+		{ID: "L4", ApplicantID: "13", Amount: 20000, Term: 12, Branch: "BKK"},
+		{ID: "L5", ApplicantID: "14", Amount: 70000, Term: 12, Branch: "BKK", ConsentStatus: loan.ConsentGranted},
+	}
+	for _, application := range loans {
+		assert.NoError(t, service.ApplyForLoan(application, nil))
+	}
+
+	engine := loadTestPolicies(t)
+	subjects := []*policy.Subject{
+		{UserID: 7, Roles: []string{"borrower"}},
+		{UserID: 8, Roles: []string{"loan_officer"}, Attributes: policy.Attributes{"branch": "BKK"}},
+		{UserID: 9, Roles: []string{"loan_officer"}},
+		{UserID: 10, Roles: []string{"credit_manager"}},
+		{UserID: 11, Roles: []string{"clerk"}},
+		{UserID: 1, SuperAdmin: true},
+	}
+	for _, subject := range subjects {
+		for _, action := range []string{"read", "review"} {
+			filter, err := engine.Filter(subject, "loan", action, loan.PolicyColumns)
+			assert.NoError(t, err)
+			listed, err := service.ListApplications(ctx, filter)
+			assert.NoError(t, err)
+
+			var want, got []string
+			for _, application := range loans {
+				decision := engine.Evaluate(policy.Request{Subject: subject, Resource: "loan", Action: action, Attributes: application.Attributes()})
+				if decision.Allowed {
+					want = append(want, application.ID)
+				}
+			}
+			for _, application := range listed {
+				got = append(got, application.ID)
+			}
+			assert.ElementsMatch(t, want, got, "user %d %s", subject.UserID, action)
+		}
+	}
+
+	// Borrowers list their own loans only
+	filter, err := engine.Filter(subjects[0], "loan", "read", loan.PolicyColumns)
+	assert.NoError(t, err)
+	listed, err := service.ListApplications(ctx, filter)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 2)
+
+	_, err = engine.Filter(subjects[0], "loan", "read", map[string]string{"amount": "amount"})
+	assert.ErrorIs(t, err, policy.ErrNotFilterable)
+}
+
+func TestPolicyExplain(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(rbacSchema + `;
+		CREATE TABLE user_attributes (user_id INTEGER NOT NULL, name TEXT NOT NULL, value TEXT NOT NULL, PRIMARY KEY (user_id, name));
+		INSERT INTO roles (role_id, role_name, is_super_admin, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (2, 'loan_officer', 0, datetime('now'), 'test', datetime('now'), 'test', 1);
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (2, 1, datetime('now'), 'test', datetime('now'), 'test', 1);`)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "officer", Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	repo := &policy.PolicyRepo{DB: database}
+	assert.NoError(t, repo.SetUserAttribute(1, "branch", "CNX"))
+	assert.NoError(t, repo.SetUserAttribute(1, "branch", "BKK"))
+
+	service := policy.NewServiceWithEngine(loadTestPolicies(t), repo, &auth.RoleRepo{DB: database}, users)
+	explanation, err := service.Explain(policy.ExplainRequest{
+		UserID:     1,
+		Resource:   "loan",
+		Action:     "review",
+		Attributes: policy.Attributes{"branch": "BKK", "amount": 1000, "consent_status": "GRANTED"},
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, "officer", explanation.Subject.Username)
+	assert.Equal(t, []string{"loan_officer"}, explanation.Subject.Roles)
+	assert.Equal(t, "BKK", explanation.Subject.Attributes["branch"])
+	assert.True(t, explanation.Decision.Allowed, explanation.Decision.Reason)
+	assert.Equal(t, "officer_branch_loans", explanation.Decision.Policy)
+
+	_, err = service.Explain(policy.ExplainRequest{UserID: 1, Resource: "loan"})
+	assert.ErrorIs(t, err, policy.ErrInvalidRequest)
+	_, err = service.Explain(policy.ExplainRequest{UserID: 99, Resource: "loan", Action: "read"})
+	assert.ErrorIs(t, err, policy.ErrSubjectNotFound)
+}
@@ -0,0 +1,283 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/cache"
+	"api/internal/middleware"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/time/rate"
+)
+
+// testClock is a time source tests move by hand
+type testClock struct {
+	now time.Time
+}
+
+func (c *testClock) Now() time.Time {
+	return c.now
+}
+
+func rateLimitedRequest(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest("GET", "/api/v1/health", nil)
+	r.RemoteAddr = remoteAddr
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+func TestRateLimitPerClient(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := middleware.NewRateLimiter(time.Minute)
+	limiter.SetClock(clock.Now)
+
+	policy := middleware.RateLimitPolicy{Name: "public", Limit: 1, Burst: 2}
+	handler := limiter.Middleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+
+	w := rateLimitedRequest(handler, "192.0.2.1:1000")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+
+	w = rateLimitedRequest(handler, "192.0.2.1:1001")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+
+	w = rateLimitedRequest(handler, "192.0.2.1:1002")
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "RATE_LIMIT_EXCEEDED")
+
+	// A noisy client does not throttle the others
+	w = rateLimitedRequest(handler, "192.0.2.2:1000")
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	clock.now = clock.now.Add(time.Second)
+	w = rateLimitedRequest(handler, "192.0.2.1:1003")
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestRateLimitPolicies(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := middleware.NewRateLimiter(time.Minute)
+	limiter.SetClock(clock.Now)
+	limiter.SetTenantPolicies(func(tenantID int) (middleware.RateLimitPolicy, bool) {
+		return middleware.RateLimitPolicy{Limit: 5, Burst: 5}, tenantID == 2
+	})
+	limiter.SetRolePolicies(
+		map[string]middleware.RateLimitPolicy{"support": {Limit: 20, Burst: 40}, "batch": {Limit: 50, Burst: 100}},
+		func(userID int) ([]string, error) {
+			if userID == 9 {
+				return []string{"support", "batch"}, nil
+			}
+			return []string{"borrower"}, nil
+		},
+	)
+	api := middleware.RateLimitPolicy{Name: "api", Limit: 1, Burst: 3}
+
+	// Anonymous clients, users of tenants without a rate and users of other roles get the route's policy
+	assert.Equal(t, 3, limiter.Allow("ip:192.0.2.1", 0, 2, api).Limit)
+	assert.Equal(t, 3, limiter.Allow("user:3", 3, 1, api).Limit)
+	// The tenant's rate replaces it, and the most generous role replaces both
+	assert.Equal(t, 5, limiter.Allow("user:4", 4, 2, api).Limit)
+	assert.Equal(t, 100, limiter.Allow("user:9", 9, 2, api).Limit)
+
+	// Route groups keep separate limiters for the same client
+	public := middleware.RateLimitPolicy{Name: "public", Limit: 1, Burst: 1}
+	assert.True(t, limiter.Allow("ip:192.0.2.1", 0, 0, public).Allowed)
+	assert.False(t, limiter.Allow("ip:192.0.2.1", 0, 0, public).Allowed)
+	assert.True(t, limiter.Allow("ip:192.0.2.1", 0, 0, api).Allowed)
+	assert.Equal(t, 5, limiter.Clients())
+
+	// Idle limiters are evicted
+	clock.now = clock.now.Add(2 * time.Minute)
+	limiter.Allow("user:3", 3, 1, api)
+	assert.Equal(t, 1, limiter.Clients())
+}
+
+func TestRateLimitPolicyRefresh(t *testing.T) {
+	clock := &testClock{now: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	limiter := middleware.NewRateLimiter(10 * time.Minute)
+	limiter.SetClock(clock.Now)
+	roles := []string{"borrower"}
+	limiter.SetRolePolicies(
+		map[string]middleware.RateLimitPolicy{"support": {Limit: 20, Burst: 40}},
+		func(userID int) ([]string, error) { return roles, nil },
+	)
+	api := middleware.RateLimitPolicy{Name: "api", Limit: 0.01, Burst: 3}
+
+	for i := 0; i < 3; i++ {
+		assert.True(t, limiter.Allow("user:3", 3, 1, api).Allowed)
+	}
+	assert.False(t, limiter.Allow("user:3", 3, 1, api).Allowed)
+
+	// A role granted to an active client applies once its resolved policy expires
+	roles = []string{"support"}
+	clock.now = clock.now.Add(30 * time.Second)
+	assert.Equal(t, 3, limiter.Allow("user:3", 3, 1, api).Limit)
+	clock.now = clock.now.Add(31 * time.Second)
+	assert.Equal(t, 40, limiter.Allow("user:3", 3, 1, api).Limit)
+	// The tokens already spent carry over and refill at the new rate
+	clock.now = clock.now.Add(time.Second)
+	for i := 0; i < 20; i++ {
+		assert.True(t, limiter.Allow("user:3", 3, 1, api).Allowed)
+	}
+
+	// Revoking it takes the client back to the route's policy
+	roles = []string{"borrower"}
+	clock.now = clock.now.Add(time.Minute)
+	assert.Equal(t, 3, limiter.Allow("user:3", 3, 1, api).Limit)
+	assert.Equal(t, 1, limiter.Clients())
+}
+
+// failingRateLimitStore is a shared store that cannot be reached
+type failingRateLimitStore struct{}
+
+func (failingRateLimitStore) AllowRate(key string, emission time.Duration, burst int) (cache.RateLimitResult, error) {
+	return cache.RateLimitResult{}, errors.New("connection refused")
+}
+
+func TestCacheAllowRate(t *testing.T) {
+	sqlite, err := cache.NewSQLiteInMemClient()
+	assert.NoError(t, err)
+
+	for name, store := range map[string]middleware.RateLimitStore{"memory": cache.NewMemoryClient(), "sqlite": sqlite} {
+		result, err := store.AllowRate("ratelimit:test", time.Hour, 2)
+		assert.NoError(t, err, name)
+		assert.True(t, result.Allowed, name)
+		assert.Equal(t, 1, result.Remaining, name)
+
+		result, err = store.AllowRate("ratelimit:test", time.Hour, 2)
+		assert.NoError(t, err, name)
+		assert.True(t, result.Allowed, name)
+		assert.Equal(t, 0, result.Remaining, name)
+
+		result, err = store.AllowRate("ratelimit:test", time.Hour, 2)
+		assert.NoError(t, err, name)
+		assert.False(t, result.Allowed, name)
+		assert.InDelta(t, time.Hour.Seconds(), result.RetryAfter.Seconds(), 1, name)
+
+		// Other keys have limiters of their own
+		result, err = store.AllowRate("ratelimit:other", time.Hour, 2)
+		assert.NoError(t, err, name)
+		assert.True(t, result.Allowed, name)
+
+		_, err = store.AllowRate("ratelimit:test", 0, 2)
+		assert.Error(t, err, name)
+	}
+}
+
+func TestRateLimitSharedStore(t *testing.T) {
+	policy := middleware.RateLimitPolicy{Name: "api", Limit: 1, Burst: 3}
+
+	// Instances sharing a store share each client's limit
+	store := cache.NewMemoryClient()
+	first, second := middleware.NewRateLimiter(time.Minute), middleware.NewRateLimiter(time.Minute)
+	first.SetStore(store, true)
+	second.SetStore(store, true)
+	assert.True(t, first.Allow("user:3", 3, 1, policy).Allowed)
+	assert.True(t, second.Allow("user:3", 3, 1, policy).Allowed)
+	result := first.Allow("user:3", 3, 1, policy)
+	assert.True(t, result.Allowed)
+	assert.Equal(t, 0, result.Remaining)
+	result = second.Allow("user:3", 3, 1, policy)
+	assert.False(t, result.Allowed)
+	assert.Equal(t, 3, result.Limit)
+	assert.Greater(t, result.RetryAfter, time.Duration(0))
+
+	// An unreachable store lets requests through or refuses them as configured
+	open := middleware.NewRateLimiter(time.Minute)
+	open.SetStore(failingRateLimitStore{}, true)
+	assert.True(t, open.Allow("user:3", 3, 1, policy).Allowed)
+
+	closed := middleware.NewRateLimiter(time.Minute)
+	closed.SetStore(failingRateLimitStore{}, false)
+	handler := closed.Middleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	w := rateLimitedRequest(handler, "192.0.2.1:1000")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	assert.Contains(t, w.Body.String(), "RATE_LIMIT_UNAVAILABLE")
+}
+
+func TestRateLimitRedisUnreachable(t *testing.T) {
+	previous := viper.GetString("CACHE_CON_STR")
+	viper.Set("CACHE_CON_STR", "127.0.0.1:1")
+	defer viper.Set("CACHE_CON_STR", previous)
+
+	// Unlike the general cache, the shared cache does not fall back to memory
+	_, err := cache.NewSharedCache(cache.RedisBackend)
+	assert.Error(t, err)
+
+	opened := 0
+	store := middleware.NewSharedRateLimitStore(func() (middleware.RateLimitStore, error) {
+		opened++
+		shared, err := cache.NewSharedCache(cache.RedisBackend)
+		if err != nil {
+			return nil, err
+		}
+		return shared, nil
+	}, time.Hour)
+	policy := middleware.RateLimitPolicy{Name: "api", Limit: 1, Burst: 1}
+
+	// Requests are refused or let through as configured, never counted per process
+	closed := middleware.NewRateLimiter(time.Minute)
+	closed.SetStore(store, false)
+	handler := closed.Middleware(policy)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	w := rateLimitedRequest(handler, "192.0.2.1:1000")
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "RATE_LIMIT_UNAVAILABLE")
+
+	open := middleware.NewRateLimiter(time.Minute)
+	open.SetStore(store, true)
+	for i := 0; i < 3; i++ {
+		assert.True(t, open.Allow("user:3", 3, 1, policy).Allowed)
+	}
+
+	// Redis is not dialled again on every request
+	assert.Equal(t, 1, opened)
+}
+
+func TestParseRateLimits(t *testing.T) {
+	policy, err := middleware.ParseRateLimitPolicy("public", "2.5:10")
+	assert.NoError(t, err)
+	assert.Equal(t, middleware.RateLimitPolicy{Name: "public", Limit: rate.Limit(2.5), Burst: 10}, policy)
+
+	for _, spec := range []string{"", "10", "0:5", "x:5", "5:0"} {
+		_, err := middleware.ParseRateLimitPolicy("public", spec)
+		assert.Error(t, err, spec)
+	}
+
+	roles, err := middleware.ParseRoleRateLimits("support=20:40, batch = 50:100,broken=1")
+	assert.Error(t, err)
+	assert.Len(t, roles, 2)
+	assert.Equal(t, 100, roles["batch"].Burst)
+}
+
+func TestTrustedProxiesClientIP(t *testing.T) {
+	proxies, err := auth.ParseTrustedProxies("10.0.0.0/8, 192.0.2.10,bogus")
+	assert.Error(t, err)
+	assert.Len(t, proxies, 2)
+
+	r := httptest.NewRequest("GET", "/api/v1/health", nil)
+	r.Header.Set("X-Forwarded-For", "198.51.100.1, 203.0.113.5, 10.1.2.3")
+
+	// The rightmost address that is not a proxy is the client; earlier ones can be forged
+	r.RemoteAddr = "192.0.2.10:443"
+	assert.Equal(t, "203.0.113.5", proxies.ClientIP(r))
+
+	// Forwarded headers from anyone else are ignored
+	r.RemoteAddr = "203.0.113.99:443"
+	assert.Equal(t, "203.0.113.99", proxies.ClientIP(r))
+}
@@ -0,0 +1,217 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const rbacSchema = `
+CREATE TABLE users (
+    user_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_name TEXT NOT NULL,
+    password TEXT NOT NULL,
+    salt TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL,
+    email TEXT,
+    email_verified_at DATETIME,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE roles (
+    role_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_name TEXT NOT NULL,
+    role_desc TEXT,
+    is_super_admin BOOLEAN NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL,
+    require_mfa BOOLEAN NOT NULL DEFAULT 0,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE user_roles (
+    user_role_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INTEGER NOT NULL,
+    user_id INTEGER NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL
+);
+CREATE TABLE role_permissions (
+    role_permission_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    role_id INTEGER NOT NULL,
+    resource_type_id INTEGER NOT NULL,
+    resource_name TEXT NOT NULL,
+    can_execute BOOLEAN NOT NULL,
+    can_read BOOLEAN NOT NULL,
+    can_write BOOLEAN NOT NULL,
+    can_delete BOOLEAN NOT NULL,
+    methods TEXT NOT NULL DEFAULT '',
+    effect TEXT NOT NULL DEFAULT 'allow',
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT NOT NULL,
+    status_id INTEGER NOT NULL
+);`
+
+// setupTestRBAC creates a super admin (user 1), a clerk (user 2) and bob (user 3)
+func setupTestRBAC(t *testing.T) (*auth.RBACService, auth.RBACActor, auth.RBACActor) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(rbacSchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	users := &auth.UserRepo{DB: database}
+	for _, name := range []string{"admin", "clerk", "bob"} {
+		assert.NoError(t, users.CreateUser(&auth.User{Username: name, Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	}
+
+	roles := &auth.RoleRepo{DB: database}
+	service := auth.NewRBACService(roles, users, auth.NewPermissionEvaluator(roles, 0))
+	admin := auth.RBACActor{UserID: 1, Username: "admin", TenantID: auth.DefaultTenantID}
+	clerk := auth.RBACActor{UserID: 2, Username: "clerk", TenantID: auth.DefaultTenantID}
+
+	_, err = conn.Exec(`
+		INSERT INTO roles (role_id, role_name, is_super_admin, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 'super', 1, datetime('now'), 'test', datetime('now'), 'test', 1);
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 1, datetime('now'), 'test', datetime('now'), 'test', 1);`)
+	assert.NoError(t, err)
+	return service, admin, clerk
+}
+
+func TestRBACRoles(t *testing.T) {
+	service, admin, clerk := setupTestRBAC(t)
+
+	_, err := service.CreateRole(auth.Role{RoleName: " "}, clerk)
+	assert.ErrorIs(t, err, auth.ErrInvalidRole)
+
+	// Only super admins can create or grant super admin roles
+	_, err = service.CreateRole(auth.Role{RoleName: "root", IsSuperAdmin: true}, clerk)
+	assert.ErrorIs(t, err, auth.ErrSuperAdminRequired)
+
+	teller, err := service.CreateRole(auth.Role{RoleName: "teller", RoleDesc: "Branch teller"}, clerk)
+	assert.NoError(t, err)
+	assert.NotZero(t, teller.RoleID)
+	assert.Equal(t, 1, teller.StatusID)
+
+	_, err = service.CreateRole(auth.Role{RoleName: "Teller"}, admin)
+	assert.ErrorIs(t, err, auth.ErrRoleExists)
+
+	_, err = service.UpdateRole(teller.RoleID, auth.Role{RoleName: "teller", IsSuperAdmin: true}, clerk)
+	assert.ErrorIs(t, err, auth.ErrSuperAdminRequired)
+	updated, err := service.UpdateRole(teller.RoleID, auth.Role{RoleName: "cashier", RequireMFA: true}, clerk)
+	assert.NoError(t, err)
+	assert.True(t, updated.RequireMFA)
+	assert.Equal(t, "clerk", updated.UpdatedBy)
+
+	roles, total, err := service.ListRoles("cash", 10, 0, clerk)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, total)
+	assert.Equal(t, "cashier", roles[0].RoleName)
+	roles, total, err = service.ListRoles("", 1, 1, clerk)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, total)
+	assert.Len(t, roles, 1)
+
+	// Roles of other tenants are not listed
+	outsider := auth.RBACActor{UserID: 2, Username: "clerk", TenantID: auth.DefaultTenantID + 1}
+	roles, total, err = service.ListRoles("", 10, 0, outsider)
+	assert.NoError(t, err)
+	assert.Zero(t, total)
+	assert.Empty(t, roles)
+
+	// The last super admin role cannot be demoted
+	_, err = service.UpdateRole(1, auth.Role{RoleName: "super"}, admin)
+	assert.ErrorIs(t, err, auth.ErrLastSuperAdmin)
+
+	// Roles in use cannot be deleted
+	_, err = service.AssignRole(3, teller.RoleID, clerk)
+	assert.NoError(t, err)
+	_, err = service.DeleteRole(teller.RoleID, clerk)
+	assert.ErrorIs(t, err, auth.ErrRoleInUse)
+	_, err = service.RemoveRole(3, teller.RoleID, clerk)
+	assert.NoError(t, err)
+	_, err = service.DeleteRole(teller.RoleID, clerk)
+	assert.NoError(t, err)
+	_, err = service.GetRole(teller.RoleID, clerk)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+}
+
+func TestRBACPermissionsAndAssignments(t *testing.T) {
+	service, admin, clerk := setupTestRBAC(t)
+
+	reader, err := service.CreateRole(auth.Role{RoleName: "reader"}, admin)
+	assert.NoError(t, err)
+	writer, err := service.CreateRole(auth.Role{RoleName: "writer"}, admin)
+	assert.NoError(t, err)
+
+	_, err = service.AddPermission(reader.RoleID, auth.RolePermissions{ResourceName: "/api/payments"}, clerk)
+	assert.ErrorIs(t, err, auth.ErrInvalidPermission)
+	read, err := service.AddPermission(reader.RoleID, auth.RolePermissions{ResourceName: "/api/payments", CanRead: true}, clerk)
+	assert.NoError(t, err)
+	assert.Equal(t, auth.ResourceTypeAPI, read.ResourceTypeID)
+	_, err = service.AddPermission(reader.RoleID, auth.RolePermissions{ResourceName: "/api/payments", CanWrite: true}, clerk)
+	assert.ErrorIs(t, err, auth.ErrPermissionExists)
+	_, err = service.AddPermission(writer.RoleID, auth.RolePermissions{ResourceName: "/api/payments", CanWrite: true}, clerk)
+	assert.NoError(t, err)
+	_, err = service.AddPermission(1, auth.RolePermissions{ResourceName: "/api/payments", CanRead: true}, clerk)
+	assert.ErrorIs(t, err, auth.ErrSuperAdminRequired)
+
+	updated, err := service.UpdatePermission(reader.RoleID, read.RolePermissionID, auth.RolePermissions{ResourceName: "/api/payments", CanRead: true, CanExecute: true}, clerk)
+	assert.NoError(t, err)
+	assert.True(t, updated.CanExecute)
+
+	_, err = service.AssignRole(3, reader.RoleID, clerk)
+	assert.NoError(t, err)
+	_, err = service.AssignRole(3, reader.RoleID, clerk)
+	assert.ErrorIs(t, err, auth.ErrUserRoleExists)
+	_, err = service.AssignRole(3, writer.RoleID, clerk)
+	assert.NoError(t, err)
+	_, err = service.AssignRole(99, writer.RoleID, clerk)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+
+	// Effective permissions combine all roles of the user
+	access, err := service.UserAccess(3, clerk)
+	assert.NoError(t, err)
+	assert.Equal(t, "bob", access.Username)
+	assert.False(t, access.IsSuperAdmin)
+	assert.Len(t, access.Roles, 2)
+	assert.Len(t, access.Permissions, 1)
+	payments := access.Permissions[0]
+	assert.True(t, payments.CanRead && payments.CanWrite && payments.CanExecute)
+	assert.False(t, payments.CanDelete)
+	assert.ElementsMatch(t, []string{"reader", "writer"}, payments.Roles)
+
+	// Super admin roles are assigned by super admins, and the last one stays
+	_, err = service.AssignRole(2, 1, clerk)
+	assert.ErrorIs(t, err, auth.ErrSuperAdminRequired)
+	_, err = service.RemoveRole(1, 1, admin)
+	assert.ErrorIs(t, err, auth.ErrLastSuperAdmin)
+	_, err = service.AssignRole(2, 1, admin)
+	assert.NoError(t, err)
+	_, err = service.RemoveRole(1, 1, admin)
+	assert.NoError(t, err)
+	_, err = service.RemoveRole(2, 1, clerk)
+	assert.ErrorIs(t, err, auth.ErrLastSuperAdmin)
+
+	_, err = service.DeletePermission(reader.RoleID, read.RolePermissionID, clerk)
+	assert.NoError(t, err)
+	_, err = service.DeletePermission(reader.RoleID, read.RolePermissionID, clerk)
+	assert.ErrorIs(t, err, auth.ErrPermissionNotFound)
+}
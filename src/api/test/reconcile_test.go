@@ -0,0 +1,130 @@
+package test
+
+import (
+	"api/internal/reconcile"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const camt053Statement = `<?xml version="1.0" encoding="UTF-8"?>
+<Document xmlns="urn:iso:std:iso:20022:tech:xsd:camt.053.001.02">
+  <BkToCstmrStmt>
+    <Stmt>
+      <Ntry>
+        <Amt Ccy="USD">1000.00</Amt>
+        <CdtDbtInd>CRDT</CdtDbtInd>
+        <BookgDt><Dt>2025-03-02</Dt></BookgDt>
+        <AcctSvcrRef>BANK-123</AcctSvcrRef>
+        <NtryDtls>
+          <TxDtls>
+            <Refs><EndToEndId>PAY-001</EndToEndId></Refs>
+            <RltdPties><Dbtr><Nm>John Doe</Nm></Dbtr></RltdPties>
+            <RmtInf><Ustrd>Installment PAY-001</Ustrd></RmtInf>
+          </TxDtls>
+        </NtryDtls>
+      </Ntry>
+      <Ntry>
+        <Amt Ccy="USD">25.50</Amt>
+        <CdtDbtInd>DBIT</CdtDbtInd>
+        <BookgDt><Dt>2025-03-03</Dt></BookgDt>
+        <AddtlNtryInf>Bank fee</AddtlNtryInf>
+      </Ntry>
+    </Stmt>
+  </BkToCstmrStmt>
+</Document>`
+
+func TestParseCSVStatement(t *testing.T) {
+	csvStatement := "Date,Amount,Currency,Reference,Description\n" +
+		"2025-03-02,\"1,000.00\",usd,PAY-001,Installment\n" +
+		"03/03/2025,-25.50,USD,,Bank fee\n"
+
+	transactions, err := reconcile.ParseCSV(strings.NewReader(csvStatement))
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+	assert.Equal(t, 1000.00, transactions[0].Amount)
+	assert.Equal(t, "USD", transactions[0].Currency)
+	assert.Equal(t, "PAY-001", transactions[0].Reference)
+	assert.Equal(t, time.Date(2025, 3, 3, 0, 0, 0, 0, time.UTC), transactions[1].BookingDate)
+	assert.Equal(t, -25.50, transactions[1].Amount)
+
+	_, err = reconcile.ParseCSV(strings.NewReader("Reference,Amount\nPAY-001,10\n"))
+	assert.Error(t, err)
+}
+
+func TestParseCAMT053Statement(t *testing.T) {
+	transactions, err := reconcile.ParseCAMT053(strings.NewReader(camt053Statement))
+	assert.NoError(t, err)
+	assert.Len(t, transactions, 2)
+
+	assert.Equal(t, "BANK-123", transactions[0].BankReference)
+	assert.Equal(t, "PAY-001", transactions[0].Reference)
+	assert.Equal(t, "Installment PAY-001", transactions[0].Description)
+	assert.Equal(t, "John Doe", transactions[0].Counterparty)
+	assert.Equal(t, 1000.00, transactions[0].Amount)
+
+	assert.Equal(t, -25.50, transactions[1].Amount)
+	assert.Equal(t, "Bank fee", transactions[1].Description)
+
+	format, err := reconcile.DetectFormat("statement.dat", []byte(camt053Statement))
+	assert.NoError(t, err)
+	assert.Equal(t, reconcile.FormatCAMT053, format)
+}
+
+func TestMatchStatementTransaction(t *testing.T) {
+	matcher := reconcile.NewMatcher(3)
+	due := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	candidates := []reconcile.Candidate{
+		{Type: reconcile.MatchLoanInstallment, ID: "PAY-001", Reference: "LOAN-001", Amount: 1000, Date: due},
+		{Type: reconcile.MatchPayment, ID: "7d9c", Reference: "Office rent", Amount: 500, Date: due},
+		{Type: reconcile.MatchPayment, ID: "8e0d", Reference: "Office rent", Amount: 500, Date: due},
+	}
+
+	tests := []struct {
+		name   string
+		txn    reconcile.Transaction
+		status reconcile.MatchStatus
+		id     string
+	}{
+		{
+			name:   "Reference amount and date",
+			txn:    reconcile.Transaction{Reference: "pay-001", Amount: 1000, BookingDate: due.AddDate(0, 0, 2)},
+			status: reconcile.StatusMatched,
+			id:     "PAY-001",
+		},
+		{
+			name:   "Outside date tolerance",
+			txn:    reconcile.Transaction{Reference: "PAY-001", Amount: 1000, BookingDate: due.AddDate(0, 0, 10)},
+			status: reconcile.StatusSuggested,
+			id:     "PAY-001",
+		},
+		{
+			name:   "Date only",
+			txn:    reconcile.Transaction{Description: "repayment", Amount: 999, BookingDate: due},
+			status: reconcile.StatusUnmatched,
+		},
+		{
+			name:   "Ambiguous amount and date",
+			txn:    reconcile.Transaction{Amount: -500, BookingDate: due},
+			status: reconcile.StatusSuggested,
+			id:     "7d9c",
+		},
+		{
+			name:   "Nothing similar",
+			txn:    reconcile.Transaction{Reference: "XYZ", Amount: 42, BookingDate: due},
+			status: reconcile.StatusUnmatched,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := matcher.Match(tt.txn, candidates)
+			assert.Equal(t, tt.status, result.Status)
+			if tt.id != "" {
+				assert.Equal(t, tt.id, result.Candidate.ID)
+			}
+		})
+	}
+}
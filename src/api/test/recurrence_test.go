@@ -0,0 +1,103 @@
+package test
+
+import (
+	"api/internal/payment"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    string
+		freq    payment.Frequency
+		wantErr bool
+	}{
+		{name: "Plain frequency", rule: "monthly", freq: payment.FrequencyMonthly},
+		{name: "RRULE prefix", rule: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=MO,FR", freq: payment.FrequencyWeekly},
+		{name: "Count and until", rule: "FREQ=DAILY;COUNT=5;UNTIL=20251231", freq: payment.FrequencyDaily},
+		{name: "Unsupported frequency", rule: "FREQ=HOURLY", wantErr: true},
+		{name: "BYDAY on monthly", rule: "FREQ=MONTHLY;BYDAY=MO", wantErr: true},
+		{name: "Invalid interval", rule: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := payment.ParseRecurrence(tt.rule)
+			if tt.wantErr {
+				assert.ErrorIs(t, err, payment.ErrInvalidRecurrence)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.freq, rule.Freq)
+		})
+	}
+}
+
+func TestRecurrenceNext(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 9, 0, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name  string
+		rule  string
+		start time.Time
+		after time.Time
+		want  []time.Time
+	}{
+		{
+			name:  "Every other day",
+			rule:  "FREQ=DAILY;INTERVAL=2",
+			start: day(2025, 1, 1),
+			want:  []time.Time{day(2025, 1, 1), day(2025, 1, 3), day(2025, 1, 5)},
+		},
+		{
+			name:  "Monthly on the 31st clamps to month end",
+			rule:  "FREQ=MONTHLY",
+			start: day(2025, 1, 31),
+			want:  []time.Time{day(2025, 1, 31), day(2025, 2, 28), day(2025, 3, 31), day(2025, 4, 30)},
+		},
+		{
+			name:  "Last day of month",
+			rule:  "FREQ=MONTHLY;BYMONTHDAY=-1",
+			start: day(2024, 1, 15),
+			want:  []time.Time{day(2024, 1, 31), day(2024, 2, 29), day(2024, 3, 31)},
+		},
+		{
+			name:  "Weekly on Monday and Friday",
+			rule:  "FREQ=WEEKLY;BYDAY=MO,FR",
+			start: day(2025, 1, 1), // Wednesday
+			want:  []time.Time{day(2025, 1, 3), day(2025, 1, 6), day(2025, 1, 10)},
+		},
+		{
+			name:  "Fortnightly",
+			rule:  "FREQ=WEEKLY;INTERVAL=2",
+			start: day(2025, 1, 1),
+			after: day(2025, 3, 1),
+			want:  []time.Time{day(2025, 3, 12), day(2025, 3, 26)},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rule, err := payment.ParseRecurrence(tt.rule)
+			assert.NoError(t, err)
+
+			after := tt.after
+			for _, want := range tt.want {
+				next, ok := rule.Next(tt.start, after)
+				assert.True(t, ok)
+				assert.Equal(t, want, next)
+				after = next
+			}
+		})
+	}
+
+	rule, err := payment.ParseRecurrence("FREQ=DAILY;UNTIL=20250102")
+	assert.NoError(t, err)
+	_, ok := rule.Next(day(2025, 1, 1), day(2025, 1, 2))
+	assert.False(t, ok)
+}
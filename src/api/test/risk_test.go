@@ -0,0 +1,257 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/risk"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const riskSchema = `
+CREATE TABLE payments (
+    payment_id TEXT PRIMARY KEY,
+    status TEXT,
+    updated_at DATETIME,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE risk_assessments (
+    assessment_id TEXT PRIMARY KEY,
+    payment_id TEXT NOT NULL UNIQUE,
+    username TEXT NOT NULL,
+    pay_to TEXT NOT NULL,
+    amount REAL NOT NULL,
+    score INTEGER NOT NULL,
+    decision TEXT NOT NULL,
+    triggered_rules TEXT NOT NULL,
+    rules_version TEXT NOT NULL DEFAULT '',
+    review_status TEXT NOT NULL DEFAULT '',
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    review_note TEXT,
+    created_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);`
+
+const riskRules = `
+version: "test"
+review_threshold: 70
+velocity:
+  - name: user_hourly_count
+    scope: user
+    window: 1h
+    max_count: 2
+    score: 40
+amount_thresholds:
+  - name: large_amount
+    min_amount: 1000
+    score: 30
+  - name: very_large_amount
+    min_amount: 10000
+    score: 60
+new_payee:
+  enabled: true
+  score: 15
+deny_list:
+  score: 100
+  payees: ["Blocked Ltd"]
+unusual_hours:
+  enabled: true
+  start_hour: 1
+  end_hour: 5
+  timezone: UTC
+  score: 20
+`
+
+func setupTestRisk(t *testing.T) (*risk.Service, *risk.RiskRepo) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(riskSchema)
+	assert.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "risk_rules.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(riskRules), 0o600))
+	rules, err := risk.LoadRules(path)
+	assert.NoError(t, err)
+
+	repo := &risk.RiskRepo{DB: &db.DB{Connection: conn}}
+	return risk.NewServiceWithEngine(risk.NewEngine(rules, repo), repo), repo
+}
+
+func ruleNames(assessment *risk.Assessment) []string {
+	var names []string
+	for _, rule := range assessment.TriggeredRules {
+		names = append(names, rule.Rule)
+	}
+	return names
+}
+
+func TestRiskRules(t *testing.T) {
+	noon := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name     string
+		input    risk.Input
+		score    int
+		decision risk.Decision
+		rules    []string
+	}{
+		{
+			name:     "New payee only",
+			input:    risk.Input{Username: "alice", PayTo: "Acme", Amount: 100, CreatedAt: noon},
+			score:    15,
+			decision: risk.DecisionApprove,
+			rules:    []string{"new_payee"},
+		},
+		{
+			name:     "Highest amount threshold only",
+			input:    risk.Input{Username: "alice", PayTo: "Acme", Amount: 15000, CreatedAt: noon},
+			score:    75,
+			decision: risk.DecisionReview,
+			rules:    []string{"very_large_amount", "new_payee"},
+		},
+		{
+			name:     "Deny list is case-insensitive and capped",
+			input:    risk.Input{Username: "alice", PayTo: "blocked ltd", Amount: 5000, CreatedAt: noon},
+			score:    risk.MaxScore,
+			decision: risk.DecisionReview,
+			rules:    []string{"large_amount", "new_payee", "deny_list"},
+		},
+		{
+			name:     "Unusual hours",
+			input:    risk.Input{Username: "alice", PayTo: "Acme", Amount: 100, CreatedAt: noon.Add(-9 * time.Hour)},
+			score:    35,
+			decision: risk.DecisionApprove,
+			rules:    []string{"new_payee", "unusual_hours"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			service, _ := setupTestRisk(t)
+
+			assessment, err := service.Assess(tt.input)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.score, assessment.Score)
+			assert.Equal(t, tt.decision, assessment.Decision)
+			assert.Equal(t, tt.rules, ruleNames(assessment))
+			assert.Equal(t, "test", assessment.RulesVersion)
+		})
+	}
+}
+
+func TestRiskVelocityAndReview(t *testing.T) {
+	service, repo := setupTestRisk(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	record := func(paymentID string, minutesAgo int) *risk.Assessment {
+		assessment, err := service.Assess(risk.Input{
+			PaymentID: paymentID,
+			Username:  "bob",
+			PayTo:     "Acme",
+			Amount:    2000,
+			CreatedAt: now.Add(-time.Duration(minutesAgo) * time.Minute),
+		})
+		assert.NoError(t, err)
+		if assessment.Decision == risk.DecisionReview {
+			_, err = repo.DB.Exec("INSERT INTO payments (payment_id, status) VALUES (?, ?)", paymentID, risk.PaymentStatusReview)
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, service.Record(ctx, assessment))
+		return assessment
+	}
+
+	// Outside the velocity window, and the payee is no longer new afterwards
+	first := record("p1", 120)
+	assert.Equal(t, []string{"large_amount", "new_payee"}, ruleNames(first))
+	second := record("p2", 30)
+	assert.Equal(t, []string{"large_amount"}, ruleNames(second))
+
+	third := record("p3", 10)
+	assert.Equal(t, risk.DecisionApprove, third.Decision)
+	fourth := record("p4", 0)
+	assert.Equal(t, []string{"user_hourly_count", "large_amount"}, ruleNames(fourth))
+	assert.Equal(t, risk.DecisionReview, fourth.Decision)
+
+	queue, err := service.Reviews(ctx, risk.ReviewPending)
+	assert.NoError(t, err)
+	assert.Len(t, queue, 1)
+	assert.Equal(t, "p4", queue[0].PaymentID)
+
+	reviewed, err := service.Approve(ctx, "p4", "reviewer", "called customer")
+	assert.NoError(t, err)
+	assert.Equal(t, risk.ReviewApproved, reviewed.ReviewStatus)
+
+	var status string
+	assert.NoError(t, repo.DB.Connection.QueryRow("SELECT status FROM payments WHERE payment_id = 'p4'").Scan(&status))
+	assert.Equal(t, risk.PaymentStatusApproved, status)
+
+	_, err = service.Reject(ctx, "p4", "reviewer", "")
+	assert.ErrorIs(t, err, risk.ErrNotInReview)
+	_, err = service.Approve(ctx, "missing", "reviewer", "")
+	assert.ErrorIs(t, err, risk.ErrAssessmentNotFound)
+
+	stored, err := service.GetAssessment(ctx, "p4")
+	assert.NoError(t, err)
+	assert.Equal(t, "reviewer", stored.ReviewedBy)
+	assert.Equal(t, fourth.TriggeredRules, stored.TriggeredRules)
+}
+
+func TestRiskReassess(t *testing.T) {
+	service, repo := setupTestRisk(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	now := time.Date(2025, 3, 3, 12, 0, 0, 0, time.UTC)
+
+	input := risk.Input{PaymentID: "p1", Username: "bob", PayTo: "Acme", Amount: 100, CreatedAt: now}
+	first, err := service.Assess(input)
+	assert.NoError(t, err)
+	assert.Equal(t, risk.DecisionApprove, first.Decision)
+	assert.NoError(t, service.Record(ctx, first))
+
+	// The payment's own earlier assessment neither makes the payee known nor counts towards velocity
+	input.Amount = 20000
+	input.CreatedAt = now.Add(time.Minute)
+	updated, err := service.Reassess(input)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"very_large_amount", "new_payee"}, ruleNames(updated))
+	assert.Equal(t, risk.DecisionReview, updated.Decision)
+	assert.NoError(t, service.Replace(ctx, updated))
+
+	stored, err := service.GetAssessment(ctx, "p1")
+	assert.NoError(t, err)
+	assert.Equal(t, updated.AssessmentID, stored.AssessmentID)
+	assert.Equal(t, risk.ReviewPending, stored.ReviewStatus)
+	var count int
+	assert.NoError(t, repo.DB.Connection.QueryRow("SELECT COUNT(*) FROM risk_assessments WHERE payment_id = 'p1'").Scan(&count))
+	assert.Equal(t, 1, count)
+}
+
+func TestRiskRulesReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "risk_rules.yaml")
+	assert.NoError(t, os.WriteFile(path, []byte(riskRules), 0o600))
+
+	engine := risk.NewEngine(nil, nil)
+	assert.NoError(t, engine.WatchRules(path))
+	assert.Equal(t, "test", engine.Rules().Version)
+
+	// An invalid file keeps the previous rules
+	assert.NoError(t, os.WriteFile(path, []byte("velocity:\n  - name: bad\n    scope: nobody\n"), 0o600))
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, "test", engine.Rules().Version)
+
+	assert.NoError(t, os.WriteFile(path, []byte("version: \"2\"\nreview_threshold: 50\n"), 0o600))
+	assert.Eventually(t, func() bool {
+		return engine.Rules().Version == "2"
+	}, 2*time.Second, 20*time.Millisecond)
+	assert.Equal(t, 50, engine.Rules().ReviewThreshold)
+}
@@ -0,0 +1,114 @@
+package test
+
+import (
+	"api/internal/router"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// tagMiddleware appends name to the X-Chain header so tests can see the order middleware ran in
+func tagMiddleware(name string) router.Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func newTestRouter() *router.Router {
+	mux := router.NewRouter()
+	mux.Alias("/api/", "/api/v1/")
+
+	v1 := mux.Group("/api/v1", tagMiddleware("v1"))
+	v1.Get("/health", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	payments := v1.Group("/payments", tagMiddleware("payments"))
+	payments.Get("", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("list"))
+	})
+	payments.Get("/totals", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("totals"))
+	})
+	payments.Get("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("get " + router.Param(r, "id")))
+	})
+	payments.Delete("/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("delete " + router.Param(r, "id")))
+	})
+	return mux
+}
+
+func serveTestRouter(mux http.Handler, method, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	mux.ServeHTTP(w, httptest.NewRequest(method, path, nil))
+	return w
+}
+
+func TestRouterMethodsAndParams(t *testing.T) {
+	mux := newTestRouter()
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		status int
+		body   string
+	}{
+		{"Static route", http.MethodGet, "/api/v1/payments", http.StatusOK, "list"},
+		{"Static segment wins over parameter", http.MethodGet, "/api/v1/payments/totals", http.StatusOK, "totals"},
+		{"Path parameter", http.MethodGet, "/api/v1/payments/PAY-1", http.StatusOK, "get PAY-1"},
+		{"Method routing", http.MethodDelete, "/api/v1/payments/PAY-1", http.StatusOK, "delete PAY-1"},
+		{"Method not allowed", http.MethodPost, "/api/v1/payments/PAY-1", http.StatusMethodNotAllowed, ""},
+		{"Unknown route", http.MethodGet, "/api/v1/unknown", http.StatusNotFound, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := serveTestRouter(mux, tt.method, tt.path)
+			assert.Equal(t, tt.status, w.Code)
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+
+	w := serveTestRouter(mux, http.MethodPost, "/api/v1/payments/PAY-1")
+	assert.Contains(t, w.Header().Get("Allow"), http.MethodDelete)
+}
+
+func TestRouterGroupMiddleware(t *testing.T) {
+	mux := newTestRouter()
+
+	w := serveTestRouter(mux, http.MethodGet, "/api/v1/payments/PAY-1")
+	assert.Equal(t, []string{"v1", "payments"}, w.Header().Values("X-Chain"))
+
+	w = serveTestRouter(mux, http.MethodGet, "/api/v1/health")
+	assert.Equal(t, []string{"v1"}, w.Header().Values("X-Chain"))
+}
+
+func TestRouterAlias(t *testing.T) {
+	mux := newTestRouter()
+
+	w := serveTestRouter(mux, http.MethodGet, "/api/payments/PAY-2")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "get PAY-2", w.Body.String())
+	assert.Equal(t, "true", w.Header().Get("Deprecation"))
+	assert.True(t, strings.HasPrefix(w.Header().Get("Link"), "</api/v1/payments/PAY-2>"))
+	assert.Equal(t, []string{"v1", "payments"}, w.Header().Values("X-Chain"))
+
+	w = serveTestRouter(mux, http.MethodPut, "/api/payments/PAY-2")
+	assert.Equal(t, http.StatusMethodNotAllowed, w.Code)
+
+	w = serveTestRouter(mux, http.MethodGet, "/api/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+
+	w = serveTestRouter(mux, http.MethodGet, "/api/v1/missing")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+	assert.Empty(t, w.Header().Get("Deprecation"))
+}
@@ -0,0 +1,103 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/payment"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupTestScheduler shares the payments and EUR/USD rate of setupTestBatches
+func setupTestScheduler(t *testing.T) (*payment.Scheduler, *sql.DB) {
+	_, conn := setupTestBatches(t)
+	_, err := conn.Exec(scheduleSchema)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	converter := fx.NewConverterWithRepo(&fx.FXRepo{DB: database}, "usd")
+	return payment.NewSchedulerWithRepo(&payment.ScheduleRepo{DB: database}, &payment.PaymentRepo{DB: database}, converter), conn
+}
+
+func TestSchedulerLocksRate(t *testing.T) {
+	scheduler, conn := setupTestScheduler(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	start := time.Now().Add(-time.Hour)
+
+	priced := &payment.PaymentSchedule{
+		Template:   payment.PaymentTemplate{Amount: 100, Currency: "EUR", PaymentMethod: "bank_transfer", PayTo: "ACME Ltd"},
+		Recurrence: "FREQ=DAILY;COUNT=1",
+		StartDate:  start,
+	}
+	unpriced := &payment.PaymentSchedule{
+		Template:   payment.PaymentTemplate{Amount: 100, Currency: "JPY", PaymentMethod: "bank_transfer", PayTo: "Globex"},
+		Recurrence: "FREQ=DAILY;COUNT=1",
+		StartDate:  start,
+	}
+	assert.NoError(t, scheduler.CreateSchedule(ctx, priced, "ops"))
+	assert.NoError(t, scheduler.CreateSchedule(ctx, unpriced, "ops"))
+
+	processed, err := scheduler.RunDue()
+	assert.NoError(t, err)
+	assert.Equal(t, 2, processed)
+
+	// The materialized payment carries the rate locked when it was inserted
+	runs, err := scheduler.History(ctx, priced.ScheduleID)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, payment.RunSucceeded, runs[0].Status)
+	var baseCurrency string
+	var baseAmount float64
+	assert.NoError(t, conn.QueryRow("SELECT base_currency, base_amount FROM payments WHERE payment_id = ?", runs[0].PaymentID).Scan(&baseCurrency, &baseAmount))
+	assert.Equal(t, "USD", baseCurrency)
+	assert.Equal(t, 110.0, baseAmount)
+
+	// Without a rate the attempt fails and is retried later
+	runs, err = scheduler.History(ctx, unpriced.ScheduleID)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, payment.RunFailed, runs[0].Status)
+	assert.NotNil(t, runs[0].NextAttemptAt)
+	assert.Equal(t, 1, countPayments(t, conn))
+}
+
+func TestSchedulerRetryPolicy(t *testing.T) {
+	scheduler, _ := setupTestScheduler(t)
+	ctx := tenantContext(auth.DefaultTenantID)
+	template := payment.PaymentTemplate{Amount: 100, Currency: "JPY", PaymentMethod: "bank_transfer", PayTo: "Globex"}
+
+	invalid := []payment.RetryPolicy{
+		{MaxAttempts: -1},
+		{MaxAttempts: payment.MaxRetryAttempts + 1},
+		{MaxAttempts: 1 << 40},
+		{RetryMinutes: -1},
+		{RetryMinutes: 1 << 62},
+	}
+	for _, retry := range invalid {
+		schedule := &payment.PaymentSchedule{Template: template, Recurrence: "FREQ=DAILY", Retry: retry}
+		assert.ErrorIs(t, scheduler.CreateSchedule(ctx, schedule, "ops"), payment.ErrInvalidSchedule, retry)
+	}
+
+	// The longest allowed policy waits at most a day between attempts
+	schedule := &payment.PaymentSchedule{
+		Template:   template,
+		Recurrence: "FREQ=DAILY;COUNT=1",
+		StartDate:  time.Now().Add(-time.Hour),
+		Retry:      payment.RetryPolicy{MaxAttempts: payment.MaxRetryAttempts, RetryMinutes: int(payment.MaxRetryDelay / time.Minute)},
+	}
+	assert.NoError(t, scheduler.CreateSchedule(ctx, schedule, "ops"))
+	_, err := scheduler.RunDue()
+	assert.NoError(t, err)
+
+	runs, err := scheduler.History(ctx, schedule.ScheduleID)
+	assert.NoError(t, err)
+	assert.Len(t, runs, 1)
+	assert.Equal(t, payment.RunFailed, runs[0].Status)
+	assert.WithinDuration(t, time.Now().Add(payment.MaxRetryDelay), *runs[0].NextAttemptAt, time.Minute)
+}
@@ -0,0 +1,717 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/consent"
+	"api/internal/contact"
+	"api/internal/db"
+	"api/internal/fx"
+	"api/internal/iso20022"
+	"api/internal/loan"
+	"api/internal/payment"
+	"api/internal/reconcile"
+	"api/internal/risk"
+	"api/internal/tenant"
+	"api/pkg/data"
+	"api/pkg/data/models"
+	"context"
+	"database/sql"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tenantSchema = `
+CREATE TABLE tenants (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    slug TEXT NOT NULL UNIQUE,
+    name TEXT NOT NULL,
+    config TEXT NOT NULL DEFAULT '{}',
+    status_id INTEGER NOT NULL DEFAULT 1,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    updated_by TEXT NOT NULL
+);
+INSERT INTO tenants (id, slug, name, created_at, created_by, updated_at, updated_by)
+VALUES (1, 'root', 'Root tenant', datetime('now'), 'test', datetime('now'), 'test');`
+
+func tenantContext(tenantID int) context.Context {
+	return auth.WithTenantID(context.Background(), tenantID)
+}
+
+func TestScopedDB(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(`CREATE TABLE notes (id INTEGER PRIMARY KEY, body TEXT NOT NULL, tenant_id INTEGER NOT NULL)`)
+	assert.NoError(t, err)
+
+	_, err = tenant.Scope(context.Background(), conn)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+
+	acme, err := tenant.Scope(tenantContext(2), conn)
+	assert.NoError(t, err)
+	globex, err := tenant.Scope(tenantContext(3), conn)
+	assert.NoError(t, err)
+	_, err = acme.Insert("notes", []string{"id", "body"}, 1, "acme note")
+	assert.NoError(t, err)
+	_, err = globex.Insert("notes", []string{"id", "body"}, 2, "globex note")
+	assert.NoError(t, err)
+
+	_, err = acme.Insert("notes", []string{"id", "tenant_id"}, 3, 3)
+	assert.ErrorIs(t, err, tenant.ErrTenantColumn)
+	_, err = acme.Insert("notes; DROP TABLE notes", []string{"id"}, 3)
+	assert.ErrorIs(t, err, tenant.ErrInvalidQuery)
+	_, err = acme.Update("notes", "tenant_id = ?", "id = ?", 2, 1)
+	assert.ErrorIs(t, err, tenant.ErrTenantColumn)
+
+	// A condition cannot widen the scope to other tenants
+	args := make([]interface{}, 1, 4)
+	args[0] = 2
+	rows, err := acme.Select("notes", "id", "id = ? OR 1 = 1", "id DESC", args...)
+	assert.NoError(t, err)
+	var ids []int
+	for rows.Next() {
+		var id int
+		assert.NoError(t, rows.Scan(&id))
+		ids = append(ids, id)
+	}
+	assert.NoError(t, rows.Close())
+	assert.Equal(t, []int{1}, ids)
+	assert.Nil(t, args[:2][1], "caller's arguments are not modified")
+
+	row, err := acme.SelectRow("notes", "body", "id = ?", 2)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, row.Scan(new(string)), sql.ErrNoRows)
+
+	result, err := acme.Update("notes", "body = ?", "id = ?", "changed", 2)
+	assert.NoError(t, err)
+	affected, _ := result.RowsAffected()
+	assert.Zero(t, affected)
+	result, err = acme.Delete("notes", "")
+	assert.NoError(t, err)
+	affected, _ = result.RowsAffected()
+	assert.Equal(t, int64(1), affected)
+
+	var body string
+	assert.NoError(t, conn.QueryRow(`SELECT body FROM notes WHERE id = 2`).Scan(&body))
+	assert.Equal(t, "globex note", body)
+}
+
+func setupTestTenants(t *testing.T) (*tenant.Service, *sql.DB) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(rbacSchema + ";" + tenantSchema + `;
+		INSERT INTO users (user_id, user_name, password, salt, created_at, created_by, status_id)
+		VALUES (1, 'root-admin', 'x', '', datetime('now'), 'test', 1), (2, 'clerk', 'x', '', datetime('now'), 'test', 1);
+		INSERT INTO roles (role_id, role_name, is_super_admin, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 'super', 1, datetime('now'), 'test', datetime('now'), 'test', 1);
+		INSERT INTO user_roles (role_id, user_id, created_at, created_by, updated_at, updated_by, status_id)
+		VALUES (1, 1, datetime('now'), 'test', datetime('now'), 'test', 1);`)
+	assert.NoError(t, err)
+
+	database := &db.DB{Connection: conn}
+	service := tenant.NewService(&tenant.TenantRepo{DB: database}, &auth.UserRepo{DB: database},
+		&auth.UserPermissionRepo{DB: database}, cheapPasswordParams)
+	return service, conn
+}
+
+func TestTenantProvisioning(t *testing.T) {
+	service, conn := setupTestTenants(t)
+	database := &db.DB{Connection: conn}
+
+	request := tenant.ProvisionRequest{
+		Slug: "Acme",
+		Name: "Acme Lending",
+		Config: tenant.Config{
+			Products:  []tenant.Product{{Code: "personal", Name: "Personal loan", MinAmount: 1000, MaxAmount: 50000, MaxTerm: 36, InterestRate: 12.5}},
+			Branding:  tenant.Branding{DisplayName: "Acme", PrimaryColor: "#ff0000"},
+			RateLimit: tenant.RateLimit{RequestsPerSecond: 5, Burst: 20},
+		},
+		AdminUsername: "acme-admin",
+		AdminPassword: "correct horse",
+	}
+
+	invalid := request
+	invalid.Slug = "a"
+	_, err := service.Provision(invalid, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrInvalidTenant)
+	invalid = request
+	invalid.AdminPassword = "short"
+	_, err = service.Provision(invalid, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrInvalidAdmin)
+	invalid = request
+	invalid.Config = tenant.Config{Products: []tenant.Product{{Code: "a"}, {Code: "a"}}}
+	_, err = service.Provision(invalid, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrInvalidConfig)
+	invalid = request
+	invalid.AdminUsername = "clerk"
+	_, err = service.Provision(invalid, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrAdminExists)
+
+	provisioned, err := service.Provision(request, "root-admin")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, provisioned.Tenant.ID)
+	assert.Equal(t, "acme", provisioned.Tenant.Slug)
+	_, err = service.Provision(request, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrTenantExists)
+
+	// The administrator of the new tenant is not a super admin: the role is granted
+	// the routes over the tenant's own data only
+	admin, err := (&auth.UserRepo{DB: database}).GetUserByID(provisioned.AdminUserID)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, admin.TenantID)
+	ok, _, err := auth.VerifyPassword(admin, "correct horse", cheapPasswordParams)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	roles := &auth.RoleRepo{DB: database}
+	role, err := roles.GetRoleByID(provisioned.AdminRoleID)
+	assert.NoError(t, err)
+	assert.False(t, role.IsSuperAdmin)
+	assert.Equal(t, 2, role.TenantID)
+	isSuperAdmin, err := (&auth.UserPermissionRepo{DB: database}).IsSuperAdmin(provisioned.AdminUserID)
+	assert.NoError(t, err)
+	assert.False(t, isSuperAdmin)
+
+	rules := auth.NewPermissionEvaluator(roles, 0)
+	decision, err := rules.Evaluate(provisioned.AdminUserID, http.MethodPost, "/api/v1/payments")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed, decision.Reason)
+	decision, err = rules.Evaluate(provisioned.AdminUserID, http.MethodDelete, "/api/v1/roles/5")
+	assert.NoError(t, err)
+	assert.True(t, decision.Allowed, decision.Reason)
+	decision, err = rules.Evaluate(provisioned.AdminUserID, http.MethodPost, "/api/v1/fx/rates")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+	decision, err = rules.Evaluate(provisioned.AdminUserID, http.MethodPost, "/api/v1/users/1/unlock")
+	assert.NoError(t, err)
+	assert.False(t, decision.Allowed)
+
+	assert.NoError(t, service.RequireRootAdmin(1))
+	assert.ErrorIs(t, service.RequireRootAdmin(2), tenant.ErrRootAdminRequired)
+	assert.ErrorIs(t, service.RequireRootAdmin(provisioned.AdminUserID), tenant.ErrRootAdminRequired)
+
+	config, err := service.Config(2)
+	assert.NoError(t, err)
+	assert.Equal(t, "personal", config.Products[0].Code)
+	assert.Equal(t, 20, config.RateLimit.Burst)
+	_, err = service.Get(9)
+	assert.ErrorIs(t, err, tenant.ErrTenantNotFound)
+
+	tenants, err := service.List()
+	assert.NoError(t, err)
+	assert.Len(t, tenants, 2)
+}
+
+func TestTenantSuspension(t *testing.T) {
+	service, _ := setupTestTenants(t)
+	provisioned, err := service.Provision(tenant.ProvisionRequest{Slug: "acme", Name: "Acme", AdminUsername: "acme-admin", AdminPassword: "correct horse"}, "root-admin")
+	assert.NoError(t, err)
+
+	// Tokens issued before tenants existed belong to the root tenant
+	r, err := service.Attach(httptest.NewRequest("GET", "/loans/list", nil), &auth.JwtClaims{UserID: 2})
+	assert.NoError(t, err)
+	tenantID, ok := auth.TenantIDFromContext(r.Context())
+	assert.True(t, ok)
+	assert.Equal(t, auth.DefaultTenantID, tenantID)
+
+	claims := &auth.JwtClaims{UserID: provisioned.AdminUserID, TenantID: provisioned.Tenant.ID}
+	r, err = service.Attach(httptest.NewRequest("GET", "/loans/list", nil), claims)
+	assert.NoError(t, err)
+	tenantID, _ = auth.TenantIDFromContext(r.Context())
+	assert.Equal(t, provisioned.Tenant.ID, tenantID)
+
+	// API keys act in the tenant of their owner
+	keyRequest := httptest.NewRequest("GET", "/loans/list", nil)
+	keyRequest = keyRequest.WithContext(auth.WithAPIKey(keyRequest.Context(), &auth.APIKey{UserID: provisioned.AdminUserID}))
+	r, err = service.Attach(keyRequest, nil)
+	assert.NoError(t, err)
+	tenantID, _ = auth.TenantIDFromContext(r.Context())
+	assert.Equal(t, provisioned.Tenant.ID, tenantID)
+
+	suspended := tenant.StatusSuspended
+	updated, err := service.Update(provisioned.Tenant.ID, tenant.UpdateRequest{StatusID: &suspended}, "root-admin")
+	assert.NoError(t, err)
+	assert.Equal(t, "root-admin", updated.UpdatedBy)
+	_, err = service.Attach(httptest.NewRequest("GET", "/loans/list", nil), claims)
+	assert.ErrorIs(t, err, tenant.ErrTenantSuspended)
+
+	_, err = service.Update(auth.DefaultTenantID, tenant.UpdateRequest{StatusID: &suspended}, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrRootTenant)
+	unknown := 7
+	_, err = service.Update(provisioned.Tenant.ID, tenant.UpdateRequest{StatusID: &unknown}, "root-admin")
+	assert.ErrorIs(t, err, tenant.ErrInvalidStatus)
+}
+
+func TestTenantTokensAndRoles(t *testing.T) {
+	tokens := setupTestTokens(t, time.Hour)
+	tokens.SetTenantResolver(func(userID int) (int, error) { return 4, nil })
+	issued, err := tokens.IssueTokens(5, "acme-user", []string{"pwd"})
+	assert.NoError(t, err)
+	claims, err := tokens.ParseAccessToken(issued.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, claims.Tenant())
+
+	service, admin, clerk := setupTestRBAC(t)
+	acmeAdmin := auth.RBACActor{UserID: 1, Username: "admin", TenantID: 2}
+	acmeRole, err := service.CreateRole(auth.Role{RoleName: "acme-teller"}, acmeAdmin)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, acmeRole.TenantID)
+
+	// bob belongs to the root tenant and cannot hold a role of another tenant
+	_, err = service.AssignRole(3, acmeRole.RoleID, admin)
+	assert.ErrorIs(t, err, auth.ErrTenantMismatch)
+
+	// Roles and users of the root tenant are not found from another tenant
+	_, err = service.GetRole(1, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.ListPermissions(1, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.UpdateRole(1, auth.Role{RoleName: "taken"}, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.DeleteRole(1, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.AddPermission(1, auth.RolePermissions{ResourceName: "/api/payments", CanRead: true}, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.UpdatePermission(1, 1, auth.RolePermissions{ResourceName: "/api/payments", CanRead: true}, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.DeletePermission(1, 1, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	_, err = service.UserAccess(3, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+	_, err = service.AssignRole(3, acmeRole.RoleID, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+	_, err = service.RemoveRole(1, 1, acmeAdmin)
+	assert.ErrorIs(t, err, auth.ErrUserNotFound)
+
+	// Only super admins of the root tenant reach other tenants
+	_, err = service.GetRole(acmeRole.RoleID, clerk)
+	assert.ErrorIs(t, err, auth.ErrRoleNotFound)
+	role, err := service.GetRole(acmeRole.RoleID, admin)
+	assert.NoError(t, err)
+	assert.Equal(t, "acme-teller", role.RoleName)
+}
+
+func TestTenantScopedLoans(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	_, err = conn.Exec(schema)
+	assert.NoError(t, err)
+	service := loan.NewLoanService(conn, &mockCreditService{}, &mockPaymentService{}, &mockDocumentService{})
+
+	assert.NoError(t, service.ApplyForLoan(&loan.LoanApplication{ID: "R1", ApplicantID: "7", Amount: 1000, Term: 12}, nil))
+	assert.NoError(t, service.ApplyForLoan(&loan.LoanApplication{ID: "A1", ApplicantID: "7", Amount: 2000, Term: 12, TenantID: 2}, nil))
+
+	_, err = service.ListApplications(context.Background(), nil)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+
+	listed, err := service.ListApplications(tenantContext(2), nil)
+	assert.NoError(t, err)
+	assert.Len(t, listed, 1)
+	assert.Equal(t, "A1", listed[0].ID)
+	assert.Equal(t, 2, listed[0].TenantID)
+
+	application, err := service.GetApplication(tenantContext(auth.DefaultTenantID), "R1")
+	assert.NoError(t, err)
+	assert.Equal(t, auth.DefaultTenantID, application.TenantID)
+	_, err = service.GetApplication(tenantContext(2), "R1")
+	assert.ErrorIs(t, err, loan.ErrLoanNotFound)
+}
+
+func TestTenantScopedPayments(t *testing.T) {
+	_, repo := setupTestFX(t)
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+
+	_, err := repo.InsertPayment(context.Background(), &payment.Payment{PaymentID: "P0", Amount: 1, PaymentMethod: "card"})
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+
+	_, err = repo.InsertPayment(root, &payment.Payment{PaymentID: "P1", Amount: 10, Currency: "USD", PaymentMethod: "card", PaymentDate: "2025-01-15"})
+	assert.NoError(t, err)
+
+	_, err = repo.GetPaymentByID(other, "P1")
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repo.UpdatePayment(other, &payment.Payment{PaymentID: "P1", Amount: 99, Currency: "USD", PaymentMethod: "card"})
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	_, err = repo.DeletePayment(other, "P1")
+	assert.NoError(t, err)
+
+	listed, err := repo.GetPayments(other, db.PaginationParams{Type: db.OffsetPagination, Limit: 10}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), listed.Total)
+	assert.Empty(t, listed.Data)
+	groups, err := repo.GetTotalGroups(other, payment.PaymentFilter{})
+	assert.NoError(t, err)
+	assert.Empty(t, groups)
+
+	stored, err := repo.GetPaymentByID(root, "P1")
+	assert.NoError(t, err)
+	assert.Equal(t, 10.0, stored.Amount)
+	listed, err = repo.GetPayments(root, db.PaginationParams{Type: db.OffsetPagination, Limit: 10}, "")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), listed.Total)
+	groups, err = repo.GetTotalGroups(root, payment.PaymentFilter{})
+	assert.NoError(t, err)
+	assert.Len(t, groups, 1)
+}
+
+func TestTenantScopedRiskReviews(t *testing.T) {
+	service, repo := setupTestRisk(t)
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+
+	assessment, err := service.Assess(risk.Input{PaymentID: "P1", Username: "bob", PayTo: "Blocked Ltd", Amount: 10})
+	assert.NoError(t, err)
+	assert.Equal(t, risk.DecisionReview, assessment.Decision)
+	_, err = repo.DB.Exec("INSERT INTO payments (payment_id, status) VALUES (?, ?)", "P1", risk.PaymentStatusReview)
+	assert.NoError(t, err)
+	assert.ErrorIs(t, service.Record(context.Background(), assessment), tenant.ErrNoTenant)
+	assert.NoError(t, service.Record(root, assessment))
+
+	queue, err := service.Reviews(other, risk.ReviewPending)
+	assert.NoError(t, err)
+	assert.Empty(t, queue)
+	_, err = service.GetAssessment(other, "P1")
+	assert.ErrorIs(t, err, risk.ErrAssessmentNotFound)
+	_, err = service.Approve(other, "P1", "intruder", "")
+	assert.ErrorIs(t, err, risk.ErrAssessmentNotFound)
+
+	var status string
+	assert.NoError(t, repo.DB.Connection.QueryRow("SELECT status FROM payments WHERE payment_id = 'P1'").Scan(&status))
+	assert.Equal(t, risk.PaymentStatusReview, status)
+	queue, err = service.Reviews(root, risk.ReviewPending)
+	assert.NoError(t, err)
+	assert.Len(t, queue, 1)
+}
+
+func TestTenantScopedExport(t *testing.T) {
+	_, repo := setupTestFX(t)
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+	exporter := iso20022.NewExporterWithRepo(&iso20022.ExportRepo{DB: repo.DB}, exportOptions.Debtor)
+
+	_, err := repo.InsertPayment(root, &payment.Payment{PaymentID: "P1", Amount: 10, Currency: "USD", PaymentMethod: "card", PaymentDate: "2025-01-15", PayTo: "ACME Ltd", Status: "pending"})
+	assert.NoError(t, err)
+
+	_, err = exporter.Export(context.Background(), iso20022.MessagePain001, iso20022.Filter{}, "")
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	_, err = exporter.Export(other, iso20022.MessagePain001, iso20022.Filter{}, "")
+	assert.ErrorIs(t, err, iso20022.ErrNoPayments)
+	body, err := exporter.Export(root, iso20022.MessagePain001, iso20022.Filter{}, "")
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "ACME Ltd")
+}
+
+const reconcileSchema = `
+CREATE TABLE loan_applications (
+    id TEXT PRIMARY KEY,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE payment_periods (
+    id TEXT PRIMARY KEY,
+    loan_id TEXT NOT NULL,
+    amount REAL NOT NULL,
+    due_date DATETIME NOT NULL,
+    status TEXT NOT NULL
+);
+CREATE TABLE statement_imports (
+    import_id TEXT PRIMARY KEY,
+    file_name TEXT,
+    format TEXT NOT NULL,
+    line_count INTEGER NOT NULL,
+    matched INTEGER NOT NULL DEFAULT 0,
+    suggested INTEGER NOT NULL DEFAULT 0,
+    unmatched INTEGER NOT NULL DEFAULT 0,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE statement_lines (
+    line_id TEXT PRIMARY KEY,
+    import_id TEXT NOT NULL,
+    bank_reference TEXT,
+    booking_date DATETIME NOT NULL,
+    amount DECIMAL(15, 2) NOT NULL,
+    currency TEXT,
+    reference TEXT,
+    description TEXT,
+    counterparty TEXT,
+    status TEXT NOT NULL,
+    match_type TEXT NOT NULL DEFAULT '',
+    match_id TEXT NOT NULL DEFAULT '',
+    score INTEGER NOT NULL DEFAULT 0,
+    reviewed_by TEXT,
+    reviewed_at DATETIME,
+    created_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+INSERT INTO loan_applications (id, tenant_id) VALUES ('L1', 1);
+INSERT INTO payment_periods (id, loan_id, amount, due_date, status)
+VALUES ('INST-2001', 'L1', 250, '2025-01-20 00:00:00', 'PENDING');`
+
+func TestTenantScopedReconciliation(t *testing.T) {
+	_, repo := setupTestFX(t)
+	_, err := repo.DB.Connection.Exec(reconcileSchema)
+	assert.NoError(t, err)
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+	reconciler := reconcile.NewReconcilerWithRepo(&reconcile.ReconcileRepo{DB: repo.DB}, 3)
+
+	_, err = repo.InsertPayment(root, &payment.Payment{PaymentID: "PAY-1001", Amount: 10, Currency: "USD", PaymentMethod: "card", PaymentDate: "2025-01-15"})
+	assert.NoError(t, err)
+	statement := "Date,Amount,Currency,Reference\n2025-01-15,10.00,USD,PAY-1001\n2025-01-20,250.00,USD,INST-2001\n"
+
+	// Another tenant's statement cannot match the root tenant's payments or installments
+	imported, err := reconciler.Import(other, "other.csv", reconcile.FormatCSV, strings.NewReader(statement), "intruder")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported.Unmatched)
+	otherLines, err := reconciler.Lines(other, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, otherLines, 2)
+	_, err = reconciler.Override(other, otherLines[0].LineID, reconcile.OverrideRequest{MatchType: reconcile.MatchPayment, MatchID: "PAY-1001"}, "intruder")
+	assert.ErrorIs(t, err, reconcile.ErrCandidateNotFound)
+
+	imported, err = reconciler.Import(root, "root.csv", reconcile.FormatCSV, strings.NewReader(statement), "ops")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, imported.Matched)
+	rootLines, err := reconciler.Lines(root, "", "")
+	assert.NoError(t, err)
+	assert.Len(t, rootLines, 2)
+
+	// Lines of one tenant are neither listed nor reviewable by another
+	_, err = reconciler.Confirm(other, rootLines[0].LineID, "intruder")
+	assert.ErrorIs(t, err, reconcile.ErrLineNotFound)
+	otherLines, err = reconciler.Lines(other, reconcile.StatusMatched, "")
+	assert.NoError(t, err)
+	assert.Empty(t, otherLines)
+}
+
+const scheduleSchema = `
+CREATE TABLE payment_schedules (
+    schedule_id TEXT PRIMARY KEY,
+    amount REAL NOT NULL,
+    currency TEXT,
+    payment_method TEXT NOT NULL,
+    pay_to TEXT NOT NULL,
+    note TEXT,
+    description TEXT,
+    recurrence TEXT NOT NULL,
+    start_date DATETIME NOT NULL,
+    end_date DATETIME,
+    max_occurrences INTEGER NOT NULL DEFAULT 0,
+    occurrences INTEGER NOT NULL DEFAULT 0,
+    next_run_at DATETIME,
+    max_attempts INTEGER NOT NULL,
+    retry_minutes INTEGER NOT NULL,
+    status TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    created_by TEXT NOT NULL,
+    updated_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);
+CREATE TABLE payment_schedule_runs (
+    run_id TEXT PRIMARY KEY,
+    schedule_id TEXT NOT NULL,
+    occurrence INTEGER NOT NULL,
+    due_date DATETIME NOT NULL,
+    payment_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    last_error TEXT NOT NULL DEFAULT '',
+    next_attempt_at DATETIME,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    UNIQUE (schedule_id, occurrence)
+);`
+
+func TestTenantScopedSchedulesAndBatches(t *testing.T) {
+	processor, conn := setupTestBatches(t)
+	_, err := conn.Exec(scheduleSchema)
+	assert.NoError(t, err)
+	scheduler := payment.NewSchedulerWithRepo(&payment.ScheduleRepo{DB: &db.DB{Connection: conn}}, &payment.PaymentRepo{DB: &db.DB{Connection: conn}}, fx.NewConverterWithRepo(&fx.FXRepo{DB: &db.DB{Connection: conn}}, "usd"))
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+	later := time.Now().Add(24 * time.Hour)
+
+	schedule := &payment.PaymentSchedule{
+		Template:   payment.PaymentTemplate{Amount: 250, Currency: "USD", PaymentMethod: "bank_transfer", PayTo: "ACME Ltd"},
+		Recurrence: "FREQ=MONTHLY",
+		StartDate:  later,
+	}
+	assert.NoError(t, scheduler.CreateSchedule(root, schedule, "ops"))
+
+	_, err = scheduler.GetSchedule(other, schedule.ScheduleID)
+	assert.ErrorIs(t, err, payment.ErrScheduleNotFound)
+	_, err = scheduler.History(other, schedule.ScheduleID)
+	assert.ErrorIs(t, err, payment.ErrScheduleNotFound)
+	_, err = scheduler.Pause(other, schedule.ScheduleID)
+	assert.ErrorIs(t, err, payment.ErrScheduleNotFound)
+	_, err = scheduler.Cancel(other, schedule.ScheduleID)
+	assert.ErrorIs(t, err, payment.ErrScheduleNotFound)
+	schedules, err := scheduler.ListSchedules(other, "")
+	assert.NoError(t, err)
+	assert.Empty(t, schedules)
+
+	paused, err := scheduler.Pause(root, schedule.ScheduleID)
+	assert.NoError(t, err)
+	assert.Equal(t, payment.SchedulePaused, paused.Status)
+	schedules, err = scheduler.ListSchedules(root, payment.SchedulePaused)
+	assert.NoError(t, err)
+	assert.Len(t, schedules, 1)
+
+	batch, err := processor.Submit(root, payment.BatchUpload{Data: []byte(batchCSV), ExecuteAt: &later}, "ops")
+	assert.NoError(t, err)
+
+	_, err = processor.GetBatch(other, batch.BatchID)
+	assert.ErrorIs(t, err, payment.ErrBatchNotFound)
+	_, err = processor.Cancel(other, batch.BatchID)
+	assert.ErrorIs(t, err, payment.ErrBatchNotFound)
+	_, err = processor.Execute(other, batch.BatchID)
+	assert.ErrorIs(t, err, payment.ErrBatchNotFound)
+	batches, err := processor.ListBatches(other, "")
+	assert.NoError(t, err)
+	assert.Empty(t, batches)
+
+	cancelled, err := processor.Cancel(root, batch.BatchID)
+	assert.NoError(t, err)
+	assert.Equal(t, payment.BatchCancelled, cancelled.Status)
+}
+
+const consentSchema = `
+CREATE TABLE consents (
+    consent_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    patient_id TEXT NOT NULL,
+    source_hospital TEXT NOT NULL,
+    target_hospital TEXT NOT NULL,
+    purpose TEXT NOT NULL,
+    data_categories TEXT NOT NULL,
+    start_date DATETIME NOT NULL,
+    expiry_date DATETIME NOT NULL,
+    status TEXT NOT NULL,
+    version INTEGER NOT NULL DEFAULT 1,
+    signature TEXT NOT NULL,
+    created_at DATETIME NOT NULL,
+    updated_at DATETIME NOT NULL,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);`
+
+func TestTenantScopedConsents(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(consentSchema)
+	assert.NoError(t, err)
+	repo := &consent.ConsentRepo{DB: &db.DB{Connection: conn}}
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+
+	now := time.Now()
+	record := &consent.Consent{PatientID: "7", SourceHospital: "A", TargetHospital: "B", Purpose: "care",
+		DataCategories: []string{"labs"}, StartDate: now, ExpiryDate: now.AddDate(1, 0, 0), Status: "ACTIVE",
+		Version: 1, Signature: "sig", CreatedAt: now, UpdatedAt: now}
+	_, err = repo.InsertConsent(context.Background(), record)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	id, err := repo.InsertConsent(root, record)
+	assert.NoError(t, err)
+
+	_, err = repo.GetConsentByID(other, strconv.Itoa(id))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	changed := *record
+	changed.Status = "REVOKED"
+	_, err = repo.UpdateConsent(other, &changed)
+	assert.NoError(t, err)
+	_, err = repo.DeleteConsent(other, id)
+	assert.NoError(t, err)
+
+	params := db.PaginationParams{Type: db.OffsetPagination, Limit: 10, SortFields: []string{"consent_id"}, SortOrder: "ASC"}
+	listed, err := repo.GetConsents(other, params)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), listed.Total)
+
+	stored, err := repo.GetConsentByID(root, strconv.Itoa(id))
+	assert.NoError(t, err)
+	assert.Equal(t, "ACTIVE", stored.Status)
+	listed, err = repo.GetConsents(root, params)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), listed.Total)
+}
+
+const contactSchema = `
+CREATE TABLE contact (
+    contact_id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name TEXT,
+    first_name TEXT,
+    last_name TEXT,
+    gender_id INTEGER,
+    dob DATETIME,
+    email TEXT,
+    phone TEXT,
+    address TEXT,
+    photo_path TEXT,
+    created_at DATETIME,
+    created_by TEXT,
+    tenant_id INTEGER NOT NULL DEFAULT 1
+);`
+
+func TestTenantScopedContacts(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(contactSchema)
+	assert.NoError(t, err)
+	repo := &contact.ContactRepo{DB: &data.DB{Connection: conn}}
+	root, other := tenantContext(auth.DefaultTenantID), tenantContext(2)
+
+	record := &models.ContactModel{Name: "Ada Lovelace", FirstName: "Ada", LastName: "Lovelace", Email: "ada@example.com", CreatedAt: time.Now(), CreatedBy: "test"}
+	_, err = repo.InsertContact(context.Background(), record)
+	assert.ErrorIs(t, err, tenant.ErrNoTenant)
+	id, err := repo.InsertContact(root, record)
+	assert.NoError(t, err)
+	total, err := repo.InsertContacts(other, []*models.ContactModel{{Name: "Grace Hopper", CreatedAt: time.Now(), CreatedBy: "test"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), total)
+
+	_, err = repo.GetContactByID(other, int(id))
+	assert.ErrorIs(t, err, sql.ErrNoRows)
+	changed := *record
+	changed.ContactId = id
+	changed.Name = "Changed"
+	affected, err := repo.UpdateContact(other, &changed)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+	affected, err = repo.DeleteContact(other, int(id))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(0), affected)
+
+	contacts, pager, err := repo.GetContactsBySearchTextPagination(other, "", 1, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, pager.TotalItems)
+	assert.Len(t, contacts, 1)
+	assert.Equal(t, "Grace Hopper", contacts[0].Name)
+
+	stored, err := repo.GetContactByID(root, int(id))
+	assert.NoError(t, err)
+	assert.Equal(t, "Ada Lovelace", stored.Name)
+	contacts, err = repo.GetContactsBySearchText(root, "Ada", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, contacts, 1)
+}
+
+func TestTenantScopedUserSearch(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(rbacSchema)
+	assert.NoError(t, err)
+	users := &auth.UserRepo{DB: &db.DB{Connection: conn}}
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "root-clerk", Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1}))
+	assert.NoError(t, users.CreateUser(&auth.User{Username: "acme-clerk", Password: "x", CreatedAt: time.Now(), CreatedBy: "test", StatusID: 1, TenantID: 2}))
+
+	found, err := users.GetUsersBySearchText(2, "clerk", 10, 0)
+	assert.NoError(t, err)
+	assert.Len(t, found, 1)
+	assert.Equal(t, "acme-clerk", found[0].Username)
+}
@@ -0,0 +1,163 @@
+package test
+
+import (
+	"api/internal/auth"
+	"api/internal/cache"
+	"api/internal/db"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const tokenSchema = `
+CREATE TABLE refresh_tokens (
+    token_id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    user_id INTEGER NOT NULL,
+    user_name TEXT NOT NULL,
+    token_hash TEXT NOT NULL UNIQUE,
+    issued_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    used_at DATETIME,
+    revoked_at DATETIME,
+    replaced_by TEXT,
+    access_jti TEXT NOT NULL,
+    access_expires_at DATETIME NOT NULL,
+    amr TEXT NOT NULL DEFAULT '',
+    client_id TEXT NOT NULL DEFAULT '',
+    scope TEXT NOT NULL DEFAULT ''
+);
+CREATE TABLE revoked_tokens (
+    jti TEXT PRIMARY KEY,
+    user_id INTEGER NOT NULL,
+    expires_at DATETIME NOT NULL,
+    revoked_at DATETIME NOT NULL,
+    reason TEXT NOT NULL DEFAULT ''
+);`
+
+func setupTestTokens(t *testing.T, refreshTTL time.Duration) *auth.TokenService {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+
+	_, err = conn.Exec(tokenSchema)
+	assert.NoError(t, err)
+
+	keys, err := auth.NewKeySet(t.TempDir(), auth.AlgorithmEdDSA, 0, time.Hour)
+	assert.NoError(t, err)
+
+	repo := &auth.TokenRepo{DB: &db.DB{Connection: conn}}
+	return auth.NewTokenServiceWithRepo(repo, cache.NewMemoryClient(), keys, 15*time.Minute, refreshTTL)
+}
+
+func accessTokenID(t *testing.T, service *auth.TokenService, tokens *auth.JwtToken) string {
+	claims, err := service.ParseAccessToken(tokens.Token)
+	assert.NoError(t, err)
+	assert.NotEmpty(t, claims.Id)
+	return claims.Id
+}
+
+func TestTokenRefreshRotation(t *testing.T) {
+	service := setupTestTokens(t, time.Hour)
+
+	first, err := service.IssueTokens(7, "alice", []string{auth.AMRPassword})
+	assert.NoError(t, err)
+	assert.NotEmpty(t, first.RefreshToken)
+
+	claims, err := service.ParseAccessToken(first.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, 7, claims.UserID)
+	assert.Equal(t, "alice", claims.Username)
+	assert.Equal(t, []string{auth.AMRPassword}, claims.AMR)
+
+	second, err := service.Refresh(first.RefreshToken)
+	assert.NoError(t, err)
+	assert.NotEqual(t, first.RefreshToken, second.RefreshToken)
+	assert.NotEqual(t, accessTokenID(t, service, first), accessTokenID(t, service, second))
+
+	claims, err = service.ParseAccessToken(second.Token)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{auth.AMRPassword}, claims.AMR, "refreshed tokens keep the login's amr")
+
+	third, err := service.Refresh(second.RefreshToken)
+	assert.NoError(t, err)
+
+	// Replaying a used token revokes the whole family, including the newest tokens
+	_, err = service.Refresh(first.RefreshToken)
+	assert.ErrorIs(t, err, auth.ErrRefreshTokenReused)
+
+	_, err = service.Refresh(third.RefreshToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+
+	revoked, err := service.IsRevoked(accessTokenID(t, service, third))
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	// Other logins are unaffected
+	other, err := service.IssueTokens(7, "alice", []string{auth.AMRPassword})
+	assert.NoError(t, err)
+	_, err = service.Refresh(other.RefreshToken)
+	assert.NoError(t, err)
+}
+
+func TestTokenRefreshInvalid(t *testing.T) {
+	service := setupTestTokens(t, -time.Minute)
+
+	expired, err := service.IssueTokens(1, "bob", []string{auth.AMRPassword})
+	assert.NoError(t, err)
+
+	for _, value := range []string{"", "not-a-token", expired.RefreshToken} {
+		_, err := service.Refresh(value)
+		assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken, value)
+	}
+}
+
+func TestTokenRevoke(t *testing.T) {
+	service := setupTestTokens(t, time.Hour)
+
+	tokens, err := service.IssueTokens(3, "carol", []string{auth.AMRPassword})
+	assert.NoError(t, err)
+	jti := accessTokenID(t, service, tokens)
+
+	// A negative answer is cached, but revoking overwrites it immediately
+	revoked, err := service.IsRevoked(jti)
+	assert.NoError(t, err)
+	assert.False(t, revoked)
+
+	claims, err := service.ParseAccessToken(tokens.Token)
+	assert.NoError(t, err)
+	assert.NoError(t, service.Revoke(claims, tokens.RefreshToken))
+
+	revoked, err = service.IsRevoked(jti)
+	assert.NoError(t, err)
+	assert.True(t, revoked)
+
+	_, err = service.Refresh(tokens.RefreshToken)
+	assert.ErrorIs(t, err, auth.ErrInvalidRefreshToken)
+
+	// Unknown refresh tokens and tokens without a jti are ignored
+	assert.NoError(t, service.Revoke(&auth.JwtClaims{}, "unknown"))
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	client := cache.NewMemoryClient()
+
+	assert.NoError(t, client.SetWithTTL("a", "1", time.Hour))
+	assert.NoError(t, client.SetWithTTL("b", "2", time.Nanosecond))
+	time.Sleep(time.Millisecond)
+
+	value, err := client.Get("a")
+	assert.NoError(t, err)
+	assert.Equal(t, "1", value)
+
+	_, err = client.Get("b")
+	assert.Error(t, err)
+
+	client.Removes("a")
+	_, err = client.Get("a")
+	assert.Error(t, err)
+}
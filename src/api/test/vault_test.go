@@ -0,0 +1,112 @@
+package test
+
+import (
+	"api/internal/converter"
+	"api/internal/db"
+	"api/internal/vault"
+	"database/sql"
+	"testing"
+	"time"
+
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLuhnValid(t *testing.T) {
+	assert.True(t, vault.LuhnValid("4111111111111111"))
+	assert.True(t, vault.LuhnValid("378282246310005"))
+	assert.False(t, vault.LuhnValid("4111111111111112"))
+	assert.False(t, vault.LuhnValid("4111a11111111111"))
+	assert.False(t, vault.LuhnValid(""))
+}
+
+func TestDetectBrand(t *testing.T) {
+	cases := map[string]vault.CardBrand{
+		"4111111111111111": vault.BrandVisa,
+		"5555555555554444": vault.BrandMastercard,
+		"2223003122003222": vault.BrandMastercard,
+		"378282246310005":  vault.BrandAmex,
+		"6011111111111117": vault.BrandDiscover,
+		"3530111333300000": vault.BrandJCB,
+		"1234567812345670": vault.BrandUnknown,
+	}
+	for number, brand := range cases {
+		assert.Equal(t, brand, vault.DetectBrand(number), number)
+	}
+}
+
+func TestValidateCard(t *testing.T) {
+	now := time.Date(2025, 3, 15, 0, 0, 0, 0, time.UTC)
+
+	number, brand, month, year, err := vault.ValidateCard(vault.CardRequest{
+		CardNumber: "4111 1111 1111 1111",
+		ExpiryDate: "03/25",
+		CVV:        "123",
+	}, now)
+	assert.NoError(t, err)
+	assert.Equal(t, "4111111111111111", number)
+	assert.Equal(t, vault.BrandVisa, brand)
+	assert.Equal(t, 3, month)
+	assert.Equal(t, 2025, year)
+
+	_, _, _, _, err = vault.ValidateCard(vault.CardRequest{CardNumber: "4111111111111111", ExpiryDate: "02/2025"}, now)
+	assert.EqualError(t, err, "expiry_date: card is expired")
+
+	_, _, _, _, err = vault.ValidateCard(vault.CardRequest{CardNumber: "4111111111111112", ExpiryDate: "12/30"}, now)
+	assert.EqualError(t, err, "card_number: failed checksum validation")
+
+	_, _, _, _, err = vault.ValidateCard(vault.CardRequest{CardNumber: "378282246310005", ExpiryDate: "12/30", CVV: "123"}, now)
+	assert.EqualError(t, err, "cvv: invalid length")
+
+	_, _, _, _, err = vault.ValidateCard(vault.CardRequest{CardNumber: "4111111111111111", ExpiryDate: "13/30"}, now)
+	assert.EqualError(t, err, "expiry_date: invalid month")
+}
+
+func TestMaskCreditCard(t *testing.T) {
+	assert.Equal(t, "4111****1111", converter.MaskCreditCardNumer("4111-1111-1111-1111"))
+	assert.Equal(t, "***", converter.MaskCreditCardNumer("411"))
+	assert.Equal(t, "", converter.MaskCreditCardNumer(""))
+	assert.Equal(t, "**", converter.MaskCreditCardExpiryDate("12"))
+	assert.Equal(t, "***", converter.MaskCreditCardCVV("1"))
+}
+
+func TestCardTokenOwnership(t *testing.T) {
+	conn, err := sql.Open("sqlite3", ":memory:")
+	assert.NoError(t, err)
+	conn.SetMaxOpenConns(1)
+	_, err = conn.Exec(`CREATE TABLE card_tokens (
+		token TEXT PRIMARY KEY, fingerprint TEXT NOT NULL, encrypted_card TEXT NOT NULL,
+		brand TEXT NOT NULL, masked_number TEXT NOT NULL, last4 TEXT NOT NULL,
+		expiry_month INTEGER NOT NULL, expiry_year INTEGER NOT NULL, cardholder_name TEXT,
+		created_at DATETIME NOT NULL, created_by TEXT NOT NULL, status_id INTEGER NOT NULL)`)
+	assert.NoError(t, err)
+
+	v := vault.NewVaultWithRepo(&vault.VaultRepo{DB: &db.DB{Connection: conn}}, "6368616e676520746869732070617373776f726420746f206120736563726574")
+	card := vault.CardRequest{CardNumber: "4111111111111111", ExpiryDate: "12/40", CVV: "123"}
+
+	alice, err := v.Tokenize(card, "alice")
+	assert.NoError(t, err)
+	again, err := v.Tokenize(card, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, alice.Token, again.Token)
+
+	// The same card tokenized by someone else gets a token of its own
+	bob, err := v.Tokenize(card, "bob")
+	assert.NoError(t, err)
+	assert.NotEqual(t, alice.Token, bob.Token)
+
+	// Other users' tokens cannot be read or deleted
+	_, err = v.GetToken(alice.Token, "bob")
+	assert.ErrorIs(t, err, vault.ErrTokenNotFound)
+	assert.ErrorIs(t, v.DeleteToken(alice.Token, "bob"), vault.ErrTokenNotFound)
+
+	token, err := v.GetToken(alice.Token, "alice")
+	assert.NoError(t, err)
+	assert.Equal(t, "1111", token.Last4)
+
+	assert.NoError(t, v.DeleteToken(alice.Token, "alice"))
+	assert.ErrorIs(t, v.DeleteToken(alice.Token, "alice"), vault.ErrTokenNotFound)
+	_, err = v.Detokenize(bob.Token)
+	assert.NoError(t, err)
+}